@@ -20,6 +20,7 @@ var (
 	flagID          string
 	flagManifest    string
 	flagMethod      string
+	flagNonRoot     string
 	flagPush        string
 	flagRack        string
 	flagUrl         string
@@ -50,6 +51,7 @@ func execute() error {
 	fs.StringVar(&flagID, "id", "latest", "build id")
 	fs.StringVar(&flagManifest, "manifest", "", "path to app manifest")
 	fs.StringVar(&flagMethod, "method", "", "source method")
+	fs.StringVar(&flagNonRoot, "require-non-root", "false", "warn about services without a non-root user")
 	fs.StringVar(&flagPush, "push", "", "push to registry")
 	fs.StringVar(&flagRack, "rack", "convox", "rack name")
 	fs.StringVar(&flagUrl, "url", "", "source url")
@@ -94,22 +96,27 @@ func execute() error {
 		flagRack = v
 	}
 
+	if v := os.Getenv("BUILD_REQUIRE_NON_ROOT"); v != "" {
+		flagNonRoot = v
+	}
+
 	if v := os.Getenv("BUILD_URL"); v != "" {
 		flagUrl = v
 	}
 
 	opts := build.Options{
-		App:         flagApp,
-		Auth:        flagAuth,
-		Cache:       flagCache == "true",
-		Development: flagDevelopment == "true",
-		EnvWrapper:  flagEnvWrapper == "true",
-		Generation:  flagGeneration,
-		Id:          flagID,
-		Manifest:    flagManifest,
-		Push:        flagPush,
-		Rack:        flagRack,
-		Source:      flagUrl,
+		App:            flagApp,
+		Auth:           flagAuth,
+		Cache:          flagCache == "true",
+		Development:    flagDevelopment == "true",
+		EnvWrapper:     flagEnvWrapper == "true",
+		Generation:     flagGeneration,
+		Id:             flagID,
+		Manifest:       flagManifest,
+		Push:           flagPush,
+		Rack:           flagRack,
+		RequireNonRoot: flagNonRoot == "true",
+		Source:         flagUrl,
 	}
 
 	b, err := build.New(opts)
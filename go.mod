@@ -36,6 +36,7 @@ require (
 	github.com/stvp/rollbar v0.5.1
 	github.com/twmb/algoimpl v0.0.0-20170717182524-076353e90b94
 	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
+	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4
 	gopkg.in/cheggaaa/pb.v1 v1.0.28
 	gopkg.in/yaml.v2 v2.2.2
 	k8s.io/api v0.0.0-20180628040859-072894a440bd
@@ -1,7 +1,10 @@
 package api
 
 import (
+	"fmt"
+	"net/http"
 	"reflect"
+	"strings"
 
 	"github.com/convox/rack/pkg/structs"
 	"github.com/convox/rack/provider"
@@ -35,6 +38,8 @@ func NewWithProvider(p structs.Provider) *Server {
 
 	s.Server.Router.Router = s.Server.Router.Router.SkipClean(true)
 
+	s.Server.Router.Router.Use(actor)
+
 	// s.Router.HandleFunc("/debug/pprof/", pprof.Index)
 	// s.Router.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
 	// s.Router.HandleFunc("/debug/pprof/profile", pprof.Profile)
@@ -61,6 +66,24 @@ func (s *Server) authenticate(next stdapi.HandlerFunc) stdapi.HandlerFunc {
 	}
 }
 
+// actor records the caller of a request on its context so providers can attribute changes
+// the caller is the remote address, prefixed with the basic auth username when the client sends one
+func actor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := r.RemoteAddr
+
+		if ff := r.Header.Get("X-Forwarded-For"); ff != "" {
+			a = strings.TrimSpace(strings.Split(ff, ",")[0])
+		}
+
+		if user, _, ok := r.BasicAuth(); ok && user != "" {
+			a = fmt.Sprintf("%s@%s", user, a)
+		}
+
+		next.ServeHTTP(w, r.WithContext(structs.ActorContext(r.Context(), a)))
+	})
+}
+
 func (s *Server) hook(name string, args ...interface{}) error {
 	vfn, ok := reflect.TypeOf(s).MethodByName(name)
 	if !ok {
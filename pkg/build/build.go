@@ -37,6 +37,8 @@ type Options struct {
 	Push        string
 	Rack        string
 	Source      string
+
+	RequireNonRoot bool
 }
 
 type Build struct {
@@ -138,7 +140,7 @@ func (bb *Build) execute() error {
 		return err
 	}
 
-	data, err := ioutil.ReadFile(bb.Manifest)
+	data, err := bb.manifestData(".")
 	if err != nil {
 		return err
 	}
@@ -165,6 +167,29 @@ func (bb *Build) execute() error {
 	return nil
 }
 
+// manifestData reads the build manifest from dir
+// generation 2 apps without a convox.yml fall back to a manifest synthesized from their Dockerfile
+func (bb *Build) manifestData(dir string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, bb.Manifest))
+	if os.IsNotExist(err) {
+		if bb.Generation == "2" && bb.Manifest == "convox.yml" {
+			m, err := manifest.LoadDefault(dir)
+			if err != nil {
+				return nil, err
+			}
+
+			return m.Raw(), nil
+		}
+
+		return nil, fmt.Errorf("no such file: %s", bb.Manifest)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
 func (bb *Build) login() error {
 	var auth map[string]struct {
 		Username string
@@ -244,13 +269,7 @@ func (bb *Build) buildGeneration1(dir string) error {
 }
 
 func (bb *Build) buildGeneration2(dir string) error {
-	config := filepath.Join(dir, bb.Manifest)
-
-	if _, err := os.Stat(config); os.IsNotExist(err) {
-		return fmt.Errorf("no such file: %s", bb.Manifest)
-	}
-
-	data, err := ioutil.ReadFile(config)
+	data, err := bb.manifestData(dir)
 	if err != nil {
 		return err
 	}
@@ -265,6 +284,10 @@ func (bb *Build) buildGeneration2(dir string) error {
 		return err
 	}
 
+	for _, w := range m.Lint(manifest.LintOptions{RequireNonRoot: bb.RequireNonRoot}) {
+		bb.Printf("WARNING: %s\n", w)
+	}
+
 	prefix := fmt.Sprintf("%s/%s", bb.Rack, bb.App)
 
 	builds := map[string]manifest.ServiceBuild{}
@@ -282,6 +305,10 @@ func (bb *Build) buildGeneration2(dir string) error {
 		} else {
 			builds[hash] = s.Build
 			tags[hash] = append(tags[hash], to)
+
+			if s.ImageTag != "" {
+				tags[hash] = append(tags[hash], s.ImageTag)
+			}
 		}
 
 		if bb.Push != "" {
@@ -351,7 +378,7 @@ func (bb *Build) buildGeneration2(dir string) error {
 }
 
 func (bb *Build) success() error {
-	logs, err := bb.Provider.ObjectStore(bb.App, fmt.Sprintf("build/%s/logs", bb.Id), bytes.NewReader(bb.logs.Bytes()), structs.ObjectStoreOptions{})
+	logs, err := bb.Provider.ObjectStore(bb.App, fmt.Sprintf("build/%s/logs", bb.Id), bytes.NewReader(bb.logs.Bytes()), bb.logsObjectOptions())
 	if err != nil {
 		return err
 	}
@@ -384,12 +411,20 @@ func (bb *Build) success() error {
 	return nil
 }
 
+// logsObjectOptions makes build logs download as <build>.log when fetched through a presigned url
+func (bb *Build) logsObjectOptions() structs.ObjectStoreOptions {
+	return structs.ObjectStoreOptions{
+		ContentDisposition: options.String(fmt.Sprintf("attachment; filename=%q", bb.Id+".log")),
+		ContentType:        options.String("text/plain; charset=utf-8"),
+	}
+}
+
 func (bb *Build) fail(buildError error) error {
 	bb.Printf("ERROR: %s\n", buildError)
 
 	bb.Provider.EventSend("build:create", structs.EventSendOptions{Data: map[string]string{"app": bb.App, "id": bb.Id}, Error: options.String(buildError.Error())})
 
-	logs, err := bb.Provider.ObjectStore(bb.App, fmt.Sprintf("build/%s/logs", bb.Id), bytes.NewReader(bb.logs.Bytes()), structs.ObjectStoreOptions{})
+	logs, err := bb.Provider.ObjectStore(bb.App, fmt.Sprintf("build/%s/logs", bb.Id), bytes.NewReader(bb.logs.Bytes()), bb.logsObjectOptions())
 	if err != nil {
 		return err
 	}
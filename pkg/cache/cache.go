@@ -91,16 +91,23 @@ func Clear(collection string, key interface{}) error {
 }
 
 func ClearPrefix(collection string, prefix string) error {
+	return ClearMatch(collection, func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// ClearMatch removes the items of a collection with string keys that match
+func ClearMatch(collection string, match func(key string) bool) error {
 	lock.Lock()
 	defer lock.Unlock()
 
 	for k := range cache[collection] {
-		match, err := hashPrefix(k, prefix)
+		ok, err := hashMatch(k, match)
 		if err != nil {
 			return err
 		}
 
-		if match {
+		if ok {
 			delete(cache[collection], k)
 		}
 	}
@@ -117,7 +124,7 @@ func hashKey(key interface{}) (string, error) {
 	return string(data), nil
 }
 
-func hashPrefix(hash string, prefix string) (bool, error) {
+func hashMatch(hash string, match func(string) bool) (bool, error) {
 	var w interface{}
 
 	if err := json.Unmarshal([]byte(hash), &w); err != nil {
@@ -125,9 +132,7 @@ func hashPrefix(hash string, prefix string) (bool, error) {
 	}
 
 	if s, ok := w.(string); ok {
-		if strings.HasPrefix(s, prefix) {
-			return true, nil
-		}
+		return match(s), nil
 	}
 
 	return false, nil
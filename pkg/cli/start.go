@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/convox/rack/pkg/start"
 	"github.com/convox/rack/pkg/structs"
@@ -20,11 +21,16 @@ func init() {
 			flagRack,
 			flagApp,
 			stdcli.StringFlag("manifest", "m", "manifest file"),
+			stdcli.IntFlag("context-warn", "", "warn when a build context is larger than this many megabytes (generation 1 only)"),
 			stdcli.StringFlag("generation", "g", "generation"),
+			stdcli.StringFlag("filter", "", "only show output from these services, comma separated (generation 1 only)"),
+			stdcli.StringFlag("log-dir", "", "write each service log to a file in this directory (generation 1 only)"),
 			stdcli.BoolFlag("no-build", "", "skip build"),
 			stdcli.BoolFlag("no-cache", "", "build withoit layer cache"),
 			stdcli.BoolFlag("no-sync", "", "do not sync local changes into the running containers"),
 			stdcli.IntFlag("shift", "s", "shift local port numbers (generation 1 only)"),
+			stdcli.DurationFlag("since", "", "replay logs from --log-dir written within this duration (generation 1 only)"),
+			stdcli.StringFlag("timers", "", "run timers on their schedule locally, on or off (default on)"),
 		},
 		Usage: "[service] [service...]",
 	})
@@ -42,7 +48,21 @@ func Start(rack sdk.Interface, c *stdcli.Context) error {
 			Cache:    !c.Bool("no-cache"),
 			Manifest: c.String("manifest"),
 			Shift:    c.Int("shift"),
+			LogDir:   c.String("log-dir"),
 			Sync:     !c.Bool("no-sync"),
+			Timers:   c.String("timers") != "off",
+		}
+
+		if f := c.String("filter"); f != "" {
+			opts.Filter = strings.Split(f, ",")
+		}
+
+		if mb := c.Int("context-warn"); mb > 0 {
+			opts.ContextWarnSize = int64(mb) * 1024 * 1024
+		}
+
+		if since, ok := c.Value("since").(time.Duration); ok {
+			opts.Since = since
 		}
 
 		if len(c.Args) >= 1 {
@@ -100,6 +120,7 @@ func Start(rack sdk.Interface, c *stdcli.Context) error {
 		Manifest: c.String("manifest"),
 		Provider: p,
 		Sync:     !c.Bool("no-sync"),
+		Timers:   c.String("timers") != "off",
 	}
 
 	if len(c.Args) > 0 {
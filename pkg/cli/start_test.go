@@ -3,6 +3,7 @@ package cli_test
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/convox/rack/pkg/cli"
 	mocksdk "github.com/convox/rack/pkg/mock/sdk"
@@ -20,10 +21,11 @@ func TestStart1(t *testing.T) {
 		cli.Starter = ms
 
 		opts := start.Options1{
-			App:   "app1",
-			Build: true,
-			Cache: true,
-			Sync:  true,
+			App:    "app1",
+			Build:  true,
+			Cache:  true,
+			Sync:   true,
+			Timers: true,
 		}
 
 		ms.On("Start1", mock.Anything, opts).Return(nil)
@@ -42,10 +44,11 @@ func TestStart1Error(t *testing.T) {
 		cli.Starter = ms
 
 		opts := start.Options1{
-			App:   "app1",
-			Build: true,
-			Cache: true,
-			Sync:  true,
+			App:    "app1",
+			Build:  true,
+			Cache:  true,
+			Sync:   true,
+			Timers: true,
 		}
 
 		ms.On("Start1", mock.Anything, opts).Return(fmt.Errorf("err1"))
@@ -64,19 +67,24 @@ func TestStart1Options(t *testing.T) {
 		cli.Starter = ms
 
 		opts := start.Options1{
-			App:      "app1",
-			Build:    false,
-			Cache:    false,
-			Command:  []string{"bin/command", "args"},
-			Manifest: "manifest1",
-			Service:  "service1",
-			Shift:    3000,
-			Sync:     false,
+			App:             "app1",
+			Build:           false,
+			Cache:           false,
+			Command:         []string{"bin/command", "args"},
+			ContextWarnSize: 50 * 1024 * 1024,
+			Filter:          []string{"service1", "service2"},
+			LogDir:          "logs",
+			Manifest:        "manifest1",
+			Service:         "service1",
+			Shift:           3000,
+			Since:           5 * time.Minute,
+			Sync:            false,
+			Timers:          false,
 		}
 
 		ms.On("Start1", mock.Anything, opts).Return(nil)
 
-		res, err := testExecute(e, "start -g 1 -a app1 -m manifest1 --no-build --no-cache --no-sync -s 3000 service1 bin/command args", nil)
+		res, err := testExecute(e, "start -g 1 -a app1 -m manifest1 --no-build --no-cache --no-sync -s 3000 --filter service1,service2 --log-dir logs --since 5m --context-warn 50 --timers off service1 bin/command args", nil)
 		require.NoError(t, err)
 		require.Equal(t, 0, res.Code)
 		res.RequireStderr(t, []string{""})
@@ -99,6 +107,7 @@ func TestStart2(t *testing.T) {
 			Cache:    true,
 			Provider: i,
 			Sync:     true,
+			Timers:   true,
 		}
 
 		ms.On("Start2", mock.Anything, mock.Anything, opts).Return(nil)
@@ -128,6 +137,7 @@ func TestStart2Error(t *testing.T) {
 			Cache:    true,
 			Provider: i,
 			Sync:     true,
+			Timers:   true,
 		}
 
 		ms.On("Start2", mock.Anything, mock.Anything, opts).Return(fmt.Errorf("err1"))
@@ -159,13 +169,14 @@ func TestStart2Options(t *testing.T) {
 			Provider: i,
 			Services: []string{"service1", "service2"},
 			Sync:     false,
+			Timers:   false,
 		}
 
 		ms.On("Start2", mock.Anything, mock.Anything, opts).Return(nil)
 
 		i.On("SystemGet").Return(fxSystemLocal(), nil)
 
-		res, err := testExecute(e, "start -g 2 -a app1 -m manifest1 --no-build --no-cache --no-sync service1 service2", nil)
+		res, err := testExecute(e, "start -g 2 -a app1 -m manifest1 --no-build --no-cache --no-sync --timers off service1 service2", nil)
 		require.NoError(t, err)
 		require.Equal(t, 0, res.Code)
 		res.RequireStderr(t, []string{""})
@@ -188,6 +199,7 @@ func TestStart2Remote(t *testing.T) {
 			require.Equal(t, true, opts.Build)
 			require.Equal(t, true, opts.Cache)
 			require.Equal(t, true, opts.Sync)
+			require.Equal(t, true, opts.Timers)
 			p := opts.Provider.(*sdk.Client)
 			require.Equal(t, "https", p.Client.Endpoint.Scheme)
 			require.Equal(t, "rack.dev", p.Client.Endpoint.Host)
@@ -0,0 +1,81 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	AppFeatureEcsExec      = "ecs-exec"
+	AppFeatureReadonlyRoot = "readonly-root"
+)
+
+// AppFeatures are the feature flags an app can turn on under app.features and what each one does
+var AppFeatures = map[string]string{
+	AppFeatureEcsExec:      "enable ecs exec on services so processes can be reached without the instance agent",
+	AppFeatureReadonlyRoot: "mount the root filesystem of service containers read only",
+}
+
+// AppGenerations are the generations a manifest can pin its app to
+var AppGenerations = []string{"2"}
+
+// AppOptions are settings for the app as a whole rather than one of its services
+type AppOptions struct {
+	Features      map[string]bool `yaml:"features,omitempty"`
+	Generation    string          `yaml:"generation,omitempty"`
+	Name          string          `yaml:"name,omitempty"`
+	PrivateBuilds bool            `yaml:"private_builds,omitempty"`
+	Timezone      string          `yaml:"timezone,omitempty"`
+}
+
+// AppFeatureNames returns the known feature flags in order
+func AppFeatureNames() []string {
+	names := []string{}
+
+	for name := range AppFeatures {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Feature returns true if the app turns on the feature flag name
+func (o AppOptions) Feature(name string) bool {
+	return o.Features[name]
+}
+
+func (m *Manifest) validateApp() error {
+	if n := m.App.Name; n != "" && !nameValidator.MatchString(n) {
+		return fmt.Errorf("app name %s invalid, %s", n, ValidNameDescription)
+	}
+
+	if g := m.App.Generation; g != "" && !containsString(AppGenerations, g) {
+		return fmt.Errorf("app generation %s invalid, must be one of %s", g, strings.Join(AppGenerations, ", "))
+	}
+
+	if tz := m.App.Timezone; tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return fmt.Errorf("app timezone %s invalid, must be an iana time zone name", tz)
+		}
+	}
+
+	features := []string{}
+
+	for name := range m.App.Features {
+		features = append(features, name)
+	}
+
+	sort.Strings(features)
+
+	for _, name := range features {
+		if _, ok := AppFeatures[name]; !ok {
+			return fmt.Errorf("app feature %s invalid, must be one of %s", name, strings.Join(AppFeatureNames(), ", "))
+		}
+	}
+
+	return nil
+}
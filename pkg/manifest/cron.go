@@ -0,0 +1,249 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchYears bounds how far ahead NextScheduleTime looks for a cron expression to match
+const cronSearchYears = 5
+
+// cronUnsupported matches the last day, nearest weekday and nth weekday forms that are not evaluated locally
+var cronUnsupported = regexp.MustCompile(`^(?i:[0-9]*L|[0-9]*W|LW|.*#.*)$`)
+
+// cronFieldSpec is the range and names a field of a cloudwatch events cron expression accepts
+type cronFieldSpec struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+// cronFieldSpecs are the minutes, hours, day of month, month, day of week and year fields in order
+// cloudwatch events numbers the days of the week from 1 for sunday
+var cronFieldSpecs = []cronFieldSpec{
+	{name: "minutes", min: 0, max: 59},
+	{name: "hours", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6, "JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12}},
+	{name: "day of week", min: 1, max: 7, names: map[string]int{"SUN": 1, "MON": 2, "TUE": 3, "WED": 4, "THU": 5, "FRI": 6, "SAT": 7}},
+	{name: "year", min: 1970, max: 2199},
+}
+
+// cronField is the set of values a field matches, any is set for * and ? which leave the field unrestricted
+type cronField struct {
+	any    bool
+	values map[int]bool
+}
+
+// cronSchedule is a parsed cloudwatch events cron expression
+type cronSchedule []cronField
+
+// NextScheduleTime returns the first time after after that a schedule fires, or the zero time when it never fires again
+// cron expressions are evaluated in the location of after, cloudwatch events evaluates them in utc
+// local times skipped by a daylight saving change never fire and repeated ones fire once
+func NextScheduleTime(schedule string, after time.Time) (time.Time, error) {
+	expr, err := ScheduleExpression(schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch {
+	case strings.HasPrefix(expr, "at("):
+		at, err := time.Parse("2006-01-02T15:04:05", strings.TrimSuffix(strings.TrimPrefix(expr, "at("), ")"))
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if !at.After(after) {
+			return time.Time{}, nil
+		}
+
+		return at.In(after.Location()), nil
+	case strings.HasPrefix(expr, "rate("):
+		parts := strings.Fields(strings.TrimSuffix(strings.TrimPrefix(expr, "rate("), ")"))
+
+		n, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		unit := map[string]time.Duration{"minute": time.Minute, "hour": time.Hour, "day": 24 * time.Hour}[strings.TrimSuffix(parts[1], "s")]
+
+		return after.Add(time.Duration(n) * unit), nil
+	}
+
+	c, err := parseCron(strings.TrimSuffix(strings.TrimPrefix(expr, "cron("), ")"))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return c.next(after), nil
+}
+
+// Next returns the first time after after that the timer fires, or the zero time when it never fires again
+func (t Timer) Next(after time.Time) (time.Time, error) {
+	return NextScheduleTime(t.Schedule, after)
+}
+
+func parseCron(expr string) (cronSchedule, error) {
+	fields := strings.Fields(expr)
+
+	if len(fields) != len(cronFieldSpecs) {
+		return nil, fmt.Errorf("invalid cron expression %q, must have %d fields", expr, len(cronFieldSpecs))
+	}
+
+	c := make(cronSchedule, len(fields))
+
+	for i, f := range fields {
+		cf, err := parseCronField(f, cronFieldSpecs[i])
+		if err != nil {
+			return nil, err
+		}
+
+		c[i] = cf
+	}
+
+	return c, nil
+}
+
+// parseCronField reads a comma separated list of values, ranges and steps such as 1,15 or MON-FRI or */10
+func parseCronField(field string, spec cronFieldSpec) (cronField, error) {
+	if field == "*" || field == "?" {
+		return cronField{any: true}, nil
+	}
+
+	cf := cronField{values: map[int]bool{}}
+
+	for _, part := range strings.Split(field, ",") {
+		if cronUnsupported.MatchString(part) {
+			return cf, fmt.Errorf("cron %s %s is not supported, L, W and # can not be evaluated locally", spec.name, part)
+		}
+
+		rng, step := part, 1
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s < 1 {
+				return cf, fmt.Errorf("cron %s step %s invalid, must be a positive integer", spec.name, part[i+1:])
+			}
+
+			rng, step = part[:i], s
+		}
+
+		lo, hi := spec.min, spec.max
+
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+
+			l, err := cronValue(bounds[0], spec)
+			if err != nil {
+				return cf, err
+			}
+
+			h, err := cronValue(bounds[1], spec)
+			if err != nil {
+				return cf, err
+			}
+
+			lo, hi = l, h
+		default:
+			v, err := cronValue(rng, spec)
+			if err != nil {
+				return cf, err
+			}
+
+			// a value with a step runs from the value to the end of the range
+			lo = v
+
+			if !strings.Contains(part, "/") {
+				hi = v
+			}
+		}
+
+		if lo > hi {
+			return cf, fmt.Errorf("cron %s range %s invalid, must not run backwards", spec.name, rng)
+		}
+
+		for v := lo; v <= hi; v += step {
+			cf.values[v] = true
+		}
+	}
+
+	return cf, nil
+}
+
+func cronValue(s string, spec cronFieldSpec) (int, error) {
+	if v, ok := spec.names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < spec.min || v > spec.max {
+		return 0, fmt.Errorf("cron %s value %s invalid, must be between %d and %d", spec.name, s, spec.min, spec.max)
+	}
+
+	return v, nil
+}
+
+func (f cronField) matches(v int) bool {
+	return f.any || f.values[v]
+}
+
+// matchesDay follows cron in matching either day field when both are restricted
+func (c cronSchedule) matchesDay(t time.Time) bool {
+	dom, dow := c[2], c[4]
+
+	switch {
+	case dom.any:
+		return dow.matches(int(t.Weekday()) + 1)
+	case dow.any:
+		return dom.matches(t.Day())
+	default:
+		return dom.matches(t.Day()) || dow.matches(int(t.Weekday())+1)
+	}
+}
+
+func (c cronSchedule) next(after time.Time) time.Time {
+	loc := after.Location()
+
+	y, m, d := after.Date()
+
+	for i := 0; i < cronSearchYears*366; i++ {
+		day := time.Date(y, m, d+i, 12, 0, 0, 0, loc)
+
+		if !c[5].matches(day.Year()) || !c[3].matches(int(day.Month())) || !c.matchesDay(day) {
+			continue
+		}
+
+		for h := 0; h < 24; h++ {
+			if !c[1].matches(h) {
+				continue
+			}
+
+			for min := 0; min < 60; min++ {
+				if !c[0].matches(min) {
+					continue
+				}
+
+				t := time.Date(day.Year(), day.Month(), day.Day(), h, min, 0, 0, loc)
+
+				// a time that does not exist on the clock that day is normalized past it
+				if t.Day() != day.Day() || t.Hour() != h || t.Minute() != min {
+					continue
+				}
+
+				if t.After(after) {
+					return t
+				}
+			}
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,95 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNoManifest is returned by LoadDefault when a directory has neither a manifest nor a Dockerfile
+	ErrNoManifest = errors.New("no convox.yml or Dockerfile found")
+
+	// ErrNoServices is returned when a manifest declares an empty services section
+	ErrNoServices = errors.New("manifest declares no services")
+)
+
+// LoadDefault loads convox.yml from dir, or synthesizes a manifest with a single web service
+// for directories that only contain a Dockerfile
+func LoadDefault(dir string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "convox.yml"))
+	if err == nil {
+		return Load(data, map[string]string{})
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "docker-compose.yml")); err == nil {
+		return nil, fmt.Errorf("docker-compose.yml is a generation 1 manifest")
+	}
+
+	dockerfile, err := ioutil.ReadFile(filepath.Join(dir, "Dockerfile"))
+	if os.IsNotExist(err) {
+		return nil, ErrNoManifest
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data = defaultManifest(dockerfileExposedPort(dockerfile))
+
+	m, err := Load(data, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	m.raw = data
+
+	return m, nil
+}
+
+func defaultManifest(port int) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("services:\n  web:\n    build: .\n")
+
+	if port > 0 {
+		fmt.Fprintf(&buf, "    port: %d\n", port)
+	}
+
+	return buf.Bytes()
+}
+
+// dockerfileExposedPort returns the first tcp port exposed by a Dockerfile or 0 if there is none
+func dockerfileExposedPort(data []byte) int {
+	s := bufio.NewScanner(bytes.NewReader(data))
+
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+
+		if len(fields) < 2 || strings.ToUpper(fields[0]) != "EXPOSE" {
+			continue
+		}
+
+		for _, f := range fields[1:] {
+			parts := strings.SplitN(f, "/", 2)
+
+			if len(parts) == 2 && strings.ToLower(parts[1]) != "tcp" {
+				continue
+			}
+
+			if port, err := strconv.Atoi(parts[0]); err == nil && port > 0 {
+				return port
+			}
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,77 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var devicePermissionsValidator = regexp.MustCompile(`^[rwm]{1,3}$`)
+
+// DeviceMount passes a device of the host such as a gpu through to the containers of a service
+type DeviceMount struct {
+	HostPath      string `yaml:"host_path"`
+	ContainerPath string `yaml:"container_path,omitempty"`
+	Permissions   string `yaml:"permissions,omitempty"`
+}
+
+// PermissionNames spells out the permissions of a device as read, write and mknod
+// empty when no permissions are set, which grants all of them
+func (d DeviceMount) PermissionNames() []string {
+	names := []string{}
+
+	for _, p := range []struct {
+		flag string
+		name string
+	}{{"r", "read"}, {"w", "write"}, {"m", "mknod"}} {
+		if strings.Contains(d.Permissions, p.flag) {
+			names = append(names, p.name)
+		}
+	}
+
+	return names
+}
+
+// validateDevices returns an error if a device path is relative, its permissions are not a subset of rwm
+// or two devices of a service share a container path
+func (m *Manifest) validateDevices() error {
+	for _, s := range m.Services {
+		paths := map[string]bool{}
+
+		for _, d := range s.Devices {
+			if !strings.HasPrefix(d.HostPath, "/") {
+				return fmt.Errorf("service %s device host_path %s invalid, must be an absolute path", s.Name, d.HostPath)
+			}
+
+			if !strings.HasPrefix(d.ContainerPath, "/") {
+				return fmt.Errorf("service %s device container_path %s invalid, must be an absolute path", s.Name, d.ContainerPath)
+			}
+
+			if d.Permissions != "" && (!devicePermissionsValidator.MatchString(d.Permissions) || repeatsRune(d.Permissions)) {
+				return fmt.Errorf("service %s device %s permissions %s invalid, must be a combination of r, w and m", s.Name, d.ContainerPath, d.Permissions)
+			}
+
+			if paths[d.ContainerPath] {
+				return fmt.Errorf("service %s device container_path %s is used more than once", s.Name, d.ContainerPath)
+			}
+
+			paths[d.ContainerPath] = true
+		}
+	}
+
+	return nil
+}
+
+func repeatsRune(s string) bool {
+	seen := map[rune]bool{}
+
+	for _, r := range s {
+		if seen[r] {
+			return true
+		}
+
+		seen[r] = true
+	}
+
+	return false
+}
@@ -0,0 +1,10 @@
+package manifest
+
+// exports for testing unexported helpers from package manifest_test
+
+var ParseSizeString = parseSizeString
+
+// SetRaw sets the source returned by Raw so loaded manifests can be compared with ones built by hand
+func (m *Manifest) SetRaw(data []byte) {
+	m.raw = data
+}
@@ -1,8 +1,11 @@
 package manifest
 
 import (
+	"fmt"
 	"math/rand"
 	"regexp"
+	"strconv"
+	"strings"
 )
 
 func coalesce(ss ...string) string {
@@ -15,6 +18,16 @@ func coalesce(ss ...string) string {
 	return ""
 }
 
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
 var regexpInterpolation = regexp.MustCompile(`\$\{([^}]*?)\}`)
 
 func interpolate(data []byte, env map[string]string) ([]byte, error) {
@@ -34,3 +47,29 @@ func randomString(size int) string {
 	}
 	return string(b)
 }
+
+var regexpSizeString = regexp.MustCompile(`^(\d+)([bkmg]?)$`)
+
+// parseSizeString parses a docker compose style size such as 128m or 1g into bytes
+func parseSizeString(s string) (int64, error) {
+	m := regexpSizeString.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+
+	switch m[2] {
+	case "k":
+		n *= 1024
+	case "m":
+		n *= 1024 * 1024
+	case "g":
+		n *= 1024 * 1024 * 1024
+	}
+
+	return n, nil
+}
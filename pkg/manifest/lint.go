@@ -0,0 +1,73 @@
+package manifest
+
+import (
+	"fmt"
+)
+
+// LintOptions are the rack settings that lint rules depend on
+type LintOptions struct {
+	RequireNonRoot bool
+}
+
+// Lint returns warnings for settings that are valid but deprecated or that the rack may refuse to promote
+func (m *Manifest) Lint(opts LintOptions) []string {
+	warnings := []string{}
+
+	for _, s := range m.Services {
+		if s.legacyImage {
+			warnings = append(warnings, fmt.Sprintf("service %s sets both build and image, image is read as image_tag and will be rejected in a future release, rename it to image_tag", s.Name))
+		}
+
+		if len(s.Devices) > 0 {
+			warnings = append(warnings, fmt.Sprintf("service %s mounts devices which require the ec2 launch type, it will not start on fargate", s.Name))
+		}
+	}
+
+	if m.reservedEnv != ReservedEnvironmentError {
+		for _, r := range m.reservedEnvironment() {
+			warnings = append(warnings, fmt.Sprintf("%s, the platform sets it and may override the value", r))
+		}
+	}
+
+	if opts.RequireNonRoot {
+		for _, s := range m.rootServices() {
+			warnings = append(warnings, fmt.Sprintf("service %s does not set a non-root user, the rack will not promote it", s))
+		}
+	} else {
+		for _, s := range m.Services {
+			if u, err := ParseUser(s.User); s.User != "" && err == nil && u.Root() {
+				warnings = append(warnings, fmt.Sprintf("service %s sets user %s which runs as root, use a non-root user to harden it", s.Name, s.User))
+			}
+		}
+	}
+
+	return warnings
+}
+
+// ValidateNonRoot returns an error if a service does not set a user or sets root
+func (m *Manifest) ValidateNonRoot() error {
+	if ss := m.rootServices(); len(ss) > 0 {
+		return fmt.Errorf("service %s must set a non-root user, the rack requires non-root services", ss[0])
+	}
+
+	return nil
+}
+
+// rootServices returns the services that run as the image user or as root
+// images default to root so a service without a user is assumed to run as root
+func (m *Manifest) rootServices() []string {
+	ss := []string{}
+
+	for _, s := range m.Services {
+		if s.User == "" {
+			ss = append(ss, s.Name)
+			continue
+		}
+
+		if u, err := ParseUser(s.User); err != nil || u.Root() {
+			ss = append(ss, s.Name)
+		}
+	}
+
+	return ss
+}
@@ -1,9 +1,12 @@
 package manifest
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math/rand"
+	"path"
 	"regexp"
 	"sort"
 	"strings"
@@ -16,6 +19,12 @@ const (
 	ValidNameDescription = "must contain only lowercase alphanumeric and dashes"
 )
 
+// limits for the size of a service /dev/shm in bytes
+const (
+	SharedMemoryMinimum = 64 * 1024
+	SharedMemoryMaximum = 8 * 1024 * 1024 * 1024
+)
+
 var (
 	nameValidator = regexp.MustCompile(`^[a-z]{1}[a-z0-9-]*$`)
 )
@@ -25,15 +34,36 @@ var (
 	DefaultMem = 512
 )
 
+// ReservedEnvironment are environment variable names set by the platform
+// services that declare one are reported by Lint, or rejected when loaded with ReservedEnvironmentError
+var ReservedEnvironment = []string{
+	"APP",
+	"AWS_REGION",
+	"BUILD",
+	"BUILD_DESCRIPTION",
+	"CONVOX_ENV_KEY",
+	"CONVOX_ENV_URL",
+	"CONVOX_ENV_VARS",
+	"PORT",
+	"RACK",
+	"RACK_URL",
+	"RELEASE",
+	"SERVICE",
+}
+
 type Manifest struct {
+	App         AppOptions  `yaml:"app,omitempty"`
 	Environment Environment `yaml:"environment,omitempty"`
 	Params      Params      `yaml:"params,omitempty"`
 	Resources   Resources   `yaml:"resources,omitempty"`
 	Services    Services    `yaml:"services,omitempty"`
 	Timers      Timers      `yaml:"timers,omitempty"`
+	Volumes     Volumes     `yaml:"volumes,omitempty"`
 
-	attributes map[string]bool
-	env        map[string]string
+	attributes  map[string]bool
+	env         map[string]string
+	raw         []byte
+	reservedEnv string
 }
 
 func init() {
@@ -41,13 +71,31 @@ func init() {
 }
 
 func Load(data []byte, env map[string]string) (*Manifest, error) {
+	return LoadWithOptions(data, env, LoadOptions{})
+}
+
+// LoadWithOptions loads a manifest, resolving profile maps for opts.Profile
+func LoadWithOptions(data []byte, env map[string]string, opts LoadOptions) (*Manifest, error) {
 	var m Manifest
 
+	switch opts.ReservedEnvironment {
+	case "", ReservedEnvironmentWarn, ReservedEnvironmentError:
+	default:
+		return nil, fmt.Errorf("invalid reserved environment severity: %s", opts.ReservedEnvironment)
+	}
+
 	p, err := interpolate(data, env)
 	if err != nil {
 		return nil, err
 	}
 
+	p, err = resolveProfiles(p, opts.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	m.raw = p
+
 	if err := yaml.Unmarshal(p, &m); err != nil {
 		return nil, err
 	}
@@ -58,6 +106,7 @@ func Load(data []byte, env map[string]string) (*Manifest, error) {
 	}
 
 	m.env = map[string]string{}
+	m.reservedEnv = opts.ReservedEnvironment
 
 	for k, v := range env {
 		m.env[k] = v
@@ -118,6 +167,72 @@ func (m *Manifest) AttributeSet(name string) bool {
 	return m.attributes[name]
 }
 
+// Raw returns the manifest source after interpolation with profiles resolved, or the manifest synthesized by LoadDefault
+func (m *Manifest) Raw() []byte {
+	return m.raw
+}
+
+// Fingerprint returns a hash of the canonical form of the manifest
+// declaration order of services, resources, timers and environment does not change it
+func (m *Manifest) Fingerprint() (string, error) {
+	c := Manifest{
+		App:         m.App,
+		Environment: sortedStrings(m.Environment),
+		Params:      m.Params,
+		Resources:   append(Resources{}, m.Resources...),
+		Services:    append(Services{}, m.Services...),
+		Timers:      append(Timers{}, m.Timers...),
+		Volumes:     append(Volumes{}, m.Volumes...),
+	}
+
+	sort.Slice(c.Resources, func(i, j int) bool { return c.Resources[i].Name < c.Resources[j].Name })
+	sort.Slice(c.Services, func(i, j int) bool { return c.Services[i].Name < c.Services[j].Name })
+	sort.Slice(c.Timers, func(i, j int) bool { return c.Timers[i].Name < c.Timers[j].Name })
+	sort.Slice(c.Volumes, func(i, j int) bool { return c.Volumes[i].Name < c.Volumes[j].Name })
+
+	for i := range c.Services {
+		c.Services[i].Environment = sortedStrings(c.Services[i].Environment)
+	}
+
+	// json includes the names yaml leaves out and sorts map keys
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// Equivalent returns true if deploying other would not change anything declared by m
+func (m *Manifest) Equivalent(other *Manifest) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+
+	a, err := m.Fingerprint()
+	if err != nil {
+		return false
+	}
+
+	b, err := other.Fingerprint()
+	if err != nil {
+		return false
+	}
+
+	return a == b
+}
+
+func sortedStrings(ss []string) []string {
+	if ss == nil {
+		return nil
+	}
+
+	sorted := append([]string{}, ss...)
+	sort.Strings(sorted)
+
+	return sorted
+}
+
 func (m *Manifest) Env() map[string]string {
 	return m.env
 }
@@ -156,6 +271,26 @@ func (m *Manifest) Service(name string) (*Service, error) {
 	return nil, fmt.Errorf("no such service: %s", name)
 }
 
+// ServiceIndices assigns each service an index by alphabetical name so interleaved
+// output can give a service the same color no matter the order it appears in the manifest
+func (m *Manifest) ServiceIndices() map[string]int {
+	names := make([]string, len(m.Services))
+
+	for i, s := range m.Services {
+		names[i] = s.Name
+	}
+
+	sort.Strings(names)
+
+	indices := map[string]int{}
+
+	for i, name := range names {
+		indices[name] = i
+	}
+
+	return indices
+}
+
 func (m *Manifest) ServiceEnvironment(service string) (map[string]string, error) {
 	s, err := m.Service(service)
 	if err != nil {
@@ -204,6 +339,14 @@ func (m *Manifest) ServiceEnvironment(service string) (map[string]string, error)
 }
 
 func (m *Manifest) Validate() error {
+	if m.AttributeSet("services") && len(m.Services) == 0 {
+		return ErrNoServices
+	}
+
+	if err := m.validateApp(); err != nil {
+		return err
+	}
+
 	if err := m.validateEnv(); err != nil {
 		return err
 	}
@@ -214,15 +357,109 @@ func (m *Manifest) Validate() error {
 		}
 	}
 
+	if err := m.validateSharedMemory(); err != nil {
+		return err
+	}
+
+	if err := m.validateHealthCheckPorts(); err != nil {
+		return err
+	}
+
+	if err := m.validateReservedEnv(); err != nil {
+		return err
+	}
+
+	if err := m.validateUsers(); err != nil {
+		return err
+	}
+
+	if err := m.validateSources(); err != nil {
+		return err
+	}
+
+	if err := m.validateImages(); err != nil {
+		return err
+	}
+
+	if err := m.validateCapabilities(); err != nil {
+		return err
+	}
+
+	if err := m.validateScale(); err != nil {
+		return err
+	}
+
+	if err := m.validateDeployments(); err != nil {
+		return err
+	}
+
+	if err := m.validateVolumes(); err != nil {
+		return err
+	}
+
+	if err := m.validateDevices(); err != nil {
+		return err
+	}
+
+	if err := m.validateSidecars(); err != nil {
+		return err
+	}
+
 	for _, r := range m.Resources {
 		if strings.TrimSpace(r.Type) == "" {
 			return fmt.Errorf("resource type can not be blank")
 		}
 	}
 
+	for _, t := range m.Timers {
+		if _, err := t.ScheduleExpression(); err != nil {
+			return fmt.Errorf("timer %s schedule invalid, %s", t.Name, err)
+		}
+
+		if t.Timezone != "" {
+			if _, err := time.LoadLocation(t.Timezone); err != nil {
+				return fmt.Errorf("timer %s timezone %s invalid, must be an iana time zone name", t.Name, t.Timezone)
+			}
+		}
+	}
+
 	return nil
 }
 
+// ValidateAgainst returns an error for every service link or resource that names neither
+// something in the manifest nor one of the available resources
+func (m *Manifest) ValidateAgainst(available map[string]bool) []error {
+	services := map[string]bool{}
+
+	for _, s := range m.Services {
+		services[s.Name] = true
+	}
+
+	resources := map[string]bool{}
+
+	for _, r := range m.Resources {
+		resources[r.Name] = true
+	}
+
+	errs := []error{}
+
+	for _, s := range m.Services {
+		for _, l := range s.Links {
+			if !services[l] && !available[l] {
+				errs = append(errs, fmt.Errorf("service %s link %s invalid, no such service or resource", s.Name, l))
+			}
+		}
+
+		for _, r := range s.Resources {
+			if !resources[r] && !available[r] {
+				errs = append(errs, fmt.Errorf("service %s resource %s invalid, no such resource", s.Name, r))
+			}
+		}
+	}
+
+	return errs
+}
+
 // validateEnv returns an error if required env vars for a service are not available
 // It also filters m.env to the union of all service env vars defined in the manifest
 func (m *Manifest) validateEnv() error {
@@ -248,8 +485,218 @@ func (m *Manifest) validateEnv() error {
 	return nil
 }
 
+// validateSharedMemory returns an error if a service shm_size is unparseable or out of range
+func (m *Manifest) validateSharedMemory() error {
+	for _, s := range m.Services {
+		if s.SHMSize == "" {
+			continue
+		}
+
+		size, err := parseSizeString(s.SHMSize)
+		if err != nil {
+			return fmt.Errorf("service %s shm_size invalid, %s", s.Name, err)
+		}
+
+		if size < SharedMemoryMinimum || size > SharedMemoryMaximum {
+			return fmt.Errorf("service %s shm_size must be between 64k and 8g", s.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateHealthCheckPorts returns an error if a port health check protocol is unknown or can not use its path
+func (m *Manifest) validateHealthCheckPorts() error {
+	for _, s := range m.Services {
+		if s.Port.Port == 0 {
+			continue
+		}
+
+		protocol := s.Port.HealthProtocol()
+
+		if !containsString(HealthCheckProtocols, protocol) {
+			return fmt.Errorf("service %s port healthcheck_protocol must be one of %s", s.Name, strings.Join(HealthCheckProtocols, ", "))
+		}
+
+		if protocol == "TCP" && s.Port.HealthCheckPath != "" {
+			return fmt.Errorf("service %s port healthcheck_path can not be used with a TCP health check", s.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateReservedEnv returns an error if a service declares an environment variable the platform sets
+// and the manifest was loaded with ReservedEnvironmentError
+func (m *Manifest) validateReservedEnv() error {
+	if m.reservedEnv != ReservedEnvironmentError {
+		return nil
+	}
+
+	if rs := m.reservedEnvironment(); len(rs) > 0 {
+		return fmt.Errorf("%s", rs[0])
+	}
+
+	return nil
+}
+
+// reservedEnvironment describes each environment variable services declare that the platform sets
+func (m *Manifest) reservedEnvironment() []string {
+	reserved := map[string]bool{}
+
+	for _, r := range ReservedEnvironment {
+		reserved[r] = true
+	}
+
+	rs := []string{}
+
+	for _, s := range m.Services {
+		for _, e := range s.Environment {
+			name := strings.SplitN(e, "=", 2)[0]
+
+			if reserved[name] {
+				rs = append(rs, fmt.Sprintf("service %s environment variable %s is reserved", s.Name, name))
+			}
+		}
+	}
+
+	return rs
+}
+
+// validateUsers returns an error if a service user can not be parsed or its working directory is relative
+func (m *Manifest) validateUsers() error {
+	for _, s := range m.Services {
+		if s.User != "" {
+			if _, err := ParseUser(s.User); err != nil {
+				return fmt.Errorf("service %s user invalid, %s", s.Name, err)
+			}
+		}
+
+		if s.WorkingDirectory != "" && !path.IsAbs(s.WorkingDirectory) {
+			return fmt.Errorf("service %s working_dir must be an absolute path", s.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateSources returns an error unless each service sets exactly one of build or image
+// image_tag names the image a build is also tagged as so it requires a build
+func (m *Manifest) validateSources() error {
+	for _, s := range m.Services {
+		switch {
+		case s.Build.Path != "" && s.Image != "":
+			return fmt.Errorf("service %s can not set both build and image, use image_tag to name the built image", s.Name)
+		case s.Build.Path == "" && s.Image == "":
+			return fmt.Errorf("service %s must set either build or image", s.Name)
+		case s.ImageTag != "" && s.Build.Path == "":
+			return fmt.Errorf("service %s image_tag requires a build", s.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateImages returns an error if a service image or image_tag is not a valid docker image reference
+func (m *Manifest) validateImages() error {
+	for _, s := range m.Services {
+		if s.Image != "" {
+			if err := ValidateImageReference(s.Image); err != nil {
+				return fmt.Errorf("service %s image invalid, %s", s.Name, err)
+			}
+		}
+
+		if s.ImageTag != "" {
+			if err := ValidateImageReference(s.ImageTag); err != nil {
+				return fmt.Errorf("service %s image_tag invalid, %s", s.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateCapabilities returns an error if a service adds or drops an unknown capability or adds SYS_ADMIN
+func (m *Manifest) validateCapabilities() error {
+	for _, s := range m.Services {
+		for _, c := range s.CapAdd {
+			if c == "SYS_ADMIN" {
+				return fmt.Errorf("service %s cap_add SYS_ADMIN is not allowed, it grants near full control of the host to the container", s.Name)
+			}
+
+			if !containsString(LinuxCapabilities, c) {
+				return fmt.Errorf("service %s cap_add %s invalid, must be a linux capability like NET_ADMIN", s.Name, c)
+			}
+		}
+
+		for _, c := range s.CapDrop {
+			if c != "ALL" && !containsString(LinuxCapabilities, c) {
+				return fmt.Errorf("service %s cap_drop %s invalid, must be a linux capability like NET_ADMIN or ALL", s.Name, c)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateScale returns an error if a service scales to a negative count or its minimum exceeds its maximum
+func (m *Manifest) validateScale() error {
+	for _, s := range m.Services {
+		c := s.Scale.Count
+
+		if c.Min < 0 || c.Max < 0 {
+			return fmt.Errorf("service %s scale count invalid, must not be negative", s.Name)
+		}
+
+		if c.Min > c.Max {
+			return fmt.Errorf("service %s scale count %d-%d invalid, min must not be greater than max", s.Name, c.Min, c.Max)
+		}
+	}
+
+	return nil
+}
+
+// validateDeployments returns an error if a service names an unknown deployment strategy or uses blue-green without a port
+func (m *Manifest) validateDeployments() error {
+	for _, s := range m.Services {
+		if s.Deployment.Strategy != "" && !containsString(DeploymentStrategies, s.Deployment.Strategy) {
+			return fmt.Errorf("service %s deployment strategy %s invalid, must be one of %s", s.Name, s.Deployment.Strategy, strings.Join(DeploymentStrategies, ", "))
+		}
+
+		if s.BlueGreen() && s.Port.Port == 0 {
+			return fmt.Errorf("service %s deployment strategy blue-green requires a port, only balanced services can flip target groups", s.Name)
+		}
+
+		if s.BlueGreen() && (s.Agent.Enabled || s.Autoscale()) {
+			return fmt.Errorf("service %s deployment strategy blue-green requires a fixed count, agents and autoscaled services can not run a deployment per color", s.Name)
+		}
+	}
+
+	return nil
+}
+
 func (m *Manifest) ApplyDefaults() error {
+	for i, v := range m.Volumes {
+		if v.Performance == "" {
+			m.Volumes[i].Performance = "generalPurpose"
+		}
+	}
+
+	for i, t := range m.Timers {
+		if t.Timezone == "" {
+			m.Timers[i].Timezone = m.App.Timezone
+		}
+	}
+
 	for i, s := range m.Services {
+		// image next to build used to name the build, read it as image_tag until that form is removed
+		if s.Image != "" && s.ImageTag == "" && m.AttributeSet(fmt.Sprintf("services.%s.build", s.Name)) {
+			m.Services[i].ImageTag = s.Image
+			m.Services[i].Image = ""
+			m.Services[i].legacyImage = true
+			s = m.Services[i]
+		}
+
 		if s.Build.Path == "" && s.Image == "" {
 			m.Services[i].Build.Path = "."
 		}
@@ -336,6 +783,28 @@ func (m *Manifest) ApplyDefaults() error {
 	return nil
 }
 
+// EnsureHealthchecks points the health check of each service with an http port at defaultPath
+// unless the manifest declares a health check for it under health or the port healthcheck_path
+func (m *Manifest) EnsureHealthchecks(defaultPath string) error {
+	if !strings.HasPrefix(defaultPath, "/") {
+		return fmt.Errorf("default healthcheck path %q invalid, must start with /", defaultPath)
+	}
+
+	for i, s := range m.Services {
+		if s.Port.Port == 0 || s.Port.HealthProtocol() == "TCP" {
+			continue
+		}
+
+		if m.AttributeSet(fmt.Sprintf("services.%s.health", s.Name)) || s.Port.HealthCheckPath != "" {
+			continue
+		}
+
+		m.Services[i].Health.Path = defaultPath
+	}
+
+	return nil
+}
+
 func message(w io.Writer, format string, args ...interface{}) {
 	if w != nil {
 		w.Write([]byte(fmt.Sprintf(format, args...) + "\n"))
@@ -1,11 +1,15 @@
 package manifest_test
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/convox/rack/pkg/helpers"
 	"github.com/convox/rack/pkg/manifest"
 	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
 )
 
 func TestManifestLoad(t *testing.T) {
@@ -366,6 +370,10 @@ func TestManifestLoad(t *testing.T) {
 
 	m, err := testdataManifest("full", env)
 	require.NoError(t, err)
+
+	// the source itself is covered by TestManifestRaw
+	n.SetRaw(m.Raw())
+
 	require.Equal(t, n, m)
 
 	senv, err := m.ServiceEnvironment("api")
@@ -435,6 +443,9 @@ func TestManifestLoadSimple(t *testing.T) {
 
 	m, err := testdataManifest("simple", map[string]string{"REQUIRED": "test"})
 	require.NoError(t, err)
+
+	n.SetRaw(m.Raw())
+
 	require.Equal(t, n, m)
 }
 
@@ -481,6 +492,202 @@ func TestManifestServiceNameValidation(t *testing.T) {
 	require.EqualError(t, err, "service name web_with_underscore invalid, must contain only lowercase alphanumeric and dashes")
 }
 
+func TestManifestReservedEnvironmentValidation(t *testing.T) {
+	data, err := helpers.Testdata("invalid.5")
+	require.NoError(t, err)
+
+	m, err := manifest.LoadWithOptions(data, map[string]string{}, manifest.LoadOptions{ReservedEnvironment: manifest.ReservedEnvironmentError})
+	require.Nil(t, m)
+	require.EqualError(t, err, "service web environment variable PORT is reserved")
+
+	m, err = manifest.LoadWithOptions(data, map[string]string{}, manifest.LoadOptions{ReservedEnvironment: "ignore"})
+	require.Nil(t, m)
+	require.EqualError(t, err, "invalid reserved environment severity: ignore")
+}
+
+func TestManifestReservedEnvironmentWarning(t *testing.T) {
+	m, err := testdataManifest("invalid.5", map[string]string{})
+	require.NoError(t, err)
+
+	web, err := m.Service("web")
+	require.NoError(t, err)
+	require.Equal(t, manifest.Environment{"PORT=3000"}, web.Environment)
+
+	require.Contains(t, m.Lint(manifest.LintOptions{}), "service web environment variable PORT is reserved, the platform sets it and may override the value")
+}
+
+func TestManifestNoServices(t *testing.T) {
+	m, err := testdataManifest("invalid.6", map[string]string{})
+	require.Nil(t, m)
+	require.Equal(t, manifest.ErrNoServices, err)
+}
+
+func TestManifestSharedMemoryValidation(t *testing.T) {
+	tests := []struct {
+		Size  string
+		Error string
+		MiB   int64
+	}{
+		{"65536b", "", 1},
+		{"64k", "", 1},
+		{"128m", "", 128},
+		{"8g", "", 8192},
+		{"63k", "service web shm_size must be between 64k and 8g", 0},
+		{"8193m", "service web shm_size must be between 64k and 8g", 0},
+		{"lots", "service web shm_size invalid, invalid size: lots", 0},
+	}
+
+	for _, tt := range tests {
+		m, err := manifest.Load([]byte("services:\n  web:\n    shm_size: "+tt.Size+"\n"), map[string]string{})
+
+		if tt.Error != "" {
+			require.EqualError(t, err, tt.Error, tt.Size)
+			continue
+		}
+
+		require.NoError(t, err, tt.Size)
+		require.Equal(t, tt.MiB, m.Services[0].SharedMemorySize(), tt.Size)
+	}
+}
+
+func TestParseSizeString(t *testing.T) {
+	tests := map[string]int64{
+		"512":  512,
+		"512b": 512,
+		"64k":  64 * 1024,
+		"64K":  64 * 1024,
+		"128m": 128 * 1024 * 1024,
+		"128M": 128 * 1024 * 1024,
+		"1g":   1024 * 1024 * 1024,
+		"2G":   2 * 1024 * 1024 * 1024,
+	}
+
+	for in, out := range tests {
+		size, err := manifest.ParseSizeString(in)
+		require.NoError(t, err, in)
+		require.Equal(t, out, size, in)
+	}
+
+	_, err := manifest.ParseSizeString("1t")
+	require.EqualError(t, err, "invalid size: 1t")
+}
+
+func TestManifestLoadDefault(t *testing.T) {
+	m, err := manifest.LoadDefault("testdata/default")
+	require.NoError(t, err)
+	require.Len(t, m.Services, 1)
+	require.Equal(t, "web", m.Services[0].Name)
+	require.Equal(t, ".", m.Services[0].Build.Path)
+	require.Equal(t, 8080, m.Services[0].Port.Port)
+	require.Equal(t, "services:\n  web:\n    build: .\n    port: 8080\n", string(m.Raw()))
+
+	m, err = manifest.LoadDefault("testdata/api")
+	require.NoError(t, err)
+	require.Len(t, m.Services, 1)
+	require.Equal(t, 0, m.Services[0].Port.Port)
+}
+
+func TestManifestEquivalent(t *testing.T) {
+	m1, err := manifest.Load([]byte("services:\n  web:\n    image: httpd\n    environment:\n      - FOO\n      - BAR=baz\n    port: 80\n  worker:\n    image: worker:1\n"), map[string]string{"FOO": "x"})
+	require.NoError(t, err)
+
+	m2, err := manifest.Load([]byte("services:\n  worker:\n    image: worker:1\n  web:\n    port: 80\n    environment:\n      - BAR=baz\n      - FOO\n    image: httpd\n"), map[string]string{"FOO": "x"})
+	require.NoError(t, err)
+
+	m3, err := manifest.Load([]byte("services:\n  web:\n    image: httpd\n    environment:\n      - FOO\n      - BAR=baz\n    port: 80\n  worker:\n    image: worker:2\n"), map[string]string{"FOO": "x"})
+	require.NoError(t, err)
+
+	require.True(t, m1.Equivalent(m2))
+	require.True(t, m2.Equivalent(m1))
+	require.False(t, m1.Equivalent(m3))
+	require.False(t, m1.Equivalent(nil))
+
+	f1, err := m1.Fingerprint()
+	require.NoError(t, err)
+
+	f2, err := m2.Fingerprint()
+	require.NoError(t, err)
+
+	require.Equal(t, f1, f2)
+	require.Equal(t, "web", m2.Services[1].Name)
+}
+
+func TestManifestRaw(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  web:\n    image: ${IMAGE}\n"), map[string]string{"IMAGE": "httpd"})
+	require.NoError(t, err)
+	require.Equal(t, "services:\n  web:\n    image: httpd\n", string(m.Raw()))
+}
+
+func TestManifestLoadDefaultMissing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	m, err := manifest.LoadDefault(dir)
+	require.Nil(t, m)
+	require.Equal(t, manifest.ErrNoManifest, err)
+}
+
+func TestManifestLoadProfiles(t *testing.T) {
+	data, err := helpers.Testdata("profiles")
+	require.NoError(t, err)
+
+	m, err := manifest.LoadWithOptions(data, map[string]string{}, manifest.LoadOptions{Profile: "production"})
+	require.NoError(t, err)
+
+	web, err := m.Service("web")
+	require.NoError(t, err)
+	require.Equal(t, manifest.ServiceDomains{"www.example.org"}, web.Domains)
+	require.Equal(t, manifest.Environment{"FOO=bar", "LEVEL=warn"}, web.Environment)
+	require.Equal(t, manifest.ServiceScaleCount{Min: 5, Max: 5}, web.Scale.Count)
+
+	worker, err := m.Service("worker")
+	require.NoError(t, err)
+	require.Equal(t, manifest.ServiceScaleCount{Min: 3, Max: 10}, worker.Scale.Count)
+	require.Equal(t, 1024, worker.Scale.Memory)
+
+	require.NotContains(t, string(m.Raw()), "profiles")
+	require.Contains(t, string(m.Raw()), "domain: www.example.org")
+
+	m, err = manifest.LoadWithOptions(data, map[string]string{}, manifest.LoadOptions{Profile: "staging"})
+	require.NoError(t, err)
+
+	web, err = m.Service("web")
+	require.NoError(t, err)
+	require.Equal(t, manifest.ServiceDomains{"staging.example.org"}, web.Domains)
+	require.Equal(t, manifest.Environment{"FOO=bar", "LEVEL=debug"}, web.Environment)
+
+	worker, err = m.Service("worker")
+	require.NoError(t, err)
+	require.Equal(t, manifest.ServiceScaleCount{Min: 1, Max: 1}, worker.Scale.Count)
+
+	m, err = manifest.LoadWithOptions(data, map[string]string{}, manifest.LoadOptions{Profile: "other"})
+	require.Nil(t, m)
+	require.EqualError(t, err, "unknown profile: other")
+}
+
+func TestManifestLoadProfilesMissingDefault(t *testing.T) {
+	data, err := helpers.Testdata("profiles-no-default")
+	require.NoError(t, err)
+
+	m, err := manifest.LoadWithOptions(data, map[string]string{}, manifest.LoadOptions{Profile: "staging"})
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	m, err = manifest.LoadWithOptions(data, map[string]string{}, manifest.LoadOptions{})
+	require.Nil(t, m)
+	require.EqualError(t, err, "services.web.scale: default required")
+}
+
+func TestManifestLoadProfilesUnknown(t *testing.T) {
+	data, err := helpers.Testdata("profiles-unknown")
+	require.NoError(t, err)
+
+	m, err := manifest.LoadWithOptions(data, map[string]string{}, manifest.LoadOptions{Profile: "staging"})
+	require.Nil(t, m)
+	require.EqualError(t, err, "services.web.scale: unknown profiles: prod")
+}
+
 func testdataManifest(name string, env map[string]string) (*manifest.Manifest, error) {
 	data, err := helpers.Testdata(name)
 	if err != nil {
@@ -489,3 +696,591 @@ func testdataManifest(name string, env map[string]string) (*manifest.Manifest, e
 
 	return manifest.Load(data, env)
 }
+
+func TestScheduleExpression(t *testing.T) {
+	tests := []struct {
+		Schedule   string
+		Expression string
+		Error      string
+	}{
+		{"*/10 * * * ?", "cron(*/10 * * * ? *)", ""},
+		{"0 12 * * ? 2030", "cron(0 12 * * ? 2030)", ""},
+		{"rate: 5 minutes", "rate(5 minutes)", ""},
+		{"rate: 1 minute", "rate(1 minute)", ""},
+		{"rate: 1 minutes", "rate(1 minute)", ""},
+		{"rate: 2 hour", "rate(2 hours)", ""},
+		{"rate: 3 Days", "rate(3 days)", ""},
+		{"rate: 0 minutes", "", `invalid rate value "0", must be a positive integer`},
+		{"rate: 5 weeks", "", `invalid rate unit "weeks", must be one of minutes, hours or days`},
+		{"rate: minutes", "", `invalid schedule "rate: minutes", must be ` + manifest.ScheduleForms},
+		{"@every 5m", "rate(5 minutes)", ""},
+		{"@every 1m", "rate(1 minute)", ""},
+		{"@every 90m", "rate(90 minutes)", ""},
+		{"@every 1h", "rate(1 hour)", ""},
+		{"@every 48h", "rate(2 days)", ""},
+		{"@every 30s", "", "invalid interval 30s, must be a whole number of minutes"},
+		{"@every soon", "", `invalid schedule "@every soon", must be ` + manifest.ScheduleForms},
+		{"at: 2100-01-02T15:04:05Z", "at(2100-01-02T15:04:05)", ""},
+		{"at: 2100-01-02T10:04:05-05:00", "at(2100-01-02T15:04:05)", ""},
+		{"at: 2100-01-02T15:04:05", "at(2100-01-02T15:04:05)", ""},
+		{"at: 2000-01-02T15:04:05Z", "", `invalid schedule "at: 2000-01-02T15:04:05Z", the time must be in the future`},
+		{"at: tomorrow", "", `invalid schedule "at: tomorrow", must be ` + manifest.ScheduleForms},
+		{"* * *", "", `invalid schedule "* * *", must be ` + manifest.ScheduleForms},
+	}
+
+	for _, test := range tests {
+		expr, err := manifest.ScheduleExpression(test.Schedule)
+
+		if test.Error != "" {
+			require.EqualError(t, err, test.Error, test.Schedule)
+			continue
+		}
+
+		require.NoError(t, err, test.Schedule)
+		require.Equal(t, test.Expression, expr, test.Schedule)
+	}
+}
+
+func TestSplitSchedule(t *testing.T) {
+	tests := []struct {
+		Value    string
+		Schedule string
+		Command  string
+	}{
+		{"0 * * * ? bin/cleanup --all", "0 * * * ?", "bin/cleanup --all"},
+		{"rate: 5 minutes bin/cleanup", "rate: 5 minutes", "bin/cleanup"},
+		{"@every 1h bin/cleanup", "@every 1h", "bin/cleanup"},
+		{"at: 2100-01-02T15:04:05Z bin/cleanup", "at: 2100-01-02T15:04:05Z", "bin/cleanup"},
+		{"0 * * * ?", "0 * * * ?", ""},
+	}
+
+	for _, test := range tests {
+		schedule, command, err := manifest.SplitSchedule(test.Value)
+		require.NoError(t, err, test.Value)
+		require.Equal(t, test.Schedule, schedule, test.Value)
+		require.Equal(t, test.Command, command, test.Value)
+	}
+
+	_, _, err := manifest.SplitSchedule("rate: 5")
+	require.EqualError(t, err, `invalid schedule "rate: 5", must be `+manifest.ScheduleForms)
+}
+
+func TestNextScheduleTime(t *testing.T) {
+	utc := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		require.NoError(t, err)
+		return v
+	}
+
+	tests := []struct {
+		Schedule string
+		After    time.Time
+		Next     time.Time
+		Error    string
+	}{
+		{"*/10 * * * ?", utc("2021-01-01T00:05:00Z"), utc("2021-01-01T00:10:00Z"), ""},
+		{"*/10 * * * ?", utc("2021-01-01T00:10:00Z"), utc("2021-01-01T00:20:00Z"), ""},
+		{"0 12 ? * MON-FRI *", utc("2021-01-02T13:00:00Z"), utc("2021-01-04T12:00:00Z"), ""},
+		{"0 0 29 FEB ? *", utc("2021-03-01T00:00:00Z"), utc("2024-02-29T00:00:00Z"), ""},
+		{"5,35 8-9 1 * ? 2024", utc("2021-01-01T00:00:00Z"), utc("2024-01-01T08:05:00Z"), ""},
+		{"0 0 1 1 ? 2020", utc("2021-01-01T00:00:00Z"), time.Time{}, ""},
+		{"rate: 5 minutes", utc("2021-01-01T00:00:00Z"), utc("2021-01-01T00:05:00Z"), ""},
+		{"@every 2h", utc("2021-01-01T00:00:00Z"), utc("2021-01-01T02:00:00Z"), ""},
+		{"at: 2100-01-02T15:04:05Z", utc("2021-01-01T00:00:00Z"), utc("2100-01-02T15:04:05Z"), ""},
+		{"15 10 L * ? *", utc("2021-01-01T00:00:00Z"), time.Time{}, "cron day of month L is not supported, L, W and # can not be evaluated locally"},
+		{"0 10 ? * 6#3 *", utc("2021-01-01T00:00:00Z"), time.Time{}, "cron day of week 6#3 is not supported, L, W and # can not be evaluated locally"},
+		{"0 25 * * ? *", utc("2021-01-01T00:00:00Z"), time.Time{}, "cron hours value 25 invalid, must be between 0 and 23"},
+		{"0 0 * 12-1 ? *", utc("2021-01-01T00:00:00Z"), time.Time{}, "cron month range 12-1 invalid, must not run backwards"},
+	}
+
+	for _, test := range tests {
+		next, err := manifest.NextScheduleTime(test.Schedule, test.After)
+
+		if test.Error != "" {
+			require.EqualError(t, err, test.Error, test.Schedule)
+			continue
+		}
+
+		require.NoError(t, err, test.Schedule)
+		require.True(t, test.Next.Equal(next), "%s: expected %s got %s", test.Schedule, test.Next, next)
+	}
+}
+
+func TestNextScheduleTimeDaylightSaving(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// 02:30 does not exist on 2021-03-14 so the next fire is a day later
+	next, err := manifest.NextScheduleTime("30 2 * * ? *", time.Date(2021, 3, 13, 3, 0, 0, 0, ny))
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, 3, 15, 2, 30, 0, 0, ny), next)
+
+	// 01:30 happens twice on 2021-11-07 and fires once
+	first, err := manifest.NextScheduleTime("30 1 * * ? *", time.Date(2021, 11, 7, 0, 0, 0, 0, ny))
+	require.NoError(t, err)
+	require.Equal(t, 7, first.Day())
+	require.Equal(t, 1, first.Hour())
+
+	next, err = manifest.NextScheduleTime("30 1 * * ? *", first)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, 11, 8, 1, 30, 0, 0, ny), next)
+
+	// hourly fires are an hour apart in absolute time across the change
+	next, err = manifest.NextScheduleTime("0 * * * ? *", time.Date(2021, 3, 14, 1, 30, 0, 0, ny))
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, 3, 14, 3, 0, 0, 0, ny), next)
+	require.Equal(t, 30*time.Minute, next.Sub(time.Date(2021, 3, 14, 1, 30, 0, 0, ny)))
+}
+
+func TestTimerNext(t *testing.T) {
+	tm := manifest.Timer{Name: "cleanup", Schedule: "0 * * * ?", Service: "web"}
+
+	next, err := tm.Next(time.Date(2021, 1, 1, 10, 15, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, 1, 1, 11, 0, 0, 0, time.UTC), next)
+}
+
+func TestManifestLoadInvalidTimerSchedule(t *testing.T) {
+	_, err := manifest.Load([]byte("services:\n  web:\n    image: httpd\ntimers:\n  cleanup:\n    command: bin/cleanup\n    schedule: \"rate: 1 fortnight\"\n    service: web\n"), map[string]string{})
+	require.EqualError(t, err, `timer cleanup schedule invalid, invalid rate unit "fortnight", must be one of minutes, hours or days`)
+}
+
+func TestManifestLoadHealthCheckProtocol(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  web:\n    port:\n      port: 9000\n      healthcheck_protocol: https\n      healthcheck_path: /up\n"), map[string]string{})
+	require.NoError(t, err)
+	require.Equal(t, manifest.ServicePort{Port: 9000, Scheme: "http", HealthCheckPath: "/up", HealthCheckProtocol: "https"}, m.Services[0].Port)
+	require.Equal(t, "HTTPS", m.Services[0].Port.HealthProtocol())
+	require.Equal(t, "/up", m.Services[0].HealthPath())
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    port:\n      port: 9000\n      healthcheck_protocol: tcp\n      healthcheck_path: /up\n"), map[string]string{})
+	require.EqualError(t, err, "service web port healthcheck_path can not be used with a TCP health check")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    port:\n      port: 9000\n      healthcheck_path: /up\n"), map[string]string{})
+	require.EqualError(t, err, "service web port healthcheck_path can not be used with a TCP health check")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    port:\n      port: 9000\n      healthcheck_protocol: udp\n"), map[string]string{})
+	require.EqualError(t, err, "service web port healthcheck_protocol must be one of HTTP, HTTPS, TCP")
+}
+
+func TestServicePortHealthProtocolDefault(t *testing.T) {
+	for _, port := range []int{80, 3000, 4000, 5000, 8080} {
+		require.Equal(t, "HTTP", manifest.ServicePort{Port: port}.HealthProtocol(), port)
+	}
+
+	for _, port := range []int{443, 8000, 9000} {
+		require.Equal(t, "TCP", manifest.ServicePort{Port: port}.HealthProtocol(), port)
+	}
+
+	require.Equal(t, "HTTPS", manifest.ServicePort{Port: 9000, HealthCheckProtocol: "HTTPS"}.HealthProtocol())
+
+	s := manifest.Service{Health: manifest.ServiceHealth{Path: "/health"}, Port: manifest.ServicePort{Port: 3000}}
+	require.Equal(t, "/health", s.HealthPath())
+}
+
+func TestParseUser(t *testing.T) {
+	tests := []struct {
+		User  string
+		Name  string
+		Uid   *int64
+		Group string
+		Gid   *int64
+		Error string
+	}{
+		{"app", "app", nil, "", nil, ""},
+		{"nobody", "nobody", nil, "", nil, ""},
+		{"1000", "", int64p(1000), "", nil, ""},
+		{"1000:2000", "", int64p(1000), "", int64p(2000), ""},
+		{"0:0", "", int64p(0), "", int64p(0), ""},
+		{"app:staff", "app", nil, "staff", nil, ""},
+		{"app:2000", "app", nil, "", int64p(2000), ""},
+		{"1000:staff", "", int64p(1000), "staff", nil, ""},
+		{"4294967294", "", int64p(4294967294), "", nil, ""},
+		{"4294967295", "", nil, "", nil, "invalid uid 4294967295, must be between 0 and 4294967294"},
+		{"1000:4294967295", "", nil, "", nil, "invalid gid 4294967295, must be between 0 and 4294967294"},
+		{"1000:", "", nil, "", nil, `invalid group "", must be a name or gid`},
+		{"1000:-1", "", nil, "", nil, "invalid gid -1, must be between 0 and 4294967294"},
+		{"-1", "", nil, "", nil, "invalid uid -1, must be between 0 and 4294967294"},
+		{"1:2:3", "", nil, "", nil, `invalid user "1:2:3", must be a name or uid with an optional :group or :gid`},
+		{"App User", "", nil, "", nil, `invalid user "App User", must be a name or uid with an optional :group or :gid`},
+		{"app: staff", "", nil, "", nil, `invalid group " staff", must be a name or gid`},
+	}
+
+	for _, tt := range tests {
+		u, err := manifest.ParseUser(tt.User)
+
+		if tt.Error != "" {
+			require.EqualError(t, err, tt.Error, tt.User)
+			continue
+		}
+
+		require.NoError(t, err, tt.User)
+		require.Equal(t, manifest.ServiceUser{Name: tt.Name, Uid: tt.Uid, Group: tt.Group, Gid: tt.Gid}, u, tt.User)
+	}
+}
+
+func TestManifestLoadUser(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  web:\n    user: \"1000:1000\"\n    working_dir: /app/web\n"), map[string]string{})
+	require.NoError(t, err)
+	require.Equal(t, "1000:1000", m.Services[0].User)
+	require.Equal(t, "/app/web", m.Services[0].WorkingDirectory)
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    user: \"1000:Staff\"\n"), map[string]string{})
+	require.EqualError(t, err, `service web user invalid, invalid group "Staff", must be a name or gid`)
+
+	m, err = manifest.Load([]byte("services:\n  web:\n    read_only: true\n    user: nobody\n"), map[string]string{})
+	require.NoError(t, err)
+	require.True(t, m.Services[0].ReadonlyRootFilesystem)
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    working_dir: app/web\n"), map[string]string{})
+	require.EqualError(t, err, "service web working_dir must be an absolute path")
+}
+
+func TestManifestNonRoot(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  api:\n    user: app\n  root:\n    user: \"0:1000\"\n  web:\n    image: httpd\n"), map[string]string{})
+	require.NoError(t, err)
+
+	// only services that set root explicitly are warned about unless the rack requires non-root services
+	require.Equal(t, []string{
+		"service root sets user 0:1000 which runs as root, use a non-root user to harden it",
+	}, m.Lint(manifest.LintOptions{}))
+	require.Equal(t, []string{
+		"service root does not set a non-root user, the rack will not promote it",
+		"service web does not set a non-root user, the rack will not promote it",
+	}, m.Lint(manifest.LintOptions{RequireNonRoot: true}))
+
+	require.EqualError(t, m.ValidateNonRoot(), "service root must set a non-root user, the rack requires non-root services")
+
+	m, err = manifest.Load([]byte("services:\n  api:\n    user: app\n  web:\n    user: \"1000\"\n"), map[string]string{})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{}, m.Lint(manifest.LintOptions{RequireNonRoot: true}))
+	require.NoError(t, m.ValidateNonRoot())
+}
+
+func int64p(i int64) *int64 {
+	return &i
+}
+
+func TestManifestLoadCapabilities(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  web:\n    cap_add:\n      - NET_ADMIN\n      - SYS_PTRACE\n    cap_drop:\n      - ALL\n"), map[string]string{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"NET_ADMIN", "SYS_PTRACE"}, m.Services[0].CapAdd)
+	require.Equal(t, []string{"ALL"}, m.Services[0].CapDrop)
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    cap_add:\n      - NET_MAGIC\n"), map[string]string{})
+	require.EqualError(t, err, "service web cap_add NET_MAGIC invalid, must be a linux capability like NET_ADMIN")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    cap_drop:\n      - chown\n"), map[string]string{})
+	require.EqualError(t, err, "service web cap_drop chown invalid, must be a linux capability like NET_ADMIN or ALL")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    cap_add:\n      - SYS_ADMIN\n"), map[string]string{})
+	require.EqualError(t, err, "service web cap_add SYS_ADMIN is not allowed, it grants near full control of the host to the container")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    cap_drop:\n      - SYS_ADMIN\n"), map[string]string{})
+	require.NoError(t, err)
+}
+
+func TestManifestScaleBounds(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  fixed:\n    scale: 2\n  range:\n    scale: 1-5\n  count:\n    scale:\n      count: 3-10\n  default:\n    image: httpd\n  agent:\n    agent: true\n"), map[string]string{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		Service string
+		Count   int
+		Min     int
+		Max     int
+		Ok      bool
+	}{
+		{"fixed", 2, 2, 2, true},
+		{"range", 1, 1, 5, true},
+		{"count", 3, 3, 10, true},
+		{"default", 1, 1, 1, true},
+		{"agent", 0, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		s, err := m.Service(test.Service)
+		require.NoError(t, err)
+
+		count, min, max, ok := s.ScaleBounds()
+		require.Equal(t, test.Count, count, test.Service)
+		require.Equal(t, test.Min, min, test.Service)
+		require.Equal(t, test.Max, max, test.Service)
+		require.Equal(t, test.Ok, ok, test.Service)
+	}
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    scale: 5-2\n"), map[string]string{})
+	require.EqualError(t, err, "service web scale count 5-2 invalid, min must not be greater than max")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    scale: -1\n"), map[string]string{})
+	require.EqualError(t, err, "service web scale count invalid, must not be negative")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    scale: 0\n"), map[string]string{})
+	require.NoError(t, err)
+}
+
+func TestManifestDeploymentStrategy(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  web:\n    port: 3000\n    deployment:\n      strategy: blue-green\n  worker:\n    deployment:\n      strategy: rolling\n"), map[string]string{})
+	require.NoError(t, err)
+	require.True(t, m.Services[0].BlueGreen())
+	require.False(t, m.Services[1].BlueGreen())
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    port: 3000\n    deployment:\n      strategy: canary\n"), map[string]string{})
+	require.EqualError(t, err, "service web deployment strategy canary invalid, must be one of blue-green, rolling")
+
+	_, err = manifest.Load([]byte("services:\n  worker:\n    deployment:\n      strategy: blue-green\n"), map[string]string{})
+	require.EqualError(t, err, "service worker deployment strategy blue-green requires a port, only balanced services can flip target groups")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    port: 3000\n    deployment:\n      strategy: blue-green\n    scale:\n      count: 1-3\n      targets:\n        cpu: 70\n"), map[string]string{})
+	require.EqualError(t, err, "service web deployment strategy blue-green requires a fixed count, agents and autoscaled services can not run a deployment per color")
+}
+
+func TestManifestLoadDevices(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  web:\n    devices:\n      - /dev/nvidia0\n      - /dev/fuse:/dev/fuse:rw\n      - host_path: /dev/sdb\n        container_path: /dev/xvdb\n        permissions: r\n"), map[string]string{})
+	require.NoError(t, err)
+	require.Equal(t, []manifest.DeviceMount{
+		{HostPath: "/dev/nvidia0", ContainerPath: "/dev/nvidia0"},
+		{HostPath: "/dev/fuse", ContainerPath: "/dev/fuse", Permissions: "rw"},
+		{HostPath: "/dev/sdb", ContainerPath: "/dev/xvdb", Permissions: "r"},
+	}, m.Services[0].Devices)
+	require.Equal(t, []string{}, m.Services[0].Devices[0].PermissionNames())
+	require.Equal(t, []string{"read", "write"}, m.Services[0].Devices[1].PermissionNames())
+	require.Contains(t, m.Lint(manifest.LintOptions{}), "service web mounts devices which require the ec2 launch type, it will not start on fargate")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    devices:\n      - /dev/fuse:/dev/fuse:rx\n"), map[string]string{})
+	require.EqualError(t, err, "service web device /dev/fuse permissions rx invalid, must be a combination of r, w and m")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    devices:\n      - /dev/fuse:/dev/fuse:rr\n"), map[string]string{})
+	require.EqualError(t, err, "service web device /dev/fuse permissions rr invalid, must be a combination of r, w and m")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    devices:\n      - /dev/sdb:/dev/xvdb\n      - /dev/sdc:/dev/xvdb\n"), map[string]string{})
+	require.EqualError(t, err, "service web device container_path /dev/xvdb is used more than once")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    devices:\n      - host_path: dev/sdb\n"), map[string]string{})
+	require.EqualError(t, err, "service web device host_path dev/sdb invalid, must be an absolute path")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    devices:\n      - /dev/sdb:xvdb\n"), map[string]string{})
+	require.EqualError(t, err, "service web device container_path xvdb invalid, must be an absolute path")
+}
+
+func TestManifestLoadSources(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  web:\n    build: .\n"), map[string]string{})
+	require.NoError(t, err)
+	require.Equal(t, ".", m.Services[0].Build.Path)
+	require.Equal(t, "", m.Services[0].Image)
+
+	m, err = manifest.Load([]byte("services:\n  web:\n    image: httpd\n"), map[string]string{})
+	require.NoError(t, err)
+	require.Equal(t, "", m.Services[0].Build.Path)
+	require.Equal(t, "httpd", m.Services[0].Image)
+
+	m, err = manifest.Load([]byte("services:\n  web:\n    build: .\n    image_tag: example/web\n"), map[string]string{})
+	require.NoError(t, err)
+	require.Equal(t, ".", m.Services[0].Build.Path)
+	require.Equal(t, "example/web", m.Services[0].ImageTag)
+	require.Equal(t, []string{}, m.Lint(manifest.LintOptions{}))
+
+	// a service that sets neither builds the app root
+	m, err = manifest.Load([]byte("services:\n  web:\n    port: 3000\n"), map[string]string{})
+	require.NoError(t, err)
+	require.Equal(t, ".", m.Services[0].Build.Path)
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    build: .\n    image: httpd\n    image_tag: example/web\n"), map[string]string{})
+	require.EqualError(t, err, "service web can not set both build and image, use image_tag to name the built image")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    image: httpd\n    image_tag: example/web\n"), map[string]string{})
+	require.EqualError(t, err, "service web image_tag requires a build")
+
+	m = &manifest.Manifest{Services: manifest.Services{{Name: "web"}}}
+	require.EqualError(t, m.Validate(), "service web must set either build or image")
+
+	m = &manifest.Manifest{Services: manifest.Services{{Name: "web", Build: manifest.ServiceBuild{Path: "."}, Image: "httpd"}}}
+	require.EqualError(t, m.Validate(), "service web can not set both build and image, use image_tag to name the built image")
+}
+
+func TestManifestValidateImages(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  web:\n    image: registry.example.com:5000/team/web:1.2.3\n"), map[string]string{})
+	require.NoError(t, err)
+	require.Equal(t, "registry.example.com:5000/team/web:1.2.3", m.Services[0].Image)
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    image: httpd@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef\n"), map[string]string{})
+	require.NoError(t, err)
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    image: \"my app:latest\"\n"), map[string]string{})
+	require.EqualError(t, err, "service web image invalid, invalid image reference \"my app:latest\", must be like registry/repository:tag or repository@sha256:digest")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    image: httpd:-latest\n"), map[string]string{})
+	require.EqualError(t, err, "service web image invalid, invalid image reference \"httpd:-latest\", must be like registry/repository:tag or repository@sha256:digest")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    build: .\n    image_tag: Example/Web\n"), map[string]string{})
+	require.EqualError(t, err, "service web image_tag invalid, invalid image reference \"Example/Web\", must be like registry/repository:tag or repository@sha256:digest")
+}
+
+func TestManifestServiceIndices(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  web:\n    image: httpd\n  api:\n    image: httpd\n  worker:\n    image: httpd\n"), map[string]string{})
+	require.NoError(t, err)
+
+	indices := map[string]int{"api": 0, "web": 1, "worker": 2}
+
+	require.Equal(t, indices, m.ServiceIndices())
+	require.Equal(t, indices, m.ServiceIndices())
+
+	m, err = manifest.Load([]byte("services:\n  worker:\n    image: httpd\n  api:\n    image: httpd\n  web:\n    image: httpd\n"), map[string]string{})
+	require.NoError(t, err)
+	require.Equal(t, indices, m.ServiceIndices())
+}
+
+func TestManifestValidateAgainst(t *testing.T) {
+	m, err := manifest.Load([]byte("resources:\n  cache:\n    type: redis\nservices:\n  web:\n    image: httpd\n    links:\n      - worker\n      - database\n    resources:\n      - cache\n      - queue\n  worker:\n    image: httpd\n    links:\n      - search\n"), map[string]string{})
+	require.NoError(t, err)
+
+	require.Empty(t, m.ValidateAgainst(map[string]bool{"database": true, "queue": true, "search": true}))
+
+	errs := m.ValidateAgainst(map[string]bool{"database": true})
+	require.Len(t, errs, 2)
+	require.EqualError(t, errs[0], "service web resource queue invalid, no such resource")
+	require.EqualError(t, errs[1], "service worker link search invalid, no such service or resource")
+}
+
+func TestManifestLoadSourcesDeprecated(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  web:\n    build: .\n    image: example/web\n"), map[string]string{})
+	require.NoError(t, err)
+	require.Equal(t, ".", m.Services[0].Build.Path)
+	require.Equal(t, "", m.Services[0].Image)
+	require.Equal(t, "example/web", m.Services[0].ImageTag)
+
+	require.Equal(t, []string{
+		"service web sets both build and image, image is read as image_tag and will be rejected in a future release, rename it to image_tag",
+	}, m.Lint(manifest.LintOptions{}))
+}
+
+func TestManifestLoadVolumes(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  web:\n    build: .\n    mounts:\n      - data:/data\n      - cache:/var/cache\n      - data:/srv/data\nvolumes:\n  data:\n    encrypted: true\n  cache:\n    performance: maxIO\n"), map[string]string{})
+	require.NoError(t, err)
+	require.Equal(t, manifest.Volumes{
+		{Name: "data", Encrypted: true, Performance: "generalPurpose"},
+		{Name: "cache", Performance: "maxIO"},
+	}, m.Volumes)
+	require.Equal(t, manifest.ServiceMounts{
+		{Volume: "data", Path: "/data"},
+		{Volume: "cache", Path: "/var/cache"},
+		{Volume: "data", Path: "/srv/data"},
+	}, m.Services[0].Mounts)
+	require.Equal(t, []string{"cache", "data"}, m.Services[0].MountedVolumes())
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    mounts:\n      - /data\nvolumes:\n  data: {}\n"), map[string]string{})
+	require.EqualError(t, err, "invalid mount \"/data\", must be <volume>:<path>")
+
+	_, err = manifest.Load([]byte("volumes:\n  data:\n    performance: fast\n"), map[string]string{})
+	require.EqualError(t, err, "volume data performance invalid, must be one of generalPurpose, maxIO")
+
+	_, err = manifest.Load([]byte("volumes:\n  Data: {}\n"), map[string]string{})
+	require.EqualError(t, err, "volume name Data invalid, "+manifest.ValidNameDescription)
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    mounts:\n      - other:/data\nvolumes:\n  data: {}\n"), map[string]string{})
+	require.EqualError(t, err, "service web mounts unknown volume other")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    mounts:\n      - data:data\nvolumes:\n  data: {}\n"), map[string]string{})
+	require.EqualError(t, err, "service web mount path data must be absolute")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    mounts:\n      - data:/data\n      - data:/data/sub\nvolumes:\n  data: {}\n"), map[string]string{})
+	require.EqualError(t, err, "service web mount paths /data and /data/sub overlap")
+
+	_, err = manifest.Load([]byte("services:\n  web:\n    mounts:\n      - data:/data\n      - data:/database\nvolumes:\n  data: {}\n"), map[string]string{})
+	require.NoError(t, err)
+}
+
+func TestManifestEnsureHealthchecks(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  web:\n    port: 3000\n  api:\n    port: 5000\n    health: /ping\n  admin:\n    port:\n      port: 8080\n      healthcheck_path: /up\n  db:\n    port: 9000\n  worker:\n    command: work\n"), map[string]string{})
+	require.NoError(t, err)
+
+	require.NoError(t, m.EnsureHealthchecks("/healthz"))
+
+	paths := map[string]string{}
+
+	for _, s := range m.Services {
+		paths[s.Name] = s.HealthPath()
+	}
+
+	require.Equal(t, map[string]string{"admin": "/up", "api": "/ping", "db": "/", "web": "/healthz", "worker": "/"}, paths)
+
+	require.EqualError(t, m.EnsureHealthchecks("healthz"), `default healthcheck path "healthz" invalid, must start with /`)
+}
+
+func TestManifestAppOptions(t *testing.T) {
+	data := []byte("app:\n  name: myapp\n  generation: 2\n  timezone: America/New_York\n  private_builds: true\n  features:\n    ecs-exec: true\n    readonly-root: false\nservices:\n  web:\n    port: 3000\ntimers:\n  daily:\n    schedule: \"0 9 * * ?\"\n    command: bin/daily\n    service: web\n  weekly:\n    schedule: \"0 9 ? * MON\"\n    command: bin/weekly\n    service: web\n    timezone: UTC\n")
+
+	m, err := manifest.Load(data, map[string]string{})
+	require.NoError(t, err)
+
+	require.Equal(t, manifest.AppOptions{
+		Features:      map[string]bool{"ecs-exec": true, "readonly-root": false},
+		Generation:    "2",
+		Name:          "myapp",
+		PrivateBuilds: true,
+		Timezone:      "America/New_York",
+	}, m.App)
+
+	require.True(t, m.App.Feature(manifest.AppFeatureEcsExec))
+	require.False(t, m.App.Feature(manifest.AppFeatureReadonlyRoot))
+
+	require.Len(t, m.Timers, 2)
+	require.Equal(t, "America/New_York", m.Timers[0].Timezone)
+	require.Equal(t, "UTC", m.Timers[1].Timezone)
+
+	out, err := yaml.Marshal(m)
+	require.NoError(t, err)
+
+	n, err := manifest.Load(out, map[string]string{})
+	require.NoError(t, err)
+	require.Equal(t, m.App, n.App)
+	require.True(t, m.Equivalent(n))
+}
+
+func TestManifestAppOptionsInvalid(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{"app:\n  features:\n    turbo: true\n", "app feature turbo invalid, must be one of ecs-exec, readonly-root"},
+		{"app:\n  generation: 1\n", "app generation 1 invalid, must be one of 2"},
+		{"app:\n  name: My_App\n", "app name My_App invalid, must contain only lowercase alphanumeric and dashes"},
+		{"app:\n  timezone: Mars/Olympus\n", "app timezone Mars/Olympus invalid, must be an iana time zone name"},
+		{"services:\n  web:\n    command: work\ntimers:\n  daily:\n    schedule: \"0 9 * * ?\"\n    command: bin/daily\n    service: web\n    timezone: Nowhere\n", "timer daily timezone Nowhere invalid, must be an iana time zone name"},
+	}
+
+	for _, tt := range tests {
+		_, err := manifest.Load([]byte(tt.data), map[string]string{})
+		require.EqualError(t, err, tt.err, tt.data)
+	}
+}
+
+func TestManifestLoadSidecars(t *testing.T) {
+	m, err := manifest.Load([]byte("services:\n  web:\n    sidecars:\n      - name: envoy\n        image: envoyproxy/envoy:v1.27\n        essential: true\n        environment:\n          ENVOY_UID: \"0\"\n      - name: logs\n        image: fluent/fluent-bit\n        volumes_from:\n          - web\n        depends_on:\n          - container: envoy\n            condition: HEALTHY\n          - container: web\n"), map[string]string{})
+	require.NoError(t, err)
+	require.Equal(t, []manifest.SidecarService{
+		{Name: "envoy", Image: "envoyproxy/envoy:v1.27", Environment: map[string]string{"ENVOY_UID": "0"}, Essential: true},
+		{Name: "logs", Image: "fluent/fluent-bit", VolumesFrom: []string{"web"}, DependsOn: []manifest.ContainerDependency{{Container: "envoy", Condition: "HEALTHY"}, {Container: "web"}}},
+	}, m.Services[0].Sidecars)
+	require.Equal(t, "START", m.Services[0].Sidecars[1].DependsOn[1].ConditionOrDefault())
+
+	tests := []struct {
+		sidecars string
+		err      string
+	}{
+		{"      - name: web\n        image: nginx\n", "service web sidecar name web invalid, must differ from the name of its service"},
+		{"      - name: Envoy\n        image: nginx\n", "service web sidecar name Envoy invalid, must contain only lowercase alphanumeric and dashes"},
+		{"      - name: envoy\n        image: nginx\n      - name: envoy\n        image: nginx\n", "service web sidecar envoy is defined more than once"},
+		{"      - name: envoy\n", "service web sidecar envoy requires an image"},
+		{"      - name: envoy\n        image: nginx\n        essential: true\n      - name: logs\n        image: nginx\n        essential: true\n", "service web sidecars envoy and logs are both essential, only one sidecar of a service can be"},
+		{"      - name: envoy\n        image: nginx\n        depends_on:\n          - container: db\n", "service web sidecar envoy depends on unknown container db"},
+		{"      - name: envoy\n        image: nginx\n        depends_on:\n          - container: envoy\n", "service web sidecar envoy can not depend on itself"},
+		{"      - name: envoy\n        image: nginx\n        depends_on:\n          - container: web\n            condition: READY\n", "service web sidecar envoy condition READY invalid, must be one of COMPLETE, HEALTHY, START, SUCCESS"},
+		{"      - name: envoy\n        image: nginx\n        volumes_from:\n          - db\n", "service web sidecar envoy volumes_from db invalid, must be another container of the service"},
+	}
+
+	for _, tt := range tests {
+		_, err := manifest.Load([]byte("services:\n  web:\n    sidecars:\n"+tt.sidecars), map[string]string{})
+		require.EqualError(t, err, tt.err, tt.sidecars)
+	}
+}
@@ -0,0 +1,236 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// LoadOptions configures how a manifest is loaded
+type LoadOptions struct {
+	// Profile selects values from profile maps in manifests that declare profiles
+	Profile string
+
+	// ReservedEnvironment is how services that declare a ReservedEnvironment name are treated,
+	// ReservedEnvironmentWarn when empty
+	ReservedEnvironment string
+}
+
+const (
+	// ReservedEnvironmentWarn reports services that declare a reserved environment variable from Lint
+	ReservedEnvironmentWarn = "warn"
+
+	// ReservedEnvironmentError fails loading manifests with services that declare a reserved environment variable
+	ReservedEnvironmentError = "error"
+)
+
+const profileDefault = "default"
+
+type profileResolver struct {
+	active   string
+	profiles map[string]bool
+}
+
+// resolveProfiles flattens profile maps in manifests that declare a top-level list of profiles
+//
+// Only a fixed set of fields may use profile maps:
+//
+//	services.<name>.domain
+//	services.<name>.environment (per variable)
+//	services.<name>.scale
+//	services.<name>.scale.count
+//
+// Manifests without a profiles declaration are returned unchanged
+func resolveProfiles(data []byte, profile string) ([]byte, error) {
+	var doc yaml.MapSlice
+
+	// leave reporting of malformed manifests to the main unmarshal
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return data, nil
+	}
+
+	i := mapSliceIndex(doc, "profiles")
+
+	if i < 0 {
+		if profile != "" {
+			return nil, fmt.Errorf("profile %s is not declared in manifest", profile)
+		}
+
+		return data, nil
+	}
+
+	r := profileResolver{active: profile, profiles: map[string]bool{}}
+
+	ps, ok := doc[i].Value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("profiles must be a list")
+	}
+
+	for _, p := range ps {
+		name := fmt.Sprintf("%v", p)
+
+		if name == profileDefault {
+			return nil, fmt.Errorf("profile name %s is reserved", profileDefault)
+		}
+
+		r.profiles[name] = true
+	}
+
+	if profile != "" && !r.profiles[profile] {
+		return nil, fmt.Errorf("unknown profile: %s", profile)
+	}
+
+	doc = append(doc[:i:i], doc[i+1:]...)
+
+	if j := mapSliceIndex(doc, "services"); j >= 0 {
+		services, _ := doc[j].Value.(yaml.MapSlice)
+
+		for _, s := range services {
+			attrs, ok := s.Value.(yaml.MapSlice)
+			if !ok {
+				continue
+			}
+
+			if err := r.resolveService(fmt.Sprintf("services.%v", s.Key), attrs); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (r profileResolver) resolveService(prefix string, attrs yaml.MapSlice) error {
+	for i := range attrs {
+		key := fmt.Sprintf("%v", attrs[i].Key)
+		path := fmt.Sprintf("%s.%s", prefix, key)
+
+		switch key {
+		case "domain":
+			v, err := r.resolve(path, attrs[i].Value)
+			if err != nil {
+				return err
+			}
+
+			attrs[i].Value = v
+		case "environment":
+			v, err := r.resolveEnvironment(path, attrs[i].Value)
+			if err != nil {
+				return err
+			}
+
+			attrs[i].Value = v
+		case "scale":
+			v, err := r.resolve(path, attrs[i].Value)
+			if err != nil {
+				return err
+			}
+
+			if scale, ok := v.(yaml.MapSlice); ok {
+				if j := mapSliceIndex(scale, "count"); j >= 0 {
+					cv, err := r.resolve(fmt.Sprintf("%s.count", path), scale[j].Value)
+					if err != nil {
+						return err
+					}
+
+					scale[j].Value = cv
+				}
+			}
+
+			attrs[i].Value = v
+		}
+	}
+
+	return nil
+}
+
+// resolveEnvironment turns entries of the form `KEY: { profile: value }` into `KEY=value`
+func (r profileResolver) resolveEnvironment(path string, v interface{}) (interface{}, error) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return v, nil
+	}
+
+	for i, item := range items {
+		entry, ok := item.(yaml.MapSlice)
+		if !ok || len(entry) != 1 {
+			continue
+		}
+
+		name := fmt.Sprintf("%v", entry[0].Key)
+
+		ev, err := r.resolve(fmt.Sprintf("%s.%s", path, name), entry[0].Value)
+		if err != nil {
+			return nil, err
+		}
+
+		items[i] = fmt.Sprintf("%s=%v", name, ev)
+	}
+
+	return items, nil
+}
+
+// resolve returns the value for the active profile if v is a profile map, otherwise v
+func (r profileResolver) resolve(path string, v interface{}) (interface{}, error) {
+	ms, ok := v.(yaml.MapSlice)
+	if !ok || !r.selector(ms) {
+		return v, nil
+	}
+
+	unknown := []string{}
+
+	for _, item := range ms {
+		if k := fmt.Sprintf("%v", item.Key); k != profileDefault && !r.profiles[k] {
+			unknown = append(unknown, k)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("%s: unknown profiles: %s", path, strings.Join(unknown, ", "))
+	}
+
+	if r.active != "" {
+		if i := mapSliceIndex(ms, r.active); i >= 0 {
+			return ms[i].Value, nil
+		}
+	}
+
+	if i := mapSliceIndex(ms, profileDefault); i >= 0 {
+		return ms[i].Value, nil
+	}
+
+	if r.active == "" {
+		return nil, fmt.Errorf("%s: default required", path)
+	}
+
+	return nil, fmt.Errorf("%s: no value for profile %s and no default", path, r.active)
+}
+
+// selector returns true if any key in ms names a profile
+func (r profileResolver) selector(ms yaml.MapSlice) bool {
+	for _, item := range ms {
+		if k := fmt.Sprintf("%v", item.Key); k == profileDefault || r.profiles[k] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func mapSliceIndex(ms yaml.MapSlice, key string) int {
+	for i, item := range ms {
+		if fmt.Sprintf("%v", item.Key) == key {
+			return i
+		}
+	}
+
+	return -1
+}
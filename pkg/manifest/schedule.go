@@ -0,0 +1,135 @@
+package manifest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScheduleForms describes the accepted schedule forms for validation errors
+const ScheduleForms = `a cron expression of 5 or 6 fields, "rate: <value> <minutes|hours|days>", "@every <duration>" or "at: <future timestamp like 2030-01-02T15:04:05Z>"`
+
+// scheduleRateUnits maps the accepted rate units to the singular form AWS requires for a value of 1
+var scheduleRateUnits = map[string]string{
+	"minute":  "minute",
+	"minutes": "minute",
+	"hour":    "hour",
+	"hours":   "hour",
+	"day":     "day",
+	"days":    "day",
+}
+
+// ScheduleExpression translates a schedule into a cloudwatch events schedule expression
+func ScheduleExpression(schedule string) (string, error) {
+	s := strings.TrimSpace(schedule)
+
+	switch {
+	case strings.HasPrefix(s, "rate:"):
+		return scheduleRate(strings.TrimSpace(strings.TrimPrefix(s, "rate:")))
+	case strings.HasPrefix(s, "@every"):
+		return scheduleEvery(strings.TrimSpace(strings.TrimPrefix(s, "@every")))
+	case strings.HasPrefix(s, "at:"):
+		return scheduleAt(strings.TrimSpace(strings.TrimPrefix(s, "at:")))
+	}
+
+	switch len(strings.Fields(s)) {
+	case 5:
+		return fmt.Sprintf("cron(%s *)", strings.Join(strings.Fields(s), " ")), nil
+	case 6:
+		return fmt.Sprintf("cron(%s)", strings.Join(strings.Fields(s), " ")), nil
+	}
+
+	return "", fmt.Errorf("invalid schedule %q, must be %s", schedule, ScheduleForms)
+}
+
+// SplitSchedule separates the schedule at the start of a cron label from the command that follows it
+func SplitSchedule(value string) (string, string, error) {
+	tokens := strings.Fields(value)
+
+	n := 5
+
+	if len(tokens) > 0 {
+		switch t := tokens[0]; {
+		case t == "rate:":
+			n = 3
+		case strings.HasPrefix(t, "rate:"):
+			n = 2
+		case t == "@every", t == "at:":
+			n = 2
+		case strings.HasPrefix(t, "at:"):
+			n = 1
+		}
+	}
+
+	if len(tokens) < n {
+		return "", "", fmt.Errorf("invalid schedule %q, must be %s", value, ScheduleForms)
+	}
+
+	return strings.Join(tokens[0:n], " "), strings.Join(tokens[n:], " "), nil
+}
+
+func scheduleRate(rate string) (string, error) {
+	parts := strings.Fields(rate)
+
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid schedule %q, must be %s", "rate: "+rate, ScheduleForms)
+	}
+
+	value, err := strconv.Atoi(parts[0])
+	if err != nil || value < 1 {
+		return "", fmt.Errorf("invalid rate value %q, must be a positive integer", parts[0])
+	}
+
+	unit, ok := scheduleRateUnits[strings.ToLower(parts[1])]
+	if !ok {
+		return "", fmt.Errorf("invalid rate unit %q, must be one of minutes, hours or days", parts[1])
+	}
+
+	return rateExpression(value, unit), nil
+}
+
+func scheduleEvery(every string) (string, error) {
+	d, err := time.ParseDuration(every)
+	if err != nil {
+		return "", fmt.Errorf("invalid schedule %q, must be %s", "@every "+every, ScheduleForms)
+	}
+
+	if d < time.Minute || d%time.Minute != 0 {
+		return "", fmt.Errorf("invalid interval %s, must be a whole number of minutes", every)
+	}
+
+	switch {
+	case d%(24*time.Hour) == 0:
+		return rateExpression(int(d/(24*time.Hour)), "day"), nil
+	case d%time.Hour == 0:
+		return rateExpression(int(d/time.Hour), "hour"), nil
+	default:
+		return rateExpression(int(d/time.Minute), "minute"), nil
+	}
+}
+
+func scheduleAt(at string) (string, error) {
+	t, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		t, err = time.Parse("2006-01-02T15:04:05", at)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid schedule %q, must be %s", "at: "+at, ScheduleForms)
+	}
+
+	if !t.After(time.Now()) {
+		return "", fmt.Errorf("invalid schedule %q, the time must be in the future", "at: "+at)
+	}
+
+	return fmt.Sprintf("at(%s)", t.UTC().Format("2006-01-02T15:04:05")), nil
+}
+
+// rateExpression renders a rate, AWS rejects a plural unit for a value of 1 and a singular unit otherwise
+func rateExpression(value int, unit string) string {
+	if value != 1 {
+		unit += "s"
+	}
+
+	return fmt.Sprintf("rate(%d %s)", value, unit)
+}
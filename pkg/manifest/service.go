@@ -3,7 +3,9 @@ package manifest
 import (
 	"crypto/sha1"
 	"fmt"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -14,6 +16,7 @@ type Service struct {
 	Build       ServiceBuild       `yaml:"build,omitempty"`
 	Command     ServiceCommand     `yaml:"command,omitempty"`
 	Deployment  ServiceDeployment  `yaml:"deployment,omitempty"`
+	Devices     []DeviceMount      `yaml:"devices,omitempty"`
 	Domains     ServiceDomains     `yaml:"domain,omitempty"`
 	Drain       int                `yaml:"drain,omitempty"`
 	Environment Environment        `yaml:"environment,omitempty"`
@@ -22,15 +25,28 @@ type Service struct {
 	Init        bool               `yaml:"init,omitempty"`
 	Internal    bool               `yaml:"internal,omitempty"`
 	Links       []string           `yaml:"links,omitempty"`
+	Mounts      ServiceMounts      `yaml:"mounts,omitempty"`
 	Port        ServicePort        `yaml:"port,omitempty"`
 	Privileged  bool               `yaml:"privileged,omitempty"`
 	Resources   []string           `yaml:"resources,omitempty"`
 	Scale       ServiceScale       `yaml:"scale,omitempty"`
+	SHMSize     string             `yaml:"shm_size,omitempty"`
+	Sidecars    []SidecarService   `yaml:"sidecars,omitempty"`
 	Singleton   bool               `yaml:"singleton,omitempty"`
 	Sticky      bool               `yaml:"sticky,omitempty"`
 	Termination ServiceTermination `yaml:"termination,omitempty"`
 	Test        string             `yaml:"test,omitempty"`
+	User        string             `yaml:"user,omitempty"`
 	Volumes     []string           `yaml:"volumes,omitempty"`
+
+	CapAdd                 []string `yaml:"cap_add,omitempty"`
+	CapDrop                []string `yaml:"cap_drop,omitempty"`
+	ImageTag               string   `yaml:"image_tag,omitempty"`
+	ReadonlyRootFilesystem bool     `yaml:"read_only,omitempty"`
+	WorkingDirectory       string   `yaml:"working_dir,omitempty"`
+
+	// legacyImage is set when image was given alongside build and has been read as image_tag
+	legacyImage bool
 }
 
 type Services []Service
@@ -54,8 +70,9 @@ type ServiceBuild struct {
 type ServiceCommand []string
 
 type ServiceDeployment struct {
-	Maximum int `yaml:"maximum,omitempty"`
-	Minimum int `yaml:"minimum,omitempty"`
+	Maximum  int    `yaml:"maximum,omitempty"`
+	Minimum  int    `yaml:"minimum,omitempty"`
+	Strategy string `yaml:"strategy,omitempty"`
 }
 
 type ServiceDomains []string
@@ -70,12 +87,39 @@ type ServiceHealth struct {
 type ServicePort struct {
 	Port   int    `yaml:"port,omitempty"`
 	Scheme string `yaml:"scheme,omitempty"`
+
+	HealthCheckPath     string `yaml:"healthcheck_path,omitempty"`
+	HealthCheckProtocol string `yaml:"healthcheck_protocol,omitempty"`
+}
+
+// HealthCheckProtocols are the protocols a load balancer can use to check the health of a port
+// LinuxCapabilities are the capability names a service can add or drop
+var LinuxCapabilities = []string{
+	"AUDIT_CONTROL", "AUDIT_WRITE", "BLOCK_SUSPEND", "CHOWN", "DAC_OVERRIDE", "DAC_READ_SEARCH", "FOWNER",
+	"FSETID", "IPC_LOCK", "IPC_OWNER", "KILL", "LEASE", "LINUX_IMMUTABLE", "MAC_ADMIN", "MAC_OVERRIDE",
+	"MKNOD", "NET_ADMIN", "NET_BIND_SERVICE", "NET_BROADCAST", "NET_RAW", "SETFCAP", "SETGID", "SETPCAP",
+	"SETUID", "SYS_ADMIN", "SYS_BOOT", "SYS_CHROOT", "SYS_MODULE", "SYS_NICE", "SYS_PACCT", "SYS_PTRACE",
+	"SYS_RAWIO", "SYS_RESOURCE", "SYS_TIME", "SYS_TTY_CONFIG", "SYSLOG", "WAKE_ALARM",
 }
 
+// DeploymentStrategies are the values accepted for deployment strategy, an empty strategy is a rolling deployment
+var DeploymentStrategies = []string{DeploymentStrategyBlueGreen, DeploymentStrategyRolling}
+
+const (
+	DeploymentStrategyBlueGreen = "blue-green"
+	DeploymentStrategyRolling   = "rolling"
+)
+
+var HealthCheckProtocols = []string{"HTTP", "HTTPS", "TCP"}
+
+// httpPorts are the container ports assumed to serve http when no health check protocol is set
+var httpPorts = map[int]bool{80: true, 3000: true, 4000: true, 5000: true, 8080: true}
+
 type ServiceScale struct {
 	Cooldown ServiceScaleCooldown
 	Count    ServiceScaleCount
 	Cpu      int
+	Gpu      int
 	Memory   int
 	Targets  ServiceScaleTargets `yaml:"targets,omitempty"`
 }
@@ -111,6 +155,92 @@ type ServiceTermination struct {
 	Grace int `yaml:"grace,omitempty"`
 }
 
+// ServiceUser is the user a service runs as, a name or uid with an optional group name or gid
+type ServiceUser struct {
+	Name  string
+	Uid   *int64
+	Group string
+	Gid   *int64
+}
+
+// MaxUserId is the largest uid or gid, linux reserves 4294967295 for errors
+const MaxUserId = 4294967294
+
+var regexpUserName = regexp.MustCompile(`^[a-z_][a-z0-9_-]*$`)
+
+// ParseUser parses a user given as name or uid with an optional :group or :gid
+func ParseUser(user string) (ServiceUser, error) {
+	parts := strings.Split(user, ":")
+
+	if len(parts) > 2 {
+		return ServiceUser{}, fmt.Errorf("invalid user %q, must be a name or uid with an optional :group or :gid", user)
+	}
+
+	u := ServiceUser{}
+
+	if uid, err := strconv.ParseInt(parts[0], 10, 64); err == nil {
+		if uid < 0 || uid > MaxUserId {
+			return ServiceUser{}, fmt.Errorf("invalid uid %d, must be between 0 and %d", uid, MaxUserId)
+		}
+
+		u.Uid = &uid
+	} else if regexpUserName.MatchString(parts[0]) {
+		u.Name = parts[0]
+	} else {
+		return ServiceUser{}, fmt.Errorf("invalid user %q, must be a name or uid with an optional :group or :gid", user)
+	}
+
+	if len(parts) == 2 {
+		if gid, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+			if gid < 0 || gid > MaxUserId {
+				return ServiceUser{}, fmt.Errorf("invalid gid %d, must be between 0 and %d", gid, MaxUserId)
+			}
+
+			u.Gid = &gid
+		} else if regexpUserName.MatchString(parts[1]) {
+			u.Group = parts[1]
+		} else {
+			return ServiceUser{}, fmt.Errorf("invalid group %q, must be a name or gid", parts[1])
+		}
+	}
+
+	return u, nil
+}
+
+// regexpImageReference is the docker image reference grammar, [registry[:port]/]repo[/repo...][:tag][@digest]
+// repository components are lowercase while the registry may use any case
+var regexpImageReference = regexp.MustCompile(`^` +
+	`(?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+	`(?::[\w][\w.-]{0,127})?` +
+	`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?` +
+	`$`)
+
+// ValidateImageReference returns an error if image is not a valid docker image reference
+func ValidateImageReference(image string) error {
+	if !regexpImageReference.MatchString(image) {
+		return fmt.Errorf("invalid image reference %q, must be like registry/repository:tag or repository@sha256:digest", image)
+	}
+
+	// docker limits the name without tag or digest to 255 characters
+	name := strings.SplitN(image, "@", 2)[0]
+
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[0:i]
+	}
+
+	if len(name) > 255 {
+		return fmt.Errorf("invalid image reference %q, repository name must be at most 255 characters", image)
+	}
+
+	return nil
+}
+
+// Root returns true if the user is root by name or uid
+func (u ServiceUser) Root() bool {
+	return u.Name == "root" || (u.Uid != nil && *u.Uid == 0)
+}
+
 func (s Service) BuildHash(key string) string {
 	return fmt.Sprintf("%x", sha1.Sum([]byte(fmt.Sprintf("key=%q build[path=%q, manifest=%q, args=%v] image=%q", key, s.Build.Path, s.Build.Manifest, s.Build.Args, s.Image))))
 }
@@ -154,6 +284,48 @@ func (s Service) EnvironmentKeys() string {
 	return strings.Join(keys, ",")
 }
 
+// SharedMemorySize returns the size of /dev/shm in MiB rounded up, 0 if not set
+func (s Service) SharedMemorySize() int64 {
+	if s.SHMSize == "" {
+		return 0
+	}
+
+	size, err := parseSizeString(s.SHMSize)
+	if err != nil {
+		return 0
+	}
+
+	return (size + 1024*1024 - 1) / (1024 * 1024)
+}
+
+// HealthProtocol returns the protocol used to check the health of the port
+// it defaults to HTTP for common http ports and TCP for anything else
+func (p ServicePort) HealthProtocol() string {
+	if p.HealthCheckProtocol != "" {
+		return strings.ToUpper(p.HealthCheckProtocol)
+	}
+
+	if httpPorts[p.Port] {
+		return "HTTP"
+	}
+
+	return "TCP"
+}
+
+// HealthPath returns the path checked by the load balancer, a path on the port overrides the one under health
+func (s Service) HealthPath() string {
+	if s.Port.HealthCheckPath != "" {
+		return s.Port.HealthCheckPath
+	}
+
+	return s.Health.Path
+}
+
+// BlueGreen returns true if the service is balanced across a pair of target groups that the rack flips between
+func (s Service) BlueGreen() bool {
+	return s.Deployment.Strategy == DeploymentStrategyBlueGreen
+}
+
 func (s Service) GetName() string {
 	return s.Name
 }
@@ -178,3 +350,14 @@ func (s Service) Autoscale() bool {
 
 	return false
 }
+
+// ScaleBounds returns the count a service deploys with and the bounds autoscaling keeps it within
+// a fixed scale like 2 has equal bounds, a range like 1-5 deploys at its minimum
+// ok is false for agents, which run one process per instance regardless of scale
+func (s Service) ScaleBounds() (count, min, max int, ok bool) {
+	if s.Agent.Enabled {
+		return 0, 0, 0, false
+	}
+
+	return s.Scale.Count.Min, s.Scale.Count.Min, s.Scale.Count.Max, true
+}
@@ -0,0 +1,96 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SidecarConditions are the states a container can wait for another container of its task to reach
+var SidecarConditions = []string{"COMPLETE", "HEALTHY", "START", "SUCCESS"}
+
+// SidecarService is a container that runs in the same task as its service, such as a proxy or a log router
+type SidecarService struct {
+	Name        string                `yaml:"name"`
+	Image       string                `yaml:"image"`
+	Environment map[string]string     `yaml:"environment,omitempty"`
+	VolumesFrom []string              `yaml:"volumes_from,omitempty"`
+	DependsOn   []ContainerDependency `yaml:"depends_on,omitempty"`
+	Essential   bool                  `yaml:"essential,omitempty"`
+}
+
+// ContainerDependency holds a sidecar back until another container of the task reaches a condition, START by default
+type ContainerDependency struct {
+	Container string `yaml:"container"`
+	Condition string `yaml:"condition,omitempty"`
+}
+
+// ConditionOrDefault returns the condition of the dependency, START when none is given
+func (d ContainerDependency) ConditionOrDefault() string {
+	if d.Condition == "" {
+		return "START"
+	}
+
+	return d.Condition
+}
+
+// validateSidecars returns an error if a sidecar is unnamed, shares a name with its service or another sidecar,
+// has no image, depends on or takes volumes from a container outside its task, or if more than one sidecar of
+// a service is essential
+func (m *Manifest) validateSidecars() error {
+	for _, s := range m.Services {
+		containers := map[string]bool{s.Name: true}
+		essential := ""
+
+		for _, sc := range s.Sidecars {
+			if !nameValidator.MatchString(sc.Name) {
+				return fmt.Errorf("service %s sidecar name %s invalid, %s", s.Name, sc.Name, ValidNameDescription)
+			}
+
+			if sc.Name == s.Name {
+				return fmt.Errorf("service %s sidecar name %s invalid, must differ from the name of its service", s.Name, sc.Name)
+			}
+
+			if containers[sc.Name] {
+				return fmt.Errorf("service %s sidecar %s is defined more than once", s.Name, sc.Name)
+			}
+
+			if strings.TrimSpace(sc.Image) == "" {
+				return fmt.Errorf("service %s sidecar %s requires an image", s.Name, sc.Name)
+			}
+
+			if sc.Essential {
+				if essential != "" {
+					return fmt.Errorf("service %s sidecars %s and %s are both essential, only one sidecar of a service can be", s.Name, essential, sc.Name)
+				}
+
+				essential = sc.Name
+			}
+
+			containers[sc.Name] = true
+		}
+
+		for _, sc := range s.Sidecars {
+			for _, d := range sc.DependsOn {
+				if d.Container == sc.Name {
+					return fmt.Errorf("service %s sidecar %s can not depend on itself", s.Name, sc.Name)
+				}
+
+				if !containers[d.Container] {
+					return fmt.Errorf("service %s sidecar %s depends on unknown container %s", s.Name, sc.Name, d.Container)
+				}
+
+				if d.Condition != "" && !containsString(SidecarConditions, d.Condition) {
+					return fmt.Errorf("service %s sidecar %s condition %s invalid, must be one of %s", s.Name, sc.Name, d.Condition, strings.Join(SidecarConditions, ", "))
+				}
+			}
+
+			for _, v := range sc.VolumesFrom {
+				if v == sc.Name || !containers[v] {
+					return fmt.Errorf("service %s sidecar %s volumes_from %s invalid, must be another container of the service", s.Name, sc.Name, v)
+				}
+			}
+		}
+	}
+
+	return nil
+}
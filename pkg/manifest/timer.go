@@ -11,6 +11,10 @@ type Timer struct {
 	Command  string `yaml:"command"`
 	Schedule string `yaml:"schedule"`
 	Service  string `yaml:"service"`
+
+	// Timezone is the TZ the command runs with, app.timezone when unset
+	// cloudwatch events evaluates the schedule itself in utc
+	Timezone string `yaml:"timezone,omitempty"`
 }
 
 type Timers []Timer
@@ -26,6 +30,11 @@ func (t Timer) Cron() (string, error) {
 	}
 }
 
+// ScheduleExpression returns the cloudwatch events expression for the timer schedule
+func (t Timer) ScheduleExpression() (string, error) {
+	return ScheduleExpression(t.Schedule)
+}
+
 func (t Timer) GetName() string {
 	return t.Name
 }
@@ -0,0 +1,111 @@
+package manifest
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// VolumePerformanceModes are the EFS performance modes a volume can use
+var VolumePerformanceModes = []string{"generalPurpose", "maxIO"}
+
+// Volume is named storage that outlives the processes of the services that mount it
+type Volume struct {
+	Name string `yaml:"-"`
+
+	Encrypted   bool   `yaml:"encrypted,omitempty"`
+	Performance string `yaml:"performance,omitempty"`
+}
+
+type Volumes []Volume
+
+// ServiceMount attaches a named volume to a path in the containers of a service
+type ServiceMount struct {
+	Volume string
+	Path   string
+}
+
+type ServiceMounts []ServiceMount
+
+func (v Volume) GetName() string {
+	return v.Name
+}
+
+// Volume returns the named volume
+func (m *Manifest) Volume(name string) (*Volume, error) {
+	for _, v := range m.Volumes {
+		if v.Name == name {
+			return &v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no such volume: %s", name)
+}
+
+// MountedVolumes returns the names of the volumes a service mounts, each once and in name order
+func (s Service) MountedVolumes() []string {
+	vh := map[string]bool{}
+
+	for _, m := range s.Mounts {
+		vh[m.Volume] = true
+	}
+
+	vs := []string{}
+
+	for v := range vh {
+		vs = append(vs, v)
+	}
+
+	sort.Strings(vs)
+
+	return vs
+}
+
+// validateVolumes returns an error if a volume is misconfigured or a service mount is unknown, relative or overlapping
+func (m *Manifest) validateVolumes() error {
+	for _, v := range m.Volumes {
+		if !nameValidator.MatchString(v.Name) {
+			return fmt.Errorf("volume name %s invalid, %s", v.Name, ValidNameDescription)
+		}
+
+		if !containsString(VolumePerformanceModes, v.Performance) {
+			return fmt.Errorf("volume %s performance invalid, must be one of %s", v.Name, strings.Join(VolumePerformanceModes, ", "))
+		}
+	}
+
+	for _, s := range m.Services {
+		paths := []string{}
+
+		for _, sm := range s.Mounts {
+			if _, err := m.Volume(sm.Volume); err != nil {
+				return fmt.Errorf("service %s mounts unknown volume %s", s.Name, sm.Volume)
+			}
+
+			if !path.IsAbs(sm.Path) {
+				return fmt.Errorf("service %s mount path %s must be absolute", s.Name, sm.Path)
+			}
+
+			p := path.Clean(sm.Path)
+
+			for _, q := range paths {
+				if mountPathsOverlap(p, q) {
+					return fmt.Errorf("service %s mount paths %s and %s overlap", s.Name, q, p)
+				}
+			}
+
+			paths = append(paths, p)
+		}
+	}
+
+	return nil
+}
+
+// mountPathsOverlap returns true if a and b are the same path or one is inside the other
+func mountPathsOverlap(a, b string) bool {
+	if a == b || a == "/" || b == "/" {
+		return true
+	}
+
+	return strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
@@ -75,6 +75,19 @@ func (v *Service) SetName(name string) error {
 	return nil
 }
 
+func (v Volumes) MarshalYAML() (interface{}, error) {
+	return marshalMapSlice(v)
+}
+
+func (v *Volumes) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return unmarshalMapSlice(unmarshal, v)
+}
+
+func (v *Volume) SetName(name string) error {
+	v.Name = name
+	return nil
+}
+
 func (v *ServiceAgent) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var w interface{}
 
@@ -224,6 +237,71 @@ func (v *ServiceDomains) UnmarshalYAML(unmarshal func(interface{}) error) error
 	return nil
 }
 
+func (v *ServiceMount) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	parts := strings.SplitN(s, ":", 2)
+
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("invalid mount %q, must be <volume>:<path>", s)
+	}
+
+	v.Volume = parts[0]
+	v.Path = parts[1]
+
+	return nil
+}
+
+func (v ServiceMount) MarshalYAML() (interface{}, error) {
+	return fmt.Sprintf("%s:%s", v.Volume, v.Path), nil
+}
+
+// UnmarshalYAML reads a device as <host>[:<container>[:<permissions>]] like docker or as a map
+func (v *DeviceMount) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+
+	if err := unmarshal(&s); err == nil {
+		parts := strings.Split(s, ":")
+
+		if len(parts) > 3 || parts[0] == "" {
+			return fmt.Errorf("invalid device %q, must be <host>[:<container>[:<permissions>]]", s)
+		}
+
+		v.HostPath = parts[0]
+		v.ContainerPath = parts[0]
+
+		if len(parts) > 1 {
+			v.ContainerPath = parts[1]
+		}
+
+		if len(parts) > 2 {
+			v.Permissions = parts[2]
+		}
+
+		return nil
+	}
+
+	type deviceMount DeviceMount
+
+	var d deviceMount
+
+	if err := unmarshal(&d); err != nil {
+		return err
+	}
+
+	*v = DeviceMount(d)
+
+	if v.ContainerPath == "" {
+		v.ContainerPath = v.HostPath
+	}
+
+	return nil
+}
+
 func (v *ServiceHealth) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var w interface{}
 
@@ -287,6 +365,14 @@ func (v *ServicePort) UnmarshalYAML(unmarshal func(interface{}) error) error {
 			v.Scheme = scheme.(string)
 		}
 
+		if path, ok := t["healthcheck_path"].(string); ok {
+			v.HealthCheckPath = path
+		}
+
+		if protocol, ok := t["healthcheck_protocol"].(string); ok {
+			v.HealthCheckProtocol = protocol
+		}
+
 		if v.Port == 0 {
 			return fmt.Errorf("could not parse port: %+v", t)
 		}
@@ -354,6 +440,9 @@ func (v *ServiceScale) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		if w, ok := t["cpu"].(int); ok {
 			v.Cpu = w
 		}
+		if w, ok := t["gpu"].(int); ok {
+			v.Gpu = w
+		}
 		if w, ok := t["memory"].(int); ok {
 			v.Memory = w
 		}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"path/filepath"
 	"sort"
@@ -15,6 +16,9 @@ type BuildOptions struct {
 	Environment map[string]string
 	Service     string
 	Verbose     bool
+
+	// ContextWarnSize is the context size in bytes to warn above, DefaultContextWarnSize when zero and never when negative
+	ContextWarnSize int64
 }
 
 func (m *Manifest) Build(dir, appName string, s Stream, opts BuildOptions) error {
@@ -93,11 +97,46 @@ func (m *Manifest) Build(dir, appName string, s Stream, opts BuildOptions) error
 			args = append(args, "--build-arg", fmt.Sprintf("%s=%s", name, bargs[name]))
 		}
 
+		report, err := ReportContext(context)
+		if err != nil {
+			return fmt.Errorf("build error: %s", err)
+		}
+
+		warn := opts.ContextWarnSize
+		if warn == 0 {
+			warn = DefaultContextWarnSize
+		}
+
+		for _, line := range report.Lines(warn) {
+			s <- line
+		}
+
+		var input io.Reader
+
+		// docker only knows about .dockerignore so a context using another ignore file is sent on stdin
+		if report.IgnoreFile != "" && report.IgnoreFile != IgnoreFiles[0] {
+			rel, err := filepath.Rel(context, dockerFile)
+			if err != nil {
+				return err
+			}
+
+			input, err = contextArchive(context, rel)
+			if err != nil {
+				return fmt.Errorf("build error: %s", err)
+			}
+
+			dockerFile = rel
+			context = "-"
+		}
+
 		args = append(args, "-f", dockerFile)
 		args = append(args, "-t", service.Tag(appName))
 		args = append(args, context)
 
-		if err := DefaultRunner.Run(s, Docker(args...), RunnerOptions{Verbose: opts.Verbose}); err != nil {
+		cmd := Docker(args...)
+		cmd.Stdin = input
+
+		if err := DefaultRunner.Run(s, cmd, RunnerOptions{Verbose: opts.Verbose}); err != nil {
 			return fmt.Errorf("build error: %s", err)
 		}
 
@@ -1,13 +1,17 @@
 package manifest1_test
 
 import (
+	"archive/tar"
 	"fmt"
+	"io"
 	"math"
+	"os"
 	"os/exec"
 	"testing"
 
 	"github.com/convox/rack/pkg/manifest1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type ExecResponse struct {
@@ -334,3 +338,47 @@ func TestPush(t *testing.T) {
 	assert.Equal(t, te.Commands[2].Args, cmd3)
 	assert.Equal(t, te.Commands[3].Args, cmd4)
 }
+
+func TestBuildConvoxIgnore(t *testing.T) {
+	output := manifest1.NewOutput(true)
+	str := output.Stream("build")
+	dr := manifest1.DefaultRunner
+	te := NewTestExecer()
+
+	manifest1.DefaultRunner = te
+	defer func() { manifest1.DefaultRunner = dr }()
+
+	dir := contextFixture(t, map[string]string{
+		".convoxignore":  "tmp\n",
+		"Dockerfile":     "FROM scratch\n",
+		"tmp/cache.data": "cache",
+	})
+	defer os.RemoveAll(dir)
+
+	m, err := manifest1.Load([]byte("web:\n  build: .\n"))
+	require.NoError(t, err)
+
+	err = m.Build(dir, "app", str, manifest1.BuildOptions{Cache: true})
+	require.NoError(t, err)
+
+	te.AssertCommands(t, TestCommands{
+		[]string{"docker", "build", "-f", "Dockerfile", "-t", "app/web", "-"},
+	})
+
+	require.Len(t, te.Commands, 1)
+
+	tr := tar.NewReader(te.Commands[0].Stdin)
+	names := []string{}
+
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		names = append(names, h.Name)
+	}
+
+	assert.Equal(t, []string{".convoxignore", "Dockerfile"}, names)
+}
@@ -0,0 +1,186 @@
+package manifest1
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/pkg/archive"
+	humanize "github.com/dustin/go-humanize"
+)
+
+// DefaultContextWarnSize is the build context size above which a build warns about what could be ignored
+const DefaultContextWarnSize = 100 * 1024 * 1024
+
+// ContextTop is the number of largest top level entries listed in a ContextReport
+const ContextTop = 5
+
+// ContextEntry is a top level file or directory in a build context and the size it adds
+type ContextEntry struct {
+	Path  string
+	Dir   bool
+	Size  int64
+	Files int
+}
+
+// ContextReport is what a build sends to docker from a context directory once ignored paths are left out
+type ContextReport struct {
+	Dir        string
+	IgnoreFile string
+	Files      int
+	Size       int64
+	Top        []ContextEntry
+}
+
+// ReportContext walks a build context honoring its ignore file and totals what would be sent
+func ReportContext(dir string) (*ContextReport, error) {
+	patterns, file, err := ReadIgnore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := NewIgnoreMatcher(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+
+	r := &ContextReport{Dir: dir, IgnoreFile: file}
+
+	entries := map[string]*ContextEntry{}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		ignored, err := m.Matches(rel)
+		if err != nil {
+			return err
+		}
+
+		if ignored {
+			if info.IsDir() && m.SkipDir(rel) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		// the top level directory itself may be ignored with some of its contents added back
+		top := strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]
+
+		e, ok := entries[top]
+		if !ok {
+			e = &ContextEntry{Path: top, Dir: info.IsDir() || top != filepath.ToSlash(rel)}
+			entries[top] = e
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		e.Files++
+		e.Size += info.Size()
+
+		r.Files++
+		r.Size += info.Size()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		r.Top = append(r.Top, *e)
+	}
+
+	sort.Slice(r.Top, func(i, j int) bool {
+		if r.Top[i].Size != r.Top[j].Size {
+			return r.Top[i].Size > r.Top[j].Size
+		}
+
+		return r.Top[i].Path < r.Top[j].Path
+	})
+
+	if len(r.Top) > ContextTop {
+		r.Top = r.Top[0:ContextTop]
+	}
+
+	return r, nil
+}
+
+// Lines describes the report for build output and, when the context is larger than warn, suggests entries to ignore
+func (r *ContextReport) Lines(warn int64) []string {
+	ignore := coalesce(r.IgnoreFile, IgnoreFiles[0])
+
+	lines := []string{
+		fmt.Sprintf("context: %s is %s in %d files", r.Dir, humanize.Bytes(uint64(r.Size)), r.Files),
+	}
+
+	if r.IgnoreFile == "" {
+		lines[0] += ", no ignore file"
+	} else {
+		lines[0] += fmt.Sprintf(", using %s", r.IgnoreFile)
+	}
+
+	top := []string{}
+
+	for _, e := range r.Top {
+		top = append(top, fmt.Sprintf("%s %s", e.display(), humanize.Bytes(uint64(e.Size))))
+	}
+
+	if len(top) > 0 {
+		lines = append(lines, fmt.Sprintf("context: largest %s", strings.Join(top, ", ")))
+	}
+
+	if warn > 0 && r.Size > warn {
+		suggest := []string{}
+
+		for _, e := range r.Top {
+			if e.Dir && e.Size > 0 {
+				suggest = append(suggest, e.Path)
+			}
+		}
+
+		if len(suggest) > 0 {
+			lines = append(lines, fmt.Sprintf("context: WARNING larger than %s, consider adding %s to %s", humanize.Bytes(uint64(warn)), strings.Join(suggest, ", "), ignore))
+		} else {
+			lines = append(lines, fmt.Sprintf("context: WARNING larger than %s", humanize.Bytes(uint64(warn))))
+		}
+	}
+
+	return lines
+}
+
+func (e ContextEntry) display() string {
+	if e.Dir {
+		return e.Path + "/"
+	}
+
+	return e.Path
+}
+
+// contextArchive tars up dir leaving out what its ignore file matches, the dockerfile is always included
+func contextArchive(dir, dockerfile string) (io.Reader, error) {
+	patterns, _, err := ReadIgnore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return archive.TarWithOptions(dir, &archive.TarOptions{
+		ExcludePatterns: append(patterns, "!"+filepath.ToSlash(dockerfile)),
+	})
+}
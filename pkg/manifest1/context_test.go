@@ -0,0 +1,99 @@
+package manifest1_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/convox/rack/pkg/manifest1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func contextFixture(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(data), 0644))
+	}
+
+	return dir
+}
+
+func TestReportContext(t *testing.T) {
+	dir := contextFixture(t, map[string]string{
+		".dockerignore":              "# dependencies\n/node_modules\n**/*.log\ndocs\n!docs/README.md\n",
+		"Dockerfile":                 "FROM scratch\n",
+		"app/main.go":                "package main\n",
+		"app/debug.log":              strings.Repeat("x", 500),
+		"docs/README.md":             "readme\n",
+		"docs/guide.md":              strings.Repeat("x", 300),
+		"node_modules/pkg/index.js":  strings.Repeat("x", 1000),
+		"vendor/lib/lib.go":          strings.Repeat("x", 200),
+		"vendor/node_modules/dep.js": strings.Repeat("x", 50),
+	})
+	defer os.RemoveAll(dir)
+
+	r, err := manifest1.ReportContext(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, ".dockerignore", r.IgnoreFile)
+	assert.Equal(t, 6, r.Files)
+	assert.Equal(t, int64(59+13+13+7+200+50), r.Size)
+
+	assert.Equal(t, []manifest1.ContextEntry{
+		{Path: "vendor", Dir: true, Size: 250, Files: 2},
+		{Path: ".dockerignore", Size: 59, Files: 1},
+		{Path: "Dockerfile", Size: 13, Files: 1},
+		{Path: "app", Dir: true, Size: 13, Files: 1},
+		{Path: "docs", Dir: true, Size: 7, Files: 1},
+	}, r.Top)
+}
+
+func TestReportContextConvoxIgnore(t *testing.T) {
+	dir := contextFixture(t, map[string]string{
+		".convoxignore":  "tmp\n",
+		"Dockerfile":     "FROM scratch\n",
+		"tmp/cache.data": strings.Repeat("x", 1000),
+	})
+	defer os.RemoveAll(dir)
+
+	r, err := manifest1.ReportContext(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, ".convoxignore", r.IgnoreFile)
+	assert.Equal(t, 2, r.Files)
+	assert.Equal(t, int64(4+13), r.Size)
+}
+
+func TestContextReportLines(t *testing.T) {
+	r := &manifest1.ContextReport{
+		Dir:   "app",
+		Files: 1200,
+		Size:  250 * 1000 * 1000,
+		Top: []manifest1.ContextEntry{
+			{Path: "node_modules", Dir: true, Size: 200 * 1000 * 1000, Files: 1000},
+			{Path: ".git", Dir: true, Size: 45 * 1000 * 1000, Files: 190},
+			{Path: "data.json", Size: 5 * 1000 * 1000, Files: 1},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"context: app is 250 MB in 1200 files, no ignore file",
+		"context: largest node_modules/ 200 MB, .git/ 45 MB, data.json 5.0 MB",
+		"context: WARNING larger than 100 MB, consider adding node_modules, .git to .dockerignore",
+	}, r.Lines(100*1000*1000))
+
+	r.IgnoreFile = ".convoxignore"
+
+	assert.Equal(t, []string{
+		"context: app is 250 MB in 1200 files, using .convoxignore",
+		"context: largest node_modules/ 200 MB, .git/ 45 MB, data.json 5.0 MB",
+	}, r.Lines(300*1000*1000))
+}
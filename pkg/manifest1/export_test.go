@@ -0,0 +1,7 @@
+package manifest1
+
+import "io"
+
+func (o *Output) SetConsole(w io.Writer) {
+	o.console = w
+}
@@ -0,0 +1,149 @@
+package manifest1
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/pkg/fileutils"
+)
+
+// IgnoreFiles are the files read for build context ignore patterns, in order of preference
+// .convoxignore is only used when there is no .dockerignore
+var IgnoreFiles = []string{".dockerignore", ".convoxignore"}
+
+// IgnoreMatcher matches paths relative to a build context against dockerignore patterns
+type IgnoreMatcher struct {
+	Patterns []string
+
+	dirs       [][]string
+	exceptions bool
+}
+
+// ParseIgnore reads dockerignore patterns skipping comments and blank lines
+// Patterns are cleaned the way docker does, a leading slash is dropped as patterns are always relative to the context
+func ParseIgnore(r io.Reader) ([]string, error) {
+	patterns := []string{}
+
+	scanner := bufio.NewScanner(r)
+
+	for line := 0; scanner.Scan(); line++ {
+		data := scanner.Bytes()
+
+		if line == 0 {
+			data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+		}
+
+		pattern := string(data)
+
+		if strings.HasPrefix(pattern, "#") {
+			continue
+		}
+
+		pattern = strings.TrimSpace(pattern)
+
+		if pattern == "" {
+			continue
+		}
+
+		negate := strings.HasPrefix(pattern, "!")
+
+		if negate {
+			pattern = strings.TrimSpace(pattern[1:])
+		}
+
+		if pattern != "" {
+			pattern = filepath.ToSlash(filepath.Clean(pattern))
+
+			if len(pattern) > 1 && pattern[0] == '/' {
+				pattern = pattern[1:]
+			}
+		}
+
+		if negate {
+			pattern = "!" + pattern
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read ignore patterns: %s", err)
+	}
+
+	return patterns, nil
+}
+
+// ReadIgnore returns the patterns from the first of IgnoreFiles found in dir and the name of that file
+// The name is empty when dir has no ignore file
+func ReadIgnore(dir string) ([]string, string, error) {
+	for _, name := range IgnoreFiles {
+		fd, err := os.Open(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		defer fd.Close()
+
+		patterns, err := ParseIgnore(fd)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %s", name, err)
+		}
+
+		return patterns, name, nil
+	}
+
+	return []string{}, "", nil
+}
+
+// NewIgnoreMatcher compiles patterns as returned by ParseIgnore
+func NewIgnoreMatcher(patterns []string) (*IgnoreMatcher, error) {
+	ps, dirs, exceptions, err := fileutils.CleanPatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IgnoreMatcher{Patterns: ps, dirs: dirs, exceptions: exceptions}, nil
+}
+
+// Matches returns true if path is left out of the context
+// A later pattern overrides an earlier one so a negation can add back part of an ignored directory
+func (m *IgnoreMatcher) Matches(path string) (bool, error) {
+	path = filepath.Clean(path)
+
+	if path == "." || len(m.Patterns) == 0 {
+		return false, nil
+	}
+
+	return fileutils.OptimizedMatches(path, m.Patterns, m.dirs)
+}
+
+// SkipDir returns true if nothing under the ignored directory dir can be added back by a negation
+func (m *IgnoreMatcher) SkipDir(dir string) bool {
+	if !m.exceptions {
+		return true
+	}
+
+	prefix := filepath.ToSlash(filepath.Clean(dir)) + "/"
+
+	for _, p := range m.Patterns {
+		if !strings.HasPrefix(p, "!") {
+			continue
+		}
+
+		p = p[1:]
+
+		// a negation with a wildcard could match anywhere below
+		if strings.ContainsAny(p, "*?[\\") || strings.HasPrefix(p, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
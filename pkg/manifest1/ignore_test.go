@@ -0,0 +1,100 @@
+package manifest1_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/convox/rack/pkg/manifest1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIgnore(t *testing.T) {
+	input := "\xEF\xBB\xBF# comment\nnode_modules\n\n  /tmp/  \n!/docs/README.md\n./build/../dist\n**/*.log\n"
+
+	patterns, err := manifest1.ParseIgnore(strings.NewReader(input))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"node_modules", "tmp", "!docs/README.md", "dist", "**/*.log"}, patterns)
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	tests := []struct {
+		Patterns []string
+		Path     string
+		Ignored  bool
+	}{
+		{[]string{"node_modules"}, "node_modules", true},
+		{[]string{"node_modules"}, "node_modules/pkg/index.js", true},
+		{[]string{"node_modules"}, "app/node_modules", false},
+		{[]string{"**/node_modules"}, "app/node_modules", true},
+		{[]string{"**/*.log"}, "debug.log", true},
+		{[]string{"**/*.log"}, "app/logs/debug.log", true},
+		{[]string{"*.log"}, "app/debug.log", false},
+		{[]string{"app/*.go"}, "app/main.go", true},
+		{[]string{"app/*.go"}, "app/cmd/main.go", false},
+		{[]string{"docs", "!docs/README.md"}, "docs/guide.md", true},
+		{[]string{"docs", "!docs/README.md"}, "docs/README.md", false},
+		{[]string{"!docs/README.md", "docs"}, "docs/README.md", true},
+		{[]string{"*", "!app"}, "app/main.go", false},
+		{[]string{"*", "!app"}, "Dockerfile", true},
+		{[]string{"tmp"}, ".", false},
+		{[]string{}, "anything", false},
+	}
+
+	for _, test := range tests {
+		m, err := manifest1.NewIgnoreMatcher(test.Patterns)
+		require.NoError(t, err)
+
+		ignored, err := m.Matches(test.Path)
+		require.NoError(t, err)
+
+		assert.Equal(t, test.Ignored, ignored, "%v %s", test.Patterns, test.Path)
+	}
+}
+
+func TestIgnoreMatcherInvalid(t *testing.T) {
+	_, err := manifest1.NewIgnoreMatcher([]string{"!"})
+	assert.EqualError(t, err, "Illegal exclusion pattern: !")
+}
+
+func TestIgnoreMatcherSkipDir(t *testing.T) {
+	m, err := manifest1.NewIgnoreMatcher([]string{"docs", "node_modules", "!docs/README.md"})
+	require.NoError(t, err)
+
+	assert.False(t, m.SkipDir("docs"))
+	assert.True(t, m.SkipDir("node_modules"))
+
+	m, err = manifest1.NewIgnoreMatcher([]string{"node_modules", "!**/*.md"})
+	require.NoError(t, err)
+
+	assert.False(t, m.SkipDir("node_modules"))
+}
+
+func TestReadIgnore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	patterns, file, err := manifest1.ReadIgnore(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, patterns)
+	assert.Equal(t, "", file)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".convoxignore"), []byte("tmp\n"), 0644))
+
+	patterns, file, err = manifest1.ReadIgnore(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tmp"}, patterns)
+	assert.Equal(t, ".convoxignore", file)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".dockerignore"), []byte("node_modules\n"), 0644))
+
+	patterns, file, err = manifest1.ReadIgnore(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"node_modules"}, patterns)
+	assert.Equal(t, ".dockerignore", file)
+}
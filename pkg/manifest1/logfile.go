@@ -0,0 +1,197 @@
+package manifest1
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLogMaxSize is the size a service log grows to before it is rotated
+const DefaultLogMaxSize = 10 * 1024 * 1024
+
+const logTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// LogFile is a size capped log for a single service written to <dir>/<service>.log
+// When a write would take it past MaxSize the file is moved to <service>.log.1 and a new one started
+type LogFile struct {
+	MaxSize int64
+	Path    string
+
+	file *os.File
+	lock sync.Mutex
+	size int64
+}
+
+// LogLine is a single timestamped line read back from a service log
+type LogLine struct {
+	Service string
+	Time    time.Time
+	Line    string
+}
+
+// OpenLogFile opens or creates the log for service in dir, appending to any existing log
+func OpenLogFile(dir, service string, max int64) (*LogFile, error) {
+	if max <= 0 {
+		max = DefaultLogMaxSize
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	l := &LogFile{
+		MaxSize: max,
+		Path:    logFilePath(dir, service),
+	}
+
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// WriteLine appends line to the log prefixed with t, rotating first if the line would not fit
+func (l *LogFile) WriteLine(t time.Time, line string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.file == nil {
+		return fmt.Errorf("log closed: %s", l.Path)
+	}
+
+	data := []byte(fmt.Sprintf("%s %s\n", t.UTC().Format(logTimeFormat), line))
+
+	if l.size > 0 && l.size+int64(len(data)) > l.MaxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+
+	return err
+}
+
+// Close closes the log, later writes return an error
+func (l *LogFile) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+
+	return err
+}
+
+func (l *LogFile) open() error {
+	fd, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	stat, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return err
+	}
+
+	l.file = fd
+	l.size = stat.Size()
+
+	return nil
+}
+
+func (l *LogFile) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+
+	l.file = nil
+
+	if err := os.Rename(l.Path, l.Path+".1"); err != nil {
+		return err
+	}
+
+	return l.open()
+}
+
+// ReplayLogs reads the logs for services in dir, including the rotated ones, and returns
+// the lines written at or after since ordered by time
+func ReplayLogs(dir string, services []string, since time.Time) ([]LogLine, error) {
+	lines := []LogLine{}
+
+	for _, s := range services {
+		path := logFilePath(dir, s)
+
+		for _, file := range []string{path + ".1", path} {
+			ll, err := readLogFile(file, s, since)
+			if err != nil {
+				return nil, err
+			}
+
+			lines = append(lines, ll...)
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Time.Before(lines[j].Time)
+	})
+
+	return lines, nil
+}
+
+func readLogFile(path, service string, since time.Time) ([]LogLine, error) {
+	fd, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	lines := []LogLine{}
+
+	scanner := bufio.NewScanner(fd)
+
+	scanner.Buffer(make([]byte, 0, 4*1024), 10*1024*1024)
+
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		t, err := time.Parse(logTimeFormat, parts[0])
+		if err != nil {
+			continue
+		}
+
+		if t.Before(since) {
+			continue
+		}
+
+		lines = append(lines, LogLine{Service: service, Time: t, Line: parts[1]})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+func logFilePath(dir, service string) string {
+	return filepath.Join(dir, fmt.Sprintf("%s.log", service))
+}
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/convox/rack/pkg/manifest"
 	"github.com/docker/go-units"
 	"github.com/twmb/algoimpl/go/graph"
 	"gopkg.in/yaml.v2"
@@ -20,6 +21,7 @@ import (
 
 var interpolationBracketRegex = regexp.MustCompile("\\$\\{([0-9A-Za-z_]*)\\}")
 var interpolationDollarRegex = regexp.MustCompile("\\$([0-9A-Za-z_]+)")
+var logMaxSizeRegex = regexp.MustCompile(`^\d+(k|m|g)$`)
 
 type Manifest struct {
 	Version  string             `yaml:"version"`
@@ -68,7 +70,7 @@ func Load(data []byte) (*Manifest, error) {
 		}
 
 		// denormalize a bit
-		service.Networks = m.Networks
+		service.ExternalNetworks = m.Networks
 
 		m.Services[name] = service
 	}
@@ -96,6 +98,34 @@ func (m Manifest) Service(name string) (*Service, error) {
 	return nil, fmt.Errorf("no such service: %s", name)
 }
 
+// Timers returns the cron labels of every service as timers sorted by name
+// a label without a command leaves Command empty so the service command runs
+func (m Manifest) Timers() manifest.Timers {
+	ts := manifest.Timers{}
+
+	for _, entry := range m.Services {
+		for k, v := range entry.LabelsByPrefix("convox.cron") {
+			parts := strings.Split(k, ".")
+
+			schedule, command, err := manifest.SplitSchedule(v)
+			if err != nil {
+				schedule = v
+			}
+
+			ts = append(ts, manifest.Timer{
+				Name:     parts[len(parts)-1],
+				Command:  command,
+				Schedule: schedule,
+				Service:  entry.Name,
+			})
+		}
+	}
+
+	sort.Slice(ts, func(i, j int) bool { return ts[i].Name < ts[j].Name })
+
+	return ts
+}
+
 func (m Manifest) Validate() []error {
 	regexValidCronLabel := regexp.MustCompile(`\A[a-zA-Z][-a-zA-Z0-9]{3,29}\z`)
 	errors := []error{}
@@ -106,10 +136,17 @@ func (m Manifest) Validate() []error {
 		}
 
 		labels := entry.LabelsByPrefix("convox.cron")
-		for k := range labels {
+
+		// cron tasks run as one-off processes of the service that carries the label
+		if len(labels) > 0 && entry.Image == "" && entry.Build.Context == "" {
+			errors = append(errors, fmt.Errorf("%s has cron tasks but declares neither an image nor a build", entry.Name))
+		}
+
+		for k, v := range labels {
 			parts := strings.Split(k, ".")
 			if len(parts) != 3 {
 				errors = append(errors, fmt.Errorf("Cron task is not valid (must be in format convox.cron.myjob)"))
+				continue
 			}
 			name := parts[2]
 			if !regexValidCronLabel.MatchString(name) {
@@ -119,6 +156,20 @@ func (m Manifest) Validate() []error {
 					name,
 				))
 			}
+
+			// the schedule comes first, anything after it is the command
+			schedule, command, err := manifest.SplitSchedule(v)
+			if err != nil {
+				errors = append(errors, fmt.Errorf("Cron task %s has an invalid schedule (must be %s followed by a command)", name, manifest.ScheduleForms))
+				continue
+			}
+			if _, err := manifest.ScheduleExpression(schedule); err != nil {
+				errors = append(errors, fmt.Errorf("Cron task %s has an invalid schedule: %s", name, err))
+				continue
+			}
+			if command == "" && entry.Command.String == "" && len(entry.Command.Array) == 0 {
+				errors = append(errors, fmt.Errorf("Cron task %s has no command and %s does not declare one", name, entry.Name))
+			}
 		}
 
 		labels = entry.LabelsByPrefix("convox.health.interval")
@@ -185,6 +236,21 @@ func (m Manifest) Validate() []error {
 			errors = append(errors, e)
 		}
 
+		if entry.Logging.Driver == "json-file" {
+			if v, ok := entry.Logging.LogOptions["max-size"]; ok && !logMaxSizeRegex.MatchString(v) {
+				errors = append(errors, fmt.Errorf("%s service has invalid logging max-size %q: must be a number followed by k, m, or g", entry.Name, v))
+			}
+
+			if v, ok := entry.Logging.LogOptions["max-file"]; ok {
+				i, err := strconv.Atoi(v)
+				if err != nil || i < 1 {
+					errors = append(errors, fmt.Errorf("%s service has invalid logging max-file %q: must be a positive integer", entry.Name, v))
+				}
+			}
+		}
+
+		errors = append(errors, entry.duplicateContainerPorts()...)
+
 		// check that health check port is valid
 		if port, ok := entry.Labels["convox.health.port"]; ok {
 			pi, err := strconv.Atoi(port)
@@ -205,11 +271,85 @@ func (m Manifest) Validate() []error {
 				}
 			}
 		}
+
+		for _, name := range entry.NetworkNames() {
+			if _, ok := m.Networks[name]; !ok {
+				errors = append(errors, fmt.Errorf("%s joins network: %s which is not declared in networks", entry.Name, name))
+			}
+
+			if ip := entry.Networks[name].IPv4Address; ip != "" {
+				if pip := net.ParseIP(ip); pip == nil || pip.To4() == nil || strings.Contains(ip, ":") {
+					errors = append(errors, fmt.Errorf("%s service has invalid ipv4_address %q on network %s", entry.Name, ip, name))
+				}
+			}
+		}
+	}
+
+	if cycle := m.linkCycle(); cycle != nil {
+		errors = append(errors, fmt.Errorf("services have circular links: %s", strings.Join(cycle, " -> ")))
 	}
 
 	return errors
 }
 
+// linkCycle returns the services along a circular chain of links, starting and ending with the same service
+// services are walked in name order so the same cycle is always reported the same way
+func (m Manifest) linkCycle() []string {
+	names := []string{}
+
+	for name := range m.Services {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	done := map[string]bool{}
+	path := []string{}
+
+	var visit func(name string) []string
+
+	visit = func(name string) []string {
+		for i, p := range path {
+			if p == name {
+				return append(append([]string{}, path[i:]...), name)
+			}
+		}
+
+		if done[name] {
+			return nil
+		}
+
+		s, ok := m.Services[name]
+		if !ok {
+			return nil
+		}
+
+		path = append(path, name)
+
+		links := append([]string{}, s.Links...)
+		sort.Strings(links)
+
+		for _, l := range links {
+			if cycle := visit(l); cycle != nil {
+				return cycle
+			}
+		}
+
+		path = path[:len(path)-1]
+		done[name] = true
+
+		return nil
+	}
+
+	for _, name := range names {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
 // ExternalPorts returns a collection of ints of the Manifest's Services' external ports
 func (m *Manifest) ExternalPorts() []int {
 	ports := []int{}
@@ -425,6 +565,23 @@ func (m *Manifest) Raw() ([]byte, error) {
 	return yaml.Marshal(m)
 }
 
+// RawVersion renders the manifest in the format of a specific version
+// version 1 is a top-level map of services and version 2 wraps them with networks under services
+func (m *Manifest) RawVersion(v string) ([]byte, error) {
+	switch v {
+	case "1":
+		if len(m.Networks) > 0 {
+			return nil, fmt.Errorf("networks can not be rendered in a version 1 manifest")
+		}
+
+		return yaml.Marshal(m.Services)
+	case "2":
+		return yaml.Marshal(m)
+	default:
+		return nil, fmt.Errorf("unknown manifest version: %s", v)
+	}
+}
+
 func (m Manifest) EntryNames() []string {
 	names := make([]string, len(m.Services))
 	x := 0
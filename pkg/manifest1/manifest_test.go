@@ -10,6 +10,7 @@ import (
 
 	"gopkg.in/yaml.v2"
 
+	"github.com/convox/rack/pkg/manifest"
 	"github.com/convox/rack/pkg/manifest1"
 	"github.com/stretchr/testify/assert"
 )
@@ -453,7 +454,7 @@ func TestManifestNetworks(t *testing.T) {
 	m, err := manifestFixture("networks")
 	if assert.NoError(t, err) {
 		for _, s := range m.Services {
-			assert.Equal(t, s.Networks, manifest1.Networks{
+			assert.Equal(t, s.ExternalNetworks, manifest1.Networks{
 				"foo": manifest1.InternalNetwork{
 					"external": manifest1.ExternalNetwork{
 						Name: "foo",
@@ -555,6 +556,48 @@ func TestManifestMarshalYaml(t *testing.T) {
 	assert.Equal(t, m2.Services["food"].Command.Array, arrayCmd.Array)
 }
 
+func TestManifestRawVersion(t *testing.T) {
+	for _, fixture := range []string{"full-v1", "full-v2"} {
+		m, err := manifestFixture(fixture)
+		if !assert.NoError(t, err, fixture) {
+			continue
+		}
+
+		for _, v := range []string{"1", "2"} {
+			data, err := m.RawVersion(v)
+			if !assert.NoError(t, err, fixture) {
+				continue
+			}
+
+			m2, err := manifest1.Load(data)
+			if !assert.NoError(t, err, fixture) {
+				continue
+			}
+
+			assert.Equal(t, v, m2.Version, fixture)
+			assert.Equal(t, m.Services, m2.Services, fixture)
+		}
+	}
+
+	m, err := manifestFixture("networks")
+	if assert.NoError(t, err) {
+		_, err = m.RawVersion("1")
+		assert.EqualError(t, err, "networks can not be rendered in a version 1 manifest")
+
+		data, err := m.RawVersion("2")
+		if assert.NoError(t, err) {
+			m2, err := manifest1.Load(data)
+			if assert.NoError(t, err) {
+				assert.Equal(t, m.Networks, m2.Networks)
+				assert.Equal(t, m.Services, m2.Services)
+			}
+		}
+	}
+
+	_, err = (&manifest1.Manifest{}).RawVersion("3")
+	assert.EqualError(t, err, "unknown manifest version: 3")
+}
+
 func TestManifestValidate(t *testing.T) {
 	m, err := manifestFixture("invalid-cron")
 	if err != nil {
@@ -567,6 +610,29 @@ func TestManifestValidate(t *testing.T) {
 		assert.Equal(t, cerr[0].Error(), "Cron task my_job is not valid (cron names can contain only alphanumeric characters, dashes and must be between 4 and 30 characters)")
 	}
 
+	m, err = manifestFixture("invalid-cron-target")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	cterr := m.Validate()
+	if assert.Len(t, cterr, 2) {
+		assert.Equal(t, cterr[0].Error(), "worker has cron tasks but declares neither an image nor a build")
+		assert.Equal(t, cterr[1].Error(), "Cron task cleanup has no command and worker does not declare one")
+	}
+
+	m, err = manifestFixture("invalid-cron-schedule")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	serr := m.Validate()
+	if assert.Len(t, serr, 1) {
+		assert.Equal(t, serr[0].Error(), `Cron task cleanup has an invalid schedule: invalid rate unit "fortnight", must be one of minutes, hours or days`)
+	}
+
 	m, err = manifestFixture("invalid-link")
 	if err != nil {
 		t.Error(err.Error())
@@ -611,6 +677,17 @@ func TestManifestValidate(t *testing.T) {
 		assert.Equal(t, merrm[0].Error(), "web service has invalid mem_limit 2097152 bytes (2 MB): should be either 0, or at least 4MB")
 	}
 
+	m, err = manifestFixture("invalid-duplicate-container-port")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if errs := m.Validate(); assert.Len(t, errs, 2) {
+		assert.Equal(t, "web service maps container port 3000/tcp more than once, from ports 80 and 443", errs[0].Error())
+		assert.Equal(t, "web service maps container port 5000/udp more than once, from ports 5000 and 6000", errs[1].Error())
+	}
+
 	m, err = manifestFixture("invalid-health-check")
 	if err != nil {
 		t.Error(err.Error())
@@ -642,6 +719,56 @@ func TestManifestValidate(t *testing.T) {
 	if assert.NotNil(t, tuerr) {
 		assert.Equal(t, tuerr[0].Error(), "convox.health.threshold.unhealthy is invalid for web, must be a number between 2 and 10")
 	}
+
+	m, err = manifestFixture("invalid-logging-max-size")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if errs := m.Validate(); assert.NotNil(t, errs) {
+		assert.Equal(t, errs[0].Error(), `web service has invalid logging max-size "10mb": must be a number followed by k, m, or g`)
+	}
+
+	m, err = manifestFixture("invalid-logging-max-file")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if errs := m.Validate(); assert.NotNil(t, errs) {
+		assert.Equal(t, errs[0].Error(), `web service has invalid logging max-file "0": must be a positive integer`)
+	}
+
+	m, err = manifestFixture("invalid-link-cycle")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if errs := m.Validate(); assert.Len(t, errs, 1) {
+		assert.Equal(t, errs[0].Error(), "services have circular links: web -> worker -> web")
+	}
+
+	m, err = manifestFixture("invalid-link-cycle-three")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if errs := m.Validate(); assert.Len(t, errs, 1) {
+		assert.Equal(t, errs[0].Error(), "services have circular links: api -> database -> web -> api")
+	}
+}
+
+func TestManifestTimers(t *testing.T) {
+	m, err := manifestFixture("cron-timers")
+	if assert.NoError(t, err) {
+		assert.Equal(t, manifest.Timers{
+			{Name: "cleanup", Schedule: "rate: 5 minutes", Service: "worker"},
+			{Name: "report", Command: "bin/report --daily", Schedule: "0 0 * * ?", Service: "web"},
+		}, m.Timers())
+	}
 }
 
 func manifestFixture(name string) (*manifest1.Manifest, error) {
@@ -657,3 +784,41 @@ func randomString(size int) string {
 	}
 	return string(b)
 }
+
+func TestManifestServiceNetworks(t *testing.T) {
+	m, err := manifestFixture("service-networks")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Len(t, m.Validate(), 0)
+
+	assert.Equal(t, manifest1.ServiceNetworks{
+		"front": {Aliases: []string{"www", "api"}},
+		"back":  {IPv4Address: "10.5.0.10"},
+	}, m.Services["web"].Networks)
+
+	assert.Equal(t, manifest1.ServiceNetworks{"back": {}}, m.Services["worker"].Networks)
+
+	data, err := m.RawVersion("2")
+	if assert.NoError(t, err) {
+		m2, err := manifest1.Load(data)
+		if assert.NoError(t, err) {
+			assert.Equal(t, m.Services["web"].Networks, m2.Services["web"].Networks)
+			assert.Equal(t, m.Services["worker"].Networks, m2.Services["worker"].Networks)
+		}
+	}
+}
+
+func TestManifestServiceNetworksInvalid(t *testing.T) {
+	m, err := manifestFixture("invalid-service-networks")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errs := m.Validate()
+	if assert.Len(t, errs, 2) {
+		assert.EqualError(t, errs[0], `web service has invalid ipv4_address "10.5.0.300" on network back`)
+		assert.EqualError(t, errs[1], "web joins network: missing which is not declared in networks")
+	}
+}
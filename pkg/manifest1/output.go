@@ -2,7 +2,10 @@ package manifest1
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/fatih/color"
 )
@@ -24,6 +27,9 @@ type Stream chan string
 
 type Output struct {
 	colors   map[string]color.Attribute
+	console  io.Writer
+	files    map[string]*LogFile
+	filter   map[string]bool
 	lock     sync.Mutex
 	prefixes map[Stream]string
 	streams  map[string]Stream
@@ -33,6 +39,8 @@ type Output struct {
 func NewOutput(quiet bool) Output {
 	return Output{
 		colors:   make(map[string]color.Attribute),
+		console:  os.Stdout,
+		files:    make(map[string]*LogFile),
 		prefixes: make(map[Stream]string),
 		streams:  make(map[string]Stream),
 		quiet:    quiet,
@@ -60,6 +68,70 @@ func (o *Output) Stream(prefix string) Stream {
 	return s
 }
 
+// Filter limits the console to the given services, system output and log files are not affected
+func (o *Output) Filter(services []string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.filter = nil
+
+	if len(services) == 0 {
+		return
+	}
+
+	o.filter = map[string]bool{}
+
+	for _, s := range services {
+		o.filter[s] = true
+	}
+}
+
+// LogTo writes every line from services to <dir>/<service>.log in addition to the console
+func (o *Output) LogTo(dir string, services []string, max int64) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	for _, s := range services {
+		if _, ok := o.files[s]; ok {
+			continue
+		}
+
+		l, err := OpenLogFile(dir, s, max)
+		if err != nil {
+			return err
+		}
+
+		o.files[s] = l
+	}
+
+	return nil
+}
+
+// Replay prints previously logged lines to the console without writing them to the log files again
+func (o *Output) Replay(lines []LogLine) {
+	for _, l := range lines {
+		o.printLine(o.Stream(l.Service), l.Line, false)
+	}
+}
+
+// Close closes the log files, lines that arrive afterwards only go to the console
+func (o *Output) Close() error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	var err error
+
+	for s, l := range o.files {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+
+		delete(o.files, s)
+	}
+
+	return err
+}
+
 func (o *Output) paddedPrefix(s Stream) string {
 	color := color.New(o.colors[o.prefixes[s]]).Add(color.Bold)
 	color.EnableColor()
@@ -67,18 +139,32 @@ func (o *Output) paddedPrefix(s Stream) string {
 	return fmt.Sprintf(color.SprintfFunc()("%%-%ds │", o.widestPrefix()), o.prefixes[s])
 }
 
-func (o *Output) printLine(s Stream, line string) {
+func (o *Output) printLine(s Stream, line string, log bool) {
 	o.lock.Lock()
 	defer o.lock.Unlock()
 
-	if !o.quiet {
-		fmt.Printf("%s %s\n", o.paddedPrefix(s), line)
+	prefix := o.prefixes[s]
+
+	if l, ok := o.files[prefix]; ok && log {
+		if err := l.WriteLine(time.Now(), line); err != nil && !o.quiet {
+			fmt.Fprintf(o.console, "%s %s\n", o.paddedPrefix(o.streams["convox"]), err)
+		}
+	}
+
+	if o.quiet {
+		return
 	}
+
+	if _, static := StaticColors[prefix]; o.filter != nil && !static && !o.filter[prefix] {
+		return
+	}
+
+	fmt.Fprintf(o.console, "%s %s\n", o.paddedPrefix(s), line)
 }
 
 func (o *Output) watchStream(s Stream) {
 	for line := range s {
-		o.printLine(s, line)
+		o.printLine(s, line, true)
 	}
 }
 
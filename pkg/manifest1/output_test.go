@@ -0,0 +1,163 @@
+package manifest1_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/manifest1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type lockedBuffer struct {
+	buf  bytes.Buffer
+	lock sync.Mutex
+}
+
+func (b *lockedBuffer) Write(data []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.Write(data)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.String()
+}
+
+func TestLogFileRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	l, err := manifest1.OpenLogFile(dir, "web", 100)
+	require.NoError(t, err)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// each line is 40 bytes so the third one rotates
+	require.NoError(t, l.WriteLine(now, "line one"))
+	require.NoError(t, l.WriteLine(now, "line two"))
+	require.NoError(t, l.WriteLine(now, "line six"))
+	require.NoError(t, l.Close())
+
+	rotated, err := ioutil.ReadFile(filepath.Join(dir, "web.log.1"))
+	require.NoError(t, err)
+	assert.Equal(t, "2020-01-01T00:00:00.000000000Z line one\n2020-01-01T00:00:00.000000000Z line two\n", string(rotated))
+
+	current, err := ioutil.ReadFile(filepath.Join(dir, "web.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "2020-01-01T00:00:00.000000000Z line six\n", string(current))
+
+	require.EqualError(t, l.WriteLine(now, "closed"), "log closed: "+filepath.Join(dir, "web.log"))
+}
+
+func TestLogFileConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	l, err := manifest1.OpenLogFile(dir, "web", 1024)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				l.WriteLine(time.Now(), "concurrent line")
+			}
+		}()
+	}
+
+	wg.Wait()
+	require.NoError(t, l.Close())
+
+	lines, err := manifest1.ReplayLogs(dir, []string{"web"}, time.Time{})
+	require.NoError(t, err)
+	assert.True(t, len(lines) > 0)
+
+	for _, l := range lines {
+		assert.Equal(t, "concurrent line", l.Line)
+	}
+}
+
+func TestReplayLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	web, err := manifest1.OpenLogFile(dir, "web", 100)
+	require.NoError(t, err)
+	worker, err := manifest1.OpenLogFile(dir, "worker", 100)
+	require.NoError(t, err)
+
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, web.WriteLine(base, "web 0"))
+	require.NoError(t, worker.WriteLine(base.Add(1*time.Second), "worker 1"))
+	require.NoError(t, web.WriteLine(base.Add(2*time.Second), "web 2"))
+	require.NoError(t, web.WriteLine(base.Add(3*time.Second), "web 3"))
+	require.NoError(t, worker.WriteLine(base.Add(4*time.Second), "worker 4"))
+	require.NoError(t, web.Close())
+	require.NoError(t, worker.Close())
+
+	lines, err := manifest1.ReplayLogs(dir, []string{"web", "worker", "missing"}, base.Add(1*time.Second))
+	require.NoError(t, err)
+
+	replayed := []string{}
+
+	for _, l := range lines {
+		replayed = append(replayed, l.Service+": "+l.Line)
+	}
+
+	// web 2 was rotated into web.log.1 and is still replayed in order
+	assert.Equal(t, []string{"worker: worker 1", "web: web 2", "web: web 3", "worker: worker 4"}, replayed)
+}
+
+func TestOutputFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	console := &lockedBuffer{}
+
+	o := manifest1.NewOutput(false)
+	o.SetConsole(console)
+	o.Filter([]string{"web"})
+
+	require.NoError(t, o.LogTo(dir, []string{"web", "worker"}, 0))
+
+	web := o.Stream("web")
+	worker := o.Stream("worker")
+	system := o.Stream("convox")
+
+	web <- "web line"
+	worker <- "worker line"
+	system <- "system line"
+
+	// a stream only takes the next line once the previous one is written
+	web <- "done"
+	worker <- "done"
+	system <- "done"
+
+	require.NoError(t, o.Close())
+
+	out := console.String()
+	assert.Contains(t, out, "web line")
+	assert.Contains(t, out, "system line")
+	assert.NotContains(t, out, "worker line")
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "worker.log"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(strings.SplitN(string(data), "\n", 2)[0], " worker line"))
+
+	_, err = ioutil.ReadFile(filepath.Join(dir, "convox.log"))
+	assert.Error(t, err)
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -21,6 +22,14 @@ type Process struct {
 	service  Service
 }
 
+// localLogDrivers are the log drivers a local docker run can use without any remote setup
+var localLogDrivers = map[string]bool{
+	"journald":  true,
+	"json-file": true,
+	"local":     true,
+	"none":      true,
+}
+
 type ArgOptions struct {
 	Command     string
 	IgnorePorts bool
@@ -98,12 +107,29 @@ func (p *Process) GenerateArgs(opts *ArgOptions) ([]string, error) {
 		args = append(args, "--privileged")
 	}
 
-	for _, n := range p.service.Networks {
+	for _, n := range p.service.ExternalNetworks {
 		for _, in := range n {
 			args = append(args, "--net", in.Name)
 		}
 	}
 
+	// the network the container starts on takes its settings at run, the rest are joined by NetworkConnects
+	for _, name := range p.service.NetworkNames() {
+		if p.service.dockerNetwork(name) != p.service.NetworkName() {
+			continue
+		}
+
+		sn := p.service.Networks[name]
+
+		for _, a := range sn.Aliases {
+			args = append(args, "--network-alias", a)
+		}
+
+		if sn.IPv4Address != "" {
+			args = append(args, "--ip", sn.IPv4Address)
+		}
+	}
+
 	for _, link := range p.service.Links {
 		args = append(args, linkArgs(p.manifest.Services[link], fmt.Sprintf("%s-%s", p.app, link))...)
 	}
@@ -143,6 +169,28 @@ func (p *Process) GenerateArgs(opts *ArgOptions) ([]string, error) {
 		args = append(args, "-v", volume)
 	}
 
+	// remote drivers such as awslogs only apply to the ECS log configuration, locally docker keeps its default
+	if localLogDrivers[p.service.Logging.Driver] {
+		args = append(args, "--log-driver", p.service.Logging.Driver)
+
+		logOpts := []string{}
+
+		for k, v := range p.service.Logging.LogOptions {
+			// awslogs options only apply to the ECS log configuration
+			if strings.HasPrefix(k, "awslogs-") {
+				continue
+			}
+
+			logOpts = append(logOpts, fmt.Sprintf("%s=%s", k, v))
+		}
+
+		sort.Strings(logOpts)
+
+		for _, o := range logOpts {
+			args = append(args, "--log-opt", o)
+		}
+	}
+
 	if p.service.Cpu != 0 {
 		args = append(args, "--cpu-shares", strconv.FormatInt(p.service.Cpu, 10))
 	}
@@ -164,6 +212,36 @@ func (p *Process) GenerateArgs(opts *ArgOptions) ([]string, error) {
 	return args, nil
 }
 
+// NetworkConnects returns the docker network connect arguments that join the started container
+// to each network of its service other than the one it was started on
+func (p *Process) NetworkConnects() [][]string {
+	connects := [][]string{}
+
+	for _, name := range p.service.NetworkNames() {
+		network := p.service.dockerNetwork(name)
+
+		if network == p.service.NetworkName() {
+			continue
+		}
+
+		sn := p.service.Networks[name]
+
+		args := []string{"network", "connect"}
+
+		for _, a := range sn.Aliases {
+			args = append(args, "--alias", a)
+		}
+
+		if sn.IPv4Address != "" {
+			args = append(args, "--ip", sn.IPv4Address)
+		}
+
+		connects = append(connects, append(args, network, p.Name))
+	}
+
+	return connects
+}
+
 func (p *Process) Sync(local, remote string, ignores []string) (*sync.Sync, error) {
 	return sync.NewSync(p.Name, local, remote, ignores)
 }
@@ -123,3 +123,86 @@ func TestProcessStringArray(t *testing.T) {
 		assert.Equal(t, []string{"-i", "--rm", "--name", "api-foo", "api/foo", "ls", "-la"}, p.Args)
 	}
 }
+
+func TestProcessLogging(t *testing.T) {
+	s := manifest1.Service{
+		Name: "foo",
+		Logging: manifest1.Logging{
+			Driver: "json-file",
+			LogOptions: map[string]string{
+				"awslogs-multiline-pattern": "^INFO",
+				"max-file":                  "3",
+				"max-size":                  "10m",
+			},
+		},
+	}
+
+	m := manifest1.Manifest{
+		Services: map[string]manifest1.Service{
+			"foo": s,
+		},
+	}
+
+	p := manifest1.NewProcess("api", s, m)
+
+	if assert.NotNil(t, p) {
+		assert.Equal(t, []string{"-i", "--rm", "--name", "api-foo", "--log-driver", "json-file", "--log-opt", "max-file=3", "--log-opt", "max-size=10m", "api/foo"}, p.Args)
+	}
+}
+
+func TestProcessGenerateArgsAwslogs(t *testing.T) {
+	s := manifest1.Service{
+		Name: "foo",
+		Logging: manifest1.Logging{
+			Driver: "awslogs",
+			LogOptions: map[string]string{
+				"awslogs-group":             "convox-app",
+				"awslogs-multiline-pattern": "^INFO",
+			},
+		},
+	}
+
+	m := manifest1.Manifest{
+		Services: map[string]manifest1.Service{
+			"foo": s,
+		},
+	}
+
+	p := manifest1.NewProcess("api", s, m)
+
+	args, err := p.GenerateArgs(&manifest1.ArgOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-i", "--rm", "--name", "api-foo", "api/foo"}, args)
+}
+
+func TestProcessNetworks(t *testing.T) {
+	s := manifest1.Service{
+		Name: "foo",
+		ExternalNetworks: manifest1.Networks{
+			"front": manifest1.InternalNetwork{
+				"external": manifest1.ExternalNetwork{Name: "frontnet"},
+			},
+		},
+		Networks: manifest1.ServiceNetworks{
+			"front": {Aliases: []string{"www"}},
+			"back":  {Aliases: []string{"api", "internal"}, IPv4Address: "10.5.0.10"},
+			"logs":  {},
+		},
+	}
+
+	m := manifest1.Manifest{
+		Services: map[string]manifest1.Service{
+			"foo": s,
+		},
+	}
+
+	p := manifest1.NewProcess("api", s, m)
+
+	assert.Equal(t, []string{"-i", "--rm", "--name", "api-foo", "--net", "frontnet", "--network-alias", "www", "api/foo"}, p.Args)
+
+	assert.Equal(t, [][]string{
+		{"network", "connect", "--alias", "api", "--alias", "internal", "--ip", "10.5.0.10", "back", "api-foo"},
+		{"network", "connect", "logs", "api-foo"},
+	}, p.NetworkConnects())
+}
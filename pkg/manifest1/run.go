@@ -30,12 +30,17 @@ type Run struct {
 }
 
 type RunOptions struct {
-	Service string
-	Command []string
-	Build   bool
-	Cache   bool
-	Quiet   bool
-	Sync    bool
+	Service         string
+	Command         []string
+	Build           bool
+	Cache           bool
+	ContextWarnSize int64
+	Filter          []string
+	LogDir          string
+	LogMaxSize      int64
+	Quiet           bool
+	Since           time.Duration
+	Sync            bool
 }
 
 // NewRun Default constructor method for a Run object
@@ -125,6 +130,29 @@ func (r *Run) Start() error {
 		r.Output.Stream(s.Name)
 	}
 
+	r.Output.Filter(r.Opts.Filter)
+
+	if r.Opts.LogDir != "" {
+		names := []string{}
+
+		for _, s := range services {
+			names = append(names, s.Name)
+		}
+
+		if r.Opts.Since > 0 {
+			lines, err := ReplayLogs(r.Opts.LogDir, names, time.Now().Add(-r.Opts.Since))
+			if err != nil {
+				return err
+			}
+
+			r.Output.Replay(lines)
+		}
+
+		if err := r.Output.LogTo(r.Opts.LogDir, names, r.Opts.LogMaxSize); err != nil {
+			return err
+		}
+	}
+
 	r.done = make(chan error)
 
 	if r.Opts.Build {
@@ -139,9 +167,10 @@ func (r *Run) Start() error {
 		}
 
 		err = r.manifest.Build(r.Dir, r.App, r.Output.Stream("build"), BuildOptions{
-			Environment: env,
-			Cache:       r.Opts.Cache,
-			Service:     r.Opts.Service,
+			Environment:     env,
+			Cache:           r.Opts.Cache,
+			ContextWarnSize: r.Opts.ContextWarnSize,
+			Service:         r.Opts.Service,
 		})
 		if err != nil {
 			return err
@@ -245,6 +274,12 @@ func (r *Run) Start() error {
 			return err
 		}
 
+		for _, args := range p.NetworkConnects() {
+			if out, err := Docker(args...).CombinedOutput(); err != nil {
+				return fmt.Errorf("could not connect %s to network %s: %s", p.Name, args[len(args)-2], strings.TrimSpace(string(out)))
+			}
+		}
+
 		for _, proxy := range proxies {
 			r.proxies = append(r.proxies, proxy)
 			proxy.Start()
@@ -275,6 +310,8 @@ func (r *Run) Stop() {
 	}
 
 	Docker(args...).Run()
+
+	r.Output.Close()
 }
 
 func pruneSyncs(syncs []sync.Sync) ([]sync.Sync, error) {
@@ -319,7 +356,7 @@ func waitForContainer(container string, service Service) error {
 	i := 0
 
 	for {
-		host := containerHost(container, service.Networks)
+		host := containerHost(container, service.ExternalNetworks)
 		i += 1
 
 		if host != "" {
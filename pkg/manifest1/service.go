@@ -21,19 +21,20 @@ var ManifestRandomPorts = true
 type Service struct {
 	Name string `yaml:"-"`
 
-	Build       Build       `yaml:"build,omitempty"`
-	Command     Command     `yaml:"command,omitempty"`
-	Dockerfile  string      `yaml:"dockerfile,omitempty"`
-	Entrypoint  string      `yaml:"entrypoint,omitempty"`
-	Environment Environment `yaml:"environment,omitempty"`
-	ExtraHosts  []string    `yaml:"extra_hosts,omitempty"`
-	Image       string      `yaml:"image,omitempty"`
-	Labels      Labels      `yaml:"labels,omitempty"`
-	Links       []string    `yaml:"links,omitempty"`
-	Networks    Networks    `yaml:"-"`
-	Ports       Ports       `yaml:"ports,omitempty"`
-	Privileged  bool        `yaml:"privileged,omitempty"`
-	Volumes     []string    `yaml:"volumes,omitempty"`
+	Build       Build           `yaml:"build,omitempty"`
+	Command     Command         `yaml:"command,omitempty"`
+	Dockerfile  string          `yaml:"dockerfile,omitempty"`
+	Entrypoint  string          `yaml:"entrypoint,omitempty"`
+	Environment Environment     `yaml:"environment,omitempty"`
+	ExtraHosts  []string        `yaml:"extra_hosts,omitempty"`
+	Image       string          `yaml:"image,omitempty"`
+	Labels      Labels          `yaml:"labels,omitempty"`
+	Links       []string        `yaml:"links,omitempty"`
+	Logging     Logging         `yaml:"logging,omitempty"`
+	Networks    ServiceNetworks `yaml:"networks,omitempty"`
+	Ports       Ports           `yaml:"ports,omitempty"`
+	Privileged  bool            `yaml:"privileged,omitempty"`
+	Volumes     []string        `yaml:"volumes,omitempty"`
 
 	Cpu    int64  `yaml:"cpu_shares,omitempty"`
 	Memory Memory `yaml:"mem_limit,omitempty"`
@@ -42,6 +43,9 @@ type Service struct {
 	Exports  map[string]string        `yaml:"-"`
 	LinkVars map[string]template.HTML `yaml:"-"`
 
+	// ExternalNetworks are the top level networks of the manifest, denormalized on load
+	ExternalNetworks Networks `yaml:"-"`
+
 	Primary bool `yaml:"-"`
 
 	randoms map[string]int
@@ -74,6 +78,12 @@ type EnvironmentItem struct {
 type Environment []EnvironmentItem
 
 type Labels map[string]string
+
+// Logging configures the log driver for a service
+type Logging struct {
+	Driver     string            `yaml:"driver,omitempty"`
+	LogOptions map[string]string `yaml:"options,omitempty"`
+}
 type Memory int64
 type Networks map[string]InternalNetwork
 
@@ -84,6 +94,15 @@ type Network struct {
 	Name string
 }
 
+// ServiceNetworks are the named networks a service joins, see yaml.go for the list form
+type ServiceNetworks map[string]ServiceNetwork
+
+// ServiceNetwork configures how a service appears on a network it joins
+type ServiceNetwork struct {
+	Aliases     []string `yaml:"aliases,omitempty"`
+	IPv4Address string   `yaml:"ipv4_address,omitempty"`
+}
+
 // Hash returns a string suitable for using as a map key
 func (b *Build) Hash() string {
 	argKeys := []string{}
@@ -135,6 +154,19 @@ func (s Service) IsAgent() bool {
 	return okAgent || okDaemon
 }
 
+// AwslogsOptions returns the log options that can be passed through to the awslogs driver on ECS
+func (s Service) AwslogsOptions() map[string]string {
+	opts := map[string]string{}
+
+	for _, k := range []string{"awslogs-datetime-format", "awslogs-multiline-pattern"} {
+		if v, ok := s.Logging.LogOptions[k]; ok {
+			opts[k] = v
+		}
+	}
+
+	return opts
+}
+
 // DefaultParams returns a string of comma-delimited Count, CPU, and Memory params
 func (s Service) DefaultParams() string {
 	count := 1
@@ -322,7 +354,7 @@ func (s *Service) NetworkName() string {
 	// No custom docker network by default
 	networkName := ""
 
-	for _, n := range s.Networks {
+	for _, n := range s.ExternalNetworks {
 		for _, in := range n {
 			networkName = in.Name
 		}
@@ -330,6 +362,30 @@ func (s *Service) NetworkName() string {
 	return networkName
 }
 
+// NetworkNames returns the names of the networks the service joins in sorted order
+func (s *Service) NetworkNames() []string {
+	names := []string{}
+
+	for name := range s.Networks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// dockerNetwork returns the docker network behind a manifest network, its external name when it has one
+func (s *Service) dockerNetwork(name string) string {
+	for _, en := range s.ExternalNetworks[name] {
+		if en.Name != "" {
+			return en.Name
+		}
+	}
+
+	return name
+}
+
 func containerEnv(container string) map[string]string {
 	es := []string{}
 
@@ -387,7 +443,7 @@ func linkArgs(s Service, container string) []string {
 	env := containerEnv(container)
 
 	scheme := coalesce(env["LINK_SCHEME"], "tcp")
-	host := containerHost(container, s.Networks)
+	host := containerHost(container, s.ExternalNetworks)
 	port := coalesce(env["LINK_PORT"], containerPort(container))
 	path := env["LINK_PATH"]
 	username := env["LINK_USERNAME"]
@@ -506,6 +562,26 @@ func (s Service) ContainerPorts() []string {
 	return ext
 }
 
+// duplicateContainerPorts returns an error for each container port the service maps more than once over the same protocol
+// ecs rejects a task definition that does
+func (s Service) duplicateContainerPorts() []error {
+	errs := []error{}
+	seen := map[string]Port{}
+
+	for _, port := range s.Ports {
+		key := fmt.Sprintf("%d/%s", port.Container, port.Protocol)
+
+		if first, ok := seen[key]; ok {
+			errs = append(errs, fmt.Errorf("%s service maps container port %s more than once, from ports %d and %d", s.Name, key, first.Balancer, port.Balancer))
+			continue
+		}
+
+		seen[key] = port
+	}
+
+	return errs
+}
+
 func (s Service) ParamName(name string) string {
 	return fmt.Sprintf("%s%s", UpperName(s.Name), name)
 }
@@ -518,8 +594,8 @@ func (s Service) RegistryImage(appName, buildId string, outputs map[string]strin
 	return fmt.Sprintf("%s/%s-%s:%s", os.Getenv("REGISTRY_HOST"), appName, s.Name, buildId)
 }
 
-//ExtraHostsMap is a convenience method to allow for easier use of the hosts in
-//AWS templates
+// ExtraHostsMap is a convenience method to allow for easier use of the hosts in
+// AWS templates
 func (s Service) ExtraHostsMap() map[string]string {
 	res := map[string]string{}
 	for _, str := range s.ExtraHosts {
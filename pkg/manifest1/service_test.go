@@ -53,7 +53,7 @@ func TestNetworkName(t *testing.T) {
 	}
 
 	s := manifest1.Service{
-		Networks: networks,
+		ExternalNetworks: networks,
 	}
 
 	assert.Equal(t, s.NetworkName(), "foonet")
@@ -63,7 +63,7 @@ func TestDefaultNetworkName(t *testing.T) {
 	networks := manifest1.Networks{}
 
 	s := manifest1.Service{
-		Networks: networks,
+		ExternalNetworks: networks,
 	}
 
 	assert.Equal(t, s.NetworkName(), "")
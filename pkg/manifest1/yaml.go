@@ -322,6 +322,31 @@ func (m *Memory) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// UnmarshalYAML accepts networks as either a list of names or a map of names to settings
+func (sn *ServiceNetworks) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var names []string
+
+	if err := unmarshal(&names); err == nil {
+		*sn = ServiceNetworks{}
+
+		for _, n := range names {
+			(*sn)[n] = ServiceNetwork{}
+		}
+
+		return nil
+	}
+
+	var networks map[string]ServiceNetwork
+
+	if err := unmarshal(&networks); err != nil {
+		return fmt.Errorf("could not parse networks: %s", err)
+	}
+
+	*sn = ServiceNetworks(networks)
+
+	return nil
+}
+
 func (pp *Ports) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var v []string
 
@@ -5,21 +5,28 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/convox/rack/pkg/helpers"
+	"github.com/convox/rack/pkg/manifest"
 	"github.com/convox/rack/pkg/manifest1"
 	"github.com/pkg/errors"
 )
 
 type Options1 struct {
-	App      string
-	Build    bool
-	Cache    bool
-	Command  []string
-	Manifest string
-	Service  string
-	Shift    int
-	Sync     bool
+	App             string
+	Build           bool
+	Cache           bool
+	Command         []string
+	ContextWarnSize int64
+	Filter          []string
+	LogDir          string
+	Manifest        string
+	Service         string
+	Shift           int
+	Since           time.Duration
+	Sync            bool
+	Timers          bool
 }
 
 func (s *Start) Start1(ctx context.Context, opts Options1) error {
@@ -66,11 +73,15 @@ func (s *Start) Start1(ctx context.Context, opts Options1) error {
 	}
 
 	r := m.Run(filepath.Dir(opts.Manifest), opts.App, manifest1.RunOptions{
-		Build:   opts.Build,
-		Cache:   opts.Cache,
-		Command: opts.Command,
-		Service: opts.Service,
-		Sync:    opts.Sync,
+		Build:           opts.Build,
+		Cache:           opts.Cache,
+		Command:         opts.Command,
+		ContextWarnSize: opts.ContextWarnSize,
+		Filter:          opts.Filter,
+		LogDir:          opts.LogDir,
+		Service:         opts.Service,
+		Since:           opts.Since,
+		Sync:            opts.Sync,
 	})
 
 	defer r.Stop()
@@ -79,5 +90,61 @@ func (s *Start) Start1(ctx context.Context, opts Options1) error {
 		return errors.WithStack(err)
 	}
 
+	if opts.Timers {
+		timers := manifest.Timers{}
+
+		// only services started by this run have a container to run in
+		for _, t := range m.Timers() {
+			if _, ok := r.Processes[processName1(opts.App, t.Service)]; ok {
+				timers = append(timers, t)
+			}
+		}
+
+		errch := make(chan error)
+
+		go handleErrors1(ctx, r.Output.Stream("convox"), errch)
+
+		scheduleTimers(ctx, timers, runTimer1(&r, m), errch)
+	}
+
 	return r.Wait(ctx)
 }
+
+// runTimer1 runs a timer in the running container of its service
+// a cron label without a command runs the command of the service
+func runTimer1(r *manifest1.Run, m *manifest1.Manifest) timerRunner {
+	return func(ctx context.Context, t manifest.Timer) error {
+		command := t.Command
+
+		if command == "" {
+			s, err := m.Service(t.Service)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			command = helpers.CoalesceString(s.Command.String, strings.Join(s.Command.Array, " "))
+		}
+
+		out := r.Output.Stream(timerPrefix(t.Name))
+
+		out <- fmt.Sprintf("running on %s", t.Service)
+
+		return manifest1.DefaultRunner.Run(out, manifest1.Docker("exec", processName1(r.App, t.Service), "sh", "-c", command), manifest1.RunnerOptions{})
+	}
+}
+
+func handleErrors1(ctx context.Context, system manifest1.Stream, errch chan error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-errch:
+			system <- fmt.Sprintf("error: %s", err)
+		}
+	}
+}
+
+// processName1 matches the container name manifest1 gives the process of a service
+func processName1(app, service string) string {
+	return fmt.Sprintf("%s-%s", app, service)
+}
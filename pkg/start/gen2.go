@@ -49,6 +49,7 @@ type Options2 struct {
 	Services []string
 	Sync     bool
 	Test     bool
+	Timers   bool
 }
 
 type buildSource struct {
@@ -105,7 +106,17 @@ func (s *Start) Start2(ctx context.Context, w io.Writer, opts Options2) error {
 		}
 	}
 
-	pw := prefixWriter(w, services)
+	timers := manifest.Timers{}
+
+	if opts.Timers {
+		for _, t := range m.Timers {
+			if services[t.Service] {
+				timers = append(timers, t)
+			}
+		}
+	}
+
+	pw := prefixWriter(w, services, timers)
 
 	if opts.Build {
 		pw.Writef("build", "uploading source\n")
@@ -198,6 +209,8 @@ func (s *Start) Start2(ctx context.Context, w io.Writer, opts Options2) error {
 		return err
 	}
 
+	scheduleTimers(ctx, timers, opts.runTimer(&pw), errch)
+
 	<-ctx.Done()
 
 	a, err = opts.Provider.AppGet(opts.App)
@@ -375,6 +388,38 @@ func (opts Options2) healthCheck(ctx context.Context, pw prefix.Writer, s manife
 	}
 }
 
+// runTimer runs a timer as a one-off process of its service and streams its logs until it exits
+func (opts Options2) runTimer(pw *prefix.Writer) timerRunner {
+	return func(ctx context.Context, t manifest.Timer) error {
+		ropts := structs.ProcessRunOptions{}
+
+		if t.Command != "" {
+			ropts.Command = options.String(t.Command)
+		}
+
+		if t.Timezone != "" {
+			ropts.Environment = map[string]string{"TZ": t.Timezone}
+		}
+
+		pw.Writef(timerPrefix(t.Name), "running on <service>%s</service>\n", t.Service)
+
+		ps, err := opts.Provider.ProcessRun(opts.App, t.Service, ropts)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		logs, err := opts.Provider.ProcessLogs(opts.App, ps.Id, structs.LogsOptions{Follow: options.Bool(true)})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer logs.Close()
+
+		pw.Write(timerPrefix(t.Name), logs)
+
+		return nil
+	}
+}
+
 func (opts Options2) stopProcess(pid string, wg *sync.WaitGroup) {
 	defer wg.Done()
 	opts.Provider.ProcessStop(opts.App, pid)
@@ -148,3 +148,90 @@ func TestStart2Options(t *testing.T) {
 	p.AssertExpectations(t)
 	e.AssertExpectations(t)
 }
+
+func TestStart2Timers(t *testing.T) {
+	helpers.ProviderWaitDuration = 1
+
+	clock := &fastForwardClock{now: time.Date(2021, 1, 1, 0, 3, 0, 0, time.UTC), fires: 2}
+
+	tc := start.TimerClock
+	start.TimerClock = clock
+	defer func() { start.TimerClock = tc }()
+
+	p := &structs.MockProvider{}
+
+	p.On("AppGet", "app1").Return(&structs.App{Name: "app1", Generation: "2", Status: "running"}, nil)
+	p.On("ReleaseList", "app1", structs.ReleaseListOptions{Limit: options.Int(1)}).Return(structs.Releases{{Id: "release1"}}, nil)
+	p.On("ReleaseGet", "app1", "release1").Return(&structs.Release{}, nil)
+	p.On("AppLogs", "app1", structs.LogsOptions{Prefix: options.Bool(true), Since: options.Duration(1 * time.Second)}).Return(ioutil.NopCloser(strings.NewReader("")), nil)
+	p.On("ProcessRun", "app1", "web", structs.ProcessRunOptions{Command: options.String("bin/cleanup")}).Return(&structs.Process{Id: "pid1"}, nil).Twice()
+	p.On("ProcessLogs", "app1", "pid1", structs.LogsOptions{Follow: options.Bool(true)}).Return(ioutil.NopCloser(strings.NewReader("cleaned\n")), nil).Once()
+	p.On("ProcessLogs", "app1", "pid1", structs.LogsOptions{Follow: options.Bool(true)}).Return(ioutil.NopCloser(strings.NewReader("cleaned\n")), nil).Once()
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	os.Chdir("testdata/timers")
+	defer os.Chdir(cwd)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	buf := bytes.Buffer{}
+
+	opts := start.Options2{
+		App:      "app1",
+		Provider: p,
+		Test:     true,
+		Timers:   true,
+	}
+
+	err = start.New().Start2(ctx, &buf, opts)
+	require.NoError(t, err)
+
+	require.Equal(t,
+		[]string{
+			"<color3>timer/cleanup</color3> | running on <service>web</service>",
+			"<color3>timer/cleanup</color3> | cleaned",
+			"<color3>timer/cleanup</color3> | running on <service>web</service>",
+			"<color3>timer/cleanup</color3> | cleaned",
+			"<system>convox       </system> | stopping",
+		},
+		strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"),
+	)
+
+	require.Equal(t, time.Date(2021, 1, 1, 0, 10, 0, 0, time.UTC), clock.Now())
+
+	p.AssertExpectations(t)
+}
+
+func TestStart2TimersOff(t *testing.T) {
+	helpers.ProviderWaitDuration = 1
+
+	tc := start.TimerClock
+	start.TimerClock = &fastForwardClock{now: time.Date(2021, 1, 1, 0, 3, 0, 0, time.UTC), fires: 2}
+	defer func() { start.TimerClock = tc }()
+
+	p := &structs.MockProvider{}
+
+	p.On("AppGet", "app1").Return(&structs.App{Name: "app1", Generation: "2", Status: "running"}, nil)
+	p.On("ReleaseList", "app1", structs.ReleaseListOptions{Limit: options.Int(1)}).Return(structs.Releases{{Id: "release1"}}, nil)
+	p.On("ReleaseGet", "app1", "release1").Return(&structs.Release{}, nil)
+	p.On("AppLogs", "app1", structs.LogsOptions{Prefix: options.Bool(true), Since: options.Duration(1 * time.Second)}).Return(ioutil.NopCloser(strings.NewReader("")), nil)
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	os.Chdir("testdata/timers")
+	defer os.Chdir(cwd)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	buf := bytes.Buffer{}
+
+	err = start.New().Start2(ctx, &buf, start.Options2{App: "app1", Provider: p, Test: true})
+	require.NoError(t, err)
+
+	require.Equal(t, "<system>convox</system> | stopping\n", buf.String())
+
+	p.AssertExpectations(t)
+}
@@ -8,6 +8,7 @@ import (
 	"os/signal"
 
 	"github.com/convox/exec"
+	"github.com/convox/rack/pkg/manifest"
 	"github.com/convox/rack/pkg/prefix"
 )
 
@@ -36,7 +37,7 @@ func prefixHash(prefix string) int {
 	return sum % 18
 }
 
-func prefixWriter(w io.Writer, services map[string]bool) prefix.Writer {
+func prefixWriter(w io.Writer, services map[string]bool, timers manifest.Timers) prefix.Writer {
 	prefixes := map[string]string{
 		"build":  "system",
 		"convox": "system",
@@ -46,6 +47,11 @@ func prefixWriter(w io.Writer, services map[string]bool) prefix.Writer {
 		prefixes[s] = fmt.Sprintf("color%d", prefixHash(s))
 	}
 
+	// timer output shares the color of the service it runs on
+	for _, t := range timers {
+		prefixes[timerPrefix(t.Name)] = fmt.Sprintf("color%d", prefixHash(t.Service))
+	}
+
 	return prefix.NewWriter(w, prefixes)
 }
 
@@ -3,6 +3,8 @@ package start_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"time"
 )
 
 type MockHealthCheck struct {
@@ -25,3 +27,32 @@ func mockHealthCheck(fn func(n int) int) *MockHealthCheck {
 	m.Server = httptest.NewTLSServer(m)
 	return m
 }
+
+// fastForwardClock jumps to each fire instead of waiting for it, up to a number of fires
+type fastForwardClock struct {
+	lock  sync.Mutex
+	now   time.Time
+	fires int
+}
+
+func (c *fastForwardClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ch := make(chan time.Time, 1)
+
+	if c.fires > 0 {
+		c.fires--
+		c.now = c.now.Add(d)
+		ch <- c.now
+	}
+
+	return ch
+}
+
+func (c *fastForwardClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
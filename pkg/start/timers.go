@@ -0,0 +1,74 @@
+package start
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/convox/rack/pkg/manifest"
+)
+
+// Clock is the source of time for the local timer scheduler
+type Clock interface {
+	After(d time.Duration) <-chan time.Time
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+var (
+	// TimerClock can be replaced by tests to fast forward the scheduler to each fire
+	TimerClock Clock = systemClock{}
+)
+
+// timerRunner runs the command of a timer in a container of its service and returns when it exits
+type timerRunner func(ctx context.Context, t manifest.Timer) error
+
+// timerPrefix is the output prefix of a timer
+func timerPrefix(name string) string {
+	return fmt.Sprintf("timer/%s", name)
+}
+
+// scheduleTimers fires each timer until the context is done
+func scheduleTimers(ctx context.Context, ts manifest.Timers, run timerRunner, errch chan error) {
+	for _, t := range ts {
+		go scheduleTimer(ctx, t, run, errch)
+	}
+}
+
+// scheduleTimer evaluates the schedule in utc like cloudwatch events does
+// a fire that comes due while the previous run is still going waits for it to finish
+func scheduleTimer(ctx context.Context, t manifest.Timer, run timerRunner, errch chan error) {
+	for {
+		now := TimerClock.Now().UTC()
+
+		next, err := t.Next(now)
+		if err != nil {
+			errch <- fmt.Errorf("timer %s: %s", t.Name, err)
+			return
+		}
+
+		if next.IsZero() {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-TimerClock.After(next.Sub(now)):
+		}
+
+		// a run cut short by stopping is not an error
+		if err := run(ctx, t); err != nil && ctx.Err() == nil {
+			errch <- fmt.Errorf("timer %s: %s", t.Name, err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package structs
+
+import "context"
+
+type actorKey struct{}
+
+// ActorContext records who is making the provider calls made with ctx
+func ActorContext(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ContextActor returns the actor recorded in ctx, calls the rack makes on its own have none
+func ContextActor(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	actor, _ := ctx.Value(actorKey{}).(string)
+
+	return actor
+}
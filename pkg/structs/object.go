@@ -5,5 +5,14 @@ type Object struct {
 }
 
 type ObjectStoreOptions struct {
-	Presign *bool
+	CacheControl       *string `query:"cache-control"`
+	ContentDisposition *string `query:"content-disposition"`
+	ContentType        *string `query:"content-type"`
+	Prefix             *string `query:"prefix"`
+	Presign            *bool
+}
+
+type PresignedPost struct {
+	Url    string
+	Fields map[string]string
 }
@@ -27,12 +27,14 @@ type ReleaseListOptions struct {
 }
 
 type ReleasePromoteOptions struct {
-	Development *bool `param:"development"`
-	Force       *bool `param:"force"`
-	Idle        *bool `param:"idle"`
-	Min         *int  `param:"min"`
-	Max         *int  `param:"max"`
-	Timeout     *int  `param:"timeout"`
+	Development    *bool `param:"development"`
+	Force          *bool `param:"force"`
+	Idle           *bool `param:"idle"`
+	IgnoreCapacity *bool `param:"ignore-capacity"`
+	Min            *int  `param:"min"`
+	Max            *int  `param:"max"`
+	Reconcile      *bool `param:"reconcile"`
+	Timeout        *int  `param:"timeout"`
 }
 
 func NewRelease(app string) *Release {
@@ -11,6 +11,18 @@ type Service struct {
 
 type Services []Service
 
+// ServiceCapacity is the number of tasks a service wants and how many of them are running or starting
+type ServiceCapacity struct {
+	Desired int `json:"desired"`
+	Pending int `json:"pending"`
+	Running int `json:"running"`
+}
+
+// Missing is the number of desired tasks that are not running, negative when there are more running than desired
+func (c ServiceCapacity) Missing() int {
+	return c.Desired - c.Running
+}
+
 type ServicePort struct {
 	Balancer    int    `json:"balancer"`
 	Certificate string `json:"certificate"`
@@ -0,0 +1,140 @@
+package aws
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// SetDeploymentAlarms gates deployments of a service on cloudwatch alarms, an empty list removes the gate
+// When rollbackOnAlarm is true a deployment that sets off an alarm is rolled back. The alarms are set on the
+// ecs service directly so a later stack update that changes its deployment configuration can clear them
+func (p *Provider) SetDeploymentAlarms(app, service string, alarmNames []string, rollbackOnAlarm bool) error {
+	if err := p.alarmsExist(alarmNames); err != nil {
+		return err
+	}
+
+	arn, err := p.serviceEcsArn(app, service)
+	if err != nil {
+		return err
+	}
+
+	d, err := p.serviceDeployment(arn)
+	if err != nil {
+		return err
+	}
+
+	enable := len(alarmNames) > 0
+
+	dc := &ecsDeploymentConfiguration{
+		Alarms: &ecsDeploymentAlarms{
+			AlarmNames: aws.StringSlice(alarmNames),
+			Enable:     aws.Bool(enable),
+			Rollback:   aws.Bool(enable && rollbackOnAlarm),
+		},
+	}
+
+	// the percentages are sent back unchanged so that only the alarms change
+	if d.DeploymentConfiguration != nil {
+		dc.MaximumPercent = d.DeploymentConfiguration.MaximumPercent
+		dc.MinimumHealthyPercent = d.DeploymentConfiguration.MinimumHealthyPercent
+	}
+
+	op := &request.Operation{Name: "UpdateService", HTTPMethod: "POST", HTTPPath: "/"}
+
+	req := p.ecs().NewRequest(op, &ecsUpdateServiceInput{
+		Cluster:                 aws.String(p.Cluster),
+		DeploymentConfiguration: dc,
+		Service:                 aws.String(arn),
+	}, &ecs.UpdateServiceOutput{})
+
+	if err := req.Send(); err != nil {
+		return err
+	}
+
+	p.auditRecord("service.alarms", app, p.actor(), map[string]string{"service": service, "alarms": strings.Join(alarmNames, ","), "rollback": strconv.FormatBool(enable && rollbackOnAlarm)})
+
+	return nil
+}
+
+// GetDeploymentAlarms returns the alarms that gate deployments of a service and whether a deployment that sets one off is rolled back
+func (p *Provider) GetDeploymentAlarms(app, service string) ([]string, bool, error) {
+	arn, err := p.serviceEcsArn(app, service)
+	if err != nil {
+		return nil, false, err
+	}
+
+	d, err := p.serviceDeployment(arn)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if d.DeploymentConfiguration == nil || d.DeploymentConfiguration.Alarms == nil || !aws.BoolValue(d.DeploymentConfiguration.Alarms.Enable) {
+		return []string{}, false, nil
+	}
+
+	a := d.DeploymentConfiguration.Alarms
+
+	return aws.StringValueSlice(a.AlarmNames), aws.BoolValue(a.Rollback), nil
+}
+
+// alarmsExist returns an error naming the first alarm that does not exist
+func (p *Provider) alarmsExist(names []string) error {
+	found := map[string]bool{}
+
+	// DescribeAlarms takes at most 100 names at a time
+	for i := 0; i < len(names); i += 100 {
+		j := i + 100
+
+		if j > len(names) {
+			j = len(names)
+		}
+
+		err := p.cloudwatch().DescribeAlarmsPages(&cloudwatch.DescribeAlarmsInput{
+			AlarmNames: aws.StringSlice(names[i:j]),
+		}, func(res *cloudwatch.DescribeAlarmsOutput, last bool) bool {
+			for _, a := range res.MetricAlarms {
+				found[aws.StringValue(a.AlarmName)] = true
+			}
+
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, n := range names {
+		if !found[n] {
+			return errorNotFound(fmt.Sprintf("alarm not found: %s", n))
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) serviceDeployment(arn string) (*ecsService, error) {
+	res := &ecsDescribeServicesOutput{}
+
+	op := &request.Operation{Name: "DescribeServices", HTTPMethod: "POST", HTTPPath: "/"}
+
+	req := p.ecs().NewRequest(op, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(p.Cluster),
+		Services: []*string{aws.String(arn)},
+	}, res)
+
+	if err := req.Send(); err != nil {
+		return nil, err
+	}
+
+	if len(res.Services) != 1 {
+		return nil, errorNotFound(fmt.Sprintf("service not found: %s", arn))
+	}
+
+	return res.Services[0], nil
+}
@@ -0,0 +1,61 @@
+package aws_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeploymentAlarms(t *testing.T) {
+	p := ecsServiceTestProvider(t)
+	defer p.Close()
+
+	p.Fake.CloudWatch.AddAlarm("httpd-5xx")
+	p.Fake.CloudWatch.AddAlarm("httpd-latency")
+
+	names, rollback, err := p.GetDeploymentAlarms("httpd", "web")
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, names)
+	assert.False(t, rollback)
+
+	require.NoError(t, p.SetDeploymentAlarms("httpd", "web", []string{"httpd-5xx", "httpd-latency"}, true))
+
+	names, rollback, err = p.GetDeploymentAlarms("httpd", "web")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"httpd-5xx", "httpd-latency"}, names)
+	assert.True(t, rollback)
+
+	// only the alarms change
+	res, err := ecs.New(session.New(), p.Fake.Config()).DescribeServices(&ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster-test"),
+		Services: []*string{aws.String("convox-httpd-ServiceWeb")},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Services, 1)
+	assert.Equal(t, int64(200), aws.Int64Value(res.Services[0].DeploymentConfiguration.MaximumPercent))
+	assert.Equal(t, int64(50), aws.Int64Value(res.Services[0].DeploymentConfiguration.MinimumHealthyPercent))
+
+	require.NoError(t, p.SetDeploymentAlarms("httpd", "web", []string{}, true))
+
+	names, rollback, err = p.GetDeploymentAlarms("httpd", "web")
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, names)
+	assert.False(t, rollback)
+}
+
+func TestDeploymentAlarmsUnknownAlarm(t *testing.T) {
+	p := ecsServiceTestProvider(t)
+	defer p.Close()
+
+	p.Fake.CloudWatch.AddAlarm("httpd-5xx")
+
+	err := p.SetDeploymentAlarms("httpd", "web", []string{"httpd-5xx", "httpd-missing"}, false)
+	require.EqualError(t, err, "alarm not found: httpd-missing")
+
+	// the service is never updated
+	assert.Nil(t, p.Fake.ECS.DeploymentAlarms("cluster-test", "convox-httpd-ServiceWeb"))
+}
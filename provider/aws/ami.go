@@ -0,0 +1,92 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/convox/rack/pkg/manifest"
+)
+
+const (
+	BottlerocketVariantECS    = "aws-ecs-1"
+	BottlerocketVariantNvidia = "aws-ecs-1-nvidia"
+
+	// ecsAMIParameter is the ssm parameter aws publishes the recommended ecs optimized amazon linux 2 image under
+	ecsAMIParameter = "/aws/service/ecs/optimized-ami/amazon-linux-2/recommended/image_id"
+)
+
+// BottlerocketVariants are the bottlerocket variants that run ecs agents
+var BottlerocketVariants = []string{BottlerocketVariantECS, BottlerocketVariantNvidia}
+
+// LatestECSAMI returns the id of the current ecs optimized image for the rack region
+// racks that use bottlerocket get the image of its standard ecs variant instead
+func (p *Provider) LatestECSAMI() (string, error) {
+	if p.UseBottlerocket {
+		return p.LatestBottlerocketAMI(BottlerocketVariantECS)
+	}
+
+	log := Logger.At("LatestECSAMI").Start()
+
+	ami, err := p.ssmParameterValue(ecsAMIParameter)
+	if err != nil {
+		return "", log.Error(err)
+	}
+
+	return ami, log.Successf("ami=%s", ami)
+}
+
+// LatestBottlerocketAMI returns the id of the current x86_64 bottlerocket image of variant for the rack region
+func (p *Provider) LatestBottlerocketAMI(variant string) (string, error) {
+	log := Logger.At("LatestBottlerocketAMI").Namespace("variant=%s", variant).Start()
+
+	if !containsString(BottlerocketVariants, variant) {
+		return "", log.Error(fmt.Errorf("bottlerocket variant %s invalid, must be one of %s", variant, strings.Join(BottlerocketVariants, ", ")))
+	}
+
+	ami, err := p.ssmParameterValue(bottlerocketParameter(variant))
+	if err != nil {
+		return "", log.Error(err)
+	}
+
+	return ami, log.Successf("ami=%s", ami)
+}
+
+// bottlerocketParameter returns the ssm parameter aws publishes the latest image of a bottlerocket variant under
+func bottlerocketParameter(variant string) string {
+	return fmt.Sprintf("/aws/service/bottlerocket/%s/x86_64/latest/image_id", variant)
+}
+
+// validateBottlerocketVariant returns an error if variant is unknown or is the nvidia variant
+// while no service in the manifest asks for a gpu
+func validateBottlerocketVariant(variant string, m *manifest.Manifest) error {
+	if !containsString(BottlerocketVariants, variant) {
+		return fmt.Errorf("bottlerocket variant %s invalid, must be one of %s", variant, strings.Join(BottlerocketVariants, ", "))
+	}
+
+	if variant != BottlerocketVariantNvidia {
+		return nil
+	}
+
+	for _, s := range m.Services {
+		if s.Scale.Gpu > 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("bottlerocket variant %s requires a service with scale gpu set", variant)
+}
+
+func (p *Provider) ssmParameterValue(name string) (string, error) {
+	res, err := p.ssm().GetParameter(&ssm.GetParameterInput{Name: aws.String(name)})
+	if err != nil {
+		return "", err
+	}
+
+	if res.Parameter == nil || aws.StringValue(res.Parameter.Value) == "" {
+		return "", fmt.Errorf("parameter has no value: %s", name)
+	}
+
+	return aws.StringValue(res.Parameter.Value), nil
+}
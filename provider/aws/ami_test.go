@@ -0,0 +1,75 @@
+package aws_test
+
+import (
+	"testing"
+
+	"github.com/convox/rack/pkg/manifest"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestBottlerocketAMI(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.Fake.SSM.SetParameter("/aws/service/bottlerocket/aws-ecs-1/x86_64/latest/image_id", "ami-0bottlerocket00001")
+	provider.Fake.SSM.SetParameter("/aws/service/bottlerocket/aws-ecs-1-nvidia/x86_64/latest/image_id", "ami-0bottlerocket00002")
+
+	ami, err := provider.LatestBottlerocketAMI(aws.BottlerocketVariantECS)
+	require.NoError(t, err)
+	assert.Equal(t, "ami-0bottlerocket00001", ami)
+
+	ami, err = provider.LatestBottlerocketAMI(aws.BottlerocketVariantNvidia)
+	require.NoError(t, err)
+	assert.Equal(t, "ami-0bottlerocket00002", ami)
+
+	_, err = provider.LatestBottlerocketAMI("aws-k8s-1.24")
+	require.EqualError(t, err, "bottlerocket variant aws-k8s-1.24 invalid, must be one of aws-ecs-1, aws-ecs-1-nvidia")
+}
+
+func TestLatestECSAMI(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.Fake.SSM.SetParameter("/aws/service/ecs/optimized-ami/amazon-linux-2/recommended/image_id", "ami-0amazonlinux00001")
+	provider.Fake.SSM.SetParameter("/aws/service/bottlerocket/aws-ecs-1/x86_64/latest/image_id", "ami-0bottlerocket00001")
+
+	ami, err := provider.LatestECSAMI()
+	require.NoError(t, err)
+	assert.Equal(t, "ami-0amazonlinux00001", ami)
+
+	provider.UseBottlerocket = true
+
+	ami, err = provider.LatestECSAMI()
+	require.NoError(t, err)
+	assert.Equal(t, "ami-0bottlerocket00001", ami)
+}
+
+func TestLatestECSAMIMissingParameter(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	_, err := provider.LatestECSAMI()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "parameter not found")
+}
+
+func TestValidateBottlerocketVariant(t *testing.T) {
+	gpu, err := manifest.Load([]byte("services:\n  train:\n    scale:\n      gpu: 1\n  web:\n    port: 3000\n"), map[string]string{})
+	require.NoError(t, err)
+
+	cpu, err := manifest.Load([]byte("services:\n  web:\n    port: 3000\n"), map[string]string{})
+	require.NoError(t, err)
+
+	require.NoError(t, aws.ValidateBottlerocketVariant(aws.BottlerocketVariantECS, cpu))
+	require.NoError(t, aws.ValidateBottlerocketVariant(aws.BottlerocketVariantECS, gpu))
+	require.NoError(t, aws.ValidateBottlerocketVariant(aws.BottlerocketVariantNvidia, gpu))
+
+	err = aws.ValidateBottlerocketVariant(aws.BottlerocketVariantNvidia, cpu)
+	require.EqualError(t, err, "bottlerocket variant aws-ecs-1-nvidia requires a service with scale gpu set")
+
+	err = aws.ValidateBottlerocketVariant("aws-ecs-2", cpu)
+	require.EqualError(t, err, "bottlerocket variant aws-ecs-2 invalid, must be one of aws-ecs-1, aws-ecs-1-nvidia")
+}
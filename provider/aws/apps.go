@@ -3,13 +3,13 @@ package aws
 import (
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 	"unicode"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/aws/aws-sdk-go/service/ecr"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -71,12 +71,14 @@ func (p *Provider) AppCreate(name string, opts structs.AppCreateOptions) (*struc
 	}
 
 	if err := p.createStack(p.rackStack(name), data, params, tags); err != nil {
-		if awsError(err) == "AlreadyExistsException" {
+		if isConflict(err) {
 			return nil, fmt.Errorf("app already exists: %s", name)
 		}
 		return nil, err
 	}
 
+	p.auditRecord("app.create", name, p.actor(), nil)
+
 	p.EventSend("app:create", structs.EventSendOptions{Data: map[string]string{"name": name}})
 
 	return p.AppGet(name)
@@ -110,12 +112,14 @@ func (p *Provider) appCreateGeneration1(name string) (*structs.App, error) {
 	}
 
 	if err := p.createStack(p.rackStack(name), data, params, tags); err != nil {
-		if awsError(err) == "AlreadyExistsException" {
+		if isConflict(err) {
 			return nil, fmt.Errorf("app already exists: %s", name)
 		}
 		return nil, err
 	}
 
+	p.auditRecord("app.create", name, p.actor(), nil)
+
 	p.EventSend("app:create", structs.EventSendOptions{Data: map[string]string{"name": name}})
 
 	return p.AppGet(name)
@@ -126,7 +130,7 @@ func (p *Provider) AppGet(name string) (*structs.App, error) {
 	stacks, err := p.describeStacks(&cloudformation.DescribeStacksInput{
 		StackName: aws.String(p.Rack + "-" + name),
 	})
-	if ae, ok := err.(awserr.Error); ok && ae.Code() == "ValidationError" {
+	if isNotFound(err) {
 		return nil, errorNotFound(fmt.Sprintf("app not found: %s", name))
 	}
 	if err != nil {
@@ -137,7 +141,7 @@ func (p *Provider) AppGet(name string) (*structs.App, error) {
 	}
 
 	app, err := p.appFromStack(stacks[0])
-	if ae, ok := err.(awserr.Error); ok && ae.Code() == "ValidationError" {
+	if isNotFound(err) {
 		return nil, errorNotFound(fmt.Sprintf("app not found: %s", name))
 	}
 	if err != nil {
@@ -186,6 +190,8 @@ func (p *Provider) AppDelete(name string) error {
 		return err
 	}
 
+	p.auditRecord("app.delete", name, p.actor(), nil)
+
 	go p.cleanup(app)
 
 	return nil
@@ -257,7 +263,23 @@ func (p *Provider) AppUpdate(app string, opts structs.AppUpdateOptions) error {
 		}
 	}
 
-	return p.updateStack(p.rackStack(app), nil, opts.Parameters, map[string]string{}, "")
+	if err := p.updateStack(p.rackStack(app), nil, opts.Parameters, map[string]string{}, ""); err != nil {
+		return err
+	}
+
+	detail := map[string]string{}
+
+	if len(opts.Parameters) > 0 {
+		detail["params"] = auditParams(opts.Parameters)
+	}
+
+	if opts.Lock != nil {
+		detail["lock"] = strconv.FormatBool(*opts.Lock)
+	}
+
+	p.auditRecord("app.update", app, p.actor(), detail)
+
+	return nil
 }
 
 func (p *Provider) appFromStack(stack *cloudformation.Stack) (*structs.App, error) {
@@ -401,16 +423,14 @@ func (p *Provider) cleanup(app *structs.App) error {
 		})
 
 		// return when stack is not found indicating successful delete
-		if ae, ok := err.(awserr.Error); ok {
-			if ae.Code() == "ValidationError" { // Error indicates stack wasn't found, hence deleted.
-				helpers.TrackEvent("kernel-app-delete-success", nil)
-				// Last ditch effort to remove the empty bucket CF leaves behind.
-				_, err := p.s3().DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String(settings)})
-				if err != nil {
-					fmt.Printf("last ditch effort bucket error: %s\n", err)
-				}
-				return nil
+		if isNotFound(err) {
+			helpers.TrackEvent("kernel-app-delete-success", nil)
+			// Last ditch effort to remove the empty bucket CF leaves behind.
+			_, err := p.s3().DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String(settings)})
+			if err != nil {
+				fmt.Printf("last ditch effort bucket error: %s\n", err)
 			}
+			return nil
 		}
 
 		if err == nil && len(res.Stacks) == 1 && shouldRetry {
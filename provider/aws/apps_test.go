@@ -10,6 +10,7 @@ import (
 	"github.com/convox/rack/pkg/options"
 	"github.com/convox/rack/pkg/structs"
 	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws/awsfake"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -19,15 +20,20 @@ func init() {
 }
 
 func TestAppCancel(t *testing.T) {
-	provider := StubAwsProvider(
-		cycleAppDescribeStacks,
-		cycleAppCancelUpdateStack,
-	)
+	provider := awsfake.NewTestProvider()
 	defer provider.Close()
 
-	err := provider.AppCancel("httpd")
+	provider.AddApp("httpd", "1", awsfake.Stack{
+		Parameters: map[string]string{"Release": "RVFETUHHKKD"},
+		Status:     "UPDATE_IN_PROGRESS",
+	})
 
+	err := provider.AppCancel("httpd")
 	assert.NoError(t, err)
+
+	s, ok := provider.Fake.CloudFormation.Stack("convox-httpd")
+	assert.True(t, ok)
+	assert.Equal(t, "UPDATE_ROLLBACK_COMPLETE", s.Status)
 }
 
 func TestAppGet(t *testing.T) {
@@ -129,23 +135,6 @@ func TestAppLogsSince(t *testing.T) {
 	assert.Equal(t, "2020-07-29T15:09:38Z stream1 event2\n2020-07-29T15:09:38Z stream2 event3\n2020-07-29T15:09:38Z stream3 event4\n2020-07-29T15:09:38Z stream1 event1\n2020-07-29T15:09:38Z stream2 event5\n", buf.String())
 }
 
-var cycleAppCancelUpdateStack = awsutil.Cycle{
-	awsutil.Request{
-		RequestURI: "/",
-		Body:       `/Action=CancelUpdateStack&ClientRequestToken=[0-9]+-[A-Z]+&StackName=convox-httpd&Version=2010-05-15/`,
-	},
-	awsutil.Response{
-		StatusCode: 200,
-		Body: `
-			<CancelUpdateStackResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
-				<ResponseMetadata>
-					<RequestId>5ccc7dcd-744c-11e5-be70-1b08c228efb3</RequestId>
-				</ResponseMetadata>
-			</CancelUpdateStackResponse>
-		`,
-	},
-}
-
 var cycleAppDescribeStacks = awsutil.Cycle{
 	awsutil.Request{"POST", "/", "", `Action=DescribeStacks&StackName=convox-httpd&Version=2010-05-15`},
 	awsutil.Response{200, `
@@ -0,0 +1,142 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+)
+
+// arnParts is an amazon resource name split into its fields
+// arn:<partition>:<service>:<region>:<account>:<resource type>/<resource>
+type arnParts struct {
+	Partition    string
+	Service      string
+	Region       string
+	Account      string
+	ResourceType string
+	Resource     string
+
+	// separator is what divides the resource type from the resource, "/" unless the arn used ":" like log groups do
+	separator string
+}
+
+// parseARN splits an arn into its fields
+// ecs arns keep the cluster in the resource of the long format, e.g. task/<cluster>/<id>, use the extractors to read them
+func parseARN(s string) (arnParts, error) {
+	ap := strings.SplitN(s, ":", 6)
+
+	if len(ap) < 6 || ap[0] != "arn" || ap[1] == "" || ap[2] == "" || ap[5] == "" {
+		return arnParts{}, fmt.Errorf("invalid arn: %s", s)
+	}
+
+	a := arnParts{
+		Partition: ap[1],
+		Service:   ap[2],
+		Region:    ap[3],
+		Account:   ap[4],
+		Resource:  ap[5],
+	}
+
+	if i := strings.IndexAny(ap[5], "/:"); i > 0 {
+		a.ResourceType = ap[5][:i]
+		a.Resource = ap[5][i+1:]
+		a.separator = ap[5][i : i+1]
+	}
+
+	return a, nil
+}
+
+// String joins the fields back into an arn
+func (a arnParts) String() string {
+	resource := a.Resource
+
+	if a.ResourceType != "" {
+		resource = a.ResourceType + coalesces(a.separator, "/") + a.Resource
+	}
+
+	return strings.Join([]string{"arn", coalesces(a.Partition, "aws"), a.Service, a.Region, a.Account, resource}, ":")
+}
+
+// arnPartition returns the partition resources in region belong to
+func arnPartition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	default:
+		return "aws"
+	}
+}
+
+// ecsResource parses an ecs arn of the given resource type and returns the path of its resource
+// short arns have only the name, long arns put the cluster first
+func ecsResource(s, kind string) ([]string, error) {
+	a, err := parseARN(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Service != "ecs" || a.ResourceType != kind {
+		return nil, fmt.Errorf("invalid ecs %s arn: %s", kind, s)
+	}
+
+	path := strings.Split(a.Resource, "/")
+
+	if len(path) > 2 || path[len(path)-1] == "" {
+		return nil, fmt.Errorf("invalid ecs %s arn: %s", kind, s)
+	}
+
+	return path, nil
+}
+
+// taskIdFromArn returns the id of an ecs task from its short or long arn
+func taskIdFromArn(s string) (string, error) {
+	path, err := ecsResource(s, "task")
+	if err != nil {
+		return "", err
+	}
+
+	return path[len(path)-1], nil
+}
+
+// serviceNameFromArn returns the name of an ecs service from its short or long arn
+func serviceNameFromArn(s string) (string, error) {
+	path, err := ecsResource(s, "service")
+	if err != nil {
+		return "", err
+	}
+
+	return path[len(path)-1], nil
+}
+
+// clusterNameFromArn returns the cluster name of an ecs cluster arn, or of a task, service or container instance
+// arn in the long format, short arns of those do not name their cluster
+func clusterNameFromArn(s string) (string, error) {
+	a, err := parseARN(s)
+	if err != nil {
+		return "", err
+	}
+
+	if a.Service != "ecs" {
+		return "", fmt.Errorf("invalid ecs arn: %s", s)
+	}
+
+	if a.ResourceType == "cluster" {
+		if a.Resource == "" || strings.Contains(a.Resource, "/") {
+			return "", fmt.Errorf("invalid ecs cluster arn: %s", s)
+		}
+
+		return a.Resource, nil
+	}
+
+	path, err := ecsResource(s, a.ResourceType)
+	if err != nil {
+		return "", err
+	}
+
+	if len(path) < 2 {
+		return "", fmt.Errorf("arn does not include a cluster: %s", s)
+	}
+
+	return path[0], nil
+}
@@ -0,0 +1,153 @@
+package aws_test
+
+import (
+	"testing"
+
+	"github.com/convox/rack/provider/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseARN(t *testing.T) {
+	tests := []struct {
+		Arn   string
+		Parts aws.ARNParts
+		Error string
+	}{
+		{
+			Arn:   "arn:aws:ecs:us-east-1:123456789012:task/1dc5c17a-422b-4dc4-b493-371970c6c4d6",
+			Parts: aws.ARNParts{Partition: "aws", Service: "ecs", Region: "us-east-1", Account: "123456789012", ResourceType: "task", Resource: "1dc5c17a-422b-4dc4-b493-371970c6c4d6"},
+		},
+		{
+			Arn:   "arn:aws:ecs:us-east-1:123456789012:task/convox-Cluster-1E4XJ0PQWNAYP/2d1cd8e4a4b44d6c9c1a8e3a0f5e2f10",
+			Parts: aws.ARNParts{Partition: "aws", Service: "ecs", Region: "us-east-1", Account: "123456789012", ResourceType: "task", Resource: "convox-Cluster-1E4XJ0PQWNAYP/2d1cd8e4a4b44d6c9c1a8e3a0f5e2f10"},
+		},
+		{
+			Arn:   "arn:aws-us-gov:ecs:us-gov-west-1:123456789012:service/convox-Cluster-1E4XJ0PQWNAYP/convox-httpd-ServiceWeb-1OAYMX5UA6ZAR",
+			Parts: aws.ARNParts{Partition: "aws-us-gov", Service: "ecs", Region: "us-gov-west-1", Account: "123456789012", ResourceType: "service", Resource: "convox-Cluster-1E4XJ0PQWNAYP/convox-httpd-ServiceWeb-1OAYMX5UA6ZAR"},
+		},
+		{
+			Arn:   "arn:aws-cn:ecs:cn-north-1:123456789012:task-definition/convox-httpd-web:12",
+			Parts: aws.ARNParts{Partition: "aws-cn", Service: "ecs", Region: "cn-north-1", Account: "123456789012", ResourceType: "task-definition", Resource: "convox-httpd-web:12"},
+		},
+		{
+			Arn:   "arn:aws:logs:us-east-1:123456789012:log-group:/convox/httpd:*",
+			Parts: aws.ARNParts{Partition: "aws", Service: "logs", Region: "us-east-1", Account: "123456789012", ResourceType: "log-group", Resource: "/convox/httpd:*"},
+		},
+		{
+			Arn:   "arn:aws:iam::123456789012:server-certificate/cloudfront/prod/example",
+			Parts: aws.ARNParts{Partition: "aws", Service: "iam", Account: "123456789012", ResourceType: "server-certificate", Resource: "cloudfront/prod/example"},
+		},
+		{
+			Arn:   "arn:aws:sns:us-east-1:123456789012:convox-notifications",
+			Parts: aws.ARNParts{Partition: "aws", Service: "sns", Region: "us-east-1", Account: "123456789012", Resource: "convox-notifications"},
+		},
+		{Arn: "arn:aws:ecs:us-east-1:123456789012:", Error: "invalid arn: arn:aws:ecs:us-east-1:123456789012:"},
+		{Arn: "arn:aws:ecs:us-east-1", Error: "invalid arn: arn:aws:ecs:us-east-1"},
+		{Arn: "urn:aws:ecs:us-east-1:123456789012:task/abc", Error: "invalid arn: urn:aws:ecs:us-east-1:123456789012:task/abc"},
+		{Arn: "", Error: "invalid arn: "},
+	}
+
+	for _, test := range tests {
+		a, err := aws.ParseARN(test.Arn)
+
+		if test.Error != "" {
+			assert.EqualError(t, err, test.Error, test.Arn)
+			continue
+		}
+
+		require.NoError(t, err, test.Arn)
+
+		// the separator is unexported so compare the fields and the round trip
+		assert.Equal(t, test.Parts.Partition, a.Partition, test.Arn)
+		assert.Equal(t, test.Parts.Service, a.Service, test.Arn)
+		assert.Equal(t, test.Parts.Region, a.Region, test.Arn)
+		assert.Equal(t, test.Parts.Account, a.Account, test.Arn)
+		assert.Equal(t, test.Parts.ResourceType, a.ResourceType, test.Arn)
+		assert.Equal(t, test.Parts.Resource, a.Resource, test.Arn)
+		assert.Equal(t, test.Arn, a.String(), test.Arn)
+	}
+}
+
+func TestARNString(t *testing.T) {
+	a := aws.ARNParts{Partition: aws.ArnPartition("us-gov-east-1"), Service: "ecr", Region: "us-gov-east-1", Account: "123456789012", ResourceType: "repository", Resource: "convox/httpd"}
+	assert.Equal(t, "arn:aws-us-gov:ecr:us-gov-east-1:123456789012:repository/convox/httpd", a.String())
+
+	a = aws.ARNParts{Service: "sqs", Region: "us-east-1", Account: "123456789012", Resource: "convox-events"}
+	assert.Equal(t, "arn:aws:sqs:us-east-1:123456789012:convox-events", a.String())
+
+	assert.Equal(t, "aws", aws.ArnPartition("us-east-1"))
+	assert.Equal(t, "aws-cn", aws.ArnPartition("cn-northwest-1"))
+	assert.Equal(t, "aws-us-gov", aws.ArnPartition("us-gov-west-1"))
+}
+
+func TestECSArnExtractors(t *testing.T) {
+	tests := []struct {
+		Arn     string
+		Task    string
+		Service string
+		Cluster string
+	}{
+		{
+			Arn:  "arn:aws:ecs:us-east-1:123456789012:task/1dc5c17a-422b-4dc4-b493-371970c6c4d6",
+			Task: "1dc5c17a-422b-4dc4-b493-371970c6c4d6",
+		},
+		{
+			Arn:     "arn:aws:ecs:us-east-1:123456789012:task/convox-Cluster-1E4XJ0PQWNAYP/2d1cd8e4a4b44d6c9c1a8e3a0f5e2f10",
+			Task:    "2d1cd8e4a4b44d6c9c1a8e3a0f5e2f10",
+			Cluster: "convox-Cluster-1E4XJ0PQWNAYP",
+		},
+		{
+			Arn:     "arn:aws:ecs:us-east-1:123456789012:service/convox-httpd-ServiceWeb-1OAYMX5UA6ZAR",
+			Service: "convox-httpd-ServiceWeb-1OAYMX5UA6ZAR",
+		},
+		{
+			Arn:     "arn:aws-us-gov:ecs:us-gov-west-1:123456789012:service/convox-Cluster-1E4XJ0PQWNAYP/convox-httpd-ServiceWeb-1OAYMX5UA6ZAR",
+			Service: "convox-httpd-ServiceWeb-1OAYMX5UA6ZAR",
+			Cluster: "convox-Cluster-1E4XJ0PQWNAYP",
+		},
+		{
+			Arn:     "arn:aws-cn:ecs:cn-north-1:123456789012:cluster/convox-Cluster-1E4XJ0PQWNAYP",
+			Cluster: "convox-Cluster-1E4XJ0PQWNAYP",
+		},
+		{
+			Arn:     "arn:aws:ecs:us-east-1:123456789012:container-instance/convox-Cluster-1E4XJ0PQWNAYP/6f0e1f5cbf2a4a0b9f1f0d5a3c7b8e9d",
+			Cluster: "convox-Cluster-1E4XJ0PQWNAYP",
+		},
+		{
+			Arn: "arn:aws:ecs:us-east-1:123456789012:task/",
+		},
+		{
+			Arn: "arn:aws:ecs:us-east-1:123456789012:task/a/b/c",
+		},
+		{
+			Arn: "arn:aws:ec2:us-east-1:123456789012:instance/i-0abcdef1234567890",
+		},
+	}
+
+	for _, test := range tests {
+		task, err := aws.TaskIdFromArn(test.Arn)
+		assert.Equal(t, test.Task, task, test.Arn)
+		assert.Equal(t, test.Task == "", err != nil, test.Arn)
+
+		service, err := aws.ServiceNameFromArn(test.Arn)
+		assert.Equal(t, test.Service, service, test.Arn)
+		assert.Equal(t, test.Service == "", err != nil, test.Arn)
+
+		cluster, err := aws.ClusterNameFromArn(test.Arn)
+		assert.Equal(t, test.Cluster, cluster, test.Arn)
+		assert.Equal(t, test.Cluster == "", err != nil, test.Arn)
+	}
+
+	_, err := aws.ClusterNameFromArn("arn:aws:ecs:us-east-1:123456789012:task/1dc5c17a-422b-4dc4-b493-371970c6c4d6")
+	assert.EqualError(t, err, "arn does not include a cluster: arn:aws:ecs:us-east-1:123456789012:task/1dc5c17a-422b-4dc4-b493-371970c6c4d6")
+
+	_, err = aws.TaskIdFromArn("arn:aws:ecs:us-east-1:123456789012:service/web")
+	assert.EqualError(t, err, "invalid ecs task arn: arn:aws:ecs:us-east-1:123456789012:service/web")
+}
+
+func TestArnToPid(t *testing.T) {
+	assert.Equal(t, "371970c6c4d6", aws.ArnToPid("arn:aws:ecs:us-east-1:123456789012:task/1dc5c17a-422b-4dc4-b493-371970c6c4d6"))
+	assert.Equal(t, "8e3a0f5e2f10", aws.ArnToPid("arn:aws:ecs:us-east-1:123456789012:task/convox-Cluster-1E4XJ0PQWNAYP/2d1cd8e4a4b44d6c9c1a8e3a0f5e2f10"))
+	assert.Equal(t, "abc", aws.ArnToPid("abc"))
+}
@@ -0,0 +1,243 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/convox/rack/pkg/cache"
+	"github.com/convox/rack/pkg/structs"
+)
+
+// auditEntry is a record of a change made through the provider
+type auditEntry struct {
+	Id      string
+	Op      string
+	Target  string
+	Actor   string
+	Created time.Time
+	Detail  map[string]string
+}
+
+// auditParams returns the sorted names of changed stack parameters
+// values are left out so NoEcho parameters such as passwords never reach the audit table
+func auditParams(params map[string]string) string {
+	names := []string{}
+
+	for k := range params {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, ",")
+}
+
+// auditRecord notes that actor performed op on target
+// recording is best-effort so a failure never fails the change itself, but it is logged as an error
+func (p *Provider) auditRecord(op, target, actor string, detail map[string]string) {
+	log := Logger.At("auditRecord").Namespace("op=%s target=%s actor=%q", op, target, actor).Start()
+
+	table, err := p.auditTable()
+	if err != nil {
+		log.Error(fmt.Errorf("could not record audit entry: %s", err))
+		return
+	}
+
+	now := time.Now().UTC()
+
+	item := map[string]*dynamodb.AttributeValue{
+		"id":      {S: aws.String(fmt.Sprintf("%s-%s", now.Format(sortableTime), generateId("", 6)))},
+		"op":      {S: aws.String(op)},
+		"target":  {S: aws.String(target)},
+		"actor":   {S: aws.String(actor)},
+		"created": {S: aws.String(now.Format(sortableTime))},
+	}
+
+	if len(detail) > 0 {
+		m := map[string]*dynamodb.AttributeValue{}
+
+		for k, v := range detail {
+			m[k] = &dynamodb.AttributeValue{S: aws.String(v)}
+		}
+
+		item["detail"] = &dynamodb.AttributeValue{M: m}
+	}
+
+	_, err = p.dynamodb().PutItem(&dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(table),
+	})
+	if err != nil {
+		log.Error(fmt.Errorf("could not record audit entry: %s", err))
+		return
+	}
+
+	log.Success()
+}
+
+// auditLog returns up to limit entries for target created at or after since, newest first
+func (p *Provider) auditLog(target string, since time.Time, limit int) ([]auditEntry, error) {
+	table, err := p.auditTable()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []auditEntry{}
+
+	req := &dynamodb.QueryInput{
+		KeyConditions: map[string]*dynamodb.Condition{
+			"target": {
+				AttributeValueList: []*dynamodb.AttributeValue{{S: aws.String(target)}},
+				ComparisonOperator: aws.String("EQ"),
+			},
+			"created": {
+				AttributeValueList: []*dynamodb.AttributeValue{{S: aws.String(since.UTC().Format(sortableTime))}},
+				ComparisonOperator: aws.String("GE"),
+			},
+		},
+		IndexName:        aws.String("target.created"),
+		Limit:            aws.Int64(int64(limit)),
+		ScanIndexForward: aws.Bool(false),
+		TableName:        aws.String(table),
+	}
+
+	for {
+		res, err := p.dynamodb().Query(req)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range res.Items {
+			e, err := auditEntryFromItem(item)
+			if err != nil {
+				return nil, err
+			}
+
+			entries = append(entries, *e)
+		}
+
+		if len(entries) >= limit || len(res.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		req.ExclusiveStartKey = res.LastEvaluatedKey
+		req.Limit = aws.Int64(int64(limit - len(entries)))
+	}
+
+	return entries, nil
+}
+
+// auditTable returns the name of the audit table declared in the rack template
+// racks installed before the table was declared fall back to one created on demand until they are updated,
+// creation runs in the background so callers get an error rather than waiting while that table is not active yet
+func (p *Provider) auditTable() (string, error) {
+	if t, ok := cache.Get("auditTable", p.Rack).(string); ok {
+		return t, nil
+	}
+
+	table, err := p.rackResource("DynamoAudit")
+	switch {
+	case errors.Is(err, ErrResourceNotFound):
+		table = fmt.Sprintf("%s-audit", p.Rack)
+
+		if err := p.auditTableReady(table); err != nil {
+			return "", err
+		}
+	case err != nil:
+		return "", err
+	}
+
+	if !p.SkipCache {
+		if err := cache.Set("auditTable", p.Rack, table, 1*time.Hour); err != nil {
+			return "", err
+		}
+	}
+
+	return table, nil
+}
+
+// auditTableReady returns nil if the fallback audit table is active, otherwise it starts creating it if needed
+func (p *Provider) auditTableReady(table string) error {
+	res, err := p.dynamodb().DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	switch {
+	case isNotFound(err):
+		go p.auditTableCreate(table)
+	case err != nil:
+		return err
+	case res.Table != nil && aws.StringValue(res.Table.TableStatus) == dynamodb.TableStatusActive:
+		return nil
+	}
+
+	return fmt.Errorf("audit table %s is not ready", table)
+}
+
+func (p *Provider) auditTableCreate(table string) {
+	log := Logger.At("auditTableCreate").Namespace("table=%s", table).Start()
+
+	_, err := p.dynamodb().CreateTable(&dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: aws.String("S")},
+			{AttributeName: aws.String("target"), AttributeType: aws.String("S")},
+			{AttributeName: aws.String("created"), AttributeType: aws.String("S")},
+		},
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String("target.created"),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{AttributeName: aws.String("target"), KeyType: aws.String("HASH")},
+					{AttributeName: aws.String("created"), KeyType: aws.String("RANGE")},
+				},
+				Projection: &dynamodb.Projection{ProjectionType: aws.String("ALL")},
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: aws.String("HASH")},
+		},
+		TableName: aws.String(table),
+	})
+	if err != nil && !isConflict(err) {
+		log.Error(err)
+		return
+	}
+
+	log.Success()
+}
+
+// actor returns who is making the current calls, changes the rack makes on its own are attributed to system
+func (p *Provider) actor() string {
+	if a := structs.ContextActor(p.Context()); a != "" {
+		return a
+	}
+
+	return "system"
+}
+
+func auditEntryFromItem(item map[string]*dynamodb.AttributeValue) (*auditEntry, error) {
+	created, err := time.Parse(sortableTime, coalesce(item["created"], ""))
+	if err != nil {
+		return nil, err
+	}
+
+	e := &auditEntry{
+		Id:      coalesce(item["id"], ""),
+		Op:      coalesce(item["op"], ""),
+		Target:  coalesce(item["target"], ""),
+		Actor:   coalesce(item["actor"], ""),
+		Created: created,
+		Detail:  map[string]string{},
+	}
+
+	if d, ok := item["detail"]; ok {
+		for k, v := range d.M {
+			e.Detail[k] = aws.StringValue(v.S)
+		}
+	}
+
+	return e, nil
+}
@@ -0,0 +1,146 @@
+package aws_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/options"
+	"github.com/convox/rack/pkg/structs"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditMutations(t *testing.T) {
+	provider := auditTestProvider()
+	defer provider.Close()
+
+	// formation templates are loaded relative to the repository root
+	cwd, _ := os.Getwd()
+	require.NoError(t, os.Chdir("../.."))
+	defer os.Chdir(cwd)
+
+	p := provider.WithContext(structs.ActorContext(context.Background(), "admin@10.0.0.1"))
+
+	_, err := p.AppCreate("web", structs.AppCreateOptions{Generation: options.String("2")})
+	require.NoError(t, err)
+
+	_, err = p.ReleaseCreate("httpd", structs.ReleaseCreateOptions{Env: options.String("FOO=bar")})
+	require.NoError(t, err)
+
+	require.NoError(t, p.AppUpdate("httpd", structs.AppUpdateOptions{Parameters: map[string]string{"Internal": "Yes"}}))
+
+	require.NoError(t, p.ServiceUpdate("httpd", "web", structs.ServiceUpdateOptions{Count: options.Int(3)}))
+
+	web, err := provider.AuditLog("web", time.Time{}, 10)
+	require.NoError(t, err)
+	require.Len(t, web, 1)
+	assert.Equal(t, "app.create", web[0].Op)
+	assert.Equal(t, "admin@10.0.0.1", web[0].Actor)
+
+	httpd, err := provider.AuditLog("httpd", time.Time{}, 10)
+	require.NoError(t, err)
+
+	ops := []string{}
+
+	for _, e := range httpd {
+		ops = append(ops, e.Op)
+		assert.Equal(t, "admin@10.0.0.1", e.Actor)
+	}
+
+	assert.ElementsMatch(t, []string{"app.update", "env.update", "service.scale"}, ops)
+
+	for _, e := range httpd {
+		switch e.Op {
+		case "app.update":
+			assert.Equal(t, map[string]string{"params": "Internal"}, e.Detail)
+		case "env.update":
+			assert.Equal(t, "env add:FOO", e.Detail["change"])
+		case "service.scale":
+			assert.Equal(t, map[string]string{"service": "web", "formation": "3,256,512"}, e.Detail)
+		}
+	}
+
+	assert.Len(t, provider.Fake.DynamoDB.Items("convox-audit"), 4)
+}
+
+func TestAuditLog(t *testing.T) {
+	provider := auditTestProvider()
+	defer provider.Close()
+
+	provider.AuditRecord("app.create", "httpd", "system", nil)
+	time.Sleep(10 * time.Millisecond)
+	since := time.Now()
+	provider.AuditRecord("app.update", "httpd", "system", nil)
+	time.Sleep(10 * time.Millisecond)
+	provider.AuditRecord("service.scale", "httpd", "system", nil)
+	provider.AuditRecord("app.create", "other", "system", nil)
+
+	entries, err := provider.AuditLog("httpd", time.Time{}, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "service.scale", entries[0].Op)
+	assert.Equal(t, "app.update", entries[1].Op)
+	assert.Equal(t, "app.create", entries[2].Op)
+	assert.True(t, entries[0].Created.After(entries[1].Created))
+
+	entries, err = provider.AuditLog("httpd", time.Time{}, 2)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "service.scale", entries[0].Op)
+	assert.Equal(t, "app.update", entries[1].Op)
+
+	entries, err = provider.AuditLog("httpd", since, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "service.scale", entries[0].Op)
+	assert.Equal(t, "app.update", entries[1].Op)
+}
+
+func TestAuditRecordLegacyTable(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	// a rack installed before DynamoAudit was declared has no audit table yet
+	provider.Rack = "legacy"
+	provider.Fake.CloudFormation.AddStack(awsfake.Stack{Name: "legacy"})
+
+	// the first entry is dropped rather than waiting for the table to be created
+	provider.AuditRecord("app.create", "httpd", "system", nil)
+
+	for i := 0; i < 100; i++ {
+		if _, err := provider.AuditLog("httpd", time.Time{}, 10); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	provider.AuditRecord("app.update", "httpd", "system", nil)
+
+	entries, err := provider.AuditLog("httpd", time.Time{}, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "app.update", entries[0].Op)
+}
+
+func auditTestProvider() *awsfake.TestProvider {
+	provider := awsfake.NewTestProvider()
+	provider.Version = "20200101000000"
+
+	provider.AddRack(awsfake.Stack{
+		Parameters: map[string]string{"Private": "No"},
+		Resources: []awsfake.Resource{
+			{LogicalId: "DynamoAudit", PhysicalId: "convox-audit"},
+			{LogicalId: "EncryptionKey", PhysicalId: ""},
+		},
+	})
+
+	provider.AddApp("httpd", "2", awsfake.Stack{
+		Parameters: map[string]string{"Internal": "No", "WebFormation": "1,256,512"},
+		Resources:  []awsfake.Resource{{LogicalId: "Settings", PhysicalId: "convox-httpd-settings"}},
+	})
+
+	return provider
+}
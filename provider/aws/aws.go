@@ -3,29 +3,38 @@ package aws
 import (
 	"context"
 	"fmt"
-	"math/rand"
 	"os"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
 	"github.com/aws/aws-sdk-go/service/acm"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ecr"
 	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/efs"
+	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/route53"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/sns"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/convox/logger"
 	"github.com/convox/rack/pkg/metrics"
@@ -55,6 +64,7 @@ type Provider struct {
 	Development         bool
 	DynamoBuilds        string
 	DynamoReleases      string
+	EcsExec             bool
 	EcsPollInterval     int
 	EncryptionKey       string
 	Fargate             bool
@@ -68,6 +78,7 @@ type Provider struct {
 	Private             bool
 	PrivateBuild        bool
 	Rack                string
+	RequireNonRoot      bool
 	SecurityGroup       string
 	SettingsBucket      string
 	SshKey              string
@@ -75,6 +86,8 @@ type Provider struct {
 	Subnets             string
 	SubnetsPrivate      string
 	StackId             string
+	StackOnFailure      string
+	UseBottlerocket     bool
 	Version             string
 	Vpc                 string
 	VpcCidr             string
@@ -84,24 +97,37 @@ type Provider struct {
 
 	CloudWatch cloudwatchiface.CloudWatchAPI
 
-	ctx context.Context
-	log *logger.Logger
+	ctx     context.Context
+	limiter *rateLimiter
+	log     *logger.Logger
 }
 
 // NewProviderFromEnv returns a new AWS provider from env vars
 func FromEnv() (*Provider, error) {
 	p := &Provider{
-		ClientId:    os.Getenv("CLIENT_ID"),
-		Development: os.Getenv("DEVELOPMENT") == "true",
-		Password:    os.Getenv("PASSWORD"),
-		Rack:        os.Getenv("RACK"),
-		Region:      os.Getenv("AWS_REGION"),
-		StackId:     os.Getenv("STACK_ID"),
-		Metrics:     metrics.New("https://metrics.convox.com/metrics/rack"),
-		ctx:         context.Background(),
-		log:         logger.New("ns=aws"),
+		ClientId:       os.Getenv("CLIENT_ID"),
+		Development:    os.Getenv("DEVELOPMENT") == "true",
+		Password:       os.Getenv("PASSWORD"),
+		Rack:           os.Getenv("RACK"),
+		Region:         os.Getenv("AWS_REGION"),
+		StackId:        os.Getenv("STACK_ID"),
+		StackOnFailure: os.Getenv("STACK_ON_FAILURE"),
+		Metrics:        metrics.New("https://metrics.convox.com/metrics/rack"),
+		ctx:            context.Background(),
+		log:            logger.New("ns=aws"),
 	}
 
+	if err := validateStackOnFailure(p.StackOnFailure); err != nil {
+		return nil, err
+	}
+
+	limits, err := parseRateLimits(os.Getenv("RATE_LIMITS"))
+	if err != nil {
+		return nil, err
+	}
+
+	p.limiter = newRateLimiter(limits)
+
 	if err := p.loadParams(); err != nil {
 		return nil, err
 	}
@@ -147,6 +173,7 @@ func (p *Provider) loadParams() error {
 	p.CustomEncryptionKey = labels["rack.CustomEncryptionKey"]
 	p.DynamoBuilds = labels["rack.DynamoBuilds"]
 	p.DynamoReleases = labels["rack.DynamoReleases"]
+	p.EcsExec = labels["rack.EcsExec"] == "Yes"
 	p.EcsPollInterval = intParam(labels["rack.EcsPollInterval"], 1)
 	p.EncryptionKey = labels["rack.EncryptionKey"]
 	p.Fargate = labels["rack.Fargate"] == "Yes"
@@ -158,6 +185,7 @@ func (p *Provider) loadParams() error {
 	p.OnDemandMinCount = intParam(labels["rack.OnDemandMinCount"], 2)
 	p.Private = labels["rack.Private"] == "Yes"
 	p.PrivateBuild = labels["rack.PrivateBuild"] == "Yes"
+	p.RequireNonRoot = labels["rack.RequireNonRoot"] == "Yes"
 	p.SecurityGroup = labels["rack.SecurityGroup"]
 	p.SettingsBucket = labels["rack.SettingsBucket"]
 	p.SpotInstances = labels["rack.SpotInstances"] == "Yes"
@@ -171,10 +199,6 @@ func (p *Provider) loadParams() error {
 	return nil
 }
 
-func init() {
-	rand.Seed(time.Now().UTC().UnixNano())
-}
-
 func (p *Provider) Initialize(opts structs.ProviderOptions) error {
 	if opts.Logs != nil {
 		Logger = logger.NewWriter("ns=aws", opts.Logs)
@@ -184,7 +208,7 @@ func (p *Provider) Initialize(opts structs.ProviderOptions) error {
 		go p.Workers()
 	}
 
-	p.CloudWatch = cloudwatch.New(session.New(), p.config())
+	p.CloudWatch = cloudwatch.New(p.session(), p.config())
 
 	return nil
 }
@@ -197,7 +221,12 @@ func (p *Provider) Context() context.Context {
 	return p.ctx
 }
 
+// WithContext returns a provider for serving a request, its calls use the interactive rate limits unless ctx says otherwise
 func (p *Provider) WithContext(ctx context.Context) structs.Provider {
+	if _, ok := contextPriority(ctx); !ok {
+		ctx = InteractiveContext(ctx)
+	}
+
 	cp := *p
 	cp.ctx = ctx
 	return &cp
@@ -246,10 +275,24 @@ func (p *Provider) config() *aws.Config {
 	return config
 }
 
+// session returns an aws session with the rate limiter installed
+func (p *Provider) session() *session.Session {
+	s := session.New()
+
+	s.Handlers.Sign.PushFrontNamed(request.NamedHandler{Name: "convox.RateLimit", Fn: p.rateLimitHandler})
+
+	return s
+}
+
 func (p *Provider) logger(at string) *logger.Logger {
 	log := p.log
 
-	if id := p.ctx.Value("request.id"); id != nil {
+	// providers not built by FromEnv have no logger of their own
+	if log == nil {
+		log = Logger
+	}
+
+	if id := p.Context().Value("request.id"); id != nil {
 		log = log.Prepend("id=%s", id)
 	}
 
@@ -257,63 +300,113 @@ func (p *Provider) logger(at string) *logger.Logger {
 }
 
 func (p *Provider) acm() *acm.ACM {
-	return acm.New(session.New(), p.config())
+	return acm.New(p.session(), p.config())
 }
 
 func (p *Provider) autoscaling() *autoscaling.AutoScaling {
-	return autoscaling.New(session.New(), p.config())
+	return autoscaling.New(p.session(), p.config())
 }
 
 func (p *Provider) cloudformation() *cloudformation.CloudFormation {
-	return cloudformation.New(session.New(), p.config())
+	return cloudformation.New(p.session(), p.config())
 }
 
 func (p *Provider) cloudwatch() *cloudwatch.CloudWatch {
-	return cloudwatch.New(session.New(), p.config())
+	return cloudwatch.New(p.session(), p.config())
+}
+
+func (p *Provider) cloudwatchevents() *cloudwatchevents.CloudWatchEvents {
+	return cloudwatchevents.New(p.session(), p.config())
 }
 
 func (p *Provider) cloudwatchlogs() *cloudwatchlogs.CloudWatchLogs {
-	return cloudwatchlogs.New(session.New(), p.config().WithLogLevel(aws.LogOff))
+	return cloudwatchlogs.New(p.session(), p.config().WithLogLevel(aws.LogOff))
 }
 
 func (p *Provider) dynamodb() *dynamodb.DynamoDB {
-	return dynamodb.New(session.New(), p.config())
+	return dynamodb.New(p.session(), p.config())
 }
 
 func (p *Provider) ec2() *ec2.EC2 {
-	return ec2.New(session.New(), p.config())
+	return ec2.New(p.session(), p.config())
 }
 
 func (p *Provider) ecr() *ecr.ECR {
-	return ecr.New(session.New(), p.config())
+	return ecr.New(p.session(), p.config())
 }
 
 func (p *Provider) ecs() *ecs.ECS {
-	return ecs.New(session.New(), p.config())
+	return ecs.New(p.session(), p.config())
+}
+
+func (p *Provider) efs() *efs.EFS {
+	return efs.New(p.session(), p.config())
+}
+
+func (p *Provider) elbv2() *elbv2.ELBV2 {
+	return elbv2.New(p.session(), p.config())
 }
 
 func (p *Provider) kms() *kms.KMS {
-	return kms.New(session.New(), p.config())
+	return kms.New(p.session(), p.config())
 }
 
 func (p *Provider) iam() *iam.IAM {
-	return iam.New(session.New(), p.config())
+	return iam.New(p.session(), p.config())
+}
+
+// pricing is only served from us-east-1 and ap-south-1 so the client ignores the rack region
+func (p *Provider) pricing() *pricing.Pricing {
+	return pricing.New(p.session(), p.config().WithRegion("us-east-1"))
+}
+
+func (p *Provider) route53() *route53.Route53 {
+	return route53.New(p.session(), p.config())
 }
 
 func (p *Provider) s3() *s3.S3 {
-	return s3.New(session.New(), p.config().WithS3ForcePathStyle(true))
+	return s3.New(p.session(), p.config().WithS3ForcePathStyle(true))
 }
 
 func (p *Provider) sns() *sns.SNS {
-	return sns.New(session.New(), p.config())
+	return sns.New(p.session(), p.config())
 }
 
 func (p *Provider) sqs() *sqs.SQS {
-	return sqs.New(session.New(), p.config())
+	return sqs.New(p.session(), p.config())
+}
+
+func (p *Provider) ssm() *ssm.SSM {
+	return ssm.New(p.session(), p.config())
 }
 
 func (p *Provider) sts() *sts.STS {
-	return sts.New(session.New(), p.config())
+	return sts.New(p.session(), p.config())
+}
+
+// wafv2 returns a bare json client for WAFv2, the vendored sdk predates it so requests go through local types in waf.go
+func (p *Provider) wafv2() *client.Client {
+	c := p.session().ClientConfig("wafv2", p.config())
+
+	cl := client.New(*c.Config, metadata.ClientInfo{
+		ServiceName:   "wafv2",
+		ServiceID:     "WAFV2",
+		SigningName:   c.SigningName,
+		SigningRegion: c.SigningRegion,
+		PartitionID:   c.PartitionID,
+		Endpoint:      c.Endpoint,
+		APIVersion:    "2019-07-29",
+		JSONVersion:   "1.1",
+		TargetPrefix:  "AWSWAF_20190729",
+	}, c.Handlers)
+
+	cl.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	cl.Handlers.Build.PushBackNamed(jsonrpc.BuildHandler)
+	cl.Handlers.Unmarshal.PushBackNamed(jsonrpc.UnmarshalHandler)
+	cl.Handlers.UnmarshalMeta.PushBackNamed(jsonrpc.UnmarshalMetaHandler)
+	cl.Handlers.UnmarshalError.PushBackNamed(jsonrpc.UnmarshalErrorHandler)
+
+	return cl
 }
 
 // IsTest returns true when we're in test mode
@@ -1,7 +1,7 @@
 package aws_test
 
 import (
-	"bytes"
+	"io/ioutil"
 	"net/http/httptest"
 	"os"
 
@@ -12,7 +12,7 @@ import (
 )
 
 func init() {
-	logger.Output = &bytes.Buffer{}
+	logger.Output = ioutil.Discard
 }
 
 type AwsStub struct {
@@ -35,17 +35,18 @@ func StubAwsProvider(cycles ...awsutil.Cycle) *AwsStub {
 	os.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret")
 
 	p := &aws.Provider{
-		Region:         "us-test-1",
-		Endpoint:       s.URL,
-		BuildCluster:   "cluster-test",
-		Cluster:        "cluster-test",
-		Development:    true,
-		DynamoBuilds:   "convox-builds",
-		DynamoReleases: "convox-releases",
-		Password:       "password",
-		Rack:           "convox",
-		SettingsBucket: "convox-settings",
-		SkipCache:      true,
+		Region:              "us-test-1",
+		Endpoint:            s.URL,
+		BuildCluster:        "cluster-test",
+		CloudformationTopic: "arn:aws:sns:us-test-1:123456789012:convox-events",
+		Cluster:             "cluster-test",
+		Development:         true,
+		DynamoBuilds:        "convox-builds",
+		DynamoReleases:      "convox-releases",
+		Password:            "password",
+		Rack:                "convox",
+		SettingsBucket:      "convox-settings",
+		SkipCache:           true,
 	}
 
 	return &AwsStub{p, s}
@@ -0,0 +1,417 @@
+// Package awsfake provides in-memory fakes of the AWS services used by the aws provider
+//
+// The provider builds its own SDK clients against Provider.Endpoint so the fakes are served
+// over HTTP and speak the same wire protocols as the real services
+package awsfake
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/private/protocol/xml/xmlutil"
+	provider "github.com/convox/rack/provider/aws"
+)
+
+const (
+	// Region is the region used by fake clients, it is also the region that makes Provider.IsTest true
+	Region = "us-test-1"
+
+	dynamoTargetPrefix  = "DynamoDB_20120810."
+	ecrTargetPrefix     = "AmazonEC2ContainerRegistry_V20150921."
+	ecsTargetPrefix     = "AmazonEC2ContainerServiceV20141113."
+	eventsTargetPrefix  = "AWSEvents."
+	pricingTargetPrefix = "AWSPriceListService."
+	ssmTargetPrefix     = "AmazonSSM."
+	wafv2TargetPrefix   = "AWSWAF_20190729."
+)
+
+// Fake serves fake CloudFormation, CloudWatch, CloudWatch Events, DynamoDB, ECR, ECS, ELBv2, IAM, Pricing, S3, SQS, SSM and WAFv2 apis from a single endpoint
+type Fake struct {
+	CloudFormation *CloudFormation
+	CloudWatch     *CloudWatch
+	Clock          *Clock
+	DynamoDB       *DynamoDB
+	ECR            *ECR
+	ECS            *ECS
+	ELBv2          *ELBv2
+	Events         *Events
+	IAM            *IAM
+	Pricing        *Pricing
+	S3             *S3
+	SQS            *SQS
+	SSM            *SSM
+	WAFv2          *WAFv2
+
+	drops  map[string]int
+	lock   sync.Mutex
+	server *httptest.Server
+}
+
+// New starts a Fake with empty services and a clock set to the current time
+func New() *Fake {
+	f := &Fake{Clock: NewClock(time.Now().UTC())}
+
+	f.S3 = &S3{clock: f.Clock}
+	f.CloudFormation = &CloudFormation{clock: f.Clock, s3: f.S3}
+	f.CloudWatch = &CloudWatch{}
+	f.DynamoDB = &DynamoDB{}
+	f.ECR = &ECR{clock: f.Clock}
+	f.ECS = &ECS{}
+	f.ELBv2 = &ELBv2{}
+	f.Events = &Events{}
+	f.IAM = &IAM{}
+	f.Pricing = &Pricing{}
+	f.SQS = &SQS{}
+	f.SSM = &SSM{}
+	f.WAFv2 = &WAFv2{}
+
+	f.server = httptest.NewServer(f)
+
+	return f
+}
+
+// Close shuts down the fake endpoint
+func (f *Fake) Close() {
+	f.server.Close()
+}
+
+// URL returns the endpoint for the fake services
+func (f *Fake) URL() string {
+	return f.server.URL
+}
+
+// Config returns an sdk config for building clients against the fake services
+func (f *Fake) Config() *aws.Config {
+	return &aws.Config{
+		Credentials:      credentials.NewStaticCredentials("test-access", "test-secret", ""),
+		Endpoint:         aws.String(f.URL()),
+		MaxRetries:       aws.Int(0),
+		Region:           aws.String(Region),
+		S3ForcePathStyle: aws.Bool(true),
+	}
+}
+
+// DropResponses serves the next n requests for operation and then closes the connection without answering
+// the client sees the request time out after the fake acted on it, so its retries exercise idempotency
+func (f *Fake) DropResponses(operation string, n int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.drops == nil {
+		f.drops = map[string]int{}
+	}
+
+	f.drops[operation] = n
+}
+
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.dropping(r) {
+		f.serve(w, r)
+		return
+	}
+
+	f.serve(&discardWriter{header: http.Header{}}, r)
+
+	if hj, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hj.Hijack(); err == nil {
+			conn.Close()
+		}
+	}
+}
+
+// dropping returns true when the response to r should be dropped, counting it against the operation
+func (f *Fake) dropping(r *http.Request) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if len(f.drops) == 0 {
+		return false
+	}
+
+	operation := ""
+
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		operation = target[strings.LastIndex(target, ".")+1:]
+	} else if r.Method == "POST" && r.URL.Path == "/" {
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return false
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+		if form, err := url.ParseQuery(string(data)); err == nil {
+			operation = form.Get("Action")
+		}
+	}
+
+	if f.drops[operation] <= 0 {
+		return false
+	}
+
+	f.drops[operation]--
+
+	return true
+}
+
+func (f *Fake) serve(w http.ResponseWriter, r *http.Request) {
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		switch {
+		case strings.HasPrefix(target, dynamoTargetPrefix):
+			f.DynamoDB.serve(w, r, strings.TrimPrefix(target, dynamoTargetPrefix))
+		case strings.HasPrefix(target, ecrTargetPrefix):
+			f.ECR.serve(w, r, strings.TrimPrefix(target, ecrTargetPrefix))
+		case strings.HasPrefix(target, ecsTargetPrefix):
+			f.ECS.serve(w, r, strings.TrimPrefix(target, ecsTargetPrefix))
+		case strings.HasPrefix(target, eventsTargetPrefix):
+			f.Events.serve(w, r, strings.TrimPrefix(target, eventsTargetPrefix))
+		case strings.HasPrefix(target, pricingTargetPrefix):
+			f.Pricing.serve(w, r, strings.TrimPrefix(target, pricingTargetPrefix))
+		case strings.HasPrefix(target, ssmTargetPrefix):
+			f.SSM.serve(w, r, strings.TrimPrefix(target, ssmTargetPrefix))
+		case strings.HasPrefix(target, wafv2TargetPrefix):
+			f.WAFv2.serve(w, r, strings.TrimPrefix(target, wafv2TargetPrefix))
+		default:
+			writeJSONError(w, 400, "UnknownOperationException", fmt.Sprintf("unsupported operation: %s", target))
+		}
+		return
+	}
+
+	if r.Method == "POST" && r.URL.Path == "/" {
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeQueryError(w, 400, "InvalidRequest", err.Error())
+			return
+		}
+
+		form, err := url.ParseQuery(string(data))
+		if err != nil {
+			writeQueryError(w, 400, "InvalidRequest", err.Error())
+			return
+		}
+
+		action := form.Get("Action")
+
+		var res interface{}
+
+		switch {
+		case cloudwatchActions[action]:
+			res, err = f.CloudWatch.serve(action, form)
+		case elbv2Actions[action]:
+			res, err = f.ELBv2.serve(action, form)
+		case iamActions[action]:
+			res, err = f.IAM.serve(action, form)
+		case sqsActions[action]:
+			res, err = f.SQS.serve(r.Context(), action, form)
+		default:
+			f.CloudFormation.serve(w, action, form)
+			return
+		}
+
+		if err != nil {
+			e, ok := err.(cfError)
+			if !ok {
+				e = cfError{"InternalFailure", err.Error()}
+			}
+
+			writeQueryError(w, 400, e.code, e.message)
+			return
+		}
+
+		writeXML(w, res)
+		return
+	}
+
+	f.S3.serve(w, r)
+}
+
+// discardWriter swallows the response to a request whose connection is dropped
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header {
+	return d.header
+}
+
+func (d *discardWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (d *discardWriter) WriteHeader(int) {}
+
+// TestProvider is an aws Provider wired to a Fake
+type TestProvider struct {
+	*provider.Provider
+	Fake *Fake
+}
+
+// Close shuts down the fake endpoint
+func (t *TestProvider) Close() {
+	t.Fake.Close()
+}
+
+// NewTestProvider returns a Provider backed by fresh fakes
+// The rack cluster, settings bucket and build and release tables are created so most provider calls work without seeding
+func NewTestProvider() *TestProvider {
+	f := New()
+
+	// the provider builds clients from the default credential chain
+	os.Setenv("AWS_ACCESS_KEY_ID", "test-access")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret")
+
+	p := &provider.Provider{
+		Region:              Region,
+		Endpoint:            f.URL(),
+		BuildCluster:        "cluster-test",
+		CloudformationTopic: "arn:aws:sns:us-test-1:123456789012:convox-events",
+		Cluster:             "cluster-test",
+		Development:         true,
+		DynamoBuilds:        "convox-builds",
+		DynamoReleases:      "convox-releases",
+		Password:            "password",
+		Rack:                "convox",
+		SettingsBucket:      "convox-settings",
+		SkipCache:           true,
+	}
+
+	f.DynamoDB.AddTable(Table{Name: "convox-audit", HashKey: "id", Indexes: map[string]Index{"target.created": {HashKey: "target", RangeKey: "created"}}})
+	f.DynamoDB.AddTable(Table{Name: p.DynamoBuilds, HashKey: "id", Indexes: map[string]Index{"app.created": {HashKey: "app", RangeKey: "created"}}})
+	f.DynamoDB.AddTable(Table{Name: p.DynamoReleases, HashKey: "id", Indexes: map[string]Index{"app.created": {HashKey: "app", RangeKey: "created"}}})
+	f.ECS.AddCluster(p.Cluster)
+	f.S3.CreateBucket(p.SettingsBucket)
+
+	return &TestProvider{Provider: p, Fake: f}
+}
+
+// AddRack seeds the stack of the rack tagged as the installer tags it, returning its id
+func (t *TestProvider) AddRack(s Stack) string {
+	s.Name = t.Rack
+	s.Tags = mergeTags(map[string]string{"System": "convox", "Type": "rack"}, s.Tags)
+
+	return t.Fake.CloudFormation.AddStack(s)
+}
+
+// AddApp seeds the stack of an app of the rack tagged as AppCreate tags it for generation, returning its id
+// The bucket of a Settings resource is created so env and release objects can be put into it
+func (t *TestProvider) AddApp(name, generation string, s Stack) string {
+	s.Name = fmt.Sprintf("%s-%s", t.Rack, name)
+	s.Tags = mergeTags(map[string]string{"Generation": generation, "Name": name, "Rack": t.Rack, "System": "convox", "Type": "app"}, s.Tags)
+
+	for _, r := range s.Resources {
+		if r.LogicalId == "Settings" && r.PhysicalId != "" {
+			t.Fake.S3.CreateBucket(r.PhysicalId)
+		}
+	}
+
+	return t.Fake.CloudFormation.AddStack(s)
+}
+
+func mergeTags(tags, extra map[string]string) map[string]string {
+	for k, v := range extra {
+		tags[k] = v
+	}
+
+	return tags
+}
+
+// Clock is a manually advanced clock shared by the fakes
+type Clock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+// NewClock returns a Clock set to t
+func NewClock(t time.Time) *Clock {
+	return &Clock{now: t}
+}
+
+// Now returns the current fake time
+func (c *Clock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+// Advance moves the fake time forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// formList returns the values of a query protocol list parameter like Names.member.1, Names.member.2
+func formList(form url.Values, name string) []string {
+	vs := []string{}
+
+	for i := 1; form.Get(fmt.Sprintf("%s.member.%d", name, i)) != ""; i++ {
+		vs = append(vs, form.Get(fmt.Sprintf("%s.member.%d", name, i)))
+	}
+
+	return vs
+}
+
+// writeJSON writes v in the sdk json protocol, json.RawMessage values are written as is
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, ok := v.(json.RawMessage)
+
+	if !ok {
+		d, err := jsonutil.BuildJSON(v)
+		if err != nil {
+			writeJSONError(w, 500, "InternalFailure", err.Error())
+			return
+		}
+
+		data = d
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.Write(data)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"__type":%q,"message":%q}`, code, message)
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "text/xml")
+
+	e := xml.NewEncoder(w)
+
+	if err := xmlutil.BuildXML(v, e); err != nil {
+		writeQueryError(w, 500, "InternalFailure", err.Error())
+		return
+	}
+
+	e.Flush()
+}
+
+func writeQueryError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+
+	fmt.Fprintf(w, "<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>%s</Message></Error><RequestId>fake</RequestId></ErrorResponse>", code, xmlEscape(message))
+}
+
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
@@ -0,0 +1,329 @@
+package awsfake_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTemplate = `{
+	"Parameters": {
+		"Count": { "Type": "Number", "Default": "1" },
+		"Release": { "Type": "String" }
+	},
+	"Resources": {
+		"Balancer": { "Type": "AWS::ElasticLoadBalancing::LoadBalancer" },
+		"Settings": { "Type": "AWS::S3::Bucket" }
+	}
+}`
+
+func TestCloudFormationLifecycle(t *testing.T) {
+	f := awsfake.New()
+	defer f.Close()
+
+	f.CloudFormation.Delay = 1 * time.Minute
+
+	cf := cloudformation.New(session.New(), f.Config())
+
+	_, err := cf.CreateStack(&cloudformation.CreateStackInput{
+		StackName:    aws.String("convox-httpd"),
+		TemplateBody: aws.String(testTemplate),
+		Parameters: []*cloudformation.Parameter{
+			{ParameterKey: aws.String("Release"), ParameterValue: aws.String("R1")},
+		},
+		Tags: []*cloudformation.Tag{
+			{Key: aws.String("Name"), Value: aws.String("httpd")},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "CREATE_IN_PROGRESS", describeStatus(t, cf, "convox-httpd"))
+
+	f.Clock.Advance(1 * time.Minute)
+
+	res, err := cf.DescribeStacks(&cloudformation.DescribeStacksInput{StackName: aws.String("convox-httpd")})
+	require.NoError(t, err)
+	require.Len(t, res.Stacks, 1)
+	assert.Equal(t, "CREATE_COMPLETE", *res.Stacks[0].StackStatus)
+	assert.Equal(t, []*cloudformation.Parameter{
+		{ParameterKey: aws.String("Count"), ParameterValue: aws.String("1")},
+		{ParameterKey: aws.String("Release"), ParameterValue: aws.String("R1")},
+	}, res.Stacks[0].Parameters)
+	assert.Equal(t, []*cloudformation.Tag{{Key: aws.String("Name"), Value: aws.String("httpd")}}, res.Stacks[0].Tags)
+
+	unchanged := &cloudformation.UpdateStackInput{
+		StackName:           aws.String("convox-httpd"),
+		UsePreviousTemplate: aws.Bool(true),
+		Parameters: []*cloudformation.Parameter{
+			{ParameterKey: aws.String("Count"), UsePreviousValue: aws.Bool(true)},
+			{ParameterKey: aws.String("Release"), UsePreviousValue: aws.Bool(true)},
+		},
+	}
+
+	_, err = cf.UpdateStack(unchanged)
+	require.Error(t, err)
+	assert.Equal(t, "No updates are to be performed.", err.(awserr.Error).Message())
+
+	_, err = cf.UpdateStack(&cloudformation.UpdateStackInput{
+		StackName:           aws.String("convox-httpd"),
+		UsePreviousTemplate: aws.Bool(true),
+		Parameters: []*cloudformation.Parameter{
+			{ParameterKey: aws.String("Count"), UsePreviousValue: aws.Bool(true)},
+			{ParameterKey: aws.String("Release"), ParameterValue: aws.String("R2")},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "UPDATE_IN_PROGRESS", describeStatus(t, cf, "convox-httpd"))
+
+	_, err = cf.UpdateStack(unchanged)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is in UPDATE_IN_PROGRESS state and can not be updated")
+
+	f.Clock.Advance(1 * time.Minute)
+
+	assert.Equal(t, "UPDATE_COMPLETE", describeStatus(t, cf, "convox-httpd"))
+
+	s, ok := f.CloudFormation.Stack("convox-httpd")
+	require.True(t, ok)
+	assert.Equal(t, "R2", s.Parameters["Release"])
+
+	events, err := cf.DescribeStackEvents(&cloudformation.DescribeStackEventsInput{StackName: aws.String("convox-httpd")})
+	require.NoError(t, err)
+
+	statuses := []string{}
+
+	for _, e := range events.StackEvents {
+		statuses = append(statuses, *e.ResourceStatus)
+	}
+
+	assert.Equal(t, []string{"UPDATE_COMPLETE", "UPDATE_IN_PROGRESS", "CREATE_COMPLETE", "CREATE_IN_PROGRESS"}, statuses)
+
+	rs, err := cf.ListStackResources(&cloudformation.ListStackResourcesInput{StackName: aws.String("convox-httpd")})
+	require.NoError(t, err)
+	require.Len(t, rs.StackResourceSummaries, 2)
+	assert.Equal(t, "Balancer", *rs.StackResourceSummaries[0].LogicalResourceId)
+	assert.Equal(t, "convox-httpd-Balancer", *rs.StackResourceSummaries[0].PhysicalResourceId)
+
+	_, err = cf.DeleteStack(&cloudformation.DeleteStackInput{StackName: aws.String("convox-httpd")})
+	require.NoError(t, err)
+
+	assert.Equal(t, "DELETE_IN_PROGRESS", describeStatus(t, cf, "convox-httpd"))
+
+	f.Clock.Advance(1 * time.Minute)
+
+	_, err = cf.DescribeStacks(&cloudformation.DescribeStacksInput{StackName: aws.String("convox-httpd")})
+	require.Error(t, err)
+	assert.Equal(t, "ValidationError", err.(awserr.Error).Code())
+	assert.Equal(t, "Stack with id convox-httpd does not exist", err.(awserr.Error).Message())
+}
+
+func TestCloudFormationTemplateURL(t *testing.T) {
+	f := awsfake.New()
+	defer f.Close()
+
+	f.S3.PutObject("convox-settings", "templates/app.json", []byte(testTemplate), nil)
+
+	cf := cloudformation.New(session.New(), f.Config())
+
+	_, err := cf.CreateStack(&cloudformation.CreateStackInput{
+		StackName:   aws.String("convox-httpd"),
+		TemplateURL: aws.String("https://s3.us-test-1.amazonaws.com/convox-settings/templates/app.json"),
+	})
+	require.NoError(t, err)
+
+	res, err := cf.GetTemplate(&cloudformation.GetTemplateInput{StackName: aws.String("convox-httpd")})
+	require.NoError(t, err)
+	assert.Equal(t, testTemplate, *res.TemplateBody)
+
+	_, err = cf.CreateStack(&cloudformation.CreateStackInput{
+		StackName:   aws.String("convox-missing"),
+		TemplateURL: aws.String("https://s3.us-test-1.amazonaws.com/convox-settings/templates/missing.json"),
+	})
+	require.Error(t, err)
+	assert.Equal(t, "ValidationError", err.(awserr.Error).Code())
+}
+
+func TestS3Objects(t *testing.T) {
+	f := awsfake.New()
+	defer f.Close()
+
+	f.S3.CreateBucket("convox-settings")
+
+	s := s3.New(session.New(), f.Config())
+
+	_, err := s.PutObject(&s3.PutObjectInput{
+		Body:     bytes.NewReader([]byte("hello")),
+		Bucket:   aws.String("convox-settings"),
+		Key:      aws.String("app/httpd/env"),
+		Metadata: map[string]*string{"Release": aws.String("R1")},
+	})
+	require.NoError(t, err)
+
+	res, err := s.GetObject(&s3.GetObjectInput{Bucket: aws.String("convox-settings"), Key: aws.String("app/httpd/env")})
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	assert.Equal(t, "R1", aws.StringValue(res.Metadata["Release"]))
+
+	meta, ok := f.S3.Metadata("convox-settings", "app/httpd/env")
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"release": "R1"}, meta)
+
+	_, err = s.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String("convox-settings"), Key: aws.String("app/httpd/env")})
+	require.NoError(t, err)
+
+	_, err = s.GetObject(&s3.GetObjectInput{Bucket: aws.String("convox-settings"), Key: aws.String("app/httpd/env")})
+	require.Error(t, err)
+	assert.Equal(t, "NoSuchKey", err.(awserr.Error).Code())
+
+	_, err = s.HeadObject(&s3.HeadObjectInput{Bucket: aws.String("convox-settings"), Key: aws.String("app/httpd/env")})
+	require.Error(t, err)
+	assert.Equal(t, 404, err.(awserr.RequestFailure).StatusCode())
+
+	_, err = s.GetObject(&s3.GetObjectInput{Bucket: aws.String("missing"), Key: aws.String("key")})
+	require.Error(t, err)
+	assert.Equal(t, "NoSuchBucket", err.(awserr.Error).Code())
+}
+
+func TestECSTasks(t *testing.T) {
+	f := awsfake.New()
+	defer f.Close()
+
+	f.ECS.AddCluster("cluster-test")
+
+	require.NoError(t, f.ECS.AddTask("cluster-test", &ecs.Task{
+		DesiredStatus:     aws.String("RUNNING"),
+		Group:             aws.String("service:web"),
+		LastStatus:        aws.String("RUNNING"),
+		TaskArn:           aws.String("arn:aws:ecs:us-test-1:123456789012:task/cluster-test/web1"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-web:3"),
+	}))
+
+	require.NoError(t, f.ECS.AddTask("cluster-test", &ecs.Task{
+		DesiredStatus: aws.String("RUNNING"),
+		LastStatus:    aws.String("PENDING"),
+		StartedBy:     aws.String("convox"),
+		TaskArn:       aws.String("arn:aws:ecs:us-test-1:123456789012:task/cluster-test/run1"),
+	}))
+
+	e := ecs.New(session.New(), f.Config())
+
+	lres, err := e.ListTasks(&ecs.ListTasksInput{Cluster: aws.String("cluster-test"), ServiceName: aws.String("web")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"arn:aws:ecs:us-test-1:123456789012:task/cluster-test/web1"}, aws.StringValueSlice(lres.TaskArns))
+
+	lres, err = e.ListTasks(&ecs.ListTasksInput{Cluster: aws.String("cluster-test"), StartedBy: aws.String("convox")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"arn:aws:ecs:us-test-1:123456789012:task/cluster-test/run1"}, aws.StringValueSlice(lres.TaskArns))
+
+	dres, err := e.DescribeTasks(&ecs.DescribeTasksInput{Cluster: aws.String("cluster-test"), Tasks: aws.StringSlice([]string{"run1", "missing"})})
+	require.NoError(t, err)
+	require.Len(t, dres.Tasks, 1)
+	assert.Equal(t, "PENDING", *dres.Tasks[0].LastStatus)
+	require.Len(t, dres.Failures, 1)
+	assert.Equal(t, "MISSING", *dres.Failures[0].Reason)
+
+	require.NoError(t, f.ECS.SetTaskStatus("run1", "STOPPED"))
+
+	lres, err = e.ListTasks(&ecs.ListTasksInput{Cluster: aws.String("cluster-test"), DesiredStatus: aws.String("STOPPED")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"arn:aws:ecs:us-test-1:123456789012:task/cluster-test/run1"}, aws.StringValueSlice(lres.TaskArns))
+
+	_, err = e.DescribeTasks(&ecs.DescribeTasksInput{Cluster: aws.String("missing"), Tasks: aws.StringSlice([]string{"run1"})})
+	require.Error(t, err)
+	assert.Equal(t, "ClusterNotFoundException", err.(awserr.Error).Code())
+}
+
+func TestDynamoDBItems(t *testing.T) {
+	f := awsfake.New()
+	defer f.Close()
+
+	f.DynamoDB.AddTable(awsfake.Table{
+		Name:    "convox-releases",
+		HashKey: "id",
+		Indexes: map[string]awsfake.Index{"app.created": {HashKey: "app", RangeKey: "created"}},
+	})
+
+	d := dynamodb.New(session.New(), f.Config())
+
+	for _, r := range [][3]string{{"R1", "httpd", "20200101"}, {"R2", "httpd", "20200102"}, {"R3", "other", "20200103"}} {
+		_, err := d.PutItem(&dynamodb.PutItemInput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"id":      {S: aws.String(r[0])},
+				"app":     {S: aws.String(r[1])},
+				"created": {S: aws.String(r[2])},
+			},
+			TableName: aws.String("convox-releases"),
+		})
+		require.NoError(t, err)
+	}
+
+	gres, err := d.GetItem(&dynamodb.GetItemInput{Key: map[string]*dynamodb.AttributeValue{"id": {S: aws.String("R2")}}, TableName: aws.String("convox-releases")})
+	require.NoError(t, err)
+	assert.Equal(t, "httpd", *gres.Item["app"].S)
+
+	gres, err = d.GetItem(&dynamodb.GetItemInput{Key: map[string]*dynamodb.AttributeValue{"id": {S: aws.String("R9")}}, TableName: aws.String("convox-releases")})
+	require.NoError(t, err)
+	assert.Nil(t, gres.Item)
+
+	qres, err := d.Query(&dynamodb.QueryInput{
+		KeyConditions: map[string]*dynamodb.Condition{
+			"app": {AttributeValueList: []*dynamodb.AttributeValue{{S: aws.String("httpd")}}, ComparisonOperator: aws.String("EQ")},
+		},
+		IndexName:        aws.String("app.created"),
+		ScanIndexForward: aws.Bool(false),
+		TableName:        aws.String("convox-releases"),
+	})
+	require.NoError(t, err)
+	require.Len(t, qres.Items, 2)
+	assert.Equal(t, "R2", *qres.Items[0]["id"].S)
+	assert.Equal(t, "R1", *qres.Items[1]["id"].S)
+
+	_, err = d.GetItem(&dynamodb.GetItemInput{Key: map[string]*dynamodb.AttributeValue{"id": {S: aws.String("R1")}}, TableName: aws.String("missing")})
+	require.Error(t, err)
+	assert.Equal(t, "ResourceNotFoundException", err.(awserr.Error).Code())
+}
+
+func TestNewTestProvider(t *testing.T) {
+	p := awsfake.NewTestProvider()
+	defer p.Close()
+
+	assert.True(t, p.IsTest())
+	assert.True(t, p.SkipCache)
+
+	p.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name:       "convox-httpd",
+		Outputs:    map[string]string{"Release": "R1"},
+		Parameters: map[string]string{"Release": "R1"},
+		Tags:       map[string]string{"Name": "httpd", "Rack": "convox", "System": "convox", "Type": "app"},
+	})
+
+	a, err := p.AppGet("httpd")
+	require.NoError(t, err)
+	assert.Equal(t, "httpd", a.Name)
+	assert.Equal(t, "R1", a.Release)
+	assert.Equal(t, "running", a.Status)
+}
+
+func describeStatus(t *testing.T, cf *cloudformation.CloudFormation, name string) string {
+	res, err := cf.DescribeStacks(&cloudformation.DescribeStacksInput{StackName: aws.String(name)})
+	require.NoError(t, err)
+	require.Len(t, res.Stacks, 1)
+
+	return *res.Stacks[0].StackStatus
+}
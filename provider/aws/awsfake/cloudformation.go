@@ -0,0 +1,788 @@
+package awsfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// Stack describes a stack to seed into the CloudFormation fake, it is also used for snapshots of fake stacks
+type Stack struct {
+	Name             string
+	NotificationARNs []string
+	Outputs          map[string]string
+	Parameters       map[string]string
+	Resources        []Resource
+	Status           string
+	Tags             map[string]string
+	Template         string
+
+	// TemplateURL is the url the last create or update read the template from, empty for inline bodies
+	TemplateURL string
+}
+
+// Resource is a resource belonging to a fake stack
+type Resource struct {
+	LogicalId  string
+	PhysicalId string
+	Type       string
+}
+
+// CloudFormation is an in-memory CloudFormation that tracks stacks, parameters and events
+//
+// Create, update and delete operations stay in progress for Delay of fake time and complete the
+// next time the stack is read after the Clock has been advanced past that point
+type CloudFormation struct {
+	Delay time.Duration
+
+	clock  *Clock
+	count  int
+	lock   sync.Mutex
+	s3     *S3
+	stacks []*fakeStack
+}
+
+// templateBodyLimit is the largest template cloudformation accepts inline
+const templateBodyLimit = 51200
+
+type fakeStack struct {
+	created       time.Time
+	events        []*cloudformation.StackEvent
+	id            string
+	name          string
+	notifications []string
+	outputs       map[string]string
+	parameters    map[string]string
+	pending       string
+	previous      *fakeStack
+	resources     []Resource
+	status        string
+	tags          map[string]string
+	template      string
+	templateURL   string
+	token         string
+	until         time.Time
+	updated       time.Time
+}
+
+// AddStack seeds a stack that already exists, returning its id
+// Status defaults to CREATE_COMPLETE
+func (c *CloudFormation) AddStack(s Stack) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fs := c.newStack(s.Name)
+
+	fs.notifications = append([]string{}, s.NotificationARNs...)
+	fs.outputs = copyMap(s.Outputs)
+	fs.parameters = copyMap(s.Parameters)
+	fs.tags = copyMap(s.Tags)
+	fs.template = s.Template
+	fs.resources = append([]Resource{}, s.Resources...)
+
+	if len(fs.resources) == 0 {
+		_, fs.resources, _ = parseTemplate(s.Template, fs.name)
+	}
+
+	fs.setStatus(coalesce(s.Status, cloudformation.StackStatusCreateComplete), c.clock.Now())
+
+	return fs.id
+}
+
+// SetOutputs replaces the outputs of a stack, templates are not evaluated so outputs must be seeded
+func (c *CloudFormation) SetOutputs(name string, outputs map[string]string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fs, err := c.find(name)
+	if err != nil {
+		return err
+	}
+
+	fs.outputs = copyMap(outputs)
+
+	return nil
+}
+
+// Stacks returns the names of all stacks in the order they were created
+func (c *CloudFormation) Stacks() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	names := []string{}
+
+	for _, fs := range c.stacks {
+		names = append(names, fs.name)
+	}
+
+	return names
+}
+
+// Stack returns a snapshot of a stack by name or id
+func (c *CloudFormation) Stack(name string) (Stack, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fs, err := c.find(name)
+	if err != nil {
+		return Stack{}, false
+	}
+
+	s := Stack{
+		Name:             fs.name,
+		NotificationARNs: append([]string{}, fs.notifications...),
+		Outputs:          copyMap(fs.outputs),
+		Parameters:       copyMap(fs.parameters),
+		Resources:        append([]Resource{}, fs.resources...),
+		Status:           fs.status,
+		Tags:             copyMap(fs.tags),
+		Template:         fs.template,
+		TemplateURL:      fs.templateURL,
+	}
+
+	return s, true
+}
+
+func (c *CloudFormation) serve(w http.ResponseWriter, action string, form url.Values) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var res interface{}
+	var err error
+
+	switch action {
+	case "CancelUpdateStack":
+		res, err = c.cancelUpdateStack(form)
+	case "CreateStack":
+		res, err = c.createStack(form)
+	case "DeleteStack":
+		res, err = c.deleteStack(form)
+	case "DescribeStackEvents":
+		res, err = c.describeStackEvents(form)
+	case "DescribeStackResource":
+		res, err = c.describeStackResource(form)
+	case "DescribeStackResources":
+		res, err = c.describeStackResources(form)
+	case "DescribeStacks":
+		res, err = c.describeStacks(form)
+	case "GetTemplate":
+		res, err = c.getTemplate(form)
+	case "ListStackResources":
+		res, err = c.listStackResources(form)
+	case "UpdateStack":
+		res, err = c.updateStack(form)
+	default:
+		err = cfError{"InvalidAction", fmt.Sprintf("unsupported action: %s", action)}
+	}
+
+	if err != nil {
+		e, ok := err.(cfError)
+		if !ok {
+			e = cfError{"InternalFailure", err.Error()}
+		}
+
+		writeQueryError(w, 400, e.code, e.message)
+		return
+	}
+
+	writeXML(w, res)
+}
+
+type cfError struct {
+	code    string
+	message string
+}
+
+func (e cfError) Error() string {
+	return e.message
+}
+
+func stackNotFound(name string) error {
+	return cfError{"ValidationError", fmt.Sprintf("Stack with id %s does not exist", name)}
+}
+
+func (c *CloudFormation) newStack(name string) *fakeStack {
+	c.count++
+
+	now := c.clock.Now()
+
+	fs := &fakeStack{
+		created:    now,
+		id:         fmt.Sprintf("arn:aws:cloudformation:%s:123456789012:stack/%s/%08d-0000-0000-0000-000000000000", Region, name, c.count),
+		name:       name,
+		outputs:    map[string]string{},
+		parameters: map[string]string{},
+		tags:       map[string]string{},
+	}
+
+	c.stacks = append(c.stacks, fs)
+
+	return fs
+}
+
+// find looks up a stack by name or id, deleted stacks can only be found by id
+func (c *CloudFormation) find(name string) (*fakeStack, error) {
+	now := c.clock.Now()
+
+	for _, fs := range c.stacks {
+		fs.resolve(now)
+
+		if fs.id == name || (fs.name == name && fs.status != cloudformation.StackStatusDeleteComplete) {
+			return fs, nil
+		}
+	}
+
+	return nil, stackNotFound(name)
+}
+
+func (c *CloudFormation) template(form url.Values, previous string) (string, error) {
+	if body := form.Get("TemplateBody"); body != "" {
+		if len(body) > templateBodyLimit {
+			return "", cfError{"ValidationError", fmt.Sprintf("Member must have length less than or equal to %d", templateBodyLimit)}
+		}
+
+		return body, nil
+	}
+
+	if tu := form.Get("TemplateURL"); tu != "" {
+		u, err := url.Parse(tu)
+		if err != nil {
+			return "", cfError{"ValidationError", "TemplateURL must be a supported URL."}
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+
+		if len(parts) == 2 {
+			if data, ok := c.s3.Object(parts[0], parts[1]); ok {
+				return string(data), nil
+			}
+		}
+
+		return "", cfError{"ValidationError", fmt.Sprintf("Template could not be loaded from %s", tu)}
+	}
+
+	if form.Get("UsePreviousTemplate") == "true" {
+		return previous, nil
+	}
+
+	return "", cfError{"ValidationError", "Either Template URL or Template Body must be specified."}
+}
+
+func (c *CloudFormation) createStack(form url.Values) (interface{}, error) {
+	name := form.Get("StackName")
+
+	if _, err := c.find(name); err == nil {
+		return nil, cfError{"AlreadyExistsException", fmt.Sprintf("Stack [%s] already exists", name)}
+	}
+
+	template, err := c.template(form, "")
+	if err != nil {
+		return nil, err
+	}
+
+	defaults, resources, err := parseTemplate(template, name)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications, err := formNotifications(form)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := c.newStack(name)
+
+	fs.notifications = notifications
+	fs.template = template
+	fs.templateURL = form.Get("TemplateURL")
+	fs.resources = resources
+	fs.parameters = resolveParameters(form, defaults, nil)
+	fs.tags = formTags(form)
+	fs.token = form.Get("ClientRequestToken")
+
+	c.transition(fs, cloudformation.StackStatusCreateInProgress, cloudformation.StackStatusCreateComplete)
+
+	return &struct {
+		_      struct{}                          `locationName:"CreateStackResponse"`
+		Result *cloudformation.CreateStackOutput `locationName:"CreateStackResult"`
+	}{Result: &cloudformation.CreateStackOutput{StackId: aws.String(fs.id)}}, nil
+}
+
+func (c *CloudFormation) updateStack(form url.Values) (interface{}, error) {
+	fs, err := c.find(form.Get("StackName"))
+	if err != nil {
+		return nil, err
+	}
+
+	if fs.pending != "" {
+		return nil, cfError{"ValidationError", fmt.Sprintf("Stack:%s is in %s state and can not be updated.", fs.id, fs.status)}
+	}
+
+	template, err := c.template(form, fs.template)
+	if err != nil {
+		return nil, err
+	}
+
+	defaults, resources, err := parseTemplate(template, fs.name)
+	if err != nil {
+		return nil, err
+	}
+
+	params := resolveParameters(form, defaults, fs.parameters)
+
+	notifications, err := formNotifications(form)
+	if err != nil {
+		return nil, err
+	}
+
+	// updates that leave out notification arns keep the ones the stack has
+	if len(notifications) == 0 {
+		notifications = fs.notifications
+	}
+
+	tags := fs.tags
+
+	if len(formMembers(form, "Tags")) > 0 {
+		tags = formTags(form)
+	}
+
+	if template == fs.template && reflect.DeepEqual(params, fs.parameters) && reflect.DeepEqual(tags, fs.tags) {
+		return nil, cfError{"ValidationError", "No updates are to be performed."}
+	}
+
+	fs.previous = &fakeStack{parameters: fs.parameters, resources: fs.resources, tags: fs.tags, template: fs.template}
+
+	fs.notifications = notifications
+	fs.template = template
+	fs.templateURL = form.Get("TemplateURL")
+	fs.resources = resources
+	fs.parameters = params
+	fs.tags = tags
+	fs.token = form.Get("ClientRequestToken")
+	fs.updated = c.clock.Now()
+
+	c.transition(fs, cloudformation.StackStatusUpdateInProgress, cloudformation.StackStatusUpdateComplete)
+
+	return &struct {
+		_      struct{}                          `locationName:"UpdateStackResponse"`
+		Result *cloudformation.UpdateStackOutput `locationName:"UpdateStackResult"`
+	}{Result: &cloudformation.UpdateStackOutput{StackId: aws.String(fs.id)}}, nil
+}
+
+// cancelUpdateStack rolls an in progress update back to the previous template, parameters and tags
+func (c *CloudFormation) cancelUpdateStack(form url.Values) (interface{}, error) {
+	fs, err := c.find(form.Get("StackName"))
+	if err != nil {
+		return nil, err
+	}
+
+	if fs.status != cloudformation.StackStatusUpdateInProgress {
+		return nil, cfError{"ValidationError", fmt.Sprintf("CancelUpdateStack cannot be called from current stack status %s", fs.status)}
+	}
+
+	if p := fs.previous; p != nil {
+		fs.parameters = p.parameters
+		fs.resources = p.resources
+		fs.tags = p.tags
+		fs.template = p.template
+	}
+
+	c.transition(fs, cloudformation.StackStatusUpdateRollbackInProgress, cloudformation.StackStatusUpdateRollbackComplete)
+
+	return &struct {
+		_      struct{}                                `locationName:"CancelUpdateStackResponse"`
+		Result *cloudformation.CancelUpdateStackOutput `locationName:"CancelUpdateStackResult"`
+	}{Result: &cloudformation.CancelUpdateStackOutput{}}, nil
+}
+
+func (c *CloudFormation) deleteStack(form url.Values) (interface{}, error) {
+	// deleting a stack that does not exist succeeds
+	if fs, err := c.find(form.Get("StackName")); err == nil && fs.status != cloudformation.StackStatusDeleteComplete {
+		c.transition(fs, cloudformation.StackStatusDeleteInProgress, cloudformation.StackStatusDeleteComplete)
+	}
+
+	return &struct {
+		_      struct{}                          `locationName:"DeleteStackResponse"`
+		Result *cloudformation.DeleteStackOutput `locationName:"DeleteStackResult"`
+	}{Result: &cloudformation.DeleteStackOutput{}}, nil
+}
+
+func (c *CloudFormation) describeStacks(form url.Values) (interface{}, error) {
+	stacks := []*cloudformation.Stack{}
+
+	if name := form.Get("StackName"); name != "" {
+		fs, err := c.find(name)
+		if err != nil {
+			return nil, err
+		}
+
+		stacks = append(stacks, fs.describe())
+	} else {
+		now := c.clock.Now()
+
+		for _, fs := range c.stacks {
+			fs.resolve(now)
+
+			if fs.status != cloudformation.StackStatusDeleteComplete {
+				stacks = append(stacks, fs.describe())
+			}
+		}
+	}
+
+	return &struct {
+		_      struct{}                             `locationName:"DescribeStacksResponse"`
+		Result *cloudformation.DescribeStacksOutput `locationName:"DescribeStacksResult"`
+	}{Result: &cloudformation.DescribeStacksOutput{Stacks: stacks}}, nil
+}
+
+func (c *CloudFormation) describeStackEvents(form url.Values) (interface{}, error) {
+	fs, err := c.find(form.Get("StackName"))
+	if err != nil {
+		return nil, err
+	}
+
+	// newest first like the real api
+	events := []*cloudformation.StackEvent{}
+
+	for i := len(fs.events) - 1; i >= 0; i-- {
+		events = append(events, fs.events[i])
+	}
+
+	return &struct {
+		_      struct{}                                  `locationName:"DescribeStackEventsResponse"`
+		Result *cloudformation.DescribeStackEventsOutput `locationName:"DescribeStackEventsResult"`
+	}{Result: &cloudformation.DescribeStackEventsOutput{StackEvents: events}}, nil
+}
+
+func (c *CloudFormation) describeStackResource(form url.Values) (interface{}, error) {
+	fs, err := c.find(form.Get("StackName"))
+	if err != nil {
+		return nil, err
+	}
+
+	logical := form.Get("LogicalResourceId")
+
+	for _, r := range fs.resources {
+		if r.LogicalId == logical {
+			detail := &cloudformation.StackResourceDetail{
+				LastUpdatedTimestamp: aws.Time(fs.lastUpdated()),
+				LogicalResourceId:    aws.String(r.LogicalId),
+				PhysicalResourceId:   aws.String(r.PhysicalId),
+				ResourceStatus:       aws.String(fs.status),
+				ResourceType:         aws.String(r.Type),
+				StackId:              aws.String(fs.id),
+				StackName:            aws.String(fs.name),
+			}
+
+			return &struct {
+				_      struct{}                                    `locationName:"DescribeStackResourceResponse"`
+				Result *cloudformation.DescribeStackResourceOutput `locationName:"DescribeStackResourceResult"`
+			}{Result: &cloudformation.DescribeStackResourceOutput{StackResourceDetail: detail}}, nil
+		}
+	}
+
+	return nil, cfError{"ValidationError", fmt.Sprintf("Resource %s does not exist for stack %s", logical, fs.name)}
+}
+
+func (c *CloudFormation) describeStackResources(form url.Values) (interface{}, error) {
+	fs, err := c.find(form.Get("StackName"))
+	if err != nil {
+		return nil, err
+	}
+
+	resources := []*cloudformation.StackResource{}
+
+	for _, r := range fs.resources {
+		if id := form.Get("LogicalResourceId"); id != "" && id != r.LogicalId {
+			continue
+		}
+
+		resources = append(resources, &cloudformation.StackResource{
+			LogicalResourceId:  aws.String(r.LogicalId),
+			PhysicalResourceId: aws.String(r.PhysicalId),
+			ResourceStatus:     aws.String(fs.status),
+			ResourceType:       aws.String(r.Type),
+			StackId:            aws.String(fs.id),
+			StackName:          aws.String(fs.name),
+			Timestamp:          aws.Time(fs.lastUpdated()),
+		})
+	}
+
+	return &struct {
+		_      struct{}                                     `locationName:"DescribeStackResourcesResponse"`
+		Result *cloudformation.DescribeStackResourcesOutput `locationName:"DescribeStackResourcesResult"`
+	}{Result: &cloudformation.DescribeStackResourcesOutput{StackResources: resources}}, nil
+}
+
+func (c *CloudFormation) listStackResources(form url.Values) (interface{}, error) {
+	fs, err := c.find(form.Get("StackName"))
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := []*cloudformation.StackResourceSummary{}
+
+	for _, r := range fs.resources {
+		summaries = append(summaries, &cloudformation.StackResourceSummary{
+			LastUpdatedTimestamp: aws.Time(fs.lastUpdated()),
+			LogicalResourceId:    aws.String(r.LogicalId),
+			PhysicalResourceId:   aws.String(r.PhysicalId),
+			ResourceStatus:       aws.String(fs.status),
+			ResourceType:         aws.String(r.Type),
+		})
+	}
+
+	return &struct {
+		_      struct{}                                 `locationName:"ListStackResourcesResponse"`
+		Result *cloudformation.ListStackResourcesOutput `locationName:"ListStackResourcesResult"`
+	}{Result: &cloudformation.ListStackResourcesOutput{StackResourceSummaries: summaries}}, nil
+}
+
+func (c *CloudFormation) getTemplate(form url.Values) (interface{}, error) {
+	fs, err := c.find(form.Get("StackName"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &struct {
+		_      struct{}                          `locationName:"GetTemplateResponse"`
+		Result *cloudformation.GetTemplateOutput `locationName:"GetTemplateResult"`
+	}{Result: &cloudformation.GetTemplateOutput{TemplateBody: aws.String(fs.template)}}, nil
+}
+
+// transition puts a stack into an in progress status that completes after Delay
+func (c *CloudFormation) transition(fs *fakeStack, progress, complete string) {
+	now := c.clock.Now()
+
+	fs.setStatus(progress, now)
+	fs.pending = complete
+	fs.until = now.Add(c.Delay)
+}
+
+func (fs *fakeStack) resolve(now time.Time) {
+	if fs.pending != "" && !now.Before(fs.until) {
+		fs.setStatus(fs.pending, fs.until)
+		fs.pending = ""
+	}
+}
+
+func (fs *fakeStack) setStatus(status string, at time.Time) {
+	fs.status = status
+
+	fs.events = append(fs.events, &cloudformation.StackEvent{
+		ClientRequestToken: nonEmpty(fs.token),
+		EventId:            aws.String(fmt.Sprintf("%s-%d", fs.name, len(fs.events)+1)),
+		LogicalResourceId:  aws.String(fs.name),
+		PhysicalResourceId: aws.String(fs.id),
+		ResourceStatus:     aws.String(status),
+		ResourceType:       aws.String("AWS::CloudFormation::Stack"),
+		StackId:            aws.String(fs.id),
+		StackName:          aws.String(fs.name),
+		Timestamp:          aws.Time(at),
+	})
+}
+
+func (fs *fakeStack) lastUpdated() time.Time {
+	if fs.updated.IsZero() {
+		return fs.created
+	}
+
+	return fs.updated
+}
+
+func (fs *fakeStack) describe() *cloudformation.Stack {
+	s := &cloudformation.Stack{
+		CreationTime: aws.Time(fs.created),
+		Outputs:      []*cloudformation.Output{},
+		Parameters:   []*cloudformation.Parameter{},
+		StackId:      aws.String(fs.id),
+		StackName:    aws.String(fs.name),
+		StackStatus:  aws.String(fs.status),
+		Tags:         []*cloudformation.Tag{},
+	}
+
+	if !fs.updated.IsZero() {
+		s.LastUpdatedTime = aws.Time(fs.updated)
+	}
+
+	for _, k := range sortedKeys(fs.outputs) {
+		s.Outputs = append(s.Outputs, &cloudformation.Output{OutputKey: aws.String(k), OutputValue: aws.String(fs.outputs[k])})
+	}
+
+	for _, k := range sortedKeys(fs.parameters) {
+		s.Parameters = append(s.Parameters, &cloudformation.Parameter{ParameterKey: aws.String(k), ParameterValue: aws.String(fs.parameters[k])})
+	}
+
+	for _, k := range sortedKeys(fs.tags) {
+		s.Tags = append(s.Tags, &cloudformation.Tag{Key: aws.String(k), Value: aws.String(fs.tags[k])})
+	}
+
+	return s
+}
+
+// parseTemplate returns the parameter defaults and resources declared in a json template
+// parameters without a default map to nil
+func parseTemplate(body, stack string) (map[string]*string, []Resource, error) {
+	var t struct {
+		Parameters map[string]struct {
+			Default interface{}
+		}
+		Resources map[string]struct {
+			Type string
+		}
+	}
+
+	if body == "" {
+		return map[string]*string{}, []Resource{}, nil
+	}
+
+	if err := json.Unmarshal([]byte(body), &t); err != nil {
+		return nil, nil, cfError{"ValidationError", fmt.Sprintf("Template format error: %s", err)}
+	}
+
+	defaults := map[string]*string{}
+
+	for name, p := range t.Parameters {
+		if p.Default != nil {
+			defaults[name] = aws.String(fmt.Sprintf("%v", p.Default))
+		} else {
+			defaults[name] = nil
+		}
+	}
+
+	resources := []Resource{}
+
+	for _, name := range sortedKeys(t.Resources) {
+		resources = append(resources, Resource{
+			LogicalId:  name,
+			PhysicalId: fmt.Sprintf("%s-%s", stack, name),
+			Type:       t.Resources[name].Type,
+		})
+	}
+
+	return defaults, resources, nil
+}
+
+// resolveParameters applies the parameters of a request to the template defaults and previous values
+func resolveParameters(form url.Values, defaults map[string]*string, previous map[string]string) map[string]string {
+	params := map[string]string{}
+
+	for name, def := range defaults {
+		if def != nil {
+			params[name] = *def
+		}
+	}
+
+	for _, m := range formMembers(form, "Parameters") {
+		if m["UsePreviousValue"] == "true" {
+			if v, ok := previous[m["ParameterKey"]]; ok {
+				params[m["ParameterKey"]] = v
+			}
+			continue
+		}
+
+		params[m["ParameterKey"]] = m["ParameterValue"]
+	}
+
+	return params
+}
+
+func formTags(form url.Values) map[string]string {
+	tags := map[string]string{}
+
+	for _, m := range formMembers(form, "Tags") {
+		tags[m["Key"]] = m["Value"]
+	}
+
+	return tags
+}
+
+// formMembers decodes a query protocol list of structures such as Parameters.member.1.ParameterKey
+// formNotifications returns the notification arns of a create or update, rejecting blank ones the way cloudformation does
+func formNotifications(form url.Values) ([]string, error) {
+	arns := []string{}
+
+	for i := 1; ; i++ {
+		vs, ok := form[fmt.Sprintf("NotificationARNs.member.%d", i)]
+		if !ok {
+			return arns, nil
+		}
+
+		if len(vs) == 0 || vs[0] == "" {
+			return nil, cfError{"ValidationError", "Notification ARN is invalid"}
+		}
+
+		arns = append(arns, vs[0])
+	}
+}
+
+func formMembers(form url.Values, name string) []map[string]string {
+	members := []map[string]string{}
+
+	for i := 1; ; i++ {
+		prefix := fmt.Sprintf("%s.member.%d.", name, i)
+		m := map[string]string{}
+
+		for k := range form {
+			if strings.HasPrefix(k, prefix) {
+				m[strings.TrimPrefix(k, prefix)] = form.Get(k)
+			}
+		}
+
+		if len(m) == 0 {
+			return members
+		}
+
+		members = append(members, m)
+	}
+}
+
+func coalesce(ss ...string) string {
+	for _, s := range ss {
+		if s != "" {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// nonEmpty returns nil for an empty string so optional fields are left out of responses
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return aws.String(s)
+}
+
+func copyMap(m map[string]string) map[string]string {
+	c := map[string]string{}
+
+	for k, v := range m {
+		c[k] = v
+	}
+
+	return c
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,147 @@
+package awsfake
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// cloudwatchActions are the query actions served by the CloudWatch fake rather than CloudFormation
+var cloudwatchActions = map[string]bool{
+	"DescribeAlarms": true,
+	"GetMetricData":  true,
+}
+
+// CloudWatch is an in-memory CloudWatch holding metric alarms and metric data
+type CloudWatch struct {
+	alarms  []*cloudwatch.MetricAlarm
+	calls   []int
+	lock    sync.Mutex
+	metrics map[string][]float64
+}
+
+// AddAlarm adds a metric alarm with the given name in the OK state
+func (c *CloudWatch) AddAlarm(name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.alarms = append(c.alarms, &cloudwatch.MetricAlarm{
+		AlarmArn:   aws.String(fmt.Sprintf("arn:aws:cloudwatch:%s:123456789012:alarm:%s", Region, name)),
+		AlarmName:  aws.String(name),
+		StateValue: aws.String(cloudwatch.StateValueOk),
+	})
+}
+
+// AddMetricData sets the datapoints returned for a metric, any stat or period returns the same values
+func (c *CloudWatch) AddMetricData(namespace, name string, dimensions map[string]string, values ...float64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.metrics == nil {
+		c.metrics = map[string][]float64{}
+	}
+
+	c.metrics[metricKey(namespace, name, dimensions)] = values
+}
+
+// MetricDataCalls returns the number of queries in each GetMetricData call received
+func (c *CloudWatch) MetricDataCalls() []int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]int{}, c.calls...)
+}
+
+func (c *CloudWatch) serve(action string, form url.Values) (interface{}, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	switch action {
+	case "DescribeAlarms":
+		return c.describeAlarms(form)
+	case "GetMetricData":
+		return c.getMetricData(form)
+	}
+
+	return nil, cfError{"InvalidAction", fmt.Sprintf("unsupported action: %s", action)}
+}
+
+func (c *CloudWatch) describeAlarms(form url.Values) (interface{}, error) {
+	names := map[string]bool{}
+
+	for _, n := range formList(form, "AlarmNames") {
+		names[n] = true
+	}
+
+	res := &cloudwatch.DescribeAlarmsOutput{MetricAlarms: []*cloudwatch.MetricAlarm{}}
+
+	for _, a := range c.alarms {
+		if len(names) == 0 || names[aws.StringValue(a.AlarmName)] {
+			res.MetricAlarms = append(res.MetricAlarms, a)
+		}
+	}
+
+	return &struct {
+		_      struct{}                         `locationName:"DescribeAlarmsResponse"`
+		Result *cloudwatch.DescribeAlarmsOutput `locationName:"DescribeAlarmsResult"`
+	}{Result: res}, nil
+}
+
+func (c *CloudWatch) getMetricData(form url.Values) (interface{}, error) {
+	res := &cloudwatch.GetMetricDataOutput{MetricDataResults: []*cloudwatch.MetricDataResult{}}
+
+	n := 0
+
+	for i := 1; form.Get(fmt.Sprintf("MetricDataQueries.member.%d.Id", i)) != ""; i++ {
+		prefix := fmt.Sprintf("MetricDataQueries.member.%d", i)
+
+		dimensions := map[string]string{}
+
+		for j := 1; form.Get(fmt.Sprintf("%s.MetricStat.Metric.Dimensions.member.%d.Name", prefix, j)) != ""; j++ {
+			dp := fmt.Sprintf("%s.MetricStat.Metric.Dimensions.member.%d", prefix, j)
+			dimensions[form.Get(dp+".Name")] = form.Get(dp + ".Value")
+		}
+
+		key := metricKey(form.Get(prefix+".MetricStat.Metric.Namespace"), form.Get(prefix+".MetricStat.Metric.MetricName"), dimensions)
+
+		r := &cloudwatch.MetricDataResult{
+			Id:         aws.String(form.Get(prefix + ".Id")),
+			Label:      aws.String(form.Get(prefix + ".MetricStat.Metric.MetricName")),
+			StatusCode: aws.String(cloudwatch.StatusCodeComplete),
+			Values:     []*float64{},
+		}
+
+		// timestamps are left out as xmlutil writes lists of them as empty elements
+		for _, v := range c.metrics[key] {
+			r.Values = append(r.Values, aws.Float64(v))
+		}
+
+		res.MetricDataResults = append(res.MetricDataResults, r)
+
+		n++
+	}
+
+	c.calls = append(c.calls, n)
+
+	return &struct {
+		_      struct{}                        `locationName:"GetMetricDataResponse"`
+		Result *cloudwatch.GetMetricDataOutput `locationName:"GetMetricDataResult"`
+	}{Result: res}, nil
+}
+
+func metricKey(namespace, name string, dimensions map[string]string) string {
+	ds := []string{}
+
+	for k, v := range dimensions {
+		ds = append(ds, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	sort.Strings(ds)
+
+	return fmt.Sprintf("%s/%s/%s", namespace, name, strings.Join(ds, ","))
+}
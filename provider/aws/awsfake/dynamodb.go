@@ -0,0 +1,518 @@
+package awsfake
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Table describes the keys of a fake DynamoDB table
+type Table struct {
+	Name    string
+	HashKey string
+	Indexes map[string]Index
+}
+
+// Index is a global secondary index with string hash and range keys
+type Index struct {
+	HashKey  string
+	RangeKey string
+}
+
+// DynamoDB is an in-memory DynamoDB with string keyed tables
+// Tables must be added before use, items are stored as the attribute values they were put with
+type DynamoDB struct {
+	failConditions int
+	lock           sync.Mutex
+	tables         map[string]*fakeTable
+	updates        []*dynamodb.UpdateItemInput
+}
+
+type fakeTable struct {
+	items map[string]map[string]*dynamodb.AttributeValue
+	table Table
+}
+
+// AddTable creates an empty table
+func (d *DynamoDB) AddTable(t Table) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.tables == nil {
+		d.tables = map[string]*fakeTable{}
+	}
+
+	d.tables[t.Name] = &fakeTable{items: map[string]map[string]*dynamodb.AttributeValue{}, table: t}
+}
+
+// Item returns the item with a hash key of key
+func (d *DynamoDB) Item(table, key string) (map[string]*dynamodb.AttributeValue, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	t, ok := d.tables[table]
+	if !ok {
+		return nil, false
+	}
+
+	item, ok := t.items[key]
+
+	return item, ok
+}
+
+// PutItem stores an item keyed by its hash key attribute
+func (d *DynamoDB) PutItem(table string, item map[string]*dynamodb.AttributeValue) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if t, ok := d.tables[table]; ok {
+		t.items[aws.StringValue(item[t.table.HashKey].S)] = item
+	}
+}
+
+// Items returns the items of a table in no particular order
+func (d *DynamoDB) Items(table string) []map[string]*dynamodb.AttributeValue {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	items := []map[string]*dynamodb.AttributeValue{}
+
+	if t, ok := d.tables[table]; ok {
+		for _, item := range t.items {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// FailConditions makes the next n conditional UpdateItem requests fail their condition as if another writer got there first
+func (d *DynamoDB) FailConditions(n int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.failConditions = n
+}
+
+// Updates returns the UpdateItem requests received so far
+func (d *DynamoDB) Updates() []*dynamodb.UpdateItemInput {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return append([]*dynamodb.UpdateItemInput{}, d.updates...)
+}
+
+func (d *DynamoDB) serve(w http.ResponseWriter, r *http.Request, operation string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	var res interface{}
+	var err error
+
+	switch operation {
+	case "CreateTable":
+		res, err = d.createTable(r)
+	case "DescribeTable":
+		res, err = d.describeTable(r)
+	case "GetItem":
+		res, err = d.getItem(r)
+	case "PutItem":
+		res, err = d.putItem(r)
+	case "Query":
+		res, err = d.query(r)
+	case "UpdateItem":
+		res, err = d.updateItem(r)
+	default:
+		err = dynamoError{"UnknownOperationException", fmt.Sprintf("unsupported operation: %s", operation)}
+	}
+
+	if err != nil {
+		de, ok := err.(dynamoError)
+		if !ok {
+			de = dynamoError{"ValidationException", err.Error()}
+		}
+
+		writeJSONError(w, 400, de.code, de.message)
+		return
+	}
+
+	writeJSON(w, res)
+}
+
+type dynamoError struct {
+	code    string
+	message string
+}
+
+func (e dynamoError) Error() string {
+	return e.message
+}
+
+func (d *DynamoDB) findTable(name string) (*fakeTable, error) {
+	t, ok := d.tables[name]
+	if !ok {
+		return nil, dynamoError{"ResourceNotFoundException", "Requested resource not found"}
+	}
+
+	return t, nil
+}
+
+// createTable supports a string hash key and global secondary indexes with string hash and range keys
+// tables are usable as soon as they are created
+func (d *DynamoDB) createTable(r *http.Request) (interface{}, error) {
+	var req dynamodb.CreateTableInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	name := aws.StringValue(req.TableName)
+
+	if _, ok := d.tables[name]; ok {
+		return nil, dynamoError{"ResourceInUseException", fmt.Sprintf("Table already exists: %s", name)}
+	}
+
+	t := Table{Name: name, Indexes: map[string]Index{}}
+
+	for _, k := range req.KeySchema {
+		if aws.StringValue(k.KeyType) == "HASH" {
+			t.HashKey = aws.StringValue(k.AttributeName)
+		}
+	}
+
+	for _, gsi := range req.GlobalSecondaryIndexes {
+		i := Index{}
+
+		for _, k := range gsi.KeySchema {
+			switch aws.StringValue(k.KeyType) {
+			case "HASH":
+				i.HashKey = aws.StringValue(k.AttributeName)
+			case "RANGE":
+				i.RangeKey = aws.StringValue(k.AttributeName)
+			}
+		}
+
+		t.Indexes[aws.StringValue(gsi.IndexName)] = i
+	}
+
+	if d.tables == nil {
+		d.tables = map[string]*fakeTable{}
+	}
+
+	d.tables[name] = &fakeTable{items: map[string]map[string]*dynamodb.AttributeValue{}, table: t}
+
+	return &dynamodb.CreateTableOutput{TableDescription: &dynamodb.TableDescription{TableName: aws.String(name), TableStatus: aws.String("CREATING")}}, nil
+}
+
+func (d *DynamoDB) describeTable(r *http.Request) (interface{}, error) {
+	var req dynamodb.DescribeTableInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	t, err := d.findTable(aws.StringValue(req.TableName))
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{TableName: aws.String(t.table.Name), TableStatus: aws.String("ACTIVE")}}, nil
+}
+
+func (d *DynamoDB) getItem(r *http.Request) (interface{}, error) {
+	var req dynamodb.GetItemInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	t, err := d.findTable(aws.StringValue(req.TableName))
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := req.Key[t.table.HashKey]
+	if !ok || key.S == nil {
+		return nil, fmt.Errorf("The provided key element does not match the schema")
+	}
+
+	return &dynamodb.GetItemOutput{Item: t.items[*key.S]}, nil
+}
+
+func (d *DynamoDB) putItem(r *http.Request) (interface{}, error) {
+	var req dynamodb.PutItemInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	t, err := d.findTable(aws.StringValue(req.TableName))
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := req.Item[t.table.HashKey]
+	if !ok || key.S == nil {
+		return nil, fmt.Errorf("One of the required keys was not given a value")
+	}
+
+	t.items[*key.S] = req.Item
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// updateItem supports an UpdateExpression of "SET a = :a, ..." and a ConditionExpression of clauses joined by OR
+// where each clause is "a = :a" or "attribute_not_exists(a)"
+// attribute names may be placeholders from ExpressionAttributeNames
+func (d *DynamoDB) updateItem(r *http.Request) (interface{}, error) {
+	var req dynamodb.UpdateItemInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	d.updates = append(d.updates, &req)
+
+	t, err := d.findTable(aws.StringValue(req.TableName))
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := req.Key[t.table.HashKey]
+	if !ok || key.S == nil {
+		return nil, fmt.Errorf("The provided key element does not match the schema")
+	}
+
+	name := func(n string) string {
+		if v, ok := req.ExpressionAttributeNames[n]; ok {
+			return aws.StringValue(v)
+		}
+		return n
+	}
+
+	value := func(v string) (*dynamodb.AttributeValue, error) {
+		av, ok := req.ExpressionAttributeValues[v]
+		if !ok {
+			return nil, fmt.Errorf("An expression attribute value used in expression is not defined: %s", v)
+		}
+		return av, nil
+	}
+
+	item := t.items[*key.S]
+
+	if c := aws.StringValue(req.ConditionExpression); c != "" {
+		holds := false
+
+		for _, clause := range strings.Split(c, " OR ") {
+			clause = strings.TrimSpace(clause)
+
+			if strings.HasPrefix(clause, "attribute_not_exists(") && strings.HasSuffix(clause, ")") {
+				if _, ok := item[name(strings.TrimSuffix(strings.TrimPrefix(clause, "attribute_not_exists("), ")"))]; !ok {
+					holds = true
+				}
+				continue
+			}
+
+			parts := strings.Fields(clause)
+
+			if len(parts) != 3 || parts[1] != "=" {
+				return nil, fmt.Errorf("ConditionExpression not supported: %s", c)
+			}
+
+			expected, err := value(parts[2])
+			if err != nil {
+				return nil, err
+			}
+
+			if current, ok := item[name(parts[0])]; ok && aws.StringValue(current.S) == aws.StringValue(expected.S) {
+				holds = true
+			}
+		}
+
+		if d.failConditions > 0 {
+			d.failConditions--
+			holds = false
+		}
+
+		if !holds {
+			return nil, dynamoError{"ConditionalCheckFailedException", "The conditional request failed"}
+		}
+	}
+
+	updated := map[string]*dynamodb.AttributeValue{}
+
+	for k, v := range item {
+		updated[k] = v
+	}
+
+	updated[t.table.HashKey] = key
+
+	expr := strings.TrimSpace(aws.StringValue(req.UpdateExpression))
+
+	if !strings.HasPrefix(expr, "SET ") {
+		return nil, fmt.Errorf("UpdateExpression not supported: %s", expr)
+	}
+
+	for _, set := range strings.Split(strings.TrimPrefix(expr, "SET "), ",") {
+		parts := strings.Fields(set)
+
+		if len(parts) != 3 || parts[1] != "=" {
+			return nil, fmt.Errorf("UpdateExpression not supported: %s", expr)
+		}
+
+		av, err := value(parts[2])
+		if err != nil {
+			return nil, err
+		}
+
+		updated[name(parts[0])] = av
+	}
+
+	t.items[*key.S] = updated
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+// query supports equality on the hash key of a table or index using KeyConditions
+// or a KeyConditionExpression of the form "key = :value"
+// KeyConditions may also compare the range key of an index, results are paged by Limit
+func (d *DynamoDB) query(r *http.Request) (interface{}, error) {
+	var req dynamodb.QueryInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	t, err := d.findTable(aws.StringValue(req.TableName))
+	if err != nil {
+		return nil, err
+	}
+
+	index := Index{HashKey: t.table.HashKey}
+
+	if name := aws.StringValue(req.IndexName); name != "" {
+		i, ok := t.table.Indexes[name]
+		if !ok {
+			return nil, fmt.Errorf("The table does not have the specified index: %s", name)
+		}
+		index = i
+	}
+
+	value, err := queryHashValue(&req, index.HashKey)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []map[string]*dynamodb.AttributeValue{}
+
+	for _, item := range t.items {
+		if av, ok := item[index.HashKey]; ok && aws.StringValue(av.S) == value {
+			if c, ok := req.KeyConditions[index.RangeKey]; ok && index.RangeKey != "" {
+				match, err := queryRangeMatch(c, item[index.RangeKey])
+				if err != nil {
+					return nil, err
+				}
+				if !match {
+					continue
+				}
+			}
+
+			items = append(items, item)
+		}
+	}
+
+	forward := req.ScanIndexForward == nil || *req.ScanIndexForward
+
+	sort.Slice(items, func(i, j int) bool {
+		a, b := aws.StringValue(items[i][index.RangeKey].S), aws.StringValue(items[j][index.RangeKey].S)
+
+		if a == b {
+			a, b = aws.StringValue(items[i][t.table.HashKey].S), aws.StringValue(items[j][t.table.HashKey].S)
+		}
+
+		if forward {
+			return a < b
+		}
+
+		return a > b
+	})
+
+	if start, ok := req.ExclusiveStartKey[t.table.HashKey]; ok {
+		for i, item := range items {
+			if aws.StringValue(item[t.table.HashKey].S) == aws.StringValue(start.S) {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+
+	res := &dynamodb.QueryOutput{}
+
+	if req.Limit != nil && int64(len(items)) > *req.Limit {
+		items = items[0:*req.Limit]
+
+		last := items[len(items)-1]
+
+		res.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{t.table.HashKey: last[t.table.HashKey]}
+
+		for _, k := range []string{index.HashKey, index.RangeKey} {
+			if av, ok := last[k]; ok {
+				res.LastEvaluatedKey[k] = av
+			}
+		}
+	}
+
+	res.Count = aws.Int64(int64(len(items)))
+	res.Items = items
+
+	return res, nil
+}
+
+func queryRangeMatch(c *dynamodb.Condition, av *dynamodb.AttributeValue) (bool, error) {
+	if len(c.AttributeValueList) != 1 {
+		return false, fmt.Errorf("Query key condition not supported")
+	}
+
+	v, bound := aws.StringValue(av.S), aws.StringValue(c.AttributeValueList[0].S)
+
+	switch aws.StringValue(c.ComparisonOperator) {
+	case "EQ":
+		return v == bound, nil
+	case "GE":
+		return v >= bound, nil
+	case "GT":
+		return v > bound, nil
+	case "LE":
+		return v <= bound, nil
+	case "LT":
+		return v < bound, nil
+	}
+
+	return false, fmt.Errorf("Query key condition not supported")
+}
+
+func queryHashValue(req *dynamodb.QueryInput, key string) (string, error) {
+	if c, ok := req.KeyConditions[key]; ok {
+		if aws.StringValue(c.ComparisonOperator) != "EQ" || len(c.AttributeValueList) != 1 {
+			return "", fmt.Errorf("Query key condition not supported")
+		}
+
+		return aws.StringValue(c.AttributeValueList[0].S), nil
+	}
+
+	parts := strings.Fields(aws.StringValue(req.KeyConditionExpression))
+
+	if len(parts) == 3 && parts[0] == key && parts[1] == "=" {
+		if av, ok := req.ExpressionAttributeValues[parts[2]]; ok {
+			return aws.StringValue(av.S), nil
+		}
+	}
+
+	return "", fmt.Errorf("Query key condition not supported")
+}
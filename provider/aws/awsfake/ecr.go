@@ -0,0 +1,148 @@
+package awsfake
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// ECRAccount is the account id of fake repositories and the registry they live in
+const ECRAccount = "123456789012"
+
+// ECR is an in-memory ECR holding repositories and a single set of registry credentials
+type ECR struct {
+	clock        *Clock
+	lock         sync.Mutex
+	password     string
+	repositories []*ecr.Repository
+	tokens       int
+}
+
+// AddRepository adds a repository and returns its uri
+func (e *ECR) AddRepository(name string) string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	uri := fmt.Sprintf("%s/%s", e.registry(), name)
+
+	e.repositories = append(e.repositories, &ecr.Repository{
+		RegistryId:     aws.String(ECRAccount),
+		RepositoryArn:  aws.String(fmt.Sprintf("arn:aws:ecr:%s:%s:repository/%s", Region, ECRAccount, name)),
+		RepositoryName: aws.String(name),
+		RepositoryUri:  aws.String(uri),
+	})
+
+	return uri
+}
+
+// SetPassword sets the password handed out for the AWS user by GetAuthorizationToken
+func (e *ECR) SetPassword(password string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.password = password
+}
+
+// Tokens returns the number of GetAuthorizationToken requests received
+func (e *ECR) Tokens() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.tokens
+}
+
+func (e *ECR) registry() string {
+	return fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com", ECRAccount, Region)
+}
+
+func (e *ECR) serve(w http.ResponseWriter, r *http.Request, operation string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	var res interface{}
+	var err error
+
+	switch operation {
+	case "DescribeRepositories":
+		res, err = e.describeRepositories(r)
+	case "GetAuthorizationToken":
+		res, err = e.getAuthorizationToken(r)
+	default:
+		writeJSONError(w, 400, "UnknownOperationException", fmt.Sprintf("unsupported operation: %s", operation))
+		return
+	}
+
+	if err != nil {
+		if ee, ok := err.(ecrError); ok {
+			writeJSONError(w, 400, ee.code, ee.message)
+			return
+		}
+
+		writeJSONError(w, 400, "InvalidParameterException", err.Error())
+		return
+	}
+
+	writeJSON(w, res)
+}
+
+type ecrError struct {
+	code    string
+	message string
+}
+
+func (e ecrError) Error() string {
+	return fmt.Sprintf("%s: %s", e.code, e.message)
+}
+
+func (e *ECR) describeRepositories(r *http.Request) (interface{}, error) {
+	var req ecr.DescribeRepositoriesInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	res := &ecr.DescribeRepositoriesOutput{Repositories: []*ecr.Repository{}}
+
+	for _, name := range req.RepositoryNames {
+		found := false
+
+		for _, repo := range e.repositories {
+			if aws.StringValue(repo.RepositoryName) == aws.StringValue(name) {
+				res.Repositories = append(res.Repositories, repo)
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, ecrError{"RepositoryNotFoundException", fmt.Sprintf("The repository with name '%s' does not exist in the registry with id '%s'", aws.StringValue(name), ECRAccount)}
+		}
+	}
+
+	if len(req.RepositoryNames) == 0 {
+		res.Repositories = e.repositories
+	}
+
+	return res, nil
+}
+
+func (e *ECR) getAuthorizationToken(r *http.Request) (interface{}, error) {
+	e.tokens++
+
+	token := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("AWS:%s", e.password)))
+
+	return &ecr.GetAuthorizationTokenOutput{
+		AuthorizationData: []*ecr.AuthorizationData{
+			{
+				AuthorizationToken: aws.String(token),
+				ExpiresAt:          aws.Time(e.clock.Now().Add(12 * time.Hour)),
+				ProxyEndpoint:      aws.String(fmt.Sprintf("https://%s", e.registry())),
+			},
+		},
+	}, nil
+}
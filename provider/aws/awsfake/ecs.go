@@ -0,0 +1,941 @@
+package awsfake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// ECS is an in-memory ECS with clusters, container instances, services, tasks and task definitions
+// Resources are seeded directly, the fake serves the read operations the provider relies on,
+// UpdateService and the task definition and RunTask calls behind one-off tasks
+type ECS struct {
+	clusters        []*fakeCluster
+	commands        []ExecuteCommand
+	execs           map[string]bool
+	lock            sync.Mutex
+	runs            []string
+	taskDefinitions []*ecs.TaskDefinition
+	tokens          map[string]*ecs.Task
+}
+
+type fakeCluster struct {
+	alarms    map[string]*DeploymentAlarms
+	cluster   *ecs.Cluster
+	instances []*ecs.ContainerInstance
+	services  []*ecs.Service
+	tasks     []*ecs.Task
+}
+
+// DeploymentAlarms are the cloudwatch alarms that gate the deployments of a service
+// the vendored sdk predates them so the fake keeps them beside the service
+type DeploymentAlarms struct {
+	AlarmNames []string `json:"alarmNames"`
+	Enable     bool     `json:"enable"`
+	Rollback   bool     `json:"rollback"`
+}
+
+// ExecuteCommand is an ECS Exec request, the vendored sdk predates it
+type ExecuteCommand struct {
+	Cluster     string `json:"cluster"`
+	Command     string `json:"command"`
+	Container   string `json:"container"`
+	Interactive bool   `json:"interactive"`
+	Task        string `json:"task"`
+}
+
+// AddCluster creates an empty cluster and returns its arn
+func (e *ECS) AddCluster(name string) string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	arn := fmt.Sprintf("arn:aws:ecs:%s:123456789012:cluster/%s", Region, name)
+
+	e.clusters = append(e.clusters, &fakeCluster{
+		cluster: &ecs.Cluster{
+			ClusterArn:  aws.String(arn),
+			ClusterName: aws.String(name),
+			Status:      aws.String("ACTIVE"),
+		},
+	})
+
+	return arn
+}
+
+// AddContainerInstance adds a container instance to a cluster, an arn is generated if missing
+func (e *ECS) AddContainerInstance(cluster string, ci *ecs.ContainerInstance) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	c, err := e.findCluster(cluster)
+	if err != nil {
+		return err
+	}
+
+	if ci.ContainerInstanceArn == nil {
+		ci.ContainerInstanceArn = aws.String(fmt.Sprintf("arn:aws:ecs:%s:123456789012:container-instance/%s/%d", Region, *c.cluster.ClusterName, len(c.instances)+1))
+	}
+
+	c.instances = append(c.instances, ci)
+
+	return nil
+}
+
+// AddService adds a service to a cluster, an arn is generated from the service name if missing
+func (e *ECS) AddService(cluster string, s *ecs.Service) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	c, err := e.findCluster(cluster)
+	if err != nil {
+		return err
+	}
+
+	if s.ServiceArn == nil {
+		s.ServiceArn = aws.String(fmt.Sprintf("arn:aws:ecs:%s:123456789012:service/%s/%s", Region, *c.cluster.ClusterName, aws.StringValue(s.ServiceName)))
+	}
+
+	s.ClusterArn = c.cluster.ClusterArn
+
+	c.services = append(c.services, s)
+
+	return nil
+}
+
+// AddTask adds a task to a cluster, an arn is generated if missing
+func (e *ECS) AddTask(cluster string, t *ecs.Task) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	c, err := e.findCluster(cluster)
+	if err != nil {
+		return err
+	}
+
+	if t.TaskArn == nil {
+		t.TaskArn = aws.String(fmt.Sprintf("arn:aws:ecs:%s:123456789012:task/%s/%032d", Region, *c.cluster.ClusterName, len(c.tasks)+1))
+	}
+
+	t.ClusterArn = c.cluster.ClusterArn
+
+	c.tasks = append(c.tasks, t)
+
+	return nil
+}
+
+// AddTaskDefinition registers a task definition, the arn is generated from the family and revision if missing
+func (e *ECS) AddTaskDefinition(td *ecs.TaskDefinition) string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if td.TaskDefinitionArn == nil {
+		td.TaskDefinitionArn = aws.String(fmt.Sprintf("arn:aws:ecs:%s:123456789012:task-definition/%s:%d", Region, aws.StringValue(td.Family), aws.Int64Value(td.Revision)))
+	}
+
+	e.taskDefinitions = append(e.taskDefinitions, td)
+
+	return *td.TaskDefinitionArn
+}
+
+// DeploymentAlarms returns the deployment alarms of a service or nil if none were set
+func (e *ECS) DeploymentAlarms(cluster, service string) *DeploymentAlarms {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	c, err := e.findCluster(cluster)
+	if err != nil {
+		return nil
+	}
+
+	s := findService(c, service)
+	if s == nil {
+		return nil
+	}
+
+	return c.alarms[aws.StringValue(s.ServiceArn)]
+}
+
+// ExecuteCommands returns the ECS Exec requests received so far
+func (e *ECS) ExecuteCommands() []ExecuteCommand {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return append([]ExecuteCommand{}, e.commands...)
+}
+
+// RunTasks returns the arns of the tasks started with RunTask so far
+// they start PENDING so tests can move them along with SetTaskStatus
+func (e *ECS) RunTasks() []string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return append([]string{}, e.runs...)
+}
+
+// SetTaskExecuteCommand sets whether a task runs with ECS Exec enabled, the vendored sdk has no field for it
+func (e *ECS) SetTaskExecuteCommand(arn string, enabled bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.setExecuteCommand(arn, enabled)
+}
+
+// TaskExecuteCommand returns whether a task runs with ECS Exec enabled
+func (e *ECS) TaskExecuteCommand(arn string) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.execs[arn]
+}
+
+func (e *ECS) setExecuteCommand(arn string, enabled bool) {
+	if e.execs == nil {
+		e.execs = map[string]bool{}
+	}
+
+	e.execs[arn] = enabled
+}
+
+// SetTaskStatus changes the last and desired status of a task to simulate it progressing
+func (e *ECS) SetTaskStatus(arn, status string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for _, c := range e.clusters {
+		if t := findTask(c, arn); t != nil {
+			t.LastStatus = aws.String(status)
+
+			if status == "STOPPED" {
+				t.DesiredStatus = aws.String(status)
+			}
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no such task: %s", arn)
+}
+
+func (e *ECS) serve(w http.ResponseWriter, r *http.Request, operation string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	var res interface{}
+	var err error
+
+	switch operation {
+	case "DeregisterTaskDefinition":
+		res, err = e.deregisterTaskDefinition(r)
+	case "DescribeClusters":
+		res, err = e.describeClusters(r)
+	case "DescribeContainerInstances":
+		res, err = e.describeContainerInstances(r)
+	case "DescribeServices":
+		res, err = e.describeServices(r)
+	case "DescribeTaskDefinition":
+		res, err = e.describeTaskDefinition(r)
+	case "DescribeTasks":
+		res, err = e.describeTasks(r)
+	case "ExecuteCommand":
+		res, err = e.executeCommand(r)
+	case "ListContainerInstances":
+		res, err = e.listContainerInstances(r)
+	case "ListServices":
+		res, err = e.listServices(r)
+	case "ListTaskDefinitionFamilies":
+		res, err = e.listTaskDefinitionFamilies(r)
+	case "ListTasks":
+		res, err = e.listTasks(r)
+	case "RegisterTaskDefinition":
+		res, err = e.registerTaskDefinition(r)
+	case "RunTask":
+		res, err = e.runTask(r)
+	case "StopTask":
+		res, err = e.stopTask(r)
+	case "UpdateService":
+		res, err = e.updateService(r)
+	default:
+		err = ecsError{"UnknownOperationException", fmt.Sprintf("unsupported operation: %s", operation)}
+	}
+
+	if err != nil {
+		ee, ok := err.(ecsError)
+		if !ok {
+			ee = ecsError{"ClientException", err.Error()}
+		}
+
+		writeJSONError(w, 400, ee.code, ee.message)
+		return
+	}
+
+	writeJSON(w, res)
+}
+
+type ecsError struct {
+	code    string
+	message string
+}
+
+func (e ecsError) Error() string {
+	return e.message
+}
+
+func (e *ECS) findCluster(name string) (*fakeCluster, error) {
+	if name == "" {
+		name = "default"
+	}
+
+	for _, c := range e.clusters {
+		if *c.cluster.ClusterArn == name || *c.cluster.ClusterName == name {
+			return c, nil
+		}
+	}
+
+	return nil, ecsError{"ClusterNotFoundException", "Cluster not found."}
+}
+
+// findService matches a service by arn or name
+func findService(c *fakeCluster, id string) *ecs.Service {
+	for _, s := range c.services {
+		if aws.StringValue(s.ServiceArn) == id || aws.StringValue(s.ServiceName) == id {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// findTask matches a task by arn or by the id at the end of its arn
+func findTask(c *fakeCluster, id string) *ecs.Task {
+	for _, t := range c.tasks {
+		if arn := aws.StringValue(t.TaskArn); arn == id || strings.HasSuffix(arn, "/"+id) {
+			return t
+		}
+	}
+
+	return nil
+}
+
+func (e *ECS) describeClusters(r *http.Request) (interface{}, error) {
+	var req ecs.DescribeClustersInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	res := &ecs.DescribeClustersOutput{Clusters: []*ecs.Cluster{}, Failures: []*ecs.Failure{}}
+
+	for _, name := range aws.StringValueSlice(req.Clusters) {
+		c, err := e.findCluster(name)
+		if err != nil {
+			res.Failures = append(res.Failures, &ecs.Failure{Arn: aws.String(name), Reason: aws.String("MISSING")})
+			continue
+		}
+
+		cluster := *c.cluster
+		cluster.RegisteredContainerInstancesCount = aws.Int64(int64(len(c.instances)))
+		cluster.ActiveServicesCount = aws.Int64(int64(len(c.services)))
+
+		running := int64(0)
+
+		for _, t := range c.tasks {
+			if aws.StringValue(t.LastStatus) == "RUNNING" {
+				running++
+			}
+		}
+
+		cluster.RunningTasksCount = aws.Int64(running)
+
+		res.Clusters = append(res.Clusters, &cluster)
+	}
+
+	return res, nil
+}
+
+func (e *ECS) describeContainerInstances(r *http.Request) (interface{}, error) {
+	var req ecs.DescribeContainerInstancesInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	c, err := e.findCluster(aws.StringValue(req.Cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ecs.DescribeContainerInstancesOutput{ContainerInstances: []*ecs.ContainerInstance{}, Failures: []*ecs.Failure{}}
+
+	for _, arn := range aws.StringValueSlice(req.ContainerInstances) {
+		found := false
+
+		for _, ci := range c.instances {
+			if a := aws.StringValue(ci.ContainerInstanceArn); a == arn || strings.HasSuffix(a, "/"+arn) {
+				res.ContainerInstances = append(res.ContainerInstances, ci)
+				found = true
+			}
+		}
+
+		if !found {
+			res.Failures = append(res.Failures, &ecs.Failure{Arn: aws.String(arn), Reason: aws.String("MISSING")})
+		}
+	}
+
+	return res, nil
+}
+
+func (e *ECS) describeServices(r *http.Request) (interface{}, error) {
+	var req ecs.DescribeServicesInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	if len(req.Services) > 10 {
+		return nil, ecsError{"InvalidParameterException", "Services cannot have more than 10 items"}
+	}
+
+	c, err := e.findCluster(aws.StringValue(req.Cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ecs.DescribeServicesOutput{Services: []*ecs.Service{}, Failures: []*ecs.Failure{}}
+
+	for _, name := range aws.StringValueSlice(req.Services) {
+		found := false
+
+		for _, s := range c.services {
+			if aws.StringValue(s.ServiceArn) == name || aws.StringValue(s.ServiceName) == name {
+				res.Services = append(res.Services, s)
+				found = true
+			}
+		}
+
+		if !found {
+			res.Failures = append(res.Failures, &ecs.Failure{Arn: aws.String(name), Reason: aws.String("MISSING")})
+		}
+	}
+
+	return withDeploymentAlarms(c, res)
+}
+
+func (e *ECS) describeTaskDefinition(r *http.Request) (interface{}, error) {
+	var req ecs.DescribeTaskDefinitionInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	found := e.findTaskDefinition(aws.StringValue(req.TaskDefinition))
+	if found == nil {
+		return nil, ecsError{"ClientException", "Unable to describe task definition."}
+	}
+
+	return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: found}, nil
+}
+
+// findTaskDefinition matches a task definition by arn, family:revision or family for the latest active revision
+func (e *ECS) findTaskDefinition(name string) *ecs.TaskDefinition {
+	var found *ecs.TaskDefinition
+
+	for _, td := range e.taskDefinitions {
+		family := aws.StringValue(td.Family)
+
+		switch name {
+		case aws.StringValue(td.TaskDefinitionArn), fmt.Sprintf("%s:%d", family, aws.Int64Value(td.Revision)):
+			return td
+		case family:
+			if aws.StringValue(td.Status) == ecs.TaskDefinitionStatusInactive {
+				continue
+			}
+
+			if found == nil || aws.Int64Value(td.Revision) > aws.Int64Value(found.Revision) {
+				found = td
+			}
+		}
+	}
+
+	return found
+}
+
+func (e *ECS) deregisterTaskDefinition(r *http.Request) (interface{}, error) {
+	var req ecs.DeregisterTaskDefinitionInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	td := e.findTaskDefinition(aws.StringValue(req.TaskDefinition))
+	if td == nil {
+		return nil, ecsError{"ClientException", "The specified task definition does not exist."}
+	}
+
+	td.Status = aws.String("INACTIVE")
+
+	return &ecs.DeregisterTaskDefinitionOutput{TaskDefinition: td}, nil
+}
+
+func (e *ECS) describeTasks(r *http.Request) (interface{}, error) {
+	var req ecs.DescribeTasksInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	c, err := e.findCluster(aws.StringValue(req.Cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ecs.DescribeTasksOutput{Tasks: []*ecs.Task{}, Failures: []*ecs.Failure{}}
+
+	for _, id := range aws.StringValueSlice(req.Tasks) {
+		if t := findTask(c, id); t != nil {
+			res.Tasks = append(res.Tasks, t)
+		} else {
+			res.Failures = append(res.Failures, &ecs.Failure{Arn: aws.String(id), Reason: aws.String("MISSING")})
+		}
+	}
+
+	return e.withExecuteCommand(res)
+}
+
+// withExecuteCommand adds whether ECS Exec is enabled to the tasks of a DescribeTasks response
+func (e *ECS) withExecuteCommand(res *ecs.DescribeTasksOutput) (interface{}, error) {
+	if len(e.execs) == 0 {
+		return res, nil
+	}
+
+	data, err := jsonutil.BuildJSON(res)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	tasks, _ := m["tasks"].([]interface{})
+
+	for _, t := range tasks {
+		if tm, ok := t.(map[string]interface{}); ok {
+			tm["enableExecuteCommand"] = e.execs[fmt.Sprint(tm["taskArn"])]
+		}
+	}
+
+	data, err = json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(data), nil
+}
+
+func (e *ECS) listContainerInstances(r *http.Request) (interface{}, error) {
+	var req ecs.ListContainerInstancesInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	c, err := e.findCluster(aws.StringValue(req.Cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ecs.ListContainerInstancesOutput{ContainerInstanceArns: []*string{}}
+
+	for _, ci := range c.instances {
+		if status := aws.StringValue(req.Status); status != "" && status != aws.StringValue(ci.Status) {
+			continue
+		}
+
+		res.ContainerInstanceArns = append(res.ContainerInstanceArns, ci.ContainerInstanceArn)
+	}
+
+	return res, nil
+}
+
+func (e *ECS) listServices(r *http.Request) (interface{}, error) {
+	var req ecs.ListServicesInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	c, err := e.findCluster(aws.StringValue(req.Cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ecs.ListServicesOutput{ServiceArns: []*string{}}
+
+	for _, s := range c.services {
+		res.ServiceArns = append(res.ServiceArns, s.ServiceArn)
+	}
+
+	return res, nil
+}
+
+// listTaskDefinitionFamilies lists families by prefix, a family is active while any of its revisions is
+func (e *ECS) listTaskDefinitionFamilies(r *http.Request) (interface{}, error) {
+	var req ecs.ListTaskDefinitionFamiliesInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	active := map[string]bool{}
+	families := []string{}
+
+	for _, td := range e.taskDefinitions {
+		family := aws.StringValue(td.Family)
+
+		if !strings.HasPrefix(family, aws.StringValue(req.FamilyPrefix)) {
+			continue
+		}
+
+		if _, ok := active[family]; !ok {
+			families = append(families, family)
+		}
+
+		active[family] = active[family] || aws.StringValue(td.Status) != ecs.TaskDefinitionStatusInactive
+	}
+
+	res := &ecs.ListTaskDefinitionFamiliesOutput{Families: []*string{}}
+
+	for _, family := range families {
+		switch aws.StringValue(req.Status) {
+		case "", ecs.TaskDefinitionFamilyStatusActive:
+			if !active[family] {
+				continue
+			}
+		case ecs.TaskDefinitionFamilyStatusInactive:
+			if active[family] {
+				continue
+			}
+		}
+
+		res.Families = append(res.Families, aws.String(family))
+	}
+
+	return res, nil
+}
+
+func (e *ECS) listTasks(r *http.Request) (interface{}, error) {
+	var req ecs.ListTasksInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	c, err := e.findCluster(aws.StringValue(req.Cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	desired := coalesce(aws.StringValue(req.DesiredStatus), "RUNNING")
+
+	res := &ecs.ListTasksOutput{TaskArns: []*string{}}
+
+	for _, t := range c.tasks {
+		if aws.StringValue(t.DesiredStatus) != desired {
+			continue
+		}
+
+		if req.StartedBy != nil && aws.StringValue(t.StartedBy) != *req.StartedBy {
+			continue
+		}
+
+		if req.ServiceName != nil && aws.StringValue(t.Group) != "service:"+*req.ServiceName {
+			continue
+		}
+
+		if req.Family != nil && !strings.Contains(aws.StringValue(t.TaskDefinitionArn), "/"+*req.Family+":") {
+			continue
+		}
+
+		if req.ContainerInstance != nil && aws.StringValue(t.ContainerInstanceArn) != *req.ContainerInstance {
+			continue
+		}
+
+		res.TaskArns = append(res.TaskArns, t.TaskArn)
+	}
+
+	return res, nil
+}
+
+func (e *ECS) executeCommand(r *http.Request) (interface{}, error) {
+	var req ExecuteCommand
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+
+	c, err := e.findCluster(req.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	t := findTask(c, req.Task)
+	if t == nil {
+		return nil, ecsError{"InvalidParameterException", "The referenced task was not found."}
+	}
+
+	e.commands = append(e.commands, req)
+
+	id := fmt.Sprintf("ecs-execute-command-%d", len(e.commands))
+
+	data, err := json.Marshal(map[string]interface{}{
+		"clusterArn":    aws.StringValue(c.cluster.ClusterArn),
+		"containerName": req.Container,
+		"interactive":   req.Interactive,
+		"session": map[string]string{
+			"sessionId":  id,
+			"streamUrl":  fmt.Sprintf("wss://ssmmessages.%s.amazonaws.com/v1/data-channel/%s", Region, id),
+			"tokenValue": "token",
+		},
+		"taskArn": aws.StringValue(t.TaskArn),
+	})
+
+	return json.RawMessage(data), err
+}
+
+func (e *ECS) registerTaskDefinition(r *http.Request) (interface{}, error) {
+	var req ecs.RegisterTaskDefinitionInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	revision := int64(1)
+
+	if latest := e.findTaskDefinition(aws.StringValue(req.Family)); latest != nil {
+		revision = aws.Int64Value(latest.Revision) + 1
+	}
+
+	td := &ecs.TaskDefinition{
+		ContainerDefinitions:    req.ContainerDefinitions,
+		Cpu:                     req.Cpu,
+		Family:                  req.Family,
+		Memory:                  req.Memory,
+		NetworkMode:             req.NetworkMode,
+		RequiresCompatibilities: req.RequiresCompatibilities,
+		Revision:                aws.Int64(revision),
+		Status:                  aws.String("ACTIVE"),
+		TaskDefinitionArn:       aws.String(fmt.Sprintf("arn:aws:ecs:%s:123456789012:task-definition/%s:%d", Region, aws.StringValue(req.Family), revision)),
+	}
+
+	e.taskDefinitions = append(e.taskDefinitions, td)
+
+	return &ecs.RegisterTaskDefinitionOutput{TaskDefinition: td}, nil
+}
+
+func (e *ECS) runTask(r *http.Request) (interface{}, error) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var req ecs.RunTaskInput
+
+	if err := jsonutil.UnmarshalJSON(&req, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	// the vendored sdk predates client tokens and ECS Exec so the fake reads them from the body itself
+	var idempotency struct {
+		ClientToken          string `json:"clientToken"`
+		EnableExecuteCommand bool   `json:"enableExecuteCommand"`
+	}
+
+	if err := json.Unmarshal(data, &idempotency); err != nil {
+		return nil, err
+	}
+
+	// a run retried with the same token returns the task the first attempt started
+	if t, ok := e.tokens[idempotency.ClientToken]; ok {
+		return &ecs.RunTaskOutput{Tasks: []*ecs.Task{t}, Failures: []*ecs.Failure{}}, nil
+	}
+
+	c, err := e.findCluster(aws.StringValue(req.Cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	td := e.findTaskDefinition(aws.StringValue(req.TaskDefinition))
+	if td == nil || aws.StringValue(td.Status) == "INACTIVE" {
+		return nil, ecsError{"ClientException", "TaskDefinition not found."}
+	}
+
+	n := len(c.tasks) + 1
+
+	t := &ecs.Task{
+		ClusterArn:        c.cluster.ClusterArn,
+		DesiredStatus:     aws.String("RUNNING"),
+		LastStatus:        aws.String("PENDING"),
+		LaunchType:        req.LaunchType,
+		StartedBy:         req.StartedBy,
+		TaskArn:           aws.String(fmt.Sprintf("arn:aws:ecs:%s:123456789012:task/%s/%032d", Region, *c.cluster.ClusterName, n)),
+		TaskDefinitionArn: td.TaskDefinitionArn,
+	}
+
+	for _, cd := range td.ContainerDefinitions {
+		t.Containers = append(t.Containers, &ecs.Container{Name: cd.Name, RuntimeId: aws.String(fmt.Sprintf("runtime%d", n))})
+	}
+
+	c.tasks = append(c.tasks, t)
+	e.runs = append(e.runs, *t.TaskArn)
+
+	if idempotency.EnableExecuteCommand {
+		e.setExecuteCommand(*t.TaskArn, true)
+	}
+
+	if idempotency.ClientToken != "" {
+		if e.tokens == nil {
+			e.tokens = map[string]*ecs.Task{}
+		}
+
+		e.tokens[idempotency.ClientToken] = t
+	}
+
+	return &ecs.RunTaskOutput{Tasks: []*ecs.Task{t}, Failures: []*ecs.Failure{}}, nil
+}
+
+func (e *ECS) stopTask(r *http.Request) (interface{}, error) {
+	var req ecs.StopTaskInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	c, err := e.findCluster(aws.StringValue(req.Cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	t := findTask(c, aws.StringValue(req.Task))
+	if t == nil {
+		return nil, ecsError{"InvalidParameterException", "The referenced task was not found."}
+	}
+
+	t.DesiredStatus = aws.String("STOPPED")
+	t.LastStatus = aws.String("STOPPED")
+	t.StoppedReason = req.Reason
+
+	return &ecs.StopTaskOutput{Task: t}, nil
+}
+
+func (e *ECS) updateService(r *http.Request) (interface{}, error) {
+	var req struct {
+		Cluster                 string
+		DeploymentConfiguration *struct {
+			Alarms                *DeploymentAlarms
+			MaximumPercent        *int64
+			MinimumHealthyPercent *int64
+		}
+		DesiredCount       *int64
+		ForceNewDeployment bool
+		Service            string
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+
+	c, err := e.findCluster(req.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	s := findService(c, req.Service)
+	if s == nil {
+		return nil, ecsError{"ServiceNotFoundException", "Service not found."}
+	}
+
+	if req.DesiredCount != nil {
+		s.DesiredCount = req.DesiredCount
+	}
+
+	if dc := req.DeploymentConfiguration; dc != nil {
+		s.DeploymentConfiguration = &ecs.DeploymentConfiguration{
+			MaximumPercent:        dc.MaximumPercent,
+			MinimumHealthyPercent: dc.MinimumHealthyPercent,
+		}
+
+		if dc.Alarms != nil {
+			if c.alarms == nil {
+				c.alarms = map[string]*DeploymentAlarms{}
+			}
+
+			c.alarms[aws.StringValue(s.ServiceArn)] = dc.Alarms
+		}
+	}
+
+	return withDeploymentAlarms(c, &struct {
+		Service *ecs.Service `locationName:"service" type:"structure"`
+	}{Service: s})
+}
+
+// withDeploymentAlarms adds the deployment alarms of the cluster's services to a response that holds them
+func withDeploymentAlarms(c *fakeCluster, res interface{}) (interface{}, error) {
+	if len(c.alarms) == 0 {
+		return res, nil
+	}
+
+	data, err := jsonutil.BuildJSON(res)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	services := []interface{}{}
+
+	if ss, ok := m["services"].([]interface{}); ok {
+		services = ss
+	}
+
+	if s, ok := m["service"]; ok {
+		services = append(services, s)
+	}
+
+	for _, s := range services {
+		sm, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if a, ok := c.alarms[fmt.Sprint(sm["serviceArn"])]; ok {
+			dc, _ := sm["deploymentConfiguration"].(map[string]interface{})
+			if dc == nil {
+				dc = map[string]interface{}{}
+			}
+
+			dc["alarms"] = a
+			sm["deploymentConfiguration"] = dc
+		}
+	}
+
+	data, err = json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(data), nil
+}
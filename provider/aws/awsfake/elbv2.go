@@ -0,0 +1,186 @@
+package awsfake
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// elbv2Actions are the query actions served by the ELBv2 fake rather than CloudFormation
+var elbv2Actions = map[string]bool{
+	"DescribeRules":        true,
+	"DescribeTargetHealth": true,
+	"ModifyRule":           true,
+}
+
+// ELBv2 is an in-memory ELBv2 holding listener rules that forward to a single target group and the health of targets
+type ELBv2 struct {
+	health   map[string][]string
+	lock     sync.Mutex
+	modified []string
+	rules    map[string]string
+}
+
+type elbv2Action struct {
+	TargetGroupArn *string
+	Type           *string
+}
+
+type elbv2Rule struct {
+	Actions []*elbv2Action `type:"list"`
+	RuleArn *string
+}
+
+type elbv2RulesResult struct {
+	Rules []*elbv2Rule `type:"list"`
+}
+
+type elbv2Target struct {
+	Id *string
+}
+
+type elbv2TargetHealth struct {
+	State *string
+}
+
+type elbv2TargetHealthDescription struct {
+	Target       *elbv2Target
+	TargetHealth *elbv2TargetHealth
+}
+
+type elbv2TargetHealthResult struct {
+	TargetHealthDescriptions []*elbv2TargetHealthDescription `type:"list"`
+}
+
+// AddRule adds a listener rule that forwards to a target group
+func (e *ELBv2) AddRule(arn, targetGroup string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.rules == nil {
+		e.rules = map[string]string{}
+	}
+
+	e.rules[arn] = targetGroup
+}
+
+// Forward returns the target group a listener rule forwards to
+func (e *ELBv2) Forward(arn string) string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.rules[arn]
+}
+
+// ModifiedRules returns the arns of rules changed by ModifyRule in the order they were changed
+func (e *ELBv2) ModifiedRules() []string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return append([]string{}, e.modified...)
+}
+
+// SetTargetHealth sets the state of each target registered with a target group, e.g. healthy or initial
+func (e *ELBv2) SetTargetHealth(targetGroup string, states ...string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.health == nil {
+		e.health = map[string][]string{}
+	}
+
+	e.health[targetGroup] = states
+}
+
+func (e *ELBv2) serve(action string, form url.Values) (interface{}, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	switch action {
+	case "DescribeRules":
+		return e.describeRules(form)
+	case "DescribeTargetHealth":
+		return e.describeTargetHealth(form)
+	case "ModifyRule":
+		return e.modifyRule(form)
+	}
+
+	return nil, cfError{"InvalidAction", fmt.Sprintf("unsupported action: %s", action)}
+}
+
+func (e *ELBv2) rule(arn string) (*elbv2Rule, error) {
+	tg, ok := e.rules[arn]
+	if !ok {
+		return nil, cfError{"RuleNotFound", fmt.Sprintf("One or more rules not found: %s", arn)}
+	}
+
+	return &elbv2Rule{
+		Actions: []*elbv2Action{{TargetGroupArn: aws.String(tg), Type: aws.String("forward")}},
+		RuleArn: aws.String(arn),
+	}, nil
+}
+
+func (e *ELBv2) describeRules(form url.Values) (interface{}, error) {
+	rules := []*elbv2Rule{}
+
+	for _, arn := range formList(form, "RuleArns") {
+		r, err := e.rule(arn)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, r)
+	}
+
+	return &struct {
+		_      struct{}          `locationName:"DescribeRulesResponse"`
+		Result *elbv2RulesResult `locationName:"DescribeRulesResult"`
+	}{Result: &elbv2RulesResult{Rules: rules}}, nil
+}
+
+func (e *ELBv2) modifyRule(form url.Values) (interface{}, error) {
+	arn := form.Get("RuleArn")
+
+	if _, ok := e.rules[arn]; !ok {
+		return nil, cfError{"RuleNotFound", fmt.Sprintf("One or more rules not found: %s", arn)}
+	}
+
+	actions := formMembers(form, "Actions")
+
+	if len(actions) != 1 || actions[0]["Type"] != "forward" || actions[0]["TargetGroupArn"] == "" {
+		return nil, cfError{"ValidationError", "fake elbv2 rules forward to exactly one target group"}
+	}
+
+	e.rules[arn] = actions[0]["TargetGroupArn"]
+	e.modified = append(e.modified, arn)
+
+	r, err := e.rule(arn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &struct {
+		_      struct{}          `locationName:"ModifyRuleResponse"`
+		Result *elbv2RulesResult `locationName:"ModifyRuleResult"`
+	}{Result: &elbv2RulesResult{Rules: []*elbv2Rule{r}}}, nil
+}
+
+func (e *ELBv2) describeTargetHealth(form url.Values) (interface{}, error) {
+	tg := form.Get("TargetGroupArn")
+
+	ds := []*elbv2TargetHealthDescription{}
+
+	for i, state := range e.health[tg] {
+		ds = append(ds, &elbv2TargetHealthDescription{
+			Target:       &elbv2Target{Id: aws.String(fmt.Sprintf("i-%08d", i+1))},
+			TargetHealth: &elbv2TargetHealth{State: aws.String(state)},
+		})
+	}
+
+	return &struct {
+		_      struct{}                 `locationName:"DescribeTargetHealthResponse"`
+		Result *elbv2TargetHealthResult `locationName:"DescribeTargetHealthResult"`
+	}{Result: &elbv2TargetHealthResult{TargetHealthDescriptions: ds}}, nil
+}
@@ -0,0 +1,202 @@
+package awsfake
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+)
+
+// Events is an in-memory CloudWatch Events (EventBridge) with rules and their targets
+// Events are never matched or delivered, tests deliver messages to the target queues directly
+type Events struct {
+	lock  sync.Mutex
+	rules []*Rule
+}
+
+// Rule is a rule held by the fake
+type Rule struct {
+	Arn          string
+	Description  string
+	EventPattern string
+	Name         string
+	State        string
+	Targets      []*cloudwatchevents.Target
+}
+
+// Rule returns a copy of the named rule or nil if it does not exist
+func (e *Events) Rule(name string) *Rule {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	r := e.find(name)
+	if r == nil {
+		return nil
+	}
+
+	rc := *r
+	rc.Targets = append([]*cloudwatchevents.Target{}, r.Targets...)
+
+	return &rc
+}
+
+func (e *Events) serve(w http.ResponseWriter, r *http.Request, operation string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	var res interface{}
+	var err error
+
+	switch operation {
+	case "DescribeRule":
+		res, err = e.describeRule(r)
+	case "DisableRule":
+		res, err = e.setRuleState(r, cloudwatchevents.RuleStateDisabled)
+	case "EnableRule":
+		res, err = e.setRuleState(r, cloudwatchevents.RuleStateEnabled)
+	case "PutRule":
+		res, err = e.putRule(r)
+	case "PutTargets":
+		res, err = e.putTargets(r)
+	default:
+		err = eventsError{"UnknownOperationException", fmt.Sprintf("unsupported operation: %s", operation)}
+	}
+
+	if err != nil {
+		ee, ok := err.(eventsError)
+		if !ok {
+			ee = eventsError{"ValidationException", err.Error()}
+		}
+
+		writeJSONError(w, 400, ee.code, ee.message)
+		return
+	}
+
+	writeJSON(w, res)
+}
+
+type eventsError struct {
+	code    string
+	message string
+}
+
+func (e eventsError) Error() string {
+	return e.message
+}
+
+func ruleNotFound(name string) error {
+	return eventsError{"ResourceNotFoundException", fmt.Sprintf("Rule %s does not exist.", name)}
+}
+
+func (e *Events) find(name string) *Rule {
+	for _, r := range e.rules {
+		if r.Name == name {
+			return r
+		}
+	}
+
+	return nil
+}
+
+func (e *Events) describeRule(r *http.Request) (interface{}, error) {
+	var req cloudwatchevents.DescribeRuleInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	rule := e.find(aws.StringValue(req.Name))
+	if rule == nil {
+		return nil, ruleNotFound(aws.StringValue(req.Name))
+	}
+
+	return &cloudwatchevents.DescribeRuleOutput{
+		Arn:          aws.String(rule.Arn),
+		Description:  aws.String(rule.Description),
+		EventPattern: aws.String(rule.EventPattern),
+		Name:         aws.String(rule.Name),
+		State:        aws.String(rule.State),
+	}, nil
+}
+
+func (e *Events) putRule(r *http.Request) (interface{}, error) {
+	var req cloudwatchevents.PutRuleInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	name := aws.StringValue(req.Name)
+
+	rule := e.find(name)
+	if rule == nil {
+		rule = &Rule{
+			Arn:  fmt.Sprintf("arn:aws:events:%s:123456789012:rule/%s", Region, name),
+			Name: name,
+		}
+
+		e.rules = append(e.rules, rule)
+	}
+
+	rule.Description = aws.StringValue(req.Description)
+	rule.EventPattern = aws.StringValue(req.EventPattern)
+	rule.State = aws.StringValue(req.State)
+
+	if rule.State == "" {
+		rule.State = cloudwatchevents.RuleStateEnabled
+	}
+
+	return &cloudwatchevents.PutRuleOutput{RuleArn: aws.String(rule.Arn)}, nil
+}
+
+func (e *Events) putTargets(r *http.Request) (interface{}, error) {
+	var req cloudwatchevents.PutTargetsInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	rule := e.find(aws.StringValue(req.Rule))
+	if rule == nil {
+		return nil, ruleNotFound(aws.StringValue(req.Rule))
+	}
+
+	for _, t := range req.Targets {
+		replaced := false
+
+		for i, rt := range rule.Targets {
+			if aws.StringValue(rt.Id) == aws.StringValue(t.Id) {
+				rule.Targets[i] = t
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			rule.Targets = append(rule.Targets, t)
+		}
+	}
+
+	return &cloudwatchevents.PutTargetsOutput{FailedEntryCount: aws.Int64(0), FailedEntries: []*cloudwatchevents.PutTargetsResultEntry{}}, nil
+}
+
+func (e *Events) setRuleState(r *http.Request, state string) (interface{}, error) {
+	var req struct {
+		Name *string
+	}
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		return nil, err
+	}
+
+	rule := e.find(aws.StringValue(req.Name))
+	if rule == nil {
+		return nil, ruleNotFound(aws.StringValue(req.Name))
+	}
+
+	rule.State = state
+
+	return struct{}{}, nil
+}
@@ -0,0 +1,278 @@
+package awsfake
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+// iamActions are the query actions served by the IAM fake rather than CloudFormation
+var iamActions = map[string]bool{
+	"CreateOpenIDConnectProvider": true,
+	"DeleteOpenIDConnectProvider": true,
+	"DeleteServerCertificate":     true,
+	"GetOpenIDConnectProvider":    true,
+	"GetServerCertificate":        true,
+	"ListServerCertificates":      true,
+	"SimulatePrincipalPolicy":     true,
+	"UploadServerCertificate":     true,
+}
+
+// IAM is an in-memory IAM policy simulator, openid connect provider and server certificate registry
+// Decisions are seeded per principal, action and resource, anything not seeded is an implicit deny
+type IAM struct {
+	decisions          map[string]string
+	lock               sync.Mutex
+	oidcProviders      map[string]*iam.GetOpenIDConnectProviderOutput
+	serverCertificates map[string]*iam.ServerCertificate
+}
+
+// Allow lets principal perform action on resource
+func (i *IAM) Allow(principal, action, resource string) {
+	i.decide(principal, action, resource, iam.PolicyEvaluationDecisionTypeAllowed)
+}
+
+// Deny explicitly forbids principal from performing action on resource
+func (i *IAM) Deny(principal, action, resource string) {
+	i.decide(principal, action, resource, iam.PolicyEvaluationDecisionTypeExplicitDeny)
+}
+
+func (i *IAM) decide(principal, action, resource, decision string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.decisions == nil {
+		i.decisions = map[string]string{}
+	}
+
+	i.decisions[fmt.Sprintf("%s|%s|%s", principal, action, resource)] = decision
+}
+
+func (i *IAM) serve(action string, form url.Values) (interface{}, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	switch action {
+	case "CreateOpenIDConnectProvider":
+		return i.createOpenIDConnectProvider(form)
+	case "DeleteOpenIDConnectProvider":
+		return i.deleteOpenIDConnectProvider(form)
+	case "DeleteServerCertificate":
+		return i.deleteServerCertificate(form)
+	case "GetOpenIDConnectProvider":
+		return i.getOpenIDConnectProvider(form)
+	case "GetServerCertificate":
+		return i.getServerCertificate(form)
+	case "ListServerCertificates":
+		return i.listServerCertificates(form)
+	case "SimulatePrincipalPolicy":
+		return i.simulatePrincipalPolicy(form)
+	case "UploadServerCertificate":
+		return i.uploadServerCertificate(form)
+	}
+
+	return nil, cfError{"InvalidAction", fmt.Sprintf("unsupported action: %s", action)}
+}
+
+func (i *IAM) simulatePrincipalPolicy(form url.Values) (interface{}, error) {
+	principal := form.Get("PolicySourceArn")
+
+	res := &iam.SimulatePolicyResponse{EvaluationResults: []*iam.EvaluationResult{}, IsTruncated: aws.Bool(false)}
+
+	for _, action := range formList(form, "ActionNames") {
+		for _, resource := range formList(form, "ResourceArns") {
+			decision, ok := i.decisions[fmt.Sprintf("%s|%s|%s", principal, action, resource)]
+			if !ok {
+				decision = iam.PolicyEvaluationDecisionTypeImplicitDeny
+			}
+
+			res.EvaluationResults = append(res.EvaluationResults, &iam.EvaluationResult{
+				EvalActionName:   aws.String(action),
+				EvalDecision:     aws.String(decision),
+				EvalResourceName: aws.String(resource),
+			})
+		}
+	}
+
+	return &struct {
+		_      struct{}                    `locationName:"SimulatePrincipalPolicyResponse"`
+		Result *iam.SimulatePolicyResponse `locationName:"SimulatePrincipalPolicyResult"`
+	}{Result: res}, nil
+}
+
+// OIDCProviders returns the arns of the registered openid connect providers
+func (i *IAM) OIDCProviders() []string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	arns := []string{}
+
+	for arn := range i.oidcProviders {
+		arns = append(arns, arn)
+	}
+
+	return arns
+}
+
+func (i *IAM) createOpenIDConnectProvider(form url.Values) (interface{}, error) {
+	u := form.Get("Url")
+
+	if !strings.HasPrefix(u, "https://") {
+		return nil, cfError{"ValidationError", "Url must begin with https://"}
+	}
+
+	arn := fmt.Sprintf("arn:aws:iam::123456789012:oidc-provider/%s", strings.TrimPrefix(u, "https://"))
+
+	if _, ok := i.oidcProviders[arn]; ok {
+		return nil, cfError{"EntityAlreadyExists", fmt.Sprintf("Provider with url %s already exists.", u)}
+	}
+
+	if i.oidcProviders == nil {
+		i.oidcProviders = map[string]*iam.GetOpenIDConnectProviderOutput{}
+	}
+
+	i.oidcProviders[arn] = &iam.GetOpenIDConnectProviderOutput{
+		ClientIDList:   aws.StringSlice(formList(form, "ClientIDList")),
+		CreateDate:     aws.Time(time.Now().UTC().Truncate(time.Second)),
+		ThumbprintList: aws.StringSlice(formList(form, "ThumbprintList")),
+		Url:            aws.String(strings.TrimPrefix(u, "https://")),
+	}
+
+	return &struct {
+		_      struct{}                               `locationName:"CreateOpenIDConnectProviderResponse"`
+		Result *iam.CreateOpenIDConnectProviderOutput `locationName:"CreateOpenIDConnectProviderResult"`
+	}{Result: &iam.CreateOpenIDConnectProviderOutput{OpenIDConnectProviderArn: aws.String(arn)}}, nil
+}
+
+func (i *IAM) deleteOpenIDConnectProvider(form url.Values) (interface{}, error) {
+	arn := form.Get("OpenIDConnectProviderArn")
+
+	if _, ok := i.oidcProviders[arn]; !ok {
+		return nil, cfError{"NoSuchEntity", fmt.Sprintf("OpenIDConnect Provider not found for arn %s", arn)}
+	}
+
+	delete(i.oidcProviders, arn)
+
+	return &struct {
+		_ struct{} `locationName:"DeleteOpenIDConnectProviderResponse"`
+	}{}, nil
+}
+
+func (i *IAM) getOpenIDConnectProvider(form url.Values) (interface{}, error) {
+	arn := form.Get("OpenIDConnectProviderArn")
+
+	res, ok := i.oidcProviders[arn]
+	if !ok {
+		return nil, cfError{"NoSuchEntity", fmt.Sprintf("OpenIDConnect Provider not found for arn %s", arn)}
+	}
+
+	return &struct {
+		_      struct{}                            `locationName:"GetOpenIDConnectProviderResponse"`
+		Result *iam.GetOpenIDConnectProviderOutput `locationName:"GetOpenIDConnectProviderResult"`
+	}{Result: res}, nil
+}
+
+// AddServerCertificate seeds a server certificate and returns its arn
+func (i *IAM) AddServerCertificate(name, body string) (string, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	form := url.Values{"ServerCertificateName": {name}, "CertificateBody": {body}, "PrivateKey": {"key"}}
+
+	if _, err := i.uploadServerCertificate(form); err != nil {
+		return "", err
+	}
+
+	return *i.serverCertificates[name].ServerCertificateMetadata.Arn, nil
+}
+
+// ServerCertificates returns the names of the uploaded server certificates
+func (i *IAM) ServerCertificates() []string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	return sortedKeys(i.serverCertificates)
+}
+
+func (i *IAM) uploadServerCertificate(form url.Values) (interface{}, error) {
+	name := form.Get("ServerCertificateName")
+
+	if _, ok := i.serverCertificates[name]; ok {
+		return nil, cfError{"EntityAlreadyExists", fmt.Sprintf("The Server Certificate with name %s already exists.", name)}
+	}
+
+	if form.Get("CertificateBody") == "" || form.Get("PrivateKey") == "" {
+		return nil, cfError{"MalformedCertificate", "Unable to parse certificate. Please ensure the certificate is in PEM format."}
+	}
+
+	if i.serverCertificates == nil {
+		i.serverCertificates = map[string]*iam.ServerCertificate{}
+	}
+
+	meta := &iam.ServerCertificateMetadata{
+		Arn:                   aws.String(fmt.Sprintf("arn:aws:iam::123456789012:server-certificate%s%s", coalesce(form.Get("Path"), "/"), name)),
+		Path:                  aws.String(coalesce(form.Get("Path"), "/")),
+		ServerCertificateId:   aws.String(fmt.Sprintf("ASCA%016X", len(i.serverCertificates)+1)),
+		ServerCertificateName: aws.String(name),
+		UploadDate:            aws.Time(time.Now().UTC().Truncate(time.Second)),
+	}
+
+	i.serverCertificates[name] = &iam.ServerCertificate{
+		CertificateBody:           aws.String(form.Get("CertificateBody")),
+		ServerCertificateMetadata: meta,
+	}
+
+	return &struct {
+		_      struct{}                           `locationName:"UploadServerCertificateResponse"`
+		Result *iam.UploadServerCertificateOutput `locationName:"UploadServerCertificateResult"`
+	}{Result: &iam.UploadServerCertificateOutput{ServerCertificateMetadata: meta}}, nil
+}
+
+func (i *IAM) deleteServerCertificate(form url.Values) (interface{}, error) {
+	name := form.Get("ServerCertificateName")
+
+	if _, ok := i.serverCertificates[name]; !ok {
+		return nil, cfError{"NoSuchEntity", fmt.Sprintf("The Server Certificate with name %s cannot be found.", name)}
+	}
+
+	delete(i.serverCertificates, name)
+
+	return &struct {
+		_ struct{} `locationName:"DeleteServerCertificateResponse"`
+	}{}, nil
+}
+
+func (i *IAM) getServerCertificate(form url.Values) (interface{}, error) {
+	name := form.Get("ServerCertificateName")
+
+	c, ok := i.serverCertificates[name]
+	if !ok {
+		return nil, cfError{"NoSuchEntity", fmt.Sprintf("The Server Certificate with name %s cannot be found.", name)}
+	}
+
+	return &struct {
+		_      struct{}                        `locationName:"GetServerCertificateResponse"`
+		Result *iam.GetServerCertificateOutput `locationName:"GetServerCertificateResult"`
+	}{Result: &iam.GetServerCertificateOutput{ServerCertificate: c}}, nil
+}
+
+func (i *IAM) listServerCertificates(form url.Values) (interface{}, error) {
+	res := &iam.ListServerCertificatesOutput{
+		IsTruncated:                   aws.Bool(false),
+		ServerCertificateMetadataList: []*iam.ServerCertificateMetadata{},
+	}
+
+	for _, name := range sortedKeys(i.serverCertificates) {
+		res.ServerCertificateMetadataList = append(res.ServerCertificateMetadataList, i.serverCertificates[name].ServerCertificateMetadata)
+	}
+
+	return &struct {
+		_      struct{}                          `locationName:"ListServerCertificatesResponse"`
+		Result *iam.ListServerCertificatesOutput `locationName:"ListServerCertificatesResult"`
+	}{Result: res}, nil
+}
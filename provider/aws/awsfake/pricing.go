@@ -0,0 +1,115 @@
+package awsfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/pricing"
+)
+
+// Product is an entry in the fake price list with a single on-demand USD price
+type Product struct {
+	ServiceCode string
+	Attributes  map[string]string
+	USD         string
+}
+
+// Pricing is an in-memory AWS Price List api
+type Pricing struct {
+	lock     sync.Mutex
+	products []Product
+	requests int
+}
+
+// AddProduct adds a product to the price list
+func (p *Pricing) AddProduct(product Product) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.products = append(p.products, product)
+}
+
+// Requests returns the number of GetProducts requests received
+func (p *Pricing) Requests() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.requests
+}
+
+func (p *Pricing) serve(w http.ResponseWriter, r *http.Request, operation string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if operation != "GetProducts" {
+		writeJSONError(w, 400, "UnknownOperationException", fmt.Sprintf("unsupported operation: %s", operation))
+		return
+	}
+
+	p.requests++
+
+	var req pricing.GetProductsInput
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		writeJSONError(w, 400, "InvalidParameterException", err.Error())
+		return
+	}
+
+	list := []string{}
+
+	for _, product := range p.products {
+		if product.ServiceCode != aws.StringValue(req.ServiceCode) || !productMatches(product, req.Filters) {
+			continue
+		}
+
+		data, err := json.Marshal(productDocument(product))
+		if err != nil {
+			writeJSONError(w, 500, "InternalErrorException", err.Error())
+			return
+		}
+
+		list = append(list, string(data))
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"FormatVersion": "aws_v1", "PriceList": list})
+	if err != nil {
+		writeJSONError(w, 500, "InternalErrorException", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.Write(data)
+}
+
+func productMatches(product Product, filters []*pricing.Filter) bool {
+	for _, f := range filters {
+		if product.Attributes[aws.StringValue(f.Field)] != aws.StringValue(f.Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func productDocument(product Product) map[string]interface{} {
+	return map[string]interface{}{
+		"product": map[string]interface{}{
+			"attributes": product.Attributes,
+		},
+		"terms": map[string]interface{}{
+			"OnDemand": map[string]interface{}{
+				"SKU.OFFER": map[string]interface{}{
+					"priceDimensions": map[string]interface{}{
+						"SKU.OFFER.RATE": map[string]interface{}{
+							"pricePerUnit": map[string]string{"USD": product.USD},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,244 @@
+package awsfake
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// S3 is an in-memory S3 with buckets and objects, including user metadata
+// It serves path style requests for single part uploads
+type S3 struct {
+	buckets map[string]map[string]*fakeObject
+	clock   *Clock
+	lock    sync.Mutex
+}
+
+type fakeObject struct {
+	acl                string
+	cacheControl       string
+	contentDisposition string
+	contentType        string
+	data               []byte
+	metadata           map[string]string
+	modified           time.Time
+	tagging            string
+}
+
+// CreateBucket creates an empty bucket if it does not already exist
+func (s *S3) CreateBucket(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.createBucket(name)
+}
+
+// Object returns the contents of an object
+func (s *S3) Object(bucket, key string) ([]byte, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	o, ok := s.buckets[bucket][key]
+	if !ok {
+		return nil, false
+	}
+
+	return o.data, true
+}
+
+// Metadata returns the user metadata of an object
+func (s *S3) Metadata(bucket, key string) (map[string]string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	o, ok := s.buckets[bucket][key]
+	if !ok {
+		return nil, false
+	}
+
+	return copyMap(o.metadata), true
+}
+
+// Headers returns the Cache-Control, Content-Disposition and Content-Type an object was stored with
+func (s *S3) Headers(bucket, key string) (map[string]string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	o, ok := s.buckets[bucket][key]
+	if !ok {
+		return nil, false
+	}
+
+	headers := map[string]string{}
+
+	for k, v := range map[string]string{"Cache-Control": o.cacheControl, "Content-Disposition": o.contentDisposition, "Content-Type": o.contentType} {
+		if v != "" {
+			headers[k] = v
+		}
+	}
+
+	return headers, true
+}
+
+// Tagging returns the x-amz-tagging header an object was stored with
+func (s *S3) Tagging(bucket, key string) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	o, ok := s.buckets[bucket][key]
+	if !ok {
+		return "", false
+	}
+
+	return o.tagging, true
+}
+
+// PutObject stores an object, creating the bucket if needed
+func (s *S3) PutObject(bucket, key string, data []byte, metadata map[string]string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.createBucket(bucket)
+
+	s.buckets[bucket][key] = &fakeObject{data: data, metadata: copyMap(metadata), modified: s.clock.Now()}
+}
+
+func (s *S3) createBucket(name string) {
+	if s.buckets == nil {
+		s.buckets = map[string]map[string]*fakeObject{}
+	}
+
+	if _, ok := s.buckets[name]; !ok {
+		s.buckets[name] = map[string]*fakeObject{}
+	}
+}
+
+func (s *S3) serve(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+
+	bucket := parts[0]
+	key := ""
+
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+
+	if q := r.URL.Query(); q.Get("uploadId") != "" || q["uploads"] != nil {
+		writeS3Error(w, 501, "NotImplemented", "multipart uploads are not supported")
+		return
+	}
+
+	if key == "" {
+		switch r.Method {
+		case "PUT":
+			s.createBucket(bucket)
+		case "DELETE":
+			if len(s.buckets[bucket]) > 0 {
+				writeS3Error(w, 409, "BucketNotEmpty", "The bucket you tried to delete is not empty")
+				return
+			}
+
+			delete(s.buckets, bucket)
+			w.WriteHeader(204)
+		default:
+			writeS3Error(w, 501, "NotImplemented", fmt.Sprintf("unsupported bucket operation: %s", r.Method))
+		}
+		return
+	}
+
+	objects, ok := s.buckets[bucket]
+	if !ok {
+		if r.Method == "HEAD" {
+			w.WriteHeader(404)
+			return
+		}
+
+		writeS3Error(w, 404, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, 400, "IncompleteBody", err.Error())
+			return
+		}
+
+		o := &fakeObject{
+			acl:                r.Header.Get("X-Amz-Acl"),
+			cacheControl:       r.Header.Get("Cache-Control"),
+			contentDisposition: r.Header.Get("Content-Disposition"),
+			contentType:        r.Header.Get("Content-Type"),
+			data:               data,
+			metadata:           map[string]string{},
+			modified:           s.clock.Now(),
+			tagging:            r.Header.Get("X-Amz-Tagging"),
+		}
+
+		for k := range r.Header {
+			if strings.HasPrefix(strings.ToLower(k), "x-amz-meta-") {
+				o.metadata[strings.ToLower(strings.TrimPrefix(strings.ToLower(k), "x-amz-meta-"))] = r.Header.Get(k)
+			}
+		}
+
+		objects[key] = o
+
+		w.Header().Set("ETag", o.etag())
+	case "GET", "HEAD":
+		o, ok := objects[key]
+		if !ok {
+			if r.Method == "HEAD" {
+				w.WriteHeader(404)
+				return
+			}
+
+			writeS3Error(w, 404, "NoSuchKey", "The specified key does not exist.")
+			return
+		}
+
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(o.data)))
+		w.Header().Set("Content-Type", coalesce(o.contentType, "binary/octet-stream"))
+
+		if o.cacheControl != "" {
+			w.Header().Set("Cache-Control", o.cacheControl)
+		}
+
+		if o.contentDisposition != "" {
+			w.Header().Set("Content-Disposition", o.contentDisposition)
+		}
+		w.Header().Set("ETag", o.etag())
+		w.Header().Set("Last-Modified", o.modified.UTC().Format(http.TimeFormat))
+
+		for k, v := range o.metadata {
+			w.Header().Set("X-Amz-Meta-"+k, v)
+		}
+
+		if r.Method == "GET" {
+			w.Write(o.data)
+		}
+	case "DELETE":
+		delete(objects, key)
+		w.WriteHeader(204)
+	default:
+		writeS3Error(w, 501, "NotImplemented", fmt.Sprintf("unsupported object operation: %s", r.Method))
+	}
+}
+
+func (o *fakeObject) etag() string {
+	return fmt.Sprintf("%q", fmt.Sprintf("%x", md5.Sum(o.data)))
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message><RequestId>fake</RequestId></Error>", code, xmlEscape(message))
+}
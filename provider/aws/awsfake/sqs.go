@@ -0,0 +1,312 @@
+package awsfake
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// sqsActions are the query actions served by the SQS fake rather than CloudFormation
+var sqsActions = map[string]bool{
+	"DeleteMessage":  true,
+	"GetQueueUrl":    true,
+	"PurgeQueue":     true,
+	"ReceiveMessage": true,
+	"SendMessage":    true,
+}
+
+// SQS is an in-memory SQS with standard queues
+// Received messages stay hidden until they are deleted, visibility timeouts never expire
+type SQS struct {
+	count  int
+	lock   sync.Mutex
+	queues []*fakeQueue
+}
+
+type fakeQueue struct {
+	arn      string
+	name     string
+	url      string
+	messages []*fakeMessage
+	receives int
+}
+
+type fakeMessage struct {
+	body     string
+	id       string
+	inflight bool
+	receipt  string
+	received int
+}
+
+// AddQueue creates an empty queue and returns its url
+func (s *SQS) AddQueue(name string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	q := &fakeQueue{
+		arn:  fmt.Sprintf("arn:aws:sqs:%s:123456789012:%s", Region, name),
+		name: name,
+		url:  fmt.Sprintf("https://sqs.%s.amazonaws.com/123456789012/%s", Region, name),
+	}
+
+	s.queues = append(s.queues, q)
+
+	return q.url
+}
+
+// QueueArn returns the arn of the queue at url
+func (s *SQS) QueueArn(url string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if q := s.find(url); q != nil {
+		return q.arn
+	}
+
+	return ""
+}
+
+// Send adds a message to the queue at url
+func (s *SQS) Send(url, body string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	q := s.find(url)
+	if q == nil {
+		return queueNotFound()
+	}
+
+	s.send(q, body)
+
+	return nil
+}
+
+// Pending returns the number of messages in the queue at url that have not been deleted
+func (s *SQS) Pending(url string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if q := s.find(url); q != nil {
+		return len(q.messages)
+	}
+
+	return 0
+}
+
+// Receives returns the number of ReceiveMessage requests made against the queue at url
+func (s *SQS) Receives(url string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if q := s.find(url); q != nil {
+		return q.receives
+	}
+
+	return 0
+}
+
+func (s *SQS) serve(ctx context.Context, action string, form url.Values) (interface{}, error) {
+	if action == "ReceiveMessage" {
+		return s.receiveMessage(ctx, form)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch action {
+	case "DeleteMessage":
+		return s.deleteMessage(form)
+	case "GetQueueUrl":
+		return s.getQueueUrl(form)
+	case "PurgeQueue":
+		return s.purgeQueue(form)
+	case "SendMessage":
+		return s.sendMessage(form)
+	}
+
+	return nil, cfError{"InvalidAction", fmt.Sprintf("unsupported action: %s", action)}
+}
+
+func queueNotFound() error {
+	return cfError{"AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version."}
+}
+
+func (s *SQS) find(url string) *fakeQueue {
+	for _, q := range s.queues {
+		if q.url == url || q.name == url {
+			return q
+		}
+	}
+
+	return nil
+}
+
+func (s *SQS) send(q *fakeQueue, body string) *fakeMessage {
+	s.count++
+
+	m := &fakeMessage{
+		body:    body,
+		id:      fmt.Sprintf("%08d-0000-0000-0000-000000000000", s.count),
+		receipt: fmt.Sprintf("receipt-%d", s.count),
+	}
+
+	q.messages = append(q.messages, m)
+
+	return m
+}
+
+func (s *SQS) deleteMessage(form url.Values) (interface{}, error) {
+	q := s.find(form.Get("QueueUrl"))
+	if q == nil {
+		return nil, queueNotFound()
+	}
+
+	for i, m := range q.messages {
+		if m.receipt == form.Get("ReceiptHandle") {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			break
+		}
+	}
+
+	return &struct {
+		_ struct{} `locationName:"DeleteMessageResponse"`
+	}{}, nil
+}
+
+func (s *SQS) getQueueUrl(form url.Values) (interface{}, error) {
+	q := s.find(form.Get("QueueName"))
+	if q == nil {
+		return nil, queueNotFound()
+	}
+
+	return &struct {
+		_      struct{}               `locationName:"GetQueueUrlResponse"`
+		Result *sqs.GetQueueUrlOutput `locationName:"GetQueueUrlResult"`
+	}{Result: &sqs.GetQueueUrlOutput{QueueUrl: aws.String(q.url)}}, nil
+}
+
+// receiveMessage waits up to WaitTimeSeconds for a message like a long poll, without holding the lock so sends can arrive
+func (s *SQS) receiveMessage(ctx context.Context, form url.Values) (interface{}, error) {
+	max, _ := strconv.Atoi(form.Get("MaxNumberOfMessages"))
+	if max < 1 {
+		max = 1
+	}
+
+	wait, _ := strconv.Atoi(form.Get("WaitTimeSeconds"))
+	deadline := time.Now().Add(time.Duration(wait) * time.Second)
+
+	attributes := false
+
+	for k, v := range form {
+		if strings.HasPrefix(k, "AttributeName.") && len(v) > 0 && (v[0] == "All" || v[0] == "ApproximateReceiveCount") {
+			attributes = true
+		}
+	}
+
+	s.lock.Lock()
+	if q := s.find(form.Get("QueueUrl")); q != nil {
+		q.receives++
+	}
+	s.lock.Unlock()
+
+	for {
+		ms, err := s.receive(form.Get("QueueUrl"), max, attributes)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(ms) > 0 || time.Now().After(deadline) {
+			return &struct {
+				_      struct{} `locationName:"ReceiveMessageResponse"`
+				Result *struct {
+					Messages []*sqs.Message `locationName:"Message" type:"list" flattened:"true"`
+				} `locationName:"ReceiveMessageResult"`
+			}{Result: &struct {
+				Messages []*sqs.Message `locationName:"Message" type:"list" flattened:"true"`
+			}{Messages: ms}}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (s *SQS) receive(url string, max int, attributes bool) ([]*sqs.Message, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	q := s.find(url)
+	if q == nil {
+		return nil, queueNotFound()
+	}
+
+	ms := []*sqs.Message{}
+
+	for _, m := range q.messages {
+		if len(ms) >= max {
+			break
+		}
+
+		if m.inflight {
+			continue
+		}
+
+		m.inflight = true
+		m.received++
+
+		// the sdk rejects received messages whose body does not match its checksum
+		sm := &sqs.Message{
+			Body:          aws.String(m.body),
+			MD5OfBody:     aws.String(fmt.Sprintf("%x", md5.Sum([]byte(m.body)))),
+			MessageId:     aws.String(m.id),
+			ReceiptHandle: aws.String(m.receipt),
+		}
+
+		if attributes {
+			sm.Attributes = map[string]*string{"ApproximateReceiveCount": aws.String(strconv.Itoa(m.received))}
+		}
+
+		ms = append(ms, sm)
+	}
+
+	return ms, nil
+}
+
+func (s *SQS) purgeQueue(form url.Values) (interface{}, error) {
+	q := s.find(form.Get("QueueUrl"))
+	if q == nil {
+		return nil, queueNotFound()
+	}
+
+	q.messages = nil
+
+	return &struct {
+		_ struct{} `locationName:"PurgeQueueResponse"`
+	}{}, nil
+}
+
+func (s *SQS) sendMessage(form url.Values) (interface{}, error) {
+	q := s.find(form.Get("QueueUrl"))
+	if q == nil {
+		return nil, queueNotFound()
+	}
+
+	m := s.send(q, form.Get("MessageBody"))
+
+	return &struct {
+		_      struct{}               `locationName:"SendMessageResponse"`
+		Result *sqs.SendMessageOutput `locationName:"SendMessageResult"`
+	}{Result: &sqs.SendMessageOutput{MD5OfMessageBody: aws.String(fmt.Sprintf("%x", md5.Sum([]byte(m.body)))), MessageId: aws.String(m.id)}}, nil
+}
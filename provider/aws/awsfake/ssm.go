@@ -0,0 +1,238 @@
+package awsfake
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+)
+
+// SSM is an in-memory SSM with parameters and managed instances that run commands
+// Commands sent to an instance are reported in progress by the first GetCommandInvocation and
+// complete with the output set for the instance by the next one
+type SSM struct {
+	lock        sync.Mutex
+	commands    []SSMCommand
+	invocations map[string]*fakeInvocation
+	managed     map[string]bool
+	outputs     map[string]string
+	parameters  map[string]string
+}
+
+// SSMCommand is a command sent to a managed instance
+type SSMCommand struct {
+	Id           string
+	DocumentName string
+	InstanceId   string
+	Commands     []string
+}
+
+type fakeInvocation struct {
+	instance string
+	polls    int
+}
+
+type ssmRequest struct {
+	CommandId    *string
+	DocumentName *string
+	Filters      []struct {
+		Key    *string
+		Values []*string
+	}
+	InstanceId  *string
+	InstanceIds []*string
+	Name        *string
+	Parameters  map[string][]*string
+}
+
+// AddManagedInstance registers an instance whose ssm agent is online
+func (f *SSM) AddManagedInstance(id string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.managed == nil {
+		f.managed = map[string]bool{}
+	}
+
+	f.managed[id] = true
+}
+
+// SetParameter sets the value of a parameter
+func (f *SSM) SetParameter(name, value string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.parameters == nil {
+		f.parameters = map[string]string{}
+	}
+
+	f.parameters[name] = value
+}
+
+// SetOutput sets the standard output of commands run on an instance
+func (f *SSM) SetOutput(instance, output string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.outputs == nil {
+		f.outputs = map[string]string{}
+	}
+
+	f.outputs[instance] = output
+}
+
+// Commands returns the commands sent so far
+func (f *SSM) Commands() []SSMCommand {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return append([]SSMCommand{}, f.commands...)
+}
+
+func (f *SSM) serve(w http.ResponseWriter, r *http.Request, operation string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var req ssmRequest
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		writeJSONError(w, 400, "ValidationException", err.Error())
+		return
+	}
+
+	var res interface{}
+	var err error
+
+	switch operation {
+	case "DescribeInstanceInformation":
+		res = f.describeInstanceInformation(req)
+	case "GetCommandInvocation":
+		res, err = f.getCommandInvocation(req)
+	case "GetParameter":
+		res, err = f.getParameter(req)
+	case "SendCommand":
+		res, err = f.sendCommand(req)
+	default:
+		err = ssmError{"UnknownOperationException", fmt.Sprintf("unsupported operation: %s", operation)}
+	}
+
+	if err != nil {
+		se, ok := err.(ssmError)
+		if !ok {
+			se = ssmError{"InternalServerError", err.Error()}
+		}
+
+		writeJSONError(w, 400, se.code, se.message)
+		return
+	}
+
+	writeJSON(w, res)
+}
+
+type ssmError struct {
+	code    string
+	message string
+}
+
+func (e ssmError) Error() string {
+	return e.message
+}
+
+type ssmInstanceInformation struct {
+	InstanceId *string
+	PingStatus *string
+}
+
+func (f *SSM) describeInstanceInformation(req ssmRequest) interface{} {
+	list := []*ssmInstanceInformation{}
+
+	for _, filter := range req.Filters {
+		if aws.StringValue(filter.Key) != "InstanceIds" {
+			continue
+		}
+
+		for _, id := range aws.StringValueSlice(filter.Values) {
+			if f.managed[id] {
+				list = append(list, &ssmInstanceInformation{InstanceId: aws.String(id), PingStatus: aws.String("Online")})
+			}
+		}
+	}
+
+	return &struct {
+		InstanceInformationList []*ssmInstanceInformation
+	}{InstanceInformationList: list}
+}
+
+func (f *SSM) sendCommand(req ssmRequest) (interface{}, error) {
+	if len(req.InstanceIds) != 1 {
+		return nil, ssmError{"ValidationException", "fake ssm sends commands to exactly one instance"}
+	}
+
+	instance := aws.StringValue(req.InstanceIds[0])
+
+	if !f.managed[instance] {
+		return nil, ssmError{"InvalidInstanceId", fmt.Sprintf("instance is not managed: %s", instance)}
+	}
+
+	if f.invocations == nil {
+		f.invocations = map[string]*fakeInvocation{}
+	}
+
+	id := fmt.Sprintf("%08d-0000-0000-0000-000000000000", len(f.commands)+1)
+
+	f.commands = append(f.commands, SSMCommand{
+		Id:           id,
+		DocumentName: aws.StringValue(req.DocumentName),
+		InstanceId:   instance,
+		Commands:     aws.StringValueSlice(req.Parameters["commands"]),
+	})
+
+	f.invocations[id] = &fakeInvocation{instance: instance}
+
+	return &struct {
+		Command struct {
+			CommandId *string
+		}
+	}{Command: struct{ CommandId *string }{CommandId: aws.String(id)}}, nil
+}
+
+func (f *SSM) getCommandInvocation(req ssmRequest) (interface{}, error) {
+	inv, ok := f.invocations[aws.StringValue(req.CommandId)]
+	if !ok || inv.instance != aws.StringValue(req.InstanceId) {
+		return nil, ssmError{"InvocationDoesNotExist", "An error occurred (InvocationDoesNotExist)"}
+	}
+
+	inv.polls++
+
+	if inv.polls == 1 {
+		return &ssmInvocation{Status: aws.String("InProgress")}, nil
+	}
+
+	return &ssmInvocation{Status: aws.String("Success"), StandardOutputContent: aws.String(f.outputs[inv.instance])}, nil
+}
+
+func (f *SSM) getParameter(req ssmRequest) (interface{}, error) {
+	name := aws.StringValue(req.Name)
+
+	value, ok := f.parameters[name]
+	if !ok {
+		return nil, ssmError{"ParameterNotFound", fmt.Sprintf("parameter not found: %s", name)}
+	}
+
+	return &struct {
+		Parameter *ssmParameter
+	}{Parameter: &ssmParameter{Name: aws.String(name), Value: aws.String(value)}}, nil
+}
+
+type ssmParameter struct {
+	Name  *string
+	Value *string
+}
+
+type ssmInvocation struct {
+	Status                *string
+	StandardErrorContent  *string
+	StandardOutputContent *string
+}
@@ -0,0 +1,187 @@
+package awsfake
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+)
+
+// WAFv2 is an in-memory WAFv2 with regional web acls, their tags and the resources they are associated with
+// Web acls have no rules, they only exist to be associated and tagged
+type WAFv2 struct {
+	lock         sync.Mutex
+	acls         []*fakeWebACL
+	associations map[string]string
+}
+
+type fakeWebACL struct {
+	arn  string
+	id   string
+	name string
+	tags map[string]string
+}
+
+type wafWebACL struct {
+	ARN  *string
+	Id   *string
+	Name *string
+}
+
+type wafRequest struct {
+	ResourceArn *string
+	ResourceARN *string
+	Tags        []struct {
+		Key   *string
+		Value *string
+	}
+	WebACLArn *string
+}
+
+// AddWebACL creates a regional web acl and returns its arn
+func (f *WAFv2) AddWebACL(name string) string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	id := fmt.Sprintf("%08d-0000-0000-0000-000000000000", len(f.acls)+1)
+
+	acl := &fakeWebACL{
+		arn:  fmt.Sprintf("arn:aws:wafv2:%s:123456789012:regional/webacl/%s/%s", Region, name, id),
+		id:   id,
+		name: name,
+		tags: map[string]string{},
+	}
+
+	f.acls = append(f.acls, acl)
+
+	return acl.arn
+}
+
+// Associated returns the arn of the web acl associated with resource or an empty string if there is none
+func (f *WAFv2) Associated(resource string) string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.associations[resource]
+}
+
+// Tags returns a copy of the tags on the web acl with arn
+func (f *WAFv2) Tags(arn string) map[string]string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	tags := map[string]string{}
+
+	if acl := f.find(arn); acl != nil {
+		for k, v := range acl.tags {
+			tags[k] = v
+		}
+	}
+
+	return tags
+}
+
+func (f *WAFv2) serve(w http.ResponseWriter, r *http.Request, operation string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var req wafRequest
+
+	if err := jsonutil.UnmarshalJSON(&req, r.Body); err != nil {
+		writeJSONError(w, 400, "WAFInvalidParameterException", err.Error())
+		return
+	}
+
+	if f.associations == nil {
+		f.associations = map[string]string{}
+	}
+
+	var res interface{}
+	var err error
+
+	switch operation {
+	case "AssociateWebACL":
+		res, err = f.associateWebACL(req)
+	case "DisassociateWebACL":
+		delete(f.associations, aws.StringValue(req.ResourceArn))
+		res = struct{}{}
+	case "GetWebACLForResource":
+		res, err = f.getWebACLForResource(req)
+	case "TagResource":
+		res, err = f.tagResource(req)
+	default:
+		err = wafError{"UnknownOperationException", fmt.Sprintf("unsupported operation: %s", operation)}
+	}
+
+	if err != nil {
+		we, ok := err.(wafError)
+		if !ok {
+			we = wafError{"WAFInternalErrorException", err.Error()}
+		}
+
+		writeJSONError(w, 400, we.code, we.message)
+		return
+	}
+
+	writeJSON(w, res)
+}
+
+type wafError struct {
+	code    string
+	message string
+}
+
+func (e wafError) Error() string {
+	return e.message
+}
+
+func webACLNotFound() error {
+	return wafError{"WAFNonexistentItemException", "AWS WAF couldn't perform the operation because your resource doesn't exist."}
+}
+
+func (f *WAFv2) find(arn string) *fakeWebACL {
+	for _, acl := range f.acls {
+		if acl.arn == arn {
+			return acl
+		}
+	}
+
+	return nil
+}
+
+func (f *WAFv2) associateWebACL(req wafRequest) (interface{}, error) {
+	acl := f.find(aws.StringValue(req.WebACLArn))
+	if acl == nil {
+		return nil, webACLNotFound()
+	}
+
+	f.associations[aws.StringValue(req.ResourceArn)] = acl.arn
+
+	return struct{}{}, nil
+}
+
+func (f *WAFv2) getWebACLForResource(req wafRequest) (interface{}, error) {
+	acl := f.find(f.associations[aws.StringValue(req.ResourceArn)])
+	if acl == nil {
+		return struct{}{}, nil
+	}
+
+	return &struct {
+		WebACL *wafWebACL
+	}{WebACL: &wafWebACL{ARN: aws.String(acl.arn), Id: aws.String(acl.id), Name: aws.String(acl.name)}}, nil
+}
+
+func (f *WAFv2) tagResource(req wafRequest) (interface{}, error) {
+	acl := f.find(aws.StringValue(req.ResourceARN))
+	if acl == nil {
+		return nil, webACLNotFound()
+	}
+
+	for _, t := range req.Tags {
+		acl.tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+
+	return struct{}{}, nil
+}
@@ -0,0 +1,210 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/convox/rack/pkg/manifest"
+)
+
+const (
+	bluegreenBlue  = "blue"
+	bluegreenGreen = "green"
+)
+
+// bluegreenState is the deployment state of a blue-green service, read from the parameters of its service stack
+// each color is its own ecs service registered with its own target group, Pinned holds the task definition
+// a color is kept on and is empty for a color that runs the current release
+type bluegreenState struct {
+	Active       string
+	Healthy      map[string]int
+	Pinned       map[string]string
+	Services     map[string]string
+	TargetGroups map[string]string
+}
+
+// standby returns the color that does not receive traffic
+func (s bluegreenState) standby() string {
+	if s.Active == bluegreenGreen {
+		return bluegreenBlue
+	}
+
+	return bluegreenGreen
+}
+
+// bluegreenStatus reads which color of a service receives traffic, what each color runs and how many healthy targets each has
+func (p *Provider) bluegreenStatus(app, service string) (*bluegreenState, error) {
+	rs, err := p.appResourcesRecursive(app)
+	if err != nil {
+		return nil, err
+	}
+
+	id := fmt.Sprintf("Service%s", upperName(service))
+
+	s := &bluegreenState{
+		Healthy:      map[string]int{},
+		Pinned:       map[string]string{},
+		Services:     map[string]string{},
+		TargetGroups: map[string]string{},
+	}
+
+	for _, suffix := range []string{"", "Internal"} {
+		if arn, ok := rs[id+".BalancerTargetGroup"+suffix]; ok {
+			s.TargetGroups[bluegreenBlue] = arn
+		}
+
+		if arn, ok := rs[id+".BalancerTargetGroupGreen"+suffix]; ok {
+			s.TargetGroups[bluegreenGreen] = arn
+		}
+	}
+
+	if s.TargetGroups[bluegreenBlue] == "" {
+		return nil, errorNotFoundOf(ErrServiceNotFound, service)
+	}
+
+	if s.TargetGroups[bluegreenGreen] == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNotBlueGreen, service)
+	}
+
+	stack, err := p.describeStack(rs[id])
+	if err != nil {
+		return nil, err
+	}
+
+	params := stackParameters(stack)
+	outputs := stackOutputs(stack)
+
+	s.Active = coalesces(params["Active"], bluegreenBlue)
+	s.Pinned[bluegreenBlue] = params["TasksBlue"]
+	s.Pinned[bluegreenGreen] = params["TasksGreen"]
+	s.Services[bluegreenBlue] = outputs["Service"]
+	s.Services[bluegreenGreen] = outputs["ServiceGreen"]
+
+	for color, arn := range s.TargetGroups {
+		hres, err := p.elbv2().DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{TargetGroupArn: aws.String(arn)})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range hres.TargetHealthDescriptions {
+			if d.TargetHealth != nil && aws.StringValue(d.TargetHealth.State) == "healthy" {
+				s.Healthy[color]++
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// bluegreenPromote moves the traffic of a service to its standby color once that color runs the current release
+// and has healthy targets
+func (p *Provider) bluegreenPromote(app, service string) error {
+	log := Logger.At("bluegreenPromote").Namespace("app=%s service=%s", app, service).Start()
+
+	s, err := p.bluegreenStatus(app, service)
+	if err != nil {
+		return log.Error(err)
+	}
+
+	color := s.standby()
+
+	if s.Pinned[color] != "" {
+		return log.Error(fmt.Errorf("service %s %s deployment runs an earlier release, promote a release first", service, color))
+	}
+
+	if err := p.bluegreenActivate(app, service, s, color); err != nil {
+		return log.Error(err)
+	}
+
+	return log.Successf("active=%s", color)
+}
+
+// bluegreenRollback moves the traffic of a service back to its standby color while that color still runs the earlier release
+func (p *Provider) bluegreenRollback(app, service string) error {
+	log := Logger.At("bluegreenRollback").Namespace("app=%s service=%s", app, service).Start()
+
+	s, err := p.bluegreenStatus(app, service)
+	if err != nil {
+		return log.Error(err)
+	}
+
+	color := s.standby()
+
+	if s.Pinned[color] == "" {
+		return log.Error(fmt.Errorf("service %s has no earlier release to roll back to", service))
+	}
+
+	if err := p.bluegreenActivate(app, service, s, color); err != nil {
+		return log.Error(err)
+	}
+
+	return log.Successf("active=%s", color)
+}
+
+// bluegreenActivate points the listener rules of a service at color by updating the app stack,
+// the rules belong to the service stack so a later update of the stack keeps them where they are
+func (p *Provider) bluegreenActivate(app, service string, s *bluegreenState, color string) error {
+	if s.Healthy[color] == 0 {
+		return fmt.Errorf("service %s %s target group has no healthy targets", service, color)
+	}
+
+	changes := map[string]string{
+		fmt.Sprintf("%sActive", upperName(service)): color,
+	}
+
+	return p.updateStack(p.rackStack(app), nil, changes, map[string]string{}, "")
+}
+
+// bluegreenReleaseChanges returns the app stack parameters that keep the active color of each blue-green service
+// on the task definition it runs, so promoting a release only replaces the tasks of the standby color
+func (p *Provider) bluegreenReleaseChanges(app string, m *manifest.Manifest) (map[string]string, error) {
+	changes := map[string]string{}
+
+	for _, ms := range m.Services {
+		if !ms.BlueGreen() {
+			continue
+		}
+
+		// services that are new or are switching to blue-green start with both colors on the release
+		s, err := p.bluegreenStatus(app, ms.Name)
+		if errors.Is(err, ErrServiceNotFound) || errors.Is(err, ErrNotBlueGreen) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := upperName(ms.Name)
+
+		// carried over explicitly so reconciling drifted parameters does not move traffic back to blue
+		changes[fmt.Sprintf("%sActive", name)] = s.Active
+
+		task := s.Pinned[s.Active]
+
+		if task == "" && s.Services[s.Active] != "" {
+			ss, _, err := p.describeServicesChecked(&ecs.DescribeServicesInput{
+				Cluster:  aws.String(p.Cluster),
+				Services: []*string{aws.String(s.Services[s.Active])},
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			if len(ss) == 1 {
+				task = aws.StringValue(ss[0].TaskDefinition)
+			}
+		}
+
+		if task == "" {
+			continue
+		}
+
+		changes[fmt.Sprintf("%sTasks%s", name, upperName(s.Active))] = task
+		changes[fmt.Sprintf("%sTasks%s", name, upperName(s.standby()))] = ""
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,162 @@
+package aws_test
+
+import (
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/convox/rack/pkg/manifest"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	bluegreenBlue      = "arn:aws:elasticloadbalancing:us-test-1:123456789012:targetgroup/httpd-web-blue/1111111111111111"
+	bluegreenGreen     = "arn:aws:elasticloadbalancing:us-test-1:123456789012:targetgroup/httpd-web-green/2222222222222222"
+	bluegreenBlueTasks = "arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-ServiceWeb-1ABCDEF-service-web:4"
+	bluegreenNewTasks  = "arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-ServiceWeb-1ABCDEF-service-web:5"
+)
+
+// bluegreenProvider seeds the app stack of httpd with a web service stack, params are the app stack parameters
+// of the service and are passed down to the service stack as they would be by the app template
+func bluegreenProvider(green bool, params map[string]string) *awsfake.TestProvider {
+	provider := awsfake.NewTestProvider()
+
+	resources := []awsfake.Resource{
+		{LogicalId: "BalancerListenerRule443", PhysicalId: "convox-httpd-ServiceWeb-BalancerListenerRule443", Type: "AWS::ElasticLoadBalancingV2::ListenerRule"},
+		{LogicalId: "BalancerTargetGroup", PhysicalId: bluegreenBlue, Type: "AWS::ElasticLoadBalancingV2::TargetGroup"},
+		{LogicalId: "Service", PhysicalId: "arn:aws:ecs:us-test-1:123456789012:service/cluster-test/httpd-web-blue", Type: "AWS::ECS::Service"},
+	}
+
+	outputs := map[string]string{"Service": "arn:aws:ecs:us-test-1:123456789012:service/cluster-test/httpd-web-blue"}
+	sparams := map[string]string{}
+
+	if green {
+		resources = append(resources,
+			awsfake.Resource{LogicalId: "BalancerTargetGroupGreen", PhysicalId: bluegreenGreen, Type: "AWS::ElasticLoadBalancingV2::TargetGroup"},
+			awsfake.Resource{LogicalId: "ServiceGreen", PhysicalId: "arn:aws:ecs:us-test-1:123456789012:service/cluster-test/httpd-web-green", Type: "AWS::ECS::Service"},
+		)
+
+		outputs["ServiceGreen"] = "arn:aws:ecs:us-test-1:123456789012:service/cluster-test/httpd-web-green"
+
+		sparams = map[string]string{"Active": params["WebActive"], "TasksBlue": params["WebTasksBlue"], "TasksGreen": params["WebTasksGreen"]}
+	}
+
+	web := provider.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name:       "convox-httpd-ServiceWeb-1ABCDEF",
+		Outputs:    outputs,
+		Parameters: sparams,
+		Resources:  resources,
+	})
+
+	provider.AddApp("httpd", "2", awsfake.Stack{
+		Parameters: params,
+		Resources: []awsfake.Resource{
+			{LogicalId: "ServiceWeb", PhysicalId: web, Type: "AWS::CloudFormation::Stack"},
+		},
+	})
+
+	provider.Fake.ELBv2.SetTargetHealth(bluegreenBlue, "healthy", "healthy")
+
+	return provider
+}
+
+func TestBluegreenPromote(t *testing.T) {
+	provider := bluegreenProvider(true, map[string]string{"WebActive": "blue", "WebTasksBlue": bluegreenBlueTasks, "WebTasksGreen": ""})
+	defer provider.Close()
+
+	provider.Fake.ELBv2.SetTargetHealth(bluegreenGreen, "healthy", "initial")
+
+	s, err := provider.BluegreenStatus("httpd", "web")
+	require.NoError(t, err)
+	assert.Equal(t, "blue", s.Active)
+	assert.Equal(t, map[string]int{"blue": 2, "green": 1}, s.Healthy)
+	assert.Equal(t, map[string]string{"blue": bluegreenBlueTasks, "green": ""}, s.Pinned)
+	assert.Equal(t, map[string]string{"blue": bluegreenBlue, "green": bluegreenGreen}, s.TargetGroups)
+
+	require.NoError(t, provider.BluegreenPromote("httpd", "web"))
+
+	// the listener rules follow the parameter through the template, nothing modifies them out of band
+	stack, ok := provider.Fake.CloudFormation.Stack("convox-httpd")
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"WebActive": "green", "WebTasksBlue": bluegreenBlueTasks, "WebTasksGreen": ""}, stack.Parameters)
+	assert.Empty(t, provider.Fake.ELBv2.ModifiedRules())
+}
+
+func TestBluegreenPromoteUnhealthy(t *testing.T) {
+	provider := bluegreenProvider(true, map[string]string{"WebActive": "blue", "WebTasksBlue": bluegreenBlueTasks, "WebTasksGreen": ""})
+	defer provider.Close()
+
+	provider.Fake.ELBv2.SetTargetHealth(bluegreenGreen, "initial", "unhealthy")
+
+	err := provider.BluegreenPromote("httpd", "web")
+	require.EqualError(t, err, "service web green target group has no healthy targets")
+
+	stack, ok := provider.Fake.CloudFormation.Stack("convox-httpd")
+	require.True(t, ok)
+	assert.Equal(t, "blue", stack.Parameters["WebActive"])
+}
+
+func TestBluegreenPromoteEarlierRelease(t *testing.T) {
+	provider := bluegreenProvider(true, map[string]string{"WebActive": "green", "WebTasksBlue": bluegreenBlueTasks, "WebTasksGreen": ""})
+	defer provider.Close()
+
+	err := provider.BluegreenPromote("httpd", "web")
+	require.EqualError(t, err, "service web blue deployment runs an earlier release, promote a release first")
+}
+
+func TestBluegreenRollback(t *testing.T) {
+	// after a promotion green serves the release while blue is still held on the earlier one
+	provider := bluegreenProvider(true, map[string]string{"WebActive": "green", "WebTasksBlue": bluegreenBlueTasks, "WebTasksGreen": ""})
+	defer provider.Close()
+
+	require.NoError(t, provider.BluegreenRollback("httpd", "web"))
+
+	stack, ok := provider.Fake.CloudFormation.Stack("convox-httpd")
+	require.True(t, ok)
+	assert.Equal(t, "blue", stack.Parameters["WebActive"])
+}
+
+func TestBluegreenRollbackNoEarlierRelease(t *testing.T) {
+	provider := bluegreenProvider(true, map[string]string{"WebActive": "blue", "WebTasksBlue": bluegreenBlueTasks, "WebTasksGreen": ""})
+	defer provider.Close()
+
+	provider.Fake.ELBv2.SetTargetHealth(bluegreenGreen, "healthy")
+
+	err := provider.BluegreenRollback("httpd", "web")
+	require.EqualError(t, err, "service web has no earlier release to roll back to")
+}
+
+func TestBluegreenNotOptedIn(t *testing.T) {
+	provider := bluegreenProvider(false, map[string]string{})
+	defer provider.Close()
+
+	_, err := provider.BluegreenStatus("httpd", "web")
+	require.EqualError(t, err, "service does not use blue-green deployment: web")
+
+	err = provider.BluegreenPromote("httpd", "web")
+	require.EqualError(t, err, "service does not use blue-green deployment: web")
+
+	_, err = provider.BluegreenStatus("httpd", "other")
+	require.EqualError(t, err, "service not found: other")
+}
+
+func TestBluegreenReleaseChanges(t *testing.T) {
+	provider := bluegreenProvider(true, map[string]string{"WebActive": "green", "WebTasksBlue": bluegreenBlueTasks, "WebTasksGreen": ""})
+	defer provider.Close()
+
+	require.NoError(t, provider.Fake.ECS.AddService("cluster-test", &ecs.Service{
+		ServiceArn:     awssdk.String("arn:aws:ecs:us-test-1:123456789012:service/cluster-test/httpd-web-green"),
+		ServiceName:    awssdk.String("httpd-web-green"),
+		TaskDefinition: awssdk.String(bluegreenNewTasks),
+	}))
+
+	m, err := manifest.Load([]byte("services:\n  web:\n    port: 3000\n    deployment:\n      strategy: blue-green\n  api:\n    port: 3000\n"), map[string]string{})
+	require.NoError(t, err)
+
+	// green serves traffic so it is held on what it runs and blue takes the release
+	changes, err := provider.BluegreenReleaseChanges("httpd", m)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"WebActive": "green", "WebTasksBlue": "", "WebTasksGreen": bluegreenNewTasks}, changes)
+}
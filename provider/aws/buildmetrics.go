@@ -0,0 +1,51 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// BuildMetrics is the time a build spent in each phase and what it produced
+type BuildMetrics struct {
+	PullDuration  time.Duration `json:"pull-duration"`
+	BuildDuration time.Duration `json:"build-duration"`
+	PushDuration  time.Duration `json:"push-duration"`
+	LayersCached  int           `json:"layers-cached"`
+	LayersTotal   int           `json:"layers-total"`
+	ImageSizeMB   float64       `json:"image-size-mb"`
+}
+
+// GetBuildMetrics returns the metrics recorded by the build process
+// builds from before metrics were recorded have none and return nil without an error
+func (p *Provider) GetBuildMetrics(app, buildId string) (*BuildMetrics, error) {
+	data, err := p.s3Get(p.SettingsBucket, buildMetricsKey(app, buildId))
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m BuildMetrics
+
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid metrics for build %s: %s", buildId, err)
+	}
+
+	return &m, nil
+}
+
+// RecordBuildMetrics stores the metrics for a build, replacing any recorded before
+func (p *Provider) RecordBuildMetrics(app, buildId string, m BuildMetrics) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return p.s3Put(p.SettingsBucket, buildMetricsKey(app, buildId), data, false)
+}
+
+func buildMetricsKey(app, buildId string) string {
+	return fmt.Sprintf("%s/builds/%s/metrics.json", app, buildId)
+}
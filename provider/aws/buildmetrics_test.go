@@ -0,0 +1,60 @@
+package aws_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildMetrics(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	m := aws.BuildMetrics{
+		PullDuration:  12 * time.Second,
+		BuildDuration: 95 * time.Second,
+		PushDuration:  1500 * time.Millisecond,
+		LayersCached:  7,
+		LayersTotal:   10,
+		ImageSizeMB:   245.5,
+	}
+
+	require.NoError(t, provider.RecordBuildMetrics("httpd", "BABCDEFGHI", m))
+
+	data, ok := provider.Fake.S3.Object("convox-settings", "httpd/builds/BABCDEFGHI/metrics.json")
+	require.True(t, ok)
+
+	var stored map[string]interface{}
+
+	require.NoError(t, json.Unmarshal(data, &stored))
+	assert.Equal(t, float64(12*time.Second), stored["pull-duration"])
+	assert.Equal(t, 245.5, stored["image-size-mb"])
+
+	got, err := provider.GetBuildMetrics("httpd", "BABCDEFGHI")
+	require.NoError(t, err)
+	assert.Equal(t, &m, got)
+}
+
+func TestBuildMetricsMissing(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	m, err := provider.GetBuildMetrics("httpd", "BABCDEFGHI")
+	require.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestBuildMetricsInvalid(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.Fake.S3.PutObject("convox-settings", "httpd/builds/BABCDEFGHI/metrics.json", []byte("{"), nil)
+
+	_, err := provider.GetBuildMetrics("httpd", "BABCDEFGHI")
+	require.EqualError(t, err, "invalid metrics for build BABCDEFGHI: unexpected end of JSON input")
+}
@@ -2,8 +2,10 @@ package aws
 
 import (
 	"archive/tar"
+	"bufio"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -20,9 +22,11 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/ecr"
 	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/s3"
 	docker "github.com/fsouza/go-dockerclient"
 
 	"github.com/convox/rack/pkg/manifest"
@@ -31,6 +35,15 @@ import (
 	"github.com/convox/rack/pkg/structs"
 )
 
+const (
+	// buildOutputSentinel is the line a build writes when its output is complete
+	buildOutputSentinel = "=== build complete ==="
+)
+
+var (
+	buildOutputPollInterval = 2 * time.Second
+)
+
 // ECR host is formatted like 123456789012.dkr.ecr.us-east-1.amazonaws.com
 var regexpECRHost = regexp.MustCompile(`(\d+)\.dkr\.ecr\.([^.]+)\.amazonaws\.com`)
 var regexpECRImage = regexp.MustCompile(`(\d+)\.dkr\.ecr\.([^.]+)\.amazonaws\.com\/([^:]+):([^ ]+)`)
@@ -421,10 +434,12 @@ func (p *Provider) BuildImport(app string, r io.Reader) (*structs.Build, error)
 		return nil, log.Error(err)
 	}
 
+	from := targetBuild.Status
+
 	targetBuild.Status = "complete"
 	targetBuild.Release = rr.Id
 
-	if err := p.buildSave(targetBuild); err != nil {
+	if err := p.buildTransition(targetBuild, from); err != nil {
 		log.Error(err)
 		return nil, err
 	}
@@ -506,6 +521,166 @@ func (p *Provider) BuildLogs(app, id string, opts structs.LogsOptions) (io.ReadC
 	return nil, fmt.Errorf("unreachable")
 }
 
+// StreamBuildOutput writes the output of a build to w a line at a time as it is produced
+// a finished build is read from its stored log object, a running one is followed in the app log group until the build complete sentinel
+// service narrows a running build to the log streams of that service, the stored log always holds the whole build
+func (p *Provider) StreamBuildOutput(ctx context.Context, app, service, buildId string, w io.Writer) error {
+	b, err := p.BuildGet(app, buildId)
+	if err != nil {
+		return err
+	}
+
+	switch b.Status {
+	case "created", "running":
+		return p.followBuildOutput(ctx, app, service, b, w)
+	}
+
+	u, err := url.Parse(b.Logs)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "object" {
+		return writeBuildOutput(strings.NewReader(b.Logs), w)
+	}
+
+	bucket, err := p.appBucket(app)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.s3().GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(strings.TrimPrefix(u.Path, "/")),
+	})
+	if isNotFound(err) {
+		return errorNotFound(fmt.Sprintf("build logs not found: %s", buildId))
+	}
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return writeBuildOutput(res.Body, w)
+}
+
+// followBuildOutput polls the app log group for new build events until the sentinel arrives
+// a build that stops without writing the sentinel ends the stream once its last events have been read
+func (p *Provider) followBuildOutput(ctx context.Context, app, service string, b *structs.Build, w io.Writer) error {
+	group, err := p.appResource(app, "LogGroup")
+	if err != nil {
+		return err
+	}
+
+	req := &cloudwatchlogs.FilterLogEventsInput{
+		Interleaved:         aws.Bool(true),
+		LogGroupName:        aws.String(group),
+		LogStreamNamePrefix: aws.String(buildLogStreamPrefix(b.Id, service)),
+	}
+
+	if !b.Started.IsZero() {
+		req.StartTime = aws.Int64(b.Started.UnixNano() / int64(time.Millisecond))
+	}
+
+	// polls start again from the newest timestamp seen so events at that millisecond come back and are skipped by id
+	seen := map[string]bool{}
+
+	for {
+		found := false
+		done := false
+
+		err := p.cloudwatchlogs().FilterLogEventsPagesWithContext(ctx, req, func(res *cloudwatchlogs.FilterLogEventsOutput, last bool) bool {
+			for _, e := range res.Events {
+				id := cs(e.EventId, "")
+
+				if seen[id] {
+					continue
+				}
+
+				seen[id] = true
+				found = true
+
+				if ts := ci(e.Timestamp, 0); req.StartTime == nil || ts > *req.StartTime {
+					req.StartTime = aws.Int64(ts)
+				}
+
+				line := strings.TrimSuffix(cs(e.Message, ""), "\n")
+
+				if line == buildOutputSentinel {
+					done = true
+					return false
+				}
+
+				if _, err := fmt.Fprintf(w, "%s\n", line); err != nil {
+					done = true
+					return false
+				}
+			}
+
+			return true
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+
+		req.NextToken = nil
+
+		if done {
+			return nil
+		}
+
+		if !found {
+			bb, err := p.BuildGet(app, b.Id)
+			if err != nil {
+				return err
+			}
+
+			switch bb.Status {
+			case "created", "running":
+			default:
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(buildOutputPollInterval):
+		}
+	}
+}
+
+// buildLogStreamPrefix is the log stream prefix of a build in the app log group
+func buildLogStreamPrefix(id, service string) string {
+	if service == "" {
+		return fmt.Sprintf("build/%s/", id)
+	}
+
+	return fmt.Sprintf("build/%s/%s/", id, service)
+}
+
+// writeBuildOutput copies stored build output to w up to the sentinel
+func writeBuildOutput(r io.Reader, w io.Writer) error {
+	s := bufio.NewScanner(r)
+
+	s.Buffer(make([]byte, 4096), 20*1024*1024)
+
+	for s.Scan() {
+		if s.Text() == buildOutputSentinel {
+			return nil
+		}
+
+		if _, err := fmt.Fprintf(w, "%s\n", s.Text()); err != nil {
+			return err
+		}
+	}
+
+	return s.Err()
+}
+
 // BuildList returns a list of the latest builds, with the length specified in limit
 func (p *Provider) BuildList(app string, opts structs.BuildListOptions) (structs.Builds, error) {
 	a, err := p.AppGet(app)
@@ -551,6 +726,8 @@ func (p *Provider) BuildUpdate(app, id string, opts structs.BuildUpdateOptions)
 		return nil, err
 	}
 
+	from := b.Status
+
 	if opts.Ended != nil {
 		b.Ended = *opts.Ended
 	}
@@ -575,25 +752,62 @@ func (p *Provider) BuildUpdate(app, id string, opts structs.BuildUpdateOptions)
 		b.Started = *opts.Started
 	}
 
-	if opts.Status != nil {
+	if opts.Status != nil && *opts.Status != from {
 		b.Status = *opts.Status
+
+		if err := p.buildTransition(b, from); err != nil {
+			return nil, err
+		}
+
+		return b, nil
 	}
 
-	if err := p.buildSave(b); err != nil {
+	if err := p.buildUpdate(b); err != nil {
 		return nil, err
 	}
 
 	return b, nil
 }
 
+// buildUpdate saves a build without writing its status, which only changes through buildTransition
+// so an update racing a transition does not put back the status it read
+func (p *Provider) buildUpdate(b *structs.Build) error {
+	item, err := p.buildItem(b)
+	if err != nil {
+		return err
+	}
+
+	key := map[string]*dynamodb.AttributeValue{"id": item["id"]}
+
+	delete(item, "id")
+	delete(item, "status")
+
+	return p.dynamoUpdateConditional(p.DynamoBuilds, key, item, "", nil)
+}
+
 func (p *Provider) buildSave(b *structs.Build) error {
-	_, err := p.AppGet(b.App)
+	item, err := p.buildItem(b)
 	if err != nil {
 		return err
 	}
 
+	_, err = p.dynamodb().PutItem(&dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(p.DynamoBuilds),
+	})
+
+	return err
+}
+
+// buildItem validates a build and returns its dynamodb attributes
+func (p *Provider) buildItem(b *structs.Build) (map[string]*dynamodb.AttributeValue, error) {
+	_, err := p.AppGet(b.App)
+	if err != nil {
+		return nil, err
+	}
+
 	if b.Id == "" {
-		return fmt.Errorf("Id can not be blank")
+		return nil, fmt.Errorf("Id can not be blank")
 	}
 
 	if b.Started.IsZero() {
@@ -605,56 +819,51 @@ func (p *Provider) buildSave(b *structs.Build) error {
 		b.Ended = time.Unix(1473028892, 0).UTC()
 	}
 
-	req := &dynamodb.PutItemInput{
-		Item: map[string]*dynamodb.AttributeValue{
-			"id":      {S: aws.String(b.Id)},
-			"app":     {S: aws.String(b.App)},
-			"status":  {S: aws.String(b.Status)},
-			"created": {S: aws.String(b.Started.Format(sortableTime))},
-		},
-		TableName: aws.String(p.DynamoBuilds),
+	item := map[string]*dynamodb.AttributeValue{
+		"id":      {S: aws.String(b.Id)},
+		"app":     {S: aws.String(b.App)},
+		"status":  {S: aws.String(b.Status)},
+		"created": {S: aws.String(b.Started.Format(sortableTime))},
 	}
 
 	if b.Description != "" {
-		req.Item["description"] = &dynamodb.AttributeValue{S: aws.String(b.Description)}
+		item["description"] = &dynamodb.AttributeValue{S: aws.String(b.Description)}
 	}
 
 	if b.Entrypoint != "" {
-		req.Item["entrypoint"] = &dynamodb.AttributeValue{S: aws.String(b.Entrypoint)}
+		item["entrypoint"] = &dynamodb.AttributeValue{S: aws.String(b.Entrypoint)}
 	}
 
 	if b.Manifest != "" {
-		req.Item["manifest"] = &dynamodb.AttributeValue{S: aws.String(b.Manifest)}
+		item["manifest"] = &dynamodb.AttributeValue{S: aws.String(b.Manifest)}
 	}
 
 	if b.Logs != "" {
-		req.Item["logs"] = &dynamodb.AttributeValue{S: aws.String(b.Logs)}
+		item["logs"] = &dynamodb.AttributeValue{S: aws.String(b.Logs)}
 	}
 
 	if b.Reason != "" {
-		req.Item["reason"] = &dynamodb.AttributeValue{S: aws.String(b.Reason)}
+		item["reason"] = &dynamodb.AttributeValue{S: aws.String(b.Reason)}
 	}
 
 	if b.Release != "" {
-		req.Item["release"] = &dynamodb.AttributeValue{S: aws.String(b.Release)}
+		item["release"] = &dynamodb.AttributeValue{S: aws.String(b.Release)}
 	}
 
 	if !b.Ended.IsZero() {
-		req.Item["ended"] = &dynamodb.AttributeValue{S: aws.String(b.Ended.Format(sortableTime))}
+		item["ended"] = &dynamodb.AttributeValue{S: aws.String(b.Ended.Format(sortableTime))}
 	}
 
 	if len(b.Tags) > 0 {
 		tags, err := json.Marshal(b.Tags)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		req.Item["tags"] = &dynamodb.AttributeValue{B: tags}
+		item["tags"] = &dynamodb.AttributeValue{B: tags}
 	}
 
-	_, err = p.dynamodb().PutItem(req)
-
-	return err
+	return item, nil
 }
 
 func (p *Provider) buildAuth(build *structs.Build) (string, error) {
@@ -905,6 +1114,14 @@ func (p *Provider) runBuild(build *structs.Build, burl string, opts structs.Buil
 		},
 	}
 
+	if p.RequireNonRoot {
+		co := req.Overrides.ContainerOverrides[0]
+		co.Environment = append(co.Environment, &ecs.KeyValuePair{
+			Name:  aws.String("BUILD_REQUIRE_NON_ROOT"),
+			Value: aws.String("true"),
+		})
+	}
+
 	task, err := p.runTask(req)
 	if err != nil {
 		log.Error(err)
@@ -916,11 +1133,13 @@ func (p *Provider) runBuild(build *structs.Build, burl string, opts structs.Buil
 		return err
 	}
 
+	from := b.Status
+
 	b.Status = "running"
 
 	b.Tags["task"] = *task.TaskArn
 
-	if err := p.buildSave(b); err != nil {
+	if err := p.buildTransition(b, from); err != nil {
 		return err
 	}
 
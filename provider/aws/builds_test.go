@@ -2,8 +2,10 @@ package aws_test
 
 import (
 	"archive/tar"
+	"bufio"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"io"
 	"io/ioutil"
@@ -13,6 +15,7 @@ import (
 
 	"github.com/convox/rack/pkg/structs"
 	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -376,6 +379,55 @@ func TestBuildLogsNotRunning(t *testing.T) {
 	assert.Equal(t, "RUNNING: docker pull httpd", buf.String())
 }
 
+func TestStreamBuildOutputComplete(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleBuildGetItem,
+		cycleObjectListStackResources,
+		cycleBuildFetchOutput,
+	)
+	defer provider.Close()
+
+	r, w := io.Pipe()
+
+	go func() {
+		w.CloseWithError(provider.StreamBuildOutput(context.Background(), "httpd", "", "B123", w))
+	}()
+
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "Step 1/2 : FROM httpd\nStep 2/2 : COPY . /app\n", string(data))
+}
+
+func TestStreamBuildOutputRunning(t *testing.T) {
+	aws.SetBuildOutputPollInterval(1 * time.Millisecond)
+	defer aws.SetBuildOutputPollInterval(2 * time.Second)
+
+	provider := StubAwsProvider(
+		cycleBuildGetItemRunning,
+		cycleBuildOutputListStackResources,
+		cycleBuildOutputFilterLogEvents1,
+		cycleBuildOutputFilterLogEvents2,
+	)
+	defer provider.Close()
+
+	r, w := io.Pipe()
+
+	go func() {
+		w.CloseWithError(provider.StreamBuildOutput(context.Background(), "httpd", "web", "B123", w))
+	}()
+
+	s := bufio.NewScanner(r)
+
+	lines := []string{}
+
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+
+	require.NoError(t, s.Err())
+	assert.Equal(t, []string{"Step 1/2 : FROM httpd", "Step 2/2 : COPY . /app"}, lines)
+}
+
 var cycleBuildBatchDeleteImage = awsutil.Cycle{
 	awsutil.Request{
 		RequestURI: "/",
@@ -1456,3 +1508,104 @@ var cycleEnvironmentPut = awsutil.Cycle{
 		StatusCode: 200,
 	},
 }
+
+var cycleBuildFetchOutput = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "GET",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/test/foo",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       "Step 1/2 : FROM httpd\nStep 2/2 : COPY . /app\n=== build complete ===\nignored\n",
+	},
+}
+
+var cycleBuildOutputListStackResources = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "",
+		Body:       `Action=ListStackResources&StackName=convox-httpd&Version=2010-05-15`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `
+			<ListStackResourcesResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+				<ListStackResourcesResult>
+					<StackResourceSummaries>
+						<member>
+							<PhysicalResourceId>convox-httpd-LogGroup-L4V203L35WRM</PhysicalResourceId>
+							<ResourceStatus>UPDATE_COMPLETE</ResourceStatus>
+							<LogicalResourceId>LogGroup</LogicalResourceId>
+							<Timestamp>2016-10-22T02:53:23.817Z</Timestamp>
+							<ResourceType>AWS::Logs::LogGroup</ResourceType>
+						</member>
+					</StackResourceSummaries>
+				</ListStackResourcesResult>
+			</ListStackResourcesResponse>
+		`,
+	},
+}
+
+var cycleBuildOutputFilterLogEvents1 = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "Logs_20140328.FilterLogEvents",
+		Body: `{
+			"interleaved": true,
+			"logGroupName": "convox-httpd-LogGroup-L4V203L35WRM",
+			"logStreamNamePrefix": "build/BAFVEWUCAYT/web/",
+			"startTime": 1459780456178
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"events": [
+				{
+					"eventId": "event1",
+					"logStreamName": "build/BAFVEWUCAYT/web/1",
+					"message": "Step 1/2 : FROM httpd\n",
+					"timestamp": 1459780457000
+				}
+			]
+		}`,
+	},
+}
+
+var cycleBuildOutputFilterLogEvents2 = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "Logs_20140328.FilterLogEvents",
+		Body: `{
+			"interleaved": true,
+			"logGroupName": "convox-httpd-LogGroup-L4V203L35WRM",
+			"logStreamNamePrefix": "build/BAFVEWUCAYT/web/",
+			"startTime": 1459780457000
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"events": [
+				{
+					"eventId": "event1",
+					"logStreamName": "build/BAFVEWUCAYT/web/1",
+					"message": "Step 1/2 : FROM httpd\n",
+					"timestamp": 1459780457000
+				},
+				{
+					"eventId": "event2",
+					"logStreamName": "build/BAFVEWUCAYT/web/1",
+					"message": "Step 2/2 : COPY . /app",
+					"timestamp": 1459780458000
+				},
+				{
+					"eventId": "event3",
+					"logStreamName": "build/BAFVEWUCAYT/web/1",
+					"message": "=== build complete ===",
+					"timestamp": 1459780459000
+				}
+			]
+		}`,
+	},
+}
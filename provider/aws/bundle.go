@@ -0,0 +1,391 @@
+package aws
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/convox/rack/pkg/options"
+	"github.com/convox/rack/pkg/structs"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	appBundleVersion = 1
+
+	// appBundleChecksum is the pax record holding the sha256 of each bundle entry
+	appBundleChecksum = "CONVOX.sha256"
+
+	appBundleReleaseLimit = 1000
+)
+
+// entries of an app bundle, bundle.json is always written first so the version can be checked before anything else is read
+const (
+	appBundleEntryBundle   = "bundle.json"
+	appBundleEntryEnv      = "env"
+	appBundleEntryManifest = "manifest.yml"
+	appBundleEntryReleases = "releases.json"
+)
+
+// appBundle describes the contents of an app export
+type appBundle struct {
+	Version    int       `json:"version"`
+	App        string    `json:"app"`
+	Generation string    `json:"generation"`
+	Release    string    `json:"release"`
+	Encrypted  bool      `json:"encrypted"`
+	Exported   time.Time `json:"exported"`
+}
+
+// appBundleRelease is the release metadata carried in a bundle, environments are not included
+type appBundleRelease struct {
+	Id          string    `json:"id"`
+	Build       string    `json:"build"`
+	Description string    `json:"description"`
+	Created     time.Time `json:"created"`
+}
+
+// appBundleEnvelope is an environment encrypted with a user supplied key
+type appBundleEnvelope struct {
+	Ciphertext []byte `json:"c"`
+	Nonce      []byte `json:"n"`
+	Salt       []byte `json:"s"`
+}
+
+type appExportOptions struct {
+	Key string
+}
+
+type appImportOptions struct {
+	Key string
+}
+
+// appExport writes a bundle of an app's current manifest, environment and release history as a tar.gz
+// the environment is encrypted when opts.Key is set
+func (p *Provider) appExport(app string, w io.Writer, opts appExportOptions) error {
+	log := Logger.At("appExport").Namespace("app=%q", app).Start()
+
+	a, err := p.AppGet(app)
+	if err != nil {
+		return log.Error(err)
+	}
+
+	if a.Release == "" {
+		return log.Error(fmt.Errorf("no release for app: %s", app))
+	}
+
+	r, err := p.ReleaseGet(app, a.Release)
+	if err != nil {
+		return log.Error(err)
+	}
+
+	rs, err := p.ReleaseList(app, structs.ReleaseListOptions{Limit: options.Int(appBundleReleaseLimit)})
+	if err != nil {
+		return log.Error(err)
+	}
+
+	env := []byte(r.Env)
+
+	if opts.Key != "" {
+		env, err = appBundleEncrypt(opts.Key, env)
+		if err != nil {
+			return log.Error(err)
+		}
+	}
+
+	b := appBundle{
+		Version:    appBundleVersion,
+		App:        a.Name,
+		Generation: a.Generation,
+		Release:    r.Id,
+		Encrypted:  opts.Key != "",
+		Exported:   time.Now().UTC(),
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if err := writeBundleEntry(tw, appBundleEntryBundle, func(w io.Writer) error { return json.NewEncoder(w).Encode(b) }); err != nil {
+		return log.Error(err)
+	}
+
+	if err := writeBundleEntry(tw, appBundleEntryManifest, func(w io.Writer) error { _, err := io.WriteString(w, r.Manifest); return err }); err != nil {
+		return log.Error(err)
+	}
+
+	if err := writeBundleEntry(tw, appBundleEntryEnv, func(w io.Writer) error { _, err := w.Write(env); return err }); err != nil {
+		return log.Error(err)
+	}
+
+	// release history can be large so it is encoded one release at a time
+	err = writeBundleEntry(tw, appBundleEntryReleases, func(w io.Writer) error {
+		e := json.NewEncoder(w)
+
+		for _, r := range rs {
+			if err := e.Encode(appBundleRelease{Id: r.Id, Build: r.Build, Description: r.Description, Created: r.Created}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return log.Error(err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return log.Error(err)
+	}
+
+	if err := gz.Close(); err != nil {
+		return log.Error(err)
+	}
+
+	return log.Success()
+}
+
+// appImport creates a new app from a bundle written by appExport
+// the imported environment and manifest become the first release of the new app
+// and the release history of the exported app is stored alongside it
+func (p *Provider) appImport(r io.Reader, name string, opts appImportOptions) (*structs.Release, error) {
+	log := Logger.At("appImport").Namespace("name=%q", name).Start()
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, log.Error(err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+
+	var b *appBundle
+	var env, manifest []byte
+
+	lineage := []appBundleRelease{}
+
+	// every entry is verified before anything is created
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, log.Error(err)
+		}
+
+		if b == nil && h.Name != appBundleEntryBundle {
+			return nil, log.Error(fmt.Errorf("invalid bundle: %s must be the first entry", appBundleEntryBundle))
+		}
+
+		sum, ok := h.PAXRecords[appBundleChecksum]
+		if !ok {
+			return nil, log.Error(fmt.Errorf("invalid bundle: no checksum for entry: %s", h.Name))
+		}
+
+		hash := sha256.New()
+		er := io.TeeReader(tr, hash)
+
+		switch h.Name {
+		case appBundleEntryBundle:
+			b = &appBundle{}
+			err = json.NewDecoder(er).Decode(b)
+		case appBundleEntryEnv:
+			env, err = ioutil.ReadAll(er)
+		case appBundleEntryManifest:
+			manifest, err = ioutil.ReadAll(er)
+		case appBundleEntryReleases:
+			d := json.NewDecoder(er)
+			for d.More() {
+				var r appBundleRelease
+				if err = d.Decode(&r); err != nil {
+					break
+				}
+				lineage = append(lineage, r)
+			}
+		}
+		if err != nil {
+			return nil, log.Error(fmt.Errorf("invalid bundle entry %s: %s", h.Name, err))
+		}
+
+		if _, err := io.Copy(ioutil.Discard, er); err != nil {
+			return nil, log.Error(err)
+		}
+
+		if hex.EncodeToString(hash.Sum(nil)) != sum {
+			return nil, log.Error(fmt.Errorf("checksum mismatch for bundle entry: %s", h.Name))
+		}
+
+		if h.Name == appBundleEntryBundle && b.Version != appBundleVersion {
+			return nil, log.Error(fmt.Errorf("unsupported bundle version: %d", b.Version))
+		}
+	}
+
+	if b == nil {
+		return nil, log.Error(fmt.Errorf("invalid bundle: no %s", appBundleEntryBundle))
+	}
+
+	if b.Encrypted {
+		if opts.Key == "" {
+			return nil, log.Error(fmt.Errorf("bundle environment is encrypted, a key is required"))
+		}
+
+		env, err = appBundleDecrypt(opts.Key, env)
+		if err != nil {
+			return nil, log.Error(err)
+		}
+	}
+
+	if _, err := p.AppCreate(name, structs.AppCreateOptions{Generation: options.String(b.Generation)}); err != nil {
+		return nil, log.Error(err)
+	}
+
+	// the settings bucket the release is written to only exists once the app stack is created
+	if err := p.waitForStackCreated(context.Background(), p.rackStack(name)); err != nil {
+		return nil, log.Error(err)
+	}
+
+	rr := structs.NewRelease(name)
+
+	rr.Description = fmt.Sprintf("import %s %s", b.App, b.Release)
+	rr.Env = string(env)
+	rr.Manifest = string(manifest)
+
+	if err := p.releaseSave(rr); err != nil {
+		return nil, log.Error(err)
+	}
+
+	data, err := json.Marshal(lineage)
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	settings, err := p.appResource(name, "Settings")
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	if err := p.s3Put(settings, fmt.Sprintf("releases/%s/lineage.json", rr.Id), data, false); err != nil {
+		return nil, log.Error(err)
+	}
+
+	return rr, log.Success()
+}
+
+// writeBundleEntry spools an entry to a temporary file to learn its size and checksum
+// so large entries are never held in memory
+func writeBundleEntry(tw *tar.Writer, name string, fn func(w io.Writer) error) error {
+	f, err := ioutil.TempFile("", "bundle")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	hash := sha256.New()
+
+	if err := fn(io.MultiWriter(f, hash)); err != nil {
+		return err
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	h := &tar.Header{
+		Typeflag:   tar.TypeReg,
+		Name:       name,
+		Mode:       0600,
+		Size:       size,
+		ModTime:    time.Now().UTC(),
+		Format:     tar.FormatPAX,
+		PAXRecords: map[string]string{appBundleChecksum: hex.EncodeToString(hash.Sum(nil))},
+	}
+
+	if err := tw.WriteHeader(h); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(tw, f); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func appBundleKey(key string, salt []byte) (*[32]byte, error) {
+	k, err := scrypt.Key([]byte(key), salt, 32768, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	var kk [32]byte
+	copy(kk[:], k)
+
+	return &kk, nil
+}
+
+func appBundleEncrypt(key string, data []byte) ([]byte, error) {
+	e := appBundleEnvelope{Nonce: make([]byte, 24), Salt: make([]byte, 16)}
+
+	if _, err := rand.Read(e.Nonce); err != nil {
+		return nil, err
+	}
+
+	if _, err := rand.Read(e.Salt); err != nil {
+		return nil, err
+	}
+
+	k, err := appBundleKey(key, e.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [24]byte
+	copy(nonce[:], e.Nonce)
+
+	e.Ciphertext = secretbox.Seal(nil, data, &nonce, k)
+
+	return json.Marshal(e)
+}
+
+func appBundleDecrypt(key string, data []byte) ([]byte, error) {
+	var e appBundleEnvelope
+
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+
+	if len(e.Nonce) != 24 {
+		return nil, fmt.Errorf("invalid bundle environment")
+	}
+
+	k, err := appBundleKey(key, e.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [24]byte
+	copy(nonce[:], e.Nonce)
+
+	dec, ok := secretbox.Open(nil, e.Ciphertext, &nonce, k)
+	if !ok {
+		return nil, fmt.Errorf("could not decrypt bundle environment, check the key")
+	}
+
+	return dec, nil
+}
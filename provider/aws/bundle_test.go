@@ -0,0 +1,256 @@
+package aws_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/convox/rack/pkg/structs"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const bundleManifest = "services:\n  web:\n    build: .\n    port: 3000\n"
+
+func TestAppExportImport(t *testing.T) {
+	provider := bundleTestProvider(t)
+	defer provider.Close()
+
+	// formation templates are loaded relative to the repository root
+	cwd, _ := os.Getwd()
+	require.NoError(t, os.Chdir("../.."))
+	defer os.Chdir(cwd)
+
+	var buf bytes.Buffer
+
+	require.NoError(t, provider.AppExport("httpd", &buf, aws.AppExportOptions{Key: "secret"}))
+
+	entries := bundleEntries(t, buf.Bytes())
+	assert.Equal(t, []string{"bundle.json", "manifest.yml", "env", "releases.json"}, bundleEntryNames(entries))
+	assert.NotContains(t, string(entries[2].data), "FOO=bar")
+
+	_, err := provider.AppImport(bytes.NewReader(buf.Bytes()), "imported", aws.AppImportOptions{})
+	require.EqualError(t, err, "bundle environment is encrypted, a key is required")
+
+	_, err = provider.AppImport(bytes.NewReader(buf.Bytes()), "imported", aws.AppImportOptions{Key: "wrong"})
+	require.EqualError(t, err, "could not decrypt bundle environment, check the key")
+
+	provider.Fake.S3.CreateBucket("convox-imported-Settings")
+
+	r, err := provider.AppImport(bytes.NewReader(buf.Bytes()), "imported", aws.AppImportOptions{Key: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "import httpd R2", r.Description)
+
+	s, ok := provider.Fake.CloudFormation.Stack("convox-imported")
+	require.True(t, ok)
+	assert.Equal(t, "imported", s.Tags["Name"])
+	assert.Equal(t, "2", s.Tags["Generation"])
+
+	ir, err := provider.ReleaseGet("imported", r.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "FOO=bar", ir.Env)
+	assert.Equal(t, bundleManifest, ir.Manifest)
+
+	data, ok := provider.Fake.S3.Object("convox-imported-Settings", "releases/"+r.Id+"/lineage.json")
+	require.True(t, ok)
+
+	var lineage []map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &lineage))
+	require.Len(t, lineage, 2)
+	assert.Equal(t, "R2", lineage[0]["id"])
+	assert.Equal(t, "BAPI", lineage[0]["build"])
+	assert.Equal(t, "R1", lineage[1]["id"])
+}
+
+func TestAppImportWaitsForStack(t *testing.T) {
+	provider := bundleTestProvider(t)
+	defer provider.Close()
+
+	cwd, _ := os.Getwd()
+	require.NoError(t, os.Chdir("../.."))
+	defer os.Chdir(cwd)
+
+	aws.SetStackCreatePollInterval(1 * time.Millisecond)
+	defer aws.SetStackCreatePollInterval(5 * time.Second)
+
+	var buf bytes.Buffer
+
+	require.NoError(t, provider.AppExport("httpd", &buf, aws.AppExportOptions{}))
+
+	provider.Fake.CloudFormation.Delay = 5 * time.Minute
+	provider.Fake.S3.CreateBucket("convox-imported-Settings")
+
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := provider.AppImport(bytes.NewReader(buf.Bytes()), "imported", aws.AppImportOptions{})
+		done <- err
+	}()
+
+	for {
+		if s, ok := provider.Fake.CloudFormation.Stack("convox-imported"); ok {
+			assert.Equal(t, "CREATE_IN_PROGRESS", s.Status)
+			break
+		}
+
+		time.Sleep(1 * time.Millisecond)
+	}
+
+	// nothing is written to the app until its stack is created
+	time.Sleep(20 * time.Millisecond)
+
+	rs, err := provider.ReleaseList("imported", structs.ReleaseListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, rs)
+
+	provider.Fake.Clock.Advance(5 * time.Minute)
+
+	require.NoError(t, <-done)
+
+	rs, err = provider.ReleaseList("imported", structs.ReleaseListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, rs, 1)
+}
+
+func TestAppImportChecksumMismatch(t *testing.T) {
+	provider := bundleTestProvider(t)
+	defer provider.Close()
+
+	var buf bytes.Buffer
+
+	require.NoError(t, provider.AppExport("httpd", &buf, aws.AppExportOptions{}))
+
+	entries := bundleEntries(t, buf.Bytes())
+	entries[2].data = []byte("FOO=tampered")
+
+	_, err := provider.AppImport(bytes.NewReader(bundleWrite(t, entries)), "imported", aws.AppImportOptions{})
+	require.EqualError(t, err, "checksum mismatch for bundle entry: env")
+
+	_, ok := provider.Fake.CloudFormation.Stack("convox-imported")
+	assert.False(t, ok)
+}
+
+func TestAppImportUnsupportedVersion(t *testing.T) {
+	provider := bundleTestProvider(t)
+	defer provider.Close()
+
+	var buf bytes.Buffer
+
+	require.NoError(t, provider.AppExport("httpd", &buf, aws.AppExportOptions{}))
+
+	entries := bundleEntries(t, buf.Bytes())
+	entries[0].data = bytes.Replace(entries[0].data, []byte(`"version":1`), []byte(`"version":2`), 1)
+	entries[0].header.PAXRecords["CONVOX.sha256"] = bundleChecksum(entries[0].data)
+
+	_, err := provider.AppImport(bytes.NewReader(bundleWrite(t, entries)), "imported", aws.AppImportOptions{})
+	require.EqualError(t, err, "unsupported bundle version: 2")
+}
+
+func bundleTestProvider(t *testing.T) *awsfake.TestProvider {
+	provider := awsfake.NewTestProvider()
+	provider.Version = "20200101000000"
+
+	provider.AddRack(awsfake.Stack{
+		Resources: []awsfake.Resource{{LogicalId: "EncryptionKey", PhysicalId: ""}},
+	})
+
+	provider.AddApp("httpd", "2", awsfake.Stack{
+		Outputs:   map[string]string{"Release": "R2"},
+		Resources: []awsfake.Resource{{LogicalId: "Settings", PhysicalId: "convox-httpd-settings"}},
+	})
+
+	provider.Fake.S3.PutObject("convox-httpd-settings", "releases/R2/env", []byte("FOO=bar"), nil)
+
+	d := dynamodb.New(session.New(), provider.Fake.Config())
+
+	for _, r := range []map[string]string{
+		{"id": "R1", "app": "httpd", "created": "20200101.120000.000000000", "description": "env add:FOO"},
+		{"id": "R2", "app": "httpd", "created": "20200102.120000.000000000", "build": "BAPI", "manifest": bundleManifest},
+	} {
+		item := map[string]*dynamodb.AttributeValue{}
+
+		for k, v := range r {
+			item[k] = &dynamodb.AttributeValue{S: awssdk.String(v)}
+		}
+
+		_, err := d.PutItem(&dynamodb.PutItemInput{Item: item, TableName: awssdk.String("convox-releases")})
+		require.NoError(t, err)
+	}
+
+	return provider
+}
+
+type bundleEntry struct {
+	header *tar.Header
+	data   []byte
+}
+
+func bundleEntries(t *testing.T, data []byte) []bundleEntry {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	tr := tar.NewReader(gz)
+
+	entries := []bundleEntry{}
+
+	for {
+		h, err := tr.Next()
+		if err != nil {
+			break
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+
+		entries = append(entries, bundleEntry{header: h, data: data})
+	}
+
+	return entries
+}
+
+func bundleEntryNames(entries []bundleEntry) []string {
+	names := []string{}
+
+	for _, e := range entries {
+		names = append(names, e.header.Name)
+	}
+
+	return names
+}
+
+func bundleWrite(t *testing.T, entries []bundleEntry) []byte {
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, e := range entries {
+		e.header.Size = int64(len(e.data))
+		require.NoError(t, tw.WriteHeader(e.header))
+
+		_, err := tw.Write(e.data)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	return buf.Bytes()
+}
+
+func bundleChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
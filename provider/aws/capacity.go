@@ -3,9 +3,12 @@ package aws
 import (
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/convox/rack/pkg/manifest"
 	"github.com/convox/rack/pkg/structs"
 )
 
@@ -156,7 +159,8 @@ func (p *Provider) clusterServices() (ECSServices, error) {
 			return nil, err
 		}
 
-		dres, err := p.describeServices(&ecs.DescribeServicesInput{
+		// services deleted since they were listed are left out
+		ss, _, err := p.describeServicesChecked(&ecs.DescribeServicesInput{
 			Cluster:  aws.String(p.Cluster),
 			Services: lres.ServiceArns,
 		})
@@ -164,9 +168,7 @@ func (p *Provider) clusterServices() (ECSServices, error) {
 			return nil, err
 		}
 
-		for _, s := range dres.Services {
-			services = append(services, s)
-		}
+		services = append(services, ss...)
 
 		if lres.NextToken == nil {
 			break
@@ -177,3 +179,196 @@ func (p *Provider) clusterServices() (ECSServices, error) {
 
 	return services, nil
 }
+
+// instanceCapacity is the cpu units and memory in MB a single instance can offer to tasks
+type instanceCapacity struct {
+	Cpu    int64
+	Memory int64
+}
+
+// instanceTypeCapacity are the specs of common instance types
+// used when the cluster has no instances to measure
+var instanceTypeCapacity = map[string]instanceCapacity{
+	"c5.large":   {Cpu: 2048, Memory: 4096},
+	"c5.xlarge":  {Cpu: 4096, Memory: 8192},
+	"c5.2xlarge": {Cpu: 8192, Memory: 16384},
+	"c5.4xlarge": {Cpu: 16384, Memory: 32768},
+	"m5.large":   {Cpu: 2048, Memory: 8192},
+	"m5.xlarge":  {Cpu: 4096, Memory: 16384},
+	"m5.2xlarge": {Cpu: 8192, Memory: 32768},
+	"m5.4xlarge": {Cpu: 16384, Memory: 65536},
+	"r5.large":   {Cpu: 2048, Memory: 16384},
+	"r5.xlarge":  {Cpu: 4096, Memory: 32768},
+	"r5.2xlarge": {Cpu: 8192, Memory: 65536},
+	"t3.micro":   {Cpu: 2048, Memory: 1024},
+	"t3.small":   {Cpu: 2048, Memory: 2048},
+	"t3.medium":  {Cpu: 2048, Memory: 4096},
+	"t3.large":   {Cpu: 2048, Memory: 8192},
+	"t3.xlarge":  {Cpu: 4096, Memory: 16384},
+	"t3.2xlarge": {Cpu: 8192, Memory: 32768},
+}
+
+// fargateMemory are the memory sizes fargate allows for each task cpu size
+var fargateMemory = map[int][]int{
+	256:  {512, 1024, 2048},
+	512:  fargateMemoryRange(1024, 4096),
+	1024: fargateMemoryRange(2048, 8192),
+	2048: fargateMemoryRange(4096, 16384),
+	4096: fargateMemoryRange(8192, 30720),
+}
+
+func fargateMemoryRange(min, max int) []int {
+	mems := []int{}
+
+	for m := min; m <= max; m += 1024 {
+		mems = append(mems, m)
+	}
+
+	return mems
+}
+
+// clusterCapacity returns the largest cpu and memory registered by any active instance in the cluster
+// an empty cluster falls back to the specs of the rack instance type, nil means the capacity is unknown
+func (p *Provider) clusterCapacity() (*instanceCapacity, error) {
+	c := &instanceCapacity{}
+
+	req := &ecs.ListContainerInstancesInput{
+		Cluster: aws.String(p.Cluster),
+		Status:  aws.String("ACTIVE"),
+	}
+
+	for {
+		res, err := p.listContainerInstances(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(res.ContainerInstanceArns) > 0 {
+			dres, err := p.describeContainerInstances(&ecs.DescribeContainerInstancesInput{
+				Cluster:            aws.String(p.Cluster),
+				ContainerInstances: res.ContainerInstanceArns,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, instance := range dres.ContainerInstances {
+				for _, r := range instance.RegisteredResources {
+					switch cs(r.Name, "") {
+					case "CPU":
+						if v := ci(r.IntegerValue, 0); v > c.Cpu {
+							c.Cpu = v
+						}
+					case "MEMORY":
+						if v := ci(r.IntegerValue, 0); v > c.Memory {
+							c.Memory = v
+						}
+					}
+				}
+			}
+		}
+
+		if res.NextToken == nil {
+			break
+		}
+
+		req.NextToken = res.NextToken
+	}
+
+	if c.Cpu > 0 || c.Memory > 0 {
+		return c, nil
+	}
+
+	it, err := p.stackParameter(p.Rack, "InstanceType")
+	if err != nil {
+		return nil, err
+	}
+
+	if tc, ok := instanceTypeCapacity[it]; ok {
+		return &tc, nil
+	}
+
+	return nil, nil
+}
+
+// capacityPreflight checks that every service of a manifest can be placed before a release is promoted
+// ec2 services must fit on the largest instance and fargate services must use a supported cpu and memory combination
+func (p *Provider) capacityPreflight(a *structs.App, m *manifest.Manifest) error {
+	var capacity *instanceCapacity
+
+	for _, s := range m.Services {
+		cpu, memory, fargate := serviceFormation(a, m, s)
+
+		if fargate {
+			if !fargateCombination(cpu, memory) {
+				return fmt.Errorf("service %s requests cpu=%d memory=%d which is not a supported fargate combination", s.Name, cpu, memory)
+			}
+			continue
+		}
+
+		if capacity == nil {
+			c, err := p.clusterCapacity()
+			if err != nil {
+				return err
+			}
+
+			// nothing to compare against for instance types we do not know
+			if c == nil {
+				return nil
+			}
+
+			capacity = c
+		}
+
+		if int64(cpu) > capacity.Cpu {
+			return fmt.Errorf("service %s requests %d cpu but instances in this rack offer at most %d", s.Name, cpu, capacity.Cpu)
+		}
+
+		if int64(memory) > capacity.Memory {
+			return fmt.Errorf("service %s requests %dMB memory but instances in this rack offer at most %dMB", s.Name, memory, capacity.Memory)
+		}
+	}
+
+	return nil
+}
+
+// serviceFormation returns the cpu and memory a service will deploy with and whether it runs on fargate
+// values set on the app stack by scaling take precedence over the manifest as promotion keeps them
+func serviceFormation(a *structs.App, m *manifest.Manifest, s manifest.Service) (int, int, bool) {
+	cpu, memory, fargate := s.Scale.Cpu, s.Scale.Memory, false
+
+	switch coalesces(m.Params["FargateServices"], a.Parameters["FargateServices"]) {
+	case "Yes", "Spot":
+		fargate = true
+	}
+
+	if f, ok := a.Parameters[fmt.Sprintf("%sFormation", upperName(s.Name))]; ok {
+		parts := strings.Split(f, ",")
+
+		if len(parts) > 2 {
+			if v, err := strconv.Atoi(parts[1]); err == nil {
+				cpu = v
+			}
+
+			if v, err := strconv.Atoi(parts[2]); err == nil {
+				memory = v
+			}
+		}
+
+		if len(parts) > 3 && strings.HasPrefix(parts[3], "FARGATE") {
+			fargate = true
+		}
+	}
+
+	return cpu, memory, fargate
+}
+
+func fargateCombination(cpu, memory int) bool {
+	for _, m := range fargateMemory[cpu] {
+		if m == memory {
+			return true
+		}
+	}
+
+	return false
+}
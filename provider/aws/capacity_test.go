@@ -3,9 +3,14 @@ package aws_test
 import (
 	"testing"
 
-	"github.com/convox/rack/pkg/test/awsutil"
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/convox/rack/pkg/manifest"
 	"github.com/convox/rack/pkg/structs"
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws/awsfake"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCapacityGet(t *testing.T) {
@@ -35,6 +40,107 @@ func TestCapacityGet(t *testing.T) {
 	}, r)
 }
 
+func TestCapacityPreflightInstances(t *testing.T) {
+	provider := capacityTestProvider(t, "t3.small")
+	defer provider.Close()
+
+	require.NoError(t, provider.Fake.ECS.AddContainerInstance("cluster-test", capacityTestInstance("ACTIVE", 2048, 7680)))
+	require.NoError(t, provider.Fake.ECS.AddContainerInstance("cluster-test", capacityTestInstance("DRAINING", 8192, 31744)))
+
+	a := &structs.App{Name: "httpd", Parameters: map[string]string{}}
+
+	err := provider.CapacityPreflight(a, capacityTestManifest(256, 4096))
+	require.NoError(t, err)
+
+	err = provider.CapacityPreflight(a, capacityTestManifest(256, 8192))
+	require.EqualError(t, err, "service web requests 8192MB memory but instances in this rack offer at most 7680MB")
+
+	err = provider.CapacityPreflight(a, capacityTestManifest(4096, 512))
+	require.EqualError(t, err, "service web requests 4096 cpu but instances in this rack offer at most 2048")
+
+	// scaled values on the app take precedence over the manifest
+	a.Parameters["WebFormation"] = "2,256,512"
+
+	err = provider.CapacityPreflight(a, capacityTestManifest(4096, 512))
+	require.NoError(t, err)
+}
+
+func TestCapacityPreflightEmptyCluster(t *testing.T) {
+	provider := capacityTestProvider(t, "t3.small")
+	defer provider.Close()
+
+	a := &structs.App{Name: "httpd", Parameters: map[string]string{}}
+
+	err := provider.CapacityPreflight(a, capacityTestManifest(256, 2048))
+	require.NoError(t, err)
+
+	err = provider.CapacityPreflight(a, capacityTestManifest(256, 4096))
+	require.EqualError(t, err, "service web requests 4096MB memory but instances in this rack offer at most 2048MB")
+}
+
+func TestCapacityPreflightUnknownInstanceType(t *testing.T) {
+	provider := capacityTestProvider(t, "x9.huge")
+	defer provider.Close()
+
+	a := &structs.App{Name: "httpd", Parameters: map[string]string{}}
+
+	err := provider.CapacityPreflight(a, capacityTestManifest(256, 1048576))
+	assert.NoError(t, err)
+}
+
+func TestCapacityPreflightFargate(t *testing.T) {
+	provider := capacityTestProvider(t, "t3.small")
+	defer provider.Close()
+
+	a := &structs.App{Name: "httpd", Parameters: map[string]string{"FargateServices": "Yes"}}
+
+	// fargate services are not limited by the instances in the cluster
+	err := provider.CapacityPreflight(a, capacityTestManifest(1024, 8192))
+	require.NoError(t, err)
+
+	err = provider.CapacityPreflight(a, capacityTestManifest(256, 4096))
+	require.EqualError(t, err, "service web requests cpu=256 memory=4096 which is not a supported fargate combination")
+
+	a.Parameters["FargateServices"] = "No"
+	a.Parameters["WebFormation"] = "1,512,3072,FARGATE_SPOT"
+
+	err = provider.CapacityPreflight(a, capacityTestManifest(256, 512))
+	require.NoError(t, err)
+
+	a.Parameters["WebFormation"] = "1,512,3000,FARGATE"
+
+	err = provider.CapacityPreflight(a, capacityTestManifest(256, 512))
+	require.EqualError(t, err, "service web requests cpu=512 memory=3000 which is not a supported fargate combination")
+}
+
+func capacityTestProvider(t *testing.T, instanceType string) *awsfake.TestProvider {
+	provider := awsfake.NewTestProvider()
+
+	provider.AddRack(awsfake.Stack{
+		Parameters: map[string]string{"InstanceType": instanceType},
+	})
+
+	return provider
+}
+
+func capacityTestInstance(status string, cpu, memory int64) *ecs.ContainerInstance {
+	return &ecs.ContainerInstance{
+		Status: awssdk.String(status),
+		RegisteredResources: []*ecs.Resource{
+			{Name: awssdk.String("CPU"), Type: awssdk.String("INTEGER"), IntegerValue: awssdk.Int64(cpu)},
+			{Name: awssdk.String("MEMORY"), Type: awssdk.String("INTEGER"), IntegerValue: awssdk.Int64(memory)},
+		},
+	}
+}
+
+func capacityTestManifest(cpu, memory int) *manifest.Manifest {
+	return &manifest.Manifest{
+		Services: manifest.Services{
+			{Name: "web", Scale: manifest.ServiceScale{Cpu: cpu, Memory: memory}},
+		},
+	}
+}
+
 var cycleCapacityDescribeContainerInstances = awsutil.Cycle{
 	awsutil.Request{
 		RequestURI: "/",
@@ -0,0 +1,150 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+var (
+	capacityProviderPollInterval = 5 * time.Second
+)
+
+// ManagedScalingOptions configure how ecs scales the auto scaling group behind a capacity provider
+type ManagedScalingOptions struct {
+	Enabled                      bool
+	ManagedTerminationProtection bool
+	MaximumScalingStepSize       int64
+	MinimumScalingStepSize       int64
+	TargetCapacity               int64
+}
+
+// RegisterCapacityProvider creates a capacity provider for an auto scaling group of the rack and returns its arn
+// the capacity provider is named after the rack and the auto scaling group
+func (p *Provider) RegisterCapacityProvider(asgArn string, managedScaling ManagedScalingOptions) (string, error) {
+	i := strings.Index(asgArn, ":autoScalingGroupName/")
+	if !strings.HasPrefix(asgArn, "arn:") || i < 0 {
+		return "", fmt.Errorf("invalid auto scaling group arn: %s", asgArn)
+	}
+
+	name := fmt.Sprintf("%s-%s", p.Rack, asgArn[i+len(":autoScalingGroupName/"):])
+
+	if managedScaling.Enabled {
+		if t := managedScaling.TargetCapacity; t < 1 || t > 100 {
+			return "", fmt.Errorf("target capacity %d out of range, must be between 1 and 100", t)
+		}
+
+		if managedScaling.MinimumScalingStepSize > managedScaling.MaximumScalingStepSize {
+			return "", fmt.Errorf("minimum scaling step size %d must not be more than maximum %d", managedScaling.MinimumScalingStepSize, managedScaling.MaximumScalingStepSize)
+		}
+	}
+
+	ms := &ecsManagedScaling{Status: aws.String("DISABLED")}
+
+	if managedScaling.Enabled {
+		ms = &ecsManagedScaling{
+			MaximumScalingStepSize: aws.Int64(managedScaling.MaximumScalingStepSize),
+			MinimumScalingStepSize: aws.Int64(managedScaling.MinimumScalingStepSize),
+			Status:                 aws.String("ENABLED"),
+			TargetCapacity:         aws.Int64(managedScaling.TargetCapacity),
+		}
+	}
+
+	protection := "DISABLED"
+
+	if managedScaling.ManagedTerminationProtection {
+		protection = "ENABLED"
+	}
+
+	res := &ecsCreateCapacityProviderOutput{}
+
+	op := &request.Operation{Name: "CreateCapacityProvider", HTTPMethod: "POST", HTTPPath: "/"}
+
+	req := p.ecs().NewRequest(op, &ecsCreateCapacityProviderInput{
+		AutoScalingGroupProvider: &ecsAutoScalingGroupProvider{
+			AutoScalingGroupArn:          aws.String(asgArn),
+			ManagedScaling:               ms,
+			ManagedTerminationProtection: aws.String(protection),
+		},
+		Name: aws.String(name),
+		Tags: []*ecs.Tag{
+			{Key: aws.String("Rack"), Value: aws.String(p.Rack)},
+			{Key: aws.String("System"), Value: aws.String("convox")},
+		},
+	}, res)
+
+	if err := req.Send(); err != nil {
+		return "", err
+	}
+
+	if res.CapacityProvider == nil || res.CapacityProvider.CapacityProviderArn == nil {
+		return "", fmt.Errorf("could not create capacity provider: %s", name)
+	}
+
+	return *res.CapacityProvider.CapacityProviderArn, nil
+}
+
+// CapacityProviderStatus returns the status of a capacity provider
+func (p *Provider) CapacityProviderStatus(name string) (string, error) {
+	cp, err := p.describeCapacityProvider(name)
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(cp.Status), nil
+}
+
+// WaitForCapacityProvider waits for a capacity provider to be active with no update in progress
+// a capacity provider that has never been updated has no update status and counts as ready once it is active
+func (p *Provider) WaitForCapacityProvider(name string, timeout time.Duration) error {
+	done := time.Now().Add(timeout)
+
+	for {
+		cp, err := p.describeCapacityProvider(name)
+		if err != nil {
+			return err
+		}
+
+		status := aws.StringValue(cp.Status)
+		update := aws.StringValue(cp.UpdateStatus)
+
+		switch {
+		case status == "INACTIVE":
+			return fmt.Errorf("capacity provider %s is inactive", name)
+		case strings.HasSuffix(update, "_FAILED"):
+			return fmt.Errorf("capacity provider %s %s: %s", name, strings.ToLower(update), aws.StringValue(cp.UpdateStatusReason))
+		case status == "ACTIVE" && (update == "" || update == "UPDATE_COMPLETE"):
+			return nil
+		}
+
+		if time.Now().After(done) {
+			return fmt.Errorf("timeout waiting for capacity provider %s to be ready", name)
+		}
+
+		time.Sleep(capacityProviderPollInterval)
+	}
+}
+
+func (p *Provider) describeCapacityProvider(name string) (*ecsCapacityProvider, error) {
+	res := &ecsDescribeCapacityProvidersOutput{}
+
+	op := &request.Operation{Name: "DescribeCapacityProviders", HTTPMethod: "POST", HTTPPath: "/"}
+
+	req := p.ecs().NewRequest(op, &ecsDescribeCapacityProvidersInput{
+		CapacityProviders: []*string{aws.String(name)},
+	}, res)
+
+	if err := req.Send(); err != nil {
+		return nil, err
+	}
+
+	if len(res.CapacityProviders) != 1 {
+		return nil, errorNotFound(fmt.Sprintf("capacity provider not found: %s", name))
+	}
+
+	return res.CapacityProviders[0], nil
+}
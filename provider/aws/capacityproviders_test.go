@@ -0,0 +1,192 @@
+package aws_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const capacityProviderAsgArn = "arn:aws:autoscaling:us-test-1:123456789012:autoScalingGroup:6d8e2f1a-1234-5678-9abc-def012345678:autoScalingGroupName/convox-Instances-ABC123"
+
+func TestRegisterCapacityProvider(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleCapacityProviderCreate,
+	)
+	defer provider.Close()
+
+	arn, err := provider.RegisterCapacityProvider(capacityProviderAsgArn, aws.ManagedScalingOptions{
+		Enabled:                true,
+		MaximumScalingStepSize: 10,
+		MinimumScalingStepSize: 1,
+		TargetCapacity:         90,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws:ecs:us-test-1:123456789012:capacity-provider/convox-convox-Instances-ABC123", arn)
+}
+
+func TestRegisterCapacityProviderInvalid(t *testing.T) {
+	provider := StubAwsProvider()
+	defer provider.Close()
+
+	_, err := provider.RegisterCapacityProvider("convox-Instances-ABC123", aws.ManagedScalingOptions{})
+	require.EqualError(t, err, "invalid auto scaling group arn: convox-Instances-ABC123")
+
+	_, err = provider.RegisterCapacityProvider(capacityProviderAsgArn, aws.ManagedScalingOptions{Enabled: true, TargetCapacity: 101})
+	require.EqualError(t, err, "target capacity 101 out of range, must be between 1 and 100")
+
+	_, err = provider.RegisterCapacityProvider(capacityProviderAsgArn, aws.ManagedScalingOptions{Enabled: true, TargetCapacity: 100, MinimumScalingStepSize: 5, MaximumScalingStepSize: 2})
+	require.EqualError(t, err, "minimum scaling step size 5 must not be more than maximum 2")
+}
+
+func TestCapacityProviderStatus(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleCapacityProviderDescribe("ACTIVE", "UPDATE_IN_PROGRESS"),
+		cycleCapacityProviderDescribeMissing,
+	)
+	defer provider.Close()
+
+	status, err := provider.CapacityProviderStatus("convox-convox-Instances-ABC123")
+	require.NoError(t, err)
+	assert.Equal(t, "ACTIVE", status)
+
+	_, err = provider.CapacityProviderStatus("convox-convox-Instances-ABC123")
+	require.EqualError(t, err, "capacity provider not found: convox-convox-Instances-ABC123")
+	assert.Equal(t, 404, err.(interface{ Code() int }).Code())
+}
+
+func TestWaitForCapacityProvider(t *testing.T) {
+	aws.SetCapacityProviderPollInterval(0)
+
+	provider := StubAwsProvider(
+		cycleCapacityProviderDescribe("ACTIVE", "UPDATE_IN_PROGRESS"),
+		cycleCapacityProviderDescribe("ACTIVE", "UPDATE_IN_PROGRESS"),
+		cycleCapacityProviderDescribe("ACTIVE", "UPDATE_COMPLETE"),
+	)
+	defer provider.Close()
+
+	err := provider.WaitForCapacityProvider("convox-convox-Instances-ABC123", time.Minute)
+	require.NoError(t, err)
+}
+
+func TestWaitForCapacityProviderNeverUpdated(t *testing.T) {
+	aws.SetCapacityProviderPollInterval(0)
+
+	provider := StubAwsProvider(
+		cycleCapacityProviderDescribe("ACTIVE", ""),
+	)
+	defer provider.Close()
+
+	err := provider.WaitForCapacityProvider("convox-convox-Instances-ABC123", time.Minute)
+	require.NoError(t, err)
+}
+
+func TestWaitForCapacityProviderFailed(t *testing.T) {
+	aws.SetCapacityProviderPollInterval(0)
+
+	provider := StubAwsProvider(
+		cycleCapacityProviderDescribe("ACTIVE", "UPDATE_IN_PROGRESS"),
+		cycleCapacityProviderDescribe("ACTIVE", "UPDATE_FAILED"),
+	)
+	defer provider.Close()
+
+	err := provider.WaitForCapacityProvider("convox-convox-Instances-ABC123", time.Minute)
+	require.EqualError(t, err, "capacity provider convox-convox-Instances-ABC123 update_failed: auto scaling group is in use")
+}
+
+func TestWaitForCapacityProviderTimeout(t *testing.T) {
+	aws.SetCapacityProviderPollInterval(0)
+
+	provider := StubAwsProvider(
+		cycleCapacityProviderDescribe("ACTIVE", "UPDATE_IN_PROGRESS"),
+	)
+	defer provider.Close()
+
+	err := provider.WaitForCapacityProvider("convox-convox-Instances-ABC123", 0)
+	require.EqualError(t, err, "timeout waiting for capacity provider convox-convox-Instances-ABC123 to be ready")
+}
+
+var cycleCapacityProviderCreate = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "AmazonEC2ContainerServiceV20141113.CreateCapacityProvider",
+		Body: `{
+			"autoScalingGroupProvider": {
+				"autoScalingGroupArn": "` + capacityProviderAsgArn + `",
+				"managedScaling": {
+					"maximumScalingStepSize": 10,
+					"minimumScalingStepSize": 1,
+					"status": "ENABLED",
+					"targetCapacity": 90
+				},
+				"managedTerminationProtection": "DISABLED"
+			},
+			"name": "convox-convox-Instances-ABC123",
+			"tags": [
+				{ "key": "Rack", "value": "convox" },
+				{ "key": "System", "value": "convox" }
+			]
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"capacityProvider": {
+				"capacityProviderArn": "arn:aws:ecs:us-test-1:123456789012:capacity-provider/convox-convox-Instances-ABC123",
+				"name": "convox-convox-Instances-ABC123",
+				"status": "ACTIVE"
+			}
+		}`,
+	},
+}
+
+var cycleCapacityProviderDescribeMissing = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "AmazonEC2ContainerServiceV20141113.DescribeCapacityProviders",
+		Body:       `{"capacityProviders": ["convox-convox-Instances-ABC123"]}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"capacityProviders": [],
+			"failures": [
+				{ "arn": "convox-convox-Instances-ABC123", "reason": "MISSING" }
+			]
+		}`,
+	},
+}
+
+func cycleCapacityProviderDescribe(status, update string) awsutil.Cycle {
+	reason := ""
+
+	if update == "UPDATE_FAILED" {
+		reason = "auto scaling group is in use"
+	}
+
+	return awsutil.Cycle{
+		Request: awsutil.Request{
+			RequestURI: "/",
+			Operation:  "AmazonEC2ContainerServiceV20141113.DescribeCapacityProviders",
+			Body:       `{"capacityProviders": ["convox-convox-Instances-ABC123"]}`,
+		},
+		Response: awsutil.Response{
+			StatusCode: 200,
+			Body: `{
+				"capacityProviders": [
+					{
+						"capacityProviderArn": "arn:aws:ecs:us-test-1:123456789012:capacity-provider/convox-convox-Instances-ABC123",
+						"name": "convox-convox-Instances-ABC123",
+						"status": "` + status + `",
+						"updateStatus": "` + update + `",
+						"updateStatusReason": "` + reason + `"
+					}
+				],
+				"failures": []
+			}`,
+		},
+	}
+}
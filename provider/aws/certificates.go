@@ -4,12 +4,15 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/route53"
 	"github.com/convox/rack/pkg/structs"
 )
 
@@ -78,28 +81,17 @@ func (p *Provider) CertificateCreate(pub, key string, opts structs.CertificateCr
 }
 
 func (p *Provider) CertificateDelete(id string) error {
-	if strings.HasPrefix(id, "acm") {
-		ss := strings.Split(id, "-")
-		if len(ss) < 2 {
-			return fmt.Errorf("invalid certificate id")
-		}
-		id = ss[1]
-
-		certs, err := p.certificateListACM()
+	if strings.HasPrefix(id, "acm-") {
+		arn, err := p.certificateArnFromId(id)
 		if err != nil {
 			return err
 		}
 
-		for _, c := range certs {
-			if strings.HasSuffix(*c.CertificateArn, id) {
-				_, err = p.acm().DeleteCertificate(&acm.DeleteCertificateInput{
-					CertificateArn: c.CertificateArn,
-				})
-				return err
-			}
-		}
+		_, err = p.acm().DeleteCertificate(&acm.DeleteCertificateInput{
+			CertificateArn: aws.String(arn),
+		})
 
-		return fmt.Errorf("certificate not found")
+		return err
 	}
 
 	_, err := p.iam().DeleteServerCertificate(&iam.DeleteServerCertificateInput{
@@ -134,8 +126,10 @@ func (p *Provider) CertificateGenerate(domains []string) (*structs.Certificate,
 		return nil, err
 	}
 
-	parts := strings.Split(*res.CertificateArn, "-")
-	id := fmt.Sprintf("acm-%s", parts[len(parts)-1])
+	id, err := certificateFriendlyId(*res.CertificateArn)
+	if err != nil {
+		return nil, err
+	}
 
 	cert := structs.Certificate{
 		Id:     id,
@@ -205,7 +199,7 @@ func (p *Provider) CertificateList() (structs.Certificates, error) {
 		tres, err := p.acm().ListTagsForCertificate(&acm.ListTagsForCertificateInput{
 			CertificateArn: cert.CertificateArn,
 		})
-		if awsError(err) == "ResourceNotFoundException" {
+		if isNotFound(err) {
 			continue
 		}
 		if err != nil {
@@ -247,8 +241,10 @@ func (e CfsslError) Error() string {
 }
 
 func (p *Provider) certificateGetACM(arn string) (*structs.Certificate, error) {
-	parts := strings.Split(arn, "-")
-	id := fmt.Sprintf("acm-%s", parts[len(parts)-1])
+	id, err := certificateFriendlyId(arn)
+	if err != nil {
+		return nil, err
+	}
 
 	c := &structs.Certificate{
 		Arn: arn,
@@ -256,7 +252,6 @@ func (p *Provider) certificateGetACM(arn string) (*structs.Certificate, error) {
 	}
 
 	var res *acm.DescribeCertificateOutput
-	var err error
 
 	err = retry(5, 2*time.Second, func() error {
 		res, err = p.acm().DescribeCertificate(&acm.DescribeCertificateInput{
@@ -286,6 +281,55 @@ func (p *Provider) certificateGetACM(arn string) (*structs.Certificate, error) {
 	return c, nil
 }
 
+// certificateArnFromId resolves a friendly certificate id (acm-xxxx or an iam server certificate name) to its arn
+func (p *Provider) certificateArnFromId(id string) (string, error) {
+	if strings.HasPrefix(id, "acm-") {
+		certs, err := p.certificateListACM()
+		if err != nil {
+			return "", err
+		}
+
+		arns := []string{}
+
+		for _, c := range certs {
+			if fid, err := certificateFriendlyId(cs(c.CertificateArn, "")); err == nil && fid == id {
+				arns = append(arns, *c.CertificateArn)
+			}
+		}
+
+		switch len(arns) {
+		case 0:
+			return "", errorNotFound(fmt.Sprintf("certificate not found: %s", id))
+		case 1:
+			return arns[0], nil
+		default:
+			return "", fmt.Errorf("ambiguous certificate id %s matches: %s", id, strings.Join(arns, ", "))
+		}
+	}
+
+	arn := ""
+
+	err := p.iam().ListServerCertificatesPages(&iam.ListServerCertificatesInput{}, func(res *iam.ListServerCertificatesOutput, last bool) bool {
+		for _, c := range res.ServerCertificateMetadataList {
+			if cs(c.ServerCertificateName, "") == id {
+				arn = cs(c.Arn, "")
+				return false
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if arn == "" {
+		return "", errorNotFound(fmt.Sprintf("certificate not found: %s", id))
+	}
+
+	return arn, nil
+}
+
 func (p *Provider) certificateListACM() ([]*acm.CertificateSummary, error) {
 	certs := []*acm.CertificateSummary{}
 
@@ -308,3 +352,308 @@ func (p *Provider) certificateListACM() ([]*acm.CertificateSummary, error) {
 
 	return certs, nil
 }
+
+var (
+	acmCertificatePollInterval = 5 * time.Second
+	acmCertificateTimeout      = 5 * time.Minute
+)
+
+// RequestACMCertificate requests an ACM certificate validated by DNS, creates the validation records
+// in the Route 53 hosted zones of its domains and waits for it to be issued
+func (p *Provider) RequestACMCertificate(domainName string, alternativeNames []string) (string, error) {
+	log := Logger.At("RequestACMCertificate").Namespace("domain=%q", domainName).Start()
+
+	req := &acm.RequestCertificateInput{
+		DomainName:       aws.String(domainName),
+		ValidationMethod: aws.String(acm.ValidationMethodDns),
+	}
+
+	if len(alternativeNames) > 0 {
+		req.SubjectAlternativeNames = aws.StringSlice(alternativeNames)
+	}
+
+	res, err := p.acm().RequestCertificate(req)
+	if err != nil {
+		return "", log.Error(err)
+	}
+
+	arn := aws.StringValue(res.CertificateArn)
+	done := time.Now().Add(acmCertificateTimeout)
+
+	records, err := p.acmValidationRecords(arn, done)
+	if err != nil {
+		return "", log.Error(err)
+	}
+
+	if err := p.createValidationRecords(records); err != nil {
+		return "", log.Error(err)
+	}
+
+	if err := p.waitForCertificateIssued(arn, done); err != nil {
+		return "", log.Error(err)
+	}
+
+	return arn, log.Successf("arn=%s", arn)
+}
+
+// acmValidationRecords waits for acm to generate the validation record of every domain on a certificate
+// the records are keyed by the domain they validate and domains sharing a record are returned once
+func (p *Provider) acmValidationRecords(arn string, done time.Time) (map[string]*acm.ResourceRecord, error) {
+	for {
+		res, err := p.acm().DescribeCertificate(&acm.DescribeCertificateInput{
+			CertificateArn: aws.String(arn),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		records := map[string]*acm.ResourceRecord{}
+		seen := map[string]bool{}
+		ready := len(res.Certificate.DomainValidationOptions) > 0
+
+		for _, o := range res.Certificate.DomainValidationOptions {
+			if o.ResourceRecord == nil {
+				ready = false
+				break
+			}
+
+			if name := aws.StringValue(o.ResourceRecord.Name); !seen[name] {
+				seen[name] = true
+				records[coalesces(aws.StringValue(o.ValidationDomain), aws.StringValue(o.DomainName))] = o.ResourceRecord
+			}
+		}
+
+		if ready {
+			return records, nil
+		}
+
+		if time.Now().After(done) {
+			return nil, fmt.Errorf("timeout waiting for validation records of certificate %s", arn)
+		}
+
+		time.Sleep(acmCertificatePollInterval)
+	}
+}
+
+// createValidationRecords upserts validation records into the hosted zone of each domain
+func (p *Provider) createValidationRecords(records map[string]*acm.ResourceRecord) error {
+	changes := map[string][]*route53.Change{}
+
+	domains := []string{}
+
+	for domain := range records {
+		domains = append(domains, domain)
+	}
+
+	sort.Strings(domains)
+
+	for _, domain := range domains {
+		zone, err := p.hostedZoneForDomain(domain)
+		if err != nil {
+			return err
+		}
+
+		r := records[domain]
+
+		changes[zone] = append(changes[zone], &route53.Change{
+			Action: aws.String(route53.ChangeActionUpsert),
+			ResourceRecordSet: &route53.ResourceRecordSet{
+				Name: r.Name,
+				Type: r.Type,
+				TTL:  aws.Int64(300),
+				ResourceRecords: []*route53.ResourceRecord{
+					{Value: r.Value},
+				},
+			},
+		})
+	}
+
+	zones := []string{}
+
+	for zone := range changes {
+		zones = append(zones, zone)
+	}
+
+	sort.Strings(zones)
+
+	for _, zone := range zones {
+		_, err := p.route53().ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+			ChangeBatch: &route53.ChangeBatch{
+				Changes: changes[zone],
+				Comment: aws.String("convox acm certificate validation"),
+			},
+			HostedZoneId: aws.String(zone),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hostedZoneForDomain finds the most specific public hosted zone containing a domain
+func (p *Provider) hostedZoneForDomain(domain string) (string, error) {
+	labels := strings.Split(strings.TrimSuffix(strings.TrimPrefix(domain, "*."), "."), ".")
+
+	for i := 0; i < len(labels)-1; i++ {
+		name := strings.Join(labels[i:], ".") + "."
+
+		res, err := p.route53().ListHostedZonesByName(&route53.ListHostedZonesByNameInput{
+			DNSName:  aws.String(name),
+			MaxItems: aws.String("1"),
+		})
+		if err != nil {
+			return "", err
+		}
+
+		for _, z := range res.HostedZones {
+			if aws.StringValue(z.Name) != name {
+				continue
+			}
+
+			if z.Config != nil && aws.BoolValue(z.Config.PrivateZone) {
+				continue
+			}
+
+			return strings.TrimPrefix(aws.StringValue(z.Id), "/hostedzone/"), nil
+		}
+	}
+
+	return "", fmt.Errorf("no hosted zone found for domain: %s", domain)
+}
+
+func (p *Provider) waitForCertificateIssued(arn string, done time.Time) error {
+	for {
+		res, err := p.acm().DescribeCertificate(&acm.DescribeCertificateInput{
+			CertificateArn: aws.String(arn),
+		})
+		if err != nil {
+			return err
+		}
+
+		switch status := aws.StringValue(res.Certificate.Status); status {
+		case acm.CertificateStatusIssued:
+			return nil
+		case acm.CertificateStatusPendingValidation:
+		default:
+			return fmt.Errorf("could not issue certificate %s: %s", arn, status)
+		}
+
+		if time.Now().After(done) {
+			return fmt.Errorf("timeout waiting for certificate %s to be issued", arn)
+		}
+
+		time.Sleep(acmCertificatePollInterval)
+	}
+}
+
+var (
+	internalCertificateUpdatePollInterval = 5 * time.Second
+	internalCertificateUpdateTimeout      = 30 * time.Minute
+)
+
+// rotateInternalCertificate replaces the self-signed certificate the rack generates for https and tls
+// balancer ports that have no certificate of their own and returns the arn of the new one
+// the previous certificates are only deleted once every listener using them has been swapped
+func (p *Provider) rotateInternalCertificate(host string) (string, error) {
+	previous := map[string]string{}
+
+	err := p.iam().ListServerCertificatesPages(&iam.ListServerCertificatesInput{}, func(res *iam.ListServerCertificatesOutput, last bool) bool {
+		for _, c := range res.ServerCertificateMetadataList {
+			if name := aws.StringValue(c.ServerCertificateName); strings.HasPrefix(name, p.internalCertificatePrefix()) {
+				previous[aws.StringValue(c.Arn)] = name
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+
+	arn, err := p.uploadSelfSignedCertificate(host)
+	if err != nil {
+		return "", err
+	}
+
+	apps, err := p.AppList()
+	if err != nil {
+		return "", err
+	}
+
+	for _, a := range apps {
+		params := map[string]string{}
+
+		for k, v := range a.Parameters {
+			if !strings.HasSuffix(k, "Listener") {
+				continue
+			}
+
+			listener := strings.Split(v, ",")
+
+			if len(listener) == 2 && previous[listener[1]] != "" {
+				params[k] = fmt.Sprintf("%s,%s", listener[0], arn)
+			}
+		}
+
+		if len(params) == 0 {
+			continue
+		}
+
+		stack := p.rackStack(a.Name)
+
+		if err := p.updateStack(stack, nil, params, map[string]string{}, ""); err != nil {
+			return "", err
+		}
+
+		if err := p.waitForStackUpdated(stack); err != nil {
+			return "", err
+		}
+	}
+
+	names := []string{}
+
+	for _, name := range previous {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		_, err := p.iam().DeleteServerCertificate(&iam.DeleteServerCertificateInput{
+			ServerCertificateName: aws.String(name),
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return arn, nil
+}
+
+func (p *Provider) waitForStackUpdated(name string) error {
+	done := time.Now().Add(internalCertificateUpdateTimeout)
+
+	for {
+		p.invalidateStack(name)
+
+		stack, err := p.describeStack(name)
+		if err != nil {
+			return err
+		}
+
+		switch status := cs(stack.StackStatus, ""); status {
+		case cloudformation.StackStatusUpdateComplete:
+			return nil
+		case cloudformation.StackStatusUpdateInProgress, cloudformation.StackStatusUpdateCompleteCleanupInProgress:
+		default:
+			return fmt.Errorf("could not update stack %s: %s", name, status)
+		}
+
+		if time.Now().After(done) {
+			return fmt.Errorf("timeout waiting for stack %s to be updated", name)
+		}
+
+		time.Sleep(internalCertificateUpdatePollInterval)
+	}
+}
@@ -0,0 +1,270 @@
+package aws_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateArnFromId(t *testing.T) {
+	tests := []struct {
+		Id    string
+		Cycle awsutil.Cycle
+		Arn   string
+		Error string
+	}{
+		{"acm-123456789012", cycleCertificateListCertificates, "arn:aws:acm:us-test-1:123456789012:certificate/12345678-1234-1234-1234-123456789012", ""},
+		{"acm-ffffffffffff", cycleCertificateListCertificates, "", "certificate not found: acm-ffffffffffff"},
+		{"acm-abcdefabcdef", cycleCertificateListCertificates, "", "ambiguous certificate id acm-abcdefabcdef matches: arn:aws:acm:us-test-1:123456789012:certificate/11111111-1111-1111-1111-abcdefabcdef, arn:aws:acm:us-test-1:123456789012:certificate/22222222-2222-2222-2222-abcdefabcdef"},
+		{"example", cycleCertificateListServerCertificates, "arn:aws:iam::123456789012:server-certificate/cloudfront/example", ""},
+		{"missing", cycleCertificateListServerCertificates, "", "certificate not found: missing"},
+	}
+
+	for _, test := range tests {
+		provider := StubAwsProvider(test.Cycle)
+
+		arn, err := provider.CertificateArnFromId(test.Id)
+
+		if test.Error != "" {
+			assert.EqualError(t, err, test.Error, test.Id)
+		} else {
+			require.NoError(t, err, test.Id)
+		}
+
+		assert.Equal(t, test.Arn, arn, test.Id)
+
+		provider.Close()
+	}
+}
+
+func TestRequestACMCertificate(t *testing.T) {
+	aws.SetACMCertificatePollInterval(1 * time.Millisecond)
+	defer aws.SetACMCertificatePollInterval(5 * time.Second)
+
+	provider := StubAwsProvider(
+		cycleCertificateRequestDNS,
+		cycleCertificateDescribe("PENDING_VALIDATION", false),
+		cycleCertificateDescribe("PENDING_VALIDATION", true),
+		cycleHostedZonesByName("api.example.org.", "other.org.", "Z2"),
+		cycleHostedZonesByName("example.org.", "example.org.", "Z1"),
+		cycleHostedZonesByName("www.example.org.", "xyz.example.org.", "Z3"),
+		cycleHostedZonesByName("example.org.", "example.org.", "Z1"),
+		cycleCertificateValidationRecords,
+		cycleCertificateDescribe("PENDING_VALIDATION", true),
+		cycleCertificateDescribe("ISSUED", true),
+	)
+	defer provider.Close()
+
+	arn, err := provider.RequestACMCertificate("www.example.org", []string{"api.example.org"})
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws:acm:us-test-1:123456789012:certificate/33333333-3333-3333-3333-abcdefabcdef", arn)
+}
+
+func TestRequestACMCertificateNoHostedZone(t *testing.T) {
+	aws.SetACMCertificatePollInterval(1 * time.Millisecond)
+	defer aws.SetACMCertificatePollInterval(5 * time.Second)
+
+	provider := StubAwsProvider(
+		cycleCertificateRequestDNS,
+		cycleCertificateDescribe("PENDING_VALIDATION", true),
+		cycleHostedZonesByName("api.example.org.", "other.org.", "Z2"),
+		cycleHostedZonesByName("example.org.", "other.org.", "Z2"),
+	)
+	defer provider.Close()
+
+	_, err := provider.RequestACMCertificate("www.example.org", []string{"api.example.org"})
+	require.EqualError(t, err, "no hosted zone found for domain: api.example.org")
+}
+
+func TestRequestACMCertificateFailed(t *testing.T) {
+	aws.SetACMCertificatePollInterval(1 * time.Millisecond)
+	defer aws.SetACMCertificatePollInterval(5 * time.Second)
+
+	provider := StubAwsProvider(
+		cycleCertificateRequestDNS,
+		cycleCertificateDescribe("PENDING_VALIDATION", true),
+		cycleHostedZonesByName("api.example.org.", "api.example.org.", "Z1"),
+		cycleHostedZonesByName("www.example.org.", "www.example.org.", "Z1"),
+		cycleCertificateValidationRecords,
+		cycleCertificateDescribe("FAILED", true),
+	)
+	defer provider.Close()
+
+	_, err := provider.RequestACMCertificate("www.example.org", []string{"api.example.org"})
+	require.EqualError(t, err, "could not issue certificate arn:aws:acm:us-test-1:123456789012:certificate/33333333-3333-3333-3333-abcdefabcdef: FAILED")
+}
+
+var cycleCertificateListCertificates = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "CertificateManager.ListCertificates",
+		Body:       `{}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"CertificateSummaryList": [
+				{ "CertificateArn": "arn:aws:acm:us-test-1:123456789012:certificate/12345678-1234-1234-1234-123456789012", "DomainName": "example.org" },
+				{ "CertificateArn": "arn:aws:acm:us-test-1:123456789012:certificate/11111111-1111-1111-1111-abcdefabcdef", "DomainName": "one.example.org" },
+				{ "CertificateArn": "arn:aws:acm:us-test-1:123456789012:certificate/22222222-2222-2222-2222-abcdefabcdef", "DomainName": "two.example.org" }
+			]
+		}`,
+	},
+}
+
+var cycleCertificateListServerCertificates = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Body:       `Action=ListServerCertificates&Version=2010-05-08`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `<ListServerCertificatesResponse xmlns="https://iam.amazonaws.com/doc/2010-05-08/">
+			<ListServerCertificatesResult>
+				<IsTruncated>false</IsTruncated>
+				<ServerCertificateMetadataList>
+					<member>
+						<ServerCertificateId>ASCACKCEVSQ6C2EXAMPLE</ServerCertificateId>
+						<ServerCertificateName>example</ServerCertificateName>
+						<Path>/cloudfront/</Path>
+						<Arn>arn:aws:iam::123456789012:server-certificate/cloudfront/example</Arn>
+					</member>
+				</ServerCertificateMetadataList>
+			</ListServerCertificatesResult>
+		</ListServerCertificatesResponse>`,
+	},
+}
+
+var cycleCertificateRequestDNS = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "CertificateManager.RequestCertificate",
+		Body:       `{"DomainName":"www.example.org","SubjectAlternativeNames":["api.example.org"],"ValidationMethod":"DNS"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"CertificateArn":"arn:aws:acm:us-test-1:123456789012:certificate/33333333-3333-3333-3333-abcdefabcdef"}`,
+	},
+}
+
+func cycleCertificateDescribe(status string, records bool) awsutil.Cycle {
+	www := `{"DomainName":"www.example.org","ValidationDomain":"www.example.org","ValidationStatus":"PENDING_VALIDATION"}`
+	api := `{"DomainName":"api.example.org","ValidationDomain":"api.example.org","ValidationStatus":"PENDING_VALIDATION"}`
+
+	if records {
+		www = `{"DomainName":"www.example.org","ValidationDomain":"www.example.org","ValidationStatus":"PENDING_VALIDATION","ResourceRecord":{"Name":"_a1.www.example.org.","Type":"CNAME","Value":"_b1.acm-validations.aws."}}`
+		api = `{"DomainName":"api.example.org","ValidationDomain":"api.example.org","ValidationStatus":"PENDING_VALIDATION","ResourceRecord":{"Name":"_a2.api.example.org.","Type":"CNAME","Value":"_b2.acm-validations.aws."}}`
+	}
+
+	return awsutil.Cycle{
+		Request: awsutil.Request{
+			RequestURI: "/",
+			Operation:  "CertificateManager.DescribeCertificate",
+			Body:       `{"CertificateArn":"arn:aws:acm:us-test-1:123456789012:certificate/33333333-3333-3333-3333-abcdefabcdef"}`,
+		},
+		Response: awsutil.Response{
+			StatusCode: 200,
+			Body: fmt.Sprintf(`{
+				"Certificate": {
+					"CertificateArn": "arn:aws:acm:us-test-1:123456789012:certificate/33333333-3333-3333-3333-abcdefabcdef",
+					"DomainName": "www.example.org",
+					"DomainValidationOptions": [%s, %s],
+					"Status": %q
+				}
+			}`, www, api, status),
+		},
+	}
+}
+
+func cycleHostedZonesByName(dnsname, zone, id string) awsutil.Cycle {
+	return awsutil.Cycle{
+		Request: awsutil.Request{
+			Method:     "GET",
+			RequestURI: fmt.Sprintf("/2013-04-01/hostedzonesbyname?dnsname=%s&maxitems=1", dnsname),
+		},
+		Response: awsutil.Response{
+			StatusCode: 200,
+			Body: fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+				<ListHostedZonesByNameResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/">
+					<HostedZones>
+						<HostedZone>
+							<Id>/hostedzone/%s</Id>
+							<Name>%s</Name>
+							<CallerReference>ref</CallerReference>
+							<Config><PrivateZone>false</PrivateZone></Config>
+						</HostedZone>
+					</HostedZones>
+					<DNSName>%s</DNSName>
+					<IsTruncated>false</IsTruncated>
+					<MaxItems>1</MaxItems>
+				</ListHostedZonesByNameResponse>`, id, zone, dnsname),
+		},
+	}
+}
+
+var cycleCertificateValidationRecords = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/2013-04-01/hostedzone/Z1/rrset/",
+		Body:       `/<Changes><Change>.*_a2\.api\.example\.org\..*</Change><Change>.*_a1\.www\.example\.org\..*</Change></Changes>/`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `<?xml version="1.0" encoding="UTF-8"?>
+			<ChangeResourceRecordSetsResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/">
+				<ChangeInfo>
+					<Id>/change/C1</Id>
+					<Status>PENDING</Status>
+					<SubmittedAt>2020-01-01T00:00:00Z</SubmittedAt>
+				</ChangeInfo>
+			</ChangeResourceRecordSetsResponse>`,
+	},
+}
+
+func TestRotateInternalCertificate(t *testing.T) {
+	aws.SetServerCertificateWaitTick(0)
+	aws.SetInternalCertificateUpdatePollInterval(0)
+
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	old, err := provider.Fake.IAM.AddServerCertificate("cert-convox-1500000000-00001", "old")
+	require.NoError(t, err)
+
+	custom, err := provider.Fake.IAM.AddServerCertificate("custom", "custom")
+	require.NoError(t, err)
+
+	template := `{"Parameters":{"WebPort443Listener":{},"WebPort8443Listener":{},"WebFormation":{}},"Resources":{"Settings":{"Type":"AWS::S3::Bucket"}}}`
+
+	provider.AddApp("httpd", "1", awsfake.Stack{
+		Parameters: map[string]string{
+			"WebFormation":        "1,256,512",
+			"WebPort443Listener":  "30000," + old,
+			"WebPort8443Listener": "30001," + custom,
+		},
+		Template: template,
+	})
+
+	arn, err := provider.RotateInternalCertificate("*.*.elb.amazonaws.com")
+	require.NoError(t, err)
+	assert.Regexp(t, `^arn:aws:iam::123456789012:server-certificate/cert-convox-\d+-\d{5}$`, arn)
+	assert.NotEqual(t, old, arn)
+
+	stack, ok := provider.Fake.CloudFormation.Stack("convox-httpd")
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{
+		"WebFormation":        "1,256,512",
+		"WebPort443Listener":  "30000," + arn,
+		"WebPort8443Listener": "30001," + custom,
+	}, stack.Parameters)
+
+	certs := provider.Fake.IAM.ServerCertificates()
+	require.Len(t, certs, 2)
+	assert.Equal(t, "custom", certs[1])
+	assert.NotEqual(t, "cert-convox-1500000000-00001", certs[0])
+}
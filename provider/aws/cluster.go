@@ -0,0 +1,223 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// ClusterSettings are the rack cluster settings that can be changed without a stack update
+// Arn and ExecLogGroup are reported for reference and ignored by UpdateClusterSettings
+type ClusterSettings struct {
+	Arn                             string
+	ContainerInsights               string
+	DefaultCapacityProviderStrategy []CapacityProviderStrategyItem
+	ExecLogGroup                    string
+	LogConfiguration                *LogConfig
+}
+
+// CapacityProviderStrategyItem places a share of the tasks started without a strategy of their own on a capacity provider
+type CapacityProviderStrategyItem struct {
+	Base             int64
+	CapacityProvider string
+	Weight           int64
+}
+
+// LogConfig is where the output of ECS Exec sessions in the cluster is written
+type LogConfig struct {
+	CloudWatchEncryptionEnabled bool
+	CloudWatchLogGroupName      string
+	S3BucketName                string
+	S3EncryptionEnabled         bool
+	S3KeyPrefix                 string
+}
+
+// GetClusterSettings returns the current settings of the rack's ECS cluster
+func (p *Provider) GetClusterSettings() (*ClusterSettings, error) {
+	c, err := p.describeCluster()
+	if err != nil {
+		return nil, err
+	}
+
+	return clusterSettings(c), nil
+}
+
+// UpdateClusterSettings applies the fields of s that differ from the current settings of the rack's ECS cluster
+// an empty ContainerInsights and a nil LogConfiguration or DefaultCapacityProviderStrategy are left as they are,
+// ecs has no UpdateCluster field for the default strategy so it is set along with the cluster capacity providers instead
+func (p *Provider) UpdateClusterSettings(s ClusterSettings) error {
+	switch s.ContainerInsights {
+	case "", "enabled", "disabled":
+	default:
+		return fmt.Errorf("invalid container insights setting: %s", s.ContainerInsights)
+	}
+
+	for _, i := range s.DefaultCapacityProviderStrategy {
+		if i.CapacityProvider == "" {
+			return fmt.Errorf("capacity provider strategy item has no capacity provider")
+		}
+
+		if i.Weight < 0 || i.Weight > 1000 {
+			return fmt.Errorf("capacity provider %s weight %d out of range, must be between 0 and 1000", i.CapacityProvider, i.Weight)
+		}
+
+		if i.Base < 0 || i.Base > 100000 {
+			return fmt.Errorf("capacity provider %s base %d out of range, must be between 0 and 100000", i.CapacityProvider, i.Base)
+		}
+	}
+
+	c, err := p.describeCluster()
+	if err != nil {
+		return err
+	}
+
+	current := clusterSettings(c)
+
+	req := &ecsUpdateClusterInput{Cluster: aws.String(p.Cluster)}
+
+	if s.ContainerInsights != "" && s.ContainerInsights != current.ContainerInsights {
+		req.Settings = []*ecs.ClusterSetting{
+			{
+				Name:  aws.String(ecs.ClusterSettingNameContainerInsights),
+				Value: aws.String(s.ContainerInsights),
+			},
+		}
+	}
+
+	if s.LogConfiguration != nil && (current.LogConfiguration == nil || *s.LogConfiguration != *current.LogConfiguration) {
+		lc := &ecsExecuteCommandLogConfiguration{
+			CloudWatchEncryptionEnabled: aws.Bool(s.LogConfiguration.CloudWatchEncryptionEnabled),
+			S3EncryptionEnabled:         aws.Bool(s.LogConfiguration.S3EncryptionEnabled),
+		}
+
+		if s.LogConfiguration.CloudWatchLogGroupName != "" {
+			lc.CloudWatchLogGroupName = aws.String(s.LogConfiguration.CloudWatchLogGroupName)
+		}
+
+		if s.LogConfiguration.S3BucketName != "" {
+			lc.S3BucketName = aws.String(s.LogConfiguration.S3BucketName)
+			lc.S3KeyPrefix = aws.String(s.LogConfiguration.S3KeyPrefix)
+		}
+
+		req.Configuration = &ecsClusterConfiguration{
+			ExecuteCommandConfiguration: &ecsExecuteCommandConfiguration{
+				LogConfiguration: lc,
+				Logging:          aws.String("OVERRIDE"),
+			},
+		}
+	}
+
+	if req.Settings != nil || req.Configuration != nil {
+		op := &request.Operation{Name: "UpdateCluster", HTTPMethod: "POST", HTTPPath: "/"}
+
+		if err := p.ecs().NewRequest(op, req, &ecsClusterOutput{}).Send(); err != nil {
+			return err
+		}
+	}
+
+	if s.DefaultCapacityProviderStrategy != nil && !capacityProviderStrategiesEqual(s.DefaultCapacityProviderStrategy, current.DefaultCapacityProviderStrategy) {
+		cps := c.CapacityProviders
+		strategy := []*ecsCapacityProviderStrategyItem{}
+
+		// providers in the strategy have to be associated with the cluster, the ones already associated stay that way
+		for _, i := range s.DefaultCapacityProviderStrategy {
+			if !containsString(aws.StringValueSlice(cps), i.CapacityProvider) {
+				cps = append(cps, aws.String(i.CapacityProvider))
+			}
+
+			strategy = append(strategy, &ecsCapacityProviderStrategyItem{
+				Base:             aws.Int64(i.Base),
+				CapacityProvider: aws.String(i.CapacityProvider),
+				Weight:           aws.Int64(i.Weight),
+			})
+		}
+
+		op := &request.Operation{Name: "PutClusterCapacityProviders", HTTPMethod: "POST", HTTPPath: "/"}
+
+		req := p.ecs().NewRequest(op, &ecsPutClusterCapacityProvidersInput{
+			CapacityProviders:               cps,
+			Cluster:                         aws.String(p.Cluster),
+			DefaultCapacityProviderStrategy: strategy,
+		}, &ecsClusterOutput{})
+
+		if err := req.Send(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) describeCluster() (*ecsCluster, error) {
+	res := &ecsDescribeClustersOutput{}
+
+	op := &request.Operation{Name: "DescribeClusters", HTTPMethod: "POST", HTTPPath: "/"}
+
+	req := p.ecs().NewRequest(op, &ecs.DescribeClustersInput{
+		Clusters: []*string{aws.String(p.Cluster)},
+		Include:  []*string{aws.String("CONFIGURATIONS"), aws.String("SETTINGS")},
+	}, res)
+
+	if err := req.Send(); err != nil {
+		return nil, err
+	}
+
+	if len(res.Clusters) != 1 {
+		return nil, errorNotFound(fmt.Sprintf("cluster not found: %s", p.Cluster))
+	}
+
+	return res.Clusters[0], nil
+}
+
+func clusterSettings(c *ecsCluster) *ClusterSettings {
+	s := &ClusterSettings{
+		Arn:               cs(c.ClusterArn, ""),
+		ContainerInsights: "disabled",
+	}
+
+	for _, setting := range c.Settings {
+		if cs(setting.Name, "") == ecs.ClusterSettingNameContainerInsights {
+			s.ContainerInsights = cs(setting.Value, s.ContainerInsights)
+		}
+	}
+
+	for _, i := range c.DefaultCapacityProviderStrategy {
+		s.DefaultCapacityProviderStrategy = append(s.DefaultCapacityProviderStrategy, CapacityProviderStrategyItem{
+			Base:             aws.Int64Value(i.Base),
+			CapacityProvider: aws.StringValue(i.CapacityProvider),
+			Weight:           aws.Int64Value(i.Weight),
+		})
+	}
+
+	if c.Configuration != nil && c.Configuration.ExecuteCommandConfiguration != nil {
+		if lc := c.Configuration.ExecuteCommandConfiguration.LogConfiguration; lc != nil {
+			s.ExecLogGroup = cs(lc.CloudWatchLogGroupName, "")
+
+			s.LogConfiguration = &LogConfig{
+				CloudWatchEncryptionEnabled: aws.BoolValue(lc.CloudWatchEncryptionEnabled),
+				CloudWatchLogGroupName:      aws.StringValue(lc.CloudWatchLogGroupName),
+				S3BucketName:                aws.StringValue(lc.S3BucketName),
+				S3EncryptionEnabled:         aws.BoolValue(lc.S3EncryptionEnabled),
+				S3KeyPrefix:                 aws.StringValue(lc.S3KeyPrefix),
+			}
+		}
+	}
+
+	return s
+}
+
+func capacityProviderStrategiesEqual(a, b []CapacityProviderStrategyItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
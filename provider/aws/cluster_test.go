@@ -0,0 +1,188 @@
+package aws_test
+
+import (
+	"testing"
+
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetClusterSettings(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleClusterDescribeClusters("enabled", ""),
+		cycleClusterDescribeClusters("disabled", "convox-exec"),
+	)
+	defer provider.Close()
+
+	strategy := []aws.CapacityProviderStrategyItem{{Base: 1, CapacityProvider: "FARGATE", Weight: 1}}
+
+	s, err := provider.GetClusterSettings()
+	require.NoError(t, err)
+	assert.Equal(t, &aws.ClusterSettings{
+		Arn:                             "arn:aws:ecs:us-test-1:123456789012:cluster/cluster-test",
+		ContainerInsights:               "enabled",
+		DefaultCapacityProviderStrategy: strategy,
+	}, s)
+
+	s, err = provider.GetClusterSettings()
+	require.NoError(t, err)
+	assert.Equal(t, &aws.ClusterSettings{
+		Arn:                             "arn:aws:ecs:us-test-1:123456789012:cluster/cluster-test",
+		ContainerInsights:               "disabled",
+		DefaultCapacityProviderStrategy: strategy,
+		ExecLogGroup:                    "convox-exec",
+		LogConfiguration:                &aws.LogConfig{CloudWatchLogGroupName: "convox-exec"},
+	}, s)
+}
+
+func TestUpdateClusterSettings(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleClusterDescribeClusters("disabled", ""),
+		cycleClusterUpdateCluster,
+		cycleClusterPutClusterCapacityProviders,
+	)
+	defer provider.Close()
+
+	err := provider.UpdateClusterSettings(aws.ClusterSettings{
+		ContainerInsights: "enabled",
+		DefaultCapacityProviderStrategy: []aws.CapacityProviderStrategyItem{
+			{Base: 1, CapacityProvider: "FARGATE", Weight: 1},
+			{CapacityProvider: "FARGATE_SPOT", Weight: 4},
+		},
+		LogConfiguration: &aws.LogConfig{CloudWatchEncryptionEnabled: true, CloudWatchLogGroupName: "convox-exec"},
+	})
+	require.NoError(t, err)
+}
+
+func TestUpdateClusterSettingsUnchanged(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleClusterDescribeClusters("enabled", "convox-exec"),
+	)
+	defer provider.Close()
+
+	err := provider.UpdateClusterSettings(aws.ClusterSettings{
+		ContainerInsights:               "enabled",
+		DefaultCapacityProviderStrategy: []aws.CapacityProviderStrategyItem{{Base: 1, CapacityProvider: "FARGATE", Weight: 1}},
+		LogConfiguration:                &aws.LogConfig{CloudWatchLogGroupName: "convox-exec"},
+	})
+	require.NoError(t, err)
+}
+
+func TestUpdateClusterSettingsInvalid(t *testing.T) {
+	provider := StubAwsProvider()
+	defer provider.Close()
+
+	err := provider.UpdateClusterSettings(aws.ClusterSettings{ContainerInsights: "on"})
+	require.EqualError(t, err, "invalid container insights setting: on")
+
+	err = provider.UpdateClusterSettings(aws.ClusterSettings{DefaultCapacityProviderStrategy: []aws.CapacityProviderStrategyItem{{CapacityProvider: "FARGATE", Weight: 1001}}})
+	require.EqualError(t, err, "capacity provider FARGATE weight 1001 out of range, must be between 0 and 1000")
+
+	err = provider.UpdateClusterSettings(aws.ClusterSettings{DefaultCapacityProviderStrategy: []aws.CapacityProviderStrategyItem{{Weight: 1}}})
+	require.EqualError(t, err, "capacity provider strategy item has no capacity provider")
+}
+
+func cycleClusterDescribeClusters(insights, execLogGroup string) awsutil.Cycle {
+	configuration := ""
+
+	if execLogGroup != "" {
+		configuration = `"configuration": {
+			"executeCommandConfiguration": {
+				"logConfiguration": { "cloudWatchLogGroupName": "` + execLogGroup + `" },
+				"logging": "OVERRIDE"
+			}
+		},`
+	}
+
+	return awsutil.Cycle{
+		Request: awsutil.Request{
+			RequestURI: "/",
+			Operation:  "AmazonEC2ContainerServiceV20141113.DescribeClusters",
+			Body: `{
+				"clusters": ["cluster-test"],
+				"include": ["CONFIGURATIONS", "SETTINGS"]
+			}`,
+		},
+		Response: awsutil.Response{
+			StatusCode: 200,
+			Body: `{
+				"clusters": [
+					{
+						"capacityProviders": ["FARGATE"],
+						"clusterArn": "arn:aws:ecs:us-test-1:123456789012:cluster/cluster-test",
+						"clusterName": "cluster-test",
+						` + configuration + `
+						"defaultCapacityProviderStrategy": [
+							{ "base": 1, "capacityProvider": "FARGATE", "weight": 1 }
+						],
+						"settings": [
+							{ "name": "containerInsights", "value": "` + insights + `" }
+						],
+						"status": "ACTIVE"
+					}
+				],
+				"failures": []
+			}`,
+		},
+	}
+}
+
+var cycleClusterUpdateCluster = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "AmazonEC2ContainerServiceV20141113.UpdateCluster",
+		Body: `{
+			"cluster": "cluster-test",
+			"configuration": {
+				"executeCommandConfiguration": {
+					"logConfiguration": {
+						"cloudWatchEncryptionEnabled": true,
+						"cloudWatchLogGroupName": "convox-exec",
+						"s3EncryptionEnabled": false
+					},
+					"logging": "OVERRIDE"
+				}
+			},
+			"settings": [
+				{ "name": "containerInsights", "value": "enabled" }
+			]
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"cluster": {
+				"clusterName": "cluster-test",
+				"settings": [
+					{ "name": "containerInsights", "value": "enabled" }
+				]
+			}
+		}`,
+	},
+}
+
+var cycleClusterPutClusterCapacityProviders = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "AmazonEC2ContainerServiceV20141113.PutClusterCapacityProviders",
+		Body: `{
+			"capacityProviders": ["FARGATE", "FARGATE_SPOT"],
+			"cluster": "cluster-test",
+			"defaultCapacityProviderStrategy": [
+				{ "base": 1, "capacityProvider": "FARGATE", "weight": 1 },
+				{ "base": 0, "capacityProvider": "FARGATE_SPOT", "weight": 4 }
+			]
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"cluster": {
+				"capacityProviders": ["FARGATE", "FARGATE_SPOT"],
+				"clusterName": "cluster-test"
+			}
+		}`,
+	},
+}
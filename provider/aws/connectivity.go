@@ -0,0 +1,292 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+const (
+	// connectivityImage is run beside the source service to probe the target, it ships nc and time
+	connectivityImage = "busybox"
+
+	// connectivityDialTimeout is how many seconds nc waits for the target to accept
+	connectivityDialTimeout = 5
+)
+
+// connectivityTimeout bounds a whole connectivity test, from starting the probe task to reading its result
+var connectivityTimeout = 60 * time.Second
+
+var regexpConnectivityReal = regexp.MustCompile(`real\s+(?:(\d+)m\s*)?([0-9.]+)s`)
+
+// ConnectivityResult is the outcome of connecting from one service to a port of another
+type ConnectivityResult struct {
+	Success bool          `json:"success"`
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// TestConnectivity starts a busybox task with the network settings of fromService and checks
+// that it can open a tcp connection to port on a running task of toService
+// a failed connection is reported in the result, errors are for tests that could not be run
+func (p *Provider) TestConnectivity(app, fromService, toService string, port int) (*ConnectivityResult, error) {
+	log := Logger.At("TestConnectivity").Namespace("app=%q from=%q to=%q port=%d", app, fromService, toService, port).Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectivityTimeout)
+	defer cancel()
+
+	from, err := p.serviceEcs(app, fromService)
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	to, err := p.serviceEcs(app, toService)
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	host, hostPort, err := p.serviceAddress(to, port)
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	td, err := p.describeTaskDefinition(&ecs.DescribeTaskDefinitionInput{TaskDefinition: from.TaskDefinition})
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	probe, err := p.registerConnectivityTask(app, from, td.TaskDefinition)
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	defer func() {
+		if _, err := p.ecs().DeregisterTaskDefinition(&ecs.DeregisterTaskDefinitionInput{TaskDefinition: aws.String(probe)}); err != nil {
+			log.Logf("deregister=%q error=%q", probe, err)
+		}
+	}()
+
+	req := &ecs.RunTaskInput{
+		Cluster:              aws.String(p.Cluster),
+		Count:                aws.Int64(1),
+		LaunchType:           from.LaunchType,
+		NetworkConfiguration: from.NetworkConfiguration,
+		StartedBy:            aws.String(fmt.Sprintf("convox.%s", app)),
+		TaskDefinition:       aws.String(probe),
+	}
+
+	opts := []request.Option{}
+
+	if p.EcsExec || aws.StringValue(from.LaunchType) == ecs.LaunchTypeFargate {
+		opts = append(opts, ecsRequestField("enableExecuteCommand", true))
+	}
+
+	task, err := p.runTask(req, opts...)
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	arn := aws.StringValue(task.TaskArn)
+
+	// the probe is stopped however the test ends
+	defer func() {
+		if err := p.stopTask(arn, "connectivity test finished"); err != nil {
+			log.Logf("stop=%q error=%q", arn, err)
+		}
+	}()
+
+	task, err = p.waitForTaskStatus(ctx, arn, "RUNNING")
+	if ctx.Err() != nil {
+		return nil, log.Error(connectivityTimeoutError())
+	}
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	cmd := []string{"sh", "-c", fmt.Sprintf("time nc -zv -w %d %s %d", connectivityDialTimeout, host, hostPort)}
+
+	var out bytes.Buffer
+
+	s, err := p.taskExec(ctx, task, cmd, struct {
+		io.Reader
+		io.Writer
+	}{&bytes.Buffer{}, &out})
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	code, err := s.Wait()
+	if ctx.Err() != nil {
+		return nil, log.Error(connectivityTimeoutError())
+	}
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	log.Success()
+
+	return parseConnectivity(out.String(), code), nil
+}
+
+func connectivityTimeoutError() error {
+	return fmt.Errorf("connectivity test timed out after %s", connectivityTimeout)
+}
+
+// registerConnectivityTask registers a task definition for the probe that can run where the service runs
+func (p *Provider) registerConnectivityTask(app string, s *ecs.Service, td *ecs.TaskDefinition) (string, error) {
+	c := &ecs.ContainerDefinition{
+		Command:   aws.StringSlice([]string{"sleep", strconv.Itoa(int(connectivityTimeout.Seconds()))}),
+		Essential: aws.Bool(true),
+		Image:     aws.String(connectivityImage),
+		Name:      aws.String("connectivity"),
+	}
+
+	req := &ecs.RegisterTaskDefinitionInput{
+		ContainerDefinitions: []*ecs.ContainerDefinition{c},
+		Family:               aws.String(fmt.Sprintf("%s-%s-connectivity", p.Rack, app)),
+		NetworkMode:          td.NetworkMode,
+		TaskRoleArn:          td.TaskRoleArn,
+	}
+
+	if aws.StringValue(s.LaunchType) == ecs.LaunchTypeFargate {
+		req.Cpu = aws.String("256")
+		req.Memory = aws.String("512")
+		req.ExecutionRoleArn = td.ExecutionRoleArn
+		req.RequiresCompatibilities = aws.StringSlice([]string{ecs.CompatibilityFargate})
+	} else {
+		c.Memory = aws.Int64(64)
+	}
+
+	res, err := p.ecs().RegisterTaskDefinition(req)
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(res.TaskDefinition.TaskDefinitionArn), nil
+}
+
+// serviceAddress returns the host and port that reach port of a running task of an ecs service
+// awsvpc tasks are reached on their own address, other tasks on their instance and the host port mapped to port
+func (p *Provider) serviceAddress(s *ecs.Service, port int) (string, int, error) {
+	res, err := p.ecs().ListTasks(&ecs.ListTasksInput{
+		Cluster:       aws.String(p.Cluster),
+		DesiredStatus: aws.String("RUNNING"),
+		ServiceName:   s.ServiceName,
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	if len(res.TaskArns) < 1 {
+		return "", 0, fmt.Errorf("no running tasks for service: %s", aws.StringValue(s.ServiceName))
+	}
+
+	task, err := p.describeTask(aws.StringValue(res.TaskArns[0]))
+	if err != nil {
+		return "", 0, err
+	}
+
+	for _, c := range task.Containers {
+		for _, ni := range c.NetworkInterfaces {
+			if ip := aws.StringValue(ni.PrivateIpv4Address); ip != "" {
+				return ip, port, nil
+			}
+		}
+	}
+
+	if task.ContainerInstanceArn == nil {
+		return "", 0, fmt.Errorf("could not find address for task: %s", aws.StringValue(task.TaskArn))
+	}
+
+	ci, err := p.containerInstance(*task.ContainerInstanceArn)
+	if err != nil {
+		return "", 0, err
+	}
+
+	i, err := p.describeInstance(aws.StringValue(ci.Ec2InstanceId))
+	if err != nil {
+		return "", 0, err
+	}
+
+	hostPort := port
+
+	for _, c := range task.Containers {
+		for _, nb := range c.NetworkBindings {
+			if int(aws.Int64Value(nb.ContainerPort)) == port {
+				hostPort = int(aws.Int64Value(nb.HostPort))
+			}
+		}
+	}
+
+	return aws.StringValue(i.PrivateIpAddress), hostPort, nil
+}
+
+// taskExec runs cmd in the first container of a task through ECS Exec or the docker api of its instance
+func (p *Provider) taskExec(ctx context.Context, task *ecs.Task, cmd []string, rw io.ReadWriter) (execSession, error) {
+	ecsExec, err := p.useECSExec(task)
+	if err != nil {
+		return nil, err
+	}
+
+	if ecsExec {
+		return p.execCommand(ctx, task, cmd, false, rw)
+	}
+
+	dc, err := p.dockerClientFromPid(arnToPid(aws.StringValue(task.TaskArn)))
+	if err != nil {
+		return nil, err
+	}
+
+	cs, err := dc.ListContainers(docker.ListContainersOptions{
+		Filters: map[string][]string{
+			"label": {fmt.Sprintf("com.amazonaws.ecs.task-arn=%s", aws.StringValue(task.TaskArn))},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) != 1 {
+		return nil, fmt.Errorf("could not find container for task: %s", aws.StringValue(task.TaskArn))
+	}
+
+	return startDockerExec(ctx, dc, cs[0].ID, cmd, rw, processExecOptions{})
+}
+
+// parseConnectivity reads the output of time nc -zv, latency comes from the real time reported by time
+func parseConnectivity(out string, code int) *ConnectivityResult {
+	r := &ConnectivityResult{Success: code == 0 && strings.Contains(out, "open")}
+
+	if m := regexpConnectivityReal.FindStringSubmatch(out); len(m) == 3 {
+		minutes, _ := strconv.Atoi(coalesces(m[1], "0"))
+		seconds, _ := strconv.ParseFloat(m[2], 64)
+
+		r.Latency = time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second))
+	}
+
+	if r.Success {
+		return r
+	}
+
+	// ecs exec sessions wrap the output in session messages so only the line nc reports its error on is kept
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "nc:") {
+			r.Error = line
+			break
+		}
+	}
+
+	if r.Error == "" {
+		r.Error = fmt.Sprintf("nc exited with code %d", code)
+	}
+
+	return r
+}
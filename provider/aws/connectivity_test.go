@@ -0,0 +1,201 @@
+package aws_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestConnectivity(t *testing.T) {
+	provider := connectivityTestProvider(t, "#!/bin/sh\necho \"10.0.1.9 (10.0.1.9:5000) open\"\nprintf 'real\\t0m 0.02s\\nuser\\t0m 0.00s\\n'\n")
+	defer provider.Close()
+
+	done := connectivityStartProbe(t, provider)
+
+	r, err := provider.TestConnectivity("httpd", "web", "api", 5000)
+	require.NoError(t, err)
+	<-done
+
+	assert.Equal(t, &aws.ConnectivityResult{Success: true, Latency: 20 * time.Millisecond}, r)
+
+	cmds := provider.Fake.ECS.ExecuteCommands()
+	require.Len(t, cmds, 1)
+	assert.Equal(t, `'sh' '-c' 'time nc -zv -w 5 10.0.1.9 5000'`, cmds[0].Command)
+	assert.Equal(t, "connectivity", cmds[0].Container)
+
+	// the probe runs on fargate so it is started with ECS Exec enabled
+	runs := provider.Fake.ECS.RunTasks()
+	require.Len(t, runs, 1)
+	assert.True(t, provider.Fake.ECS.TaskExecuteCommand(runs[0]))
+
+	connectivityAssertCleanedUp(t, provider)
+}
+
+func TestTestConnectivityRefused(t *testing.T) {
+	provider := connectivityTestProvider(t, "#!/bin/sh\necho \"nc: 10.0.1.9 (10.0.1.9:6000): Connection refused\"\nprintf 'real\\t0m 1.50s\\n'\nexit 1\n")
+	defer provider.Close()
+
+	done := connectivityStartProbe(t, provider)
+
+	r, err := provider.TestConnectivity("httpd", "web", "api", 6000)
+	require.NoError(t, err)
+	<-done
+
+	assert.Equal(t, &aws.ConnectivityResult{Latency: 1500 * time.Millisecond, Error: "nc: 10.0.1.9 (10.0.1.9:6000): Connection refused"}, r)
+
+	connectivityAssertCleanedUp(t, provider)
+}
+
+func TestTestConnectivityTimeout(t *testing.T) {
+	provider := connectivityTestProvider(t, "#!/bin/sh\nexit 0\n")
+	defer provider.Close()
+
+	aws.SetConnectivityTimeout(50 * time.Millisecond)
+	defer aws.SetConnectivityTimeout(60 * time.Second)
+
+	// the probe never leaves PENDING
+	_, err := provider.TestConnectivity("httpd", "web", "api", 5000)
+	require.EqualError(t, err, "connectivity test timed out after 50ms")
+
+	assert.Len(t, provider.Fake.ECS.ExecuteCommands(), 0)
+
+	connectivityAssertCleanedUp(t, provider)
+}
+
+func TestTestConnectivityNoTarget(t *testing.T) {
+	provider := connectivityTestProvider(t, "#!/bin/sh\nexit 0\n")
+	defer provider.Close()
+
+	_, err := provider.TestConnectivity("httpd", "web", "worker", 5000)
+	require.EqualError(t, err, "no running tasks for service: convox-httpd-ServiceWorker")
+
+	assert.Len(t, provider.Fake.ECS.RunTasks(), 0)
+}
+
+func TestParseConnectivity(t *testing.T) {
+	assert.Equal(t, &aws.ConnectivityResult{Success: true, Latency: 62*time.Second + 500*time.Millisecond}, aws.ParseConnectivity("host (10.0.0.1:80) open\nreal\t1m 2.50s\n", 0))
+	assert.Equal(t, &aws.ConnectivityResult{Error: "nc exited with code 1"}, aws.ParseConnectivity("", 1))
+	assert.Equal(t, &aws.ConnectivityResult{Error: "nc: bad address 'api'"}, aws.ParseConnectivity("Starting session with SessionId: ecs-execute-command-1\nnc: bad address 'api'\n\nExiting session with sessionId: ecs-execute-command-1.\n", 1))
+}
+
+// connectivityTestProvider runs fargate services web and api, with a task of api at 10.0.1.9,
+// and an ec2 service worker without tasks; plugin stands in for the session manager plugin
+func connectivityTestProvider(t *testing.T, plugin string) *awsfake.TestProvider {
+	p := awsfake.NewTestProvider()
+
+	aws.SetTaskStatusPollInterval(5 * time.Millisecond)
+
+	resources := []awsfake.Resource{}
+
+	for _, name := range []string{"Web", "Api", "Worker"} {
+		arn := "arn:aws:ecs:us-test-1:123456789012:service/cluster-test/convox-httpd-Service" + name
+
+		stack := p.Fake.CloudFormation.AddStack(awsfake.Stack{
+			Name:      "convox-httpd-Service" + name,
+			Resources: []awsfake.Resource{{LogicalId: "Service", PhysicalId: arn}},
+		})
+
+		resources = append(resources, awsfake.Resource{LogicalId: "Service" + name, PhysicalId: stack})
+
+		td := p.Fake.ECS.AddTaskDefinition(&ecs.TaskDefinition{
+			Family:      awssdk.String("convox-httpd-service-" + name),
+			NetworkMode: awssdk.String("awsvpc"),
+			Revision:    awssdk.Int64(1),
+			TaskRoleArn: awssdk.String("arn:aws:iam::123456789012:role/convox-httpd-task"),
+		})
+
+		require.NoError(t, p.Fake.ECS.AddService("cluster-test", &ecs.Service{
+			LaunchType: awssdk.String(ecs.LaunchTypeFargate),
+			NetworkConfiguration: &ecs.NetworkConfiguration{AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+				SecurityGroups: awssdk.StringSlice([]string{"sg-web"}),
+				Subnets:        awssdk.StringSlice([]string{"subnet-1"}),
+			}},
+			ServiceArn:     awssdk.String(arn),
+			ServiceName:    awssdk.String("convox-httpd-Service" + name),
+			TaskDefinition: awssdk.String(td),
+		}))
+	}
+
+	p.AddApp("httpd", "2", awsfake.Stack{Resources: resources})
+
+	require.NoError(t, p.Fake.ECS.AddTask("cluster-test", &ecs.Task{
+		Containers: []*ecs.Container{{
+			Name:              awssdk.String("api"),
+			NetworkInterfaces: []*ecs.NetworkInterface{{PrivateIpv4Address: awssdk.String("10.0.1.9")}},
+		}},
+		DesiredStatus: awssdk.String("RUNNING"),
+		Group:         awssdk.String("service:convox-httpd-ServiceApi"),
+		LastStatus:    awssdk.String("RUNNING"),
+		LaunchType:    awssdk.String(ecs.LaunchTypeFargate),
+	}))
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "session-manager-plugin")
+	require.NoError(t, ioutil.WriteFile(path, []byte(plugin), 0755))
+
+	aws.SetSessionManagerPlugin(path)
+
+	t.Cleanup(func() {
+		aws.SetSessionManagerPlugin("session-manager-plugin")
+		aws.SetTaskStatusPollInterval(1 * time.Second)
+		os.RemoveAll(dir)
+	})
+
+	return p
+}
+
+// connectivityStartProbe moves the probe task to RUNNING once it has been started
+func connectivityStartProbe(t *testing.T, p *awsfake.TestProvider) chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 1000; i++ {
+			if runs := p.Fake.ECS.RunTasks(); len(runs) > 0 {
+				assert.NoError(t, p.Fake.ECS.SetTaskStatus(runs[0], "RUNNING"))
+				return
+			}
+
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		t.Error("probe task was never started")
+	}()
+
+	return done
+}
+
+// connectivityAssertCleanedUp checks the probe task was stopped and its task definition deregistered
+func connectivityAssertCleanedUp(t *testing.T, p *awsfake.TestProvider) {
+	runs := p.Fake.ECS.RunTasks()
+	require.Len(t, runs, 1)
+
+	e := ecs.New(session.New(), p.Fake.Config())
+
+	tres, err := e.DescribeTasks(&ecs.DescribeTasksInput{Cluster: awssdk.String("cluster-test"), Tasks: awssdk.StringSlice(runs)})
+	require.NoError(t, err)
+	require.Len(t, tres.Tasks, 1)
+	assert.Equal(t, "STOPPED", awssdk.StringValue(tres.Tasks[0].LastStatus))
+	assert.Equal(t, "convox.httpd", awssdk.StringValue(tres.Tasks[0].StartedBy))
+	assert.Equal(t, ecs.LaunchTypeFargate, awssdk.StringValue(tres.Tasks[0].LaunchType))
+
+	dres, err := e.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{TaskDefinition: tres.Tasks[0].TaskDefinitionArn})
+	require.NoError(t, err)
+	assert.Equal(t, "convox-httpd-connectivity", awssdk.StringValue(dres.TaskDefinition.Family))
+	assert.Equal(t, "INACTIVE", awssdk.StringValue(dres.TaskDefinition.Status))
+	assert.Equal(t, "awsvpc", awssdk.StringValue(dres.TaskDefinition.NetworkMode))
+	assert.Equal(t, "busybox", awssdk.StringValue(dres.TaskDefinition.ContainerDefinitions[0].Image))
+}
@@ -0,0 +1,196 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/pricing"
+)
+
+const costHoursPerMonth = 730
+
+var pricingCacheTTL = 24 * time.Hour
+
+// pricingLocations are the location names the pricing api uses for each region
+var pricingLocations = map[string]string{
+	"ap-east-1":      "Asia Pacific (Hong Kong)",
+	"ap-northeast-1": "Asia Pacific (Tokyo)",
+	"ap-northeast-2": "Asia Pacific (Seoul)",
+	"ap-south-1":     "Asia Pacific (Mumbai)",
+	"ap-southeast-1": "Asia Pacific (Singapore)",
+	"ap-southeast-2": "Asia Pacific (Sydney)",
+	"ca-central-1":   "Canada (Central)",
+	"eu-central-1":   "EU (Frankfurt)",
+	"eu-north-1":     "EU (Stockholm)",
+	"eu-west-1":      "EU (Ireland)",
+	"eu-west-2":      "EU (London)",
+	"eu-west-3":      "EU (Paris)",
+	"me-south-1":     "Middle East (Bahrain)",
+	"sa-east-1":      "South America (Sao Paulo)",
+	"us-east-1":      "US East (N. Virginia)",
+	"us-east-2":      "US East (Ohio)",
+	"us-west-1":      "US West (N. California)",
+	"us-west-2":      "US West (Oregon)",
+}
+
+// CostEstimate is the projected monthly on-demand cost of a rack
+type CostEstimate struct {
+	InstanceCost     float64 `json:"instance-cost"`
+	StorageCost      float64 `json:"storage-cost"`
+	DataTransferCost float64 `json:"data-transfer-cost"`
+	Total            float64 `json:"total"`
+	Currency         string  `json:"currency"`
+}
+
+type pricingCacheEntry struct {
+	Price   float64   `json:"price"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// EstimateMonthlyCost projects the monthly cost of the rack instances and their volumes from on-demand prices
+// data transfer depends on traffic the rack can not predict so it is not estimated
+func (p *Provider) EstimateMonthlyCost() (*CostEstimate, error) {
+	log := Logger.At("EstimateMonthlyCost").Start()
+
+	location, ok := pricingLocations[p.Region]
+	if !ok {
+		return nil, log.Error(fmt.Errorf("no pricing location for region %s", p.Region))
+	}
+
+	stack, err := p.describeStack(p.Rack)
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	params := stackParameters(stack)
+
+	count, err := strconv.Atoi(params["InstanceCount"])
+	if err != nil {
+		return nil, log.Error(fmt.Errorf("invalid instance count: %s", params["InstanceCount"]))
+	}
+
+	volume := 0
+
+	for _, name := range []string{"VolumeSize", "SwapSize"} {
+		if v, err := strconv.Atoi(params[name]); err == nil {
+			volume += v
+		}
+	}
+
+	hourly, err := p.pricingLookup(fmt.Sprintf("ec2-%s", params["InstanceType"]), map[string]string{
+		"capacitystatus":  "Used",
+		"instanceType":    params["InstanceType"],
+		"location":        location,
+		"operatingSystem": "Linux",
+		"preInstalledSw":  "NA",
+		"tenancy":         "Shared",
+	})
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	storage, err := p.pricingLookup("ebs-gp3", map[string]string{
+		"location":      location,
+		"productFamily": "Storage",
+		"volumeApiName": "gp3",
+	})
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	e := &CostEstimate{
+		InstanceCost: hourly * costHoursPerMonth * float64(count),
+		StorageCost:  storage * float64(volume) * float64(count),
+		Currency:     "USD",
+	}
+
+	e.Total = e.InstanceCost + e.StorageCost + e.DataTransferCost
+
+	return e, log.Successf("total=%0.2f", e.Total)
+}
+
+// pricingLookup returns the on-demand USD price of the ec2 product matching filters
+// prices are cached in the settings bucket as they rarely change and the pricing api is slow
+func (p *Provider) pricingLookup(name string, filters map[string]string) (float64, error) {
+	key := fmt.Sprintf("pricing/%s/%s.json", p.Region, name)
+
+	if data, err := p.s3Get(p.SettingsBucket, key); err == nil {
+		var c pricingCacheEntry
+
+		if err := json.Unmarshal(data, &c); err == nil && time.Since(c.Fetched) < pricingCacheTTL {
+			return c.Price, nil
+		}
+	}
+
+	fields := []string{}
+
+	for f := range filters {
+		fields = append(fields, f)
+	}
+
+	sort.Strings(fields)
+
+	req := &pricing.GetProductsInput{
+		FormatVersion: aws.String("aws_v1"),
+		ServiceCode:   aws.String("AmazonEC2"),
+	}
+
+	for _, f := range fields {
+		req.Filters = append(req.Filters, &pricing.Filter{
+			Field: aws.String(f),
+			Type:  aws.String(pricing.FilterTypeTermMatch),
+			Value: aws.String(filters[f]),
+		})
+	}
+
+	res, err := p.pricing().GetProducts(req)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(res.PriceList) < 1 {
+		return 0, fmt.Errorf("no price found for %s", name)
+	}
+
+	price, err := onDemandPrice(res.PriceList[0])
+	if err != nil {
+		return 0, fmt.Errorf("could not read price for %s: %s", name, err)
+	}
+
+	data, err := json.Marshal(pricingCacheEntry{Price: price, Fetched: time.Now().UTC()})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := p.s3Put(p.SettingsBucket, key, data, false); err != nil {
+		return 0, err
+	}
+
+	return price, nil
+}
+
+// onDemandPrice reads terms.OnDemand.<offer>.priceDimensions.<rate>.pricePerUnit.USD from a price list entry
+func onDemandPrice(product aws.JSONValue) (float64, error) {
+	terms, _ := product["terms"].(map[string]interface{})
+	ondemand, _ := terms["OnDemand"].(map[string]interface{})
+
+	for _, offer := range ondemand {
+		o, _ := offer.(map[string]interface{})
+		dimensions, _ := o["priceDimensions"].(map[string]interface{})
+
+		for _, dimension := range dimensions {
+			d, _ := dimension.(map[string]interface{})
+			unit, _ := d["pricePerUnit"].(map[string]interface{})
+
+			if usd, ok := unit["USD"].(string); ok {
+				return strconv.ParseFloat(usd, 64)
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("no on-demand USD price")
+}
@@ -0,0 +1,98 @@
+package aws_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateMonthlyCost(t *testing.T) {
+	provider := costTestProvider()
+	defer provider.Close()
+
+	e, err := provider.EstimateMonthlyCost()
+	require.NoError(t, err)
+
+	// 0.0832/hour * 730 hours * 2 instances
+	assert.InDelta(t, 121.472, e.InstanceCost, 0.0001)
+	// 0.08/GB-month * (50GB volume + 5GB swap) * 2 instances
+	assert.InDelta(t, 8.8, e.StorageCost, 0.0001)
+	assert.Equal(t, 0.0, e.DataTransferCost)
+	assert.InDelta(t, 130.272, e.Total, 0.0001)
+	assert.Equal(t, "USD", e.Currency)
+	assert.Equal(t, 2, provider.Fake.Pricing.Requests())
+
+	data, ok := provider.Fake.S3.Object("convox-settings", "pricing/us-east-1/ec2-t3.large.json")
+	require.True(t, ok)
+
+	var cached struct {
+		Price float64
+	}
+
+	require.NoError(t, json.Unmarshal(data, &cached))
+	assert.Equal(t, 0.0832, cached.Price)
+
+	// prices come from the settings bucket while the cache is fresh
+	e, err = provider.EstimateMonthlyCost()
+	require.NoError(t, err)
+	assert.InDelta(t, 130.272, e.Total, 0.0001)
+	assert.Equal(t, 2, provider.Fake.Pricing.Requests())
+}
+
+func TestEstimateMonthlyCostStaleCache(t *testing.T) {
+	provider := costTestProvider()
+	defer provider.Close()
+
+	stale, err := json.Marshal(map[string]interface{}{"price": 1.0, "fetched": time.Now().Add(-25 * time.Hour)})
+	require.NoError(t, err)
+
+	provider.Fake.S3.PutObject("convox-settings", "pricing/us-east-1/ec2-t3.large.json", stale, nil)
+
+	e, err := provider.EstimateMonthlyCost()
+	require.NoError(t, err)
+	assert.InDelta(t, 121.472, e.InstanceCost, 0.0001)
+	assert.Equal(t, 2, provider.Fake.Pricing.Requests())
+}
+
+func TestEstimateMonthlyCostUnknownRegion(t *testing.T) {
+	provider := costTestProvider()
+	defer provider.Close()
+
+	provider.Region = "xx-nowhere-1"
+
+	_, err := provider.EstimateMonthlyCost()
+	require.EqualError(t, err, "no pricing location for region xx-nowhere-1")
+}
+
+func costTestProvider() *awsfake.TestProvider {
+	provider := awsfake.NewTestProvider()
+	provider.Region = "us-east-1"
+
+	provider.AddRack(awsfake.Stack{
+		Parameters: map[string]string{"InstanceCount": "2", "InstanceType": "t3.large", "SwapSize": "5", "VolumeSize": "50"},
+	})
+
+	provider.Fake.Pricing.AddProduct(awsfake.Product{
+		ServiceCode: "AmazonEC2",
+		Attributes:  map[string]string{"capacitystatus": "Used", "instanceType": "t3.large", "location": "US East (N. Virginia)", "operatingSystem": "Linux", "preInstalledSw": "NA", "tenancy": "Shared"},
+		USD:         "0.0832000000",
+	})
+
+	provider.Fake.Pricing.AddProduct(awsfake.Product{
+		ServiceCode: "AmazonEC2",
+		Attributes:  map[string]string{"capacitystatus": "Used", "instanceType": "t3.small", "location": "US East (N. Virginia)", "operatingSystem": "Linux", "preInstalledSw": "NA", "tenancy": "Shared"},
+		USD:         "0.0208000000",
+	})
+
+	provider.Fake.Pricing.AddProduct(awsfake.Product{
+		ServiceCode: "AmazonEC2",
+		Attributes:  map[string]string{"location": "US East (N. Virginia)", "productFamily": "Storage", "volumeApiName": "gp3"},
+		USD:         "0.0800000000",
+	})
+
+	return provider
+}
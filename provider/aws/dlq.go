@@ -0,0 +1,116 @@
+package aws
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// sqs returns at most 10 messages from each ReceiveMessage call
+const sqsReceiveLimit = 10
+
+// dlqVisibilityTimeout hides received messages long enough that one listing does not return them twice
+// they are never deleted so they become visible again once it expires
+const dlqVisibilityTimeout = 30
+
+// DLQMessage is a message a worker failed to process that was moved to its dead letter queue
+type DLQMessage struct {
+	MessageId               string
+	Body                    string
+	Attributes              map[string]string
+	ApproximateReceiveCount int
+}
+
+// GetDLQMessages returns up to maxMessages messages from the dead letter queue of a service without removing them
+func (p *Provider) GetDLQMessages(app, service string, maxMessages int) ([]DLQMessage, error) {
+	url, err := p.dlqUrl(app, service)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := []DLQMessage{}
+	seen := map[string]bool{}
+
+	for len(msgs) < maxMessages {
+		max := maxMessages - len(msgs)
+		if max > sqsReceiveLimit {
+			max = sqsReceiveLimit
+		}
+
+		res, err := p.sqs().ReceiveMessage(&sqs.ReceiveMessageInput{
+			AttributeNames:      []*string{aws.String("All")},
+			MaxNumberOfMessages: aws.Int64(int64(max)),
+			QueueUrl:            aws.String(url),
+			VisibilityTimeout:   aws.Int64(dlqVisibilityTimeout),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(res.Messages) == 0 {
+			break
+		}
+
+		for _, m := range res.Messages {
+			id := aws.StringValue(m.MessageId)
+
+			if seen[id] {
+				continue
+			}
+
+			seen[id] = true
+
+			dm := DLQMessage{
+				MessageId:  id,
+				Body:       aws.StringValue(m.Body),
+				Attributes: map[string]string{},
+			}
+
+			for k, v := range m.Attributes {
+				dm.Attributes[k] = aws.StringValue(v)
+			}
+
+			if c, err := strconv.Atoi(dm.Attributes["ApproximateReceiveCount"]); err == nil {
+				dm.ApproximateReceiveCount = c
+			}
+
+			msgs = append(msgs, dm)
+		}
+	}
+
+	return msgs, nil
+}
+
+// PurgeDLQ deletes every message in the dead letter queue of a service
+func (p *Provider) PurgeDLQ(app, service string) error {
+	url, err := p.dlqUrl(app, service)
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.sqs().PurgeQueue(&sqs.PurgeQueueInput{QueueUrl: aws.String(url)}); err != nil {
+		return err
+	}
+
+	p.auditRecord("dlq.purge", app, p.actor(), map[string]string{"service": service})
+
+	return nil
+}
+
+// dlqUrl returns the url of the dead letter queue in the service stack, or the app wide one if the service has none
+func (p *Provider) dlqUrl(app, service string) (string, error) {
+	rs, err := p.appResourcesRecursive(app)
+	if err != nil {
+		return "", err
+	}
+
+	for _, id := range []string{fmt.Sprintf("Service%s.WorkerDeadLetterQueue", upperName(service)), "WorkerDeadLetterQueue"} {
+		if url, ok := rs[id]; ok {
+			return url, nil
+		}
+	}
+
+	return "", errorNotFound(fmt.Sprintf("dead letter queue not found for service: %s", service))
+}
@@ -0,0 +1,112 @@
+package aws_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDLQMessages(t *testing.T) {
+	provider, queue := dlqTestProvider()
+	defer provider.Close()
+
+	for i := 0; i < 25; i++ {
+		require.NoError(t, provider.Fake.SQS.Send(queue, fmt.Sprintf("job %d", i)))
+	}
+
+	msgs, err := provider.GetDLQMessages("httpd", "worker", 23)
+	require.NoError(t, err)
+	require.Len(t, msgs, 23)
+	assert.Equal(t, 3, provider.Fake.SQS.Receives(queue))
+
+	assert.Equal(t, "00000001-0000-0000-0000-000000000000", msgs[0].MessageId)
+	assert.Equal(t, "job 0", msgs[0].Body)
+	assert.Equal(t, 1, msgs[0].ApproximateReceiveCount)
+	assert.Equal(t, "1", msgs[0].Attributes["ApproximateReceiveCount"])
+	assert.Equal(t, "job 22", msgs[22].Body)
+
+	// listing does not delete the messages
+	assert.Equal(t, 25, provider.Fake.SQS.Pending(queue))
+}
+
+func TestGetDLQMessagesDrained(t *testing.T) {
+	provider, queue := dlqTestProvider()
+	defer provider.Close()
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, provider.Fake.SQS.Send(queue, fmt.Sprintf("job %d", i)))
+	}
+
+	msgs, err := provider.GetDLQMessages("httpd", "worker", 50)
+	require.NoError(t, err)
+	assert.Len(t, msgs, 4)
+	assert.Equal(t, 2, provider.Fake.SQS.Receives(queue))
+}
+
+func TestGetDLQMessagesAppQueue(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	queue := provider.Fake.SQS.AddQueue("convox-httpd-dlq")
+
+	provider.AddApp("httpd", "2", awsfake.Stack{
+		Resources: []awsfake.Resource{{LogicalId: "WorkerDeadLetterQueue", PhysicalId: queue, Type: "AWS::SQS::Queue"}},
+	})
+
+	require.NoError(t, provider.Fake.SQS.Send(queue, "job"))
+
+	msgs, err := provider.GetDLQMessages("httpd", "worker", 10)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "job", msgs[0].Body)
+}
+
+func TestGetDLQMessagesNoQueue(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.AddApp("httpd", "2", awsfake.Stack{})
+
+	_, err := provider.GetDLQMessages("httpd", "worker", 10)
+	require.EqualError(t, err, "dead letter queue not found for service: worker")
+}
+
+func TestPurgeDLQ(t *testing.T) {
+	provider, queue := dlqTestProvider()
+	defer provider.Close()
+
+	require.NoError(t, provider.Fake.SQS.Send(queue, "job"))
+
+	require.NoError(t, provider.PurgeDLQ("httpd", "worker"))
+	assert.Equal(t, 0, provider.Fake.SQS.Pending(queue))
+
+	entries, err := provider.AuditLog("httpd", time.Time{}, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "dlq.purge", entries[0].Op)
+	assert.Equal(t, map[string]string{"service": "worker"}, entries[0].Detail)
+}
+
+// dlqTestProvider seeds an app whose worker service stack has a dead letter queue
+func dlqTestProvider() (*awsfake.TestProvider, string) {
+	provider := awsfake.NewTestProvider()
+
+	provider.AddRack(awsfake.Stack{})
+
+	queue := provider.Fake.SQS.AddQueue("convox-httpd-worker-dlq")
+
+	child := provider.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name:      "convox-httpd-ServiceWorker-1ABCDEF",
+		Resources: []awsfake.Resource{{LogicalId: "WorkerDeadLetterQueue", PhysicalId: queue, Type: "AWS::SQS::Queue"}},
+	})
+
+	provider.AddApp("httpd", "2", awsfake.Stack{
+		Resources: []awsfake.Resource{{LogicalId: "ServiceWorker", PhysicalId: child, Type: "AWS::CloudFormation::Stack"}},
+	})
+
+	return provider, queue
+}
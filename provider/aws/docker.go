@@ -54,3 +54,8 @@ func (p *Provider) dockerInstance(id string) (*docker.Client, error) {
 
 	return dc, nil
 }
+
+// dockerLocal returns a client for the docker daemon on the host running the rack api
+func (p *Provider) dockerLocal() (*docker.Client, error) {
+	return p.docker(coalesces(os.Getenv("DOCKER_HOST"), "unix:///var/run/docker.sock"))
+}
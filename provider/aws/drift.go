@@ -0,0 +1,222 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/convox/rack/pkg/manifest"
+	"github.com/convox/rack/pkg/manifest1"
+	"github.com/convox/rack/pkg/structs"
+)
+
+const (
+	// ParameterDriftDiffers is a parameter whose stack value is not the one the release expects
+	ParameterDriftDiffers = "differs"
+
+	// ParameterDriftExtra is a parameter on the stack that the release template does not declare
+	ParameterDriftExtra = "extra"
+
+	// ParameterDriftMissing is a parameter the release template declares that the stack does not have
+	ParameterDriftMissing = "missing"
+)
+
+// ParameterDrift is a difference between an app stack parameter and what its active release would set
+type ParameterDrift struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// AppParameterDrift reports the parameters of an app stack that have diverged from its active release
+func (p *Provider) AppParameterDrift(app string) ([]ParameterDrift, error) {
+	log := Logger.At("AppParameterDrift").Namespace("app=%q", app).Start()
+
+	drift, err := p.appParameterDrift(app)
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	log.Logf("drift=%d", len(drift))
+
+	return drift, log.Success()
+}
+
+// appParameterDrift compares the live parameters of an app stack with the ones its active release renders
+func (p *Provider) appParameterDrift(app string) ([]ParameterDrift, error) {
+	a, err := p.AppGet(app)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Release == "" {
+		return nil, nil
+	}
+
+	r, err := p.ReleaseGet(app, a.Release)
+	if err != nil {
+		return nil, err
+	}
+
+	template, changes, err := p.releaseParameters(a, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return parameterDrift(template, changes, a.Parameters)
+}
+
+// releaseParameters renders the app template of a release and the parameters a promotion would set on it
+// nested templates are not rendered or stored as they do not change the parameters of the app stack
+func (p *Provider) releaseParameters(a *structs.App, r *structs.Release) ([]byte, map[string]string, error) {
+	switch a.Tags["Generation"] {
+	case "", "1":
+		return p.releaseParametersGeneration1(a, r)
+	case "2":
+	default:
+		return nil, nil, fmt.Errorf("unknown generation for app: %s", a.Name)
+	}
+
+	env := structs.Environment{}
+
+	if err := env.Load([]byte(r.Env)); err != nil {
+		return nil, nil, err
+	}
+
+	m, err := manifest.Load([]byte(r.Manifest), env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := formationTemplate("app", map[string]interface{}{
+		"App":      r.App,
+		"EcsExec":  p.EcsExec,
+		"Manifest": m,
+		"Password": p.Password,
+		"Release":  r,
+		"Topic":    p.CloudformationTopic,
+		"Version":  p.Version,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes, err := p.releaseChanges(m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, changes, nil
+}
+
+func (p *Provider) releaseParametersGeneration1(a *structs.App, r *structs.Release) ([]byte, map[string]string, error) {
+	settings, err := p.appResource(r.App, "Settings")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes := p.releaseChangesGeneration1(r)
+
+	// promotion caches the template it used so prefer it over rendering again
+	data, err := p.s3Get(settings, objectKey(objectKindTemplate, "", r.Id))
+	if err == nil {
+		return data, changes, nil
+	}
+	if !isNotFound(err) {
+		return nil, nil, err
+	}
+
+	m, err := manifest1.Load([]byte(r.Manifest))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tp := map[string]interface{}{
+		"App":         a,
+		"Cluster":     p.Cluster,
+		"Environment": fmt.Sprintf("https://%s.s3.amazonaws.com/%s", settings, objectKey(objectKindEnv, "", r.Id)),
+		"Manifest":    m,
+		"Region":      p.Region,
+		"Version":     p.Version,
+	}
+
+	if r.Build != "" {
+		b, err := p.BuildGet(a.Name, r.Build)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tp["Build"] = b
+	}
+
+	data, err = formationTemplate("g1/app", tp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, changes, nil
+}
+
+// parameterDrift compares actual stack parameters with the parameters of template
+// a parameter is expected to hold the value in changes, or its default when changes does not set it
+// parameters with neither are only checked for presence, as are NoEcho parameters whose values the stack masks
+func parameterDrift(template []byte, changes, actual map[string]string) ([]ParameterDrift, error) {
+	f, err := parseFormation(template)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := []ParameterDrift{}
+
+	for name, fp := range f.Parameters {
+		expected, known := changes[name]
+
+		if !known && fp.Default != nil {
+			expected, known = fmt.Sprintf("%v", fp.Default), true
+		}
+
+		value, ok := actual[name]
+
+		switch {
+		case !ok:
+			drift = append(drift, ParameterDrift{Name: name, Kind: ParameterDriftMissing, Expected: expected})
+		case fp.NoEcho || !known:
+		case value != expected:
+			drift = append(drift, ParameterDrift{Name: name, Kind: ParameterDriftDiffers, Expected: expected, Actual: value})
+		}
+	}
+
+	for name, value := range actual {
+		if _, ok := f.Parameters[name]; !ok {
+			drift = append(drift, ParameterDrift{Name: name, Kind: ParameterDriftExtra, Actual: value})
+		}
+	}
+
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Name < drift[j].Name })
+
+	return drift, nil
+}
+
+// reportParameterDrift warns about drifted app parameters before a promotion
+// with the reconcile option the expected values are added to changes so the promotion restores them
+func (p *Provider) reportParameterDrift(a *structs.App, template []byte, changes map[string]string, opts structs.ReleasePromoteOptions) error {
+	drift, err := parameterDrift(template, changes, a.Parameters)
+	if err != nil {
+		return err
+	}
+
+	reconcile := opts.Reconcile != nil && *opts.Reconcile
+
+	for _, d := range drift {
+		fmt.Printf("ns=kernel at=release.promote warning=%q app=%s param=%s kind=%s\n", "parameter drift", a.Name, d.Name, d.Kind)
+
+		// extra parameters leave the stack with the template that no longer declares them
+		if reconcile && d.Kind != ParameterDriftExtra && d.Expected != "" {
+			if _, ok := changes[d.Name]; !ok {
+				changes[d.Name] = d.Expected
+			}
+		}
+	}
+
+	return nil
+}
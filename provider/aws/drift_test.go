@@ -0,0 +1,173 @@
+package aws_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/convox/rack/pkg/manifest"
+	"github.com/convox/rack/pkg/options"
+	"github.com/convox/rack/pkg/structs"
+	"github.com/convox/rack/provider/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var driftTemplate = []byte(`{
+	"Parameters": {
+		"LogBucket": { "Type": "String", "Default": "" },
+		"LogRetention": { "Type": "String", "Default": "7" },
+		"Private": { "Type": "String", "Default": "No" },
+		"Rack": { "Type": "String", "MinLength": "1" },
+		"RedirectHttps": { "Type": "String", "Default": "Yes" },
+		"ResourcePassword": { "Type": "String", "Default": "", "NoEcho": true },
+		"WebCount": { "Type": "Number", "Default": 2 }
+	}
+}`)
+
+var driftChanges = map[string]string{
+	"LogBucket": "logs",
+	"Private":   "Yes",
+}
+
+func TestParameterDrift(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Actual map[string]string
+		Drift  []aws.ParameterDrift
+	}{
+		{
+			Name: "matching",
+			Actual: map[string]string{
+				"LogBucket":        "logs",
+				"LogRetention":     "7",
+				"Private":          "Yes",
+				"Rack":             "convox",
+				"RedirectHttps":    "Yes",
+				"ResourcePassword": "****",
+				"WebCount":         "2",
+			},
+			Drift: []aws.ParameterDrift{},
+		},
+		{
+			Name: "extra",
+			Actual: map[string]string{
+				"Legacy":           "Yes",
+				"LogBucket":        "logs",
+				"LogRetention":     "7",
+				"Private":          "Yes",
+				"Rack":             "convox",
+				"RedirectHttps":    "Yes",
+				"ResourcePassword": "****",
+				"WebCount":         "2",
+			},
+			Drift: []aws.ParameterDrift{
+				{Name: "Legacy", Kind: aws.ParameterDriftExtra, Actual: "Yes"},
+			},
+		},
+		{
+			Name: "missing",
+			Actual: map[string]string{
+				"LogBucket": "logs",
+				"Private":   "Yes",
+				"Rack":      "convox",
+				"WebCount":  "2",
+			},
+			Drift: []aws.ParameterDrift{
+				{Name: "LogRetention", Kind: aws.ParameterDriftMissing, Expected: "7"},
+				{Name: "RedirectHttps", Kind: aws.ParameterDriftMissing, Expected: "Yes"},
+				{Name: "ResourcePassword", Kind: aws.ParameterDriftMissing},
+			},
+		},
+		{
+			Name: "differing",
+			Actual: map[string]string{
+				"LogBucket":        "other",
+				"LogRetention":     "30",
+				"Private":          "Yes",
+				"Rack":             "anything",
+				"RedirectHttps":    "Yes",
+				"ResourcePassword": "****",
+				"WebCount":         "4",
+			},
+			Drift: []aws.ParameterDrift{
+				{Name: "LogBucket", Kind: aws.ParameterDriftDiffers, Expected: "logs", Actual: "other"},
+				{Name: "LogRetention", Kind: aws.ParameterDriftDiffers, Expected: "7", Actual: "30"},
+				{Name: "WebCount", Kind: aws.ParameterDriftDiffers, Expected: "2", Actual: "4"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			drift, err := aws.ParameterDriftOf(driftTemplate, driftChanges, test.Actual)
+			require.NoError(t, err)
+			assert.Equal(t, test.Drift, drift)
+		})
+	}
+}
+
+func TestParameterDriftInvalidTemplate(t *testing.T) {
+	_, err := aws.ParameterDriftOf([]byte("{"), driftChanges, map[string]string{})
+	require.Error(t, err)
+}
+
+func TestReportParameterDriftReconcile(t *testing.T) {
+	provider := StubAwsProvider()
+	defer provider.Close()
+
+	a := &structs.App{
+		Name: "httpd",
+		Parameters: map[string]string{
+			"LogBucket":        "other",
+			"LogRetention":     "30",
+			"Private":          "Yes",
+			"Rack":             "convox",
+			"ResourcePassword": "****",
+			"Unused":           "Yes",
+			"WebCount":         "2",
+		},
+	}
+
+	changes := map[string]string{"LogBucket": "logs", "Private": "Yes"}
+
+	require.NoError(t, provider.ReportParameterDrift(a, driftTemplate, changes, structs.ReleasePromoteOptions{}))
+	assert.Equal(t, map[string]string{"LogBucket": "logs", "Private": "Yes"}, changes)
+
+	require.NoError(t, provider.ReportParameterDrift(a, driftTemplate, changes, structs.ReleasePromoteOptions{Reconcile: options.Bool(true)}))
+	assert.Equal(t, map[string]string{
+		"LogBucket":     "logs",
+		"LogRetention":  "7",
+		"Private":       "Yes",
+		"RedirectHttps": "Yes",
+	}, changes)
+}
+
+func TestParameterDriftAppTemplate(t *testing.T) {
+	// formation templates are loaded relative to the repository root
+	cwd, _ := os.Getwd()
+	require.NoError(t, os.Chdir("../.."))
+	defer os.Chdir(cwd)
+
+	m, err := manifest.Load([]byte("params:\n  LogRetention: \"14\"\nservices:\n  web:\n    port: 3000\n"), map[string]string{})
+	require.NoError(t, err)
+
+	// the parameters of the app template only depend on the manifest
+	data, err := aws.FormationTemplate("app", map[string]interface{}{
+		"App":      "httpd",
+		"Manifest": m,
+		"Release":  &structs.Release{Id: "RABCDEFGHI"},
+	})
+	require.NoError(t, err)
+
+	drift, err := aws.ParameterDriftOf(data, m.Params, map[string]string{"LogRetention": "7"})
+	require.NoError(t, err)
+
+	kinds := map[string]string{}
+	for _, d := range drift {
+		kinds[d.Name] = d.Kind
+	}
+
+	assert.Equal(t, aws.ParameterDriftDiffers, kinds["LogRetention"])
+	assert.Equal(t, aws.ParameterDriftMissing, kinds["WebFormation"])
+	assert.Equal(t, aws.ParameterDriftMissing, kinds["Rack"])
+}
@@ -0,0 +1,187 @@
+package aws
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// The vendored sdk predates capacity providers, cluster configurations, deployment alarms, client tokens and ECS Exec.
+// Requests that use them are sent with ecs().NewRequest and these types, which follow the shapes of the
+// ECS json api and only hold the fields the provider reads or writes, or get the missing fields from ecsRequestField.
+
+// ecsRequestField returns a request option that sets a field of the json body the sdk builds
+func ecsRequestField(name string, value interface{}) request.Option {
+	return func(r *request.Request) {
+		r.Handlers.Build.PushBack(func(r *request.Request) {
+			if r.Error != nil {
+				return
+			}
+
+			body := map[string]interface{}{}
+
+			if err := json.NewDecoder(r.GetBody()).Decode(&body); err != nil {
+				r.Error = err
+				return
+			}
+
+			body[name] = value
+
+			data, err := json.Marshal(body)
+			if err != nil {
+				r.Error = err
+				return
+			}
+
+			r.SetBufferBody(data)
+		})
+	}
+}
+
+type ecsAutoScalingGroupProvider struct {
+	AutoScalingGroupArn          *string            `locationName:"autoScalingGroupArn" type:"string"`
+	ManagedScaling               *ecsManagedScaling `locationName:"managedScaling" type:"structure"`
+	ManagedTerminationProtection *string            `locationName:"managedTerminationProtection" type:"string"`
+}
+
+type ecsCapacityProvider struct {
+	AutoScalingGroupProvider *ecsAutoScalingGroupProvider `locationName:"autoScalingGroupProvider" type:"structure"`
+	CapacityProviderArn      *string                      `locationName:"capacityProviderArn" type:"string"`
+	Name                     *string                      `locationName:"name" type:"string"`
+	Status                   *string                      `locationName:"status" type:"string"`
+	UpdateStatus             *string                      `locationName:"updateStatus" type:"string"`
+	UpdateStatusReason       *string                      `locationName:"updateStatusReason" type:"string"`
+}
+
+type ecsCapacityProviderStrategyItem struct {
+	Base             *int64  `locationName:"base" type:"integer"`
+	CapacityProvider *string `locationName:"capacityProvider" type:"string"`
+	Weight           *int64  `locationName:"weight" type:"integer"`
+}
+
+type ecsCluster struct {
+	CapacityProviders               []*string                          `locationName:"capacityProviders" type:"list"`
+	ClusterArn                      *string                            `locationName:"clusterArn" type:"string"`
+	Configuration                   *ecsClusterConfiguration           `locationName:"configuration" type:"structure"`
+	DefaultCapacityProviderStrategy []*ecsCapacityProviderStrategyItem `locationName:"defaultCapacityProviderStrategy" type:"list"`
+	Settings                        []*ecs.ClusterSetting              `locationName:"settings" type:"list"`
+}
+
+type ecsClusterConfiguration struct {
+	ExecuteCommandConfiguration *ecsExecuteCommandConfiguration `locationName:"executeCommandConfiguration" type:"structure"`
+}
+
+// ecsClusterOutput is the response of UpdateCluster and PutClusterCapacityProviders
+type ecsClusterOutput struct {
+	Cluster *ecsCluster `locationName:"cluster" type:"structure"`
+}
+
+type ecsCreateCapacityProviderInput struct {
+	AutoScalingGroupProvider *ecsAutoScalingGroupProvider `locationName:"autoScalingGroupProvider" type:"structure"`
+	Name                     *string                      `locationName:"name" type:"string"`
+	Tags                     []*ecs.Tag                   `locationName:"tags" type:"list"`
+}
+
+type ecsCreateCapacityProviderOutput struct {
+	CapacityProvider *ecsCapacityProvider `locationName:"capacityProvider" type:"structure"`
+}
+
+type ecsDeploymentAlarms struct {
+	AlarmNames []*string `locationName:"alarmNames" type:"list"`
+	Enable     *bool     `locationName:"enable" type:"boolean"`
+	Rollback   *bool     `locationName:"rollback" type:"boolean"`
+}
+
+type ecsDeploymentConfiguration struct {
+	Alarms                *ecsDeploymentAlarms `locationName:"alarms" type:"structure"`
+	MaximumPercent        *int64               `locationName:"maximumPercent" type:"integer"`
+	MinimumHealthyPercent *int64               `locationName:"minimumHealthyPercent" type:"integer"`
+}
+
+type ecsDescribeCapacityProvidersInput struct {
+	CapacityProviders []*string `locationName:"capacityProviders" type:"list"`
+}
+
+type ecsDescribeCapacityProvidersOutput struct {
+	CapacityProviders []*ecsCapacityProvider `locationName:"capacityProviders" type:"list"`
+	Failures          []*ecs.Failure         `locationName:"failures" type:"list"`
+}
+
+type ecsDescribeClustersOutput struct {
+	Clusters []*ecsCluster `locationName:"clusters" type:"list"`
+}
+
+type ecsDescribeServicesOutput struct {
+	Services []*ecsService `locationName:"services" type:"list"`
+}
+
+type ecsDescribeTasksOutput struct {
+	Tasks []*ecsTask `locationName:"tasks" type:"list"`
+}
+
+type ecsExecuteCommandConfiguration struct {
+	LogConfiguration *ecsExecuteCommandLogConfiguration `locationName:"logConfiguration" type:"structure"`
+	Logging          *string                            `locationName:"logging" type:"string"`
+}
+
+type ecsExecuteCommandInput struct {
+	Cluster     *string `locationName:"cluster" type:"string"`
+	Command     *string `locationName:"command" type:"string"`
+	Container   *string `locationName:"container" type:"string"`
+	Interactive *bool   `locationName:"interactive" type:"boolean"`
+	Task        *string `locationName:"task" type:"string"`
+}
+
+type ecsExecuteCommandLogConfiguration struct {
+	CloudWatchEncryptionEnabled *bool   `locationName:"cloudWatchEncryptionEnabled" type:"boolean"`
+	CloudWatchLogGroupName      *string `locationName:"cloudWatchLogGroupName" type:"string"`
+	S3BucketName                *string `locationName:"s3BucketName" type:"string"`
+	S3EncryptionEnabled         *bool   `locationName:"s3EncryptionEnabled" type:"boolean"`
+	S3KeyPrefix                 *string `locationName:"s3KeyPrefix" type:"string"`
+}
+
+type ecsExecuteCommandOutput struct {
+	Session *ecsSession `locationName:"session" type:"structure"`
+}
+
+type ecsManagedScaling struct {
+	MaximumScalingStepSize *int64  `locationName:"maximumScalingStepSize" type:"integer"`
+	MinimumScalingStepSize *int64  `locationName:"minimumScalingStepSize" type:"integer"`
+	Status                 *string `locationName:"status" type:"string"`
+	TargetCapacity         *int64  `locationName:"targetCapacity" type:"integer"`
+}
+
+type ecsPutClusterCapacityProvidersInput struct {
+	CapacityProviders               []*string                          `locationName:"capacityProviders" type:"list"`
+	Cluster                         *string                            `locationName:"cluster" type:"string"`
+	DefaultCapacityProviderStrategy []*ecsCapacityProviderStrategyItem `locationName:"defaultCapacityProviderStrategy" type:"list"`
+}
+
+type ecsService struct {
+	DeploymentConfiguration *ecsDeploymentConfiguration `locationName:"deploymentConfiguration" type:"structure"`
+	ServiceArn              *string                     `locationName:"serviceArn" type:"string"`
+}
+
+type ecsSession struct {
+	SessionId  *string `locationName:"sessionId" type:"string"`
+	StreamUrl  *string `locationName:"streamUrl" type:"string"`
+	TokenValue *string `locationName:"tokenValue" type:"string"`
+}
+
+type ecsTask struct {
+	EnableExecuteCommand *bool   `locationName:"enableExecuteCommand" type:"boolean"`
+	TaskArn              *string `locationName:"taskArn" type:"string"`
+}
+
+type ecsUpdateClusterInput struct {
+	Cluster       *string                  `locationName:"cluster" type:"string"`
+	Configuration *ecsClusterConfiguration `locationName:"configuration" type:"structure"`
+	Settings      []*ecs.ClusterSetting    `locationName:"settings" type:"list"`
+}
+
+type ecsUpdateServiceInput struct {
+	Cluster                 *string                     `locationName:"cluster" type:"string"`
+	DeploymentConfiguration *ecsDeploymentConfiguration `locationName:"deploymentConfiguration" type:"structure"`
+	Service                 *string                     `locationName:"service" type:"string"`
+}
@@ -0,0 +1,191 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/efs"
+)
+
+var (
+	efsPollInterval = 5 * time.Second
+	efsWaitTimeout  = 10 * time.Minute
+)
+
+// EFSOptions configures a new EFS file system
+type EFSOptions struct {
+	Encrypted             bool
+	KMSKeyId              string
+	PerformanceMode       string
+	ProvisionedThroughput float64
+	ThroughputMode        string
+}
+
+// CreateEFSFileSystem creates an EFS file system for an app and mounts it in each of the rack's private subnets
+// behind the rack's instance security group, a file system that can not be fully mounted is deleted again
+func (p *Provider) CreateEFSFileSystem(app string, opts EFSOptions) (string, error) {
+	log := Logger.At("CreateEFSFileSystem").Namespace("app=%q", app).Start()
+
+	subnets := []string{}
+
+	for _, subnet := range strings.Split(p.SubnetsPrivate, ",") {
+		if subnet = strings.TrimSpace(subnet); subnet != "" {
+			subnets = append(subnets, subnet)
+		}
+	}
+
+	if len(subnets) == 0 {
+		return "", log.Error(fmt.Errorf("rack %s has no private subnets to mount a file system in", p.Rack))
+	}
+
+	if p.SecurityGroup == "" {
+		return "", log.Error(fmt.Errorf("rack %s has no instance security group to mount a file system with", p.Rack))
+	}
+
+	req := &efs.CreateFileSystemInput{
+		CreationToken: aws.String(fmt.Sprintf("%s-%s", p.Rack, app)),
+		Encrypted:     aws.Bool(opts.Encrypted),
+		Tags: []*efs.Tag{
+			{Key: aws.String("Name"), Value: aws.String(fmt.Sprintf("%s-%s", p.Rack, app))},
+			{Key: aws.String("Rack"), Value: aws.String(p.Rack)},
+			{Key: aws.String("System"), Value: aws.String("convox")},
+			{Key: aws.String("App"), Value: aws.String(app)},
+		},
+	}
+
+	if opts.KMSKeyId != "" {
+		req.KmsKeyId = aws.String(opts.KMSKeyId)
+	}
+
+	if opts.PerformanceMode != "" {
+		req.PerformanceMode = aws.String(opts.PerformanceMode)
+	}
+
+	if opts.ThroughputMode != "" {
+		req.ThroughputMode = aws.String(opts.ThroughputMode)
+	}
+
+	if opts.ProvisionedThroughput > 0 {
+		req.ProvisionedThroughputInMibps = aws.Float64(opts.ProvisionedThroughput)
+	}
+
+	fs, err := p.efs().CreateFileSystem(req)
+	if err != nil {
+		return "", log.Error(err)
+	}
+
+	id := *fs.FileSystemId
+
+	if err := p.mountEFSFileSystem(id, subnets); err != nil {
+		if derr := p.DeleteEFSFileSystem(id); derr != nil {
+			return "", log.Error(fmt.Errorf("%s (could not delete file system %s: %s)", err, id, derr))
+		}
+
+		return "", log.Error(err)
+	}
+
+	return id, log.Success()
+}
+
+func (p *Provider) mountEFSFileSystem(id string, subnets []string) error {
+	if err := p.waitForEFSFileSystem(id); err != nil {
+		return err
+	}
+
+	for _, subnet := range subnets {
+		_, err := p.efs().CreateMountTarget(&efs.CreateMountTargetInput{
+			FileSystemId:   aws.String(id),
+			SecurityGroups: []*string{aws.String(p.SecurityGroup)},
+			SubnetId:       aws.String(subnet),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteEFSFileSystem removes the mount targets for an EFS file system and then deletes it
+func (p *Provider) DeleteEFSFileSystem(id string) error {
+	log := Logger.At("DeleteEFSFileSystem").Namespace("id=%q", id).Start()
+
+	res, err := p.efs().DescribeMountTargets(&efs.DescribeMountTargetsInput{
+		FileSystemId: aws.String(id),
+	})
+	if err != nil {
+		return log.Error(err)
+	}
+
+	for _, mt := range res.MountTargets {
+		_, err := p.efs().DeleteMountTarget(&efs.DeleteMountTargetInput{
+			MountTargetId: mt.MountTargetId,
+		})
+		if err != nil {
+			return log.Error(err)
+		}
+	}
+
+	if len(res.MountTargets) > 0 {
+		if err := p.waitForEFSMountTargetsDeleted(id); err != nil {
+			return log.Error(err)
+		}
+	}
+
+	_, err = p.efs().DeleteFileSystem(&efs.DeleteFileSystemInput{
+		FileSystemId: aws.String(id),
+	})
+	if err != nil {
+		return log.Error(err)
+	}
+
+	return log.Success()
+}
+
+func (p *Provider) waitForEFSFileSystem(id string) error {
+	done := time.Now().Add(efsWaitTimeout)
+
+	for {
+		res, err := p.efs().DescribeFileSystems(&efs.DescribeFileSystemsInput{
+			FileSystemId: aws.String(id),
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(res.FileSystems) == 1 && cs(res.FileSystems[0].LifeCycleState, "") == efs.LifeCycleStateAvailable {
+			return nil
+		}
+
+		if time.Now().After(done) {
+			return fmt.Errorf("timeout waiting for file system %s to become available", id)
+		}
+
+		time.Sleep(efsPollInterval)
+	}
+}
+
+func (p *Provider) waitForEFSMountTargetsDeleted(id string) error {
+	done := time.Now().Add(efsWaitTimeout)
+
+	for {
+		res, err := p.efs().DescribeMountTargets(&efs.DescribeMountTargetsInput{
+			FileSystemId: aws.String(id),
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(res.MountTargets) == 0 {
+			return nil
+		}
+
+		if time.Now().After(done) {
+			return fmt.Errorf("timeout waiting for mount targets of file system %s to be deleted", id)
+		}
+
+		time.Sleep(efsPollInterval)
+	}
+}
@@ -0,0 +1,285 @@
+package aws_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateEFSFileSystem(t *testing.T) {
+	aws.SetEFSPollInterval(0)
+	defer aws.SetEFSPollInterval(5 * time.Second)
+
+	provider := StubAwsProvider(
+		cycleEFSCreateFileSystem,
+		cycleEFSDescribeFileSystemsCreating,
+		cycleEFSDescribeFileSystemsAvailable,
+		cycleEFSCreateMountTarget1,
+		cycleEFSCreateMountTarget2,
+	)
+	defer provider.Close()
+
+	provider.SecurityGroup = "sg-1"
+	provider.SubnetsPrivate = "subnet-1,subnet-2"
+
+	id, err := provider.CreateEFSFileSystem("httpd", aws.EFSOptions{
+		Encrypted:             true,
+		KMSKeyId:              "key-1",
+		ProvisionedThroughput: 10,
+		ThroughputMode:        "provisioned",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "fs-1", id)
+}
+
+func TestCreateEFSFileSystemMountFailed(t *testing.T) {
+	aws.SetEFSPollInterval(0)
+	defer aws.SetEFSPollInterval(5 * time.Second)
+
+	provider := StubAwsProvider(
+		cycleEFSCreateFileSystem,
+		cycleEFSDescribeFileSystemsAvailable,
+		cycleEFSCreateMountTarget1,
+		cycleEFSCreateMountTarget2Failed,
+		cycleEFSDescribeMountTargetsFirst,
+		cycleEFSDeleteMountTarget1,
+		cycleEFSDescribeMountTargetsEmpty,
+		cycleEFSDeleteFileSystem,
+	)
+	defer provider.Close()
+
+	provider.SecurityGroup = "sg-1"
+	provider.SubnetsPrivate = "subnet-1,subnet-2"
+
+	_, err := provider.CreateEFSFileSystem("httpd", aws.EFSOptions{
+		Encrypted:             true,
+		KMSKeyId:              "key-1",
+		ProvisionedThroughput: 10,
+		ThroughputMode:        "provisioned",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "subnet-2 does not exist")
+}
+
+func TestCreateEFSFileSystemInvalidNetwork(t *testing.T) {
+	provider := StubAwsProvider()
+	defer provider.Close()
+
+	provider.SecurityGroup = "sg-1"
+	provider.Subnets = "subnet-public-1,subnet-public-2"
+
+	_, err := provider.CreateEFSFileSystem("httpd", aws.EFSOptions{})
+	require.EqualError(t, err, "rack convox has no private subnets to mount a file system in")
+
+	provider.SecurityGroup = ""
+	provider.SubnetsPrivate = "subnet-1,subnet-2"
+
+	_, err = provider.CreateEFSFileSystem("httpd", aws.EFSOptions{})
+	require.EqualError(t, err, "rack convox has no instance security group to mount a file system with")
+}
+
+func TestDeleteEFSFileSystem(t *testing.T) {
+	aws.SetEFSPollInterval(0)
+	defer aws.SetEFSPollInterval(5 * time.Second)
+
+	provider := StubAwsProvider(
+		cycleEFSDescribeMountTargets,
+		cycleEFSDeleteMountTarget1,
+		cycleEFSDeleteMountTarget2,
+		cycleEFSDescribeMountTargetsEmpty,
+		cycleEFSDeleteFileSystem,
+	)
+	defer provider.Close()
+
+	err := provider.DeleteEFSFileSystem("fs-1")
+	require.NoError(t, err)
+}
+
+var cycleEFSCreateFileSystem = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/2015-02-01/file-systems",
+		Body: `{
+			"CreationToken": "convox-httpd",
+			"Encrypted": true,
+			"KmsKeyId": "key-1",
+			"ProvisionedThroughputInMibps": 10,
+			"Tags": [
+				{ "Key": "Name", "Value": "convox-httpd" },
+				{ "Key": "Rack", "Value": "convox" },
+				{ "Key": "System", "Value": "convox" },
+				{ "Key": "App", "Value": "httpd" }
+			],
+			"ThroughputMode": "provisioned"
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 201,
+		Body: `{
+			"CreationToken": "convox-httpd",
+			"FileSystemId": "fs-1",
+			"LifeCycleState": "creating"
+		}`,
+	},
+}
+
+var cycleEFSDescribeFileSystemsCreating = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "GET",
+		RequestURI: "/2015-02-01/file-systems?FileSystemId=fs-1",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"FileSystems": [
+				{ "FileSystemId": "fs-1", "LifeCycleState": "creating" }
+			]
+		}`,
+	},
+}
+
+var cycleEFSDescribeFileSystemsAvailable = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "GET",
+		RequestURI: "/2015-02-01/file-systems?FileSystemId=fs-1",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"FileSystems": [
+				{ "FileSystemId": "fs-1", "LifeCycleState": "available" }
+			]
+		}`,
+	},
+}
+
+var cycleEFSCreateMountTarget1 = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/2015-02-01/mount-targets",
+		Body: `{
+			"FileSystemId": "fs-1",
+			"SecurityGroups": ["sg-1"],
+			"SubnetId": "subnet-1"
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"FileSystemId": "fs-1",
+			"MountTargetId": "fsmt-1",
+			"SubnetId": "subnet-1"
+		}`,
+	},
+}
+
+var cycleEFSCreateMountTarget2 = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/2015-02-01/mount-targets",
+		Body: `{
+			"FileSystemId": "fs-1",
+			"SecurityGroups": ["sg-1"],
+			"SubnetId": "subnet-2"
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"FileSystemId": "fs-1",
+			"MountTargetId": "fsmt-2",
+			"SubnetId": "subnet-2"
+		}`,
+	},
+}
+
+var cycleEFSCreateMountTarget2Failed = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/2015-02-01/mount-targets",
+		Body: `{
+			"FileSystemId": "fs-1",
+			"SecurityGroups": ["sg-1"],
+			"SubnetId": "subnet-2"
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 400,
+		Body: `{
+			"ErrorCode": "SubnetNotFound",
+			"Message": "subnet-2 does not exist"
+		}`,
+	},
+}
+
+var cycleEFSDescribeMountTargetsFirst = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "GET",
+		RequestURI: "/2015-02-01/mount-targets?FileSystemId=fs-1",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"MountTargets": [
+				{ "FileSystemId": "fs-1", "MountTargetId": "fsmt-1", "SubnetId": "subnet-1" }
+			]
+		}`,
+	},
+}
+
+var cycleEFSDescribeMountTargets = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "GET",
+		RequestURI: "/2015-02-01/mount-targets?FileSystemId=fs-1",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"MountTargets": [
+				{ "FileSystemId": "fs-1", "MountTargetId": "fsmt-1", "SubnetId": "subnet-1" },
+				{ "FileSystemId": "fs-1", "MountTargetId": "fsmt-2", "SubnetId": "subnet-2" }
+			]
+		}`,
+	},
+}
+
+var cycleEFSDeleteMountTarget1 = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/2015-02-01/mount-targets/fsmt-1",
+	},
+	Response: awsutil.Response{
+		StatusCode: 204,
+	},
+}
+
+var cycleEFSDeleteMountTarget2 = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/2015-02-01/mount-targets/fsmt-2",
+	},
+	Response: awsutil.Response{
+		StatusCode: 204,
+	},
+}
+
+var cycleEFSDescribeMountTargetsEmpty = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "GET",
+		RequestURI: "/2015-02-01/mount-targets?FileSystemId=fs-1",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{ "MountTargets": [] }`,
+	},
+}
+
+var cycleEFSDeleteFileSystem = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/2015-02-01/file-systems/fs-1",
+	},
+	Response: awsutil.Response{
+		StatusCode: 204,
+	},
+}
@@ -0,0 +1,262 @@
+package aws
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/convox/rack/pkg/helpers"
+	"github.com/convox/rack/pkg/manifest"
+	"github.com/convox/rack/pkg/options"
+	"github.com/convox/rack/pkg/structs"
+)
+
+const (
+	// envSetAttempts is how many times envSet reads and writes the environment before giving up on conflicts
+	envSetAttempts = 5
+
+	// envSizeLimit is the most environment ecs accepts in a container definition
+	envSizeLimit = 8192
+)
+
+var (
+	envNameValidator    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	envSetRetryInterval = 200 * time.Millisecond
+)
+
+// envSet changes and removes environment variables of an app and creates a release with the result
+// the environment is read, modified and written back under the revision of the snapshot it was read from,
+// writers that lose a race to another writer read the new snapshot and apply their changes again
+func (p *Provider) envSet(app string, changes map[string]string, deletes []string) (*structs.Release, error) {
+	log := Logger.At("envSet").Namespace("app=%s", app).Start()
+
+	for name := range changes {
+		if err := validateEnvName(name); err != nil {
+			return nil, log.Error(err)
+		}
+	}
+
+	for attempt := 1; attempt <= envSetAttempts; attempt++ {
+		r, err := p.envSetAttempt(app, changes, deletes)
+		if err == nil {
+			return r, log.Successf("release=%s attempts=%d", r.Id, attempt)
+		}
+		if _, ok := err.(errEnvConflict); !ok {
+			return nil, log.Error(err)
+		}
+
+		log.Logf("conflict=true attempt=%d", attempt)
+
+		time.Sleep(envSetRetryInterval + time.Duration(jitter(int64(envSetRetryInterval))))
+	}
+
+	return nil, log.Error(fmt.Errorf("environment of app %s changed during %d attempts to update it, try again", app, envSetAttempts))
+}
+
+type errEnvConflict struct{}
+
+func (errEnvConflict) Error() string {
+	return "environment changed since it was read"
+}
+
+func (p *Provider) envSetAttempt(app string, changes map[string]string, deletes []string) (*structs.Release, error) {
+	cr, err := helpers.ReleaseLatest(p, app)
+	if err != nil {
+		return nil, err
+	}
+
+	base := ""
+	mdata := ""
+
+	if cr != nil {
+		base = cr.Env
+		mdata = cr.Manifest
+	}
+
+	env := structs.Environment{}
+
+	if err := env.Load([]byte(base)); err != nil {
+		return nil, err
+	}
+
+	for _, name := range deletes {
+		delete(env, name)
+	}
+
+	for name, value := range changes {
+		env[name] = value
+	}
+
+	if err := validateEnvSize(env, envManifest(mdata, env)); err != nil {
+		return nil, err
+	}
+
+	next := env.String()
+
+	if err := p.envClaim(app, envRevision(base), envRevision(next)); err != nil {
+		return nil, err
+	}
+
+	r, err := p.ReleaseCreate(app, structs.ReleaseCreateOptions{Env: options.String(next)})
+	if err != nil {
+		// hand the revision back so the next writer is compared against the snapshot that is still current
+		if rerr := p.envRecord(app, envRevision(base)); rerr != nil {
+			return nil, fmt.Errorf("%s (revision not restored: %s)", err, rerr)
+		}
+
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// envClaim moves the stored environment revision of an app from expected to next
+// apps whose environment has never been written through a revision have nothing to compare against and always succeed
+func (p *Provider) envClaim(app, expected, next string) error {
+	err := p.dynamoUpdateConditional(p.DynamoReleases, envRevisionKey(app), map[string]*dynamodb.AttributeValue{
+		"revision": {S: aws.String(next)},
+	}, "attribute_not_exists(#revision) OR #revision = :expected", map[string]*dynamodb.AttributeValue{
+		":expected": {S: aws.String(expected)},
+	})
+	if dynamoConditionFailed(err) {
+		return errEnvConflict{}
+	}
+
+	return err
+}
+
+// envRecord stores the revision of the environment an app was just released with
+func (p *Provider) envRecord(app, revision string) error {
+	return p.dynamoUpdateConditional(p.DynamoReleases, envRevisionKey(app), map[string]*dynamodb.AttributeValue{
+		"revision": {S: aws.String(revision)},
+	}, "", nil)
+}
+
+// envRevisionKey is the key of the item holding the environment revision of an app in the releases table
+// the item has no app attribute so it stays out of the app.created index that releases are listed from
+func envRevisionKey(app string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id": {S: aws.String(fmt.Sprintf("env/%s", app))},
+	}
+}
+
+// envRevision returns the revision of an environment snapshot
+func envRevision(env string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(env)))
+}
+
+// envManifest returns the manifest of a release, nil for releases without one or with a manifest from before generation 2
+func envManifest(data string, env structs.Environment) *manifest.Manifest {
+	if data == "" {
+		return nil
+	}
+
+	m, err := manifest.Load([]byte(data), env)
+	if err != nil {
+		return nil
+	}
+
+	return m
+}
+
+// validateEnvName returns an error if name is not a posix environment variable name or is set by the platform
+func validateEnvName(name string) error {
+	if !envNameValidator.MatchString(name) {
+		return fmt.Errorf("environment variable name %q invalid, must contain only letters, digits and underscores and not start with a digit", name)
+	}
+
+	if containsString(manifest.ReservedEnvironment, name) {
+		return fmt.Errorf("environment variable %s is reserved", name)
+	}
+
+	return nil
+}
+
+// validateEnvSize returns an error naming the largest variables when the environment a service receives is over the ecs limit
+// services receive the variables their manifest declares or all of them for *, without a manifest the whole environment is checked
+func validateEnvSize(env structs.Environment, m *manifest.Manifest) error {
+	if m == nil {
+		return envSizeCheck("app", env)
+	}
+
+	for _, s := range m.Services {
+		senv := structs.Environment{}
+
+		for _, e := range s.Environment {
+			name := strings.SplitN(e, "=", 2)[0]
+
+			if name == "*" {
+				for k, v := range env {
+					senv[k] = v
+				}
+				continue
+			}
+
+			if v, ok := env[name]; ok {
+				senv[name] = v
+			}
+		}
+
+		if err := envSizeCheck(fmt.Sprintf("service %s", s.Name), senv); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func envSizeCheck(target string, env structs.Environment) error {
+	size := envSize(env)
+
+	if size <= envSizeLimit {
+		return nil
+	}
+
+	names := []string{}
+
+	for name := range env {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		si, sj := envVarSize(names[i], env[names[i]]), envVarSize(names[j], env[names[j]])
+
+		if si == sj {
+			return names[i] < names[j]
+		}
+
+		return si > sj
+	})
+
+	if len(names) > 3 {
+		names = names[:3]
+	}
+
+	largest := []string{}
+
+	for _, name := range names {
+		largest = append(largest, fmt.Sprintf("%s (%d bytes)", name, envVarSize(name, env[name])))
+	}
+
+	return fmt.Errorf("environment for %s is %d bytes, ecs accepts at most %d, largest variables: %s", target, size, envSizeLimit, strings.Join(largest, ", "))
+}
+
+// envSize returns the bytes an environment takes up as NAME=value pairs
+func envSize(env structs.Environment) int {
+	size := 0
+
+	for name, value := range env {
+		size += envVarSize(name, value)
+	}
+
+	return size
+}
+
+func envVarSize(name, value string) int {
+	return len(name) + len(value) + 1
+}
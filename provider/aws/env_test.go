@@ -0,0 +1,175 @@
+package aws_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/convox/rack/pkg/manifest"
+	"github.com/convox/rack/pkg/structs"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	aws.SetEnvSetRetryInterval(time.Millisecond)
+}
+
+func TestEnvSet(t *testing.T) {
+	provider := envTestProvider(t)
+	defer provider.Close()
+
+	r, err := provider.EnvSet("httpd", map[string]string{"BAZ": "qux", "FOO": "baz"}, []string{"OLD"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "BAZ=qux\nFOO=baz", r.Env)
+	assert.Equal(t, "env add:BAZ change:FOO remove:OLD", r.Description)
+
+	item, ok := provider.Fake.DynamoDB.Item("convox-releases", "env/httpd")
+	require.True(t, ok)
+	assert.Equal(t, aws.EnvRevision(r.Env), awssdk.StringValue(item["revision"].S))
+	assert.Nil(t, item["app"])
+}
+
+func TestEnvSetConflictRetry(t *testing.T) {
+	provider := envTestProvider(t)
+	defer provider.Close()
+
+	provider.Fake.DynamoDB.FailConditions(2)
+
+	r, err := provider.EnvSet("httpd", map[string]string{"BAZ": "qux"}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "BAZ=qux\nFOO=bar\nOLD=value", r.Env)
+
+	claims := 0
+
+	for _, u := range provider.Fake.DynamoDB.Updates() {
+		if u.ConditionExpression != nil {
+			claims++
+		}
+	}
+
+	assert.Equal(t, 3, claims)
+}
+
+func TestEnvSetConflictGiveUp(t *testing.T) {
+	provider := envTestProvider(t)
+	defer provider.Close()
+
+	provider.Fake.DynamoDB.FailConditions(5)
+
+	_, err := provider.EnvSet("httpd", map[string]string{"BAZ": "qux"}, nil)
+	require.EqualError(t, err, "environment of app httpd changed during 5 attempts to update it, try again")
+
+	_, ok := provider.Fake.DynamoDB.Item("convox-releases", "env/httpd")
+	assert.False(t, ok)
+}
+
+func TestEnvSetStaleRevision(t *testing.T) {
+	provider := envTestProvider(t)
+	defer provider.Close()
+
+	// another writer released an environment this one has not read yet
+	provider.Fake.DynamoDB.PutItem("convox-releases", map[string]*dynamodb.AttributeValue{
+		"id":       {S: awssdk.String("env/httpd")},
+		"revision": {S: awssdk.String(aws.EnvRevision("FOO=other"))},
+	})
+
+	_, err := provider.EnvSet("httpd", map[string]string{"BAZ": "qux"}, nil)
+	require.EqualError(t, err, "environment of app httpd changed during 5 attempts to update it, try again")
+}
+
+func TestEnvSetInvalid(t *testing.T) {
+	provider := envTestProvider(t)
+	defer provider.Close()
+
+	_, err := provider.EnvSet("httpd", map[string]string{"1FOO": "bar"}, nil)
+	require.EqualError(t, err, `environment variable name "1FOO" invalid, must contain only letters, digits and underscores and not start with a digit`)
+
+	_, err = provider.EnvSet("httpd", map[string]string{"PORT": "3000"}, nil)
+	require.EqualError(t, err, "environment variable PORT is reserved")
+
+	_, err = provider.EnvSet("httpd", map[string]string{"BIG": strings.Repeat("x", 8192)}, nil)
+	require.EqualError(t, err, "environment for app is 8212 bytes, ecs accepts at most 8192, largest variables: BIG (8196 bytes), OLD (9 bytes), FOO (7 bytes)")
+
+	assert.Empty(t, provider.Fake.DynamoDB.Updates())
+}
+
+func TestValidateEnvName(t *testing.T) {
+	for _, name := range []string{"FOO", "_FOO", "foo_bar", "F00"} {
+		assert.NoError(t, aws.ValidateEnvName(name), name)
+	}
+
+	for _, name := range []string{"", "1FOO", "FOO-BAR", "FOO BAR", "FOO=BAR", "APP", "AWS_REGION"} {
+		assert.Error(t, aws.ValidateEnvName(name), name)
+	}
+}
+
+func TestValidateEnvSize(t *testing.T) {
+	assert.Equal(t, 0, aws.EnvSize(structs.Environment{}))
+	assert.Equal(t, 13, aws.EnvSize(structs.Environment{"FOO": "bar", "BAZ": "qu"}))
+
+	env := structs.Environment{
+		"LARGE":  strings.Repeat("x", 5000),
+		"MEDIUM": strings.Repeat("x", 3000),
+		"SMALL":  "x",
+		"OTHER":  strings.Repeat("x", 4000),
+	}
+
+	m, err := manifest.Load([]byte("services:\n  web:\n    environment:\n      - LARGE\n      - SMALL\n  worker:\n    environment:\n      - LARGE\n      - MEDIUM\n      - SMALL=default\n"), env)
+	require.NoError(t, err)
+
+	assert.NoError(t, aws.ValidateEnvSize(env, m))
+
+	env["MEDIUM"] = strings.Repeat("x", 3200)
+
+	err = aws.ValidateEnvSize(env, m)
+	require.EqualError(t, err, "environment for service worker is 8220 bytes, ecs accepts at most 8192, largest variables: LARGE (5006 bytes), MEDIUM (3207 bytes), SMALL (7 bytes)")
+
+	delete(env, "MEDIUM")
+
+	assert.NoError(t, aws.ValidateEnvSize(env, m))
+
+	m, err = manifest.Load([]byte("services:\n  web:\n    environment:\n      - \"*\"\n"), env)
+	require.NoError(t, err)
+
+	err = aws.ValidateEnvSize(env, m)
+	require.EqualError(t, err, "environment for service web is 9019 bytes, ecs accepts at most 8192, largest variables: LARGE (5006 bytes), OTHER (4006 bytes), SMALL (7 bytes)")
+
+	err = aws.ValidateEnvSize(env, nil)
+	require.EqualError(t, err, "environment for app is 9019 bytes, ecs accepts at most 8192, largest variables: LARGE (5006 bytes), OTHER (4006 bytes), SMALL (7 bytes)")
+}
+
+func envTestProvider(t *testing.T) *awsfake.TestProvider {
+	provider := awsfake.NewTestProvider()
+
+	provider.AddRack(awsfake.Stack{
+		Resources: []awsfake.Resource{{LogicalId: "EncryptionKey", PhysicalId: ""}},
+	})
+
+	provider.AddApp("httpd", "2", awsfake.Stack{
+		Resources: []awsfake.Resource{{LogicalId: "Settings", PhysicalId: "convox-httpd-settings"}},
+	})
+
+	provider.Fake.S3.PutObject("convox-httpd-settings", "releases/R1/env", []byte("FOO=bar\nOLD=value"), nil)
+
+	d := dynamodb.New(session.New(), provider.Fake.Config())
+
+	_, err := d.PutItem(&dynamodb.PutItemInput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":      {S: awssdk.String("R1")},
+			"app":     {S: awssdk.String("httpd")},
+			"created": {S: awssdk.String("20200101.120000.000000000")},
+		},
+		TableName: awssdk.String("convox-releases"),
+	})
+	require.NoError(t, err)
+
+	return provider
+}
@@ -1,9 +1,14 @@
 package aws
 
 import (
+	stderrors "errors"
+	"fmt"
 	"runtime"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/convox/logger"
 	"github.com/pkg/errors"
 )
 
@@ -20,10 +25,158 @@ func (e errorWithCode) Code() int {
 	return e.code
 }
 
+func (e errorWithCode) Unwrap() error {
+	return e.error
+}
+
 func errorNotFound(s string) error {
 	return errorWithCode{code: 404, error: errors.New(s)}
 }
 
+var (
+	// ErrNotBlueGreen is returned when a service does not use the blue-green deployment strategy
+	ErrNotBlueGreen = stderrors.New("service does not use blue-green deployment")
+
+	// ErrOutputNotFound is returned when an app stack has no output with the requested key
+	ErrOutputNotFound = stderrors.New("output not found")
+
+	// ErrResourceNotFound is returned when a stack has no resource with the requested logical id
+	ErrResourceNotFound = stderrors.New("resource not found")
+
+	// ErrServiceNotFound is returned when an app stack does not declare the requested service
+	ErrServiceNotFound = stderrors.New("service not found")
+
+	// ErrServiceNotReady is returned when an app stack declares the requested service but its ecs service
+	// has not been created yet, e.g. while the stack is still creating it
+	ErrServiceNotReady = stderrors.New("service not ready")
+)
+
+// errorNotFoundOf returns a not found error for name that errors.Is matches against the sentinel err
+func errorNotFoundOf(err error, name string) error {
+	return errorWithCode{code: 404, error: fmt.Errorf("%w: %s", err, name)}
+}
+
+// awsErrorInfo describes an error returned by an aws client
+type awsErrorInfo struct {
+	Code       string
+	Message    string
+	StatusCode int
+	RequestID  string
+	Retryable  bool
+	Throttle   bool
+}
+
+// awsNotFoundCodes are the error codes aws services use for a missing resource
+var awsNotFoundCodes = map[string]bool{
+	"ClusterNotFoundException":  true,
+	"NoSuchBucket":              true,
+	"NoSuchEntity":              true,
+	"NoSuchKey":                 true,
+	"NotFound":                  true,
+	"ResourceNotFoundException": true,
+	"ServiceNotFoundException":  true,
+}
+
+// awsAccessDeniedCodes are the error codes aws services use for a missing permission
+var awsAccessDeniedCodes = map[string]bool{
+	"AccessDenied":          true,
+	"AccessDeniedException": true,
+	"UnauthorizedOperation": true,
+}
+
+// awsConflictCodes are the error codes aws services use when a resource is already present or changing
+var awsConflictCodes = map[string]bool{
+	"AlreadyExistsException":          true,
+	"ConditionalCheckFailedException": true,
+	"EntityAlreadyExists":             true,
+	"ResourceInUseException":          true,
+}
+
+// classifyAWSError returns what is known about the aws error err wraps, or nil if it does not wrap one
+func classifyAWSError(err error) *awsErrorInfo {
+	ae := unwrapAWSError(err)
+	if ae == nil {
+		return nil
+	}
+
+	info := &awsErrorInfo{
+		Code:    ae.Code(),
+		Message: ae.Message(),
+	}
+
+	if rf, ok := ae.(awserr.RequestFailure); ok {
+		info.StatusCode = rf.StatusCode()
+		info.RequestID = rf.RequestID()
+	}
+
+	info.Throttle = request.IsErrorThrottle(ae)
+	info.Retryable = info.Throttle || request.IsErrorRetryable(ae) || info.StatusCode >= 500
+
+	return info
+}
+
+// unwrapAWSError finds the aws error within err whether it was wrapped with fmt.Errorf or pkg/errors
+func unwrapAWSError(err error) awserr.Error {
+	for err != nil {
+		var ae awserr.Error
+
+		if stderrors.As(err, &ae) {
+			return ae
+		}
+
+		cause := errors.Cause(err)
+		if cause == err {
+			return nil
+		}
+
+		err = cause
+	}
+
+	return nil
+}
+
+// isNotFound returns true if err is an aws error for a resource that does not exist
+// cloudformation reports a missing stack as a ValidationError so only those that say so count
+func isNotFound(err error) bool {
+	info := classifyAWSError(err)
+	if info == nil {
+		return false
+	}
+
+	if info.Code == "ValidationError" {
+		return strings.Contains(info.Message, "does not exist")
+	}
+
+	return info.StatusCode == 404 || awsNotFoundCodes[info.Code]
+}
+
+// isThrottle returns true if err is an aws error for a request that was rate limited
+func isThrottle(err error) bool {
+	info := classifyAWSError(err)
+	return info != nil && info.Throttle
+}
+
+// isAccessDenied returns true if err is an aws error for a request the caller has no permission to make
+func isAccessDenied(err error) bool {
+	info := classifyAWSError(err)
+	return info != nil && (info.StatusCode == 403 || awsAccessDeniedCodes[info.Code])
+}
+
+// isConflict returns true if err is an aws error for a resource that already exists or is in use
+func isConflict(err error) bool {
+	info := classifyAWSError(err)
+	return info != nil && (info.StatusCode == 409 || awsConflictCodes[info.Code])
+}
+
+// logAWSError logs err with the id of the aws request that failed, if there is one, so it can be traced with aws support
+func logAWSError(log *logger.Logger, err error) error {
+	if info := classifyAWSError(err); info != nil && info.RequestID != "" {
+		log = log.Append("request=%s", info.RequestID)
+	}
+
+	return log.Error(err)
+}
+
 type apiError struct {
 	error
 	trace errors.StackTrace
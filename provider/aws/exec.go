@@ -0,0 +1,351 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+var (
+	execInspectInterval = 1 * time.Second
+	execInspectRetries  = 10
+
+	// sessionManagerPlugin streams ecs exec sessions, it is the plugin the aws cli uses for the same purpose
+	sessionManagerPlugin = "session-manager-plugin"
+)
+
+// execSession is an interactive command running inside a process
+type execSession interface {
+	// Resize changes the terminal size of the session, only valid for sessions with a tty
+	Resize(height, width int) error
+
+	// Wait blocks until the output stream ends and returns the exit code of the command
+	Wait() (int, error)
+}
+
+type processExecOptions struct {
+	TTY    bool
+	Height int
+	Width  int
+}
+
+// processExec starts cmd inside the container of a process and streams rw to and from it
+// stdin is half-closed when rw reaches EOF so commands reading until EOF exit normally
+func (p *Provider) processExec(ctx context.Context, app, pid string, cmd []string, rw io.ReadWriter, opts processExecOptions) (execSession, error) {
+	arn, err := p.taskArnFromPid(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := p.describeTask(arn)
+	if err != nil {
+		return nil, err
+	}
+
+	ecsExec, err := p.useECSExec(task)
+	if err != nil {
+		return nil, err
+	}
+
+	if ecsExec {
+		return p.execCommand(ctx, task, cmd, opts.TTY, rw)
+	}
+
+	dc, err := p.dockerClientFromPid(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := p.dockerContainerFromPid(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	return startDockerExec(ctx, dc, c.ID, cmd, rw, opts)
+}
+
+// useECSExec is true for fargate tasks, which have no instance docker api to reach, and for tasks started with
+// ECS Exec enabled when the rack enables it, tasks started before that keep going through the docker api
+func (p *Provider) useECSExec(task *ecs.Task) (bool, error) {
+	if aws.StringValue(task.LaunchType) == ecs.LaunchTypeFargate {
+		return true, nil
+	}
+
+	if !p.EcsExec {
+		return false, nil
+	}
+
+	return p.taskExecuteCommandEnabled(aws.StringValue(task.ClusterArn), aws.StringValue(task.TaskArn))
+}
+
+// taskExecuteCommandEnabled reads whether a task was started with ECS Exec enabled
+func (p *Provider) taskExecuteCommandEnabled(cluster, arn string) (bool, error) {
+	res := &ecsDescribeTasksOutput{}
+
+	op := &request.Operation{Name: "DescribeTasks", HTTPMethod: "POST", HTTPPath: "/"}
+
+	req := p.ecs().NewRequest(op, &ecs.DescribeTasksInput{
+		Cluster: aws.String(cluster),
+		Tasks:   []*string{aws.String(arn)},
+	}, res)
+
+	if err := req.Send(); err != nil {
+		return false, err
+	}
+
+	if len(res.Tasks) != 1 {
+		return false, fmt.Errorf("could not fetch process status")
+	}
+
+	return aws.BoolValue(res.Tasks[0].EnableExecuteCommand), nil
+}
+
+// execCommand starts cmd in the main container of a task with ECS Exec and streams rw through the session manager plugin
+func (p *Provider) execCommand(ctx context.Context, task *ecs.Task, cmd []string, interactive bool, rw io.ReadWriter) (execSession, error) {
+	if len(task.Containers) < 1 {
+		return nil, fmt.Errorf("no running container for task: %s", aws.StringValue(task.TaskArn))
+	}
+
+	c := task.Containers[0]
+
+	// sidecars run beside the main container, which is the first one the task definition lists
+	if len(task.Containers) > 1 {
+		cd, err := p.containerDefinitionForTask(aws.StringValue(task.TaskDefinitionArn))
+		if err != nil {
+			return nil, err
+		}
+
+		c = taskContainer(task, aws.StringValue(cd.Name))
+	}
+
+	req := &ecsExecuteCommandInput{
+		Cluster:     task.ClusterArn,
+		Command:     aws.String(shellJoin(cmd)),
+		Container:   c.Name,
+		Interactive: aws.Bool(interactive),
+		Task:        task.TaskArn,
+	}
+
+	res := &ecsExecuteCommandOutput{}
+
+	op := &request.Operation{Name: "ExecuteCommand", HTTPMethod: "POST", HTTPPath: "/"}
+
+	if err := p.ecs().NewRequest(op, req, res).Send(); err != nil {
+		return nil, err
+	}
+
+	if res.Session == nil {
+		return nil, fmt.Errorf("no exec session for task: %s", aws.StringValue(task.TaskArn))
+	}
+
+	session, err := json.Marshal(map[string]string{
+		"SessionId":  aws.StringValue(res.Session.SessionId),
+		"StreamUrl":  aws.StringValue(res.Session.StreamUrl),
+		"TokenValue": aws.StringValue(res.Session.TokenValue),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cluster, err := clusterNameFromArn(aws.StringValue(task.ClusterArn))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := taskIdFromArn(aws.StringValue(task.TaskArn))
+	if err != nil {
+		return nil, err
+	}
+
+	// ssm targets an ecs container as ecs:<cluster>_<task id>_<runtime id>
+	target, err := json.Marshal(map[string]string{
+		"Target": fmt.Sprintf("ecs:%s_%s_%s", cluster, id, aws.StringValue(c.RuntimeId)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pc := exec.CommandContext(ctx, sessionManagerPlugin, string(session), p.Region, "StartSession", "", string(target), fmt.Sprintf("https://ssm.%s.amazonaws.com", p.Region))
+
+	pc.Stdin = execInput{rw}
+	pc.Stdout = rw
+	pc.Stderr = rw
+
+	if err := pc.Start(); err != nil {
+		return nil, err
+	}
+
+	return &ecsExecSession{cmd: pc}, nil
+}
+
+// ecsExecSession is an exec session running through the session manager plugin
+type ecsExecSession struct {
+	cmd *exec.Cmd
+}
+
+// Resize is not available, the plugin sizes the remote terminal itself
+func (s *ecsExecSession) Resize(height, width int) error {
+	return fmt.Errorf("ecs exec sessions can not be resized")
+}
+
+// Wait returns the exit code of the plugin, which ends with the remote command
+func (s *ecsExecSession) Wait() (int, error) {
+	if err := s.cmd.Wait(); err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return ee.ExitCode(), nil
+		}
+
+		return -1, err
+	}
+
+	return 0, nil
+}
+
+// shellJoin quotes each argument for sh so ECS Exec, which takes a single command string, sees the same argv
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+
+	for i, a := range args {
+		quoted[i] = "'" + strings.Replace(a, "'", `'"'"'`, -1) + "'"
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// dockerExecSession is an exec session using the docker api of the instance running the process
+type dockerExecSession struct {
+	client *docker.Client
+	id     string
+	stream docker.CloseWaiter
+	tty    bool
+
+	done chan struct{}
+	err  error
+	once sync.Once
+}
+
+func startDockerExec(ctx context.Context, dc *docker.Client, container string, cmd []string, rw io.ReadWriter, opts processExecOptions) (*dockerExecSession, error) {
+	eres, err := dc.CreateExec(docker.CreateExecOptions{
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          opts.TTY,
+		Cmd:          cmd,
+		Container:    container,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &dockerExecSession{client: dc, id: eres.ID, tty: opts.TTY}
+
+	sopts := docker.StartExecOptions{
+		Tty:          opts.TTY,
+		InputStream:  execInput{rw},
+		OutputStream: rw,
+		ErrorStream:  rw,
+		RawTerminal:  opts.TTY,
+	}
+
+	// a tty can only be resized once the exec has started
+	if opts.TTY && opts.Height > 0 && opts.Width > 0 {
+		success := make(chan struct{})
+
+		go func() {
+			<-success
+			s.Resize(opts.Height, opts.Width)
+			success <- struct{}{}
+		}()
+
+		sopts.Success = success
+	}
+
+	stream, err := dc.StartExecNonBlocking(eres.ID, sopts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.stream = stream
+	s.done = make(chan struct{})
+
+	go func() {
+		s.finish(stream.Wait())
+	}()
+
+	// closing the stream abandons it without an error from Wait so cancellation finishes the session itself
+	go func() {
+		select {
+		case <-ctx.Done():
+			stream.Close()
+			s.finish(ctx.Err())
+		case <-s.done:
+		}
+	}()
+
+	return s, nil
+}
+
+func (s *dockerExecSession) finish(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+}
+
+func (s *dockerExecSession) Resize(height, width int) error {
+	if !s.tty {
+		return fmt.Errorf("exec session has no tty")
+	}
+
+	return s.client.ResizeExecTTY(s.id, height, width)
+}
+
+// Wait returns the exit code once the stream has ended
+// docker can report an exec as running briefly after its output closes so inspection is retried
+func (s *dockerExecSession) Wait() (int, error) {
+	<-s.done
+
+	if s.err != nil {
+		return -1, s.err
+	}
+
+	var err error
+
+	for i := 0; i < execInspectRetries; i++ {
+		if i > 0 {
+			time.Sleep(execInspectInterval)
+		}
+
+		var ires *docker.ExecInspect
+
+		ires, err = s.client.InspectExec(s.id)
+		if err != nil {
+			continue
+		}
+
+		if ires.Running {
+			err = fmt.Errorf("exec still running: %s", s.id)
+			continue
+		}
+
+		return ires.ExitCode, nil
+	}
+
+	return -1, err
+}
+
+// execInput hides any Close method of the input so the docker client
+// does not close the caller's stream when the command output ends
+type execInput struct {
+	io.Reader
+}
@@ -0,0 +1,288 @@
+package aws_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerExecSession(t *testing.T) {
+	aws.SetExecInspectInterval(1 * time.Millisecond)
+
+	fd := newFakeDockerExec(2, 3)
+	defer fd.Close()
+
+	in := strings.NewReader("hello")
+	out := &bytes.Buffer{}
+
+	s, err := aws.StartDockerExec(context.Background(), fd.client(t), "container1", []string{"cat"}, streamTester{in, out}, aws.ProcessExecOptions{TTY: true, Height: 24, Width: 80})
+	require.NoError(t, err)
+
+	code, err := s.Wait()
+	require.NoError(t, err)
+
+	// cat only exits once stdin is half-closed after the input reaches EOF
+	assert.Equal(t, "hello", out.String())
+	assert.Equal(t, 3, code)
+
+	require.NoError(t, s.Resize(40, 120))
+
+	assert.Equal(t, []string{"24x80", "40x120"}, fd.resizes())
+	assert.Equal(t, 3, fd.inspects())
+
+	fd.lock.Lock()
+	assert.Equal(t, true, fd.create.Tty)
+	assert.Equal(t, []string{"cat"}, fd.create.Cmd)
+	fd.lock.Unlock()
+}
+
+func TestProcessExecFargate(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	require.NoError(t, provider.Fake.ECS.AddTask("cluster-test", &ecs.Task{
+		Containers:    []*ecs.Container{{Name: awssdk.String("web"), RuntimeId: awssdk.String("runtime1")}},
+		DesiredStatus: awssdk.String("RUNNING"),
+		LastStatus:    awssdk.String("RUNNING"),
+		LaunchType:    awssdk.String(ecs.LaunchTypeFargate),
+	}))
+
+	// stand in for the session manager plugin, echo the target it was given and the input
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	plugin := filepath.Join(dir, "session-manager-plugin")
+	require.NoError(t, ioutil.WriteFile(plugin, []byte("#!/bin/sh\necho \"$3 $5\"\ncat\nexit 3\n"), 0755))
+
+	aws.SetSessionManagerPlugin(plugin)
+	defer aws.SetSessionManagerPlugin("session-manager-plugin")
+
+	in := strings.NewReader("hello")
+	out := &bytes.Buffer{}
+
+	s, err := provider.ProcessExecSession(context.Background(), "httpd", "000000000001", []string{"sh", "-c", "ls"}, streamTester{in, out}, aws.ProcessExecOptions{TTY: true})
+	require.NoError(t, err)
+
+	code, err := s.Wait()
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, code)
+	assert.Equal(t, "StartSession {\"Target\":\"ecs:cluster-test_00000000000000000000000000000001_runtime1\"}\nhello", out.String())
+
+	assert.Error(t, s.Resize(40, 120))
+
+	cmds := provider.Fake.ECS.ExecuteCommands()
+	require.Len(t, cmds, 1)
+	assert.Equal(t, `'sh' '-c' 'ls'`, cmds[0].Command)
+	assert.Equal(t, "web", cmds[0].Container)
+	assert.Equal(t, true, cmds[0].Interactive)
+}
+
+func TestUseECSExec(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	fargate := &ecs.Task{LaunchType: awssdk.String(ecs.LaunchTypeFargate)}
+	ec2 := &ecs.Task{LaunchType: awssdk.String(ecs.LaunchTypeEc2)}
+	enabled := &ecs.Task{LaunchType: awssdk.String(ecs.LaunchTypeEc2)}
+
+	// the fake fills in the arns of the tasks it is given
+	for _, task := range []*ecs.Task{fargate, ec2, enabled} {
+		require.NoError(t, provider.Fake.ECS.AddTask("cluster-test", task))
+	}
+
+	provider.Fake.ECS.SetTaskExecuteCommand(awssdk.StringValue(enabled.TaskArn), true)
+
+	for _, task := range []*ecs.Task{fargate, ec2, enabled} {
+		ok, err := provider.UseECSExec(task)
+		require.NoError(t, err)
+		assert.Equal(t, task == fargate, ok)
+	}
+
+	provider.EcsExec = true
+
+	// tasks started before the rack enabled ECS Exec have no agent for it and go through docker
+	for _, task := range []*ecs.Task{fargate, ec2, enabled} {
+		ok, err := provider.UseECSExec(task)
+		require.NoError(t, err)
+		assert.Equal(t, task != ec2, ok)
+	}
+}
+
+func TestDockerExecSessionNoTTY(t *testing.T) {
+	aws.SetExecInspectInterval(1 * time.Millisecond)
+
+	fd := newFakeDockerExec(0, 0)
+	defer fd.Close()
+
+	s, err := aws.StartDockerExec(context.Background(), fd.client(t), "container1", []string{"cat"}, streamTester{strings.NewReader(""), &bytes.Buffer{}}, aws.ProcessExecOptions{Height: 24, Width: 80})
+	require.NoError(t, err)
+
+	code, err := s.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, 0, code)
+
+	require.EqualError(t, s.Resize(40, 120), "exec session has no tty")
+	assert.Empty(t, fd.resizes())
+}
+
+func TestDockerExecSessionStillRunning(t *testing.T) {
+	aws.SetExecInspectInterval(1 * time.Millisecond)
+
+	fd := newFakeDockerExec(100, 0)
+	defer fd.Close()
+
+	s, err := aws.StartDockerExec(context.Background(), fd.client(t), "container1", []string{"cat"}, streamTester{strings.NewReader(""), &bytes.Buffer{}}, aws.ProcessExecOptions{TTY: true})
+	require.NoError(t, err)
+
+	code, err := s.Wait()
+	require.EqualError(t, err, "exec still running: exec1")
+	assert.Equal(t, -1, code)
+}
+
+func TestDockerExecSessionCancel(t *testing.T) {
+	fd := newFakeDockerExec(0, 0)
+	defer fd.Close()
+
+	// stdin is never closed so the command keeps running until the session is cancelled
+	r, w := io.Pipe()
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s, err := aws.StartDockerExec(ctx, fd.client(t), "container1", []string{"cat"}, streamTester{r, &bytes.Buffer{}}, aws.ProcessExecOptions{TTY: true})
+	require.NoError(t, err)
+
+	cancel()
+
+	code, err := s.Wait()
+	require.Equal(t, context.Canceled, err)
+	assert.Equal(t, -1, code)
+}
+
+// fakeDockerExec serves the docker exec api for a single exec that echoes its stdin
+type fakeDockerExec struct {
+	*httptest.Server
+
+	lock     sync.Mutex
+	create   docker.CreateExecOptions
+	exit     int
+	inspect  int
+	running  int
+	resized  []string
+	finished bool
+}
+
+// newFakeDockerExec returns a fake where the exec reports running for the first running inspections
+func newFakeDockerExec(running, exit int) *fakeDockerExec {
+	fd := &fakeDockerExec{exit: exit, running: running}
+	fd.Server = httptest.NewServer(http.HandlerFunc(fd.serve))
+	return fd
+}
+
+func (fd *fakeDockerExec) client(t *testing.T) *docker.Client {
+	dc, err := docker.NewClient(fd.URL)
+	require.NoError(t, err)
+
+	dc.SkipServerVersionCheck = true
+
+	return dc
+}
+
+func (fd *fakeDockerExec) inspects() int {
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+
+	return fd.inspect
+}
+
+func (fd *fakeDockerExec) resizes() []string {
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+
+	return append([]string{}, fd.resized...)
+}
+
+func (fd *fakeDockerExec) serve(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/containers/container1/exec":
+		fd.lock.Lock()
+		json.NewDecoder(r.Body).Decode(&fd.create)
+		fd.lock.Unlock()
+
+		w.WriteHeader(201)
+		fmt.Fprintf(w, `{"Id":"exec1"}`)
+	case r.Method == "POST" && r.URL.Path == "/exec/exec1/start":
+		ioutil.ReadAll(r.Body)
+		fd.start(w)
+	case r.Method == "POST" && r.URL.Path == "/exec/exec1/resize":
+		fd.lock.Lock()
+		fd.resized = append(fd.resized, fmt.Sprintf("%sx%s", r.URL.Query().Get("h"), r.URL.Query().Get("w")))
+		fd.lock.Unlock()
+
+		w.WriteHeader(201)
+	case r.Method == "GET" && r.URL.Path == "/exec/exec1/json":
+		fd.lock.Lock()
+		defer fd.lock.Unlock()
+
+		fd.inspect++
+
+		running := !fd.finished || fd.inspect <= fd.running
+
+		json.NewEncoder(w).Encode(docker.ExecInspect{ID: "exec1", Running: running, ExitCode: fd.exit})
+	default:
+		http.Error(w, "not found", 404)
+	}
+}
+
+// start hijacks the connection and echoes stdin back until the client half-closes it
+func (fd *fakeDockerExec) start(w http.ResponseWriter) {
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(rw, "HTTP/1.1 200 OK\r\nContent-Type: application/vnd.docker.raw-stream\r\n\r\n")
+	rw.Flush()
+
+	fd.lock.Lock()
+	tty := fd.create.Tty
+	fd.lock.Unlock()
+
+	data, _ := ioutil.ReadAll(rw)
+
+	if tty {
+		rw.Write(data)
+	} else if len(data) > 0 {
+		// without a tty output is multiplexed with a stream header
+		rw.Write([]byte{1, 0, 0, 0, 0, 0, 0, byte(len(data))})
+		rw.Write(data)
+	}
+
+	rw.Flush()
+
+	fd.lock.Lock()
+	fd.finished = true
+	fd.lock.Unlock()
+}
@@ -0,0 +1,341 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/convox/rack/pkg/manifest"
+	"github.com/convox/rack/pkg/structs"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// exports for testing unexported helpers from package aws_test
+
+var (
+	ArnPartition                = arnPartition
+	ArnToPid                    = arnToPid
+	AwsError                    = awsError
+	ClassifyAWSError            = classifyAWSError
+	ClusterNameFromArn          = clusterNameFromArn
+	IsAccessDenied              = isAccessDenied
+	IsConflict                  = isConflict
+	IsNotFound                  = isNotFound
+	IsThrottle                  = isThrottle
+	CertificateFriendlyId       = certificateFriendlyId
+	Coalesce                    = coalesce
+	DiffParameters              = diffParameters
+	EnvRevision                 = envRevision
+	EnvSize                     = envSize
+	ForEachApp                  = forEachApp
+	GenerateId                  = generateId
+	HealthCheckConfig           = healthCheckConfig
+	IdempotencyToken            = idempotencyToken
+	ObjectKey                   = objectKey
+	Jitter                      = jitter
+	ParameterDriftOf            = parameterDrift
+	ParseARN                    = parseARN
+	ParseConnectivity           = parseConnectivity
+	ParseTelemetry              = parseInstanceTelemetry
+	Percentile                  = percentile
+	RandomInt                   = randomInt
+	RandomString                = randomString
+	SecurePassword              = securePassword
+	TemplateActions             = templateActions
+	ServiceNameFromArn          = serviceNameFromArn
+	TaskIdFromArn               = taskIdFromArn
+	ValidateBottlerocketVariant = validateBottlerocketVariant
+	ValidateEnvName             = validateEnvName
+	ValidateEnvSize             = validateEnvSize
+)
+
+type ARNParts = arnParts
+type AWSErrorInfo = awsErrorInfo
+type AppExportOptions = appExportOptions
+type AppImportOptions = appImportOptions
+type BluegreenState = bluegreenState
+type ForEachAppOptions = forEachAppOptions
+
+func (p *Provider) S3PresignPost(bucket, keyPrefix string, maxSize int64, expires time.Duration) (*structs.PresignedPost, error) {
+	return p.s3PresignPost(bucket, keyPrefix, maxSize, expires)
+}
+
+func ParseStackNotification(body string) (*StackNotification, error) {
+	return parseStackNotification(body)
+}
+
+func SetStackNotificationLast(t time.Time) {
+	stackNotificationLock.Lock()
+	defer stackNotificationLock.Unlock()
+
+	stackNotificationLast = t
+}
+
+// SetRandSource swaps the source of ids and passwords and returns a func that restores it
+func SetRandSource(r io.Reader) func() {
+	prev := randSource
+	randSource = &lockedReader{source: r}
+
+	return func() { randSource = prev }
+}
+
+func StackCacheTTL() time.Duration {
+	return stackCacheTTL()
+}
+
+func SetEFSPollInterval(d time.Duration) {
+	efsPollInterval = d
+}
+
+func SetBuildOutputPollInterval(d time.Duration) {
+	buildOutputPollInterval = d
+}
+
+func (p *Provider) S3PutWithOptions(bucket, key string, data []byte, cacheControl, contentDisposition, contentType string) error {
+	return p.s3PutWithOptions(bucket, key, data, s3PutOptions{
+		CacheControl:       &cacheControl,
+		ContentDisposition: &contentDisposition,
+		ContentType:        &contentType,
+	})
+}
+
+func (p *Provider) S3PutTagged(bucket, key string, data []byte, tags map[string]string) error {
+	return p.s3PutWithOptions(bucket, key, data, s3PutOptions{Tags: tags})
+}
+
+func (p *Provider) S3PutLarge(bucket, key string, data []byte, public bool, partSize int64, concurrency int) error {
+	return p.s3PutLarge(bucket, key, data, s3PutOptions{Public: public}, partSize, concurrency)
+}
+
+func (p *Provider) CertificateArnFromId(id string) (string, error) {
+	return p.certificateArnFromId(id)
+}
+
+func (p *Provider) WaitForTaskStatus(ctx context.Context, id string, target string) (*ecs.Task, error) {
+	return p.waitForTaskStatus(ctx, id, target)
+}
+
+func SetTaskStatusPollInterval(d time.Duration) {
+	taskStatusPollInterval = d
+}
+
+func (p *Provider) OrphanedCronRuleNames(app string, jobs CronJobs) ([]string, error) {
+	return p.orphanedCronRuleNames(app, jobs)
+}
+
+func (p *Provider) DescribeTaskDefinition(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	return p.describeTaskDefinition(input)
+}
+
+func (p *Provider) ParseStackName(stackName string) (string, string, bool) {
+	return p.parseStackName(stackName)
+}
+
+func (p *Provider) AppResourcesRecursive(app string) (map[string]string, error) {
+	return p.appResourcesRecursive(app)
+}
+
+func (p *Provider) SetRateLimits(limits map[string]RateLimit) {
+	p.limiter = newRateLimiter(limits)
+}
+
+func ParseRateLimits(s string) (map[string]RateLimit, error) {
+	return parseRateLimits(s)
+}
+
+func SetStackDeletePollInterval(d time.Duration) {
+	stackDeletePollInterval = d
+}
+
+func SetStackCreatePollInterval(d time.Duration) {
+	stackCreatePollInterval = d
+}
+
+func (p *Provider) AppExport(app string, w io.Writer, opts AppExportOptions) error {
+	return p.appExport(app, w, opts)
+}
+
+func (p *Provider) AppImport(r io.Reader, name string, opts AppImportOptions) (*structs.Release, error) {
+	return p.appImport(r, name, opts)
+}
+
+func (p *Provider) CapacityPreflight(a *structs.App, m *manifest.Manifest) error {
+	return p.capacityPreflight(a, m)
+}
+
+type ExecSession = execSession
+type ProcessExecOptions = processExecOptions
+
+func StartDockerExec(ctx context.Context, dc *docker.Client, container string, cmd []string, rw io.ReadWriter, opts ProcessExecOptions) (ExecSession, error) {
+	return startDockerExec(ctx, dc, container, cmd, rw, opts)
+}
+
+func SetExecInspectInterval(d time.Duration) {
+	execInspectInterval = d
+}
+
+func SetConnectivityTimeout(d time.Duration) {
+	connectivityTimeout = d
+}
+
+func SetTelemetryPollInterval(d time.Duration) {
+	telemetryPollInterval = d
+}
+
+func SetSessionManagerPlugin(path string) {
+	sessionManagerPlugin = path
+}
+
+func (p *Provider) ProcessExecSession(ctx context.Context, app, pid string, cmd []string, rw io.ReadWriter, opts ProcessExecOptions) (ExecSession, error) {
+	return p.processExec(ctx, app, pid, cmd, rw, opts)
+}
+
+func (p *Provider) UseECSExec(task *ecs.Task) (bool, error) {
+	return p.useECSExec(task)
+}
+
+func (p *Provider) RackUpdatePlan(toVersion string) (string, string, []string, error) {
+	plan, err := p.rackUpdatePlan(toVersion)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	steps := []string{}
+
+	for _, s := range plan.Steps {
+		steps = append(steps, fmt.Sprintf("%s%v", s.Kind, s.Apps))
+	}
+
+	return plan.From, plan.To, steps, nil
+}
+
+func (p *Provider) RunRackUpdate(ctx context.Context, toVersion string, rack func(version string) error, app func(a structs.App) error) error {
+	plan, err := p.rackUpdatePlan(toVersion)
+	if err != nil {
+		return err
+	}
+
+	return p.runRackUpdate(ctx, plan, rack, app)
+}
+
+func SetACMCertificatePollInterval(d time.Duration) {
+	acmCertificatePollInterval = d
+}
+
+func (p *Provider) RotateInternalCertificate(host string) (string, error) {
+	return p.rotateInternalCertificate(host)
+}
+
+func SetServerCertificateWaitTick(d time.Duration) {
+	serverCertificateWaitTick = d
+}
+
+func SetInternalCertificateUpdatePollInterval(d time.Duration) {
+	internalCertificateUpdatePollInterval = d
+}
+
+func (p *Provider) DescribeStacks(input *cloudformation.DescribeStacksInput) ([]*cloudformation.Stack, error) {
+	return p.describeStacks(input)
+}
+
+func SetDescribeStacksPageInterval(d time.Duration) {
+	describeStacksPageInterval = d
+}
+
+func (p *Provider) BuildTransition(b *structs.Build, from string) error {
+	return p.buildTransition(b, from)
+}
+
+func (p *Provider) CreateStack(name string, body []byte, params map[string]string, tags map[string]string) error {
+	return p.createStack(name, body, params, tags)
+}
+
+func (p *Provider) DescribeServicesChecked(input *ecs.DescribeServicesInput) ([]*ecs.Service, []*ecs.Failure, error) {
+	return p.describeServicesChecked(input)
+}
+
+func (p *Provider) RunTask(req *ecs.RunTaskInput) (*ecs.Task, error) {
+	return p.runTask(req)
+}
+
+func (p *Provider) RackBalancerDNS() (string, error) {
+	return p.rackBalancerDNS()
+}
+
+type AuditEntry = auditEntry
+
+func (p *Provider) AuditLog(target string, since time.Time, limit int) ([]AuditEntry, error) {
+	return p.auditLog(target, since, limit)
+}
+
+func (p *Provider) AuditRecord(op, target, actor string, detail map[string]string) {
+	p.auditRecord(op, target, actor, detail)
+}
+
+func FormationTemplate(name string, data interface{}) ([]byte, error) {
+	return formationTemplate(name, data)
+}
+
+func (p *Provider) GetAllLogEvents(group, stream string) ([]string, error) {
+	return p.getAllLogEvents(group, stream)
+}
+
+func (p *Provider) ExecutionRoleCanPull(role, image string) error {
+	return p.executionRoleCanPull(role, image)
+}
+
+func (p *Provider) GetMetricData(queries []*cloudwatch.MetricDataQuery, start, end time.Time) (map[string][]float64, error) {
+	return p.getMetricData(queries, start, end)
+}
+
+func (p *Provider) RackUtilizationReport(days int) (*UtilizationReport, error) {
+	return p.rackUtilizationReport(days)
+}
+
+func (p *Provider) ServiceCapacity(app string) (map[string]structs.ServiceCapacity, error) {
+	return p.serviceCapacity(app)
+}
+
+func (p *Provider) ReportParameterDrift(a *structs.App, template []byte, changes map[string]string, opts structs.ReleasePromoteOptions) error {
+	return p.reportParameterDrift(a, template, changes, opts)
+}
+
+func (p *Provider) BluegreenStatus(app, service string) (*BluegreenState, error) {
+	return p.bluegreenStatus(app, service)
+}
+
+func (p *Provider) BluegreenPromote(app, service string) error {
+	return p.bluegreenPromote(app, service)
+}
+
+func (p *Provider) BluegreenRollback(app, service string) error {
+	return p.bluegreenRollback(app, service)
+}
+
+func (p *Provider) BluegreenReleaseChanges(app string, m *manifest.Manifest) (map[string]string, error) {
+	return p.bluegreenReleaseChanges(app, m)
+}
+
+func (p *Provider) EnvSet(app string, changes map[string]string, deletes []string) (*structs.Release, error) {
+	return p.envSet(app, changes, deletes)
+}
+
+func SetEnvSetRetryInterval(d time.Duration) {
+	envSetRetryInterval = d
+}
+
+func (p *Provider) AppOutput(app, output string) (string, error) {
+	return p.appOutput(app, output)
+}
+
+func (p *Provider) ServiceArn(app, service string) (string, error) {
+	return p.serviceArn(app, service)
+}
+
+func SetCapacityProviderPollInterval(d time.Duration) {
+	capacityProviderPollInterval = d
+}
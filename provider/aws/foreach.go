@@ -0,0 +1,123 @@
+package aws
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/convox/rack/pkg/structs"
+)
+
+// AppResult is the outcome of running a rack-wide operation against a single app
+type AppResult struct {
+	App      string
+	Duration time.Duration
+	Error    error
+}
+
+// AppReport collects the results of a rack-wide operation
+type AppReport struct {
+	Results []AppResult
+	Skipped []string
+	Total   int
+}
+
+// Failed returns the results that returned an error
+func (r *AppReport) Failed() []AppResult {
+	failed := []AppResult{}
+
+	for _, res := range r.Results {
+		if res.Error != nil {
+			failed = append(failed, res)
+		}
+	}
+
+	return failed
+}
+
+type forEachAppOptions struct {
+	// FailFast stops scheduling new apps after the first failure
+	FailFast bool
+
+	// Progress is called each time an app completes with the number of apps done so far
+	Progress func(done, total int)
+}
+
+// forEachApp runs fn for each app with at most concurrency apps in flight
+// cancelling ctx (or a failure in fail fast mode) stops scheduling new apps but lets in-flight apps finish
+func forEachApp(ctx context.Context, apps structs.Apps, concurrency int, opts forEachAppOptions, fn func(app structs.App) error) (*AppReport, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	report := &AppReport{Results: []AppResult{}, Skipped: []string{}, Total: len(apps)}
+
+	var (
+		lock     sync.Mutex
+		failed   error
+		inflight sync.WaitGroup
+	)
+
+	sem := make(chan struct{}, concurrency)
+
+	stopped := func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return failed != nil || ctx.Err() != nil
+	}
+
+	for i, a := range apps {
+		if !stopped() {
+			select {
+			case <-ctx.Done():
+			case sem <- struct{}{}:
+				if stopped() {
+					<-sem
+				}
+			}
+		}
+
+		if stopped() {
+			for _, s := range apps[i:] {
+				report.Skipped = append(report.Skipped, s.Name)
+			}
+			break
+		}
+
+		inflight.Add(1)
+
+		go func(a structs.App) {
+			defer inflight.Done()
+			defer func() { <-sem }()
+
+			start := time.Now()
+
+			err := fn(a)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			report.Results = append(report.Results, AppResult{App: a.Name, Duration: time.Since(start), Error: err})
+
+			if err != nil && opts.FailFast && failed == nil {
+				failed = err
+			}
+
+			if opts.Progress != nil {
+				opts.Progress(len(report.Results), report.Total)
+			}
+		}(a)
+	}
+
+	inflight.Wait()
+
+	sort.Slice(report.Results, func(i, j int) bool { return report.Results[i].App < report.Results[j].App })
+
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+
+	return report, failed
+}
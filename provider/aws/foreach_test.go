@@ -0,0 +1,120 @@
+package aws_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/convox/rack/pkg/structs"
+	"github.com/convox/rack/provider/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func foreachApps(names ...string) structs.Apps {
+	apps := structs.Apps{}
+
+	for _, n := range names {
+		apps = append(apps, structs.App{Name: n})
+	}
+
+	return apps
+}
+
+func TestForEachAppContinueOnError(t *testing.T) {
+	var (
+		active, peak int32
+		lock         sync.Mutex
+		progress     []int
+	)
+
+	opts := aws.ForEachAppOptions{
+		Progress: func(done, total int) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			assert.Equal(t, 5, total)
+			progress = append(progress, done)
+		},
+	}
+
+	report, err := aws.ForEachApp(context.Background(), foreachApps("a", "b", "c", "d", "e"), 2, opts, func(app structs.App) error {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		if app.Name == "b" || app.Name == "d" {
+			return fmt.Errorf("failed %s", app.Name)
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.True(t, peak <= 2)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, progress)
+
+	assert.Equal(t, 5, report.Total)
+	assert.Empty(t, report.Skipped)
+	require.Len(t, report.Results, 5)
+
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		assert.Equal(t, name, report.Results[i].App)
+	}
+
+	failed := report.Failed()
+	require.Len(t, failed, 2)
+	assert.EqualError(t, failed[0].Error, "failed b")
+	assert.EqualError(t, failed[1].Error, "failed d")
+}
+
+func TestForEachAppFailFast(t *testing.T) {
+	report, err := aws.ForEachApp(context.Background(), foreachApps("a", "b", "c", "d"), 1, aws.ForEachAppOptions{FailFast: true}, func(app structs.App) error {
+		if app.Name == "b" {
+			return fmt.Errorf("failed %s", app.Name)
+		}
+
+		return nil
+	})
+	require.EqualError(t, err, "failed b")
+
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, "a", report.Results[0].App)
+	assert.NoError(t, report.Results[0].Error)
+	assert.Equal(t, "b", report.Results[1].App)
+	assert.EqualError(t, report.Results[1].Error, "failed b")
+	assert.Equal(t, []string{"c", "d"}, report.Skipped)
+}
+
+func TestForEachAppCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go func() {
+		<-started
+		cancel()
+		close(release)
+	}()
+
+	report, err := aws.ForEachApp(ctx, foreachApps("a", "b", "c"), 1, aws.ForEachAppOptions{}, func(app structs.App) error {
+		close(started)
+		<-release
+		return nil
+	})
+	require.Equal(t, context.Canceled, err)
+
+	require.Len(t, report.Results, 1)
+	assert.Equal(t, "a", report.Results[0].App)
+	assert.NoError(t, report.Results[0].Error)
+	assert.Equal(t, []string{"b", "c"}, report.Skipped)
+}
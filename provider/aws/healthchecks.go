@@ -0,0 +1,126 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// route53 considers an endpoint healthy when more than this share of its checkers report it healthy
+const healthCheckHealthyRatio = 0.18
+
+// HealthCheckOptions configures a Route 53 health check for a service endpoint
+type HealthCheckOptions struct {
+	FailureThreshold int
+	Path             string
+	Port             int
+	Protocol         string
+	RequestInterval  int
+	SearchString     string
+}
+
+// CreateHealthCheck creates a Route 53 health check against the endpoint of an app service
+func (p *Provider) CreateHealthCheck(app, service string, opts HealthCheckOptions) (string, error) {
+	log := Logger.At("CreateHealthCheck").Namespace("app=%q service=%q", app, service).Start()
+
+	a, err := p.AppGet(app)
+	if err != nil {
+		return "", log.Error(err)
+	}
+
+	endpoint := coalesces(a.Outputs[fmt.Sprintf("Service%sEndpoint", upperName(service))], a.Outputs[fmt.Sprintf("Balancer%sHost", upperName(service))])
+
+	if endpoint == "" {
+		return "", log.Error(errorNotFound(fmt.Sprintf("no endpoint for service: %s", service)))
+	}
+
+	cfg, err := healthCheckConfig(endpoint, opts)
+	if err != nil {
+		return "", log.Error(err)
+	}
+
+	res, err := p.route53().CreateHealthCheck(&route53.CreateHealthCheckInput{
+		CallerReference:   aws.String(fmt.Sprintf("%s-%s-%s-%d", p.Rack, app, service, time.Now().UnixNano())),
+		HealthCheckConfig: cfg,
+	})
+	if err != nil {
+		return "", log.Error(err)
+	}
+
+	return *res.HealthCheck.Id, log.Success()
+}
+
+func healthCheckConfig(endpoint string, opts HealthCheckOptions) (*route53.HealthCheckConfig, error) {
+	cfg := &route53.HealthCheckConfig{
+		FailureThreshold:         aws.Int64(3),
+		FullyQualifiedDomainName: aws.String(endpoint),
+		RequestInterval:          aws.Int64(30),
+		ResourcePath:             aws.String(coalesces(opts.Path, "/")),
+		SearchString:             aws.String(opts.SearchString),
+	}
+
+	switch strings.ToLower(opts.Protocol) {
+	case "", "https":
+		cfg.Type = aws.String(route53.HealthCheckTypeHttpsStrMatch)
+		cfg.Port = aws.Int64(443)
+	case "http":
+		cfg.Type = aws.String(route53.HealthCheckTypeHttpStrMatch)
+		cfg.Port = aws.Int64(80)
+	default:
+		return nil, fmt.Errorf("invalid health check protocol: %s", opts.Protocol)
+	}
+
+	if opts.Port > 0 {
+		cfg.Port = aws.Int64(int64(opts.Port))
+	}
+
+	if opts.FailureThreshold > 0 {
+		cfg.FailureThreshold = aws.Int64(int64(opts.FailureThreshold))
+	}
+
+	if opts.RequestInterval > 0 {
+		cfg.RequestInterval = aws.Int64(int64(opts.RequestInterval))
+	}
+
+	return cfg, nil
+}
+
+// GetHealthCheckStatus returns healthy or unhealthy based on the reports of the Route 53 health checkers
+func (p *Provider) GetHealthCheckStatus(id string) (string, error) {
+	res, err := p.route53().GetHealthCheckStatus(&route53.GetHealthCheckStatusInput{
+		HealthCheckId: aws.String(id),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(res.HealthCheckObservations) == 0 {
+		return "unhealthy", nil
+	}
+
+	healthy := 0
+
+	for _, o := range res.HealthCheckObservations {
+		if o.StatusReport != nil && strings.HasPrefix(cs(o.StatusReport.Status, ""), "Success") {
+			healthy++
+		}
+	}
+
+	if float64(healthy)/float64(len(res.HealthCheckObservations)) > healthCheckHealthyRatio {
+		return "healthy", nil
+	}
+
+	return "unhealthy", nil
+}
+
+// DeleteHealthCheck removes a Route 53 health check
+func (p *Provider) DeleteHealthCheck(id string) error {
+	_, err := p.route53().DeleteHealthCheck(&route53.DeleteHealthCheckInput{
+		HealthCheckId: aws.String(id),
+	})
+
+	return err
+}
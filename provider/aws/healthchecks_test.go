@@ -0,0 +1,152 @@
+package aws_test
+
+import (
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateHealthCheck(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleAppDescribeStacks,
+		cycleHealthCheckCreate,
+	)
+	defer provider.Close()
+
+	id, err := provider.CreateHealthCheck("httpd", "web", aws.HealthCheckOptions{
+		Path:         "/check",
+		Protocol:     "http",
+		SearchString: "ok",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "abcdef11-2222-3333-4444-555555fedcba", id)
+}
+
+func TestHealthCheckConfig(t *testing.T) {
+	cfg, err := aws.HealthCheckConfig("example.org", aws.HealthCheckOptions{
+		Path:         "/check",
+		Protocol:     "http",
+		SearchString: "ok",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &route53.HealthCheckConfig{
+		FailureThreshold:         awssdk.Int64(3),
+		FullyQualifiedDomainName: awssdk.String("example.org"),
+		Port:                     awssdk.Int64(80),
+		RequestInterval:          awssdk.Int64(30),
+		ResourcePath:             awssdk.String("/check"),
+		SearchString:             awssdk.String("ok"),
+		Type:                     awssdk.String("HTTP_STR_MATCH"),
+	}, cfg)
+
+	cfg, err = aws.HealthCheckConfig("example.org", aws.HealthCheckOptions{
+		FailureThreshold: 5,
+		Port:             8443,
+		RequestInterval:  10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &route53.HealthCheckConfig{
+		FailureThreshold:         awssdk.Int64(5),
+		FullyQualifiedDomainName: awssdk.String("example.org"),
+		Port:                     awssdk.Int64(8443),
+		RequestInterval:          awssdk.Int64(10),
+		ResourcePath:             awssdk.String("/"),
+		SearchString:             awssdk.String(""),
+		Type:                     awssdk.String("HTTPS_STR_MATCH"),
+	}, cfg)
+}
+
+func TestCreateHealthCheckInvalidProtocol(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleAppDescribeStacks,
+	)
+	defer provider.Close()
+
+	_, err := provider.CreateHealthCheck("httpd", "web", aws.HealthCheckOptions{Protocol: "tcp"})
+	require.EqualError(t, err, "invalid health check protocol: tcp")
+}
+
+func TestGetHealthCheckStatus(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleHealthCheckStatus("Success: HTTP Status Code 200, OK", "Failure: Connection timed out"),
+		cycleHealthCheckStatus("Failure: Connection timed out", "Failure: Connection timed out"),
+	)
+	defer provider.Close()
+
+	status, err := provider.GetHealthCheckStatus("abcdef11-2222-3333-4444-555555fedcba")
+	require.NoError(t, err)
+	assert.Equal(t, "healthy", status)
+
+	status, err = provider.GetHealthCheckStatus("abcdef11-2222-3333-4444-555555fedcba")
+	require.NoError(t, err)
+	assert.Equal(t, "unhealthy", status)
+}
+
+func TestDeleteHealthCheck(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleHealthCheckDelete,
+	)
+	defer provider.Close()
+
+	err := provider.DeleteHealthCheck("abcdef11-2222-3333-4444-555555fedcba")
+	require.NoError(t, err)
+}
+
+var cycleHealthCheckCreate = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/2013-04-01/healthcheck",
+		Body:       `/<FullyQualifiedDomainName>httpd-web-7E5UPCM-1241527783.us-east-1.elb.amazonaws.com</FullyQualifiedDomainName>/`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 201,
+		Body: `<?xml version="1.0" encoding="UTF-8"?>
+			<CreateHealthCheckResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/">
+				<HealthCheck>
+					<Id>abcdef11-2222-3333-4444-555555fedcba</Id>
+					<CallerReference>convox-httpd-web-1</CallerReference>
+					<HealthCheckConfig>
+						<Type>HTTP_STR_MATCH</Type>
+					</HealthCheckConfig>
+					<HealthCheckVersion>1</HealthCheckVersion>
+				</HealthCheck>
+			</CreateHealthCheckResponse>`,
+	},
+}
+
+func cycleHealthCheckStatus(statuses ...string) awsutil.Cycle {
+	observations := ""
+
+	for _, s := range statuses {
+		observations += `<HealthCheckObservation><Region>us-east-1</Region><IPAddress>192.0.2.1</IPAddress><StatusReport><Status>` + s + `</Status></StatusReport></HealthCheckObservation>`
+	}
+
+	return awsutil.Cycle{
+		Request: awsutil.Request{
+			Method:     "GET",
+			RequestURI: "/2013-04-01/healthcheck/abcdef11-2222-3333-4444-555555fedcba/status",
+		},
+		Response: awsutil.Response{
+			StatusCode: 200,
+			Body: `<?xml version="1.0" encoding="UTF-8"?>
+				<GetHealthCheckStatusResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/">
+					<HealthCheckObservations>` + observations + `</HealthCheckObservations>
+				</GetHealthCheckStatusResponse>`,
+		},
+	}
+}
+
+var cycleHealthCheckDelete = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/2013-04-01/healthcheck/abcdef11-2222-3333-4444-555555fedcba",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `<DeleteHealthCheckResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/"/>`,
+	},
+}
@@ -9,34 +9,41 @@ import (
 	"encoding/base32"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"html/template"
 	"io/ioutil"
 	"math/big"
-	"math/rand"
 	"net/url"
 	"os"
 	"path"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	crand "crypto/rand"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/convox/rack/pkg/cache"
+	"github.com/convox/rack/pkg/manifest"
 	"github.com/convox/rack/pkg/manifest1"
+	"github.com/convox/rack/pkg/options"
 	"github.com/convox/rack/pkg/structs"
 	docker "github.com/fsouza/go-dockerclient"
+	shellquote "github.com/kballard/go-shellquote"
 )
 
 type Formation struct {
@@ -66,8 +73,8 @@ func (p *Provider) accountId() (string, error) {
 }
 
 func awsError(err error) string {
-	if ae, ok := err.(awserr.Error); ok {
-		return ae.Code()
+	if info := classifyAWSError(err); info != nil {
+		return info.Code
 	}
 
 	return ""
@@ -88,29 +95,37 @@ func camelize(dasherized string) string {
 	return strings.Join(tokens, "")
 }
 
-func certificateFriendlyId(arn string) string {
-	ap := strings.Split(arn, ":")
-
-	if len(ap) < 6 {
-		return ""
+func certificateFriendlyId(arn string) (string, error) {
+	a, err := parseARN(arn)
+	if err != nil {
+		return "", fmt.Errorf("invalid certificate arn: %s", arn)
 	}
 
-	switch ap[2] {
+	switch a.Service {
 	case "acm":
-		np := strings.Split(ap[5], "-")
+		if a.ResourceType != "certificate" {
+			return "", fmt.Errorf("invalid acm certificate arn: %s", arn)
+		}
+
+		np := strings.Split(a.Resource, "-")
+
+		if np[len(np)-1] == "" {
+			return "", fmt.Errorf("invalid acm certificate arn: %s", arn)
+		}
 
-		return fmt.Sprintf("acm-%s", np[len(np)-1])
+		return fmt.Sprintf("acm-%s", np[len(np)-1]), nil
 	case "iam":
-		np := strings.SplitN(ap[5], "/", 2)
+		// server certificates can be uploaded with a path: server-certificate/path/to/name
+		np := strings.Split(a.Resource, "/")
 
-		if len(np) < 2 {
-			return ""
+		if a.ResourceType != "server-certificate" || np[len(np)-1] == "" {
+			return "", fmt.Errorf("invalid iam server certificate arn: %s", arn)
 		}
 
-		return np[1]
+		return np[len(np)-1], nil
 	}
 
-	return ""
+	return "", fmt.Errorf("unsupported certificate arn: %s", arn)
 }
 
 func cfParams(source map[string]string) map[string]string {
@@ -132,9 +147,15 @@ func cfParams(source map[string]string) map[string]string {
 	return params
 }
 
+// coalesce returns a string or number attribute as a string, attributes of other types return def
 func coalesce(s *dynamodb.AttributeValue, def string) string {
-	if s != nil {
+	switch {
+	case s == nil:
+		return def
+	case s.S != nil:
 		return *s.S
+	case s.N != nil:
+		return *s.N
 	}
 	return def
 }
@@ -149,6 +170,16 @@ func coalesces(ss ...string) string {
 	return ""
 }
 
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
 func cb(b *bool, def bool) bool {
 	if b != nil {
 		return *b
@@ -192,11 +223,7 @@ func generation(g *string) string {
 var idAlphabet = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 func generateId(prefix string, size int) string {
-	b := make([]rune, size)
-	for i := range b {
-		b[i] = idAlphabet[rand.Intn(len(idAlphabet))]
-	}
-	return prefix + string(b)
+	return prefix + randomRunes(idAlphabet, size)
 }
 
 func buildTemplate(name, section string, data interface{}) (string, error) {
@@ -301,11 +328,7 @@ func lastline(data []byte) string {
 var randomAlphabet = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz")
 
 func randomString(size int) string {
-	b := make([]rune, size)
-	for i := range b {
-		b[i] = randomAlphabet[rand.Intn(len(randomAlphabet))]
-	}
-	return string(b)
+	return randomRunes(randomAlphabet, size)
 }
 
 func recoverWith(f func(err error)) {
@@ -339,7 +362,7 @@ func retry(times int, interval time.Duration, fn func() error) error {
 		}
 
 		// add 20% jitter
-		time.Sleep(interval + time.Duration(rand.Intn(int(interval/20))))
+		time.Sleep(interval + time.Duration(jitter(int64(interval/20))))
 
 		i++
 
@@ -361,6 +384,23 @@ func (p *Provider) rackStack(name string) string {
 	return fmt.Sprintf("%s-%s", p.Rack, name)
 }
 
+// parseStackName is the inverse of rackStack, it returns the rack and app of a stack name
+// and whether the stack belongs to this rack; the rack stack itself returns an empty app
+// a stack of another rack whose name starts with this rack and a dash can not be told apart
+func (p *Provider) parseStackName(stackName string) (rack, app string, ok bool) {
+	if stackName == p.Rack {
+		return p.Rack, "", true
+	}
+
+	app = strings.TrimPrefix(stackName, p.Rack+"-")
+
+	if app == stackName || app == "" {
+		return "", "", false
+	}
+
+	return p.Rack, app, true
+}
+
 func stackParameters(stack *cloudformation.Stack) map[string]string {
 	parameters := make(map[string]string)
 
@@ -489,12 +529,87 @@ func upperName(name string) string {
  * AWS API HELPERS
  ****************************************************************************/
 
+// stackOnFailureActions are the actions cloudformation can take when a stack fails to create
+var stackOnFailureActions = []string{
+	cloudformation.OnFailureRollback,
+	cloudformation.OnFailureDoNothing,
+	cloudformation.OnFailureDelete,
+}
+
+func validateStackOnFailure(action string) error {
+	if action == "" || containsString(stackOnFailureActions, action) {
+		return nil
+	}
+
+	return fmt.Errorf("invalid stack on failure action %q, must be one of %s", action, strings.Join(stackOnFailureActions, ", "))
+}
+
+// cloudformationTopic returns the sns topic stacks send their events to
+// providers configured without one read it from the rack stack so stacks never lose their notifications
+func (p *Provider) cloudformationTopic() (string, error) {
+	topic := p.CloudformationTopic
+
+	if topic == "" {
+		if t, ok := cache.Get("cloudformationTopic", p.Rack).(string); ok {
+			return t, nil
+		}
+
+		t, err := p.rackResource("CloudformationTopic")
+		if err != nil {
+			return "", fmt.Errorf("cloudformation topic is not set and could not be read from rack %s: %s", p.Rack, err)
+		}
+
+		topic = t
+	}
+
+	if a, err := parseARN(topic); err != nil || a.Service != "sns" || a.Region == "" || a.Account == "" || a.ResourceType != "" {
+		return "", fmt.Errorf("cloudformation topic %q invalid, must be an sns topic arn", topic)
+	}
+
+	if p.CloudformationTopic == "" && !p.SkipCache {
+		if err := cache.Set("cloudformationTopic", p.Rack, topic, 1*time.Hour); err != nil {
+			return "", err
+		}
+	}
+
+	return topic, nil
+}
+
+// createStack leaves a stack that fails to create as it is when StackOnFailure is DO_NOTHING so it can be inspected
 func (p *Provider) createStack(name string, body []byte, params map[string]string, tags map[string]string) error {
+	if err := validateStackOnFailure(p.StackOnFailure); err != nil {
+		return err
+	}
+
+	topic, err := p.cloudformationTopic()
+	if err != nil {
+		return err
+	}
+
 	req := &cloudformation.CreateStackInput{
 		Capabilities:     []*string{aws.String("CAPABILITY_IAM")},
 		StackName:        aws.String(name),
-		TemplateBody:     aws.String(string(body)),
-		NotificationARNs: []*string{aws.String(p.CloudformationTopic)},
+		NotificationARNs: []*string{aws.String(topic)},
+	}
+
+	if err := validateTemplateSize(body); err != nil {
+		return err
+	}
+
+	// templates too large to send inline are read from s3 the way updateStack always does
+	if len(body) > templateBodyLimit {
+		tu, err := p.uploadTemplate(body)
+		if err != nil {
+			return err
+		}
+
+		req.TemplateURL = aws.String(tu)
+	} else {
+		req.TemplateBody = aws.String(string(body))
+	}
+
+	if p.StackOnFailure != "" {
+		req.OnFailure = aws.String(p.StackOnFailure)
 	}
 
 	for key, value := range params {
@@ -511,14 +626,102 @@ func (p *Provider) createStack(name string, body []byte, params map[string]strin
 		})
 	}
 
-	_, err := p.cloudformation().CreateStack(req)
-	if err != nil {
+	// the sdk retries a create that timed out with the same token, when the first attempt went
+	// through the retry is told the stack exists and the events show whether it is the one we made
+	token := idempotencyToken(name)
+
+	req.ClientRequestToken = aws.String(token)
+
+	if _, err := p.cloudformation().CreateStack(req); err != nil {
+		if awsError(err) == "AlreadyExistsException" && p.stackCreatedBy(name, token) {
+			return nil
+		}
+
 		return err
 	}
 
 	return nil
 }
 
+// stackCreatedBy returns true when the events of a stack show it was created by the request carrying token
+func (p *Provider) stackCreatedBy(name, token string) bool {
+	created := false
+
+	err := p.cloudformation().DescribeStackEventsPages(&cloudformation.DescribeStackEventsInput{
+		StackName: aws.String(name),
+	}, func(page *cloudformation.DescribeStackEventsOutput, last bool) bool {
+		for _, e := range page.StackEvents {
+			if aws.StringValue(e.ClientRequestToken) == token {
+				created = true
+				return false
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return false
+	}
+
+	return created
+}
+
+// idempotencyTokenLimit is the longest client token ecs accepts, cloudformation accepts up to 128 characters
+const idempotencyTokenLimit = 64
+
+var idempotencyTokenInvalid = regexp.MustCompile(`[^-a-zA-Z0-9]+`)
+
+// idempotencyToken returns a unique token that lets a create request be retried without creating twice
+// it starts with a letter and holds only letters, digits and dashes so both cloudformation and ecs accept it
+func idempotencyToken(prefix string) string {
+	prefix = strings.TrimLeft(idempotencyTokenInvalid.ReplaceAllString(prefix, "-"), "-0123456789")
+
+	if prefix == "" {
+		prefix = "convox"
+	}
+
+	suffix := randomRunes(passwordAlphabet, 20)
+
+	if max := idempotencyTokenLimit - len(suffix) - 1; len(prefix) > max {
+		prefix = prefix[:max]
+	}
+
+	return fmt.Sprintf("%s-%s", prefix, suffix)
+}
+
+const (
+	// templateBodyLimit is the largest template cloudformation accepts inline as a TemplateBody
+	templateBodyLimit = 51200
+
+	// templateURLLimit is the largest template cloudformation reads from s3 through a TemplateURL
+	templateURLLimit = 460800
+)
+
+// validateTemplateSize returns an error for templates cloudformation would refuse even from s3
+func validateTemplateSize(template []byte) error {
+	if len(template) > templateURLLimit {
+		return fmt.Errorf("template is %d bytes, cloudformation accepts at most %d", len(template), templateURLLimit)
+	}
+
+	return nil
+}
+
+// uploadTemplate stores a template in the settings bucket and returns the url cloudformation can read it from
+func (p *Provider) uploadTemplate(template []byte) (string, error) {
+	key := ""
+
+	if p.IsTest() {
+		key = "test-key"
+	}
+
+	ou, err := p.ObjectStore("", key, bytes.NewReader(template), structs.ObjectStoreOptions{ContentType: options.String("application/json")})
+	if err != nil {
+		return "", err
+	}
+
+	return p.objectURL(ou.Url)
+}
+
 func (p *Provider) dynamoBatchDeleteItems(wrs []*dynamodb.WriteRequest, tableName string) error {
 
 	if len(wrs) > 0 {
@@ -572,7 +775,7 @@ func (p *Provider) listAndDescribeContainerInstances() (*ecs.DescribeContainerIn
 			Cluster:   aws.String(p.Cluster),
 			NextToken: &nextToken,
 		})
-		if ae, ok := err.(awserr.Error); ok && ae.Code() == "ClusterNotFoundException" {
+		if isNotFound(err) {
 			return nil, fmt.Errorf("cluster not found: %s", p.Cluster)
 		}
 		if err != nil {
@@ -624,28 +827,128 @@ func (p *Provider) describeContainerInstances(input *ecs.DescribeContainerInstan
 	return res, nil
 }
 
-func (p *Provider) describeServices(input *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
-	res, ok := cache.Get("describeServices", input.Services).(*ecs.DescribeServicesOutput)
+// describeServicesChecked returns the services ecs found apart from the failures for the rest
+// a service deleted since it was listed comes back as a failure or as INACTIVE, both are left out of the found services
+// only found services are cached so a missing service is looked up again rather than served as still present
+func (p *Provider) describeServicesChecked(input *ecs.DescribeServicesInput) ([]*ecs.Service, []*ecs.Failure, error) {
+	services := []*ecs.Service{}
+	failures := []*ecs.Failure{}
+	uncached := []*string{}
 
-	if ok {
-		return res, nil
+	for _, id := range input.Services {
+		if s, ok := cache.Get("describeServices", aws.StringValue(id)).(*ecs.Service); ok {
+			services = append(services, s)
+		} else {
+			uncached = append(uncached, id)
+		}
+	}
+
+	if len(uncached) == 0 {
+		return services, failures, nil
 	}
 
-	res, err := p.ecs().DescribeServices(input)
+	req := *input
+	req.Services = uncached
 
+	res, err := p.ecs().DescribeServices(&req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	if !p.SkipCache {
-		if err := cache.Set("describeServices", input.Services, res, 5*time.Second); err != nil {
-			return nil, err
+	failures = append(failures, res.Failures...)
+
+	for _, s := range res.Services {
+		if aws.StringValue(s.Status) == "INACTIVE" {
+			failures = append(failures, &ecs.Failure{Arn: s.ServiceArn, Reason: aws.String("INACTIVE")})
+			continue
+		}
+
+		services = append(services, s)
+
+		if p.SkipCache {
+			continue
+		}
+
+		// services can be asked for by arn or by name
+		for _, id := range aws.StringValueSlice(uncached) {
+			if id == aws.StringValue(s.ServiceArn) || id == aws.StringValue(s.ServiceName) {
+				if err := cache.Set("describeServices", id, s, 5*time.Second); err != nil {
+					return nil, nil, err
+				}
+			}
 		}
 	}
 
-	return res, nil
+	return services, failures, nil
 }
 
+var (
+	stackCacheTTLLong      = 60 * time.Second
+	stackCacheTTLShort     = 5 * time.Second
+	stackNotificationLast  time.Time
+	stackNotificationLock  sync.Mutex
+	stackNotificationStale = 10 * time.Minute
+)
+
+// stackCacheTTL returns the long ttl while stack notifications are arriving to invalidate
+// the stack caches and falls back to the short ttl when they have gone quiet
+func stackCacheTTL() time.Duration {
+	stackNotificationLock.Lock()
+	defer stackNotificationLock.Unlock()
+
+	if time.Since(stackNotificationLast) > stackNotificationStale {
+		return stackCacheTTLShort
+	}
+
+	return stackCacheTTLLong
+}
+
+// invalidateStack clears all cached data describing a stack
+// stacks are described by name or by arn so entries under either are cleared whichever one is given
+func (p *Provider) invalidateStack(name string) {
+	name = stackNameFromId(name)
+
+	stack := func(key string) bool {
+		return stackNameFromId(key) == name
+	}
+
+	cache.Clear("describeStacks", nil)
+	cache.ClearMatch("describeStacks", stack)
+	cache.ClearMatch("describeStackEvents", stack)
+	cache.ClearMatch("describeStackResources", stack)
+	cache.ClearMatch("listStackResources", stack)
+}
+
+// stackNameFromId returns the name of the stack a stack arn refers to, anything else is returned as is
+func stackNameFromId(id string) string {
+	if m := regexpStackID.FindStringSubmatch(id); len(m) == 5 {
+		return m[3]
+	}
+
+	return id
+}
+
+var (
+	describeStacksPageRetries  = 3
+	describeStacksPageInterval = 2 * time.Second
+)
+
+// describeStacksPartialError is returned when pagination fails after some stacks were fetched
+type describeStacksPartialError struct {
+	Err    error
+	Stacks []*cloudformation.Stack
+}
+
+func (e describeStacksPartialError) Error() string {
+	return fmt.Sprintf("could not describe stacks after %d stacks: %s", len(e.Stacks), e.Err)
+}
+
+func (e describeStacksPartialError) Unwrap() error {
+	return e.Err
+}
+
+// describeStacks fetches every page of stacks, a page that fails with a transient error is retried
+// from its own token so the pages already fetched are kept
 func (p *Provider) describeStacks(input *cloudformation.DescribeStacksInput) ([]*cloudformation.Stack, error) {
 	var stacks []*cloudformation.Stack
 	stacks, ok := cache.Get("describeStacks", input.StackName).([]*cloudformation.Stack)
@@ -654,21 +957,38 @@ func (p *Provider) describeStacks(input *cloudformation.DescribeStacksInput) ([]
 		return stacks, nil
 	}
 
-	err := p.cloudformation().DescribeStacksPages(input,
-		func(page *cloudformation.DescribeStacksOutput, lastPage bool) bool {
-			for _, stack := range page.Stacks {
-				stacks = append(stacks, stack)
+	req := *input
+
+	for {
+		var res *cloudformation.DescribeStacksOutput
+		var err error
+
+		for i := 0; ; i++ {
+			res, err = p.cloudformation().DescribeStacks(&req)
+			if err == nil || i >= describeStacksPageRetries || !(request.IsErrorRetryable(err) || request.IsErrorThrottle(err)) {
+				break
 			}
-			return true
-		},
-	)
 
-	if err != nil {
-		return nil, err
+			time.Sleep(describeStacksPageInterval)
+		}
+		if err != nil && len(stacks) > 0 {
+			return nil, describeStacksPartialError{Err: err, Stacks: stacks}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		stacks = append(stacks, res.Stacks...)
+
+		if res.NextToken == nil {
+			break
+		}
+
+		req.NextToken = res.NextToken
 	}
 
 	if !p.SkipCache {
-		if err := cache.Set("describeStacks", input.StackName, stacks, 5*time.Second); err != nil {
+		if err := cache.Set("describeStacks", input.StackName, stacks, stackCacheTTL()); err != nil {
 			return nil, err
 		}
 	}
@@ -680,7 +1000,7 @@ func (p *Provider) describeStack(name string) (*cloudformation.Stack, error) {
 	stacks, err := p.describeStacks(&cloudformation.DescribeStacksInput{
 		StackName: aws.String(name),
 	})
-	if ae, ok := err.(awserr.Error); ok && ae.Code() == "ValidationError" {
+	if isNotFound(err) {
 		return nil, fmt.Errorf("stack not found: %s", name)
 	}
 	if err != nil {
@@ -800,7 +1120,7 @@ func (p *Provider) listStackResources(stack string) ([]*cloudformation.StackReso
 	}
 
 	if !p.SkipCache {
-		if err := cache.Set("listStackResources", stack, srs, 5*time.Second); err != nil {
+		if err := cache.Set("listStackResources", stack, srs, stackCacheTTL()); err != nil {
 			return nil, err
 		}
 	}
@@ -808,19 +1128,24 @@ func (p *Provider) listStackResources(stack string) ([]*cloudformation.StackReso
 	return srs, nil
 }
 
+// appOutput returns the value of an output of an app stack, or ErrOutputNotFound if the stack has no such output
 func (p *Provider) appOutput(app, output string) (string, error) {
-	s, err := p.describeStack(p.rackStack(app))
+	return p.stackOutput(p.rackStack(app), output)
+}
+
+// stackOutput returns the value of an output of a stack, or ErrOutputNotFound if the stack has no such output
+func (p *Provider) stackOutput(stack, output string) (string, error) {
+	s, err := p.describeStack(stack)
 	if err != nil {
 		return "", err
 	}
 
-	for k, v := range stackOutputs(s) {
-		if k == output {
-			return v, nil
-		}
+	v, ok := stackOutputs(s)[output]
+	if !ok {
+		return "", errorNotFoundOf(ErrOutputNotFound, output)
 	}
 
-	return "", nil
+	return v, nil
 }
 
 func (p *Provider) rackResource(resource string) (string, error) {
@@ -832,6 +1157,20 @@ func (p *Provider) rackResource(resource string) (string, error) {
 	return *res.PhysicalResourceId, nil
 }
 
+// rackBalancerDNS returns the dns name of the elb in front of the rack api
+// racks that route the api through the router have no such balancer and return ErrResourceNotFound
+func (p *Provider) rackBalancerDNS() (string, error) {
+	if _, err := p.rackResource("Balancer"); err != nil {
+		if errors.Is(err, ErrResourceNotFound) {
+			return "", errorWithCode{code: 404, error: fmt.Errorf("rack %s has no api balancer: %w", p.Rack, err)}
+		}
+
+		return "", err
+	}
+
+	return p.stackOutput(p.Rack, "BalancerHost")
+}
+
 func (p *Provider) appResource(app, resource string) (string, error) {
 	res, err := p.stackResource(fmt.Sprintf("%s-%s", p.Rack, app), resource)
 	if err != nil {
@@ -846,7 +1185,7 @@ func (p *Provider) stackResource(stack, resource string) (*cloudformation.StackR
 
 	srs, err := p.listStackResources(stack)
 	if err != nil {
-		return nil, log.Error(err)
+		return nil, logAWSError(log, err)
 	}
 
 	for _, sr := range srs {
@@ -855,7 +1194,7 @@ func (p *Provider) stackResource(stack, resource string) (*cloudformation.StackR
 		}
 	}
 
-	return nil, fmt.Errorf("resource not found: %s", resource)
+	return nil, errorNotFoundOf(ErrResourceNotFound, resource)
 }
 
 func (p *Provider) appResources(app string) (map[string]string, error) {
@@ -875,6 +1214,43 @@ func (p *Provider) appResources(app string) (map[string]string, error) {
 	return rs, nil
 }
 
+// appResourcesRecursive returns the resources of an app stack including those of any nested stacks
+// resources inside a nested stack are keyed by their logical id prefixed with the nested stack's, e.g. Database.Instance
+func (p *Provider) appResourcesRecursive(app string) (map[string]string, error) {
+	rs := map[string]string{}
+
+	if err := p.stackResourcesRecursive(p.rackStack(app), "", rs); err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+func (p *Provider) stackResourcesRecursive(stack, prefix string, rs map[string]string) error {
+	srs, err := p.listStackResources(stack)
+	if err != nil {
+		return err
+	}
+
+	for _, sr := range srs {
+		if sr.LogicalResourceId == nil || sr.PhysicalResourceId == nil {
+			continue
+		}
+
+		id := prefix + *sr.LogicalResourceId
+
+		rs[id] = *sr.PhysicalResourceId
+
+		if cs(sr.ResourceType, "") == "AWS::CloudFormation::Stack" {
+			if err := p.stackResourcesRecursive(*sr.PhysicalResourceId, id+".", rs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func (p *Provider) stackParameter(stack, param string) (string, error) {
 	res, err := p.describeStack(stack)
 	if err != nil {
@@ -977,13 +1353,15 @@ func (p *Provider) dockerClientFromPid(pid string) (*docker.Client, error) {
 }
 
 func (p *Provider) describeTaskDefinition(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
-	td, ok := cache.Get("describeTaskDefinition", input).(*ecs.DescribeTaskDefinitionOutput)
+	key := taskDefinitionCacheKey(input)
+
+	td, ok := cache.Get("describeTaskDefinition", key).(*ecs.DescribeTaskDefinitionOutput)
 	if ok {
 		return td, nil
 	}
 
 	res, err := p.ecs().DescribeTaskDefinition(input)
-	if ae, ok := err.(awserr.Error); ok && ae.Code() == "ValidationError" {
+	if isNotFound(err) {
 		return nil, fmt.Errorf("task definition not found: %s", *input.TaskDefinition)
 	}
 	if err != nil {
@@ -991,7 +1369,7 @@ func (p *Provider) describeTaskDefinition(input *ecs.DescribeTaskDefinitionInput
 	}
 
 	if !p.SkipCache {
-		if err := cache.Set("describeTaskDefinition", input, res, 24*time.Hour); err != nil {
+		if err := cache.Set("describeTaskDefinition", key, res, 24*time.Hour); err != nil {
 			return nil, err
 		}
 	}
@@ -999,6 +1377,16 @@ func (p *Provider) describeTaskDefinition(input *ecs.DescribeTaskDefinitionInput
 	return res, nil
 }
 
+// taskDefinitionCacheKey is the arn of the task definition when the input has one as arns are immutable
+// families and family:revision references fall back to the whole input
+func taskDefinitionCacheKey(input *ecs.DescribeTaskDefinitionInput) interface{} {
+	if td := cs(input.TaskDefinition, ""); strings.HasPrefix(td, "arn:") {
+		return td
+	}
+
+	return input
+}
+
 func (p *Provider) describeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
 	res, ok := cache.Get("describeTasks", input).(*ecs.DescribeTasksOutput)
 
@@ -1070,7 +1458,7 @@ func (p *Provider) putLogEvents(req *cloudwatchlogs.PutLogEventsInput) (string,
 				return "", err
 			}
 		}
-		if awsError(err) == "ResourceNotFoundException" {
+		if isNotFound(err) {
 			_, err := p.cloudwatchlogs().CreateLogStream(&cloudwatchlogs.CreateLogStreamInput{
 				LogGroupName:  req.LogGroupName,
 				LogStreamName: req.LogStreamName,
@@ -1102,24 +1490,49 @@ func (p *Provider) putLogEvents(req *cloudwatchlogs.PutLogEventsInput) (string,
 	}
 }
 
+// serviceArn returns the arn of the ecs service that runs a service of an app
+// it is ErrServiceNotFound if the app stack does not declare the service and ErrServiceNotReady if it does
+// but the ecs service has not been created yet
 func (p *Provider) serviceArn(app, service string) (string, error) {
-	sarn, err := p.appOutput(app, fmt.Sprintf("Service%sService", upperName(service)))
+	srs, err := p.listStackResources(p.rackStack(app))
 	if err != nil {
 		return "", err
 	}
-	if sarn != "" {
-		return sarn, nil
-	}
 
-	sarn, err = p.appResource(app, fmt.Sprintf("Service%sService", upperName(service)))
-	if err != nil && !strings.HasPrefix(err.Error(), "resource not found") {
-		return "", err
-	}
-	if sarn != "" {
-		return sarn, nil
+	return p.serviceArnFromResources(app, service, srs)
+}
+
+// serviceArnFromResources is serviceArn for callers that have already listed the resources of the app stack
+// generation 1 stacks hold the ecs service as the Service<Name> resource, generation 2 stacks nest it in the
+// Service<Name> stack and export its arn as the Service<Name>Service output once that stack is created
+func (p *Provider) serviceArnFromResources(app, service string, srs []*cloudformation.StackResourceSummary) (string, error) {
+	id := fmt.Sprintf("Service%s", upperName(service))
+
+	for _, sr := range srs {
+		if aws.StringValue(sr.LogicalResourceId) != id {
+			continue
+		}
+
+		if aws.StringValue(sr.ResourceType) == "AWS::CloudFormation::Stack" {
+			arn, err := p.appOutput(app, fmt.Sprintf("%sService", id))
+			if err != nil && !errors.Is(err, ErrOutputNotFound) {
+				return "", err
+			}
+			if arn == "" {
+				return "", fmt.Errorf("%w: %s", ErrServiceNotReady, service)
+			}
+
+			return arn, nil
+		}
+
+		if arn := aws.StringValue(sr.PhysicalResourceId); arn != "" {
+			return arn, nil
+		}
+
+		return "", fmt.Errorf("%w: %s", ErrServiceNotReady, service)
 	}
 
-	return "", nil
+	return "", errorNotFoundOf(ErrServiceNotFound, service)
 }
 
 func (p *Provider) s3Exists(bucket, key string) (bool, error) {
@@ -1129,7 +1542,7 @@ func (p *Provider) s3Exists(bucket, key string) (bool, error) {
 	})
 
 	if err != nil {
-		if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() == 404 {
+		if isNotFound(err) {
 			return false, nil
 		}
 
@@ -1165,19 +1578,138 @@ func (p *Provider) s3Delete(bucket, key string) error {
 	return err
 }
 
+var (
+	// s3PutMultipartThreshold is the object size above which s3Put switches to a multipart upload
+	s3PutMultipartThreshold = int64(s3manager.DefaultUploadPartSize)
+	s3UploadPartSize        = int64(s3manager.DefaultUploadPartSize)
+	s3UploadConcurrency     = s3manager.DefaultUploadConcurrency
+)
+
+// s3PutOptions are the http headers stored with an object and returned whenever it is fetched
+// and the tags set on it in the same request
+type s3PutOptions struct {
+	CacheControl       *string
+	ContentDisposition *string
+	ContentType        *string
+	Public             bool
+	Tags               map[string]string
+}
+
+const (
+	s3TagLimit       = 10
+	s3TagKeyLimit    = 128
+	s3TagValueLimit  = 256
+	s3TagReservedKey = "aws:"
+)
+
+var regexpS3Tag = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// s3Tagging validates tags against the s3 object tag limits and encodes them for the x-amz-tagging header
+func s3Tagging(tags map[string]string) (*string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	if len(tags) > s3TagLimit {
+		return nil, fmt.Errorf("objects can have at most %d tags", s3TagLimit)
+	}
+
+	keys := []string{}
+
+	for k, v := range tags {
+		switch {
+		case k == "" || utf8.RuneCountInString(k) > s3TagKeyLimit:
+			return nil, fmt.Errorf("tag key must be between 1 and %d characters: %q", s3TagKeyLimit, k)
+		case strings.HasPrefix(strings.ToLower(k), s3TagReservedKey):
+			return nil, fmt.Errorf("tag key can not start with %s: %q", s3TagReservedKey, k)
+		case !regexpS3Tag.MatchString(k):
+			return nil, fmt.Errorf("tag key has invalid characters: %q", k)
+		case utf8.RuneCountInString(v) > s3TagValueLimit:
+			return nil, fmt.Errorf("tag value for %s must be at most %d characters", k, s3TagValueLimit)
+		case !regexpS3Tag.MatchString(v):
+			return nil, fmt.Errorf("tag value for %s has invalid characters: %q", k, v)
+		}
+
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+
+	// s3 reads the header as a query string, spaces are sent as %20 rather than +
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%s", s3TagEscape(k), s3TagEscape(tags[k]))
+	}
+
+	return aws.String(strings.Join(parts, "&")), nil
+}
+
+func s3TagEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
 func (p *Provider) s3Put(bucket, key string, data []byte, public bool) error {
+	return p.s3PutWithOptions(bucket, key, data, s3PutOptions{Public: public})
+}
+
+func (p *Provider) s3PutWithOptions(bucket, key string, data []byte, opts s3PutOptions) error {
+	if int64(len(data)) > s3PutMultipartThreshold {
+		return p.s3PutLarge(bucket, key, data, opts, s3UploadPartSize, s3UploadConcurrency)
+	}
+
+	tagging, err := s3Tagging(opts.Tags)
+	if err != nil {
+		return err
+	}
+
 	req := &s3.PutObjectInput{
-		Body:          bytes.NewReader(data),
-		Bucket:        aws.String(bucket),
-		ContentLength: aws.Int64(int64(len(data))),
-		Key:           aws.String(key),
+		Body:               bytes.NewReader(data),
+		Bucket:             aws.String(bucket),
+		CacheControl:       opts.CacheControl,
+		ContentDisposition: opts.ContentDisposition,
+		ContentLength:      aws.Int64(int64(len(data))),
+		ContentType:        opts.ContentType,
+		Key:                aws.String(key),
+		Tagging:            tagging,
 	}
 
-	if public {
+	if opts.Public {
 		req.ACL = aws.String("public-read")
 	}
 
-	_, err := p.s3().PutObject(req)
+	_, err = p.s3().PutObject(req)
+
+	return err
+}
+
+// s3PutLarge uploads an object in parts of partSize bytes with up to concurrency parts in flight
+func (p *Provider) s3PutLarge(bucket, key string, data []byte, opts s3PutOptions, partSize int64, concurrency int) error {
+	tagging, err := s3Tagging(opts.Tags)
+	if err != nil {
+		return err
+	}
+
+	up := s3manager.NewUploaderWithClient(p.s3(), func(u *s3manager.Uploader) {
+		u.PartSize = partSize
+		u.Concurrency = concurrency
+	})
+
+	req := &s3manager.UploadInput{
+		Body:               bytes.NewReader(data),
+		Bucket:             aws.String(bucket),
+		CacheControl:       opts.CacheControl,
+		ContentDisposition: opts.ContentDisposition,
+		ContentType:        opts.ContentType,
+		Key:                aws.String(key),
+		Tagging:            tagging,
+	}
+
+	if opts.Public {
+		req.ACL = aws.String("public-read")
+	}
+
+	_, err = up.Upload(req)
 
 	return err
 }
@@ -1244,10 +1776,15 @@ func (p *Provider) updateStack(name string, template []byte, changes map[string]
 	cache.Clear("describeStacks", nil)
 	cache.Clear("describeStacks", name)
 
+	topic, err := p.cloudformationTopic()
+	if err != nil {
+		return err
+	}
+
 	req := &cloudformation.UpdateStackInput{
 		Capabilities:     []*string{aws.String("CAPABILITY_IAM")},
 		StackName:        aws.String(name),
-		NotificationARNs: []*string{aws.String(p.CloudformationTopic)},
+		NotificationARNs: []*string{aws.String(topic)},
 	}
 
 	if id != "" {
@@ -1267,18 +1804,11 @@ func (p *Provider) updateStack(name string, template []byte, changes map[string]
 	}
 
 	if template != nil {
-		key := ""
-
-		if p.IsTest() {
-			key = "test-key"
-		}
-
-		ou, err := p.ObjectStore("", key, bytes.NewReader(template), structs.ObjectStoreOptions{})
-		if err != nil {
+		if err := validateTemplateSize(template); err != nil {
 			return err
 		}
 
-		tu, err := p.objectURL(ou.Url)
+		tu, err := p.uploadTemplate(template)
 		if err != nil {
 			return err
 		}
@@ -1349,6 +1879,30 @@ func (p *Provider) updateStack(name string, template []byte, changes map[string]
 	return err
 }
 
+// diffParameters compares a set of parameter changes against the existing stack parameters
+// each entry holds the old and new value; removed parameters are those being changed to blank
+func diffParameters(existing, changes map[string]string) (added, removed, modified map[string][2]string) {
+	added = map[string][2]string{}
+	removed = map[string][2]string{}
+	modified = map[string][2]string{}
+
+	for key, value := range changes {
+		old, ok := existing[key]
+
+		switch {
+		case !ok:
+			added[key] = [2]string{"", value}
+		case old == value:
+		case value == "":
+			removed[key] = [2]string{old, ""}
+		default:
+			modified[key] = [2]string{old, value}
+		}
+	}
+
+	return added, removed, modified
+}
+
 var (
 	serverCertificateWaitConfirmations = 3
 	serverCertificateWaitTick          = 5 * time.Second
@@ -1432,6 +1986,37 @@ func generateSelfSignedCertificate(host string) ([]byte, []byte, error) {
 	return pub, key, nil
 }
 
+// internalCertificatePrefix is the name prefix of the self-signed certificates the rack generates
+func (p *Provider) internalCertificatePrefix() string {
+	return fmt.Sprintf("cert-%s-", p.Rack)
+}
+
+// uploadSelfSignedCertificate generates a self-signed certificate for host, uploads it to iam
+// and waits for it to propagate, returning its arn
+func (p *Provider) uploadSelfSignedCertificate(host string) (string, error) {
+	name := fmt.Sprintf("%s%d-%05d", p.internalCertificatePrefix(), time.Now().Unix(), randomInt(100000))
+
+	body, key, err := generateSelfSignedCertificate(host)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := p.iam().UploadServerCertificate(&iam.UploadServerCertificateInput{
+		CertificateBody:       aws.String(string(body)),
+		PrivateKey:            aws.String(string(key)),
+		ServerCertificateName: aws.String(name),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := p.waitForServerCertificate(name); err != nil {
+		return "", err
+	}
+
+	return *res.ServerCertificateMetadata.Arn, nil
+}
+
 type CronJob struct {
 	Name     string `yaml:"name"`
 	Schedule string `yaml:"schedule"`
@@ -1449,15 +2034,19 @@ func appCronJobs(a *structs.App, m *manifest1.Manifest) CronJobs {
 		return cronjobs
 	}
 
-	for _, entry := range m.Services {
-		labels := entry.LabelsByPrefix("convox.cron")
-		for key, value := range labels {
-			cronjob := NewCronJobFromLabel(key, value)
-			e := entry
-			cronjob.Service = &e
-			cronjob.App = a
-			cronjobs = append(cronjobs, cronjob)
+	for _, t := range m.Timers() {
+		s, err := m.Service(t.Service)
+		if err != nil {
+			continue
 		}
+
+		cronjobs = append(cronjobs, CronJob{
+			Name:     t.Name,
+			Schedule: t.Schedule,
+			Command:  t.Command,
+			Service:  s,
+			App:      a,
+		})
 	}
 
 	return cronjobs
@@ -1470,15 +2059,23 @@ func (a CronJobs) Less(i, j int) bool { return a[i].Name < a[j].Name }
 func NewCronJobFromLabel(key, value string) CronJob {
 	keySlice := strings.Split(key, ".")
 	name := keySlice[len(keySlice)-1]
-	tokens := strings.Fields(value)
+	schedule, command, err := manifest.SplitSchedule(value)
+	if err != nil {
+		schedule = value
+	}
 	cronjob := CronJob{
 		Name:     name,
-		Schedule: fmt.Sprintf("cron(%s *)", strings.Join(tokens[0:5], " ")),
-		Command:  strings.Join(tokens[5:], " "),
+		Schedule: schedule,
+		Command:  command,
 	}
 	return cronjob
 }
 
+// ScheduleExpression returns the cloudwatch events expression for the schedule as written in the label
+func (cr *CronJob) ScheduleExpression() (string, error) {
+	return manifest.ScheduleExpression(cr.Schedule)
+}
+
 func (cr *CronJob) AppName() string {
 	return cr.App.Name
 }
@@ -1509,3 +2106,92 @@ func (cr *CronJob) LongName() string {
 	}
 	return prefix + suffix
 }
+
+// cronRulePrefix returns the name prefix shared by the schedule rules of an app's cron jobs
+func cronRulePrefix(app string) string {
+	prefix := fmt.Sprintf("%s-%s-", os.Getenv("RACK"), app)
+
+	// LongName truncates to 47 characters to leave room for its hash suffix
+	if len(prefix) > 47 {
+		prefix = prefix[:47]
+	}
+
+	return prefix
+}
+
+// orphanedCronRules returns the deployed schedule rules that no longer belong to one of jobs
+func orphanedCronRules(jobs CronJobs, deployed []string) []string {
+	current := map[string]bool{}
+
+	for _, j := range jobs {
+		current[fmt.Sprintf("%s-schedule", j.LongName())] = true
+	}
+
+	orphans := []string{}
+
+	for _, name := range deployed {
+		if strings.HasSuffix(name, "-schedule") && !current[name] {
+			orphans = append(orphans, name)
+		}
+	}
+
+	sort.Strings(orphans)
+
+	return orphans
+}
+
+// orphanedCronRuleNames lists the schedule rules deployed for an app and returns the ones not in jobs
+func (p *Provider) orphanedCronRuleNames(app string, jobs CronJobs) ([]string, error) {
+	deployed := []string{}
+
+	req := &cloudwatchevents.ListRulesInput{
+		NamePrefix: aws.String(cronRulePrefix(app)),
+	}
+
+	for {
+		res, err := p.cloudwatchevents().ListRules(req)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range res.Rules {
+			deployed = append(deployed, cs(r.Name, ""))
+		}
+
+		if res.NextToken == nil {
+			break
+		}
+
+		req.NextToken = res.NextToken
+	}
+
+	return orphanedCronRules(jobs, deployed), nil
+}
+
+type cronContainerOverride struct {
+	Name    string   `json:"name"`
+	Command []string `json:"command"`
+}
+
+type cronTargetInput struct {
+	ContainerOverrides []cronContainerOverride `json:"containerOverrides"`
+}
+
+// TargetInput renders the ECS RunTask overrides for an EventBridge target that runs this job
+func (cr *CronJob) TargetInput() (string, error) {
+	argv, err := shellquote.Split(cr.Command)
+	if err != nil {
+		return "", fmt.Errorf("invalid command for cron job %s: %s", cr.Name, err)
+	}
+
+	data, err := json.Marshal(cronTargetInput{
+		ContainerOverrides: []cronContainerOverride{
+			{Name: cr.Process(), Command: argv},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
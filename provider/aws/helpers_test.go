@@ -0,0 +1,789 @@
+package aws_test
+
+import (
+	stderrors "errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/convox/rack/pkg/cache"
+	"github.com/convox/rack/pkg/manifest1"
+	"github.com/convox/rack/pkg/structs"
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAwsError(t *testing.T) {
+	ae := awserr.New("ThrottlingException", "rate exceeded", nil)
+
+	assert.Equal(t, "ThrottlingException", aws.AwsError(ae))
+	assert.Equal(t, "", aws.AwsError(fmt.Errorf("not an aws error")))
+	assert.Equal(t, "", aws.AwsError(nil))
+}
+
+func TestAwsErrorWrapped(t *testing.T) {
+	ae := awserr.New("ResourceNotFoundException", "log stream not found", nil)
+
+	err := fmt.Errorf("outer: %w", fmt.Errorf("inner: %w", ae))
+
+	assert.Equal(t, "ResourceNotFoundException", aws.AwsError(err))
+}
+
+func TestClassifyAWSError(t *testing.T) {
+	tests := []struct {
+		Name string
+		Err  error
+		Info *aws.AWSErrorInfo
+	}{
+		{"Nil", nil, nil},
+		{"Plain", fmt.Errorf("not an aws error"), nil},
+		{
+			"Code",
+			awserr.New("ValidationError", "Stack with id convox-httpd does not exist", nil),
+			&aws.AWSErrorInfo{Code: "ValidationError", Message: "Stack with id convox-httpd does not exist"},
+		},
+		{
+			"RequestFailure",
+			awserr.NewRequestFailure(awserr.New("NoSuchKey", "The specified key does not exist.", nil), 404, "req-1"),
+			&aws.AWSErrorInfo{Code: "NoSuchKey", Message: "The specified key does not exist.", StatusCode: 404, RequestID: "req-1"},
+		},
+		{
+			"Throttle",
+			awserr.NewRequestFailure(awserr.New("ThrottlingException", "Rate exceeded", nil), 400, "req-2"),
+			&aws.AWSErrorInfo{Code: "ThrottlingException", Message: "Rate exceeded", StatusCode: 400, RequestID: "req-2", Retryable: true, Throttle: true},
+		},
+		{
+			"ServerError",
+			awserr.NewRequestFailure(awserr.New("InternalFailure", "internal error", nil), 500, "req-3"),
+			&aws.AWSErrorInfo{Code: "InternalFailure", Message: "internal error", StatusCode: 500, RequestID: "req-3", Retryable: true},
+		},
+		{
+			"Wrapped",
+			errors.Wrap(fmt.Errorf("describe: %w", awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), 403, "req-4")), "app get"),
+			&aws.AWSErrorInfo{Code: "AccessDenied", Message: "Access Denied", StatusCode: 403, RequestID: "req-4"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Info, aws.ClassifyAWSError(tt.Err))
+		})
+	}
+}
+
+func TestAWSErrorPredicates(t *testing.T) {
+	tests := []struct {
+		Name         string
+		Err          error
+		NotFound     bool
+		Throttle     bool
+		AccessDenied bool
+		Conflict     bool
+	}{
+		{Name: "Plain", Err: fmt.Errorf("not found")},
+		{Name: "StackMissing", Err: awserr.New("ValidationError", "Stack with id convox-httpd does not exist", nil), NotFound: true},
+		{Name: "StackInvalid", Err: awserr.New("ValidationError", "Template format error", nil)},
+		{Name: "LogStreamMissing", Err: awserr.New("ResourceNotFoundException", "The specified log stream does not exist.", nil), NotFound: true},
+		{Name: "HeadObject", Err: awserr.NewRequestFailure(awserr.New("NotFound", "Not Found", nil), 404, "req"), NotFound: true},
+		{Name: "Throttling", Err: awserr.New("Throttling", "Rate exceeded", nil), Throttle: true},
+		{Name: "TooManyRequests", Err: awserr.NewRequestFailure(awserr.New("TooManyRequestsException", "slow down", nil), 429, "req"), Throttle: true},
+		{Name: "AccessDeniedException", Err: awserr.New("AccessDeniedException", "not authorized", nil), AccessDenied: true},
+		{Name: "Forbidden", Err: awserr.NewRequestFailure(awserr.New("Forbidden", "Forbidden", nil), 403, "req"), AccessDenied: true},
+		{Name: "AlreadyExists", Err: awserr.New("AlreadyExistsException", "Stack [convox-httpd] already exists", nil), Conflict: true},
+		{Name: "ConditionalCheck", Err: awserr.New("ConditionalCheckFailedException", "The conditional request failed", nil), Conflict: true},
+		{Name: "WrappedNotFound", Err: errors.Wrap(awserr.New("NoSuchKey", "missing", nil), "s3 get"), NotFound: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.NotFound, aws.IsNotFound(tt.Err), "not found")
+			assert.Equal(t, tt.Throttle, aws.IsThrottle(tt.Err), "throttle")
+			assert.Equal(t, tt.AccessDenied, aws.IsAccessDenied(tt.Err), "access denied")
+			assert.Equal(t, tt.Conflict, aws.IsConflict(tt.Err), "conflict")
+		})
+	}
+}
+
+func TestCertificateFriendlyId(t *testing.T) {
+	tests := []struct {
+		Arn   string
+		Id    string
+		Error string
+	}{
+		{"arn:aws:acm:us-east-1:123456789012:certificate/12345678-1234-1234-1234-123456789012", "acm-123456789012", ""},
+		{"arn:aws-us-gov:acm:us-gov-west-1:123456789012:certificate/abcdef01-2345-6789-abcd-ef0123456789", "acm-ef0123456789", ""},
+		{"arn:aws:iam::123456789012:server-certificate/example", "example", ""},
+		{"arn:aws:iam::123456789012:server-certificate/cloudfront/prod/example", "example", ""},
+		{"arn:aws:acm:us-east-1:123456789012:certificate/", "", "invalid acm certificate arn: arn:aws:acm:us-east-1:123456789012:certificate/"},
+		{"arn:aws:acm:us-east-1:123456789012:other/12345678", "", "invalid acm certificate arn: arn:aws:acm:us-east-1:123456789012:other/12345678"},
+		{"arn:aws:iam::123456789012:server-certificate", "", "invalid iam server certificate arn: arn:aws:iam::123456789012:server-certificate"},
+		{"arn:aws:iam::123456789012:user/example", "", "invalid iam server certificate arn: arn:aws:iam::123456789012:user/example"},
+		{"arn:aws:s3:::bucket/cert", "", "unsupported certificate arn: arn:aws:s3:::bucket/cert"},
+		{"not-an-arn", "", "invalid certificate arn: not-an-arn"},
+		{"", "", "invalid certificate arn: "},
+	}
+
+	for _, test := range tests {
+		id, err := aws.CertificateFriendlyId(test.Arn)
+
+		if test.Error != "" {
+			assert.EqualError(t, err, test.Error, test.Arn)
+		} else {
+			assert.NoError(t, err, test.Arn)
+		}
+
+		assert.Equal(t, test.Id, id, test.Arn)
+	}
+}
+
+func TestDiffParameters(t *testing.T) {
+	existing := map[string]string{
+		"Count":    "1",
+		"Password": "secret",
+		"Version":  "20200101",
+	}
+
+	changes := map[string]string{
+		"Count":    "1",
+		"Password": "",
+		"Private":  "Yes",
+		"Version":  "20200202",
+	}
+
+	added, removed, modified := aws.DiffParameters(existing, changes)
+
+	assert.Equal(t, map[string][2]string{"Private": {"", "Yes"}}, added)
+	assert.Equal(t, map[string][2]string{"Password": {"secret", ""}}, removed)
+	assert.Equal(t, map[string][2]string{"Version": {"20200101", "20200202"}}, modified)
+}
+
+func TestCronJobTargetInput(t *testing.T) {
+	cj := aws.NewCronJobFromLabel("convox.cron.cleanup", `*/10 * * * ? bin/cleanup --message "hello world" --name 'a b'`)
+	cj.Service = &manifest1.Service{Name: "worker"}
+
+	input, err := cj.TargetInput()
+	require.NoError(t, err)
+	assert.Equal(t, `{"containerOverrides":[{"name":"worker","command":["bin/cleanup","--message","hello world","--name","a b"]}]}`, input)
+
+	cj = aws.NewCronJobFromLabel("convox.cron.broken", `*/10 * * * ? bin/cleanup "unterminated`)
+	cj.Service = &manifest1.Service{Name: "worker"}
+
+	_, err = cj.TargetInput()
+	require.EqualError(t, err, "invalid command for cron job broken: Unterminated double-quoted string")
+}
+
+func TestCronJobScheduleExpression(t *testing.T) {
+	tests := []struct {
+		Label      string
+		Expression string
+		Command    string
+	}{
+		{"*/10 * * * ? bin/cleanup", "cron(*/10 * * * ? *)", "bin/cleanup"},
+		{"rate: 1 minute bin/cleanup", "rate(1 minute)", "bin/cleanup"},
+		{"@every 15m bin/cleanup --all", "rate(15 minutes)", "bin/cleanup --all"},
+		{"at: 2100-01-02T15:04:05Z bin/cleanup", "at(2100-01-02T15:04:05)", "bin/cleanup"},
+	}
+
+	for _, test := range tests {
+		cj := aws.NewCronJobFromLabel("convox.cron.cleanup", test.Label)
+
+		expr, err := cj.ScheduleExpression()
+		require.NoError(t, err, test.Label)
+		assert.Equal(t, test.Expression, expr, test.Label)
+		assert.Equal(t, test.Command, cj.Command, test.Label)
+	}
+}
+
+func TestDescribeStacksPageRetry(t *testing.T) {
+	aws.SetDescribeStacksPageInterval(1 * time.Millisecond)
+	defer aws.SetDescribeStacksPageInterval(2 * time.Second)
+
+	provider := StubAwsProvider(
+		cycleDescribeStacksPage("", "page2", "convox"),
+		cycleDescribeStacksPageError("page2", 500, "InternalFailure"),
+		cycleDescribeStacksPage("page2", "", "convox-httpd", "convox-worker"),
+	)
+	defer provider.Close()
+
+	stacks, err := provider.DescribeStacks(&cloudformation.DescribeStacksInput{})
+	require.NoError(t, err)
+
+	names := []string{}
+
+	for _, s := range stacks {
+		names = append(names, *s.StackName)
+	}
+
+	assert.Equal(t, []string{"convox", "convox-httpd", "convox-worker"}, names)
+}
+
+func TestDescribeStacksPartialFailure(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleDescribeStacksPage("", "page2", "convox"),
+		cycleDescribeStacksPageError("page2", 400, "ValidationError"),
+	)
+	defer provider.Close()
+
+	_, err := provider.DescribeStacks(&cloudformation.DescribeStacksInput{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "could not describe stacks after 1 stacks: ValidationError: failed")
+}
+
+func TestDescribeStacksPartialFailureNotFound(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleDescribeStacksPage("", "page2", "convox"),
+		cycleDescribeStacksPageError("page2", 400, "NotFound"),
+	)
+	defer provider.Close()
+
+	_, err := provider.DescribeStacks(&cloudformation.DescribeStacksInput{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "could not describe stacks after 1 stacks: NotFound: failed")
+	assert.True(t, aws.IsNotFound(err))
+}
+
+func TestCreateStackOnFailure(t *testing.T) {
+	defer aws.SetRandSource(rand.New(rand.NewSource(1)))()
+
+	provider := StubAwsProvider(cycleCreateStackOnFailure)
+	defer provider.Close()
+
+	provider.StackOnFailure = "DO_NOTHING"
+
+	err := provider.CreateStack("convox-test", []byte("{}"), map[string]string{}, map[string]string{})
+	require.NoError(t, err)
+}
+
+func TestCreateStackOnFailureInvalid(t *testing.T) {
+	provider := StubAwsProvider()
+	defer provider.Close()
+
+	provider.StackOnFailure = "KEEP"
+
+	err := provider.CreateStack("convox-test", []byte("{}"), map[string]string{}, map[string]string{})
+	assert.EqualError(t, err, `invalid stack on failure action "KEEP", must be one of ROLLBACK, DO_NOTHING, DELETE`)
+}
+
+func TestOrphanedCronRuleNames(t *testing.T) {
+	app := &structs.App{Name: "httpd"}
+
+	cleanup := aws.NewCronJobFromLabel("convox.cron.cleanup", "0 * * * ? bin/cleanup")
+	cleanup.App = app
+	cleanup.Service = &manifest1.Service{Name: "worker"}
+
+	report := aws.NewCronJobFromLabel("convox.cron.report", "0 0 * * ? bin/report")
+	report.App = app
+	report.Service = &manifest1.Service{Name: "worker"}
+
+	provider := StubAwsProvider(
+		cycleCronListRules(cleanup.LongName()+"-schedule", report.LongName()+"-schedule"),
+	)
+	defer provider.Close()
+
+	// report has been removed from the manifest
+	orphans, err := provider.OrphanedCronRuleNames("httpd", aws.CronJobs{cleanup})
+	require.NoError(t, err)
+	assert.Equal(t, []string{report.LongName() + "-schedule"}, orphans)
+}
+
+func TestAppResourcesRecursive(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	child := provider.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name: "convox-httpd-ResourceDatabase-1ABCDEF",
+		Resources: []awsfake.Resource{
+			{LogicalId: "Instance", PhysicalId: "convox-httpd-database", Type: "AWS::RDS::DBInstance"},
+			{LogicalId: "SecurityGroup", PhysicalId: "sg-0123456789", Type: "AWS::EC2::SecurityGroup"},
+		},
+	})
+
+	provider.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name: "convox-httpd",
+		Resources: []awsfake.Resource{
+			{LogicalId: "ResourceDatabase", PhysicalId: child, Type: "AWS::CloudFormation::Stack"},
+			{LogicalId: "Settings", PhysicalId: "convox-httpd-settings", Type: "AWS::S3::Bucket"},
+		},
+	})
+
+	rs, err := provider.AppResourcesRecursive("httpd")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"ResourceDatabase":               child,
+		"ResourceDatabase.Instance":      "convox-httpd-database",
+		"ResourceDatabase.SecurityGroup": "sg-0123456789",
+		"Settings":                       "convox-httpd-settings",
+	}, rs)
+}
+
+func TestParseStackName(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	rack, app, ok := provider.ParseStackName("convox-httpd")
+	assert.True(t, ok)
+	assert.Equal(t, "convox", rack)
+	assert.Equal(t, "httpd", app)
+
+	rack, app, ok = provider.ParseStackName("convox-my-app")
+	assert.True(t, ok)
+	assert.Equal(t, "convox", rack)
+	assert.Equal(t, "my-app", app)
+
+	rack, app, ok = provider.ParseStackName("convox")
+	assert.True(t, ok)
+	assert.Equal(t, "convox", rack)
+	assert.Equal(t, "", app)
+
+	for _, name := range []string{"other-httpd", "other", "convoxhttpd", "convox-", ""} {
+		rack, app, ok = provider.ParseStackName(name)
+		assert.False(t, ok, name)
+		assert.Equal(t, "", rack, name)
+		assert.Equal(t, "", app, name)
+	}
+}
+
+func TestDescribeTaskDefinitionCacheByArn(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleProcessDescribeTaskDefinition1,
+	)
+	defer provider.Close()
+
+	arn := "arn:aws:ecs:us-east-1:778743527532:task-definition/convox-myapp-web:34"
+
+	provider.SkipCache = false
+	defer cache.Clear("describeTaskDefinition", arn)
+
+	td1, err := provider.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: awssdk.String(arn),
+	})
+	require.NoError(t, err)
+
+	// the stub only answers once so this must be served from the cache
+	td2, err := provider.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+		Include:        []*string{awssdk.String("TAGS")},
+		TaskDefinition: awssdk.String(arn),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, td1, td2)
+}
+
+func TestS3PutLarge(t *testing.T) {
+	data := strings.Repeat("a", 6*1024*1024)
+
+	provider := StubAwsProvider(
+		cycleS3CreateMultipartUpload,
+		cycleS3UploadPart(1, data[:5*1024*1024]),
+		cycleS3UploadPart(2, data[5*1024*1024:]),
+		cycleS3CompleteMultipartUpload,
+	)
+	defer provider.Close()
+
+	err := provider.S3PutLarge("convox-settings", "large", []byte(data), false, 5*1024*1024, 1)
+	require.NoError(t, err)
+}
+
+var cycleS3CreateMultipartUpload = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/convox-settings/large?uploads=",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `<?xml version="1.0" encoding="UTF-8"?>
+			<InitiateMultipartUploadResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+				<Bucket>convox-settings</Bucket>
+				<Key>large</Key>
+				<UploadId>upload1</UploadId>
+			</InitiateMultipartUploadResult>`,
+	},
+}
+
+func cycleS3UploadPart(part int, body string) awsutil.Cycle {
+	return awsutil.Cycle{
+		Request: awsutil.Request{
+			Method:     "PUT",
+			RequestURI: fmt.Sprintf("/convox-settings/large?partNumber=%d&uploadId=upload1", part),
+			Body:       body,
+		},
+		Response: awsutil.Response{
+			StatusCode: 200,
+		},
+	}
+}
+
+var cycleS3CompleteMultipartUpload = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/convox-settings/large?uploadId=upload1",
+		Body:       `<CompleteMultipartUpload xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Part><PartNumber>1</PartNumber></Part><Part><PartNumber>2</PartNumber></Part></CompleteMultipartUpload>`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `<?xml version="1.0" encoding="UTF-8"?>
+			<CompleteMultipartUploadResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+				<Bucket>convox-settings</Bucket>
+				<Key>large</Key>
+			</CompleteMultipartUploadResult>`,
+	},
+}
+
+func cycleCronListRules(names ...string) awsutil.Cycle {
+	rules := []string{}
+
+	for _, n := range names {
+		rules = append(rules, fmt.Sprintf(`{"Name": %q, "State": "ENABLED"}`, n))
+	}
+
+	return awsutil.Cycle{
+		Request: awsutil.Request{
+			RequestURI: "/",
+			Operation:  "AWSEvents.ListRules",
+			Body:       `{"NamePrefix": "convox-httpd-"}`,
+		},
+		Response: awsutil.Response{
+			StatusCode: 200,
+			Body:       fmt.Sprintf(`{"Rules": [%s]}`, strings.Join(rules, ",")),
+		},
+	}
+}
+
+func cycleDescribeStacksPage(token, next string, names ...string) awsutil.Cycle {
+	body := "Action=DescribeStacks&Version=2010-05-15"
+
+	if token != "" {
+		body = fmt.Sprintf("Action=DescribeStacks&NextToken=%s&Version=2010-05-15", token)
+	}
+
+	members := ""
+
+	for _, name := range names {
+		members += fmt.Sprintf("<member><StackName>%s</StackName><StackStatus>CREATE_COMPLETE</StackStatus></member>", name)
+	}
+
+	nt := ""
+
+	if next != "" {
+		nt = fmt.Sprintf("<NextToken>%s</NextToken>", next)
+	}
+
+	return awsutil.Cycle{
+		Request: awsutil.Request{
+			RequestURI: "/",
+			Body:       body,
+		},
+		Response: awsutil.Response{
+			StatusCode: 200,
+			Body: fmt.Sprintf(`
+				<DescribeStacksResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+					<DescribeStacksResult>
+						<Stacks>%s</Stacks>
+						%s
+					</DescribeStacksResult>
+				</DescribeStacksResponse>
+			`, members, nt),
+		},
+	}
+}
+
+func cycleDescribeStacksPageError(token string, status int, code string) awsutil.Cycle {
+	return awsutil.Cycle{
+		Request: awsutil.Request{
+			RequestURI: "/",
+			Body:       fmt.Sprintf("Action=DescribeStacks&NextToken=%s&Version=2010-05-15", token),
+		},
+		Response: awsutil.Response{
+			StatusCode: status,
+			Body: fmt.Sprintf(`
+				<ErrorResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+					<Error>
+						<Type>Sender</Type>
+						<Code>%s</Code>
+						<Message>failed</Message>
+					</Error>
+				</ErrorResponse>
+			`, code),
+		},
+	}
+}
+
+var cycleCreateStackOnFailure = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Body:       "Action=CreateStack&Capabilities.member.1=CAPABILITY_IAM&ClientRequestToken=convox-test-47ae1gDv3aZMGkgaJT5U&NotificationARNs.member.1=arn%3Aaws%3Asns%3Aus-test-1%3A123456789012%3Aconvox-events&OnFailure=DO_NOTHING&StackName=convox-test&TemplateBody=%7B%7D&Version=2010-05-15",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `
+			<CreateStackResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+				<CreateStackResult>
+					<StackId>arn:aws:cloudformation:us-test-1:123456789012:stack/convox-test/cd77a770-7059-11e6-9f55-50fa5f2588d2</StackId>
+				</CreateStackResult>
+			</CreateStackResponse>
+		`,
+	},
+}
+
+func TestRackBalancerDNS(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.AddRack(awsfake.Stack{
+		Outputs:   map[string]string{"BalancerHost": "convox-1234567890.us-test-1.elb.amazonaws.com", "Dashboard": "convox-1234567890.us-test-1.elb.amazonaws.com"},
+		Resources: []awsfake.Resource{{LogicalId: "Balancer", PhysicalId: "convox", Type: "AWS::ElasticLoadBalancing::LoadBalancer"}},
+	})
+
+	dns, err := provider.RackBalancerDNS()
+	require.NoError(t, err)
+	assert.Equal(t, "convox-1234567890.us-test-1.elb.amazonaws.com", dns)
+}
+
+func TestRackBalancerDNSMissingOutput(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	// racks installed before the output was added only have the balancer resource
+	provider.AddRack(awsfake.Stack{
+		Outputs:   map[string]string{"Dashboard": "convox-1234567890.us-test-1.elb.amazonaws.com"},
+		Resources: []awsfake.Resource{{LogicalId: "Balancer", PhysicalId: "convox", Type: "AWS::ElasticLoadBalancing::LoadBalancer"}},
+	})
+
+	_, err := provider.RackBalancerDNS()
+	require.EqualError(t, err, "output not found: BalancerHost")
+	assert.True(t, stderrors.Is(err, aws.ErrOutputNotFound))
+}
+
+func TestRackBalancerDNSRouter(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.AddRack(awsfake.Stack{
+		Outputs:   map[string]string{"Dashboard": "rack.convox-router.us-test-1.convox.site"},
+		Resources: []awsfake.Resource{{LogicalId: "Router", PhysicalId: "convox-router", Type: "AWS::ElasticLoadBalancingV2::LoadBalancer"}},
+	})
+
+	_, err := provider.RackBalancerDNS()
+	require.EqualError(t, err, "rack convox has no api balancer: resource not found: Balancer")
+	assert.Equal(t, 404, err.(interface{ Code() int }).Code())
+	assert.True(t, stderrors.Is(err, aws.ErrResourceNotFound))
+}
+
+// sizedTemplate returns a valid template of exactly size bytes, padded out in its description
+func sizedTemplate(size int) []byte {
+	template := `{"Description":"%s","Parameters":{"Foo":{"Type":"String","Default":"bar"}},"Resources":{}}`
+
+	return []byte(fmt.Sprintf(template, strings.Repeat("x", size-len(template)+2)))
+}
+
+func TestCreateStackRetriedAfterTimeout(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	// the first attempt creates the stack but its response never arrives so the sdk retries
+	// and is told the stack already exists
+	provider.Fake.DropResponses("CreateStack", 1)
+
+	require.NoError(t, provider.CreateStack("convox-retry", []byte("{}"), map[string]string{}, map[string]string{}))
+
+	assert.Equal(t, []string{"convox-retry"}, provider.Fake.CloudFormation.Stacks())
+}
+
+func TestCreateStackAlreadyExists(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.Fake.CloudFormation.AddStack(awsfake.Stack{Name: "convox-existing"})
+
+	err := provider.CreateStack("convox-existing", []byte("{}"), map[string]string{}, map[string]string{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "AlreadyExistsException")
+}
+
+func TestIdempotencyToken(t *testing.T) {
+	valid := regexp.MustCompile(`^[a-zA-Z][-a-zA-Z0-9]*$`)
+
+	for _, prefix := range []string{"convox-httpd", "convox.httpd", "1-app", "", "---", strings.Repeat("x", 200)} {
+		token := aws.IdempotencyToken(prefix)
+
+		assert.Regexp(t, valid, token, prefix)
+		assert.True(t, len(token) <= 64, prefix)
+	}
+
+	assert.Regexp(t, `^convox-httpd-[a-zA-Z0-9]{20}$`, aws.IdempotencyToken("convox.httpd"))
+	assert.Regexp(t, `^app-[a-zA-Z0-9]{20}$`, aws.IdempotencyToken("1-app"))
+	assert.Regexp(t, `^convox-[a-zA-Z0-9]{20}$`, aws.IdempotencyToken(""))
+	assert.NotEqual(t, aws.IdempotencyToken("convox"), aws.IdempotencyToken("convox"))
+}
+
+func TestCreateStackTemplateSize(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.AddRack(awsfake.Stack{
+		Resources: []awsfake.Resource{{LogicalId: "Settings", PhysicalId: "convox-settings", Type: "AWS::S3::Bucket"}},
+	})
+
+	small := sizedTemplate(1024)
+
+	require.NoError(t, provider.CreateStack("convox-small", small, map[string]string{}, map[string]string{}))
+
+	s, ok := provider.Fake.CloudFormation.Stack("convox-small")
+	require.True(t, ok)
+	assert.Equal(t, string(small), s.Template)
+	assert.Equal(t, "", s.TemplateURL)
+
+	large := sizedTemplate(60 * 1024)
+	require.Len(t, large, 60*1024)
+
+	require.NoError(t, provider.CreateStack("convox-large", large, map[string]string{}, map[string]string{}))
+
+	s, ok = provider.Fake.CloudFormation.Stack("convox-large")
+	require.True(t, ok)
+	assert.Equal(t, string(large), s.Template)
+	assert.Regexp(t, `^https://s3\.us-test-1\.amazonaws\.com/convox-settings/`, s.TemplateURL)
+	assert.Equal(t, "bar", s.Parameters["Foo"])
+
+	err := provider.CreateStack("convox-huge", sizedTemplate(460801), map[string]string{}, map[string]string{})
+	require.EqualError(t, err, "template is 460801 bytes, cloudformation accepts at most 460800")
+
+	_, ok = provider.Fake.CloudFormation.Stack("convox-huge")
+	assert.False(t, ok)
+}
+
+func TestCloudformationTopic(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.AddRack(awsfake.Stack{
+		Resources: []awsfake.Resource{{LogicalId: "Settings", PhysicalId: "convox-settings", Type: "AWS::S3::Bucket"}},
+	})
+
+	require.NoError(t, provider.CreateStack("convox-configured", sizedTemplate(1024), map[string]string{}, map[string]string{}))
+
+	s, ok := provider.Fake.CloudFormation.Stack("convox-configured")
+	require.True(t, ok)
+	assert.Equal(t, []string{"arn:aws:sns:us-test-1:123456789012:convox-events"}, s.NotificationARNs)
+
+	// racks whose provider was not given a topic read it from the rack stack
+	provider.CloudformationTopic = ""
+
+	err := provider.CreateStack("convox-unresolved", sizedTemplate(1024), map[string]string{}, map[string]string{})
+	require.EqualError(t, err, "cloudformation topic is not set and could not be read from rack convox: resource not found: CloudformationTopic")
+
+	_, ok = provider.Fake.CloudFormation.Stack("convox-unresolved")
+	assert.False(t, ok)
+
+	provider.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name: "convox-topic",
+		Resources: []awsfake.Resource{
+			{LogicalId: "CloudformationTopic", PhysicalId: "arn:aws:sns:us-test-1:123456789012:convox-CloudformationTopic-1ABCDEF", Type: "AWS::SNS::Topic"},
+		},
+	})
+
+	provider.Rack = "convox-topic"
+
+	require.NoError(t, provider.CreateStack("convox-resolved", sizedTemplate(1024), map[string]string{}, map[string]string{}))
+
+	s, ok = provider.Fake.CloudFormation.Stack("convox-resolved")
+	require.True(t, ok)
+	assert.Equal(t, []string{"arn:aws:sns:us-test-1:123456789012:convox-CloudformationTopic-1ABCDEF"}, s.NotificationARNs)
+
+	provider.CloudformationTopic = "convox-events"
+
+	err = provider.CreateStack("convox-invalid", sizedTemplate(1024), map[string]string{}, map[string]string{})
+	require.EqualError(t, err, `cloudformation topic "convox-events" invalid, must be an sns topic arn`)
+
+	provider.CloudformationTopic = "arn:aws:sqs:us-test-1:123456789012:convox-events"
+
+	err = provider.CreateStack("convox-invalid", sizedTemplate(1024), map[string]string{}, map[string]string{})
+	require.EqualError(t, err, `cloudformation topic "arn:aws:sqs:us-test-1:123456789012:convox-events" invalid, must be an sns topic arn`)
+}
+
+func TestAppOutput(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name:    "convox-httpd",
+		Outputs: map[string]string{"Release": "R1", "Empty": ""},
+	})
+
+	v, err := provider.AppOutput("httpd", "Release")
+	require.NoError(t, err)
+	assert.Equal(t, "R1", v)
+
+	v, err = provider.AppOutput("httpd", "Empty")
+	require.NoError(t, err)
+	assert.Equal(t, "", v)
+
+	_, err = provider.AppOutput("httpd", "Missing")
+	require.Error(t, err)
+	assert.True(t, stderrors.Is(err, aws.ErrOutputNotFound))
+	assert.EqualError(t, err, "output not found: Missing")
+	assert.Equal(t, 404, err.(interface{ Code() int }).Code())
+}
+
+func TestServiceArn(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name:    "convox-httpd",
+		Outputs: map[string]string{"ServiceWebService": "arn:aws:ecs:us-test-1:123456789012:service/convox-httpd-web"},
+		Resources: []awsfake.Resource{
+			{LogicalId: "ServiceWeb", PhysicalId: "convox-httpd-ServiceWeb", Type: "AWS::CloudFormation::Stack"},
+			{LogicalId: "ServiceWorker", PhysicalId: "convox-httpd-ServiceWorker", Type: "AWS::CloudFormation::Stack"},
+		},
+	})
+
+	provider.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name: "convox-legacy",
+		Resources: []awsfake.Resource{
+			{LogicalId: "ServiceWeb", PhysicalId: "arn:aws:ecs:us-test-1:123456789012:service/convox-legacy-web", Type: "AWS::ECS::Service"},
+			{LogicalId: "ServiceWorker", Type: "AWS::ECS::Service"},
+		},
+	})
+
+	tests := []struct {
+		app      string
+		service  string
+		arn      string
+		sentinel error
+		err      string
+	}{
+		{"httpd", "web", "arn:aws:ecs:us-test-1:123456789012:service/convox-httpd-web", nil, ""},
+		{"httpd", "worker", "", aws.ErrServiceNotReady, "service not ready: worker"},
+		{"httpd", "other", "", aws.ErrServiceNotFound, "service not found: other"},
+		{"legacy", "web", "arn:aws:ecs:us-test-1:123456789012:service/convox-legacy-web", nil, ""},
+		{"legacy", "worker", "", aws.ErrServiceNotReady, "service not ready: worker"},
+		{"legacy", "other", "", aws.ErrServiceNotFound, "service not found: other"},
+	}
+
+	for _, tt := range tests {
+		name := fmt.Sprintf("%s/%s", tt.app, tt.service)
+
+		arn, err := provider.ServiceArn(tt.app, tt.service)
+
+		if tt.sentinel == nil {
+			require.NoError(t, err, name)
+			assert.Equal(t, tt.arn, arn, name)
+			continue
+		}
+
+		assert.EqualError(t, err, tt.err, name)
+		assert.True(t, stderrors.Is(err, tt.sentinel), name)
+		assert.False(t, stderrors.Is(err, aws.ErrOutputNotFound), name)
+		assert.Equal(t, "", arn, name)
+	}
+}
@@ -0,0 +1,197 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ecr"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+var regexpPushDigest = regexp.MustCompile(`digest: (sha256:[0-9a-f]{64})`)
+
+// BuildOptions are the settings for an image built by BuildAndPushImage
+type BuildOptions struct {
+	ContextPath string
+	Dockerfile  string
+	BuildArgs   map[string]string
+	NoCache     bool
+}
+
+// BuildAndPushImage builds an image for a service of an app with the local docker daemon,
+// pushes it to the app registry tagged <service>.<build id> and returns the pushed image as <uri>:<tag>@<digest>
+// The docker client in use does not take a context so ctx is only checked between steps
+func (p *Provider) BuildAndPushImage(ctx context.Context, app, service string, opts BuildOptions) (string, error) {
+	log := Logger.At("BuildAndPushImage").Namespace("app=%q service=%q", app, service).Start()
+
+	if opts.ContextPath == "" {
+		return "", log.Error(fmt.Errorf("context path required"))
+	}
+
+	repo, err := p.appRepository(app)
+	if err != nil {
+		return "", log.Error(err)
+	}
+
+	auth, err := p.ecrAuth()
+	if err != nil {
+		return "", log.Error(err)
+	}
+
+	dc, err := p.dockerLocal()
+	if err != nil {
+		return "", log.Error(err)
+	}
+
+	id := generateId("B", 10)
+	tag := fmt.Sprintf("%s.%s", service, id)
+	local := fmt.Sprintf("convox/%s/%s:%s", app, service, id)
+
+	if err := ctx.Err(); err != nil {
+		return "", log.Error(err)
+	}
+
+	bopts := docker.BuildImageOptions{
+		AuthConfigs:  docker.AuthConfigurations{Configs: map[string]docker.AuthConfiguration{auth.ServerAddress: *auth}},
+		BuildArgs:    buildArgs(opts.BuildArgs),
+		ContextDir:   opts.ContextPath,
+		Dockerfile:   coalesces(opts.Dockerfile, "Dockerfile"),
+		Name:         local,
+		NoCache:      opts.NoCache,
+		OutputStream: ioutil.Discard,
+	}
+
+	if err := dc.BuildImage(bopts); err != nil {
+		return "", log.Error(fmt.Errorf("build failed: %s", err))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", log.Error(err)
+	}
+
+	if err := dc.TagImage(local, docker.TagImageOptions{Repo: repo.URI, Tag: tag, Force: true}); err != nil {
+		return "", log.Error(err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", log.Error(err)
+	}
+
+	var out bytes.Buffer
+
+	popts := docker.PushImageOptions{
+		Name:          repo.URI,
+		Tag:           tag,
+		OutputStream:  &out,
+		RawJSONStream: true,
+	}
+
+	if err := dc.PushImage(popts, *auth); err != nil {
+		return "", log.Error(fmt.Errorf("push failed: %s", err))
+	}
+
+	digest, err := pushDigest(&out)
+	if err != nil {
+		return "", log.Error(fmt.Errorf("push failed: %s", err))
+	}
+
+	log.Success()
+
+	return fmt.Sprintf("%s:%s@%s", repo.URI, tag, digest), nil
+}
+
+// ecrAuth returns docker credentials for the rack registry
+func (p *Provider) ecrAuth() (*docker.AuthConfiguration, error) {
+	res, err := p.ecr().GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.AuthorizationData) != 1 {
+		return nil, fmt.Errorf("no authorization data")
+	}
+
+	token, err := base64.StdEncoding.DecodeString(*res.AuthorizationData[0].AuthorizationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.SplitN(string(token), ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid auth data")
+	}
+
+	registry, err := url.Parse(*res.AuthorizationData[0].ProxyEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &docker.AuthConfiguration{
+		Username:      parts[0],
+		Password:      parts[1],
+		ServerAddress: registry.Host,
+	}, nil
+}
+
+func buildArgs(args map[string]string) []docker.BuildArg {
+	bas := []docker.BuildArg{}
+
+	for k, v := range args {
+		bas = append(bas, docker.BuildArg{Name: k, Value: v})
+	}
+
+	sort.Slice(bas, func(i, j int) bool { return bas[i].Name < bas[j].Name })
+
+	return bas
+}
+
+// pushDigest reads the digest of the pushed image from the json messages of a push
+func pushDigest(r io.Reader) (string, error) {
+	digest := ""
+
+	dec := json.NewDecoder(r)
+
+	for {
+		var msg struct {
+			Aux struct {
+				Digest string
+			} `json:"aux"`
+			Error  string `json:"error"`
+			Status string `json:"status"`
+		}
+
+		err := dec.Decode(&msg)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if msg.Error != "" {
+			return "", fmt.Errorf("%s", msg.Error)
+		}
+
+		if msg.Aux.Digest != "" {
+			digest = msg.Aux.Digest
+		}
+
+		if m := regexpPushDigest.FindStringSubmatch(msg.Status); len(m) == 2 && digest == "" {
+			digest = m[1]
+		}
+	}
+
+	if digest == "" {
+		return "", fmt.Errorf("no digest")
+	}
+
+	return digest, nil
+}
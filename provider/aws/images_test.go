@@ -0,0 +1,202 @@
+package aws_test
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestBuildAndPushImage(t *testing.T) {
+	provider, uri := imagesTestProvider()
+	defer provider.Close()
+
+	fd := newFakeDockerBuild(fmt.Sprintf(`{"status":"The push refers to repository [%s]"}{"status":"web.B: digest: %s size: 527"}`, uri, testDigest))
+	defer fd.Close()
+
+	dir := imagesTestContext(t)
+	defer os.RemoveAll(dir)
+
+	image, err := provider.BuildAndPushImage(context.Background(), "httpd", "web", aws.BuildOptions{
+		ContextPath: dir,
+		Dockerfile:  "Dockerfile.web",
+		BuildArgs:   map[string]string{"FOO": "bar", "BAZ": "qux"},
+		NoCache:     true,
+	})
+	require.NoError(t, err)
+
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+
+	require.Len(t, fd.builds, 1)
+	build := fd.builds[0]
+
+	assert.Equal(t, "Dockerfile.web", build.Get("dockerfile"))
+	assert.Equal(t, "1", build.Get("nocache"))
+	assert.Equal(t, `{"BAZ":"qux","FOO":"bar"}`, build.Get("buildargs"))
+	assert.True(t, strings.HasPrefix(build.Get("t"), "convox/httpd/web:B"))
+	assert.Equal(t, []string{"Dockerfile.web", "app.txt"}, fd.files)
+
+	require.Len(t, fd.tags, 1)
+	assert.Equal(t, "/images/"+build.Get("t")+"/tag", fd.tags[0].Path)
+	assert.Equal(t, uri, fd.tags[0].Query().Get("repo"))
+
+	tag := fd.tags[0].Query().Get("tag")
+	assert.Equal(t, "web."+strings.TrimPrefix(build.Get("t"), "convox/httpd/web:"), tag)
+
+	require.Len(t, fd.pushes, 1)
+	assert.Equal(t, "/images/"+uri+"/push", fd.pushes[0].Path)
+	assert.Equal(t, tag, fd.pushes[0].Query().Get("tag"))
+
+	assert.Equal(t, "AWS", fd.auth.Username)
+	assert.Equal(t, "secret", fd.auth.Password)
+	assert.Equal(t, "123456789012.dkr.ecr.us-test-1.amazonaws.com", fd.auth.ServerAddress)
+
+	assert.Equal(t, fmt.Sprintf("%s:%s@%s", uri, tag, testDigest), image)
+	assert.Equal(t, 1, provider.Fake.ECR.Tokens())
+}
+
+func TestBuildAndPushImagePushError(t *testing.T) {
+	provider, _ := imagesTestProvider()
+	defer provider.Close()
+
+	fd := newFakeDockerBuild(`{"status":"preparing"}{"errorDetail":{"message":"denied"},"error":"denied"}`)
+	defer fd.Close()
+
+	dir := imagesTestContext(t)
+	defer os.RemoveAll(dir)
+
+	_, err := provider.BuildAndPushImage(context.Background(), "httpd", "web", aws.BuildOptions{ContextPath: dir, Dockerfile: "Dockerfile.web"})
+	require.EqualError(t, err, "push failed: denied")
+}
+
+func TestBuildAndPushImageCanceled(t *testing.T) {
+	provider, _ := imagesTestProvider()
+	defer provider.Close()
+
+	fd := newFakeDockerBuild("")
+	defer fd.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := provider.BuildAndPushImage(ctx, "httpd", "web", aws.BuildOptions{ContextPath: "."})
+	require.EqualError(t, err, "context canceled")
+
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+
+	assert.Len(t, fd.builds, 0)
+}
+
+func imagesTestProvider() (*awsfake.TestProvider, string) {
+	provider := awsfake.NewTestProvider()
+
+	provider.AddApp("httpd", "1", awsfake.Stack{
+		Outputs:    map[string]string{"RegistryRepository": "convox-httpd-abcdefghij"},
+		Parameters: map[string]string{"Release": "RVFETUHHKKD"},
+	})
+
+	provider.Fake.ECR.SetPassword("secret")
+
+	return provider, provider.Fake.ECR.AddRepository("convox-httpd-abcdefghij")
+}
+
+func imagesTestContext(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Dockerfile.web"), []byte("FROM scratch\nCOPY app.txt /\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "app.txt"), []byte("app"), 0644))
+
+	return dir
+}
+
+// fakeDockerBuild records build, tag and push requests and answers pushes with a canned json stream
+type fakeDockerBuild struct {
+	*httptest.Server
+
+	auth   docker.AuthConfiguration
+	builds []url.Values
+	files  []string
+	lock   sync.Mutex
+	push   string
+	pushes []*url.URL
+	tags   []*url.URL
+}
+
+func newFakeDockerBuild(push string) *fakeDockerBuild {
+	fd := &fakeDockerBuild{push: push}
+	fd.Server = httptest.NewServer(http.HandlerFunc(fd.serve))
+
+	os.Setenv("DOCKER_HOST", fmt.Sprintf("tcp://%s", fd.URL[7:]))
+
+	return fd
+}
+
+func (fd *fakeDockerBuild) Close() {
+	os.Unsetenv("DOCKER_HOST")
+	fd.Server.Close()
+}
+
+func (fd *fakeDockerBuild) serve(w http.ResponseWriter, r *http.Request) {
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/version":
+		json.NewEncoder(w).Encode(map[string]string{"ApiVersion": "1.40"})
+	case r.Method == "POST" && r.URL.Path == "/build":
+		fd.builds = append(fd.builds, r.URL.Query())
+
+		tr := tar.NewReader(r.Body)
+
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+
+			fd.files = append(fd.files, h.Name)
+		}
+
+		fmt.Fprintf(w, `{"stream":"Successfully built 0123456789ab\n"}`)
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/tag"):
+		fd.tags = append(fd.tags, r.URL)
+		w.WriteHeader(201)
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/push"):
+		fd.pushes = append(fd.pushes, r.URL)
+
+		data, err := base64.URLEncoding.DecodeString(r.Header.Get("X-Registry-Auth"))
+		if err == nil {
+			json.Unmarshal(data, &fd.auth)
+		}
+
+		fmt.Fprint(w, fd.push)
+	default:
+		http.Error(w, "not found", 404)
+	}
+}
@@ -3,7 +3,6 @@ package aws
 import (
 	"fmt"
 	"io"
-	"math/rand"
 	"sort"
 	"strings"
 	"time"
@@ -16,7 +15,7 @@ import (
 )
 
 func (p *Provider) InstanceKeyroll() error {
-	key := fmt.Sprintf("%s-keypair-%d", p.Rack, (rand.Intn(8999) + 1000))
+	key := fmt.Sprintf("%s-keypair-%d", p.Rack, (randomInt(8999) + 1000))
 
 	res, err := p.ec2().CreateKeyPair(&ec2.CreateKeyPairInput{
 		KeyName: aws.String(key),
@@ -238,6 +237,77 @@ func (p *Provider) InstanceTerminate(id string) error {
 	return nil
 }
 
+// ASGActivity describes a single scaling activity on the rack's autoscaling group
+type ASGActivity struct {
+	ActivityId    string
+	Cause         string
+	Description   string
+	EndTime       *time.Time
+	StartTime     time.Time
+	StatusCode    string
+	StatusMessage string
+}
+
+// IsScaleOut returns true if the activity added capacity to the group
+func (a ASGActivity) IsScaleOut() bool {
+	cause := strings.ToLower(a.Cause)
+
+	return strings.Contains(cause, "scale out") || strings.Contains(cause, "launch")
+}
+
+// GetAutoScalingActivities returns the most recent scaling activities for the rack instances (at most 50)
+func (p *Provider) GetAutoScalingActivities(limit int) ([]ASGActivity, error) {
+	if limit < 1 || limit > 50 {
+		limit = 50
+	}
+
+	asg, err := p.rackResource("Instances")
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := p.autoscaling().DescribeScalingActivities(&autoscaling.DescribeScalingActivitiesInput{
+		AutoScalingGroupName: aws.String(asg),
+		MaxRecords:           aws.Int64(int64(limit)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	as := []ASGActivity{}
+
+	for _, a := range res.Activities {
+		if len(as) >= limit {
+			break
+		}
+
+		as = append(as, ASGActivity{
+			ActivityId:    cs(a.ActivityId, ""),
+			Cause:         cs(a.Cause, ""),
+			Description:   cs(a.Description, ""),
+			EndTime:       a.EndTime,
+			StartTime:     ct(a.StartTime, time.Time{}),
+			StatusCode:    cs(a.StatusCode, ""),
+			StatusMessage: cs(a.StatusMessage, ""),
+		})
+	}
+
+	return as, nil
+}
+
+// GetLatestScaleActivity returns the most recent scaling activity for the rack instances
+func (p *Provider) GetLatestScaleActivity() (*ASGActivity, error) {
+	as, err := p.GetAutoScalingActivities(1)
+	if err != nil {
+		return nil, err
+	}
+	if len(as) < 1 {
+		return nil, errorNotFound("no scaling activities found")
+	}
+
+	return &as[0], nil
+}
+
 type instanceResource struct {
 	Total int `json:"total"`
 	Free  int `json:"free"`
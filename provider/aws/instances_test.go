@@ -5,9 +5,11 @@ import (
 	"testing"
 	"time"
 
-	"github.com/convox/rack/pkg/test/awsutil"
 	"github.com/convox/rack/pkg/structs"
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestInstancesList(t *testing.T) {
@@ -61,6 +63,118 @@ func TestInstancesList(t *testing.T) {
 	}, is)
 }
 
+func TestGetAutoScalingActivities(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleListRackStackResources,
+		cycleInstanceDescribeScalingActivities,
+	)
+	defer provider.Close()
+
+	as, err := provider.GetAutoScalingActivities(2)
+	require.NoError(t, err)
+	require.Len(t, as, 2)
+
+	end := time.Date(2019, 6, 1, 12, 1, 0, 0, time.UTC)
+
+	assert.Equal(t, aws.ASGActivity{
+		ActivityId:    "f9f2d65b-f1f2-43e7-b46d-d86756459699",
+		Cause:         "At 2019-06-01T12:00:00Z a user request update of AutoScalingGroup constraints to min: 3, max: 6, desired: 4 changing the desired capacity from 3 to 4. At 2019-06-01T12:00:10Z an instance was launched in response to a difference between desired and actual capacity, increasing the capacity from 3 to 4.",
+		Description:   "Launching a new EC2 instance: i-0d725f4eb1d1e8f0a",
+		EndTime:       &end,
+		StartTime:     time.Date(2019, 6, 1, 12, 0, 10, 0, time.UTC),
+		StatusCode:    "Successful",
+		StatusMessage: "",
+	}, as[0])
+	assert.True(t, as[0].IsScaleOut())
+
+	assert.Equal(t, "Terminating EC2 instance: i-0b3a1c0e2f4d6a8b0", as[1].Description)
+	assert.Nil(t, as[1].EndTime)
+	assert.False(t, as[1].IsScaleOut())
+}
+
+func TestGetLatestScaleActivity(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleListRackStackResources,
+		cycleInstanceDescribeScalingActivitiesLatest,
+	)
+	defer provider.Close()
+
+	a, err := provider.GetLatestScaleActivity()
+	require.NoError(t, err)
+	assert.Equal(t, "f9f2d65b-f1f2-43e7-b46d-d86756459699", a.ActivityId)
+}
+
+func TestASGActivityIsScaleOut(t *testing.T) {
+	tests := []struct {
+		Cause string
+		Out   bool
+	}{
+		{"a user request explicitly set group desired capacity changing the desired capacity from 2 to 3", false},
+		{"an alarm triggered policy ScaleOut and an instance was started (launch)", true},
+		{"an instance was taken out of service in response to a scale out activity", true},
+		{"an instance was taken out of service in response to a scale in activity", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.Out, aws.ASGActivity{Cause: tt.Cause}.IsScaleOut(), tt.Cause)
+	}
+}
+
+var cycleInstanceDescribeScalingActivities = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "",
+		Body:       `Action=DescribeScalingActivities&AutoScalingGroupName=convox-Instances-1UEIK1IO8W9K3&MaxRecords=2&Version=2011-01-01`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       describeScalingActivitiesResponse,
+	},
+}
+
+var cycleInstanceDescribeScalingActivitiesLatest = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "",
+		Body:       `Action=DescribeScalingActivities&AutoScalingGroupName=convox-Instances-1UEIK1IO8W9K3&MaxRecords=1&Version=2011-01-01`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       describeScalingActivitiesResponse,
+	},
+}
+
+const describeScalingActivitiesResponse = `
+<DescribeScalingActivitiesResponse xmlns="http://autoscaling.amazonaws.com/doc/2011-01-01/">
+  <DescribeScalingActivitiesResult>
+    <Activities>
+      <member>
+        <ActivityId>f9f2d65b-f1f2-43e7-b46d-d86756459699</ActivityId>
+        <AutoScalingGroupName>convox-Instances-1UEIK1IO8W9K3</AutoScalingGroupName>
+        <Cause>At 2019-06-01T12:00:00Z a user request update of AutoScalingGroup constraints to min: 3, max: 6, desired: 4 changing the desired capacity from 3 to 4. At 2019-06-01T12:00:10Z an instance was launched in response to a difference between desired and actual capacity, increasing the capacity from 3 to 4.</Cause>
+        <Description>Launching a new EC2 instance: i-0d725f4eb1d1e8f0a</Description>
+        <EndTime>2019-06-01T12:01:00Z</EndTime>
+        <Progress>100</Progress>
+        <StartTime>2019-06-01T12:00:10Z</StartTime>
+        <StatusCode>Successful</StatusCode>
+      </member>
+      <member>
+        <ActivityId>c1a9c0e4-2b9d-4f8e-9a57-3c0f0e5b7d21</ActivityId>
+        <AutoScalingGroupName>convox-Instances-1UEIK1IO8W9K3</AutoScalingGroupName>
+        <Cause>At 2019-06-01T11:00:00Z an instance was taken out of service in response to a user request, shrinking the capacity from 4 to 3.</Cause>
+        <Description>Terminating EC2 instance: i-0b3a1c0e2f4d6a8b0</Description>
+        <Progress>50</Progress>
+        <StartTime>2019-06-01T11:00:00Z</StartTime>
+        <StatusCode>InProgress</StatusCode>
+      </member>
+    </Activities>
+  </DescribeScalingActivitiesResult>
+  <ResponseMetadata>
+    <RequestId>0f1d7c6c-1a2b-11e9-8a7b-0123456789ab</RequestId>
+  </ResponseMetadata>
+</DescribeScalingActivitiesResponse>
+`
+
 func listContainerInstancesCycle(clusterName string) awsutil.Cycle {
 	return awsutil.Cycle{
 		awsutil.Request{"POST", "/", "AmazonEC2ContainerServiceV20141113.ListContainerInstances",
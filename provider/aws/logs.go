@@ -0,0 +1,173 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// LogEvent is a single log line read from CloudWatch Logs
+type LogEvent struct {
+	Message   string
+	Stream    string
+	Timestamp time.Time
+}
+
+// RackLogGroup returns the name of the log group holding the rack's own application logs
+func (p *Provider) RackLogGroup() string {
+	return fmt.Sprintf("%s/rack", p.Rack)
+}
+
+// GetRackLogs sends the rack's own log events since the given time to ch in the order returned by CloudWatch
+// ch is closed when it returns, including when ctx is canceled while a send is waiting on a reader that has gone away
+func (p *Provider) GetRackLogs(ctx context.Context, since time.Time, filter string, ch chan<- LogEvent) error {
+	defer close(ch)
+
+	req := &cloudwatchlogs.FilterLogEventsInput{
+		Interleaved:  aws.Bool(true),
+		LogGroupName: aws.String(p.RackLogGroup()),
+		StartTime:    aws.Int64(since.UTC().UnixNano() / int64(time.Millisecond)),
+	}
+
+	if filter != "" {
+		req.FilterPattern = aws.String(filter)
+	}
+
+	err := p.cloudwatchlogs().FilterLogEventsPagesWithContext(ctx, req, func(res *cloudwatchlogs.FilterLogEventsOutput, last bool) bool {
+		for _, e := range res.Events {
+			ev := LogEvent{
+				Message:   cs(e.Message, ""),
+				Stream:    cs(e.LogStreamName, ""),
+				Timestamp: time.Unix(0, ci(e.Timestamp, 0)*int64(time.Millisecond)).UTC(),
+			}
+
+			select {
+			case <-ctx.Done():
+				return false
+			case ch <- ev:
+			}
+		}
+
+		return true
+	})
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// ExecLogEntry is an ECS Exec session recorded in the cluster's exec log group
+type ExecLogEntry struct {
+	TaskArn       string
+	ContainerName string
+	SessionId     string
+	Command       string
+	StartedAt     time.Time
+}
+
+// execLogMessage is the json written to the exec log group for each session
+type execLogMessage struct {
+	Command           string    `json:"command"`
+	ContainerName     string    `json:"containerName"`
+	SessionId         string    `json:"sessionId"`
+	StartedAt         time.Time `json:"startedAt"`
+	TaskArn           string    `json:"taskArn"`
+	TaskDefinitionArn string    `json:"taskDefinitionArn"`
+}
+
+// GetECSExecLogs returns the ECS Exec sessions started in tasks of an app since the given time
+// task arns do not name the app so sessions are matched to it by task definition family
+func (p *Provider) GetECSExecLogs(app string, since time.Time) ([]ExecLogEntry, error) {
+	s, err := p.GetClusterSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.ExecLogGroup == "" {
+		return nil, fmt.Errorf("exec logging is not configured for cluster: %s", p.Cluster)
+	}
+
+	prefix := strings.Replace(s.Arn, ":cluster/", ":task/", 1) + "/"
+	family := fmt.Sprintf("task-definition/%s-%s-", p.Rack, app)
+
+	req := &cloudwatchlogs.FilterLogEventsInput{
+		FilterPattern: aws.String(fmt.Sprintf(`{ $.taskArn = "%s*" }`, prefix)),
+		Interleaved:   aws.Bool(true),
+		LogGroupName:  aws.String(s.ExecLogGroup),
+		StartTime:     aws.Int64(since.UTC().UnixNano() / int64(time.Millisecond)),
+	}
+
+	entries := []ExecLogEntry{}
+
+	err = p.cloudwatchlogs().FilterLogEventsPages(req, func(res *cloudwatchlogs.FilterLogEventsOutput, last bool) bool {
+		for _, e := range res.Events {
+			var m execLogMessage
+
+			// the group can hold session output as well, only the session records are json
+			if err := json.Unmarshal([]byte(cs(e.Message, "")), &m); err != nil || m.SessionId == "" {
+				continue
+			}
+
+			if !strings.HasPrefix(m.TaskArn, prefix) || !strings.Contains(m.TaskDefinitionArn, family) {
+				continue
+			}
+
+			if m.StartedAt.IsZero() {
+				m.StartedAt = time.Unix(0, ci(e.Timestamp, 0)*int64(time.Millisecond))
+			}
+
+			entries = append(entries, ExecLogEntry{
+				TaskArn:       m.TaskArn,
+				ContainerName: m.ContainerName,
+				SessionId:     m.SessionId,
+				Command:       m.Command,
+				StartedAt:     m.StartedAt.UTC(),
+			})
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// getAllLogEvents returns the messages of every event in a log stream, oldest first
+func (p *Provider) getAllLogEvents(group, stream string) ([]string, error) {
+	req := &cloudwatchlogs.GetLogEventsInput{
+		LogGroupName:  aws.String(group),
+		LogStreamName: aws.String(stream),
+		StartFromHead: aws.Bool(true),
+	}
+
+	messages := []string{}
+
+	for {
+		res, err := p.cloudwatchlogs().GetLogEvents(req)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range res.Events {
+			messages = append(messages, cs(e.Message, ""))
+		}
+
+		// the end of the stream is reached when the forward token comes back unchanged, it is never empty
+		if res.NextForwardToken == nil || (req.NextToken != nil && *res.NextForwardToken == *req.NextToken) {
+			break
+		}
+
+		req.NextToken = res.NextForwardToken
+	}
+
+	return messages, nil
+}
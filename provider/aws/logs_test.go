@@ -0,0 +1,271 @@
+package aws_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRackLogGroup(t *testing.T) {
+	provider := StubAwsProvider()
+	defer provider.Close()
+
+	assert.Equal(t, "convox/rack", provider.RackLogGroup())
+}
+
+func TestGetRackLogs(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleRackLogsFilterLogEvents1,
+		cycleRackLogsFilterLogEvents2,
+	)
+	defer provider.Close()
+
+	ch := make(chan aws.LogEvent, 10)
+
+	err := provider.GetRackLogs(context.Background(), time.Unix(1396035378, 0), "error", ch)
+	require.NoError(t, err)
+
+	events := []aws.LogEvent{}
+
+	for e := range ch {
+		events = append(events, e)
+	}
+
+	require.Len(t, events, 3)
+
+	assert.Equal(t, aws.LogEvent{Message: "event1", Stream: "web/1", Timestamp: time.Unix(1396035378, 988000000).UTC()}, events[0])
+	assert.Equal(t, aws.LogEvent{Message: "event2", Stream: "web/2", Timestamp: time.Unix(1396035378, 989000000).UTC()}, events[1])
+	assert.Equal(t, aws.LogEvent{Message: "event3", Stream: "web/1", Timestamp: time.Unix(1396035379, 0).UTC()}, events[2])
+}
+
+func TestGetRackLogsCancel(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleRackLogsFilterLogEvents1,
+	)
+	defer provider.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := provider.GetRackLogs(ctx, time.Unix(1396035378, 0), "error", make(chan aws.LogEvent))
+
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestGetRackLogsCancelBlocked(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleRackLogsFilterLogEvents1,
+	)
+	defer provider.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan aws.LogEvent)
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- provider.GetRackLogs(ctx, time.Unix(1396035378, 0), "error", ch)
+	}()
+
+	e := <-ch
+	assert.Equal(t, "event1", e.Message)
+
+	// stop reading with event2 still to send
+	cancel()
+
+	select {
+	case err := <-errs:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetRackLogs did not return after cancel")
+	}
+
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok, "channel should be closed")
+	default:
+		t.Fatal("channel should be closed")
+	}
+}
+
+func TestGetECSExecLogs(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleClusterDescribeClusters("enabled", "convox-exec"),
+		cycleExecLogsFilterLogEvents,
+	)
+	defer provider.Close()
+
+	entries, err := provider.GetECSExecLogs("httpd", time.Unix(1396035378, 0))
+	require.NoError(t, err)
+
+	assert.Equal(t, []aws.ExecLogEntry{
+		{
+			TaskArn:       "arn:aws:ecs:us-test-1:123456789012:task/cluster-test/0a1b2c3d",
+			ContainerName: "web",
+			SessionId:     "ecs-execute-command-0f8e5d7a",
+			Command:       "bin/console",
+			StartedAt:     time.Date(2014, 3, 28, 19, 36, 18, 0, time.UTC),
+		},
+		{
+			TaskArn:       "arn:aws:ecs:us-test-1:123456789012:task/cluster-test/4e5f6a7b",
+			ContainerName: "worker",
+			SessionId:     "ecs-execute-command-1c2d3e4f",
+			Command:       "/bin/sh",
+			StartedAt:     time.Unix(1396035379, 0).UTC(),
+		},
+	}, entries)
+}
+
+func TestGetECSExecLogsNotConfigured(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleClusterDescribeClusters("enabled", ""),
+	)
+	defer provider.Close()
+
+	_, err := provider.GetECSExecLogs("httpd", time.Unix(1396035378, 0))
+	require.EqualError(t, err, "exec logging is not configured for cluster: cluster-test")
+}
+
+func TestGetAllLogEvents(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleLogsGetLogEvents("", "f/1", `{"timestamp": 1396035378988, "message": "event1"}, {"timestamp": 1396035378989, "message": "event2"}`),
+		cycleLogsGetLogEvents("f/1", "f/2", `{"timestamp": 1396035379000, "message": "event3"}`),
+		cycleLogsGetLogEvents("f/2", "f/2", ``),
+	)
+	defer provider.Close()
+
+	messages, err := provider.GetAllLogEvents("convox-httpd-LogGroup", "build/BABCDEFGHI")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"event1", "event2", "event3"}, messages)
+}
+
+func cycleLogsGetLogEvents(token, next, events string) awsutil.Cycle {
+	body := `{"logGroupName": "convox-httpd-LogGroup", "logStreamName": "build/BABCDEFGHI", "startFromHead": true}`
+
+	if token != "" {
+		body = fmt.Sprintf(`{"logGroupName": "convox-httpd-LogGroup", "logStreamName": "build/BABCDEFGHI", "nextToken": %q, "startFromHead": true}`, token)
+	}
+
+	return awsutil.Cycle{
+		Request: awsutil.Request{
+			RequestURI: "/",
+			Operation:  "Logs_20140328.GetLogEvents",
+			Body:       body,
+		},
+		Response: awsutil.Response{
+			StatusCode: 200,
+			Body:       fmt.Sprintf(`{"events": [%s], "nextBackwardToken": "b/0", "nextForwardToken": %q}`, events, next),
+		},
+	}
+}
+
+var cycleExecLogsFilterLogEvents = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "Logs_20140328.FilterLogEvents",
+		Body: `{
+			"filterPattern": "{ $.taskArn = \"arn:aws:ecs:us-test-1:123456789012:task/cluster-test/*\" }",
+			"interleaved": true,
+			"logGroupName": "convox-exec",
+			"startTime": 1396035378000
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"events": [
+				{
+					"timestamp": 1396035378988,
+					"message": "{\"sessionId\":\"ecs-execute-command-0f8e5d7a\",\"taskArn\":\"arn:aws:ecs:us-test-1:123456789012:task/cluster-test/0a1b2c3d\",\"taskDefinitionArn\":\"arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-ServiceWeb-1KQ8Z-service-web:4\",\"containerName\":\"web\",\"command\":\"bin/console\",\"startedAt\":\"2014-03-28T19:36:18Z\"}",
+					"logStreamName": "ecs-execute-command-0f8e5d7a"
+				},
+				{
+					"timestamp": 1396035378989,
+					"message": "irb(main):001:0>",
+					"logStreamName": "ecs-execute-command-0f8e5d7a"
+				},
+				{
+					"timestamp": 1396035378990,
+					"message": "{\"sessionId\":\"ecs-execute-command-5a6b7c8d\",\"taskArn\":\"arn:aws:ecs:us-test-1:123456789012:task/cluster-test/9f8e7d6c\",\"taskDefinitionArn\":\"arn:aws:ecs:us-test-1:123456789012:task-definition/convox-other-ServiceWeb-2PL3X-service-web:1\",\"containerName\":\"web\",\"command\":\"/bin/bash\"}",
+					"logStreamName": "ecs-execute-command-5a6b7c8d"
+				},
+				{
+					"timestamp": 1396035379000,
+					"message": "{\"sessionId\":\"ecs-execute-command-1c2d3e4f\",\"taskArn\":\"arn:aws:ecs:us-test-1:123456789012:task/cluster-test/4e5f6a7b\",\"taskDefinitionArn\":\"arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-ServiceWorker-3ZQ1Y-service-worker:2\",\"containerName\":\"worker\",\"command\":\"/bin/sh\"}",
+					"logStreamName": "ecs-execute-command-1c2d3e4f"
+				}
+			]
+		}`,
+	},
+}
+
+var cycleRackLogsFilterLogEvents1 = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "Logs_20140328.FilterLogEvents",
+		Body: `{
+			"filterPattern": "error",
+			"interleaved": true,
+			"logGroupName": "convox/rack",
+			"startTime": 1396035378000
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"events": [
+				{
+					"ingestionTime": 1396035394997,
+					"timestamp": 1396035378988,
+					"message": "event1",
+					"logStreamName": "web/1",
+					"eventId": "31132629274945519779805322857203735586714454643391594505"
+				},
+				{
+					"ingestionTime": 1396035394997,
+					"timestamp": 1396035378989,
+					"message": "event2",
+					"logStreamName": "web/2",
+					"eventId": "31132629274945519779805322857203735586814454643391594505"
+				}
+			],
+			"nextToken": "token1"
+		}`,
+	},
+}
+
+var cycleRackLogsFilterLogEvents2 = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "Logs_20140328.FilterLogEvents",
+		Body: `{
+			"filterPattern": "error",
+			"interleaved": true,
+			"logGroupName": "convox/rack",
+			"nextToken": "token1",
+			"startTime": 1396035378000
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"events": [
+				{
+					"ingestionTime": 1396035394997,
+					"timestamp": 1396035379000,
+					"message": "event3",
+					"logStreamName": "web/1",
+					"eventId": "31132629274945519779805322857203735586714454643391594506"
+				}
+			]
+		}`,
+	},
+}
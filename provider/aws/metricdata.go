@@ -0,0 +1,69 @@
+package aws
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/convox/rack/pkg/cache"
+)
+
+// metricDataQueryLimit is the most queries cloudwatch accepts in a single GetMetricData call
+const metricDataQueryLimit = 500
+
+type metricDataKey struct {
+	Queries []*cloudwatch.MetricDataQuery
+	Start   time.Time
+	End     time.Time
+}
+
+// getMetricData runs queries in as few GetMetricData calls as cloudwatch allows and returns the datapoints of each query by id
+// results are cached for an hour so callers should align start and end to let repeated calls share them
+func (p *Provider) getMetricData(queries []*cloudwatch.MetricDataQuery, start, end time.Time) (map[string][]float64, error) {
+	key := metricDataKey{Queries: queries, Start: start, End: end}
+
+	if values, ok := cache.Get("getMetricData", key).(map[string][]float64); ok {
+		return values, nil
+	}
+
+	values := map[string][]float64{}
+
+	for i := 0; i < len(queries); i += metricDataQueryLimit {
+		j := i + metricDataQueryLimit
+		if j > len(queries) {
+			j = len(queries)
+		}
+
+		req := &cloudwatch.GetMetricDataInput{
+			EndTime:           aws.Time(end),
+			MetricDataQueries: queries[i:j],
+			ScanBy:            aws.String("TimestampAscending"),
+			StartTime:         aws.Time(start),
+		}
+
+		err := p.cloudwatch().GetMetricDataPages(req, func(res *cloudwatch.GetMetricDataOutput, last bool) bool {
+			for _, r := range res.MetricDataResults {
+				id := aws.StringValue(r.Id)
+
+				for _, v := range r.Values {
+					if v != nil {
+						values[id] = append(values[id], *v)
+					}
+				}
+			}
+
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !p.SkipCache {
+		if err := cache.Set("getMetricData", key, values, 1*time.Hour); err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
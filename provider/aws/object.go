@@ -1,16 +1,20 @@
 package aws
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/convox/rack/pkg/structs"
@@ -52,7 +56,7 @@ func (p *Provider) ObjectExists(app, key string) (bool, error) {
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	})
-	if err, ok := err.(awserr.Error); ok && err.Code() == "NotFound" {
+	if isNotFound(err) {
 		return false, nil
 	}
 	if err != nil {
@@ -73,7 +77,7 @@ func (p *Provider) ObjectFetch(app, key string) (io.ReadCloser, error) {
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	})
-	if ae, ok := err.(awserr.Error); ok && ae.Code() == "NoSuchKey" {
+	if isNotFound(err) {
 		return nil, errorNotFound(fmt.Sprintf("key not found: %s", key))
 	}
 	if err != nil {
@@ -110,16 +114,74 @@ func (p *Provider) ObjectList(app, prefix string) ([]string, error) {
 	return objects, nil
 }
 
+// object kinds with a fixed place in the settings buckets, see objectKey
+const (
+	objectKindBuild    = "build"
+	objectKindEnv      = "env"
+	objectKindTemplate = "template"
+	objectKindTemp     = "tmp"
+)
+
+// objectKey returns where an object of kind with id is stored so lifecycle rules and the
+// export tooling can rely on the bucket layout. Objects in an app bucket pass an empty app,
+// objects for an app kept in the rack bucket are nested under apps/<app>/
+//
+//	build     build/<id>/logs
+//	env       releases/<id>/env
+//	template  templates/<id>
+//	tmp       tmp/<id>
+//
+// other kinds are stored as <kind>/<id>
+func objectKey(kind, app, id string) string {
+	key := ""
+
+	switch kind {
+	case objectKindBuild:
+		key = fmt.Sprintf("build/%s/logs", id)
+	case objectKindEnv:
+		key = fmt.Sprintf("releases/%s/env", id)
+	case objectKindTemplate:
+		key = fmt.Sprintf("templates/%s", id)
+	default:
+		key = fmt.Sprintf("%s/%s", kind, id)
+	}
+
+	if app != "" {
+		key = fmt.Sprintf("apps/%s/%s", app, key)
+	}
+
+	return key
+}
+
 // ObjectStore stores an Object
+// Without a key one is generated under tmp/, or under opts.Prefix when it is set
 func (p *Provider) ObjectStore(app, key string, r io.Reader, opts structs.ObjectStoreOptions) (*structs.Object, error) {
 	log := Logger.At("ObjectStore").Namespace("app=%q key=%q", app, key).Start()
 
-	if key == "" {
-		k, err := generateTempKey()
+	prefix := ""
+
+	if opts.Prefix != nil {
+		prefix = strings.Trim(*opts.Prefix, "/")
+
+		if strings.Contains(prefix, "..") {
+			return nil, log.Error(fmt.Errorf("invalid object prefix: %s", *opts.Prefix))
+		}
+	}
+
+	switch {
+	case key == "":
+		id, err := generateObjectId()
 		if err != nil {
 			return nil, log.Error(err)
 		}
-		key = k
+
+		if prefix != "" {
+			key = path.Join(prefix, id)
+		} else {
+			key = objectKey(objectKindTemp, "", id)
+		}
+	case prefix != "":
+		key = path.Join(prefix, key)
 	}
 
 	log = log.Replace("key", key)
@@ -132,9 +194,12 @@ func (p *Provider) ObjectStore(app, key string, r io.Reader, opts structs.Object
 	up := s3manager.NewUploaderWithClient(p.s3())
 
 	req := &s3manager.UploadInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-		Body:   r,
+		Bucket:             aws.String(bucket),
+		Key:                aws.String(key),
+		Body:               r,
+		CacheControl:       opts.CacheControl,
+		ContentDisposition: opts.ContentDisposition,
+		ContentType:        opts.ContentType,
 	}
 
 	_, err = up.Upload(req)
@@ -198,7 +263,74 @@ func (p *Provider) objectPresignedURL(o *structs.Object, duration time.Duration)
 	return su, nil
 }
 
-func generateTempKey() (string, error) {
+// s3PresignPost generates a signed POST policy allowing a browser to upload
+// objects up to maxSize bytes under keyPrefix directly to bucket
+func (p *Provider) s3PresignPost(bucket, keyPrefix string, maxSize int64, expires time.Duration) (*structs.PresignedPost, error) {
+	creds, err := p.s3().Config.Credentials.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+	stamp := now.Format("20060102T150405Z")
+	credential := fmt.Sprintf("%s/%s/%s/s3/aws4_request", creds.AccessKeyID, date, p.Region)
+
+	conditions := []interface{}{
+		map[string]string{"bucket": bucket},
+		[]interface{}{"starts-with", "$key", keyPrefix},
+		[]interface{}{"content-length-range", 0, maxSize},
+		map[string]string{"x-amz-algorithm": "AWS4-HMAC-SHA256"},
+		map[string]string{"x-amz-credential": credential},
+		map[string]string{"x-amz-date": stamp},
+	}
+
+	if creds.SessionToken != "" {
+		conditions = append(conditions, map[string]string{"x-amz-security-token": creds.SessionToken})
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"expiration": now.Add(expires).Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	policy := base64.StdEncoding.EncodeToString(data)
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+
+	for _, part := range []string{date, p.Region, "s3", "aws4_request", policy} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+
+	pp := &structs.PresignedPost{
+		Url: fmt.Sprintf("https://s3.%s.amazonaws.com/%s", p.Region, bucket),
+		Fields: map[string]string{
+			"key":              keyPrefix + "${filename}",
+			"policy":           policy,
+			"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+			"x-amz-credential": credential,
+			"x-amz-date":       stamp,
+			"x-amz-signature":  hex.EncodeToString(key),
+		},
+	}
+
+	if p.Endpoint != "" {
+		pp.Url = fmt.Sprintf("%s/%s", p.Endpoint, bucket)
+	}
+
+	if creds.SessionToken != "" {
+		pp.Fields["x-amz-security-token"] = creds.SessionToken
+	}
+
+	return pp, nil
+}
+
+func generateObjectId() (string, error) {
 	data := make([]byte, 1024)
 
 	if _, err := rand.Read(data); err != nil {
@@ -207,5 +339,5 @@ func generateTempKey() (string, error) {
 
 	hash := sha256.Sum256(data)
 
-	return fmt.Sprintf("tmp/%s", hex.EncodeToString(hash[:])[0:30]), nil
+	return hex.EncodeToString(hash[:])[0:30], nil
 }
@@ -1,6 +1,202 @@
 package aws_test
 
-import "github.com/convox/rack/pkg/test/awsutil"
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/options"
+	"github.com/convox/rack/pkg/structs"
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3PresignPost(t *testing.T) {
+	provider := StubAwsProvider()
+	defer provider.Close()
+
+	pp, err := provider.S3PresignPost("convox-settings", "uploads/", 1048576, 10*time.Minute)
+	require.NoError(t, err)
+
+	assert.Equal(t, provider.Endpoint+"/convox-settings", pp.Url)
+	assert.Equal(t, "uploads/${filename}", pp.Fields["key"])
+	assert.Equal(t, "AWS4-HMAC-SHA256", pp.Fields["x-amz-algorithm"])
+	assert.Regexp(t, `^test-access/\d{8}/us-test-1/s3/aws4_request$`, pp.Fields["x-amz-credential"])
+	assert.Regexp(t, `^[0-9a-f]{64}$`, pp.Fields["x-amz-signature"])
+
+	data, err := base64.StdEncoding.DecodeString(pp.Fields["policy"])
+	require.NoError(t, err)
+
+	var policy struct {
+		Expiration string
+		Conditions []interface{}
+	}
+
+	require.NoError(t, json.Unmarshal(data, &policy))
+
+	assert.NotEmpty(t, policy.Expiration)
+	assert.Contains(t, policy.Conditions, map[string]interface{}{"bucket": "convox-settings"})
+	assert.Contains(t, policy.Conditions, []interface{}{"starts-with", "$key", "uploads/"})
+	assert.Contains(t, policy.Conditions, []interface{}{"content-length-range", float64(0), float64(1048576)})
+}
+
+func TestObjectKey(t *testing.T) {
+	assert.Equal(t, "build/B1234/logs", aws.ObjectKey("build", "", "B1234"))
+	assert.Equal(t, "releases/R1234/env", aws.ObjectKey("env", "", "R1234"))
+	assert.Equal(t, "templates/R1234", aws.ObjectKey("template", "", "R1234"))
+	assert.Equal(t, "tmp/abc123", aws.ObjectKey("tmp", "", "abc123"))
+	assert.Equal(t, "apps/httpd/build-webhook/secret", aws.ObjectKey("build-webhook", "httpd", "secret"))
+	assert.Equal(t, "apps/httpd/releases/R1234/env", aws.ObjectKey("env", "httpd", "R1234"))
+}
+
+func TestObjectStoreHeaders(t *testing.T) {
+	provider := objectTestProvider()
+	defer provider.Close()
+
+	o, err := provider.ObjectStore("httpd", "build/B1234/logs", bytes.NewReader([]byte("logs")), structs.ObjectStoreOptions{
+		CacheControl:       options.String("max-age=3600"),
+		ContentDisposition: options.String(`attachment; filename="B1234.log"`),
+		ContentType:        options.String("text/plain"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "object://httpd/build/B1234/logs", o.Url)
+
+	headers, ok := provider.Fake.S3.Headers("convox-httpd-settings", "build/B1234/logs")
+	require.True(t, ok)
+	assert.Equal(t, "max-age=3600", headers["Cache-Control"])
+	assert.Equal(t, `attachment; filename="B1234.log"`, headers["Content-Disposition"])
+	assert.Equal(t, "text/plain", headers["Content-Type"])
+}
+
+func TestObjectStorePrefix(t *testing.T) {
+	provider := objectTestProvider()
+	defer provider.Close()
+
+	o, err := provider.ObjectStore("httpd", "", bytes.NewReader([]byte("data")), structs.ObjectStoreOptions{})
+	require.NoError(t, err)
+	assert.Regexp(t, `^object://httpd/tmp/[0-9a-f]{30}$`, o.Url)
+
+	o, err = provider.ObjectStore("httpd", "", bytes.NewReader([]byte("data")), structs.ObjectStoreOptions{Prefix: options.String("/exports/")})
+	require.NoError(t, err)
+	assert.Regexp(t, `^object://httpd/exports/[0-9a-f]{30}$`, o.Url)
+
+	o, err = provider.ObjectStore("httpd", "report.txt", bytes.NewReader([]byte("data")), structs.ObjectStoreOptions{Prefix: options.String("exports")})
+	require.NoError(t, err)
+	assert.Equal(t, "object://httpd/exports/report.txt", o.Url)
+
+	_, ok := provider.Fake.S3.Object("convox-httpd-settings", "exports/report.txt")
+	assert.True(t, ok)
+
+	_, err = provider.ObjectStore("httpd", "report.txt", bytes.NewReader([]byte("data")), structs.ObjectStoreOptions{Prefix: options.String("../other")})
+	require.EqualError(t, err, "invalid object prefix: ../other")
+}
+
+func TestObjectFetchExistingKey(t *testing.T) {
+	provider := objectTestProvider()
+	defer provider.Close()
+
+	// objects stored before the key convention still resolve by the key in their object:// url
+	provider.Fake.S3.PutObject("convox-httpd-settings", "build/B1234/logs", []byte("legacy"), nil)
+
+	r, err := provider.ObjectFetch("httpd", "build/B1234/logs")
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", string(data))
+}
+
+func TestS3PutWithOptions(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	require.NoError(t, provider.S3PutWithOptions("convox-settings", "templates/R1234", []byte("{}"), "no-cache", "inline", "application/json"))
+
+	headers, ok := provider.Fake.S3.Headers("convox-settings", "templates/R1234")
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"Cache-Control": "no-cache", "Content-Disposition": "inline", "Content-Type": "application/json"}, headers)
+}
+
+func TestS3PutTags(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	tags := map[string]string{
+		"app":     "httpd",
+		"kind":    "build artifact",
+		"owner":   "ops@example.org",
+		"path":    "builds/B1234",
+		"expires": "a+b=c",
+	}
+
+	require.NoError(t, provider.S3PutTagged("convox-settings", "builds/B1234.tgz", []byte("data"), tags))
+
+	tagging, ok := provider.Fake.S3.Tagging("convox-settings", "builds/B1234.tgz")
+	require.True(t, ok)
+	assert.Equal(t, "app=httpd&expires=a%2Bb%3Dc&kind=build%20artifact&owner=ops%40example.org&path=builds%2FB1234", tagging)
+
+	parsed, err := url.ParseQuery(tagging)
+	require.NoError(t, err)
+	assert.Equal(t, "build artifact", parsed.Get("kind"))
+	assert.Equal(t, "a+b=c", parsed.Get("expires"))
+
+	require.NoError(t, provider.S3PutTagged("convox-settings", "untagged", []byte("data"), nil))
+
+	tagging, ok = provider.Fake.S3.Tagging("convox-settings", "untagged")
+	require.True(t, ok)
+	assert.Equal(t, "", tagging)
+}
+
+func TestS3PutTagsInvalid(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	many := map[string]string{}
+
+	for i := 0; i < 11; i++ {
+		many[fmt.Sprintf("k%d", i)] = "v"
+	}
+
+	tests := []struct {
+		tags map[string]string
+		err  string
+	}{
+		{many, "objects can have at most 10 tags"},
+		{map[string]string{"": "v"}, `tag key must be between 1 and 128 characters: ""`},
+		{map[string]string{strings.Repeat("k", 129): "v"}, fmt.Sprintf("tag key must be between 1 and 128 characters: %q", strings.Repeat("k", 129))},
+		{map[string]string{"aws:created": "v"}, `tag key can not start with aws:: "aws:created"`},
+		{map[string]string{"a&b": "v"}, `tag key has invalid characters: "a&b"`},
+		{map[string]string{"k": strings.Repeat("v", 257)}, "tag value for k must be at most 256 characters"},
+		{map[string]string{"k": "a?b"}, `tag value for k has invalid characters: "a?b"`},
+	}
+
+	for _, tt := range tests {
+		err := provider.S3PutTagged("convox-settings", "invalid", []byte("data"), tt.tags)
+		assert.EqualError(t, err, tt.err)
+	}
+
+	_, ok := provider.Fake.S3.Object("convox-settings", "invalid")
+	assert.False(t, ok)
+}
+
+func objectTestProvider() *awsfake.TestProvider {
+	provider := awsfake.NewTestProvider()
+
+	provider.AddApp("httpd", "1", awsfake.Stack{
+		Resources: []awsfake.Resource{{LogicalId: "Settings", PhysicalId: "convox-httpd-settings"}},
+	})
+
+	return provider
+}
 
 var cycleObjectListStackResources = awsutil.Cycle{
 	Request: awsutil.Request{
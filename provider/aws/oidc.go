@@ -0,0 +1,118 @@
+package aws
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+const (
+	// oidcAudience is the client id sts expects in the tokens workloads exchange for role credentials
+	oidcAudience = "sts.amazonaws.com"
+
+	// oidcThumbprintLimit is the most server certificate thumbprints iam accepts for a provider
+	oidcThumbprintLimit = 5
+)
+
+var oidcThumbprintValidator = regexp.MustCompile(`^[0-9A-Fa-f]{40}$`)
+
+// OIDCProviderInfo describes an iam openid connect provider that roles can trust for web identity federation
+type OIDCProviderInfo struct {
+	Arn         string
+	Audiences   []string
+	Created     time.Time
+	Thumbprints []string
+	Url         string
+}
+
+// CreateOIDCProvider registers the openid connect issuer at issuerURL with iam so roles can trust the tokens it signs
+// thumbprints are the sha1 fingerprints of the certificates that serve the issuer, as 40 hex characters each
+func (p *Provider) CreateOIDCProvider(issuerURL string, thumbprints []string) (string, error) {
+	log := Logger.At("CreateOIDCProvider").Namespace("issuer=%q", issuerURL).Start()
+
+	if err := validateOIDCProvider(issuerURL, thumbprints); err != nil {
+		return "", log.Error(err)
+	}
+
+	res, err := p.iam().CreateOpenIDConnectProvider(&iam.CreateOpenIDConnectProviderInput{
+		ClientIDList:   []*string{aws.String(oidcAudience)},
+		ThumbprintList: aws.StringSlice(thumbprints),
+		Url:            aws.String(issuerURL),
+	})
+	if isConflict(err) {
+		return "", log.Error(fmt.Errorf("oidc provider for %s already exists", issuerURL))
+	}
+	if err != nil {
+		return "", log.Error(err)
+	}
+
+	arn := aws.StringValue(res.OpenIDConnectProviderArn)
+
+	return arn, log.Successf("arn=%s", arn)
+}
+
+// GetOIDCProvider returns the issuer, audiences and thumbprints of an iam openid connect provider
+func (p *Provider) GetOIDCProvider(arn string) (*OIDCProviderInfo, error) {
+	res, err := p.iam().GetOpenIDConnectProvider(&iam.GetOpenIDConnectProviderInput{
+		OpenIDConnectProviderArn: aws.String(arn),
+	})
+	if isNotFound(err) {
+		return nil, errorNotFound(fmt.Sprintf("oidc provider not found: %s", arn))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCProviderInfo{
+		Arn:         arn,
+		Audiences:   aws.StringValueSlice(res.ClientIDList),
+		Created:     aws.TimeValue(res.CreateDate),
+		Thumbprints: aws.StringValueSlice(res.ThumbprintList),
+		Url:         aws.StringValue(res.Url),
+	}, nil
+}
+
+// DeleteOIDCProvider removes an iam openid connect provider, roles that trust it can no longer be assumed with its tokens
+func (p *Provider) DeleteOIDCProvider(arn string) error {
+	log := Logger.At("DeleteOIDCProvider").Namespace("arn=%s", arn).Start()
+
+	_, err := p.iam().DeleteOpenIDConnectProvider(&iam.DeleteOpenIDConnectProviderInput{
+		OpenIDConnectProviderArn: aws.String(arn),
+	})
+	if isNotFound(err) {
+		return log.Error(errorNotFound(fmt.Sprintf("oidc provider not found: %s", arn)))
+	}
+	if err != nil {
+		return log.Error(err)
+	}
+
+	return log.Success()
+}
+
+// validateOIDCProvider returns an error if issuerURL is not an https url or a thumbprint is not a sha1 hex fingerprint
+func validateOIDCProvider(issuerURL string, thumbprints []string) error {
+	u, err := url.Parse(issuerURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("oidc issuer url %q invalid, must be an https url", issuerURL)
+	}
+
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("oidc issuer url %q invalid, must not have a query or fragment", issuerURL)
+	}
+
+	if len(thumbprints) == 0 || len(thumbprints) > oidcThumbprintLimit {
+		return fmt.Errorf("oidc thumbprints invalid, must have between 1 and %d", oidcThumbprintLimit)
+	}
+
+	for _, t := range thumbprints {
+		if !oidcThumbprintValidator.MatchString(t) {
+			return fmt.Errorf("oidc thumbprint %q invalid, must be 40 hex characters", t)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package aws_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const oidcThumbprint = "9e99a48a9960b14926bb7f3b02e22da2b0ab7280"
+
+func TestOIDCProvider(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	arn, err := provider.CreateOIDCProvider("https://oidc.example.org/id/ABC123", []string{oidcThumbprint})
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws:iam::123456789012:oidc-provider/oidc.example.org/id/ABC123", arn)
+
+	info, err := provider.GetOIDCProvider(arn)
+	require.NoError(t, err)
+	assert.Equal(t, arn, info.Arn)
+	assert.Equal(t, []string{"sts.amazonaws.com"}, info.Audiences)
+	assert.Equal(t, []string{oidcThumbprint}, info.Thumbprints)
+	assert.Equal(t, "oidc.example.org/id/ABC123", info.Url)
+	assert.False(t, info.Created.IsZero())
+
+	_, err = provider.CreateOIDCProvider("https://oidc.example.org/id/ABC123", []string{oidcThumbprint})
+	require.EqualError(t, err, "oidc provider for https://oidc.example.org/id/ABC123 already exists")
+
+	require.NoError(t, provider.DeleteOIDCProvider(arn))
+	assert.Empty(t, provider.Fake.IAM.OIDCProviders())
+
+	_, err = provider.GetOIDCProvider(arn)
+	require.EqualError(t, err, "oidc provider not found: "+arn)
+	assert.Equal(t, 404, err.(interface{ Code() int }).Code())
+
+	err = provider.DeleteOIDCProvider(arn)
+	require.EqualError(t, err, "oidc provider not found: "+arn)
+}
+
+func TestOIDCProviderValidation(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	tests := []struct {
+		url         string
+		thumbprints []string
+		err         string
+	}{
+		{"http://oidc.example.org", []string{oidcThumbprint}, `oidc issuer url "http://oidc.example.org" invalid, must be an https url`},
+		{"oidc.example.org", []string{oidcThumbprint}, `oidc issuer url "oidc.example.org" invalid, must be an https url`},
+		{"https://", []string{oidcThumbprint}, `oidc issuer url "https://" invalid, must be an https url`},
+		{"https://oidc.example.org?a=b", []string{oidcThumbprint}, `oidc issuer url "https://oidc.example.org?a=b" invalid, must not have a query or fragment`},
+		{"https://oidc.example.org", nil, "oidc thumbprints invalid, must have between 1 and 5"},
+		{"https://oidc.example.org", []string{oidcThumbprint, oidcThumbprint, oidcThumbprint, oidcThumbprint, oidcThumbprint, oidcThumbprint}, "oidc thumbprints invalid, must have between 1 and 5"},
+		{"https://oidc.example.org", []string{oidcThumbprint[:39]}, `oidc thumbprint "9e99a48a9960b14926bb7f3b02e22da2b0ab728" invalid, must be 40 hex characters`},
+		{"https://oidc.example.org", []string{oidcThumbprint + "0"}, `oidc thumbprint "9e99a48a9960b14926bb7f3b02e22da2b0ab72800" invalid, must be 40 hex characters`},
+		{"https://oidc.example.org", []string{oidcThumbprint, strings.Repeat("g", 40)}, `oidc thumbprint "gggggggggggggggggggggggggggggggggggggggg" invalid, must be 40 hex characters`},
+		{"https://oidc.example.org", []string{"9E99:A48A:9960:B149:26BB:7F3B:02E2:2DA2:B0AB"}, `oidc thumbprint "9E99:A48A:9960:B149:26BB:7F3B:02E2:2DA2:B0AB" invalid, must be 40 hex characters`},
+	}
+
+	for _, tt := range tests {
+		_, err := provider.CreateOIDCProvider(tt.url, tt.thumbprints)
+		assert.EqualError(t, err, tt.err, tt.url)
+	}
+
+	assert.Empty(t, provider.Fake.IAM.OIDCProviders())
+
+	_, err := provider.CreateOIDCProvider("https://oidc.example.org", []string{strings.ToUpper(oidcThumbprint)})
+	require.NoError(t, err)
+}
@@ -0,0 +1,139 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+// executionRolePullActions are the ecr actions a task execution role needs to pull an image from a repository
+var executionRolePullActions = []string{"ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer"}
+
+// executionRoleCanPull returns an error listing the permissions role is missing to pull image
+// images outside ecr are not governed by iam so they always pass
+func (p *Provider) executionRoleCanPull(role, image string) error {
+	m := regexpECRImage.FindStringSubmatch(image)
+	if len(m) < 5 {
+		return nil
+	}
+
+	repo := arnParts{Partition: arnPartition(m[2]), Service: "ecr", Region: m[2], Account: m[1], ResourceType: "repository", Resource: m[3]}.String()
+
+	// the authorization token is not tied to a repository so it is checked on its own
+	missing, err := p.simulateDenied(role, []string{"ecr:GetAuthorizationToken"}, "*")
+	if err != nil {
+		return err
+	}
+
+	denied, err := p.simulateDenied(role, executionRolePullActions, repo)
+	if err != nil {
+		return err
+	}
+
+	missing = append(missing, denied...)
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("execution role %s can not pull %s, missing %s", role, image, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// simulateDenied returns the actions the policies of principal do not allow on resource along with the reason, e.g. ecr:BatchGetImage (implicitDeny)
+func (p *Provider) simulateDenied(principal string, actions []string, resource string) ([]string, error) {
+	denied := []string{}
+
+	req := &iam.SimulatePrincipalPolicyInput{
+		ActionNames:     aws.StringSlice(actions),
+		PolicySourceArn: aws.String(principal),
+		ResourceArns:    aws.StringSlice([]string{resource}),
+	}
+
+	err := p.iam().SimulatePrincipalPolicyPages(req, func(res *iam.SimulatePolicyResponse, last bool) bool {
+		for _, r := range res.EvaluationResults {
+			if d := aws.StringValue(r.EvalDecision); d != iam.PolicyEvaluationDecisionTypeAllowed {
+				denied = append(denied, fmt.Sprintf("%s (%s)", aws.StringValue(r.EvalActionName), d))
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return denied, nil
+}
+
+// resourceTypeNamespaces maps the service of a cloudformation resource type, e.g. ECS in AWS::ECS::Service,
+// to the iam namespace of the api that manages it, it covers the types the rack and app templates use
+var resourceTypeNamespaces = map[string]string{
+	"ApplicationAutoScaling": "application-autoscaling",
+	"AutoScaling":            "autoscaling",
+	"CertificateManager":     "acm",
+	"CloudFormation":         "cloudformation",
+	"DynamoDB":               "dynamodb",
+	"EC2":                    "ec2",
+	"ECR":                    "ecr",
+	"ECS":                    "ecs",
+	"EFS":                    "elasticfilesystem",
+	"ElastiCache":            "elasticache",
+	"ElasticLoadBalancing":   "elasticloadbalancing",
+	"ElasticLoadBalancingV2": "elasticloadbalancing",
+	"Events":                 "events",
+	"IAM":                    "iam",
+	"KMS":                    "kms",
+	"Lambda":                 "lambda",
+	"Logs":                   "logs",
+	"RDS":                    "rds",
+	"Route53":                "route53",
+	"S3":                     "s3",
+	"SNS":                    "sns",
+	"SQS":                    "sqs",
+}
+
+// templateActions returns the iam actions, as namespace wildcards like ecs:*, that managing the resources of a template requires
+// resource types it has no namespace for are returned in unknown, custom resources need only the lambda that serves them
+func templateActions(data []byte) (actions []string, unknown []string, err error) {
+	f, err := parseFormation(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	as := map[string]bool{}
+	us := map[string]bool{}
+
+	for _, r := range f.Resources {
+		parts := strings.Split(r.Type, "::")
+
+		switch {
+		case len(parts) == 2 && parts[0] == "Custom":
+			as["lambda:InvokeFunction"] = true
+		case len(parts) == 3 && parts[0] == "AWS" && resourceTypeNamespaces[parts[1]] != "":
+			as[resourceTypeNamespaces[parts[1]]+":*"] = true
+		default:
+			us[r.Type] = true
+		}
+	}
+
+	actions = []string{}
+
+	for a := range as {
+		actions = append(actions, a)
+	}
+
+	unknown = []string{}
+
+	for u := range us {
+		unknown = append(unknown, u)
+	}
+
+	sort.Strings(actions)
+	sort.Strings(unknown)
+
+	return actions, unknown, nil
+}
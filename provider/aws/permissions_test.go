@@ -0,0 +1,85 @@
+package aws_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	permissionsTestImage = "123456789012.dkr.ecr.us-test-1.amazonaws.com/convox-httpd:web.BABCDEFGHI"
+	permissionsTestRepo  = "arn:aws:ecr:us-test-1:123456789012:repository/convox-httpd"
+	permissionsTestRole  = "arn:aws:iam::123456789012:role/convox-httpd-ExecutionRole"
+)
+
+func TestExecutionRoleCanPull(t *testing.T) {
+	p := awsfake.NewTestProvider()
+	defer p.Close()
+
+	p.Fake.IAM.Allow(permissionsTestRole, "ecr:GetAuthorizationToken", "*")
+	p.Fake.IAM.Allow(permissionsTestRole, "ecr:BatchGetImage", permissionsTestRepo)
+	p.Fake.IAM.Allow(permissionsTestRole, "ecr:GetDownloadUrlForLayer", permissionsTestRepo)
+
+	require.NoError(t, p.ExecutionRoleCanPull(permissionsTestRole, permissionsTestImage))
+}
+
+func TestExecutionRoleCanPullDenied(t *testing.T) {
+	p := awsfake.NewTestProvider()
+	defer p.Close()
+
+	p.Fake.IAM.Allow(permissionsTestRole, "ecr:GetAuthorizationToken", "*")
+	p.Fake.IAM.Deny(permissionsTestRole, "ecr:BatchGetImage", permissionsTestRepo)
+
+	err := p.ExecutionRoleCanPull(permissionsTestRole, permissionsTestImage)
+	require.EqualError(t, err, "execution role arn:aws:iam::123456789012:role/convox-httpd-ExecutionRole can not pull 123456789012.dkr.ecr.us-test-1.amazonaws.com/convox-httpd:web.BABCDEFGHI, missing ecr:BatchGetImage (explicitDeny), ecr:GetDownloadUrlForLayer (implicitDeny)")
+}
+
+func TestExecutionRoleCanPullImplicitDeny(t *testing.T) {
+	p := awsfake.NewTestProvider()
+	defer p.Close()
+
+	err := p.ExecutionRoleCanPull(permissionsTestRole, permissionsTestImage)
+	require.EqualError(t, err, "execution role arn:aws:iam::123456789012:role/convox-httpd-ExecutionRole can not pull 123456789012.dkr.ecr.us-test-1.amazonaws.com/convox-httpd:web.BABCDEFGHI, missing ecr:BatchGetImage (implicitDeny), ecr:GetAuthorizationToken (implicitDeny), ecr:GetDownloadUrlForLayer (implicitDeny)")
+}
+
+func TestExecutionRoleCanPullPublicImage(t *testing.T) {
+	p := awsfake.NewTestProvider()
+	defer p.Close()
+
+	require.NoError(t, p.ExecutionRoleCanPull(permissionsTestRole, "httpd:2.4"))
+}
+
+func TestTemplateActions(t *testing.T) {
+	template := `{
+		"Resources": {
+			"Bucket": { "Type": "AWS::S3::Bucket" },
+			"BucketPolicy": { "Type": "AWS::S3::BucketPolicy" },
+			"EncryptionKey": { "Type": "Custom::KMSKey" },
+			"Service": { "Type": "AWS::ECS::Service" },
+			"Tasks": { "Type": "AWS::ECS::TaskDefinition" },
+			"Widget": { "Type": "AWS::Widget::Thing" }
+		}
+	}`
+
+	actions, unknown, err := aws.TemplateActions([]byte(template))
+	require.NoError(t, err)
+	require.Equal(t, []string{"ecs:*", "lambda:InvokeFunction", "s3:*"}, actions)
+	require.Equal(t, []string{"AWS::Widget::Thing"}, unknown)
+
+	_, _, err = aws.TemplateActions([]byte("{"))
+	require.Error(t, err)
+}
+
+func TestTemplateActionsRack(t *testing.T) {
+	data, err := ioutil.ReadFile("formation/rack.json")
+	require.NoError(t, err)
+
+	actions, unknown, err := aws.TemplateActions(data)
+	require.NoError(t, err)
+	require.Empty(t, unknown)
+	require.Contains(t, actions, "ecs:*")
+	require.Contains(t, actions, "ec2:*")
+}
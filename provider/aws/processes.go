@@ -1,10 +1,10 @@
 package aws
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"math"
 	"net/url"
 	"sort"
@@ -13,7 +13,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ecs"
@@ -49,21 +49,14 @@ func (p *Provider) ProcessExec(app, pid, command string, rw io.ReadWriter, opts
 		return -1, errorNotFound(fmt.Sprintf("process id not found for %s", app))
 	}
 
-	dc, err := p.dockerClientFromPid(pid)
-	if err != nil {
-		return -1, err
-	}
-
-	c, err := p.dockerContainerFromPid(pid)
-	if err != nil {
-		return -1, err
-	}
-
 	cmd := []string{"sh", "-c", command}
 
-	tty := cb(opts.Tty, true)
-
 	if opts.Entrypoint != nil && *opts.Entrypoint {
+		c, err := p.dockerContainerFromPid(pid)
+		if err != nil {
+			return -1, err
+		}
+
 		cmd = append(c.Config.Entrypoint, cmd...)
 	} else {
 		a, err := p.AppGet(app)
@@ -76,48 +69,24 @@ func (p *Provider) ProcessExec(app, pid, command string, rw io.ReadWriter, opts
 		}
 	}
 
-	eres, err := dc.CreateExec(docker.CreateExecOptions{
-		AttachStdin:  true,
-		AttachStdout: true,
-		AttachStderr: true,
-		Tty:          tty,
-		Cmd:          cmd,
-		Container:    c.ID,
-	})
-	if err != nil {
-		return -1, log.Error(err)
-	}
-
-	success := make(chan struct{})
-
-	go func() {
-		<-success
-		if opts.Height != nil && opts.Width != nil {
-			dc.ResizeExecTTY(eres.ID, *opts.Height, *opts.Width)
-		}
-		success <- struct{}{}
-	}()
+	eopts := processExecOptions{TTY: cb(opts.Tty, true)}
 
-	err = dc.StartExec(eres.ID, docker.StartExecOptions{
-		Detach:       false,
-		Tty:          tty,
-		InputStream:  ioutil.NopCloser(rw),
-		OutputStream: rw,
-		ErrorStream:  rw,
-		RawTerminal:  true,
-		Success:      success,
-	})
+	if opts.Height != nil && opts.Width != nil {
+		eopts.Height = *opts.Height
+		eopts.Width = *opts.Width
+	}
 
+	s, err := p.processExec(p.Context(), app, pid, cmd, rw, eopts)
 	if err != nil {
 		return -1, log.Error(err)
 	}
 
-	ires, err := dc.InspectExec(eres.ID)
+	code, err := s.Wait()
 	if err != nil {
 		return -1, log.Error(err)
 	}
 
-	return ires.ExitCode, log.Success()
+	return code, log.Success()
 }
 
 // ProcessGet returns the specified process for an app
@@ -239,7 +208,7 @@ func (p *Provider) stackTasks(stack string) ([]string, error) {
 // This includes one-off processes, build tasks, etc.
 func (p *Provider) appTaskARNs(app string) ([]string, error) {
 	tasks, err := p.stackTasks(fmt.Sprintf("%s-%s", p.Rack, app))
-	if ae, ok := err.(awserr.Error); ok && ae.Code() == "ValidationError" {
+	if isNotFound(err) {
 		return nil, errorNotFound(fmt.Sprintf("app not found: %s", app))
 	}
 	if err != nil {
@@ -431,7 +400,13 @@ func (p *Provider) ProcessRun(app, service string, opts structs.ProcessRunOption
 		}
 	}
 
-	task, err := p.runTask(req)
+	ropts := []request.Option{}
+
+	if p.EcsExec {
+		ropts = append(ropts, ecsRequestField("enableExecuteCommand", true))
+	}
+
+	task, err := p.runTask(req, ropts...)
 	if err != nil {
 		return nil, log.Error(err)
 	}
@@ -486,9 +461,12 @@ func (p *Provider) stopTaskFromCluster(cluster, arn string) error {
 	return err
 }
 
+// arnToPid returns the process id of a task, the last 12 characters of its id
 func arnToPid(arn string) string {
-	parts := strings.Split(arn, "/")
-	id := parts[len(parts)-1]
+	id, err := taskIdFromArn(arn)
+	if err != nil {
+		id = arn[strings.LastIndex(arn, "/")+1:]
+	}
 
 	if len(id) > 12 {
 		id = id[len(id)-12:]
@@ -647,6 +625,18 @@ func (p *Provider) describeTask(arn string) (*ecs.Task, error) {
 	return nil, err
 }
 
+// taskContainer returns the container of a task with name, or its first container when none matches
+// tasks of services with sidecars run several containers that ecs lists in no particular order
+func taskContainer(task *ecs.Task, name string) *ecs.Container {
+	for _, c := range task.Containers {
+		if aws.StringValue(c.Name) == name {
+			return c
+		}
+	}
+
+	return task.Containers[0]
+}
+
 func (p *Provider) fetchProcess(task *ecs.Task, psch chan structs.Process, errch chan error) {
 	if len(task.Containers) < 1 {
 		errch <- fmt.Errorf("invalid task: %s", *task.TaskDefinitionArn)
@@ -675,7 +665,7 @@ func (p *Provider) fetchProcess(task *ecs.Task, psch chan structs.Process, errch
 		labels[k] = *v
 	}
 
-	container := task.Containers[0]
+	container := taskContainer(task, aws.StringValue(cd.Name))
 
 	ports := []string{}
 	for _, p := range container.NetworkBindings {
@@ -803,39 +793,35 @@ func (p *Provider) generateTaskDefinition1(app, service string, opts structs.Pro
 		return nil, err
 	}
 
-	sarn := ""
-	sn := fmt.Sprintf("Service%s", upperName(service))
+	sarn, err := p.serviceArnFromResources(app, service, srs)
+	if err != nil {
+		return nil, err
+	}
 
 	secureEnvRoleName := ""
 
 	for _, sr := range srs {
-		if *sr.LogicalResourceId == sn {
-			sarn = *sr.PhysicalResourceId
-		}
 		if *sr.LogicalResourceId == "SecureEnvironmentRole" {
 			secureEnvRoleName = *sr.PhysicalResourceId
 		}
 	}
-	if sarn == "" {
-		return nil, fmt.Errorf("could not find service: %s", service)
-	}
 	if secureEnvRoleName == "" && s.UseSecureEnvironment() {
 		return nil, fmt.Errorf("cound not find secure environment role for service: %s", service)
 	}
 
-	sres, err := p.describeServices(&ecs.DescribeServicesInput{
+	ss, _, err := p.describeServicesChecked(&ecs.DescribeServicesInput{
 		Cluster:  aws.String(p.Cluster),
 		Services: []*string{aws.String(sarn)},
 	})
 	if err != nil {
 		return nil, err
 	}
-	if len(sres.Services) != 1 {
+	if len(ss) != 1 {
 		return nil, fmt.Errorf("could not look up service for service: %s", service)
 	}
 
 	tres, err := p.describeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
-		TaskDefinition: sres.Services[0].TaskDefinition,
+		TaskDefinition: ss[0].TaskDefinition,
 	})
 	if err != nil {
 		return nil, err
@@ -898,7 +884,7 @@ func (p *Provider) generateTaskDefinition1(app, service string, opts structs.Pro
 	}
 
 	if s.UseSecureEnvironment() {
-		env["SECURE_ENVIRONMENT_URL"] = fmt.Sprintf("https://%s.s3.amazonaws.com/releases/%s/env", settings, release)
+		env["SECURE_ENVIRONMENT_URL"] = fmt.Sprintf("https://%s.s3.amazonaws.com/%s", settings, objectKey(objectKindEnv, "", release))
 		env["SECURE_ENVIRONMENT_TYPE"] = "envfile"
 		env["SECURE_ENVIRONMENT_KEY"] = p.EncryptionKey
 	} else {
@@ -1084,7 +1070,7 @@ func (p *Provider) generateTaskDefinition2(app, service string, opts structs.Pro
 	senv["BUILD"] = b.Id
 	senv["BUILD_DESCRIPTION"] = b.Description
 	senv["CONVOX_ENV_KEY"] = p.EncryptionKey
-	senv["CONVOX_ENV_URL"] = fmt.Sprintf("s3://%s/releases/%s/env", settings, release)
+	senv["CONVOX_ENV_URL"] = fmt.Sprintf("s3://%s/%s", settings, objectKey(objectKindEnv, "", release))
 	senv["CONVOX_ENV_VARS"] = s.EnvironmentKeys()
 	senv["RACK"] = p.Rack
 	senv["RELEASE"] = r.Id
@@ -1136,6 +1122,27 @@ func (p *Provider) generateTaskDefinition2(app, service string, opts structs.Pro
 		}
 	}
 
+	if s.User != "" {
+		cd.User = aws.String(s.User)
+	}
+
+	if s.ReadonlyRootFilesystem {
+		cd.ReadonlyRootFilesystem = aws.Bool(true)
+	}
+
+	if s.WorkingDirectory != "" {
+		cd.WorkingDirectory = aws.String(s.WorkingDirectory)
+	}
+
+	if len(s.CapAdd) > 0 || len(s.CapDrop) > 0 {
+		cd.LinuxParameters = &ecs.LinuxParameters{
+			Capabilities: &ecs.KernelCapabilities{
+				Add:  aws.StringSlice(s.CapAdd),
+				Drop: aws.StringSlice(s.CapDrop),
+			},
+		}
+	}
+
 	req := &ecs.RegisterTaskDefinitionInput{
 		ContainerDefinitions: []*ecs.ContainerDefinition{cd},
 		Family:               aws.String(fmt.Sprintf("%s-%s-%s", p.Rack, app, service)),
@@ -1157,6 +1164,11 @@ func (p *Provider) ProcessWait(app, pid string) (int, error) {
 		return -1, err
 	}
 
+	return taskExitCode(task)
+}
+
+// taskExitCode returns the exit code of a stopped task
+func taskExitCode(task *ecs.Task) (int, error) {
 	if task.StoppedReason != nil && strings.HasPrefix(*task.StoppedReason, "exit:") {
 		p := strings.Split(*task.StoppedReason, ":")
 		if len(p) != 2 {
@@ -1170,7 +1182,11 @@ func (p *Provider) ProcessWait(app, pid string) (int, error) {
 	}
 
 	if len(task.Containers) < 1 {
-		return -1, fmt.Errorf("could not find container for task: %s", arn)
+		return -1, fmt.Errorf("could not find container for task: %s", cs(task.TaskArn, ""))
+	}
+
+	if task.Containers[0].ExitCode == nil {
+		return -1, fmt.Errorf("no exit code for task: %s", cs(task.TaskArn, ""))
 	}
 
 	return int(*task.Containers[0].ExitCode), nil
@@ -1219,8 +1235,11 @@ func (p *Provider) resolveRelease(app, release string) (string, error) {
 	return a.Release, nil
 }
 
-func (p *Provider) runTask(req *ecs.RunTaskInput) (*ecs.Task, error) {
-	res, err := p.ecs().RunTask(req)
+func (p *Provider) runTask(req *ecs.RunTaskInput, opts ...request.Option) (*ecs.Task, error) {
+	// the sdk retries a run that timed out with the same token so ecs does not start a second task
+	opts = append(opts, ecsRequestField("clientToken", idempotencyToken(aws.StringValue(req.StartedBy))))
+
+	res, err := p.ecs().RunTaskWithContext(aws.BackgroundContext(), req, opts...)
 	switch {
 	case err != nil:
 		return nil, err
@@ -1368,8 +1387,16 @@ func (p *Provider) taskDefinitionForRun(app, service string, opts structs.Proces
 		return "", err
 	}
 
+	// DeactivateOrphanedTaskDefinitions can deregister a saved definition, register a new one in its place
 	if task, ok := tasks[fmt.Sprintf("%s.run", service)]; ok {
-		return task, nil
+		res, err := p.ecs().DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{TaskDefinition: aws.String(task)})
+		if err != nil {
+			return "", err
+		}
+
+		if aws.StringValue(res.TaskDefinition.Status) == ecs.TaskDefinitionStatusActive {
+			return task, nil
+		}
 	}
 
 	a, err := p.AppGet(app)
@@ -1444,6 +1471,41 @@ func truncate(f float64, precision int) float64 {
 	return float64(int(f*p)) / p
 }
 
+var taskStatusPollInterval = 1 * time.Second
+
+// waitForTaskStatus polls a task until its last status reaches target
+// a task that stops before reaching target returns an error with the stopped reason
+func (p *Provider) waitForTaskStatus(ctx context.Context, id string, target string) (*ecs.Task, error) {
+	for {
+		task, err := p.describeTaskInner(id)
+		if err != nil {
+			return nil, err
+		}
+
+		status := cs(task.LastStatus, "")
+
+		if status == target {
+			return task, nil
+		}
+
+		if status == "STOPPED" {
+			reason := cs(task.StoppedReason, "unknown")
+
+			if code, err := taskExitCode(task); err == nil && !strings.HasPrefix(reason, "exit:") {
+				reason = fmt.Sprintf("%s (exit code %d)", reason, code)
+			}
+
+			return task, fmt.Errorf("task stopped: %s", reason)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(taskStatusPollInterval):
+		}
+	}
+}
+
 func (p *Provider) waitForTask(arn string) (string, error) {
 	timeout := time.After(300 * time.Second)
 	tick := time.Tick(1 * time.Second)
@@ -2,13 +2,21 @@ package aws_test
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"math/rand"
 	"testing"
+	"time"
 
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/convox/rack/pkg/options"
 	"github.com/convox/rack/pkg/structs"
 	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type streamTester struct {
@@ -34,6 +42,10 @@ func TestProcessExec(t *testing.T) {
 		cycleProcessDescribeTaskDefinition1,
 		cycleProcessDescribeContainerInstances,
 		cycleProcessDescribeRackInstances,
+		cycleProcessDescribeStacks,
+		cycleProcessListTasksRunning,
+		cycleProcessListTasksStopped,
+		cycleProcessDescribeTasks,
 		cycleProcessListTasksRunning,
 		cycleProcessListTasksStopped,
 		cycleProcessDescribeTasks,
@@ -46,7 +58,6 @@ func TestProcessExec(t *testing.T) {
 		cycleProcessDescribeInstances,
 		cycleProcessListTasksRunning,
 		cycleProcessListTasksStopped,
-		cycleProcessDescribeStacks,
 		cycleProcessDescribeStackResources,
 	)
 	defer provider.Close()
@@ -221,6 +232,8 @@ func TestProcessListWithBuildCluster(t *testing.T) {
 }
 
 func TestProcessRunDetached(t *testing.T) {
+	defer aws.SetRandSource(rand.New(rand.NewSource(1)))()
+
 	provider := StubAwsProvider(
 		cycleProcessReleaseGetItem,
 		cycleProcessDescribeStacks,
@@ -262,6 +275,32 @@ func TestProcessRunDetached(t *testing.T) {
 	assert.Equal(t, pse, psa)
 }
 
+func TestRunTaskRetriedAfterTimeout(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	td := provider.Fake.ECS.AddTaskDefinition(&ecs.TaskDefinition{
+		ContainerDefinitions: []*ecs.ContainerDefinition{{Name: awssdk.String("web")}},
+		Family:               awssdk.String("convox-httpd-run"),
+		Revision:             awssdk.Int64(1),
+		Status:               awssdk.String(ecs.TaskDefinitionStatusActive),
+	})
+
+	// the first attempt starts the task but its response never arrives so the sdk retries
+	provider.Fake.DropResponses("RunTask", 1)
+
+	task, err := provider.RunTask(&ecs.RunTaskInput{
+		Cluster:        awssdk.String("cluster-test"),
+		StartedBy:      awssdk.String("convox.httpd"),
+		TaskDefinition: awssdk.String(td),
+	})
+	require.NoError(t, err)
+
+	runs := provider.Fake.ECS.RunTasks()
+	require.Len(t, runs, 1)
+	assert.Equal(t, runs[0], awssdk.StringValue(task.TaskArn))
+}
+
 func TestProcessStop(t *testing.T) {
 	provider := StubAwsProvider(
 		cycleProcessListStackResources,
@@ -616,6 +655,77 @@ var cycleProcessDescribeStackResources = awsutil.Cycle{
 	},
 }
 
+func TestWaitForTaskStatus(t *testing.T) {
+	aws.SetTaskStatusPollInterval(0)
+	defer aws.SetTaskStatusPollInterval(1 * time.Second)
+
+	provider := StubAwsProvider(
+		cycleProcessDescribeTasksPending,
+		cycleProcessDescribeTasks,
+	)
+	defer provider.Close()
+
+	task, err := provider.WaitForTaskStatus(context.Background(), "arn:aws:ecs:us-east-1:778743527532:task/cluster-test/50b8de99-f94f-4ecd-a98f-5850760f0845", "RUNNING")
+	require.NoError(t, err)
+	assert.Equal(t, "RUNNING", *task.LastStatus)
+}
+
+func TestWaitForTaskStatusStopped(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleProcessDescribeTasksStopped,
+	)
+	defer provider.Close()
+
+	task, err := provider.WaitForTaskStatus(context.Background(), "arn:aws:ecs:us-east-1:778743527532:task/50b8de99-f94f-4ecd-a98f-5850760f0845", "RUNNING")
+	require.EqualError(t, err, "task stopped: exit:3")
+	assert.Equal(t, "STOPPED", *task.LastStatus)
+}
+
+func TestWaitForTaskStatusCancel(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleProcessDescribeTasksPending,
+	)
+	defer provider.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := provider.WaitForTaskStatus(ctx, "arn:aws:ecs:us-east-1:778743527532:task/cluster-test/50b8de99-f94f-4ecd-a98f-5850760f0845", "RUNNING")
+	require.Equal(t, context.Canceled, err)
+}
+
+var cycleProcessDescribeTasksPending = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "AmazonEC2ContainerServiceV20141113.DescribeTasks",
+		Body: `{
+			"cluster": "cluster-test",
+			"tasks": [
+				"arn:aws:ecs:us-east-1:778743527532:task/cluster-test/50b8de99-f94f-4ecd-a98f-5850760f0845"
+			]
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"failures": [],
+			"tasks": [
+				{
+					"taskArn": "arn:aws:ecs:us-east-1:778743527532:task/cluster-test/50b8de99-f94f-4ecd-a98f-5850760f0845",
+					"lastStatus": "PENDING",
+					"taskDefinitionArn": "arn:aws:ecs:us-east-1:778743527532:task-definition/convox-myapp-web:34",
+					"containers": [
+						{
+							"name": "web",
+							"containerArn": "arn:aws:ecs:us-east-1:778743527532:container/3ab3b8c5-aa5c-4b54-89f8-5f1193aff5f9"
+						}
+					]
+				}
+			]
+		}`,
+	},
+}
+
 var cycleProcessDescribeTasks = awsutil.Cycle{
 	Request: awsutil.Request{
 		RequestURI: "/",
@@ -1366,6 +1476,7 @@ var cycleProcessRunTaskDetached = awsutil.Cycle{
 		RequestURI: "/",
 		Operation:  "AmazonEC2ContainerServiceV20141113.RunTask",
 		Body: `{
+			"clientToken": "convox-myapp-47ae1gDv3aZMGkgaJT5U",
 			"cluster": "cluster-test",
 			"count": 1,
 			"overrides": {
@@ -0,0 +1,233 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// rackStateMasked is the value cloudformation returns in place of NoEcho parameters
+const rackStateMasked = "****"
+
+var (
+	stackCreatePollInterval = 5 * time.Second
+	stackCreateTimeout      = 60 * time.Minute
+)
+
+// RackStateExport is a snapshot of the stacks of a rack for disaster recovery
+type RackStateExport struct {
+	Rack            string            `json:"rack"`
+	StackTemplate   string            `json:"stack-template"`
+	StackParameters map[string]string `json:"stack-parameters"`
+	StackTags       map[string]string `json:"stack-tags"`
+	AppStates       []AppState        `json:"apps"`
+	S3Path          string            `json:"s3-path"`
+}
+
+// AppState is the stack of a single app in a RackStateExport
+type AppState struct {
+	Name       string            `json:"name"`
+	Stack      string            `json:"stack"`
+	Template   string            `json:"template"`
+	Parameters map[string]string `json:"parameters"`
+	Outputs    map[string]string `json:"outputs"`
+	Tags       map[string]string `json:"tags"`
+}
+
+// ExportRackState writes the template, parameters and tags of the rack stack and every app stack
+// to s3Bucket under s3Prefix as json
+func (p *Provider) ExportRackState(ctx context.Context, s3Bucket, s3Prefix string) (*RackStateExport, error) {
+	log := Logger.At("ExportRackState").Namespace("bucket=%q prefix=%q", s3Bucket, s3Prefix).Start()
+
+	stack, err := p.describeStack(p.Rack)
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	template, err := p.stackTemplate(p.Rack)
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	e := &RackStateExport{
+		Rack:            p.Rack,
+		StackTemplate:   string(template),
+		StackParameters: stackParameters(stack),
+		StackTags:       stackTags(stack),
+		AppStates:       []AppState{},
+	}
+
+	apps, err := p.AppList()
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	for _, a := range apps {
+		if err := ctx.Err(); err != nil {
+			return nil, log.Error(err)
+		}
+
+		name := p.rackStack(a.Name)
+
+		as, err := p.describeStack(name)
+		if err != nil {
+			return nil, log.Error(err)
+		}
+
+		at, err := p.stackTemplate(name)
+		if err != nil {
+			return nil, log.Error(err)
+		}
+
+		e.AppStates = append(e.AppStates, AppState{
+			Name:       a.Name,
+			Stack:      name,
+			Template:   string(at),
+			Parameters: stackParameters(as),
+			Outputs:    stackOutputs(as),
+			Tags:       stackTags(as),
+		})
+	}
+
+	key := fmt.Sprintf("rack-state-%s-%s.json", p.Rack, time.Now().UTC().Format("20060102150405"))
+
+	if prefix := strings.Trim(s3Prefix, "/"); prefix != "" {
+		key = fmt.Sprintf("%s/%s", prefix, key)
+	}
+
+	e.S3Path = fmt.Sprintf("s3://%s/%s", s3Bucket, key)
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	if err := p.s3Put(s3Bucket, key, data, false); err != nil {
+		return nil, log.Error(err)
+	}
+
+	return e, log.Successf("path=%s", e.S3Path)
+}
+
+// ImportRackState recreates the stacks of a rack from an export written by ExportRackState
+// the rack stack is created and must complete before the app stacks that import its outputs are created
+// cloudformation masks NoEcho parameters in exports so masked holds their values keyed by stack name,
+// the import fails before creating anything when one of them is missing
+func (p *Provider) ImportRackState(ctx context.Context, s3Bucket, s3Key string, masked map[string]map[string]string) error {
+	log := Logger.At("ImportRackState").Namespace("bucket=%q key=%q", s3Bucket, s3Key).Start()
+
+	data, err := p.s3Get(s3Bucket, s3Key)
+	if err != nil {
+		return log.Error(err)
+	}
+
+	var e RackStateExport
+
+	if err := json.Unmarshal(data, &e); err != nil {
+		return log.Error(fmt.Errorf("invalid rack state export: %s", err))
+	}
+
+	if e.Rack == "" || e.StackTemplate == "" {
+		return log.Error(fmt.Errorf("invalid rack state export: no rack stack"))
+	}
+
+	params, err := unmaskParameters(e.Rack, e.StackParameters, masked[e.Rack])
+	if err != nil {
+		return log.Error(err)
+	}
+
+	aparams := make([]map[string]string, len(e.AppStates))
+
+	for i, a := range e.AppStates {
+		ps, err := unmaskParameters(a.Stack, a.Parameters, masked[a.Stack])
+		if err != nil {
+			return log.Error(err)
+		}
+
+		aparams[i] = ps
+	}
+
+	if err := p.createStack(e.Rack, []byte(e.StackTemplate), params, e.StackTags); err != nil {
+		return log.Error(err)
+	}
+
+	if err := p.waitForStackCreated(ctx, e.Rack); err != nil {
+		return log.Error(err)
+	}
+
+	for i, a := range e.AppStates {
+		if err := ctx.Err(); err != nil {
+			return log.Error(err)
+		}
+
+		if err := p.createStack(a.Stack, []byte(a.Template), aparams[i], a.Tags); err != nil {
+			return log.Error(err)
+		}
+	}
+
+	return log.Success()
+}
+
+// unmaskParameters returns the parameters of a stack with the NoEcho values cloudformation masked taken from values
+// the masked values can not be read back so every one of them has to be supplied
+func unmaskParameters(stack string, params, values map[string]string) (map[string]string, error) {
+	ps := map[string]string{}
+	missing := []string{}
+
+	for k, v := range params {
+		if v == rackStateMasked {
+			s, ok := values[k]
+			if !ok {
+				missing = append(missing, k)
+				continue
+			}
+
+			v = s
+		}
+
+		ps[k] = v
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("stack %s has NoEcho parameters that can not be read back, supply values for: %s", stack, strings.Join(missing, ", "))
+	}
+
+	return ps, nil
+}
+
+func (p *Provider) waitForStackCreated(ctx context.Context, name string) error {
+	done := time.Now().Add(stackCreateTimeout)
+
+	for {
+		p.invalidateStack(name)
+
+		stack, err := p.describeStack(name)
+		if err != nil {
+			return err
+		}
+
+		switch status := cs(stack.StackStatus, ""); status {
+		case cloudformation.StackStatusCreateComplete:
+			return nil
+		case cloudformation.StackStatusCreateInProgress:
+		default:
+			return fmt.Errorf("could not create stack %s: %s", name, status)
+		}
+
+		if time.Now().After(done) {
+			return fmt.Errorf("timeout waiting for stack %s to be created", name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(stackCreatePollInterval):
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package aws_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	rackStateRackTemplate = `{"Parameters":{"Password":{"NoEcho":true},"InstanceType":{}},"Resources":{"Cluster":{"Type":"AWS::ECS::Cluster"}}}`
+	rackStateAppTemplate  = `{"Parameters":{"WebFormation":{}},"Resources":{"Settings":{"Type":"AWS::S3::Bucket"}}}`
+)
+
+func TestRackStateExportImport(t *testing.T) {
+	source := awsfake.NewTestProvider()
+	defer source.Close()
+
+	source.AddRack(awsfake.Stack{
+		Parameters: map[string]string{"InstanceType": "t3.small", "Password": "****"},
+		Template:   rackStateRackTemplate,
+	})
+
+	source.AddApp("httpd", "2", awsfake.Stack{
+		Outputs:    map[string]string{"Release": "R1"},
+		Parameters: map[string]string{"WebFormation": "2,256,512"},
+		Template:   rackStateAppTemplate,
+	})
+
+	source.Fake.S3.CreateBucket("backups")
+
+	e, err := source.ExportRackState(context.Background(), "backups", "/dr/")
+	require.NoError(t, err)
+
+	require.True(t, strings.HasPrefix(e.S3Path, "s3://backups/dr/rack-state-convox-"))
+	assert.Equal(t, rackStateRackTemplate, e.StackTemplate)
+	assert.Equal(t, map[string]string{"InstanceType": "t3.small", "Password": "****"}, e.StackParameters)
+	assert.Equal(t, "rack", e.StackTags["Type"])
+	require.Len(t, e.AppStates, 1)
+	assert.Equal(t, aws.AppState{
+		Name:       "httpd",
+		Stack:      "convox-httpd",
+		Template:   rackStateAppTemplate,
+		Parameters: map[string]string{"WebFormation": "2,256,512"},
+		Outputs:    map[string]string{"Release": "R1"},
+		Tags:       map[string]string{"Generation": "2", "Name": "httpd", "Rack": "convox", "System": "convox", "Type": "app"},
+	}, e.AppStates[0])
+
+	key := strings.TrimPrefix(e.S3Path, "s3://backups/")
+
+	data, ok := source.Fake.S3.Object("backups", key)
+	require.True(t, ok)
+
+	var stored aws.RackStateExport
+	require.NoError(t, json.Unmarshal(data, &stored))
+	assert.Equal(t, *e, stored)
+
+	target := awsfake.NewTestProvider()
+	defer target.Close()
+
+	target.Fake.S3.CreateBucket("backups")
+	target.Fake.S3.PutObject("backups", key, data, nil)
+
+	err = target.ImportRackState(context.Background(), "backups", key, nil)
+	require.EqualError(t, err, "stack convox has NoEcho parameters that can not be read back, supply values for: Password")
+
+	// nothing is created until every masked parameter has a value
+	assert.Empty(t, target.Fake.CloudFormation.Stacks())
+
+	require.NoError(t, target.ImportRackState(context.Background(), "backups", key, map[string]map[string]string{"convox": {"Password": "secret"}}))
+
+	rack, ok := target.Fake.CloudFormation.Stack("convox")
+	require.True(t, ok)
+	assert.Equal(t, rackStateRackTemplate, rack.Template)
+	assert.Equal(t, map[string]string{"InstanceType": "t3.small", "Password": "secret"}, rack.Parameters)
+	assert.Equal(t, e.StackTags, rack.Tags)
+
+	app, ok := target.Fake.CloudFormation.Stack("convox-httpd")
+	require.True(t, ok)
+	assert.Equal(t, rackStateAppTemplate, app.Template)
+	assert.Equal(t, map[string]string{"WebFormation": "2,256,512"}, app.Parameters)
+	assert.Equal(t, e.AppStates[0].Tags, app.Tags)
+}
+
+func TestRackStateImportRackFailed(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	data, err := json.Marshal(aws.RackStateExport{
+		Rack:          "convox",
+		StackTemplate: "not json",
+		AppStates:     []aws.AppState{{Name: "httpd", Stack: "convox-httpd", Template: rackStateAppTemplate}},
+	})
+	require.NoError(t, err)
+
+	provider.Fake.S3.CreateBucket("backups")
+	provider.Fake.S3.PutObject("backups", "state.json", data, nil)
+
+	err = provider.ImportRackState(context.Background(), "backups", "state.json", nil)
+	require.Error(t, err)
+
+	// apps are not created without the rack they depend on
+	_, ok := provider.Fake.CloudFormation.Stack("convox-httpd")
+	assert.False(t, ok)
+}
+
+func TestRackStateImportInvalid(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.Fake.S3.CreateBucket("backups")
+	provider.Fake.S3.PutObject("backups", "state.json", []byte(`{}`), nil)
+
+	err := provider.ImportRackState(context.Background(), "backups", "state.json", nil)
+	require.EqualError(t, err, "invalid rack state export: no rack stack")
+}
@@ -0,0 +1,289 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/convox/rack/pkg/structs"
+)
+
+const (
+	rackUpdateBatchSizeDefault = 5
+
+	rackUpdateStepApps = "apps"
+	rackUpdateStepRack = "rack"
+)
+
+// rackRelease describes a rack version, it is read from releases/rack/<version>.json in the settings bucket
+type rackRelease struct {
+	Version         string            `json:"version"`
+	Components      map[string]string `json:"components"`
+	MinimumFrom     string            `json:"minimum-from"`
+	AppTemplateHash string            `json:"app-template-hash"`
+}
+
+// rackUpdatePlan is the ordered steps of a rack update, the rack stack first and then batches of apps
+type rackUpdatePlan struct {
+	From    string
+	To      string
+	Release rackRelease
+	Steps   []rackUpdateStep
+}
+
+type rackUpdateStep struct {
+	Kind string
+	Apps []string
+}
+
+func (p *Provider) rackRelease(version string) (*rackRelease, error) {
+	data, err := p.s3Get(p.SettingsBucket, fmt.Sprintf("releases/rack/%s.json", version))
+	if err != nil {
+		return nil, fmt.Errorf("could not read release descriptor for %s: %s", version, err)
+	}
+
+	var r rackRelease
+
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("invalid release descriptor for %s: %s", version, err)
+	}
+
+	if r.Version != version {
+		return nil, fmt.Errorf("release descriptor for %s describes version %s", version, r.Version)
+	}
+
+	return &r, nil
+}
+
+// rackUpdatePlan validates that the rack can update to toVersion and returns the steps to get there
+// apps are updated in batches of the rack AppUpdateBatchSize parameter
+func (p *Provider) rackUpdatePlan(toVersion string) (*rackUpdatePlan, error) {
+	r, err := p.rackRelease(toVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cp, err := p.rackUpdateCheckpoint(toVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// once the rack stack has updated the api runs toVersion, planning again resumes the remaining apps
+	resume := p.Version == toVersion && cp[rackUpdateStepRack]
+
+	if !resume && !rackVersionBefore(p.Version, toVersion) {
+		return nil, fmt.Errorf("rack is at version %s, can not update to %s", p.Version, toVersion)
+	}
+
+	if !resume && r.MinimumFrom != "" && rackVersionBefore(p.Version, r.MinimumFrom) {
+		return nil, fmt.Errorf("rack version %s is older than %s, the minimum version that can update to %s", p.Version, r.MinimumFrom, toVersion)
+	}
+
+	size := rackUpdateBatchSizeDefault
+
+	if v, err := p.stackParameter(p.Rack, "AppUpdateBatchSize"); err == nil {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			size = n
+		}
+	}
+
+	apps, err := p.AppList()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+
+	for _, a := range apps {
+		names = append(names, a.Name)
+	}
+
+	sort.Strings(names)
+
+	plan := &rackUpdatePlan{
+		From:    p.Version,
+		To:      toVersion,
+		Release: *r,
+		Steps:   []rackUpdateStep{{Kind: rackUpdateStepRack}},
+	}
+
+	for i := 0; i < len(names); i += size {
+		j := i + size
+
+		if j > len(names) {
+			j = len(names)
+		}
+
+		plan.Steps = append(plan.Steps, rackUpdateStep{Kind: rackUpdateStepApps, Apps: names[i:j]})
+	}
+
+	return plan, nil
+}
+
+// executeRackUpdate runs a plan, progress is checkpointed so running the same plan again resumes it
+func (p *Provider) executeRackUpdate(ctx context.Context, plan *rackUpdatePlan) error {
+	return p.runRackUpdate(ctx, plan, p.rackUpdateStack, p.rackUpdateApp)
+}
+
+// runRackUpdate applies the steps of a plan using rack to update the rack stack and app to update each app
+// apps are only updated once the api is running the new version as their templates come from it
+func (p *Provider) runRackUpdate(ctx context.Context, plan *rackUpdatePlan, rack func(version string) error, app func(a structs.App) error) error {
+	cp, err := p.rackUpdateCheckpoint(plan.To)
+	if err != nil {
+		return err
+	}
+
+	var lock sync.Mutex
+
+	complete := func(step string) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		cp[step] = true
+
+		return p.saveRackUpdateCheckpoint(plan.To, cp)
+	}
+
+	for _, s := range plan.Steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		switch s.Kind {
+		case rackUpdateStepRack:
+			// the checkpoint only records that the rack update started, an update that failed or rolled back
+			// leaves the api on the old version so the rack is updated again on resume
+			if cp[rackUpdateStepRack] && p.Version == plan.To {
+				continue
+			}
+
+			if err := rack(plan.To); err != nil {
+				return err
+			}
+
+			if err := complete(rackUpdateStepRack); err != nil {
+				return err
+			}
+		case rackUpdateStepApps:
+			if p.Version != plan.To {
+				return fmt.Errorf("rack is updating to %s, resume the update once the api is running it", plan.To)
+			}
+
+			apps := structs.Apps{}
+
+			for _, name := range s.Apps {
+				if !cp[rackUpdateAppStep(name)] {
+					apps = append(apps, structs.App{Name: name})
+				}
+			}
+
+			report, err := forEachApp(ctx, apps, len(apps), forEachAppOptions{FailFast: true}, func(a structs.App) error {
+				if err := app(a); err != nil {
+					return err
+				}
+
+				return complete(rackUpdateAppStep(a.Name))
+			})
+			if failed := report.Failed(); len(failed) > 0 {
+				return fmt.Errorf("could not update app %s: %s", failed[0].App, failed[0].Error)
+			}
+
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown rack update step: %s", s.Kind)
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) rackUpdateStack(version string) error {
+	return p.SystemUpdate(structs.SystemUpdateOptions{Version: aws.String(version)})
+}
+
+// rackUpdateApp promotes the current release of an app again so its stack is rendered from the new version
+func (p *Provider) rackUpdateApp(a structs.App) error {
+	aa, err := p.AppGet(a.Name)
+	if err != nil {
+		return err
+	}
+
+	if aa.Release == "" {
+		return nil
+	}
+
+	return p.ReleasePromote(aa.Name, aa.Release, structs.ReleasePromoteOptions{})
+}
+
+func rackUpdateAppStep(app string) string {
+	return fmt.Sprintf("app:%s", app)
+}
+
+// rackUpdateCheckpoint returns the completed steps of an update to version
+// checkpoints live in the releases table without an app so they stay out of release listings
+func (p *Provider) rackUpdateCheckpoint(version string) (map[string]bool, error) {
+	res, err := p.dynamodb().GetItem(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(rackUpdateCheckpointId(version))},
+		},
+		TableName: aws.String(p.DynamoReleases),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cp := map[string]bool{}
+
+	if c, ok := res.Item["completed"]; ok {
+		for _, s := range c.SS {
+			cp[aws.StringValue(s)] = true
+		}
+	}
+
+	return cp, nil
+}
+
+func (p *Provider) saveRackUpdateCheckpoint(version string, cp map[string]bool) error {
+	steps := []string{}
+
+	for s := range cp {
+		steps = append(steps, s)
+	}
+
+	sort.Strings(steps)
+
+	_, err := p.dynamodb().PutItem(&dynamodb.PutItemInput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":        {S: aws.String(rackUpdateCheckpointId(version))},
+			"completed": {SS: aws.StringSlice(steps)},
+			"updated":   {S: aws.String(p.createdTime())},
+		},
+		TableName: aws.String(p.DynamoReleases),
+	})
+
+	return err
+}
+
+func rackUpdateCheckpointId(version string) string {
+	return fmt.Sprintf("rack-update-%s", version)
+}
+
+// rackVersionBefore compares rack versions numerically when possible as they are timestamps
+func rackVersionBefore(a, b string) bool {
+	ai, aerr := strconv.ParseInt(a, 10, 64)
+	bi, berr := strconv.ParseInt(b, 10, 64)
+
+	if aerr == nil && berr == nil {
+		return ai < bi
+	}
+
+	return a < b
+}
@@ -0,0 +1,145 @@
+package aws_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/convox/rack/pkg/structs"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRackUpdatePlan(t *testing.T) {
+	provider := rackUpdateTestProvider("2", "web", "api", "worker")
+	defer provider.Close()
+
+	from, to, steps, err := provider.RackUpdatePlan("20200301000000")
+	require.NoError(t, err)
+
+	assert.Equal(t, "20200101000000", from)
+	assert.Equal(t, "20200301000000", to)
+	assert.Equal(t, []string{"rack[]", "apps[api web]", "apps[worker]"}, steps)
+}
+
+func TestRackUpdatePlanMinimumVersion(t *testing.T) {
+	provider := rackUpdateTestProvider("2", "web")
+	defer provider.Close()
+
+	_, _, _, err := provider.RackUpdatePlan("20200401000000")
+	require.EqualError(t, err, "rack version 20200101000000 is older than 20200201000000, the minimum version that can update to 20200401000000")
+}
+
+func TestRackUpdatePlanDowngrade(t *testing.T) {
+	provider := rackUpdateTestProvider("2", "web")
+	defer provider.Close()
+
+	provider.Version = "20200501000000"
+
+	_, _, _, err := provider.RackUpdatePlan("20200301000000")
+	require.EqualError(t, err, "rack is at version 20200501000000, can not update to 20200301000000")
+}
+
+func TestRackUpdateResume(t *testing.T) {
+	provider := rackUpdateTestProvider("2", "web", "api", "worker")
+	defer provider.Close()
+
+	var lock sync.Mutex
+
+	racks := []string{}
+	apps := []string{}
+	fail := map[string]bool{"web": true}
+
+	rack := func(version string) error {
+		racks = append(racks, version)
+		return nil
+	}
+
+	app := func(a structs.App) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if fail[a.Name] {
+			return fmt.Errorf("stack is busy")
+		}
+
+		apps = append(apps, a.Name)
+
+		return nil
+	}
+
+	// the api restarts with the new version before apps can be updated
+	err := provider.RunRackUpdate(context.Background(), "20200301000000", rack, app)
+	require.EqualError(t, err, "rack is updating to 20200301000000, resume the update once the api is running it")
+	assert.Equal(t, []string{"20200301000000"}, racks)
+	assert.Empty(t, apps)
+
+	provider.Version = "20200301000000"
+
+	err = provider.RunRackUpdate(context.Background(), "20200301000000", rack, app)
+	require.EqualError(t, err, "could not update app web: stack is busy")
+	assert.Equal(t, []string{"api"}, apps)
+
+	fail = map[string]bool{}
+
+	err = provider.RunRackUpdate(context.Background(), "20200301000000", rack, app)
+	require.NoError(t, err)
+
+	// completed steps are not repeated
+	assert.Equal(t, []string{"20200301000000"}, racks)
+	sort.Strings(apps)
+	assert.Equal(t, []string{"api", "web", "worker"}, apps)
+
+	item, ok := provider.Fake.DynamoDB.Item("convox-releases", "rack-update-20200301000000")
+	require.True(t, ok)
+	require.NotNil(t, item["completed"])
+	assert.Len(t, item["completed"].SS, 4)
+}
+
+func TestRackUpdateResumeRolledBack(t *testing.T) {
+	provider := rackUpdateTestProvider("2", "web")
+	defer provider.Close()
+
+	racks := []string{}
+	apps := []string{}
+
+	rack := func(version string) error {
+		racks = append(racks, version)
+		return nil
+	}
+
+	app := func(a structs.App) error {
+		apps = append(apps, a.Name)
+		return nil
+	}
+
+	err := provider.RunRackUpdate(context.Background(), "20200301000000", rack, app)
+	require.EqualError(t, err, "rack is updating to 20200301000000, resume the update once the api is running it")
+
+	// the rack stack rolled back so the api still runs the old version and the rack is updated again
+	err = provider.RunRackUpdate(context.Background(), "20200301000000", rack, app)
+	require.EqualError(t, err, "rack is updating to 20200301000000, resume the update once the api is running it")
+	assert.Equal(t, []string{"20200301000000", "20200301000000"}, racks)
+	assert.Empty(t, apps)
+}
+
+func rackUpdateTestProvider(batch string, apps ...string) *awsfake.TestProvider {
+	provider := awsfake.NewTestProvider()
+	provider.Version = "20200101000000"
+
+	provider.AddRack(awsfake.Stack{
+		Parameters: map[string]string{"AppUpdateBatchSize": batch},
+	})
+
+	for _, a := range apps {
+		provider.AddApp(a, "2", awsfake.Stack{})
+	}
+
+	provider.Fake.S3.PutObject("convox-settings", "releases/rack/20200301000000.json", []byte(`{"version":"20200301000000","components":{"api":"20200301000000","router":"1.2.0"},"minimum-from":"20191201000000","app-template-hash":"abc123"}`), nil)
+	provider.Fake.S3.PutObject("convox-settings", "releases/rack/20200401000000.json", []byte(`{"version":"20200401000000","minimum-from":"20200201000000"}`), nil)
+
+	return provider
+}
@@ -0,0 +1,202 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"golang.org/x/time/rate"
+)
+
+// RateLimit is the sustained requests per second and burst size allowed for calls to an aws service
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// DefaultRateLimits are the limits for interactive calls when RATE_LIMITS does not override them
+// background calls are allowed backgroundRateShare of each limit
+var DefaultRateLimits = map[string]RateLimit{
+	"cloudformation": {Rate: 4, Burst: 8},
+	"dynamodb":       {Rate: 25, Burst: 50},
+	"ecs":            {Rate: 20, Burst: 40},
+	"logs":           {Rate: 5, Burst: 10},
+}
+
+const backgroundRateShare = 0.5
+
+type requestPriority int
+
+const (
+	priorityBackground requestPriority = iota
+	priorityInteractive
+)
+
+type requestPriorityKey struct{}
+
+// BackgroundContext marks calls made with ctx as background work so they use the lower priority limits
+func BackgroundContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestPriorityKey{}, priorityBackground)
+}
+
+// InteractiveContext marks calls made with ctx as serving a user so they use the higher priority limits
+func InteractiveContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestPriorityKey{}, priorityInteractive)
+}
+
+func contextPriority(ctx context.Context) (requestPriority, bool) {
+	if ctx == nil {
+		return priorityBackground, false
+	}
+
+	p, ok := ctx.Value(requestPriorityKey{}).(requestPriority)
+
+	return p, ok
+}
+
+// parseRateLimits reads limits of the form service=rate:burst,service=rate:burst on top of the defaults
+func parseRateLimits(s string) (map[string]RateLimit, error) {
+	limits := map[string]RateLimit{}
+
+	for service, l := range DefaultRateLimits {
+		limits[service] = l
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid rate limit: %s", entry)
+		}
+
+		values := strings.SplitN(parts[1], ":", 2)
+		if len(values) != 2 {
+			return nil, fmt.Errorf("invalid rate limit: %s", entry)
+		}
+
+		r, err := strconv.ParseFloat(values[0], 64)
+		if err != nil || r <= 0 {
+			return nil, fmt.Errorf("invalid rate limit: %s", entry)
+		}
+
+		b, err := strconv.Atoi(values[1])
+		if err != nil || b < 1 {
+			return nil, fmt.Errorf("invalid rate limit: %s", entry)
+		}
+
+		limits[parts[0]] = RateLimit{Rate: r, Burst: b}
+	}
+
+	return limits, nil
+}
+
+// rateLimiter shapes calls with a token bucket per service and priority
+// the priorities have separate buckets so a backlog of background calls never delays interactive ones
+type rateLimiter struct {
+	limiters map[string]*rate.Limiter
+	lock     sync.Mutex
+	waited   map[string]time.Duration
+}
+
+func newRateLimiter(limits map[string]RateLimit) *rateLimiter {
+	l := &rateLimiter{
+		limiters: map[string]*rate.Limiter{},
+		waited:   map[string]time.Duration{},
+	}
+
+	for service, limit := range limits {
+		burst := int(float64(limit.Burst) * backgroundRateShare)
+		if burst < 1 {
+			burst = 1
+		}
+
+		l.limiters[rateLimiterKey(service, priorityInteractive)] = rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+		l.limiters[rateLimiterKey(service, priorityBackground)] = rate.NewLimiter(rate.Limit(limit.Rate*backgroundRateShare), burst)
+	}
+
+	return l
+}
+
+func rateLimiterKey(service string, priority requestPriority) string {
+	return fmt.Sprintf("%s/%d", service, priority)
+}
+
+// wait blocks until a call to service may proceed, services without a limit are not shaped
+func (l *rateLimiter) wait(ctx context.Context, service string, priority requestPriority) error {
+	lim, ok := l.limiters[rateLimiterKey(service, priority)]
+	if !ok {
+		return nil
+	}
+
+	r := lim.Reserve()
+
+	d := r.Delay()
+	if d == 0 {
+		return nil
+	}
+
+	l.lock.Lock()
+	l.waited[service] += d
+	l.lock.Unlock()
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
+
+// RateLimitWaits returns the total time calls have spent waiting on the rate limiter for each service
+func (p *Provider) RateLimitWaits() map[string]time.Duration {
+	waits := map[string]time.Duration{}
+
+	if p.limiter == nil {
+		return waits
+	}
+
+	p.limiter.lock.Lock()
+	defer p.limiter.lock.Unlock()
+
+	for service, d := range p.limiter.waited {
+		waits[service] = d
+	}
+
+	return waits
+}
+
+// rateLimitHandler is installed on every session so that all calls are shaped, not only those made through our helpers
+// the priority comes from the request context if it is tagged and otherwise from the provider context
+func (p *Provider) rateLimitHandler(r *request.Request) {
+	if p.limiter == nil {
+		return
+	}
+
+	ctx := p.Context()
+
+	priority, ok := contextPriority(r.Context())
+	if !ok {
+		priority, _ = contextPriority(ctx)
+	}
+
+	if rctx := r.Context(); rctx != nil && rctx.Done() != nil {
+		ctx = rctx
+	}
+
+	if err := p.limiter.wait(ctx, r.ClientInfo.ServiceName, priority); err != nil {
+		r.Error = err
+	}
+}
@@ -0,0 +1,107 @@
+package aws_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := aws.ParseRateLimits("ecs=2:4, logs=0.5:1")
+	require.NoError(t, err)
+	assert.Equal(t, aws.RateLimit{Rate: 2, Burst: 4}, limits["ecs"])
+	assert.Equal(t, aws.RateLimit{Rate: 0.5, Burst: 1}, limits["logs"])
+	assert.Equal(t, aws.DefaultRateLimits["cloudformation"], limits["cloudformation"])
+
+	_, err = aws.ParseRateLimits("ecs=fast")
+	require.EqualError(t, err, "invalid rate limit: ecs=fast")
+
+	_, err = aws.ParseRateLimits("ecs=0:1")
+	require.EqualError(t, err, "invalid rate limit: ecs=0:1")
+}
+
+func TestRateLimitSmoothsBursts(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.SetRateLimits(map[string]aws.RateLimit{"ecs": {Rate: 20, Burst: 2}})
+
+	p := provider.WithContext(context.Background()).(*aws.Provider)
+
+	start := time.Now()
+
+	for i := 0; i < 6; i++ {
+		_, err := p.GetClusterSettings()
+		require.NoError(t, err)
+	}
+
+	// the burst of 2 goes straight through and the other 4 are spaced 50ms apart
+	elapsed := time.Since(start)
+	assert.True(t, elapsed >= 150*time.Millisecond, "elapsed %s", elapsed)
+	assert.True(t, elapsed < 2*time.Second, "elapsed %s", elapsed)
+
+	assert.True(t, provider.RateLimitWaits()["ecs"] >= 150*time.Millisecond)
+	assert.Equal(t, time.Duration(0), provider.RateLimitWaits()["cloudformation"])
+}
+
+func TestRateLimitPriorities(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.SetRateLimits(map[string]aws.RateLimit{"ecs": {Rate: 10, Burst: 2}})
+
+	ctx, cancel := context.WithCancel(aws.BackgroundContext(context.Background()))
+	defer cancel()
+
+	background := provider.WithContext(ctx).(*aws.Provider)
+	interactive := provider.WithContext(context.Background()).(*aws.Provider)
+
+	var done int32
+
+	finished := make(chan struct{})
+
+	// queue up far more background work than its bucket allows
+	go func() {
+		defer close(finished)
+
+		for i := 0; i < 20; i++ {
+			if _, err := background.GetClusterSettings(); err != nil {
+				return
+			}
+
+			atomic.AddInt32(&done, 1)
+		}
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		_, err := interactive.GetClusterSettings()
+		require.NoError(t, err)
+	}
+
+	// interactive calls only wait on their own bucket
+	elapsed := time.Since(start)
+	assert.True(t, elapsed < 500*time.Millisecond, "elapsed %s", elapsed)
+
+	// and background calls keep making progress at their own rate
+	deadline := time.Now().Add(2 * time.Second)
+
+	for atomic.LoadInt32(&done) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.True(t, atomic.LoadInt32(&done) >= 3)
+	assert.True(t, atomic.LoadInt32(&done) < 20)
+
+	cancel()
+	<-finished
+}
@@ -3,9 +3,9 @@ package aws
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
-	"math/rand"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -69,6 +69,15 @@ func (p *Provider) ReleaseCreate(app string, opts structs.ReleaseCreateOptions)
 		return nil, err
 	}
 
+	if opts.Env != nil {
+		p.auditRecord("env.update", app, p.actor(), map[string]string{"change": r.Description, "release": r.Id})
+
+		// keep the revision in step with environments replaced whole so envSet sees them as changes
+		if err := p.envRecord(app, envRevision(r.Env)); err != nil {
+			Logger.At("ReleaseCreate").Namespace("app=%s", app).Error(fmt.Errorf("could not record environment revision: %s", err))
+		}
+	}
+
 	p.EventSend("release:create", structs.EventSendOptions{Data: map[string]string{"app": r.App, "id": r.Id}})
 
 	return r, nil
@@ -95,7 +104,7 @@ func (p *Provider) ReleaseGet(app, id string) (*structs.Release, error) {
 		return nil, err
 	}
 
-	data, err := p.s3Get(settings, fmt.Sprintf("releases/%s/env", r.Id))
+	data, err := p.s3Get(settings, objectKey(objectKindEnv, "", r.Id))
 	if err != nil {
 		return nil, err
 	}
@@ -181,7 +190,7 @@ func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOpt
 
 	switch a.Tags["Generation"] {
 	case "", "1":
-		return p.releasePromoteGeneration1(a, r)
+		return p.releasePromoteGeneration1(a, r, opts)
 	case "2":
 	default:
 		return fmt.Errorf("unknown generation for app: %s", a.Name)
@@ -198,6 +207,14 @@ func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOpt
 		return err
 	}
 
+	if n := m.App.Name; n != "" && n != a.Name {
+		return fmt.Errorf("manifest is for app %s, can not promote it to app %s", n, a.Name)
+	}
+
+	if g := m.App.Generation; g != "" && g != a.Tags["Generation"] {
+		return fmt.Errorf("manifest pins generation %s but app %s is generation %s", g, a.Name, a.Tags["Generation"])
+	}
+
 	for _, s := range m.Services {
 		if s.Internal && !p.Internal {
 			return fmt.Errorf("rack does not support internal services")
@@ -208,6 +225,18 @@ func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOpt
 		}
 	}
 
+	if p.RequireNonRoot {
+		if err := m.ValidateNonRoot(); err != nil {
+			return err
+		}
+	}
+
+	if !(opts.IgnoreCapacity != nil && *opts.IgnoreCapacity) {
+		if err := p.capacityPreflight(a, m); err != nil {
+			return err
+		}
+	}
+
 	cs, err := p.CertificateList()
 	if err != nil {
 		return err
@@ -224,6 +253,7 @@ func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOpt
 	tp := map[string]interface{}{
 		"App":          r.App,
 		"Certificates": ccs,
+		"EcsExec":      p.EcsExec,
 		"Manifest":     m,
 		"Password":     p.Password,
 		"Release":      r,
@@ -246,7 +276,7 @@ func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOpt
 			return err
 		}
 
-		ou, err := p.ObjectStore(app, "", bytes.NewReader(data), structs.ObjectStoreOptions{Presign: options.Bool(true)})
+		ou, err := p.ObjectStore(app, "", bytes.NewReader(data), structs.ObjectStoreOptions{ContentType: options.String("application/json"), Presign: options.Bool(true)})
 		if err != nil {
 			return err
 		}
@@ -281,19 +311,21 @@ func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOpt
 			"Build":         tp["Build"],
 			"DeploymentMin": min,
 			"DeploymentMax": max,
+			"EcsExec":       p.EcsExec,
 			"Manifest":      tp["Manifest"],
 			"Password":      p.Password,
 			"Release":       tp["Release"],
 			"Service":       s,
 		}
 
+		// services that are new or still being created have no desired count to carry over
 		sarn, err := p.serviceArn(r.App, s.Name)
-		if err != nil {
+		switch {
+		case errors.Is(err, ErrServiceNotFound), errors.Is(err, ErrServiceNotReady):
+		case err != nil:
 			return err
-		}
-
-		if sarn != "" {
-			res, err := p.describeServices(&ecs.DescribeServicesInput{
+		default:
+			ss, _, err := p.describeServicesChecked(&ecs.DescribeServicesInput{
 				Cluster:  aws.String(p.Cluster),
 				Services: []*string{aws.String(sarn)},
 			})
@@ -301,8 +333,8 @@ func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOpt
 				return err
 			}
 
-			if len(res.Services) == 1 && res.Services[0].DesiredCount != nil {
-				stp["CurrentDesiredCount"] = *res.Services[0].DesiredCount
+			if len(ss) == 1 && ss[0].DesiredCount != nil {
+				stp["CurrentDesiredCount"] = *ss[0].DesiredCount
 			}
 		}
 
@@ -311,7 +343,7 @@ func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOpt
 			return err
 		}
 
-		ou, err := p.ObjectStore(app, "", bytes.NewReader(data), structs.ObjectStoreOptions{Presign: options.Bool(true)})
+		ou, err := p.ObjectStore(app, "", bytes.NewReader(data), structs.ObjectStoreOptions{ContentType: options.String("application/json"), Presign: options.Bool(true)})
 		if err != nil {
 			return err
 		}
@@ -334,7 +366,7 @@ func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOpt
 			return err
 		}
 
-		ou, err := p.ObjectStore(app, "", bytes.NewReader(data), structs.ObjectStoreOptions{Presign: options.Bool(true)})
+		ou, err := p.ObjectStore(app, "", bytes.NewReader(data), structs.ObjectStoreOptions{ContentType: options.String("application/json"), Presign: options.Bool(true)})
 		if err != nil {
 			return err
 		}
@@ -347,20 +379,22 @@ func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOpt
 		return err
 	}
 
-	private, err := p.stackParameter(p.Rack, "Private")
+	updates, err := p.releaseChanges(m)
 	if err != nil {
 		return err
 	}
 
-	updates := map[string]string{
-		"LogBucket": p.LogBucket,
-		"Private":   private,
+	bgs, err := p.bluegreenReleaseChanges(r.App, m)
+	if err != nil {
+		return err
 	}
 
-	if m.Params != nil {
-		for k, v := range m.Params {
-			updates[k] = v
-		}
+	for k, v := range bgs {
+		updates[k] = v
+	}
+
+	if err := p.reportParameterDrift(a, data, updates, opts); err != nil {
+		return err
 	}
 
 	tags := map[string]string{
@@ -388,11 +422,47 @@ func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOpt
 		return err
 	}
 
+	p.auditRecord("release.promote", r.App, p.actor(), map[string]string{"release": r.Id})
+
 	p.EventSend("release:promote", structs.EventSendOptions{Data: map[string]string{"app": r.App, "id": r.Id}, Status: options.String("start")})
 
 	return nil
 }
 
+// releaseChanges returns the parameters a generation 2 promotion sets on the app stack
+func (p *Provider) releaseChanges(m *manifest.Manifest) (map[string]string, error) {
+	private, err := p.stackParameter(p.Rack, "Private")
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]string{
+		"LogBucket": p.LogBucket,
+		"Private":   private,
+	}
+
+	for k, v := range m.Params {
+		changes[k] = v
+	}
+
+	return changes, nil
+}
+
+// releaseChangesGeneration1 returns the rack parameters a generation 1 promotion sets on the app stack
+func (p *Provider) releaseChangesGeneration1(r *structs.Release) map[string]string {
+	return map[string]string{
+		"Cluster":        p.Cluster,
+		"Key":            p.EncryptionKey,
+		"LogBucket":      p.LogBucket,
+		"Rack":           p.Rack,
+		"Release":        r.Id,
+		"Subnets":        p.Subnets,
+		"SubnetsPrivate": coalesces(p.SubnetsPrivate, p.Subnets),
+		"VPC":            p.Vpc,
+		"VPCCIDR":        p.VpcCidr,
+	}
+}
+
 func (p *Provider) getCustomTags(rackName string) (map[string]string, error) {
 	stack, err := p.describeStack(rackName)
 	if err != nil {
@@ -410,12 +480,19 @@ func (p *Provider) getCustomTags(rackName string) (map[string]string, error) {
 	return tags, nil
 }
 
-func (p *Provider) releasePromoteGeneration1(a *structs.App, r *structs.Release) error {
+func (p *Provider) releasePromoteGeneration1(a *structs.App, r *structs.Release, opts structs.ReleasePromoteOptions) error {
 	m, err := manifest1.Load([]byte(r.Manifest))
 	if err != nil {
 		return err
 	}
 
+	// ecs tasks get a single network interface so only local runs join more than one network
+	for name, entry := range m.Services {
+		if len(entry.Networks) > 1 {
+			fmt.Printf("ns=kernel at=release.promote warning=%q service=%s networks=%d\n", "multiple networks are not supported on ecs", name, len(entry.Networks))
+		}
+	}
+
 	// set the image
 	for i, entry := range m.Services {
 		s := m.Services[i]
@@ -436,7 +513,7 @@ func (p *Provider) releasePromoteGeneration1(a *structs.App, r *structs.Release)
 	tp := map[string]interface{}{
 		"App":         a,
 		"Cluster":     p.Cluster,
-		"Environment": fmt.Sprintf("https://%s.s3.amazonaws.com/releases/%s/env", settings, r.Id),
+		"Environment": fmt.Sprintf("https://%s.s3.amazonaws.com/%s", settings, objectKey(objectKindEnv, "", r.Id)),
 		"Manifest":    m,
 		"Region":      p.Region,
 		"Version":     p.Version,
@@ -457,8 +534,8 @@ func (p *Provider) releasePromoteGeneration1(a *structs.App, r *structs.Release)
 	}
 
 	// If release formation was saved in S3, get that instead
-	f, err := p.s3Get(settings, fmt.Sprintf("templates/%s", r.Id))
-	if err != nil && awsError(err) != "NoSuchKey" {
+	f, err := p.s3Get(settings, objectKey(objectKindTemplate, "", r.Id))
+	if err != nil && !isNotFound(err) {
 		return err
 	}
 	if err == nil {
@@ -467,17 +544,7 @@ func (p *Provider) releasePromoteGeneration1(a *structs.App, r *structs.Release)
 
 	fmt.Printf("ns=kernel at=release.promote at=s3Get found=%t\n", err == nil)
 
-	params := map[string]string{}
-
-	params["Cluster"] = p.Cluster
-	params["Key"] = p.EncryptionKey
-	params["LogBucket"] = p.LogBucket
-	params["Rack"] = p.Rack
-	params["Release"] = r.Id
-	params["Subnets"] = p.Subnets
-	params["SubnetsPrivate"] = coalesces(p.SubnetsPrivate, p.Subnets)
-	params["VPC"] = p.Vpc
-	params["VPCCIDR"] = p.VpcCidr
+	params := p.releaseChangesGeneration1(r)
 
 	for _, entry := range m.Services {
 		for _, mapping := range entry.Ports {
@@ -508,7 +575,7 @@ func (p *Provider) releasePromoteGeneration1(a *structs.App, r *structs.Release)
 					}
 
 					for _, cert := range certs.ServerCertificateMetadataList {
-						if strings.Contains(*cert.Arn, fmt.Sprintf("server-certificate/cert-%s-", p.Rack)) {
+						if strings.Contains(*cert.Arn, fmt.Sprintf("server-certificate/%s", p.internalCertificatePrefix())) {
 							listener[1] = *cert.Arn
 							break
 						}
@@ -516,29 +583,12 @@ func (p *Provider) releasePromoteGeneration1(a *structs.App, r *structs.Release)
 
 					// if not, generate and upload a self-signed cert
 					if listener[1] == "" {
-						name := fmt.Sprintf("cert-%s-%d-%05d", p.Rack, time.Now().Unix(), rand.Intn(100000))
-
-						body, key, err := generateSelfSignedCertificate("*.*.elb.amazonaws.com")
-						if err != nil {
-							return err
-						}
-
-						input := &iam.UploadServerCertificateInput{
-							CertificateBody:       aws.String(string(body)),
-							PrivateKey:            aws.String(string(key)),
-							ServerCertificateName: aws.String(name),
-						}
-
-						res, err := p.iam().UploadServerCertificate(input)
+						arn, err := p.uploadSelfSignedCertificate("*.*.elb.amazonaws.com")
 						if err != nil {
 							return err
 						}
 
-						listener[1] = *res.ServerCertificateMetadata.Arn
-
-						if err := p.waitForServerCertificate(name); err != nil {
-							return err
-						}
+						listener[1] = arn
 					}
 
 					params[listenerParam] = strings.Join(listener, ",")
@@ -547,8 +597,12 @@ func (p *Provider) releasePromoteGeneration1(a *structs.App, r *structs.Release)
 		}
 	}
 
+	if err := p.reportParameterDrift(a, data, params, opts); err != nil {
+		return err
+	}
+
 	// cache the template
-	if err := p.s3Put(settings, fmt.Sprintf("templates/%s", r.Id), data, false); err != nil {
+	if err := p.s3PutWithOptions(settings, objectKey(objectKindTemplate, "", r.Id), data, s3PutOptions{ContentType: aws.String("application/json")}); err != nil {
 		return err
 	}
 
@@ -556,6 +610,8 @@ func (p *Provider) releasePromoteGeneration1(a *structs.App, r *structs.Release)
 		return err
 	}
 
+	p.auditRecord("release.promote", r.App, p.actor(), map[string]string{"release": r.Id})
+
 	p.EventSend("release:promote", structs.EventSendOptions{Data: map[string]string{"app": r.App, "id": r.Id}, Status: options.String("start")})
 
 	return nil
@@ -624,7 +680,7 @@ func (p *Provider) releaseSave(r *structs.Release) error {
 		Body:          bytes.NewReader(env),
 		Bucket:        aws.String(settings),
 		ContentLength: aws.Int64(int64(len(env))),
-		Key:           aws.String(fmt.Sprintf("releases/%s/env", r.Id)),
+		Key:           aws.String(objectKey(objectKindEnv, "", r.Id)),
 	}
 
 	switch a.Tags["Generation"] {
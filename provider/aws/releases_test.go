@@ -1,14 +1,20 @@
 package aws_test
 
 import (
+	"encoding/json"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/convox/rack/pkg/manifest"
 	"github.com/convox/rack/pkg/options"
 	"github.com/convox/rack/pkg/structs"
 	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestReleaseGet(t *testing.T) {
@@ -331,3 +337,433 @@ var cycleReleaseQuery = awsutil.Cycle{
 		Body:       `{"Count":2,"Items":[{"id":{"S":"RVFETUHHKKD"},"build":{"S":"BHINCLZYYVN"},"app":{"S":"httpd"},"manifest":{"S":"web:\n  image: httpd\n  ports:\n  - 80:80\n"},"env":{"S":"foo=bar"},"created":{"S":"20160404.143542.627770380"}},{"id":{"S":"RFVZFLKVTYO"},"build":{"S":"BNOARQMVHUO"},"app":{"S":"httpd"},"manifest":{"S":"web:\n  image: httpd\n  ports:\n  - 80:80\n"},"env":{"S":"foo=bar"},"created":{"S":"20160403.184639.166694813"}}],"ScannedCount":2}`,
 	},
 }
+
+func TestReleaseServiceTemplateCapabilities(t *testing.T) {
+	// formation templates are loaded relative to the repository root
+	cwd, _ := os.Getwd()
+	require.NoError(t, os.Chdir("../.."))
+	defer os.Chdir(cwd)
+
+	m, err := manifest.Load([]byte("services:\n  web:\n    init: true\n    cap_add:\n      - NET_ADMIN\n    cap_drop:\n      - ALL\n"), map[string]string{})
+	require.NoError(t, err)
+
+	data, err := aws.FormationTemplate("service", map[string]interface{}{
+		"App":      "httpd",
+		"Build":    &structs.Build{Id: "BABCDEFGHI"},
+		"Manifest": m,
+		"Release":  &structs.Release{Id: "RABCDEFGHI", Build: "BABCDEFGHI"},
+		"Service":  m.Services[0],
+	})
+	require.NoError(t, err)
+
+	var template struct {
+		Resources map[string]struct {
+			Properties struct {
+				ContainerDefinitions []struct {
+					LinuxParameters map[string]interface{}
+				}
+			}
+		}
+	}
+
+	require.NoError(t, json.Unmarshal(data, &template))
+
+	cds := template.Resources["Tasks"].Properties.ContainerDefinitions
+	require.Len(t, cds, 1)
+	assert.Equal(t, map[string]interface{}{
+		"Capabilities":       map[string]interface{}{"Add": []interface{}{"NET_ADMIN"}, "Drop": []interface{}{"ALL"}},
+		"InitProcessEnabled": "true",
+	}, cds[0].LinuxParameters)
+}
+
+func TestReleaseServiceTemplateDevices(t *testing.T) {
+	// formation templates are loaded relative to the repository root
+	cwd, _ := os.Getwd()
+	require.NoError(t, os.Chdir("../.."))
+	defer os.Chdir(cwd)
+
+	m, err := manifest.Load([]byte("services:\n  web:\n    init: true\n    devices:\n      - /dev/nvidia0\n      - /dev/sdb:/dev/xvdb:rm\n"), map[string]string{})
+	require.NoError(t, err)
+
+	data, err := aws.FormationTemplate("service", map[string]interface{}{
+		"App":      "httpd",
+		"Build":    &structs.Build{Id: "BABCDEFGHI"},
+		"Manifest": m,
+		"Release":  &structs.Release{Id: "RABCDEFGHI", Build: "BABCDEFGHI"},
+		"Service":  m.Services[0],
+	})
+	require.NoError(t, err)
+
+	var template struct {
+		Resources map[string]struct {
+			Properties struct {
+				ContainerDefinitions []struct {
+					LinuxParameters map[string]interface{}
+				}
+			}
+		}
+	}
+
+	require.NoError(t, json.Unmarshal(data, &template))
+
+	cds := template.Resources["Tasks"].Properties.ContainerDefinitions
+	require.Len(t, cds, 1)
+	assert.Equal(t, map[string]interface{}{
+		"Devices": []interface{}{
+			map[string]interface{}{"ContainerPath": "/dev/nvidia0", "HostPath": "/dev/nvidia0"},
+			map[string]interface{}{"ContainerPath": "/dev/xvdb", "HostPath": "/dev/sdb", "Permissions": []interface{}{"read", "mknod"}},
+		},
+		"InitProcessEnabled": "true",
+	}, cds[0].LinuxParameters)
+}
+
+func TestReleaseTemplatesVolumes(t *testing.T) {
+	// formation templates are loaded relative to the repository root
+	cwd, _ := os.Getwd()
+	require.NoError(t, os.Chdir("../.."))
+	defer os.Chdir(cwd)
+
+	m, err := manifest.Load([]byte("services:\n  web:\n    mounts:\n      - my-data:/data\nvolumes:\n  my-data:\n    encrypted: true\n"), map[string]string{})
+	require.NoError(t, err)
+
+	var template struct {
+		Parameters map[string]interface{}
+		Resources  map[string]struct {
+			Type       string
+			DependsOn  []string
+			Properties struct {
+				ContainerDefinitions []struct {
+					MountPoints []map[string]interface{}
+				}
+				Encrypted  string
+				Parameters map[string]interface{}
+				Volumes    []map[string]interface{}
+			}
+		}
+	}
+
+	data, err := aws.FormationTemplate("app", map[string]interface{}{
+		"App":      "httpd",
+		"Build":    &structs.Build{Id: "BABCDEFGHI"},
+		"Manifest": m,
+		"Release":  &structs.Release{Id: "RABCDEFGHI", Build: "BABCDEFGHI"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &template))
+
+	assert.Equal(t, "AWS::EFS::FileSystem", template.Resources["VolumeMyData"].Type)
+	assert.Equal(t, "true", template.Resources["VolumeMyData"].Properties.Encrypted)
+	assert.Equal(t, "AWS::EFS::AccessPoint", template.Resources["VolumeMyDataAccessPoint"].Type)
+	assert.Equal(t, "AWS::EFS::MountTarget", template.Resources["VolumeMyDataMountTarget0"].Type)
+	assert.Equal(t, "AWS::EFS::MountTarget", template.Resources["VolumeMyDataMountTarget1"].Type)
+	assert.Equal(t, []string{"VolumeMyDataMountTarget0", "VolumeMyDataMountTarget1"}, template.Resources["ServiceWeb"].DependsOn)
+	assert.Equal(t, map[string]interface{}{"Ref": "VolumeMyData"}, template.Resources["ServiceWeb"].Properties.Parameters["VolumeMyData"])
+	assert.Equal(t, map[string]interface{}{"Ref": "VolumeMyDataAccessPoint"}, template.Resources["ServiceWeb"].Properties.Parameters["VolumeMyDataAccessPoint"])
+
+	data, err = aws.FormationTemplate("service", map[string]interface{}{
+		"App":      "httpd",
+		"Build":    &structs.Build{Id: "BABCDEFGHI"},
+		"Manifest": m,
+		"Release":  &structs.Release{Id: "RABCDEFGHI", Build: "BABCDEFGHI"},
+		"Service":  m.Services[0],
+	})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &template))
+
+	assert.Contains(t, template.Parameters, "VolumeMyData")
+	assert.Contains(t, template.Parameters, "VolumeMyDataAccessPoint")
+
+	tasks := template.Resources["Tasks"].Properties
+	require.Len(t, tasks.ContainerDefinitions, 1)
+	assert.Contains(t, tasks.ContainerDefinitions[0].MountPoints, map[string]interface{}{"SourceVolume": "efs-my-data", "ContainerPath": "/data"})
+	assert.Contains(t, tasks.Volumes, map[string]interface{}{
+		"Name": "efs-my-data",
+		"EFSVolumeConfiguration": map[string]interface{}{
+			"AuthorizationConfig": map[string]interface{}{"AccessPointId": map[string]interface{}{"Ref": "VolumeMyDataAccessPoint"}},
+			"FilesystemId":        map[string]interface{}{"Ref": "VolumeMyData"},
+			"TransitEncryption":   "ENABLED",
+		},
+	})
+}
+
+func TestReleaseTemplatesUser(t *testing.T) {
+	// formation templates are loaded relative to the repository root
+	cwd, _ := os.Getwd()
+	require.NoError(t, os.Chdir("../.."))
+	defer os.Chdir(cwd)
+
+	m, err := manifest.Load([]byte("services:\n  web:\n    read_only: true\n    user: app:staff\n  worker:\n    user: \"1000\"\ntimers:\n  cleanup:\n    command: bin/cleanup\n    schedule: \"0 * * * ?\"\n    service: web\n"), map[string]string{})
+	require.NoError(t, err)
+
+	var template struct {
+		Resources map[string]struct {
+			Properties struct {
+				ContainerDefinitions []map[string]interface{}
+			}
+		}
+	}
+
+	for _, s := range m.Services {
+		data, err := aws.FormationTemplate("service", map[string]interface{}{
+			"App":      "httpd",
+			"Build":    &structs.Build{Id: "BABCDEFGHI"},
+			"Manifest": m,
+			"Release":  &structs.Release{Id: "RABCDEFGHI", Build: "BABCDEFGHI"},
+			"Service":  s,
+		})
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &template))
+
+		cds := template.Resources["Tasks"].Properties.ContainerDefinitions
+		require.Len(t, cds, 1)
+
+		switch s.Name {
+		case "web":
+			assert.Equal(t, "app:staff", cds[0]["User"])
+			assert.Equal(t, true, cds[0]["ReadonlyRootFilesystem"])
+		case "worker":
+			assert.Equal(t, "1000", cds[0]["User"])
+			assert.NotContains(t, cds[0], "ReadonlyRootFilesystem")
+		}
+	}
+
+	data, err := aws.FormationTemplate("timer", map[string]interface{}{
+		"App":      "httpd",
+		"Build":    &structs.Build{Id: "BABCDEFGHI"},
+		"Manifest": m,
+		"Release":  &structs.Release{Id: "RABCDEFGHI", Build: "BABCDEFGHI"},
+		"Timer":    m.Timers[0],
+	})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &template))
+
+	cds := template.Resources["TaskDefinition"].Properties.ContainerDefinitions
+	require.Len(t, cds, 1)
+	assert.Equal(t, "app:staff", cds[0]["User"])
+	assert.Equal(t, true, cds[0]["ReadonlyRootFilesystem"])
+}
+
+func TestReleaseServiceTemplateBlueGreen(t *testing.T) {
+	// formation templates are loaded relative to the repository root
+	cwd, _ := os.Getwd()
+	require.NoError(t, os.Chdir("../.."))
+	defer os.Chdir(cwd)
+
+	m, err := manifest.Load([]byte("services:\n  web:\n    port: 3000\n    deployment:\n      strategy: blue-green\n  api:\n    port: 3000\n"), map[string]string{})
+	require.NoError(t, err)
+
+	type resource struct {
+		Type       string
+		Properties struct {
+			Actions        []map[string]interface{}
+			LoadBalancers  []map[string]interface{}
+			TaskDefinition interface{}
+		}
+	}
+
+	var template struct {
+		Outputs    map[string]interface{}
+		Parameters map[string]interface{}
+		Resources  map[string]resource
+	}
+
+	for _, s := range m.Services {
+		data, err := aws.FormationTemplate("service", map[string]interface{}{
+			"App":      "httpd",
+			"Build":    &structs.Build{Id: "BABCDEFGHI"},
+			"Manifest": m,
+			"Release":  &structs.Release{Id: "RABCDEFGHI", Build: "BABCDEFGHI"},
+			"Service":  s,
+		})
+		require.NoError(t, err)
+
+		template.Outputs = nil
+		template.Parameters = nil
+		template.Resources = nil
+
+		require.NoError(t, json.Unmarshal(data, &template))
+
+		rule := template.Resources["BalancerListenerRule443"].Properties.Actions[0]["TargetGroupArn"]
+
+		if s.Name == "api" {
+			require.NotContains(t, template.Resources, "BalancerTargetGroupGreen")
+			require.NotContains(t, template.Resources, "ServiceGreen")
+			require.NotContains(t, template.Outputs, "TargetGroupGreen")
+			require.NotContains(t, template.Parameters, "Active")
+			assert.Equal(t, []map[string]interface{}{{"ContainerName": "api", "ContainerPort": "3000", "TargetGroupArn": map[string]interface{}{"Ref": "BalancerTargetGroup"}}}, template.Resources["Service"].Properties.LoadBalancers)
+			assert.Equal(t, map[string]interface{}{"Ref": "Tasks"}, template.Resources["Service"].Properties.TaskDefinition)
+			assert.Equal(t, map[string]interface{}{"Ref": "BalancerTargetGroup"}, rule)
+			continue
+		}
+
+		require.Equal(t, "AWS::ElasticLoadBalancingV2::TargetGroup", template.Resources["BalancerTargetGroupGreen"].Type)
+		require.Equal(t, "AWS::ECS::Service", template.Resources["ServiceGreen"].Type)
+		require.Contains(t, template.Outputs, "ServiceGreen")
+		require.Contains(t, template.Outputs, "TargetGroupGreen")
+		require.Contains(t, template.Parameters, "Active")
+
+		// each color registers its own tasks with its own target group only
+		assert.Equal(t, []map[string]interface{}{{"ContainerName": "web", "ContainerPort": "3000", "TargetGroupArn": map[string]interface{}{"Ref": "BalancerTargetGroup"}}}, template.Resources["Service"].Properties.LoadBalancers)
+		assert.Equal(t, []map[string]interface{}{{"ContainerName": "web", "ContainerPort": "3000", "TargetGroupArn": map[string]interface{}{"Ref": "BalancerTargetGroupGreen"}}}, template.Resources["ServiceGreen"].Properties.LoadBalancers)
+
+		assert.Equal(t, map[string]interface{}{"Fn::If": []interface{}{"PinnedBlue", map[string]interface{}{"Ref": "TasksBlue"}, map[string]interface{}{"Ref": "Tasks"}}}, template.Resources["Service"].Properties.TaskDefinition)
+		assert.Equal(t, map[string]interface{}{"Fn::If": []interface{}{"PinnedGreen", map[string]interface{}{"Ref": "TasksGreen"}, map[string]interface{}{"Ref": "Tasks"}}}, template.Resources["ServiceGreen"].Properties.TaskDefinition)
+
+		assert.Equal(t, map[string]interface{}{"Fn::If": []interface{}{"ActiveGreen", map[string]interface{}{"Ref": "BalancerTargetGroupGreen"}, map[string]interface{}{"Ref": "BalancerTargetGroup"}}}, rule)
+	}
+}
+func TestReleaseServiceTemplateGpu(t *testing.T) {
+	// formation templates are loaded relative to the repository root
+	cwd, _ := os.Getwd()
+	require.NoError(t, os.Chdir("../.."))
+	defer os.Chdir(cwd)
+
+	m, err := manifest.Load([]byte("services:\n  train:\n    scale:\n      gpu: 2\n"), map[string]string{})
+	require.NoError(t, err)
+
+	data, err := aws.FormationTemplate("service", map[string]interface{}{
+		"App":      "httpd",
+		"Build":    &structs.Build{Id: "BABCDEFGHI"},
+		"Manifest": m,
+		"Release":  &structs.Release{Id: "RABCDEFGHI", Build: "BABCDEFGHI"},
+		"Service":  m.Services[0],
+	})
+	require.NoError(t, err)
+
+	var template struct {
+		Resources map[string]struct {
+			Properties struct {
+				ContainerDefinitions []struct {
+					ResourceRequirements []map[string]string
+				}
+			}
+		}
+	}
+
+	require.NoError(t, json.Unmarshal(data, &template))
+
+	cds := template.Resources["Tasks"].Properties.ContainerDefinitions
+	require.Len(t, cds, 1)
+	assert.Equal(t, []map[string]string{{"Type": "GPU", "Value": "2"}}, cds[0].ResourceRequirements)
+}
+
+func TestReleaseTemplateAppOptions(t *testing.T) {
+	// formation templates are loaded relative to the repository root
+	cwd, _ := os.Getwd()
+	require.NoError(t, os.Chdir("../.."))
+	defer os.Chdir(cwd)
+
+	m, err := manifest.Load([]byte("app:\n  timezone: Europe/Paris\n  features:\n    ecs-exec: true\n    readonly-root: true\nservices:\n  web:\n    command: work\n    read_only: true\ntimers:\n  daily:\n    schedule: \"0 9 * * ?\"\n    command: bin/daily\n    service: web\n"), map[string]string{})
+	require.NoError(t, err)
+
+	data, err := aws.FormationTemplate("service", map[string]interface{}{
+		"App":      "httpd",
+		"Build":    &structs.Build{Id: "BABCDEFGHI"},
+		"Manifest": m,
+		"Release":  &structs.Release{Id: "RABCDEFGHI", Build: "BABCDEFGHI"},
+		"Service":  m.Services[0],
+	})
+	require.NoError(t, err)
+
+	var service struct {
+		Resources map[string]struct {
+			Properties struct {
+				ContainerDefinitions []struct {
+					ReadonlyRootFilesystem bool
+				}
+				EnableExecuteCommand string
+			}
+		}
+	}
+
+	require.NoError(t, json.Unmarshal(data, &service))
+
+	assert.Equal(t, "true", service.Resources["Service"].Properties.EnableExecuteCommand)
+
+	cds := service.Resources["Tasks"].Properties.ContainerDefinitions
+	require.Len(t, cds, 1)
+	assert.True(t, cds[0].ReadonlyRootFilesystem)
+
+	// the feature and the service option render a single key
+	assert.Equal(t, 1, strings.Count(string(data), `"ReadonlyRootFilesystem"`))
+
+	data, err = aws.FormationTemplate("timer", map[string]interface{}{
+		"App":      "httpd",
+		"Build":    &structs.Build{Id: "BABCDEFGHI"},
+		"Manifest": m,
+		"Release":  &structs.Release{Id: "RABCDEFGHI", Build: "BABCDEFGHI"},
+		"Timer":    m.Timers[0],
+	})
+	require.NoError(t, err)
+
+	var timer struct {
+		Resources map[string]struct {
+			Properties struct {
+				ContainerDefinitions []struct {
+					Environment []map[string]interface{}
+				}
+			}
+		}
+	}
+
+	require.NoError(t, json.Unmarshal(data, &timer))
+
+	cds2 := timer.Resources["TaskDefinition"].Properties.ContainerDefinitions
+	require.Len(t, cds2, 1)
+	assert.Contains(t, cds2[0].Environment, map[string]interface{}{"Name": "TZ", "Value": "Europe/Paris"})
+}
+
+func TestReleaseTemplateSidecars(t *testing.T) {
+	// formation templates are loaded relative to the repository root
+	cwd, _ := os.Getwd()
+	require.NoError(t, os.Chdir("../.."))
+	defer os.Chdir(cwd)
+
+	m, err := manifest.Load([]byte("services:\n  web:\n    command: work\n    sidecars:\n      - name: envoy\n        image: envoyproxy/envoy:v1.27\n        essential: true\n        depends_on:\n          - container: web\n      - name: logs\n        image: fluent/fluent-bit\n        environment:\n          FLUSH: \"5\"\n        volumes_from:\n          - web\n        depends_on:\n          - container: envoy\n            condition: HEALTHY\n"), map[string]string{})
+	require.NoError(t, err)
+
+	data, err := aws.FormationTemplate("service", map[string]interface{}{
+		"App":      "httpd",
+		"Build":    &structs.Build{Id: "BABCDEFGHI"},
+		"Manifest": m,
+		"Release":  &structs.Release{Id: "RABCDEFGHI", Build: "BABCDEFGHI"},
+		"Service":  m.Services[0],
+	})
+	require.NoError(t, err)
+
+	var service struct {
+		Resources map[string]struct {
+			Properties struct {
+				ContainerDefinitions []struct {
+					DependsOn   []map[string]string
+					Environment []map[string]interface{}
+					Essential   string
+					Image       interface{}
+					Name        string
+					VolumesFrom []map[string]interface{}
+				}
+			}
+		}
+	}
+
+	require.NoError(t, json.Unmarshal(data, &service))
+
+	cds := service.Resources["Tasks"].Properties.ContainerDefinitions
+	require.Len(t, cds, 3)
+
+	// the main container leaves essential at its default so it keeps the task alive on its own
+	assert.Equal(t, "web", cds[0].Name)
+	assert.Equal(t, "", cds[0].Essential)
+
+	assert.Equal(t, "envoy", cds[1].Name)
+	assert.Equal(t, "envoyproxy/envoy:v1.27", cds[1].Image)
+	assert.Equal(t, "true", cds[1].Essential)
+	assert.Equal(t, []map[string]string{{"Condition": "START", "ContainerName": "web"}}, cds[1].DependsOn)
+
+	// a non-essential sidecar that exits does not stop the task or the main container
+	assert.Equal(t, "logs", cds[2].Name)
+	assert.Equal(t, "false", cds[2].Essential)
+	assert.Equal(t, []map[string]string{{"Condition": "HEALTHY", "ContainerName": "envoy"}}, cds[2].DependsOn)
+	assert.Contains(t, cds[2].Environment, map[string]interface{}{"Name": "FLUSH", "Value": "5"})
+	assert.Contains(t, cds[2].VolumesFrom, map[string]interface{}{"SourceContainer": "web"})
+}
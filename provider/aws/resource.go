@@ -1,12 +1,9 @@
 package aws
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"math/rand"
 	"net/url"
 	"sort"
 	"strings"
@@ -139,14 +136,14 @@ var resourceSystemParameters = map[string]bool{
 // ResourceCreate creates a new resource.
 // Note: see also createResource() below.
 func (p *Provider) SystemResourceCreate(kind string, opts structs.ResourceCreateOptions) (*structs.Resource, error) {
-	name := fmt.Sprintf("%s-%d", kind, (rand.Intn(8999) + 1000))
+	name := fmt.Sprintf("%s-%d", kind, (randomInt(8999) + 1000))
 
 	if opts.Name != nil {
 		name = *opts.Name
 	}
 
 	_, err := p.SystemResourceGet(name)
-	if awsError(err) != "ValidationError" {
+	if !isNotFound(err) {
 		return nil, fmt.Errorf("resource named %s already exists", name)
 	}
 
@@ -546,7 +543,7 @@ func (p *Provider) createResource(s *structs.Resource) (*cloudformation.CreateSt
 	}
 
 	if s.Parameters["Password"] == "" {
-		pw, err := generatePassword()
+		pw, err := securePassword(30)
 		if err != nil {
 			return nil, err
 		}
@@ -563,9 +560,14 @@ func (p *Provider) createResource(s *structs.Resource) (*cloudformation.CreateSt
 		return nil, err
 	}
 
+	topic, err := p.cloudformationTopic()
+	if err != nil {
+		return nil, err
+	}
+
 	req := &cloudformation.CreateStackInput{
 		Capabilities:     []*string{aws.String("CAPABILITY_IAM")},
-		NotificationARNs: []*string{aws.String(p.CloudformationTopic)},
+		NotificationARNs: []*string{aws.String(topic)},
 		StackName:        aws.String(fmt.Sprintf("%s-%s", p.Rack, s.Name)),
 		TemplateBody:     aws.String(formation),
 	}
@@ -802,18 +804,6 @@ func filterFormationParameters(s *structs.Resource, formation string) error {
 	return nil
 }
 
-func generatePassword() (string, error) {
-	data := make([]byte, 1024)
-
-	if _, err := rand.Read(data); err != nil {
-		return "", err
-	}
-
-	hash := sha256.Sum256(data)
-
-	return hex.EncodeToString(hash[:])[0:30], nil
-}
-
 func resourceFormation(kind string, data interface{}) (string, error) {
 	d, err := buildTemplate(fmt.Sprintf("resource/%s", kind), "resource", data)
 	if err != nil {
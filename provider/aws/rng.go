@@ -0,0 +1,126 @@
+package aws
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+)
+
+var passwordAlphabet = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789")
+
+// randSource is where identifiers and passwords draw their randomness from
+// it is crypto/rand outside of tests, which can swap in a seeded source for reproducible ids
+var randSource io.Reader = crand.Reader
+
+// jitterSources hands each caller its own math/rand source so jitter does not contend on the global one
+var jitterSources = sync.Pool{
+	New: func() interface{} {
+		var seed int64
+
+		if err := binary.Read(crand.Reader, binary.LittleEndian, &seed); err != nil {
+			panic(fmt.Errorf("could not seed jitter: %s", err))
+		}
+
+		return rand.New(rand.NewSource(seed))
+	},
+}
+
+// lockedReader serializes reads from a source that is not safe for concurrent use such as a seeded *rand.Rand
+type lockedReader struct {
+	lock   sync.Mutex
+	source io.Reader
+}
+
+func (l *lockedReader) Read(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.source.Read(p)
+}
+
+// jitter returns a random duration in nanoseconds in [0,n), zero when n is not positive
+func jitter(n int64) int64 {
+	if n <= 0 {
+		return 0
+	}
+
+	r := jitterSources.Get().(*rand.Rand)
+	defer jitterSources.Put(r)
+
+	return r.Int63n(n)
+}
+
+// randomInt returns a uniform random int in [0,n) from randSource
+func randomInt(n int) int {
+	return int(randomIndexes(n, 1)[0])
+}
+
+// randomRunes returns size runes picked uniformly from alphabet using randSource
+func randomRunes(alphabet []rune, size int) string {
+	return pickRunes(alphabet, randomIndexes(len(alphabet), size))
+}
+
+func pickRunes(alphabet []rune, is []uint32) string {
+	b := make([]rune, len(is))
+
+	for i, j := range is {
+		b[i] = alphabet[j]
+	}
+
+	return string(b)
+}
+
+// randomIndexes is readIndexes for callers that can not surface an error
+// a failing crypto source leaves nothing safe to fall back to so it panics
+func randomIndexes(n, size int) []uint32 {
+	is, err := readIndexes(n, size)
+	if err != nil {
+		panic(fmt.Errorf("could not read random data: %s", err))
+	}
+
+	return is
+}
+
+// readIndexes reads size uniform values in [0,n) from randSource, rejecting values
+// past the largest multiple of n so that small alphabets are not biased
+func readIndexes(n, size int) ([]uint32, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid range: %d", n)
+	}
+
+	limit := (1 << 32) / uint64(n) * uint64(n)
+
+	is := make([]uint32, 0, size)
+	buf := make([]byte, 4*size)
+
+	for len(is) < size {
+		want := 4 * (size - len(is))
+
+		if _, err := io.ReadFull(randSource, buf[:want]); err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < want; i += 4 {
+			v := uint64(binary.LittleEndian.Uint32(buf[i : i+4]))
+
+			if v < limit {
+				is = append(is, uint32(v%uint64(n)))
+			}
+		}
+	}
+
+	return is, nil
+}
+
+// securePassword returns a random alphanumeric password of size characters
+func securePassword(size int) (string, error) {
+	is, err := readIndexes(len(passwordAlphabet), size)
+	if err != nil {
+		return "", err
+	}
+
+	return pickRunes(passwordAlphabet, is), nil
+}
@@ -0,0 +1,139 @@
+package aws_test
+
+import (
+	"math/rand"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/provider/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIdSeeded(t *testing.T) {
+	generate := func() []string {
+		restore := aws.SetRandSource(rand.New(rand.NewSource(42)))
+		defer restore()
+
+		return []string{aws.GenerateId("B", 10), aws.RandomString(20), aws.GenerateId("R", 10)}
+	}
+
+	first := generate()
+
+	assert.Equal(t, first, generate())
+	assert.Regexp(t, regexp.MustCompile(`^B[A-Z]{10}$`), first[0])
+	assert.Regexp(t, regexp.MustCompile(`^[A-Za-z]{20}$`), first[1])
+	assert.Regexp(t, regexp.MustCompile(`^R[A-Z]{10}$`), first[2])
+	assert.NotEqual(t, first[0][1:], first[2][1:])
+}
+
+func TestGenerateIdUnique(t *testing.T) {
+	seen := map[string]bool{}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 1000; j++ {
+				id := aws.GenerateId("B", 10)
+
+				lock.Lock()
+				seen[id] = true
+				lock.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Len(t, seen, 16000)
+}
+
+func TestSecurePassword(t *testing.T) {
+	pw, err := aws.SecurePassword(30)
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[A-Za-z0-9]{30}$`), pw)
+
+	pw2, err := aws.SecurePassword(30)
+	require.NoError(t, err)
+	assert.NotEqual(t, pw, pw2)
+
+	restore := aws.SetRandSource(rand.New(rand.NewSource(7)))
+	a, err := aws.SecurePassword(30)
+	restore()
+	require.NoError(t, err)
+
+	restore = aws.SetRandSource(rand.New(rand.NewSource(7)))
+	b, err := aws.SecurePassword(30)
+	restore()
+	require.NoError(t, err)
+
+	assert.Equal(t, a, b)
+}
+
+func TestRandomInt(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		n := aws.RandomInt(10)
+		assert.True(t, n >= 0 && n < 10, "%d out of range", n)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	assert.Equal(t, int64(0), aws.Jitter(0))
+	assert.Equal(t, int64(0), aws.Jitter(-1))
+
+	for i := 0; i < 1000; i++ {
+		j := aws.Jitter(int64(time.Second))
+		assert.True(t, j >= 0 && j < int64(time.Second), "%d out of range", j)
+	}
+}
+
+// compare with BenchmarkGlobalRandIdParallel to see the contention on the global math/rand source
+func BenchmarkGenerateIdParallel(b *testing.B) {
+	benchmarkParallel(b, func() { aws.GenerateId("B", 10) })
+}
+
+func BenchmarkJitterParallel(b *testing.B) {
+	benchmarkParallel(b, func() { aws.Jitter(int64(time.Second)) })
+}
+
+func BenchmarkGlobalRandIdParallel(b *testing.B) {
+	alphabet := []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+	benchmarkParallel(b, func() {
+		id := make([]rune, 10)
+		for i := range id {
+			id[i] = alphabet[rand.Intn(len(alphabet))]
+		}
+	})
+}
+
+// benchmarkParallel runs fn from 64 goroutines
+func benchmarkParallel(b *testing.B, fn func()) {
+	var wg sync.WaitGroup
+
+	n := b.N/64 + 1
+
+	b.ResetTimer()
+
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < n; j++ {
+				fn()
+			}
+		}()
+	}
+
+	wg.Wait()
+}
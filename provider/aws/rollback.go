@@ -0,0 +1,119 @@
+package aws
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// RecoverFromRollbackConfirmation must be passed to RecoverFromRollback to allow it to delete the stack
+const RecoverFromRollbackConfirmation = "DELETE"
+
+var (
+	stackDeletePollInterval = 5 * time.Second
+	stackDeleteTimeout      = 30 * time.Minute
+)
+
+// RecoverFromRollback recreates a stack that is stuck in ROLLBACK_COMPLETE
+// cloudformation will not update a stack in that state so it is deleted and created again
+// from its original template with the same parameters and tags
+// cloudformation masks NoEcho parameters so their values must be passed in masked, keyed by parameter name
+func (p *Provider) RecoverFromRollback(name, confirm string, masked map[string]string) error {
+	log := Logger.At("RecoverFromRollback").Namespace("name=%q", name).Start()
+
+	if confirm != RecoverFromRollbackConfirmation {
+		return log.Error(fmt.Errorf("recovering stack %s deletes it, confirm with %s", name, RecoverFromRollbackConfirmation))
+	}
+
+	stack, err := p.describeStack(name)
+	if err != nil {
+		return log.Error(err)
+	}
+
+	if status := cs(stack.StackStatus, ""); status != cloudformation.StackStatusRollbackComplete {
+		return log.Error(fmt.Errorf("stack %s is %s, only stacks in %s can be recovered", name, status, cloudformation.StackStatusRollbackComplete))
+	}
+
+	// refuse before the stack is deleted, the parameters can not be recovered afterwards
+	params, err := unmaskParameters(name, stackParameters(stack), masked)
+	if err != nil {
+		return log.Error(err)
+	}
+
+	res, err := p.cloudformation().GetTemplate(&cloudformation.GetTemplateInput{
+		StackName:     aws.String(name),
+		TemplateStage: aws.String(cloudformation.TemplateStageOriginal),
+	})
+	if err != nil {
+		return log.Error(err)
+	}
+
+	template := cs(res.TemplateBody, "")
+
+	if template == "" {
+		return log.Error(fmt.Errorf("no template for stack: %s", name))
+	}
+
+	tags := map[string]string{}
+
+	for _, st := range stack.Tags {
+		tags[cs(st.Key, "")] = cs(st.Value, "")
+	}
+
+	_, err = p.cloudformation().DeleteStack(&cloudformation.DeleteStackInput{
+		StackName: aws.String(name),
+	})
+	if err != nil {
+		return log.Error(err)
+	}
+
+	p.invalidateStack(name)
+
+	if err := p.waitForStackDeleted(cs(stack.StackId, name)); err != nil {
+		return log.Error(err)
+	}
+
+	if err := p.createStack(name, []byte(template), params, tags); err != nil {
+		return log.Error(err)
+	}
+
+	p.invalidateStack(name)
+
+	return log.Success()
+}
+
+// waitForStackDeleted polls a stack by id, deleted stacks can only be described by their id
+func (p *Provider) waitForStackDeleted(id string) error {
+	done := time.Now().Add(stackDeleteTimeout)
+
+	for {
+		res, err := p.cloudformation().DescribeStacks(&cloudformation.DescribeStacksInput{
+			StackName: aws.String(id),
+		})
+		if isNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(res.Stacks) == 0 {
+			return nil
+		}
+
+		switch status := cs(res.Stacks[0].StackStatus, ""); status {
+		case cloudformation.StackStatusDeleteComplete:
+			return nil
+		case cloudformation.StackStatusDeleteFailed:
+			return fmt.Errorf("could not delete stack: %s", id)
+		}
+
+		if time.Now().After(done) {
+			return fmt.Errorf("timeout waiting for stack %s to be deleted", id)
+		}
+
+		time.Sleep(stackDeletePollInterval)
+	}
+}
@@ -0,0 +1,186 @@
+package aws_test
+
+import (
+	"math/rand"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rollbackStackId = "arn:aws:cloudformation:us-east-1:778743527532:stack/convox-httpd/5a1b2c3d"
+
+func TestRecoverFromRollback(t *testing.T) {
+	defer aws.SetRandSource(rand.New(rand.NewSource(1)))()
+
+	aws.SetStackDeletePollInterval(1 * time.Millisecond)
+
+	provider := StubAwsProvider(
+		cycleRollbackDescribeStacks("convox-httpd", "ROLLBACK_COMPLETE"),
+		cycleRollbackGetTemplate,
+		cycleRollbackDeleteStack,
+		cycleRollbackDescribeStacks(rollbackStackId, "DELETE_IN_PROGRESS"),
+		cycleRollbackDescribeStacks(rollbackStackId, "DELETE_COMPLETE"),
+		cycleRollbackCreateStack,
+	)
+	defer provider.Close()
+
+	err := provider.RecoverFromRollback("convox-httpd", "DELETE", nil)
+	require.NoError(t, err)
+}
+
+func TestRecoverFromRollbackUnconfirmed(t *testing.T) {
+	provider := StubAwsProvider()
+	defer provider.Close()
+
+	err := provider.RecoverFromRollback("convox-httpd", "yes", nil)
+	require.EqualError(t, err, "recovering stack convox-httpd deletes it, confirm with DELETE")
+}
+
+func TestRecoverFromRollbackWrongStatus(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleRollbackDescribeStacks("convox-httpd", "UPDATE_ROLLBACK_COMPLETE"),
+	)
+	defer provider.Close()
+
+	err := provider.RecoverFromRollback("convox-httpd", "DELETE", nil)
+	require.EqualError(t, err, "stack convox-httpd is UPDATE_ROLLBACK_COMPLETE, only stacks in ROLLBACK_COMPLETE can be recovered")
+}
+
+func TestRecoverFromRollbackMasked(t *testing.T) {
+	aws.SetStackDeletePollInterval(1 * time.Millisecond)
+
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name:       "convox-httpd",
+		Parameters: map[string]string{"Cluster": "convox-Cluster-1E4XJ0PQWNAYS", "ResourcePassword": "****"},
+		Status:     "ROLLBACK_COMPLETE",
+		Template:   `{"Parameters":{"Cluster":{"Type":"String"},"ResourcePassword":{"Type":"String","NoEcho":true}},"Resources":{}}`,
+	})
+
+	err := provider.RecoverFromRollback("convox-httpd", "DELETE", nil)
+	require.EqualError(t, err, "stack convox-httpd has NoEcho parameters that can not be read back, supply values for: ResourcePassword")
+
+	s, ok := provider.Fake.CloudFormation.Stack("convox-httpd")
+	require.True(t, ok)
+	assert.Equal(t, "ROLLBACK_COMPLETE", s.Status)
+
+	err = provider.RecoverFromRollback("convox-httpd", "DELETE", map[string]string{"ResourcePassword": "secret"})
+	require.NoError(t, err)
+
+	s, ok = provider.Fake.CloudFormation.Stack("convox-httpd")
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"Cluster": "convox-Cluster-1E4XJ0PQWNAYS", "ResourcePassword": "secret"}, s.Parameters)
+}
+
+func cycleRollbackDescribeStacks(name, status string) awsutil.Cycle {
+	return awsutil.Cycle{
+		Request: awsutil.Request{
+			RequestURI: "/",
+			Operation:  "",
+			Body: url.Values{
+				"Action":    {"DescribeStacks"},
+				"StackName": {name},
+				"Version":   {"2010-05-15"},
+			}.Encode(),
+		},
+		Response: awsutil.Response{
+			StatusCode: 200,
+			Body: `
+				<DescribeStacksResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+					<DescribeStacksResult>
+						<Stacks>
+							<member>
+								<StackId>` + rollbackStackId + `</StackId>
+								<StackName>convox-httpd</StackName>
+								<StackStatus>` + status + `</StackStatus>
+								<Parameters>
+									<member>
+										<ParameterKey>Cluster</ParameterKey>
+										<ParameterValue>convox-Cluster-1E4XJ0PQWNAYS</ParameterValue>
+									</member>
+								</Parameters>
+								<Tags>
+									<member>
+										<Key>Rack</Key>
+										<Value>convox</Value>
+									</member>
+								</Tags>
+							</member>
+						</Stacks>
+					</DescribeStacksResult>
+				</DescribeStacksResponse>
+			`,
+		},
+	}
+}
+
+var cycleRollbackGetTemplate = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "",
+		Body:       `Action=GetTemplate&StackName=convox-httpd&TemplateStage=Original&Version=2010-05-15`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `
+			<GetTemplateResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+				<GetTemplateResult>
+					<TemplateBody>{"Resources":{}}</TemplateBody>
+				</GetTemplateResult>
+			</GetTemplateResponse>
+		`,
+	},
+}
+
+var cycleRollbackDeleteStack = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "",
+		Body:       `Action=DeleteStack&StackName=convox-httpd&Version=2010-05-15`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `
+			<DeleteStackResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+			</DeleteStackResponse>
+		`,
+	},
+}
+
+var cycleRollbackCreateStack = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "",
+		Body: url.Values{
+			"Action":                             {"CreateStack"},
+			"Capabilities.member.1":              {"CAPABILITY_IAM"},
+			"ClientRequestToken":                 {"convox-httpd-47ae1gDv3aZMGkgaJT5U"},
+			"NotificationARNs.member.1":          {"arn:aws:sns:us-test-1:123456789012:convox-events"},
+			"Parameters.member.1.ParameterKey":   {"Cluster"},
+			"Parameters.member.1.ParameterValue": {"convox-Cluster-1E4XJ0PQWNAYS"},
+			"StackName":                          {"convox-httpd"},
+			"Tags.member.1.Key":                  {"Rack"},
+			"Tags.member.1.Value":                {"convox"},
+			"TemplateBody":                       {`{"Resources":{}}`},
+			"Version":                            {"2010-05-15"},
+		}.Encode(),
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `
+			<CreateStackResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+				<CreateStackResult>
+					<StackId>` + rollbackStackId + `</StackId>
+				</CreateStackResult>
+			</CreateStackResponse>
+		`,
+	},
+}
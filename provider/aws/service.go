@@ -2,6 +2,8 @@ package aws
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -13,6 +15,9 @@ import (
 	"github.com/convox/rack/pkg/structs"
 )
 
+// describeServicesLimit is the most services ecs describes in a single call
+const describeServicesLimit = 10
+
 func (p *Provider) ServiceList(app string) (structs.Services, error) {
 	a, err := p.AppGet(app)
 	if err != nil {
@@ -174,8 +179,13 @@ func (p *Provider) serviceListGeneration1(a *structs.App) (structs.Services, err
 				Container: msp.Container,
 			}
 
-			if lp := strings.Split(a.Parameters[fmt.Sprintf("%sPort%dListener", upperName(ms.Name), msp.Balancer)], ","); len(lp) > 1 {
-				p.Certificate = certificateFriendlyId(lp[1])
+			if lp := strings.Split(a.Parameters[fmt.Sprintf("%sPort%dListener", upperName(ms.Name), msp.Balancer)], ","); len(lp) > 1 && lp[1] != "" {
+				id, err := certificateFriendlyId(lp[1])
+				if err != nil {
+					return nil, err
+				}
+
+				p.Certificate = id
 			}
 
 			s.Ports = append(s.Ports, p)
@@ -260,5 +270,306 @@ func (p *Provider) ServiceUpdate(app, name string, opts structs.ServiceUpdateOpt
 		return err
 	}
 
+	p.auditRecord("service.scale", a.Name, p.actor(), map[string]string{"service": name, "formation": strings.Join(parts, ",")})
+
 	return nil
 }
+
+// ServiceScaleOptions are the values to scale a service to, nil values are left as they are
+type ServiceScaleOptions struct {
+	Count  *int64
+	Cpu    *int64
+	Memory *int64
+}
+
+// ServiceScaleError is returned by ServiceScale when the service does not exist or a value is out of range
+type ServiceScaleError struct {
+	Service string
+	Field   string
+	Value   int64
+	Min     int64
+	Max     int64
+}
+
+func (e ServiceScaleError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("service not found: %s", e.Service)
+	}
+
+	return fmt.Sprintf("service %s %s %d out of range, must be between %d and %d", e.Service, e.Field, e.Value, e.Min, e.Max)
+}
+
+// Code is 404 when the service does not exist and 400 when a value is out of range
+func (e ServiceScaleError) Code() int {
+	if e.Field == "" {
+		return 404
+	}
+
+	return 400
+}
+
+// ServiceScale changes the count, cpu or memory of a service of a generation 2 app
+// The count must be within the autoscaling range of the manifest of the current release and cpu and
+// memory must fit on the instances of the rack, or be a supported combination for fargate services.
+// Only the formation parameter of the service is changed and nothing is changed if the values already match
+func (p *Provider) ServiceScale(app, service string, opts ServiceScaleOptions) error {
+	a, err := p.AppGet(app)
+	if err != nil {
+		return err
+	}
+
+	if a.Tags["Generation"] != "2" {
+		return fmt.Errorf("app %s must be generation 2 to scale services", app)
+	}
+
+	if a.Release == "" {
+		return ServiceScaleError{Service: service}
+	}
+
+	r, err := p.ReleaseGet(a.Name, a.Release)
+	if err != nil {
+		return err
+	}
+
+	env, err := helpers.AppEnvironment(p, app)
+	if err != nil {
+		return err
+	}
+
+	m, err := manifest.Load([]byte(r.Manifest), env)
+	if err != nil {
+		return err
+	}
+
+	ms, err := m.Service(service)
+	if err != nil {
+		return ServiceScaleError{Service: service}
+	}
+
+	changes, err := p.serviceScaleChanges(a, m, *ms, opts)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if err := p.updateStack(p.rackStack(a.Name), nil, changes, map[string]string{}, ""); err != nil {
+		return err
+	}
+
+	p.auditRecord("service.scale", a.Name, p.actor(), map[string]string{"service": service, "formation": changes[fmt.Sprintf("%sFormation", upperName(service))]})
+
+	return nil
+}
+
+// serviceScaleChanges validates opts against the manifest and rack capacity and returns the stack parameters to change
+func (p *Provider) serviceScaleChanges(a *structs.App, m *manifest.Manifest, s manifest.Service, opts ServiceScaleOptions) (map[string]string, error) {
+	param := fmt.Sprintf("%sFormation", upperName(s.Name))
+
+	current, ok := a.Parameters[param]
+	if !ok {
+		return nil, ServiceScaleError{Service: s.Name}
+	}
+
+	parts := strings.Split(current, ",")
+
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("could not read formation for service: %s", s.Name)
+	}
+
+	if opts.Count != nil {
+		// a service that autoscales must stay within its range, otherwise any count is allowed
+		min, max := int64(0), int64(math.MaxInt32)
+
+		if s.Scale.Count.Max > s.Scale.Count.Min {
+			min, max = int64(s.Scale.Count.Min), int64(s.Scale.Count.Max)
+		}
+
+		if *opts.Count < min || *opts.Count > max {
+			return nil, ServiceScaleError{Service: s.Name, Field: "count", Value: *opts.Count, Min: min, Max: max}
+		}
+
+		parts[0] = strconv.FormatInt(*opts.Count, 10)
+	}
+
+	if opts.Cpu != nil {
+		parts[1] = strconv.FormatInt(*opts.Cpu, 10)
+	}
+
+	if opts.Memory != nil {
+		parts[2] = strconv.FormatInt(*opts.Memory, 10)
+	}
+
+	formation := strings.Join(parts, ",")
+
+	if formation == current {
+		return map[string]string{}, nil
+	}
+
+	if opts.Cpu != nil || opts.Memory != nil {
+		if err := p.serviceScaleFits(a, m, s, formation); err != nil {
+			return nil, err
+		}
+	}
+
+	return map[string]string{param: formation}, nil
+}
+
+// serviceScaleFits returns an error if a service can not be placed with the cpu and memory of formation
+func (p *Provider) serviceScaleFits(a *structs.App, m *manifest.Manifest, s manifest.Service, formation string) error {
+	fa := *a
+	fa.Parameters = map[string]string{}
+
+	for k, v := range a.Parameters {
+		fa.Parameters[k] = v
+	}
+
+	fa.Parameters[fmt.Sprintf("%sFormation", upperName(s.Name))] = formation
+
+	cpu, memory, fargate := serviceFormation(&fa, m, s)
+
+	if cpu < 1 {
+		return ServiceScaleError{Service: s.Name, Field: "cpu", Value: int64(cpu), Min: 1, Max: math.MaxInt32}
+	}
+
+	if memory < 1 {
+		return ServiceScaleError{Service: s.Name, Field: "memory", Value: int64(memory), Min: 1, Max: math.MaxInt32}
+	}
+
+	if fargate {
+		if !fargateCombination(cpu, memory) {
+			return fmt.Errorf("service %s requests cpu=%d memory=%d which is not a supported fargate combination", s.Name, cpu, memory)
+		}
+
+		return nil
+	}
+
+	c, err := p.clusterCapacity()
+	if err != nil {
+		return err
+	}
+
+	// nothing to compare against for instance types we do not know
+	if c == nil {
+		return nil
+	}
+
+	if int64(cpu) > c.Cpu {
+		return ServiceScaleError{Service: s.Name, Field: "cpu", Value: int64(cpu), Min: 1, Max: c.Cpu}
+	}
+
+	if int64(memory) > c.Memory {
+		return ServiceScaleError{Service: s.Name, Field: "memory", Value: int64(memory), Min: 1, Max: c.Memory}
+	}
+
+	return nil
+}
+
+// serviceEcs returns the ecs service that runs a service of an app
+func (p *Provider) serviceEcs(app, service string) (*ecs.Service, error) {
+	arn, err := p.serviceEcsArn(app, service)
+	if err != nil {
+		return nil, err
+	}
+
+	ss, failures, err := p.describeServicesChecked(&ecs.DescribeServicesInput{
+		Cluster:  aws.String(p.Cluster),
+		Services: []*string{aws.String(arn)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("could not find ecs service for %s/%s: %s", app, service, aws.StringValue(failures[0].Reason))
+	}
+	if len(ss) != 1 {
+		return nil, fmt.Errorf("could not find ecs service for %s/%s", app, service)
+	}
+
+	return ss[0], nil
+}
+
+// serviceCapacity returns the desired, pending and running task counts of each service of an app
+func (p *Provider) serviceCapacity(app string) (map[string]structs.ServiceCapacity, error) {
+	rs, err := p.appResourcesRecursive(app)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]string{}
+	arns := []*string{}
+
+	// generation 1 stacks hold the ecs service as ServiceWeb, generation 2 as Service in the nested ServiceWeb stack
+	for id, arn := range rs {
+		if !strings.HasPrefix(id, "Service") || !strings.Contains(arn, ":service/") {
+			continue
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(id, "Service"), ".Service")
+
+		if name == "" || strings.Contains(name, ".") {
+			continue
+		}
+
+		names[arn] = dashName(name)
+		arns = append(arns, aws.String(arn))
+	}
+
+	sort.Slice(arns, func(i, j int) bool { return *arns[i] < *arns[j] })
+
+	cs := map[string]structs.ServiceCapacity{}
+
+	for i := 0; i < len(arns); i += describeServicesLimit {
+		j := i + describeServicesLimit
+		if j > len(arns) {
+			j = len(arns)
+		}
+
+		// services deleted mid-flight come back as failures and are left out of the capacity
+		ss, _, err := p.describeServicesChecked(&ecs.DescribeServicesInput{
+			Cluster:  aws.String(p.Cluster),
+			Services: arns[i:j],
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range ss {
+			name, ok := names[aws.StringValue(s.ServiceArn)]
+			if !ok {
+				continue
+			}
+
+			cs[name] = structs.ServiceCapacity{
+				Desired: int(aws.Int64Value(s.DesiredCount)),
+				Pending: int(aws.Int64Value(s.PendingCount)),
+				Running: int(aws.Int64Value(s.RunningCount)),
+			}
+		}
+	}
+
+	return cs, nil
+}
+
+// serviceEcsArn returns the arn of the ecs service that runs a service of an app
+func (p *Provider) serviceEcsArn(app, service string) (string, error) {
+	stack, err := p.stackResource(p.rackStack(app), fmt.Sprintf("Service%s", upperName(service)))
+	if err != nil {
+		return "", err
+	}
+	if stack.PhysicalResourceId == nil {
+		return "", fmt.Errorf("invalid stack resource")
+	}
+
+	svc, err := p.stackResource(*stack.PhysicalResourceId, "Service")
+	if err != nil {
+		return "", err
+	}
+	if svc.PhysicalResourceId == nil {
+		return "", fmt.Errorf("invalid service resource")
+	}
+
+	return *svc.PhysicalResourceId, nil
+}
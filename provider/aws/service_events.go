@@ -0,0 +1,189 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// TaskEvent is a state change of a task belonging to a service
+type TaskEvent struct {
+	Cluster        string
+	DesiredStatus  string
+	Group          string
+	LastStatus     string
+	StoppedReason  string
+	Task           string
+	TaskDefinition string
+	Time           time.Time
+}
+
+// CreateServiceEventPipe routes the task state changes of a service to an sqs queue as they happen and returns the arn of the route
+//
+// EventBridge Pipes can only read from queues and streams so the route is an EventBridge rule matching the
+// cluster and task definition family of the service with the queue as its target. The queue policy must allow
+// events.amazonaws.com to send messages.
+func (p *Provider) CreateServiceEventPipe(app, service, targetQueueArn string) (string, error) {
+	s, err := p.serviceEcs(app, service)
+	if err != nil {
+		return "", err
+	}
+
+	td := aws.StringValue(s.TaskDefinition)
+
+	// task definition arns end with :<revision>, matching on the rest covers every revision of the family
+	family := td[0 : strings.LastIndex(td, ":")+1]
+
+	pattern, err := json.Marshal(map[string]interface{}{
+		"source":      []string{"aws.ecs"},
+		"detail-type": []string{"ECS Task State Change"},
+		"detail": map[string]interface{}{
+			"clusterArn":        []string{aws.StringValue(s.ClusterArn)},
+			"taskDefinitionArn": []map[string]string{{"prefix": family}},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	name := serviceEventRuleName(p.Rack, app, service)
+
+	res, err := p.cloudwatchevents().PutRule(&cloudwatchevents.PutRuleInput{
+		Description:  aws.String(fmt.Sprintf("task events for %s/%s", app, service)),
+		EventPattern: aws.String(string(pattern)),
+		Name:         aws.String(name),
+		State:        aws.String(cloudwatchevents.RuleStateEnabled),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	_, err = p.cloudwatchevents().PutTargets(&cloudwatchevents.PutTargetsInput{
+		Rule: aws.String(name),
+		Targets: []*cloudwatchevents.Target{
+			{Arn: aws.String(targetQueueArn), Id: aws.String("queue")},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(res.RuleArn), nil
+}
+
+// StartServiceEventPipe resumes delivery of events on a route created by CreateServiceEventPipe
+func (p *Provider) StartServiceEventPipe(pipeArn string) error {
+	_, err := p.cloudwatchevents().EnableRule(&cloudwatchevents.EnableRuleInput{
+		Name: aws.String(serviceEventRuleFromArn(pipeArn)),
+	})
+
+	return err
+}
+
+// StopServiceEventPipe pauses delivery of events on a route created by CreateServiceEventPipe
+func (p *Provider) StopServiceEventPipe(pipeArn string) error {
+	_, err := p.cloudwatchevents().DisableRule(&cloudwatchevents.DisableRuleInput{
+		Name: aws.String(serviceEventRuleFromArn(pipeArn)),
+	})
+
+	return err
+}
+
+// ConsumeServiceEvents sends the task events arriving on an sqs queue to ch until ctx is done
+// Messages are deleted once they have been sent, messages that are not task events are logged and deleted
+func (p *Provider) ConsumeServiceEvents(ctx context.Context, queueUrl string, ch chan<- TaskEvent) error {
+	log := Logger.At("ConsumeServiceEvents").Namespace("queue=%s", queueUrl)
+
+	for {
+		res, err := p.sqs().ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			MaxNumberOfMessages: aws.Int64(10),
+			QueueUrl:            aws.String(queueUrl),
+			WaitTimeSeconds:     aws.Int64(10),
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, m := range res.Messages {
+			e, err := parseTaskEvent(aws.StringValue(m.Body))
+			if err != nil {
+				log.Error(fmt.Errorf("could not parse message %s: %s", aws.StringValue(m.MessageId), err))
+			} else {
+				select {
+				case ch <- *e:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+
+			_, err = p.sqs().DeleteMessage(&sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(queueUrl),
+				ReceiptHandle: m.ReceiptHandle,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func parseTaskEvent(body string) (*TaskEvent, error) {
+	var e struct {
+		DetailType string `json:"detail-type"`
+		Time       time.Time
+		Detail     struct {
+			ClusterArn        string
+			DesiredStatus     string
+			Group             string
+			LastStatus        string
+			StoppedReason     string
+			TaskArn           string
+			TaskDefinitionArn string
+		}
+	}
+
+	if err := json.Unmarshal([]byte(body), &e); err != nil {
+		return nil, err
+	}
+
+	if e.DetailType != "ECS Task State Change" {
+		return nil, fmt.Errorf("unexpected event type: %q", e.DetailType)
+	}
+
+	te := &TaskEvent{
+		Cluster:        e.Detail.ClusterArn,
+		DesiredStatus:  e.Detail.DesiredStatus,
+		Group:          e.Detail.Group,
+		LastStatus:     e.Detail.LastStatus,
+		StoppedReason:  e.Detail.StoppedReason,
+		Task:           e.Detail.TaskArn,
+		TaskDefinition: e.Detail.TaskDefinitionArn,
+		Time:           e.Time,
+	}
+
+	return te, nil
+}
+
+// serviceEventRuleName returns a rule name for the events of a service within the 64 character limit
+func serviceEventRuleName(rack, app, service string) string {
+	name := fmt.Sprintf("%s-%s-%s-events", rack, app, service)
+
+	if len(name) > 64 {
+		name = name[0:64]
+	}
+
+	return name
+}
+
+func serviceEventRuleFromArn(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
@@ -0,0 +1,143 @@
+package aws_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	provider "github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceEventPipe(t *testing.T) {
+	p := ecsServiceTestProvider(t)
+	defer p.Close()
+
+	queue := p.Fake.SQS.AddQueue("events")
+
+	arn, err := p.CreateServiceEventPipe("httpd", "web", p.Fake.SQS.QueueArn(queue))
+	require.NoError(t, err)
+	require.Equal(t, "arn:aws:events:us-test-1:123456789012:rule/convox-httpd-web-events", arn)
+
+	rule := p.Fake.Events.Rule("convox-httpd-web-events")
+	require.NotNil(t, rule)
+	assert.Equal(t, "ENABLED", rule.State)
+	require.Len(t, rule.Targets, 1)
+	assert.Equal(t, "arn:aws:sqs:us-test-1:123456789012:events", aws.StringValue(rule.Targets[0].Arn))
+
+	var pattern map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(rule.EventPattern), &pattern))
+	assert.Equal(t, map[string]interface{}{
+		"source":      []interface{}{"aws.ecs"},
+		"detail-type": []interface{}{"ECS Task State Change"},
+		"detail": map[string]interface{}{
+			"clusterArn":        []interface{}{"arn:aws:ecs:us-test-1:123456789012:cluster/cluster-test"},
+			"taskDefinitionArn": []interface{}{map[string]interface{}{"prefix": "arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-ServiceWeb-service-web:"}},
+		},
+	}, pattern)
+
+	require.NoError(t, p.StopServiceEventPipe(arn))
+	assert.Equal(t, "DISABLED", p.Fake.Events.Rule("convox-httpd-web-events").State)
+
+	require.NoError(t, p.StartServiceEventPipe(arn))
+	assert.Equal(t, "ENABLED", p.Fake.Events.Rule("convox-httpd-web-events").State)
+}
+
+func TestServiceEventPipeUnknownService(t *testing.T) {
+	p := ecsServiceTestProvider(t)
+	defer p.Close()
+
+	_, err := p.CreateServiceEventPipe("httpd", "worker", "arn:aws:sqs:us-test-1:123456789012:events")
+	require.EqualError(t, err, "resource not found: ServiceWorker")
+}
+
+func TestConsumeServiceEvents(t *testing.T) {
+	p := ecsServiceTestProvider(t)
+	defer p.Close()
+
+	queue := p.Fake.SQS.AddQueue("events")
+
+	require.NoError(t, p.Fake.SQS.Send(queue, `{"detail-type":"ECS Task State Change","source":"aws.ecs","time":"2020-01-02T03:04:05Z","detail":{"clusterArn":"arn:aws:ecs:us-test-1:123456789012:cluster/cluster-test","desiredStatus":"RUNNING","group":"service:convox-httpd-ServiceWeb","lastStatus":"PENDING","taskArn":"arn:aws:ecs:us-test-1:123456789012:task/cluster-test/1234","taskDefinitionArn":"arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-ServiceWeb-service-web:3"}}`))
+	require.NoError(t, p.Fake.SQS.Send(queue, `{"detail-type":"ECS Service Action","source":"aws.ecs"}`))
+	require.NoError(t, p.Fake.SQS.Send(queue, `{"detail-type":"ECS Task State Change","source":"aws.ecs","time":"2020-01-02T03:05:00Z","detail":{"clusterArn":"arn:aws:ecs:us-test-1:123456789012:cluster/cluster-test","desiredStatus":"STOPPED","group":"service:convox-httpd-ServiceWeb","lastStatus":"STOPPED","stoppedReason":"Essential container in task exited","taskArn":"arn:aws:ecs:us-test-1:123456789012:task/cluster-test/1234","taskDefinitionArn":"arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-ServiceWeb-service-web:3"}}`))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan provider.TaskEvent)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- p.ConsumeServiceEvents(ctx, queue, ch)
+	}()
+
+	events := []provider.TaskEvent{}
+
+	for len(events) < 2 {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		case err := <-done:
+			t.Fatalf("consumer stopped: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for events")
+		}
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []provider.TaskEvent{
+		{
+			Cluster:        "arn:aws:ecs:us-test-1:123456789012:cluster/cluster-test",
+			DesiredStatus:  "RUNNING",
+			Group:          "service:convox-httpd-ServiceWeb",
+			LastStatus:     "PENDING",
+			Task:           "arn:aws:ecs:us-test-1:123456789012:task/cluster-test/1234",
+			TaskDefinition: "arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-ServiceWeb-service-web:3",
+			Time:           time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			Cluster:        "arn:aws:ecs:us-test-1:123456789012:cluster/cluster-test",
+			DesiredStatus:  "STOPPED",
+			Group:          "service:convox-httpd-ServiceWeb",
+			LastStatus:     "STOPPED",
+			StoppedReason:  "Essential container in task exited",
+			Task:           "arn:aws:ecs:us-test-1:123456789012:task/cluster-test/1234",
+			TaskDefinition: "arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-ServiceWeb-service-web:3",
+			Time:           time.Date(2020, 1, 2, 3, 5, 0, 0, time.UTC),
+		},
+	}, events)
+
+	// the event that was not a task state change is dropped rather than redelivered
+	assert.Equal(t, 0, p.Fake.SQS.Pending(queue))
+}
+
+// ecsServiceTestProvider seeds an app with a web service whose ecs service runs in the rack cluster
+func ecsServiceTestProvider(t *testing.T) *awsfake.TestProvider {
+	p := awsfake.NewTestProvider()
+
+	service := p.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name:      "convox-httpd-ServiceWeb",
+		Resources: []awsfake.Resource{{LogicalId: "Service", PhysicalId: "arn:aws:ecs:us-test-1:123456789012:service/cluster-test/convox-httpd-ServiceWeb"}},
+	})
+
+	p.AddApp("httpd", "2", awsfake.Stack{
+		Resources: []awsfake.Resource{{LogicalId: "ServiceWeb", PhysicalId: service}},
+	})
+
+	require.NoError(t, p.Fake.ECS.AddService("cluster-test", &ecs.Service{
+		ServiceArn:  aws.String("arn:aws:ecs:us-test-1:123456789012:service/cluster-test/convox-httpd-ServiceWeb"),
+		ServiceName: aws.String("convox-httpd-ServiceWeb"),
+		DeploymentConfiguration: &ecs.DeploymentConfiguration{
+			MaximumPercent:        aws.Int64(200),
+			MinimumHealthyPercent: aws.Int64(50),
+		},
+		TaskDefinition: aws.String("arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-ServiceWeb-service-web:3"),
+	}))
+
+	return p
+}
@@ -0,0 +1,268 @@
+package aws_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/convox/rack/pkg/structs"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const serviceScaleManifest = `services:
+  web-api:
+    image: httpd
+    scale:
+      count: 2-6
+  worker:
+    image: httpd
+`
+
+func TestServiceScale(t *testing.T) {
+	provider := serviceScaleTestProvider()
+	defer provider.Close()
+
+	require.NoError(t, provider.ServiceScale("httpd", "web-api", aws.ServiceScaleOptions{Count: awssdk.Int64(4), Memory: awssdk.Int64(1024)}))
+
+	s, ok := provider.Fake.CloudFormation.Stack("convox-httpd")
+	require.True(t, ok)
+	assert.Equal(t, "4,256,1024", s.Parameters["WebApiFormation"])
+	assert.Equal(t, "1,256,512", s.Parameters["WorkerFormation"])
+
+	entries, err := provider.AuditLog("httpd", time.Time{}, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "service.scale", entries[0].Op)
+	assert.Equal(t, map[string]string{"service": "web-api", "formation": "4,256,1024"}, entries[0].Detail)
+}
+
+func TestServiceScaleUnchanged(t *testing.T) {
+	provider := serviceScaleTestProvider()
+	defer provider.Close()
+
+	require.NoError(t, provider.ServiceScale("httpd", "worker", aws.ServiceScaleOptions{Count: awssdk.Int64(1), Cpu: awssdk.Int64(256)}))
+
+	s, ok := provider.Fake.CloudFormation.Stack("convox-httpd")
+	require.True(t, ok)
+	assert.Equal(t, "CREATE_COMPLETE", s.Status)
+
+	entries, err := provider.AuditLog("httpd", time.Time{}, 10)
+	require.NoError(t, err)
+	assert.Len(t, entries, 0)
+}
+
+func TestServiceScaleBounds(t *testing.T) {
+	provider := serviceScaleTestProvider()
+	defer provider.Close()
+
+	err := provider.ServiceScale("httpd", "web-api", aws.ServiceScaleOptions{Count: awssdk.Int64(8)})
+	require.EqualError(t, err, "service web-api count 8 out of range, must be between 2 and 6")
+	assert.Equal(t, aws.ServiceScaleError{Service: "web-api", Field: "count", Value: 8, Min: 2, Max: 6}, err)
+
+	err = provider.ServiceScale("httpd", "web-api", aws.ServiceScaleOptions{Count: awssdk.Int64(1)})
+	require.EqualError(t, err, "service web-api count 1 out of range, must be between 2 and 6")
+
+	err = provider.ServiceScale("httpd", "worker", aws.ServiceScaleOptions{Memory: awssdk.Int64(8192)})
+	require.EqualError(t, err, "service worker memory 8192 out of range, must be between 1 and 4096")
+
+	err = provider.ServiceScale("httpd", "worker", aws.ServiceScaleOptions{Cpu: awssdk.Int64(0)})
+	require.EqualError(t, err, "service worker cpu 0 out of range, must be between 1 and 2147483647")
+
+	s, ok := provider.Fake.CloudFormation.Stack("convox-httpd")
+	require.True(t, ok)
+	assert.Equal(t, "2,256,512", s.Parameters["WebApiFormation"])
+
+	// services that do not autoscale take any count
+	require.NoError(t, provider.ServiceScale("httpd", "worker", aws.ServiceScaleOptions{Count: awssdk.Int64(8)}))
+}
+
+func TestServiceScaleUnknownService(t *testing.T) {
+	provider := serviceScaleTestProvider()
+	defer provider.Close()
+
+	err := provider.ServiceScale("httpd", "web", aws.ServiceScaleOptions{Count: awssdk.Int64(2)})
+	require.EqualError(t, err, "service not found: web")
+	assert.Equal(t, 404, err.(aws.ServiceScaleError).Code())
+}
+
+func serviceScaleTestProvider() *awsfake.TestProvider {
+	provider := awsfake.NewTestProvider()
+	provider.Version = "20200101000000"
+
+	provider.AddRack(awsfake.Stack{
+		Parameters: map[string]string{"InstanceType": "c5.large"},
+		Resources:  []awsfake.Resource{{LogicalId: "EncryptionKey", PhysicalId: ""}},
+	})
+
+	provider.AddApp("httpd", "2", awsfake.Stack{
+		Outputs:    map[string]string{"Release": "R1"},
+		Parameters: map[string]string{"WebApiFormation": "2,256,512", "WorkerFormation": "1,256,512"},
+		Resources:  []awsfake.Resource{{LogicalId: "Settings", PhysicalId: "convox-httpd-settings"}},
+	})
+
+	provider.Fake.S3.PutObject("convox-httpd-settings", "releases/R1/env", []byte(""), nil)
+
+	provider.Fake.DynamoDB.PutItem("convox-releases", map[string]*dynamodb.AttributeValue{
+		"id":       {S: awssdk.String("R1")},
+		"app":      {S: awssdk.String("httpd")},
+		"created":  {S: awssdk.String("20200101.120000.000000000")},
+		"manifest": {S: awssdk.String(serviceScaleManifest)},
+	})
+
+	return provider
+}
+
+func TestServiceCapacity(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	worker := provider.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name:      "convox-httpd-ServiceWorker",
+		Resources: []awsfake.Resource{{LogicalId: "Service", PhysicalId: serviceCapacityTestService(t, provider, "worker", 3, 3, 0), Type: "AWS::ECS::Service"}},
+	})
+
+	provider.AddApp("httpd", "2", awsfake.Stack{
+		Resources: []awsfake.Resource{
+			{LogicalId: "ServiceWebApi", PhysicalId: serviceCapacityTestService(t, provider, "web-api", 4, 1, 2), Type: "AWS::ECS::Service"},
+			{LogicalId: "ServiceWorker", PhysicalId: worker, Type: "AWS::CloudFormation::Stack"},
+			{LogicalId: "ServiceCron", PhysicalId: serviceCapacityTestService(t, provider, "cron", 0, 1, 0), Type: "AWS::ECS::Service"},
+			{LogicalId: "Settings", PhysicalId: "convox-httpd-settings", Type: "AWS::S3::Bucket"},
+		},
+	})
+
+	cs, err := provider.ServiceCapacity("httpd")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]structs.ServiceCapacity{
+		"cron":    {Desired: 0, Pending: 0, Running: 1},
+		"web-api": {Desired: 4, Pending: 2, Running: 1},
+		"worker":  {Desired: 3, Pending: 0, Running: 3},
+	}, cs)
+
+	assert.Equal(t, -1, cs["cron"].Missing())
+	assert.Equal(t, 3, cs["web-api"].Missing())
+	assert.Equal(t, 0, cs["worker"].Missing())
+}
+
+func TestServiceCapacityBatches(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	rs := []awsfake.Resource{}
+
+	for i := 0; i < 12; i++ {
+		name := fmt.Sprintf("service%d", i)
+		rs = append(rs, awsfake.Resource{LogicalId: fmt.Sprintf("ServiceService%d", i), PhysicalId: serviceCapacityTestService(t, provider, name, int64(i), 0, 0), Type: "AWS::ECS::Service"})
+	}
+
+	provider.AddApp("httpd", "2", awsfake.Stack{Resources: rs})
+
+	cs, err := provider.ServiceCapacity("httpd")
+	require.NoError(t, err)
+
+	require.Len(t, cs, 12)
+	assert.Equal(t, 11, cs["service11"].Desired)
+}
+
+func TestServiceCapacityDeletedService(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	// the stack still lists cron but its ecs service was deleted after the resources were read
+	provider.AddApp("httpd", "2", awsfake.Stack{
+		Resources: []awsfake.Resource{
+			{LogicalId: "ServiceCron", PhysicalId: "arn:aws:ecs:us-test-1:123456789012:service/cluster-test/convox-httpd-cron", Type: "AWS::ECS::Service"},
+			{LogicalId: "ServiceWeb", PhysicalId: serviceCapacityTestService(t, provider, "web", 2, 2, 0), Type: "AWS::ECS::Service"},
+			{LogicalId: "ServiceWorker", PhysicalId: serviceCapacityTestService(t, provider, "worker", 1, 0, 1), Type: "AWS::ECS::Service"},
+		},
+	})
+
+	cs, err := provider.ServiceCapacity("httpd")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]structs.ServiceCapacity{
+		"web":    {Desired: 2, Pending: 0, Running: 2},
+		"worker": {Desired: 1, Pending: 1, Running: 0},
+	}, cs)
+}
+
+func TestDescribeServicesChecked(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	// found services are cached, the cache is keyed by arn so the names are unique to this test
+	provider.SkipCache = false
+
+	web := serviceCapacityTestService(t, provider, "checked-web", 1, 1, 0)
+	worker := serviceCapacityTestService(t, provider, "checked-worker", 1, 1, 0)
+	gone := "arn:aws:ecs:us-test-1:123456789012:service/cluster-test/convox-httpd-checked-gone"
+
+	ss, failures, err := provider.DescribeServicesChecked(&ecs.DescribeServicesInput{
+		Cluster:  awssdk.String("cluster-test"),
+		Services: awssdk.StringSlice([]string{web, gone, worker}),
+	})
+	require.NoError(t, err)
+
+	require.Len(t, ss, 2)
+	assert.Equal(t, web, awssdk.StringValue(ss[0].ServiceArn))
+	assert.Equal(t, worker, awssdk.StringValue(ss[1].ServiceArn))
+
+	require.Len(t, failures, 1)
+	assert.Equal(t, gone, awssdk.StringValue(failures[0].Arn))
+	assert.Equal(t, "MISSING", awssdk.StringValue(failures[0].Reason))
+
+	// the failure was not cached so the service is found once it exists
+	serviceCapacityTestService(t, provider, "checked-gone", 1, 0, 0)
+
+	ss, failures, err = provider.DescribeServicesChecked(&ecs.DescribeServicesInput{
+		Cluster:  awssdk.String("cluster-test"),
+		Services: awssdk.StringSlice([]string{web, gone, worker}),
+	})
+	require.NoError(t, err)
+
+	assert.Len(t, ss, 3)
+	assert.Len(t, failures, 0)
+}
+
+func TestDescribeServicesCheckedInactive(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	arn := "arn:aws:ecs:us-test-1:123456789012:service/cluster-test/convox-httpd-draining"
+
+	require.NoError(t, provider.Fake.ECS.AddService("cluster-test", &ecs.Service{
+		ServiceArn:  awssdk.String(arn),
+		ServiceName: awssdk.String("convox-httpd-draining"),
+		Status:      awssdk.String("INACTIVE"),
+	}))
+
+	ss, failures, err := provider.DescribeServicesChecked(&ecs.DescribeServicesInput{
+		Cluster:  awssdk.String("cluster-test"),
+		Services: awssdk.StringSlice([]string{arn}),
+	})
+	require.NoError(t, err)
+
+	assert.Len(t, ss, 0)
+	require.Len(t, failures, 1)
+	assert.Equal(t, "INACTIVE", awssdk.StringValue(failures[0].Reason))
+}
+
+func serviceCapacityTestService(t *testing.T, provider *awsfake.TestProvider, name string, desired, running, pending int64) string {
+	arn := fmt.Sprintf("arn:aws:ecs:us-test-1:123456789012:service/cluster-test/convox-httpd-%s", name)
+
+	require.NoError(t, provider.Fake.ECS.AddService("cluster-test", &ecs.Service{
+		DesiredCount: awssdk.Int64(desired),
+		PendingCount: awssdk.Int64(pending),
+		RunningCount: awssdk.Int64(running),
+		ServiceArn:   awssdk.String(arn),
+		ServiceName:  awssdk.String(fmt.Sprintf("convox-httpd-%s", name)),
+	}))
+
+	return arn
+}
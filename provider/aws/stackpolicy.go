@@ -0,0 +1,101 @@
+package aws
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+const protectDatabasesSid = "ProtectDatabases"
+
+// protectedResourceTypes are the resource types ProtectDatabases keeps from being replaced
+var protectedResourceTypes = []string{"AWS::RDS::DBInstance", "AWS::DynamoDB::Table"}
+
+// GetStackPolicy returns the parsed stack policy for a stack or an empty policy if none is set
+func (p *Provider) GetStackPolicy(name string) (map[string]interface{}, error) {
+	res, err := p.cloudformation().GetStackPolicy(&cloudformation.GetStackPolicyInput{
+		StackName: aws.String(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	policy := map[string]interface{}{}
+
+	if body := cs(res.StackPolicyBody, ""); body != "" {
+		if err := json.Unmarshal([]byte(body), &policy); err != nil {
+			return nil, err
+		}
+	}
+
+	return policy, nil
+}
+
+// SetStackPolicy replaces the stack policy for a stack
+func (p *Provider) SetStackPolicy(name string, policy map[string]interface{}) error {
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.cloudformation().SetStackPolicy(&cloudformation.SetStackPolicyInput{
+		StackName:       aws.String(name),
+		StackPolicyBody: aws.String(string(data)),
+	})
+
+	return err
+}
+
+// ProtectDatabases adds a statement to the stack policy that denies updates replacing database resources
+// existing statements are kept and a stack without a policy gets an allow statement for all other updates
+func (p *Provider) ProtectDatabases(name string) error {
+	log := Logger.At("ProtectDatabases").Namespace("name=%q", name).Start()
+
+	policy, err := p.GetStackPolicy(name)
+	if err != nil {
+		return log.Error(err)
+	}
+
+	statements := []interface{}{}
+
+	if existing, ok := policy["Statement"].([]interface{}); ok {
+		for _, s := range existing {
+			if sm, ok := s.(map[string]interface{}); ok && sm["Sid"] == protectDatabasesSid {
+				continue
+			}
+
+			statements = append(statements, s)
+		}
+	}
+
+	if len(statements) == 0 {
+		statements = append(statements, map[string]interface{}{
+			"Effect":    "Allow",
+			"Action":    "Update:*",
+			"Principal": "*",
+			"Resource":  "*",
+		})
+	}
+
+	statements = append(statements, map[string]interface{}{
+		"Sid":       protectDatabasesSid,
+		"Effect":    "Deny",
+		"Action":    "Update:Replace",
+		"Principal": "*",
+		"Resource":  "*",
+		"Condition": map[string]interface{}{
+			"StringEquals": map[string]interface{}{
+				"ResourceType": protectedResourceTypes,
+			},
+		},
+	})
+
+	policy["Statement"] = statements
+
+	if err := p.SetStackPolicy(name, policy); err != nil {
+		return log.Error(err)
+	}
+
+	return log.Success()
+}
@@ -0,0 +1,123 @@
+package aws_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStackPolicy(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleStackPolicyGetStackPolicy(`{"Statement":[{"Effect":"Allow","Action":"Update:*","Principal":"*","Resource":"*"}]}`),
+		cycleStackPolicyGetStackPolicy(""),
+	)
+	defer provider.Close()
+
+	policy, err := provider.GetStackPolicy("convox-httpd")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Statement": []interface{}{
+			map[string]interface{}{"Effect": "Allow", "Action": "Update:*", "Principal": "*", "Resource": "*"},
+		},
+	}, policy)
+
+	policy, err = provider.GetStackPolicy("convox-httpd")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{}, policy)
+}
+
+func TestSetStackPolicy(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleStackPolicySetStackPolicy(`{"Statement":[{"Action":"Update:*","Effect":"Allow","Principal":"*","Resource":"*"}]}`),
+	)
+	defer provider.Close()
+
+	err := provider.SetStackPolicy("convox-httpd", map[string]interface{}{
+		"Statement": []interface{}{
+			map[string]interface{}{"Effect": "Allow", "Action": "Update:*", "Principal": "*", "Resource": "*"},
+		},
+	})
+	require.NoError(t, err)
+}
+
+func TestProtectDatabases(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleStackPolicyGetStackPolicy(""),
+		cycleStackPolicySetStackPolicy(`{"Statement":[`+
+			`{"Action":"Update:*","Effect":"Allow","Principal":"*","Resource":"*"},`+
+			`{"Action":"Update:Replace","Condition":{"StringEquals":{"ResourceType":["AWS::RDS::DBInstance","AWS::DynamoDB::Table"]}},"Effect":"Deny","Principal":"*","Resource":"*","Sid":"ProtectDatabases"}`+
+			`]}`),
+	)
+	defer provider.Close()
+
+	err := provider.ProtectDatabases("convox-httpd")
+	require.NoError(t, err)
+}
+
+func TestProtectDatabasesExistingPolicy(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleStackPolicyGetStackPolicy(`{"Statement":[`+
+			`{"Effect":"Allow","Action":"Update:*","Principal":"*","Resource":"*"},`+
+			`{"Effect":"Deny","Action":"Update:*","Principal":"*","Resource":"LogicalResourceId/Settings"},`+
+			`{"Sid":"ProtectDatabases","Effect":"Deny","Action":"Update:Replace","Principal":"*","Resource":"*"}`+
+			`]}`),
+		cycleStackPolicySetStackPolicy(`{"Statement":[`+
+			`{"Action":"Update:*","Effect":"Allow","Principal":"*","Resource":"*"},`+
+			`{"Action":"Update:*","Effect":"Deny","Principal":"*","Resource":"LogicalResourceId/Settings"},`+
+			`{"Action":"Update:Replace","Condition":{"StringEquals":{"ResourceType":["AWS::RDS::DBInstance","AWS::DynamoDB::Table"]}},"Effect":"Deny","Principal":"*","Resource":"*","Sid":"ProtectDatabases"}`+
+			`]}`),
+	)
+	defer provider.Close()
+
+	err := provider.ProtectDatabases("convox-httpd")
+	require.NoError(t, err)
+}
+
+func cycleStackPolicyGetStackPolicy(body string) awsutil.Cycle {
+	result := ""
+
+	if body != "" {
+		result = "<StackPolicyBody>" + body + "</StackPolicyBody>"
+	}
+
+	return awsutil.Cycle{
+		Request: awsutil.Request{
+			RequestURI: "/",
+			Operation:  "",
+			Body:       `Action=GetStackPolicy&StackName=convox-httpd&Version=2010-05-15`,
+		},
+		Response: awsutil.Response{
+			StatusCode: 200,
+			Body: `
+				<GetStackPolicyResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+					<GetStackPolicyResult>` + result + `</GetStackPolicyResult>
+				</GetStackPolicyResponse>
+			`,
+		},
+	}
+}
+
+func cycleStackPolicySetStackPolicy(policy string) awsutil.Cycle {
+	return awsutil.Cycle{
+		Request: awsutil.Request{
+			RequestURI: "/",
+			Operation:  "",
+			Body: url.Values{
+				"Action":          {"SetStackPolicy"},
+				"StackName":       {"convox-httpd"},
+				"StackPolicyBody": {policy},
+				"Version":         {"2010-05-15"},
+			}.Encode(),
+		},
+		Response: awsutil.Response{
+			StatusCode: 200,
+			Body: `
+				<SetStackPolicyResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+				</SetStackPolicyResponse>
+			`,
+		},
+	}
+}
@@ -15,7 +15,6 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
@@ -120,11 +119,11 @@ func (p *Provider) SystemGet() (*structs.System, error) {
 	stacks, err := p.describeStacks(&cloudformation.DescribeStacksInput{
 		StackName: aws.String(p.Rack),
 	})
-	if ae, ok := err.(awserr.Error); ok && ae.Code() == "ValidationError" {
+	if isNotFound(err) {
 		return nil, log.Error(errorNotFound(fmt.Sprintf("%s not found", p.Rack)))
 	}
 	if err != nil {
-		return nil, log.Error(err)
+		return nil, logAWSError(log, err)
 	}
 	if len(stacks) != 1 {
 		return nil, log.Errorf("could not load stack for app: %s", p.Rack)
@@ -226,7 +225,10 @@ func (p *Provider) SystemInstall(w io.Writer, opts structs.SystemInstallOptions)
 
 	template := fmt.Sprintf("https://convox.s3.amazonaws.com/release/%s/rack.json", version)
 
-	password := randomString(30)
+	password, err := securePassword(30)
+	if err != nil {
+		return "", err
+	}
 
 	params := map[string]string{
 		"Password": password,
@@ -258,7 +260,7 @@ func (p *Provider) SystemInstall(w io.Writer, opts structs.SystemInstallOptions)
 		fmt.Fprintf(w, "Preparing... ")
 	}
 
-	err := helpers.CloudformationInstall(cf, name, template, params, tags, func(current, total int) {
+	err = helpers.CloudformationInstall(cf, name, template, params, tags, func(current, total int) {
 		if raw {
 			fmt.Fprintf(w, "{ \"stack\": %q, \"current\": %d, \"total\": %d }\n", name, current, total)
 			return
@@ -556,6 +558,18 @@ func (p *Provider) SystemUpdate(opts structs.SystemUpdateOptions) error {
 		return err
 	}
 
+	detail := map[string]string{}
+
+	if len(opts.Parameters) > 0 {
+		detail["params"] = auditParams(opts.Parameters)
+	}
+
+	for k, v := range changes {
+		detail[k] = v
+	}
+
+	p.auditRecord("system.update", "system", p.actor(), detail)
+
 	// notify about the update
 	p.EventSend("rack:update", structs.EventSendOptions{Data: changes})
 
@@ -625,7 +639,7 @@ func cloudformationProgress(stack, token string, template []byte, w io.Writer) e
 		dres, err := cf.DescribeStacks(&cloudformation.DescribeStacksInput{
 			StackName: aws.String(stack),
 		})
-		if awsError(err) == "ValidationError" {
+		if isNotFound(err) {
 			return nil // stack is gone
 		}
 		if err != nil {
@@ -0,0 +1,216 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// ListInactiveTaskDefinitions returns the task definition families of the rack with no running tasks
+// families owned by the rack or app stacks are left out even while nothing runs, builds start from the rack's
+// build task definition and timers start their tasks from theirs on schedule
+func (p *Provider) ListInactiveTaskDefinitions() ([]string, error) {
+	log := Logger.At("ListInactiveTaskDefinitions").Start()
+
+	families := []string{}
+
+	err := p.ecs().ListTaskDefinitionFamiliesPages(&ecs.ListTaskDefinitionFamiliesInput{
+		FamilyPrefix: aws.String(p.Rack),
+		Status:       aws.String(ecs.TaskDefinitionFamilyStatusActive),
+	}, func(page *ecs.ListTaskDefinitionFamiliesOutput, last bool) bool {
+		families = append(families, aws.StringValueSlice(page.Families)...)
+		return true
+	})
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	used, err := p.taskDefinitionFamiliesInUse()
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	owned, err := p.stackTaskDefinitionFamilies()
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	inactive := []string{}
+
+	for _, f := range families {
+		// families of other racks can share this rack name as a prefix
+		if !strings.HasPrefix(f, p.Rack+"-") {
+			continue
+		}
+
+		if used[f] || owned[f] {
+			continue
+		}
+
+		inactive = append(inactive, f)
+	}
+
+	sort.Strings(inactive)
+
+	return inactive, log.Successf("families=%d inactive=%d", len(families), len(inactive))
+}
+
+// DeactivateOrphanedTaskDefinitions deregisters the latest revision of each family with no running tasks
+// and returns the arns it deregistered, or would deregister when dryRun is set
+func (p *Provider) DeactivateOrphanedTaskDefinitions(dryRun bool) ([]string, error) {
+	log := Logger.At("DeactivateOrphanedTaskDefinitions").Namespace("dryrun=%t", dryRun).Start()
+
+	families, err := p.ListInactiveTaskDefinitions()
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	arns := []string{}
+
+	for _, f := range families {
+		res, err := p.ecs().DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{TaskDefinition: aws.String(f)})
+		if err != nil {
+			return arns, log.Error(fmt.Errorf("could not describe task definition family %s: %s", f, err))
+		}
+
+		arn := aws.StringValue(res.TaskDefinition.TaskDefinitionArn)
+
+		if !dryRun {
+			if _, err := p.ecs().DeregisterTaskDefinition(&ecs.DeregisterTaskDefinitionInput{TaskDefinition: aws.String(arn)}); err != nil {
+				return arns, log.Error(fmt.Errorf("could not deregister task definition %s: %s", arn, err))
+			}
+		}
+
+		arns = append(arns, arn)
+	}
+
+	return arns, log.Successf("deregistered=%d", len(arns))
+}
+
+// taskDefinitionFamiliesInUse returns the families of running tasks and of the task definitions services deploy
+func (p *Provider) taskDefinitionFamiliesInUse() (map[string]bool, error) {
+	arns := map[string]bool{}
+
+	tasks := []string{}
+
+	err := p.ecs().ListTasksPages(&ecs.ListTasksInput{
+		Cluster: aws.String(p.Cluster),
+	}, func(page *ecs.ListTasksOutput, last bool) bool {
+		tasks = append(tasks, aws.StringValueSlice(page.TaskArns)...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// DescribeTasks takes at most 100 tasks at a time
+	for i := 0; i < len(tasks); i += 100 {
+		j := i + 100
+
+		if j > len(tasks) {
+			j = len(tasks)
+		}
+
+		res, err := p.ecs().DescribeTasks(&ecs.DescribeTasksInput{
+			Cluster: aws.String(p.Cluster),
+			Tasks:   aws.StringSlice(tasks[i:j]),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range res.Tasks {
+			arns[aws.StringValue(t.TaskDefinitionArn)] = true
+		}
+	}
+
+	ss, err := p.clusterServices()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range ss {
+		arns[aws.StringValue(s.TaskDefinition)] = true
+
+		for _, d := range s.Deployments {
+			arns[aws.StringValue(d.TaskDefinition)] = true
+		}
+	}
+
+	families := map[string]bool{}
+
+	for arn := range arns {
+		if f := taskDefinitionFamily(arn); f != "" {
+			families[f] = true
+		}
+	}
+
+	return families, nil
+}
+
+// stackTaskDefinitionFamilies returns the families of the task definitions declared by the rack stack
+// and the app stacks of the rack, including the service and timer stacks nested in them
+func (p *Provider) stackTaskDefinitionFamilies() (map[string]bool, error) {
+	stacks, err := p.describeStacks(&cloudformation.DescribeStacksInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	families := map[string]bool{}
+
+	for _, s := range stacks {
+		if s.ParentId != nil {
+			continue
+		}
+
+		tags := stackTags(s)
+
+		if aws.StringValue(s.StackName) != p.Rack && !(tags["System"] == "convox" && tags["Type"] == "app" && tags["Rack"] == p.Rack) {
+			continue
+		}
+
+		if err := p.stackTaskDefinitionFamiliesRecursive(aws.StringValue(s.StackId), families); err != nil {
+			return nil, err
+		}
+	}
+
+	return families, nil
+}
+
+func (p *Provider) stackTaskDefinitionFamiliesRecursive(stack string, families map[string]bool) error {
+	srs, err := p.listStackResources(stack)
+	if err != nil {
+		return err
+	}
+
+	for _, sr := range srs {
+		switch aws.StringValue(sr.ResourceType) {
+		case "AWS::CloudFormation::Stack":
+			if id := aws.StringValue(sr.PhysicalResourceId); id != "" {
+				if err := p.stackTaskDefinitionFamiliesRecursive(id, families); err != nil {
+					return err
+				}
+			}
+		case "AWS::ECS::TaskDefinition":
+			if f := taskDefinitionFamily(aws.StringValue(sr.PhysicalResourceId)); f != "" {
+				families[f] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// taskDefinitionFamily returns the family of a task definition arn
+func taskDefinitionFamily(arn string) string {
+	parts := strings.SplitN(arn, "task-definition/", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+
+	return strings.SplitN(parts[1], ":", 2)[0]
+}
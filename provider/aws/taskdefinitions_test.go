@@ -0,0 +1,119 @@
+package aws_test
+
+import (
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListInactiveTaskDefinitions(t *testing.T) {
+	provider := taskDefinitionsTestProvider(t)
+	defer provider.Close()
+
+	families, err := provider.ListInactiveTaskDefinitions()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"convox-httpd-old", "convox-httpd-web"}, families)
+}
+
+func TestDeactivateOrphanedTaskDefinitions(t *testing.T) {
+	provider := taskDefinitionsTestProvider(t)
+	defer provider.Close()
+
+	arns, err := provider.DeactivateOrphanedTaskDefinitions(true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-old:1",
+		"arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-web:3",
+	}, arns)
+
+	families, err := provider.ListInactiveTaskDefinitions()
+	require.NoError(t, err)
+	assert.Len(t, families, 2)
+
+	arns, err = provider.DeactivateOrphanedTaskDefinitions(false)
+	require.NoError(t, err)
+	assert.Len(t, arns, 2)
+
+	// the only revision of old is gone while web still has an earlier one
+	families, err = provider.ListInactiveTaskDefinitions()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"convox-httpd-web"}, families)
+
+	arns, err = provider.DeactivateOrphanedTaskDefinitions(false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-web:2"}, arns)
+}
+
+func taskDefinitionsTestProvider(t *testing.T) *awsfake.TestProvider {
+	provider := awsfake.NewTestProvider()
+
+	for _, td := range []struct {
+		family   string
+		revision int64
+	}{
+		{"convox-build", 5},
+		{"convox-httpd-ServiceWeb-1A2B3C4D5E6F-service-web", 1},
+		{"convox-httpd-ServiceWorker-3N4P5Q6R7S8T-service-worker", 4},
+		{"convox-httpd-TimerDaily-1F7G8H9J0KLM-timer-daily", 2},
+		{"convox-httpd-old", 1},
+		{"convox-httpd-run", 6},
+		{"convox-httpd-web", 2},
+		{"convox-httpd-web", 3},
+		{"convox2-other-web", 1},
+		{"staging-other-web", 1},
+	} {
+		provider.Fake.ECS.AddTaskDefinition(&ecs.TaskDefinition{
+			Family:   awssdk.String(td.family),
+			Revision: awssdk.Int64(td.revision),
+			Status:   awssdk.String(ecs.TaskDefinitionStatusActive),
+		})
+	}
+
+	// a service scaled to zero still deploys its definition
+	require.NoError(t, provider.Fake.ECS.AddService("cluster-test", &ecs.Service{
+		DesiredCount:   awssdk.Int64(0),
+		ServiceName:    awssdk.String("convox-httpd-ServiceWorker-1"),
+		TaskDefinition: awssdk.String("arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-ServiceWorker-3N4P5Q6R7S8T-service-worker:4"),
+	}))
+
+	require.NoError(t, provider.Fake.ECS.AddService("cluster-test", &ecs.Service{
+		DesiredCount:   awssdk.Int64(1),
+		ServiceName:    awssdk.String("convox-httpd-ServiceWeb-1"),
+		TaskDefinition: awssdk.String("arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-ServiceWeb-1A2B3C4D5E6F-service-web:1"),
+	}))
+
+	// the build and timer definitions run nothing between builds and schedules but belong to the rack and app stacks
+	provider.AddRack(awsfake.Stack{
+		Resources: []awsfake.Resource{
+			{LogicalId: "ApiBuildTasks", PhysicalId: "arn:aws:ecs:us-test-1:123456789012:task-definition/convox-build:5", Type: "AWS::ECS::TaskDefinition"},
+		},
+	})
+
+	timer := provider.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name: "convox-httpd-TimerDaily-1F7G8H9J0KLM",
+		Resources: []awsfake.Resource{
+			{LogicalId: "TaskDefinition", PhysicalId: "arn:aws:ecs:us-test-1:123456789012:task-definition/convox-httpd-TimerDaily-1F7G8H9J0KLM-timer-daily:2", Type: "AWS::ECS::TaskDefinition"},
+		},
+	})
+
+	provider.AddApp("httpd", "1", awsfake.Stack{
+		Resources: []awsfake.Resource{
+			{LogicalId: "TimerDaily", PhysicalId: timer, Type: "AWS::CloudFormation::Stack"},
+		},
+	})
+
+	for _, td := range []string{"convox-httpd-ServiceWeb-1A2B3C4D5E6F-service-web:1", "convox-httpd-run:6"} {
+		require.NoError(t, provider.Fake.ECS.AddTask("cluster-test", &ecs.Task{
+			DesiredStatus:     awssdk.String("RUNNING"),
+			LastStatus:        awssdk.String("RUNNING"),
+			TaskDefinitionArn: awssdk.String("arn:aws:ecs:us-test-1:123456789012:task-definition/" + td),
+		}))
+	}
+
+	return provider
+}
@@ -0,0 +1,289 @@
+package aws
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+const (
+	// telemetryDocument is the document ssm registers on every account for running shell commands
+	telemetryDocument = "AWS-RunShellScript"
+
+	telemetrySourceECS = "ecs"
+	telemetrySourceSSM = "ssm"
+)
+
+var (
+	telemetryPollInterval = 1 * time.Second
+	telemetryTimeout      = 30 * time.Second
+)
+
+// telemetryCommands print each source of telemetry after a marker line so the output can be split apart
+var telemetryCommands = []string{
+	"echo '--- meminfo'", "cat /proc/meminfo",
+	"echo '--- vmstat'", "vmstat 1 1",
+	"echo '--- loadavg'", "cat /proc/loadavg",
+}
+
+// InstanceTelemetry is a point in time reading of the memory and cpu of an instance
+// readings from ecs are reservations rather than usage and have no load average
+type InstanceTelemetry struct {
+	Source         string  `json:"source"`
+	TotalMemoryMB  int64   `json:"total-memory-mb"`
+	FreeMemoryMB   int64   `json:"free-memory-mb"`
+	CPUIdlePercent float64 `json:"cpu-idle-percent"`
+	LoadAvg1       float64 `json:"load-avg-1"`
+}
+
+// GetInstanceTelemetry reads the memory, cpu idle and load of an instance through ssm
+// instances without a connected ssm agent report the resources ecs has left unreserved on them instead
+func (p *Provider) GetInstanceTelemetry(ec2InstanceId string) (*InstanceTelemetry, error) {
+	log := Logger.At("GetInstanceTelemetry").Namespace("instance=%q", ec2InstanceId).Start()
+
+	managed, err := p.ssmManaged(ec2InstanceId)
+	if err != nil {
+		log.Logf("ssm=unavailable error=%q", err)
+	}
+
+	if !managed {
+		t, err := p.ecsInstanceTelemetry(ec2InstanceId)
+		if err != nil {
+			return nil, log.Error(err)
+		}
+
+		return t, log.Successf("source=%s", t.Source)
+	}
+
+	out, err := p.ssmRun(ec2InstanceId, telemetryCommands)
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	t, err := parseInstanceTelemetry(out)
+	if err != nil {
+		return nil, log.Error(err)
+	}
+
+	return t, log.Successf("source=%s", t.Source)
+}
+
+// ssmManaged returns true if the ssm agent on an instance is connected
+func (p *Provider) ssmManaged(ec2InstanceId string) (bool, error) {
+	res, err := p.ssm().DescribeInstanceInformation(&ssm.DescribeInstanceInformationInput{
+		Filters: []*ssm.InstanceInformationStringFilter{
+			{Key: aws.String("InstanceIds"), Values: []*string{aws.String(ec2InstanceId)}},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, i := range res.InstanceInformationList {
+		if aws.StringValue(i.InstanceId) == ec2InstanceId && aws.StringValue(i.PingStatus) == "Online" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ssmRun runs shell commands on an instance and waits for their output
+func (p *Provider) ssmRun(ec2InstanceId string, commands []string) (string, error) {
+	sres, err := p.ssm().SendCommand(&ssm.SendCommandInput{
+		DocumentName:   aws.String(telemetryDocument),
+		InstanceIds:    []*string{aws.String(ec2InstanceId)},
+		Parameters:     map[string][]*string{"commands": aws.StringSlice(commands)},
+		TimeoutSeconds: aws.Int64(int64(telemetryTimeout.Seconds())),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if sres.Command == nil || sres.Command.CommandId == nil {
+		return "", fmt.Errorf("no command id for instance: %s", ec2InstanceId)
+	}
+
+	req := &ssm.GetCommandInvocationInput{
+		CommandId:  sres.Command.CommandId,
+		InstanceId: aws.String(ec2InstanceId),
+	}
+
+	deadline := time.Now().Add(telemetryTimeout)
+
+	for {
+		res, err := p.ssm().GetCommandInvocation(req)
+
+		// invocations can take a moment to show up after the command is sent
+		if err != nil && awsError(err) != "InvocationDoesNotExist" {
+			return "", err
+		}
+
+		if err == nil {
+			switch status := aws.StringValue(res.Status); status {
+			case "Success":
+				return aws.StringValue(res.StandardOutputContent), nil
+			case "Cancelled", "Failed", "TimedOut":
+				return "", fmt.Errorf("command %s on instance %s: %s", status, ec2InstanceId, strings.TrimSpace(aws.StringValue(res.StandardErrorContent)))
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("timeout waiting for command on instance: %s", ec2InstanceId)
+		}
+
+		time.Sleep(telemetryPollInterval)
+	}
+}
+
+// ecsInstanceTelemetry reports the registered and remaining resources of the container instance on an ec2 instance
+func (p *Provider) ecsInstanceTelemetry(ec2InstanceId string) (*InstanceTelemetry, error) {
+	cis, err := p.listAndDescribeContainerInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ci := range cis.ContainerInstances {
+		if aws.StringValue(ci.Ec2InstanceId) != ec2InstanceId {
+			continue
+		}
+
+		var cpu, memory instanceResource
+
+		for _, r := range ci.RegisteredResources {
+			switch aws.StringValue(r.Name) {
+			case "CPU":
+				cpu.Total = int(aws.Int64Value(r.IntegerValue))
+			case "MEMORY":
+				memory.Total = int(aws.Int64Value(r.IntegerValue))
+			}
+		}
+
+		for _, r := range ci.RemainingResources {
+			switch aws.StringValue(r.Name) {
+			case "CPU":
+				cpu.Free = int(aws.Int64Value(r.IntegerValue))
+			case "MEMORY":
+				memory.Free = int(aws.Int64Value(r.IntegerValue))
+			}
+		}
+
+		t := &InstanceTelemetry{
+			Source:        telemetrySourceECS,
+			TotalMemoryMB: int64(memory.Total),
+			FreeMemoryMB:  int64(memory.Free),
+		}
+
+		if cpu.Total > 0 {
+			t.CPUIdlePercent = float64(cpu.Free) / float64(cpu.Total) * 100
+		}
+
+		return t, nil
+	}
+
+	return nil, errorNotFound(fmt.Sprintf("instance not found: %s", ec2InstanceId))
+}
+
+// parseInstanceTelemetry reads the output of telemetryCommands
+func parseInstanceTelemetry(out string) (*InstanceTelemetry, error) {
+	sections := map[string][]string{}
+	section := ""
+
+	s := bufio.NewScanner(strings.NewReader(out))
+
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+
+		if strings.HasPrefix(line, "--- ") {
+			section = strings.TrimPrefix(line, "--- ")
+			continue
+		}
+
+		if line != "" {
+			sections[section] = append(sections[section], line)
+		}
+	}
+
+	t := &InstanceTelemetry{Source: telemetrySourceSSM}
+
+	total, free, err := parseMeminfo(sections["meminfo"])
+	if err != nil {
+		return nil, err
+	}
+
+	t.TotalMemoryMB = total / 1024
+	t.FreeMemoryMB = free / 1024
+
+	if t.CPUIdlePercent, err = parseVmstatIdle(sections["vmstat"]); err != nil {
+		return nil, err
+	}
+
+	if la := sections["loadavg"]; len(la) > 0 {
+		if fs := strings.Fields(la[0]); len(fs) > 0 {
+			if t.LoadAvg1, err = strconv.ParseFloat(fs[0], 64); err != nil {
+				return nil, fmt.Errorf("invalid loadavg: %s", la[0])
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// parseMeminfo returns the total and available memory in kB
+// kernels before MemAvailable only report MemFree, which undercounts reclaimable cache
+func parseMeminfo(lines []string) (int64, int64, error) {
+	values := map[string]int64{}
+
+	for _, line := range lines {
+		fs := strings.Fields(line)
+
+		if len(fs) < 2 {
+			continue
+		}
+
+		v, err := strconv.ParseInt(fs[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		values[strings.TrimSuffix(fs[0], ":")] = v
+	}
+
+	total, ok := values["MemTotal"]
+	if !ok {
+		return 0, 0, fmt.Errorf("meminfo has no MemTotal")
+	}
+
+	free, ok := values["MemAvailable"]
+	if !ok {
+		free = values["MemFree"]
+	}
+
+	return total, free, nil
+}
+
+// parseVmstatIdle returns the id column of the first line of vmstat output after its header
+func parseVmstatIdle(lines []string) (float64, error) {
+	col := -1
+
+	for _, line := range lines {
+		fs := strings.Fields(line)
+
+		for i, f := range fs {
+			if f == "id" {
+				col = i
+			}
+		}
+
+		if col >= 0 && col < len(fs) && fs[col] != "id" {
+			return strconv.ParseFloat(fs[col], 64)
+		}
+	}
+
+	return 0, fmt.Errorf("vmstat has no idle cpu column")
+}
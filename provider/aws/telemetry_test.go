@@ -0,0 +1,105 @@
+package aws_test
+
+import (
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const telemetryMeminfo = `MemTotal:        7863412 kB
+MemFree:          512344 kB
+MemAvailable:    4194304 kB
+Buffers:          102400 kB
+Cached:          3481600 kB
+SwapCached:            0 kB
+`
+
+const telemetryOutput = "--- meminfo\n" + telemetryMeminfo + `--- vmstat
+procs -----------memory---------- ---swap-- -----io---- -system-- ------cpu-----
+ r  b   swpd   free   buff  cache   si   so    bi    bo   in   cs us sy id wa st
+ 1  0      0 512344 102400 3481600    0    0     3    12  210  380  7  2 88  3  0
+--- loadavg
+0.42 0.37 0.30 2/311 12345
+`
+
+func TestGetInstanceTelemetrySSM(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	aws.SetTelemetryPollInterval(time.Millisecond)
+	defer aws.SetTelemetryPollInterval(1 * time.Second)
+
+	provider.Fake.SSM.AddManagedInstance("i-0123456789abcdef0")
+	provider.Fake.SSM.SetOutput("i-0123456789abcdef0", telemetryOutput)
+
+	tm, err := provider.GetInstanceTelemetry("i-0123456789abcdef0")
+	require.NoError(t, err)
+	assert.Equal(t, &aws.InstanceTelemetry{
+		Source:         "ssm",
+		TotalMemoryMB:  7679,
+		FreeMemoryMB:   4096,
+		CPUIdlePercent: 88,
+		LoadAvg1:       0.42,
+	}, tm)
+
+	cmds := provider.Fake.SSM.Commands()
+	require.Len(t, cmds, 1)
+	assert.Equal(t, "AWS-RunShellScript", cmds[0].DocumentName)
+	assert.Equal(t, "i-0123456789abcdef0", cmds[0].InstanceId)
+	assert.Contains(t, cmds[0].Commands, "cat /proc/meminfo")
+	assert.Contains(t, cmds[0].Commands, "vmstat 1 1")
+}
+
+func TestGetInstanceTelemetryECSFallback(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	require.NoError(t, provider.Fake.ECS.AddContainerInstance("cluster-test", &ecs.ContainerInstance{
+		Ec2InstanceId: awssdk.String("i-0fedcba9876543210"),
+		Status:        awssdk.String("ACTIVE"),
+		RegisteredResources: []*ecs.Resource{
+			{Name: awssdk.String("CPU"), IntegerValue: awssdk.Int64(2048)},
+			{Name: awssdk.String("MEMORY"), IntegerValue: awssdk.Int64(7680)},
+		},
+		RemainingResources: []*ecs.Resource{
+			{Name: awssdk.String("CPU"), IntegerValue: awssdk.Int64(512)},
+			{Name: awssdk.String("MEMORY"), IntegerValue: awssdk.Int64(1920)},
+		},
+	}))
+
+	tm, err := provider.GetInstanceTelemetry("i-0fedcba9876543210")
+	require.NoError(t, err)
+	assert.Equal(t, &aws.InstanceTelemetry{
+		Source:         "ecs",
+		TotalMemoryMB:  7680,
+		FreeMemoryMB:   1920,
+		CPUIdlePercent: 25,
+	}, tm)
+
+	assert.Len(t, provider.Fake.SSM.Commands(), 0)
+
+	_, err = provider.GetInstanceTelemetry("i-00000000000000000")
+	require.EqualError(t, err, "instance not found: i-00000000000000000")
+}
+
+func TestParseTelemetry(t *testing.T) {
+	// kernels before 3.14 have no MemAvailable
+	tm, err := aws.ParseTelemetry("--- meminfo\nMemTotal: 2048000 kB\nMemFree: 1024000 kB\n--- vmstat\n r b id\n 0 0 97\n")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2000), tm.TotalMemoryMB)
+	assert.Equal(t, int64(1000), tm.FreeMemoryMB)
+	assert.Equal(t, float64(97), tm.CPUIdlePercent)
+	assert.Equal(t, float64(0), tm.LoadAvg1)
+
+	_, err = aws.ParseTelemetry("--- vmstat\n r b id\n 0 0 97\n")
+	require.EqualError(t, err, "meminfo has no MemTotal")
+
+	_, err = aws.ParseTelemetry("--- meminfo\n" + telemetryMeminfo)
+	require.EqualError(t, err, "vmstat has no idle cpu column")
+}
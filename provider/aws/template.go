@@ -49,6 +49,12 @@ func formationHelpers() template.FuncMap {
 			}
 			return "", nil
 		},
+		"colors": func(s manifest.Service) []string {
+			if s.BlueGreen() {
+				return []string{"Blue", "Green"}
+			}
+			return []string{"Blue"}
+		},
 		"dec": func(i int) int {
 			return i - 1
 		},
@@ -0,0 +1,114 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/convox/rack/pkg/structs"
+)
+
+// ErrStaleTransition is returned when a record is no longer in the state a transition expected
+// callers should read the record again and decide whether the transition still applies
+type ErrStaleTransition struct {
+	Kind string
+	Id   string
+	From string
+	To   string
+}
+
+func (e ErrStaleTransition) Error() string {
+	return fmt.Sprintf("%s %s is no longer %s, can not change it to %s", e.Kind, e.Id, e.From, e.To)
+}
+
+// stateTransitions maps a status to the statuses it may change to
+type stateTransitions map[string][]string
+
+func (st stateTransitions) allowed(from, to string) bool {
+	for _, s := range st[from] {
+		if s == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// buildTransitions are the status changes of a build, imported builds complete without running
+// releases carry no status so they have no transitions
+var buildTransitions = stateTransitions{
+	"created": {"running", "complete", "failed"},
+	"running": {"complete", "failed"},
+}
+
+// buildTransition saves a build whose status is changing from from
+// the write only succeeds if the stored status is still from
+func (p *Provider) buildTransition(b *structs.Build, from string) error {
+	if !buildTransitions.allowed(from, b.Status) {
+		return fmt.Errorf("invalid build status transition from %s to %s", from, b.Status)
+	}
+
+	item, err := p.buildItem(b)
+	if err != nil {
+		return err
+	}
+
+	key := map[string]*dynamodb.AttributeValue{"id": item["id"]}
+
+	delete(item, "id")
+
+	err = p.dynamoUpdateConditional(p.DynamoBuilds, key, item, "#status = :from", map[string]*dynamodb.AttributeValue{
+		":from": {S: aws.String(from)},
+	})
+	if dynamoConditionFailed(err) {
+		return ErrStaleTransition{Kind: "build", Id: b.Id, From: from, To: b.Status}
+	}
+
+	return err
+}
+
+// dynamoUpdateConditional sets the attributes in updates on the item at key if condition holds
+// condition can refer to any updated attribute as #name and to the placeholders in values
+func (p *Provider) dynamoUpdateConditional(table string, key, updates map[string]*dynamodb.AttributeValue, condition string, values map[string]*dynamodb.AttributeValue) error {
+	names := map[string]*string{}
+	avs := map[string]*dynamodb.AttributeValue{}
+	sets := []string{}
+
+	for k, v := range updates {
+		names["#"+k] = aws.String(k)
+		avs[":"+k] = v
+		sets = append(sets, fmt.Sprintf("#%s = :%s", k, k))
+	}
+
+	for k, v := range values {
+		if _, ok := avs[k]; ok {
+			return fmt.Errorf("condition value %s conflicts with an updated attribute", k)
+		}
+
+		avs[k] = v
+	}
+
+	sort.Strings(sets)
+
+	req := &dynamodb.UpdateItemInput{
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: avs,
+		Key:                       key,
+		TableName:                 aws.String(table),
+		UpdateExpression:          aws.String("SET " + strings.Join(sets, ", ")),
+	}
+
+	if condition != "" {
+		req.ConditionExpression = aws.String(condition)
+	}
+
+	_, err := p.dynamodb().UpdateItem(req)
+
+	return err
+}
+
+func dynamoConditionFailed(err error) bool {
+	return awsError(err) == dynamodb.ErrCodeConditionalCheckFailedException
+}
@@ -0,0 +1,104 @@
+package aws_test
+
+import (
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/convox/rack/pkg/options"
+	"github.com/convox/rack/pkg/structs"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildUpdateStatusConditional(t *testing.T) {
+	provider := transitionTestProvider("running")
+	defer provider.Close()
+
+	b, err := provider.BuildUpdate("httpd", "B1", structs.BuildUpdateOptions{Release: options.String("R1"), Status: options.String("complete")})
+	require.NoError(t, err)
+	assert.Equal(t, "complete", b.Status)
+
+	updates := provider.Fake.DynamoDB.Updates()
+	require.Len(t, updates, 1)
+	assert.Equal(t, "#status = :from", awssdk.StringValue(updates[0].ConditionExpression))
+	assert.Equal(t, "running", awssdk.StringValue(updates[0].ExpressionAttributeValues[":from"].S))
+	assert.Contains(t, awssdk.StringValue(updates[0].UpdateExpression), "#status = :status")
+	assert.Equal(t, "status", awssdk.StringValue(updates[0].ExpressionAttributeNames["#status"]))
+
+	item, ok := provider.Fake.DynamoDB.Item("convox-builds", "B1")
+	require.True(t, ok)
+	assert.Equal(t, "complete", awssdk.StringValue(item["status"].S))
+	assert.Equal(t, "R1", awssdk.StringValue(item["release"].S))
+}
+
+func TestBuildUpdateKeepsStatus(t *testing.T) {
+	provider := transitionTestProvider("running")
+	defer provider.Close()
+
+	b, err := provider.BuildUpdate("httpd", "B1", structs.BuildUpdateOptions{Logs: options.String("object:///logs")})
+	require.NoError(t, err)
+	assert.Equal(t, "running", b.Status)
+
+	// the status read before the update is not written back over a transition that happened since
+	updates := provider.Fake.DynamoDB.Updates()
+	require.Len(t, updates, 1)
+	assert.Nil(t, updates[0].ConditionExpression)
+	assert.NotContains(t, awssdk.StringValue(updates[0].UpdateExpression), "#status")
+	assert.NotContains(t, updates[0].ExpressionAttributeNames, "#status")
+
+	item, ok := provider.Fake.DynamoDB.Item("convox-builds", "B1")
+	require.True(t, ok)
+	assert.Equal(t, "running", awssdk.StringValue(item["status"].S))
+	assert.Equal(t, "object:///logs", awssdk.StringValue(item["logs"].S))
+}
+
+func TestBuildUpdateStatusInvalid(t *testing.T) {
+	provider := transitionTestProvider("complete")
+	defer provider.Close()
+
+	_, err := provider.BuildUpdate("httpd", "B1", structs.BuildUpdateOptions{Status: options.String("failed")})
+	require.EqualError(t, err, "invalid build status transition from complete to failed")
+	assert.Empty(t, provider.Fake.DynamoDB.Updates())
+}
+
+func TestBuildTransitionStale(t *testing.T) {
+	provider := transitionTestProvider("failed")
+	defer provider.Close()
+
+	// a worker that read the build while it was running tries to complete it
+	err := provider.BuildTransition(&structs.Build{Id: "B1", App: "httpd", Status: "complete"}, "running")
+	require.EqualError(t, err, "build B1 is no longer running, can not change it to complete")
+
+	stale, ok := err.(aws.ErrStaleTransition)
+	require.True(t, ok)
+	assert.Equal(t, aws.ErrStaleTransition{Kind: "build", Id: "B1", From: "running", To: "complete"}, stale)
+
+	item, ok := provider.Fake.DynamoDB.Item("convox-builds", "B1")
+	require.True(t, ok)
+	assert.Equal(t, "failed", awssdk.StringValue(item["status"].S))
+}
+
+func TestCoalesceMixedTypes(t *testing.T) {
+	assert.Equal(t, "value", aws.Coalesce(&dynamodb.AttributeValue{S: awssdk.String("value")}, "default"))
+	assert.Equal(t, "12", aws.Coalesce(&dynamodb.AttributeValue{N: awssdk.String("12")}, "default"))
+	assert.Equal(t, "default", aws.Coalesce(&dynamodb.AttributeValue{SS: awssdk.StringSlice([]string{"a"})}, "default"))
+	assert.Equal(t, "default", aws.Coalesce(nil, "default"))
+}
+
+func transitionTestProvider(status string) *awsfake.TestProvider {
+	provider := awsfake.NewTestProvider()
+
+	provider.AddApp("httpd", "2", awsfake.Stack{})
+
+	provider.Fake.DynamoDB.PutItem("convox-builds", map[string]*dynamodb.AttributeValue{
+		"id":      {S: awssdk.String("B1")},
+		"app":     {S: awssdk.String("httpd")},
+		"status":  {S: awssdk.String(status)},
+		"created": {S: awssdk.String("20200101.000000.000000000")},
+	})
+
+	return provider
+}
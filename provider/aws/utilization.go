@@ -0,0 +1,267 @@
+package aws
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+const (
+	// utilizationPeriod is the granularity in seconds of the metrics a utilization report is computed from
+	utilizationPeriod = 300
+
+	// utilizationTarget is the cluster reservation percentage instance counts are recommended for
+	utilizationTarget = 80.0
+
+	// utilizationOverRequest is how many times its p95 usage a service can reserve before a smaller reservation is recommended
+	utilizationOverRequest = 2.0
+)
+
+// UtilizationStats are the median and 95th percentile of a percentage metric over the report window
+type UtilizationStats struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+}
+
+// ServiceUtilization is the usage of an ecs service as a percentage of what its tasks reserve
+type ServiceUtilization struct {
+	Name              string           `json:"name"`
+	Cpu               int64            `json:"cpu"`
+	Memory            int64            `json:"memory"`
+	CpuUtilization    UtilizationStats `json:"cpu-utilization"`
+	MemoryUtilization UtilizationStats `json:"memory-utilization"`
+}
+
+// UtilizationRecommendation is a change that would bring reservations closer to observed usage
+type UtilizationRecommendation struct {
+	Type    string `json:"type"`
+	Target  string `json:"target"`
+	Message string `json:"message"`
+}
+
+// UtilizationReport is how much of the rack capacity services reserve and use over a number of days
+type UtilizationReport struct {
+	Days              int                         `json:"days"`
+	Start             time.Time                   `json:"start"`
+	End               time.Time                   `json:"end"`
+	Instances         int                         `json:"instances"`
+	CpuReservation    UtilizationStats            `json:"cpu-reservation"`
+	MemoryReservation UtilizationStats            `json:"memory-reservation"`
+	Services          []ServiceUtilization        `json:"services"`
+	Recommendations   []UtilizationRecommendation `json:"recommendations"`
+}
+
+// rackUtilizationReport pulls cluster reservation and service utilization metrics for the last days
+// and recommends instance count and reservation changes where usage is well below what is reserved
+func (p *Provider) rackUtilizationReport(days int) (*UtilizationReport, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("days must be at least 1")
+	}
+
+	// align the window to the hour so reports within the hour share cached metrics
+	end := time.Now().UTC().Truncate(time.Hour)
+
+	r := &UtilizationReport{
+		Days:            days,
+		Start:           end.Add(-time.Duration(days) * 24 * time.Hour),
+		End:             end,
+		Services:        []ServiceUtilization{},
+		Recommendations: []UtilizationRecommendation{},
+	}
+
+	ires, err := p.listAndDescribeContainerInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ci := range ires.ContainerInstances {
+		if aws.StringValue(ci.Status) == "ACTIVE" {
+			r.Instances++
+		}
+	}
+
+	services, err := p.clusterServices()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return aws.StringValue(services[i].ServiceName) < aws.StringValue(services[j].ServiceName)
+	})
+
+	cluster := map[string]string{"ClusterName": p.Cluster}
+
+	queries := []*cloudwatch.MetricDataQuery{
+		ecsMetricQuery("cluster_cpu", "CPUReservation", cluster),
+		ecsMetricQuery("cluster_memory", "MemoryReservation", cluster),
+	}
+
+	for i, s := range services {
+		su := ServiceUtilization{Name: aws.StringValue(s.ServiceName)}
+
+		res, err := p.describeTaskDefinition(&ecs.DescribeTaskDefinitionInput{TaskDefinition: s.TaskDefinition})
+		if err != nil {
+			return nil, err
+		}
+
+		su.Cpu, su.Memory = taskDefinitionReservation(res.TaskDefinition)
+
+		r.Services = append(r.Services, su)
+
+		dimensions := map[string]string{"ClusterName": p.Cluster, "ServiceName": su.Name}
+
+		queries = append(queries,
+			ecsMetricQuery(fmt.Sprintf("service%d_cpu", i), "CPUUtilization", dimensions),
+			ecsMetricQuery(fmt.Sprintf("service%d_memory", i), "MemoryUtilization", dimensions),
+		)
+	}
+
+	values, err := p.getMetricData(queries, r.Start, r.End)
+	if err != nil {
+		return nil, err
+	}
+
+	r.CpuReservation = utilizationStats(values["cluster_cpu"])
+	r.MemoryReservation = utilizationStats(values["cluster_memory"])
+
+	for i := range r.Services {
+		r.Services[i].CpuUtilization = utilizationStats(values[fmt.Sprintf("service%d_cpu", i)])
+		r.Services[i].MemoryUtilization = utilizationStats(values[fmt.Sprintf("service%d_memory", i)])
+	}
+
+	r.Recommendations = utilizationRecommendations(r)
+
+	return r, nil
+}
+
+func ecsMetricQuery(id, name string, dimensions map[string]string) *cloudwatch.MetricDataQuery {
+	ds := []*cloudwatch.Dimension{}
+
+	for k, v := range dimensions {
+		ds = append(ds, &cloudwatch.Dimension{Name: aws.String(k), Value: aws.String(v)})
+	}
+
+	// keep queries identical between calls so they share a cache entry
+	sort.Slice(ds, func(i, j int) bool { return aws.StringValue(ds[i].Name) < aws.StringValue(ds[j].Name) })
+
+	return &cloudwatch.MetricDataQuery{
+		Id: aws.String(id),
+		MetricStat: &cloudwatch.MetricStat{
+			Metric: &cloudwatch.Metric{
+				Dimensions: ds,
+				MetricName: aws.String(name),
+				Namespace:  aws.String("AWS/ECS"),
+			},
+			Period: aws.Int64(utilizationPeriod),
+			Stat:   aws.String("Average"),
+		},
+	}
+}
+
+// taskDefinitionReservation returns the cpu units and memory in MB reserved by a task
+func taskDefinitionReservation(td *ecs.TaskDefinition) (int64, int64) {
+	cpu, memory := int64(0), int64(0)
+
+	for _, cd := range td.ContainerDefinitions {
+		cpu += aws.Int64Value(cd.Cpu)
+
+		if cd.Memory != nil {
+			memory += *cd.Memory
+		} else {
+			memory += aws.Int64Value(cd.MemoryReservation)
+		}
+	}
+
+	// fargate tasks reserve at the task level
+	if c, err := strconv.ParseInt(aws.StringValue(td.Cpu), 10, 64); err == nil {
+		cpu = c
+	}
+
+	if m, err := strconv.ParseInt(aws.StringValue(td.Memory), 10, 64); err == nil {
+		memory = m
+	}
+
+	return cpu, memory
+}
+
+func utilizationRecommendations(r *UtilizationReport) []UtilizationRecommendation {
+	rs := []UtilizationRecommendation{}
+
+	if r.Instances > 0 {
+		peak := math.Max(r.CpuReservation.P95, r.MemoryReservation.P95)
+
+		needed := int(math.Ceil(float64(r.Instances) * peak / utilizationTarget))
+		if needed < 1 {
+			needed = 1
+		}
+
+		if n := r.Instances - needed; n > 0 {
+			rs = append(rs, UtilizationRecommendation{
+				Type:    "instances",
+				Target:  "rack",
+				Message: fmt.Sprintf("reduce instance count by %d, p95 reservation of %d instances is %0.1f%%", n, r.Instances, peak),
+			})
+		}
+	}
+
+	for _, s := range r.Services {
+		if over := overRequest(s.MemoryUtilization.P95); over >= utilizationOverRequest && s.Memory > 0 {
+			rs = append(rs, UtilizationRecommendation{
+				Type:    "memory",
+				Target:  s.Name,
+				Message: fmt.Sprintf("service %s requests %0.0fx its p95 memory (%dMB reserved)", s.Name, over, s.Memory),
+			})
+		}
+
+		if over := overRequest(s.CpuUtilization.P95); over >= utilizationOverRequest && s.Cpu > 0 {
+			rs = append(rs, UtilizationRecommendation{
+				Type:    "cpu",
+				Target:  s.Name,
+				Message: fmt.Sprintf("service %s requests %0.0fx its p95 cpu (%d units reserved)", s.Name, over, s.Cpu),
+			})
+		}
+	}
+
+	return rs
+}
+
+// overRequest is how many times its usage a service reserves given usage as a percentage of the reservation
+// a service without usage data returns zero rather than an unbounded ratio
+func overRequest(p95 float64) float64 {
+	if p95 <= 0 {
+		return 0
+	}
+
+	return math.Floor(100 / p95)
+}
+
+func utilizationStats(values []float64) UtilizationStats {
+	return UtilizationStats{
+		P50: percentile(values, 50),
+		P95: percentile(values, 95),
+	}
+}
+
+// percentile returns the nearest rank percentile of values, zero when there are none
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64{}, values...)
+
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
@@ -0,0 +1,152 @@
+package aws_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []float64{}
+
+	for i := 20; i > 0; i-- {
+		values = append(values, float64(i))
+	}
+
+	assert.Equal(t, 10.0, aws.Percentile(values, 50))
+	assert.Equal(t, 19.0, aws.Percentile(values, 95))
+	assert.Equal(t, 20.0, aws.Percentile(values, 100))
+	assert.Equal(t, 1.0, aws.Percentile(values, 0))
+	assert.Equal(t, 7.0, aws.Percentile([]float64{7}, 95))
+	assert.Equal(t, 0.0, aws.Percentile([]float64{}, 95))
+
+	// the input is left unsorted
+	assert.Equal(t, 20.0, values[0])
+}
+
+func TestGetMetricDataChunks(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.Fake.CloudWatch.AddMetricData("AWS/ECS", "CPUUtilization", map[string]string{"ServiceName": "s1200"}, 1, 2, 3)
+
+	queries := []*cloudwatch.MetricDataQuery{}
+
+	for i := 0; i < 1201; i++ {
+		queries = append(queries, &cloudwatch.MetricDataQuery{
+			Id: awssdk.String(fmt.Sprintf("q%d", i)),
+			MetricStat: &cloudwatch.MetricStat{
+				Metric: &cloudwatch.Metric{
+					Dimensions: []*cloudwatch.Dimension{{Name: awssdk.String("ServiceName"), Value: awssdk.String(fmt.Sprintf("s%d", i))}},
+					MetricName: awssdk.String("CPUUtilization"),
+					Namespace:  awssdk.String("AWS/ECS"),
+				},
+				Period: awssdk.Int64(300),
+				Stat:   awssdk.String("Average"),
+			},
+		})
+	}
+
+	end := time.Now().UTC().Truncate(time.Hour)
+
+	values, err := provider.GetMetricData(queries, end.Add(-24*time.Hour), end)
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{500, 500, 201}, provider.Fake.CloudWatch.MetricDataCalls())
+	assert.Equal(t, map[string][]float64{"q1200": {1, 2, 3}}, values)
+}
+
+func TestRackUtilizationReport(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, provider.Fake.ECS.AddContainerInstance("cluster-test", &ecs.ContainerInstance{Status: awssdk.String("ACTIVE")}))
+	}
+
+	require.NoError(t, provider.Fake.ECS.AddContainerInstance("cluster-test", &ecs.ContainerInstance{Status: awssdk.String("DRAINING")}))
+
+	web := provider.Fake.ECS.AddTaskDefinition(&ecs.TaskDefinition{
+		Family:               awssdk.String("httpd-web"),
+		Revision:             awssdk.Int64(1),
+		ContainerDefinitions: []*ecs.ContainerDefinition{{Name: awssdk.String("web"), Cpu: awssdk.Int64(256), Memory: awssdk.Int64(1024)}},
+	})
+
+	worker := provider.Fake.ECS.AddTaskDefinition(&ecs.TaskDefinition{
+		Family:               awssdk.String("httpd-worker"),
+		Revision:             awssdk.Int64(1),
+		ContainerDefinitions: []*ecs.ContainerDefinition{{Name: awssdk.String("worker"), MemoryReservation: awssdk.Int64(512)}},
+	})
+
+	require.NoError(t, provider.Fake.ECS.AddService("cluster-test", &ecs.Service{
+		ServiceArn:     awssdk.String("arn:aws:ecs:us-test-1:123456789012:service/cluster-test/httpd-worker"),
+		ServiceName:    awssdk.String("httpd-worker"),
+		TaskDefinition: awssdk.String(worker),
+	}))
+
+	require.NoError(t, provider.Fake.ECS.AddService("cluster-test", &ecs.Service{
+		ServiceArn:     awssdk.String("arn:aws:ecs:us-test-1:123456789012:service/cluster-test/httpd-web"),
+		ServiceName:    awssdk.String("httpd-web"),
+		TaskDefinition: awssdk.String(web),
+	}))
+
+	cpu := []float64{}
+
+	for i := 1; i <= 20; i++ {
+		cpu = append(cpu, float64(i))
+	}
+
+	cluster := map[string]string{"ClusterName": "cluster-test"}
+	service := map[string]string{"ClusterName": "cluster-test", "ServiceName": "httpd-web"}
+
+	provider.Fake.CloudWatch.AddMetricData("AWS/ECS", "CPUReservation", cluster, cpu...)
+	provider.Fake.CloudWatch.AddMetricData("AWS/ECS", "MemoryReservation", cluster, 30, 30, 30)
+	provider.Fake.CloudWatch.AddMetricData("AWS/ECS", "CPUUtilization", service, 60, 70, 80)
+	provider.Fake.CloudWatch.AddMetricData("AWS/ECS", "MemoryUtilization", service, 10, 20, 25, 20)
+
+	r, err := provider.RackUtilizationReport(7)
+	require.NoError(t, err)
+
+	assert.Equal(t, 7, r.Days)
+	assert.Equal(t, 7*24*time.Hour, r.End.Sub(r.Start))
+	assert.Equal(t, 4, r.Instances)
+	assert.Equal(t, aws.UtilizationStats{P50: 10, P95: 19}, r.CpuReservation)
+	assert.Equal(t, aws.UtilizationStats{P50: 30, P95: 30}, r.MemoryReservation)
+
+	assert.Equal(t, []aws.ServiceUtilization{
+		{
+			Name:              "httpd-web",
+			Cpu:               256,
+			Memory:            1024,
+			CpuUtilization:    aws.UtilizationStats{P50: 70, P95: 80},
+			MemoryUtilization: aws.UtilizationStats{P50: 20, P95: 25},
+		},
+		{
+			Name:   "httpd-worker",
+			Memory: 512,
+		},
+	}, r.Services)
+
+	assert.Equal(t, []aws.UtilizationRecommendation{
+		{Type: "instances", Target: "rack", Message: "reduce instance count by 2, p95 reservation of 4 instances is 30.0%"},
+		{Type: "memory", Target: "httpd-web", Message: "service httpd-web requests 4x its p95 memory (1024MB reserved)"},
+	}, r.Recommendations)
+
+	assert.Equal(t, []int{6}, provider.Fake.CloudWatch.MetricDataCalls())
+}
+
+func TestRackUtilizationReportDays(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	_, err := provider.RackUtilizationReport(0)
+	require.EqualError(t, err, "days must be at least 1")
+}
@@ -0,0 +1,163 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// ImageVulnerabilitySeverities are the severities reported by ECR image scans, most severe first
+var ImageVulnerabilitySeverities = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "INFORMATIONAL"}
+
+// ImageVulnerability is a single finding from an ECR image scan
+type ImageVulnerability struct {
+	PackageName string
+	Version     string
+	CVE         string
+	Severity    string
+	CVSS        float64
+}
+
+// ListImageVulnerabilities returns the scan findings for the image of a service in the current release
+// an empty severity returns findings of every severity
+func (p *Provider) ListImageVulnerabilities(app, service string, severity string) ([]ImageVulnerability, error) {
+	severity = strings.ToUpper(severity)
+
+	if severity != "" && !validImageVulnerabilitySeverity(severity) {
+		return nil, fmt.Errorf("invalid severity: %s, must be one of: %s", severity, strings.Join(ImageVulnerabilitySeverities, ", "))
+	}
+
+	repo, tag, err := p.serviceImage(app, service)
+	if err != nil {
+		return nil, err
+	}
+
+	vs := []ImageVulnerability{}
+
+	req := &ecr.DescribeImageScanFindingsInput{
+		ImageId:        &ecr.ImageIdentifier{ImageTag: aws.String(tag)},
+		RepositoryName: aws.String(repo),
+	}
+
+	for {
+		res, err := p.ecr().DescribeImageScanFindings(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.ImageScanFindings != nil {
+			for _, f := range res.ImageScanFindings.Findings {
+				v := imageVulnerabilityFromFinding(f)
+
+				if severity == "" || v.Severity == severity {
+					vs = append(vs, v)
+				}
+			}
+		}
+
+		if res.NextToken == nil {
+			break
+		}
+
+		req.NextToken = res.NextToken
+	}
+
+	sort.SliceStable(vs, func(i, j int) bool { return vs[i].CVSS > vs[j].CVSS })
+
+	return vs, nil
+}
+
+// ImageVulnerabilitySummary returns the number of scan findings of each severity for the image of a service
+func (p *Provider) ImageVulnerabilitySummary(app, service string) (map[string]int, error) {
+	vs, err := p.ListImageVulnerabilities(app, service, "")
+	if err != nil {
+		return nil, err
+	}
+
+	summary := map[string]int{}
+
+	for _, s := range ImageVulnerabilitySeverities {
+		summary[s] = 0
+	}
+
+	for _, v := range vs {
+		summary[v.Severity]++
+	}
+
+	return summary, nil
+}
+
+// serviceImage returns the repository name and tag of the image for a service in the current release of an app
+func (p *Provider) serviceImage(app, service string) (string, string, error) {
+	a, err := p.AppGet(app)
+	if err != nil {
+		return "", "", err
+	}
+
+	if a.Release == "" {
+		return "", "", fmt.Errorf("no release for app: %s", app)
+	}
+
+	rs, err := p.appResources(app)
+	if err != nil {
+		return "", "", err
+	}
+
+	repo := coalesces(rs["Registry"], rs["RegistryRepository"])
+
+	if repo == "" {
+		return "", "", errorNotFound(fmt.Sprintf("no repository for app: %s", app))
+	}
+
+	item, err := p.fetchRelease(app, a.Release)
+	if err != nil {
+		return "", "", err
+	}
+
+	r, err := releaseFromItem(item)
+	if err != nil {
+		return "", "", err
+	}
+
+	if r.Build == "" {
+		return "", "", fmt.Errorf("no build for release: %s", r.Id)
+	}
+
+	return repo, fmt.Sprintf("%s.%s", service, r.Build), nil
+}
+
+func imageVulnerabilityFromFinding(f *ecr.ImageScanFinding) ImageVulnerability {
+	v := ImageVulnerability{
+		CVE:      cs(f.Name, ""),
+		Severity: cs(f.Severity, ""),
+	}
+
+	for _, a := range f.Attributes {
+		switch cs(a.Key, "") {
+		case "package_name":
+			v.PackageName = cs(a.Value, "")
+		case "package_version":
+			v.Version = cs(a.Value, "")
+		case "CVSS2_SCORE", "CVSS3_SCORE":
+			if score, err := strconv.ParseFloat(cs(a.Value, ""), 64); err == nil && score > v.CVSS {
+				v.CVSS = score
+			}
+		}
+	}
+
+	return v
+}
+
+func validImageVulnerabilitySeverity(severity string) bool {
+	for _, s := range ImageVulnerabilitySeverities {
+		if s == severity {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,162 @@
+package aws_test
+
+import (
+	"testing"
+
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListImageVulnerabilities(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleAppDescribeStacks,
+		cycleVulnerabilitiesListStackResources,
+		cycleReleaseGetItem,
+		cycleVulnerabilitiesDescribeImageScanFindings1,
+		cycleVulnerabilitiesDescribeImageScanFindings2,
+	)
+	defer provider.Close()
+
+	vs, err := provider.ListImageVulnerabilities("httpd", "web", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, []aws.ImageVulnerability{
+		{PackageName: "openssl", Version: "1.1.0g", CVE: "CVE-2019-0001", Severity: "CRITICAL", CVSS: 9.8},
+		{PackageName: "curl", Version: "7.58.0", CVE: "CVE-2019-0003", Severity: "HIGH", CVSS: 7.5},
+		{PackageName: "bash", Version: "4.4", CVE: "CVE-2019-0002", Severity: "LOW", CVSS: 2.1},
+	}, vs)
+}
+
+func TestListImageVulnerabilitiesSeverity(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleAppDescribeStacks,
+		cycleVulnerabilitiesListStackResources,
+		cycleReleaseGetItem,
+		cycleVulnerabilitiesDescribeImageScanFindings1,
+		cycleVulnerabilitiesDescribeImageScanFindings2,
+	)
+	defer provider.Close()
+
+	vs, err := provider.ListImageVulnerabilities("httpd", "web", "high")
+	require.NoError(t, err)
+
+	assert.Equal(t, []aws.ImageVulnerability{
+		{PackageName: "curl", Version: "7.58.0", CVE: "CVE-2019-0003", Severity: "HIGH", CVSS: 7.5},
+	}, vs)
+
+	_, err = provider.ListImageVulnerabilities("httpd", "web", "severe")
+	require.EqualError(t, err, "invalid severity: SEVERE, must be one of: CRITICAL, HIGH, MEDIUM, LOW, INFORMATIONAL")
+}
+
+func TestImageVulnerabilitySummary(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleAppDescribeStacks,
+		cycleVulnerabilitiesListStackResources,
+		cycleReleaseGetItem,
+		cycleVulnerabilitiesDescribeImageScanFindings1,
+		cycleVulnerabilitiesDescribeImageScanFindings2,
+	)
+	defer provider.Close()
+
+	summary, err := provider.ImageVulnerabilitySummary("httpd", "web")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int{"CRITICAL": 1, "HIGH": 1, "MEDIUM": 0, "LOW": 1, "INFORMATIONAL": 0}, summary)
+}
+
+var cycleVulnerabilitiesListStackResources = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "",
+		Body:       `Action=ListStackResources&StackName=convox-httpd&Version=2010-05-15`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `
+			<ListStackResourcesResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+				<ListStackResourcesResult>
+					<StackResourceSummaries>
+						<member>
+							<PhysicalResourceId>convox-httpd-hqvvfosgxt</PhysicalResourceId>
+							<ResourceStatus>CREATE_COMPLETE</ResourceStatus>
+							<LogicalResourceId>RegistryRepository</LogicalResourceId>
+							<Timestamp>2016-10-22T02:53:23.817Z</Timestamp>
+							<ResourceType>Custom::ECRRepository</ResourceType>
+						</member>
+					</StackResourceSummaries>
+				</ListStackResourcesResult>
+			</ListStackResourcesResponse>
+		`,
+	},
+}
+
+var cycleVulnerabilitiesDescribeImageScanFindings1 = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "AmazonEC2ContainerRegistry_V20150921.DescribeImageScanFindings",
+		Body: `{
+			"imageId": { "imageTag": "web.BHINCLZYYVN" },
+			"repositoryName": "convox-httpd-hqvvfosgxt"
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"imageScanFindings": {
+				"findings": [
+					{
+						"name": "CVE-2019-0002",
+						"severity": "LOW",
+						"attributes": [
+							{ "key": "package_name", "value": "bash" },
+							{ "key": "package_version", "value": "4.4" },
+							{ "key": "CVSS2_SCORE", "value": "2.1" }
+						]
+					},
+					{
+						"name": "CVE-2019-0001",
+						"severity": "CRITICAL",
+						"attributes": [
+							{ "key": "package_name", "value": "openssl" },
+							{ "key": "package_version", "value": "1.1.0g" },
+							{ "key": "CVSS2_SCORE", "value": "9.8" }
+						]
+					}
+				]
+			},
+			"nextToken": "token1"
+		}`,
+	},
+}
+
+var cycleVulnerabilitiesDescribeImageScanFindings2 = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "AmazonEC2ContainerRegistry_V20150921.DescribeImageScanFindings",
+		Body: `{
+			"imageId": { "imageTag": "web.BHINCLZYYVN" },
+			"nextToken": "token1",
+			"repositoryName": "convox-httpd-hqvvfosgxt"
+		}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `{
+			"imageScanFindings": {
+				"findings": [
+					{
+						"name": "CVE-2019-0003",
+						"severity": "HIGH",
+						"attributes": [
+							{ "key": "package_name", "value": "curl" },
+							{ "key": "package_version", "value": "7.58.0" },
+							{ "key": "CVSS2_SCORE", "value": "7.5" }
+						]
+					}
+				]
+			}
+		}`,
+	},
+}
@@ -0,0 +1,152 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// WebACL ARN is formatted like arn:aws:wafv2:us-east-1:123456789012:regional/webacl/name/a1b2c3d4-5678-90ab-cdef-EXAMPLE11111
+// only regional web acls can be associated with a load balancer, cloudfront ones are global
+var regexpWebACLArn = regexp.MustCompile(`^arn:aws[a-z-]*:wafv2:([a-z0-9-]+):(\d{12}):regional/webacl/([A-Za-z0-9_-]{1,128})/([A-Za-z0-9-]+)$`)
+
+// shapes of the WAFv2 json api sent through wafv2Send, only the fields the provider uses
+type wafResourceInput struct {
+	ResourceArn *string `type:"string"`
+}
+
+type wafAssociateWebACLInput struct {
+	ResourceArn *string `type:"string"`
+	WebACLArn   *string `type:"string"`
+}
+
+type wafGetWebACLForResourceOutput struct {
+	WebACL *wafWebACL `type:"structure"`
+}
+
+type wafWebACL struct {
+	ARN  *string `type:"string"`
+	Id   *string `type:"string"`
+	Name *string `type:"string"`
+}
+
+type wafTag struct {
+	Key   *string `type:"string"`
+	Value *string `type:"string"`
+}
+
+type wafTagResourceInput struct {
+	ResourceARN *string   `type:"string"`
+	Tags        []*wafTag `type:"list"`
+}
+
+// AssociateWAF attaches a WAFv2 web acl to the rack router and tags the web acl with the rack
+// Apps share the rack router so the web acl filters traffic for every app on the rack
+func (p *Provider) AssociateWAF(webACLArn string) error {
+	m := regexpWebACLArn.FindStringSubmatch(webACLArn)
+	if m == nil {
+		return fmt.Errorf("invalid web acl arn: %s", webACLArn)
+	}
+	if m[1] != p.Region {
+		return fmt.Errorf("web acl must be in region %s: %s", p.Region, webACLArn)
+	}
+
+	router, err := p.rackRouterArn()
+	if err != nil {
+		return err
+	}
+
+	err = p.wafv2Send("AssociateWebACL", &wafAssociateWebACLInput{
+		ResourceArn: aws.String(router),
+		WebACLArn:   aws.String(webACLArn),
+	}, &struct{}{})
+	if err != nil {
+		return err
+	}
+
+	err = p.wafv2Send("TagResource", &wafTagResourceInput{
+		ResourceARN: aws.String(webACLArn),
+		Tags: []*wafTag{
+			{Key: aws.String("Rack"), Value: aws.String(p.Rack)},
+		},
+	}, &struct{}{})
+	if err != nil {
+		return err
+	}
+
+	p.auditRecord("waf.associate", "system", p.actor(), map[string]string{"webacl": webACLArn})
+
+	return nil
+}
+
+// DisassociateWAF detaches the web acl from the rack router, it does nothing if none is attached
+func (p *Provider) DisassociateWAF() error {
+	router, err := p.rackRouterArn()
+	if err != nil {
+		return err
+	}
+
+	acl, err := p.routerWebACL(router)
+	if err != nil {
+		return err
+	}
+	if acl == "" {
+		return nil
+	}
+
+	if err := p.wafv2Send("DisassociateWebACL", &wafResourceInput{ResourceArn: aws.String(router)}, &struct{}{}); err != nil {
+		return err
+	}
+
+	p.auditRecord("waf.disassociate", "system", p.actor(), map[string]string{"webacl": acl})
+
+	return nil
+}
+
+// GetAssociatedWAF returns the arn of the web acl attached to the rack router or an empty string if there is none
+func (p *Provider) GetAssociatedWAF() (string, error) {
+	router, err := p.rackRouterArn()
+	if err != nil {
+		return "", err
+	}
+
+	return p.routerWebACL(router)
+}
+
+// rackRouterArn returns the arn of the rack router, internal only racks have an internal router instead
+func (p *Provider) rackRouterArn() (string, error) {
+	for _, r := range []string{"Router", "RouterInternal"} {
+		arn, err := p.rackResource(r)
+		if err == nil && arn != "" {
+			return arn, nil
+		}
+		if err != nil && !errors.Is(err, ErrResourceNotFound) {
+			return "", err
+		}
+	}
+
+	return "", errorNotFound(fmt.Sprintf("rack %s has no router", p.Rack))
+}
+
+func (p *Provider) routerWebACL(router string) (string, error) {
+	res := &wafGetWebACLForResourceOutput{}
+
+	if err := p.wafv2Send("GetWebACLForResource", &wafResourceInput{ResourceArn: aws.String(router)}, res); err != nil {
+		return "", err
+	}
+
+	if res.WebACL == nil {
+		return "", nil
+	}
+
+	return aws.StringValue(res.WebACL.ARN), nil
+}
+
+func (p *Provider) wafv2Send(operation string, input, output interface{}) error {
+	op := &request.Operation{Name: operation, HTTPMethod: "POST", HTTPPath: "/"}
+
+	return p.wafv2().NewRequest(op, input, output).Send()
+}
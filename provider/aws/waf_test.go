@@ -0,0 +1,78 @@
+package aws_test
+
+import (
+	"testing"
+
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const wafTestRouter = "arn:aws:elasticloadbalancing:us-test-1:123456789012:loadbalancer/app/convox-Router/1234567890abcdef"
+
+func TestAssociateWAF(t *testing.T) {
+	p := wafTestProvider()
+	defer p.Close()
+
+	acl := p.Fake.WAFv2.AddWebACL("httpd")
+
+	require.NoError(t, p.AssociateWAF(acl))
+	assert.Equal(t, acl, p.Fake.WAFv2.Associated(wafTestRouter))
+	assert.Equal(t, map[string]string{"Rack": "convox"}, p.Fake.WAFv2.Tags(acl))
+
+	arn, err := p.GetAssociatedWAF()
+	require.NoError(t, err)
+	assert.Equal(t, acl, arn)
+
+	require.NoError(t, p.DisassociateWAF())
+	assert.Equal(t, "", p.Fake.WAFv2.Associated(wafTestRouter))
+
+	arn, err = p.GetAssociatedWAF()
+	require.NoError(t, err)
+	assert.Equal(t, "", arn)
+
+	// disassociating again is a no-op
+	require.NoError(t, p.DisassociateWAF())
+}
+
+func TestAssociateWAFInvalidArn(t *testing.T) {
+	p := wafTestProvider()
+	defer p.Close()
+
+	err := p.AssociateWAF("arn:aws:wafv2:us-test-1:123456789012:global/webacl/httpd/00000001-0000-0000-0000-000000000000")
+	require.EqualError(t, err, "invalid web acl arn: arn:aws:wafv2:us-test-1:123456789012:global/webacl/httpd/00000001-0000-0000-0000-000000000000")
+
+	err = p.AssociateWAF("arn:aws:wafv2:us-east-1:123456789012:regional/webacl/httpd/00000001-0000-0000-0000-000000000000")
+	require.EqualError(t, err, "web acl must be in region us-test-1: arn:aws:wafv2:us-east-1:123456789012:regional/webacl/httpd/00000001-0000-0000-0000-000000000000")
+}
+
+func TestAssociateWAFUnknownWebACL(t *testing.T) {
+	p := wafTestProvider()
+	defer p.Close()
+
+	err := p.AssociateWAF("arn:aws:wafv2:us-test-1:123456789012:regional/webacl/missing/00000009-0000-0000-0000-000000000000")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "WAFNonexistentItemException")
+	assert.Equal(t, "", p.Fake.WAFv2.Associated(wafTestRouter))
+}
+
+func TestGetAssociatedWAFNoRouter(t *testing.T) {
+	p := awsfake.NewTestProvider()
+	defer p.Close()
+
+	p.AddRack(awsfake.Stack{})
+
+	_, err := p.GetAssociatedWAF()
+	require.EqualError(t, err, "rack convox has no router")
+}
+
+// wafTestProvider seeds a rack with a public router
+func wafTestProvider() *awsfake.TestProvider {
+	p := awsfake.NewTestProvider()
+
+	p.AddRack(awsfake.Stack{
+		Resources: []awsfake.Resource{{LogicalId: "Router", PhysicalId: wafTestRouter, Type: "AWS::ElasticLoadBalancingV2::LoadBalancer"}},
+	})
+
+	return p
+}
@@ -0,0 +1,159 @@
+package aws
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BuildWebhookEvents are the build events a webhook can subscribe to
+var BuildWebhookEvents = []string{"started", "succeeded", "failed"}
+
+var buildWebhookTimeout = 5 * time.Second
+
+// BuildEvent is the payload posted to a build webhook
+type BuildEvent struct {
+	App       string    `json:"app"`
+	Build     string    `json:"build"`
+	Status    string    `json:"status"`
+	Duration  string    `json:"duration"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type buildWebhook struct {
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// SetBuildWebhook subscribes webhook to the build events of an app, no events subscribes to all of them
+// payloads are signed with a per-app secret kept in the settings bucket
+func (p *Provider) SetBuildWebhook(app, webhook string, events []string) error {
+	log := Logger.At("SetBuildWebhook").Namespace("app=%s", app).Start()
+
+	if err := validateBuildWebhook(webhook, events); err != nil {
+		return log.Error(err)
+	}
+
+	data, err := json.Marshal(buildWebhook{Url: webhook, Events: events})
+	if err != nil {
+		return log.Error(err)
+	}
+
+	exists, err := p.s3Exists(p.SettingsBucket, buildWebhookKey(app, "secret"))
+	if err != nil {
+		return log.Error(err)
+	}
+
+	if !exists {
+		secret := make([]byte, 32)
+
+		if _, err := rand.Read(secret); err != nil {
+			return log.Error(err)
+		}
+
+		if err := p.s3Put(p.SettingsBucket, buildWebhookKey(app, "secret"), []byte(hex.EncodeToString(secret)), false); err != nil {
+			return log.Error(err)
+		}
+	}
+
+	if err := p.s3Put(p.SettingsBucket, buildWebhookKey(app, "webhook.json"), data, false); err != nil {
+		return log.Error(err)
+	}
+
+	return log.Success()
+}
+
+// FireBuildWebhook posts event to the webhook of an app if it subscribes to the event
+// the X-Convox-Signature header carries the hex HMAC-SHA256 of the body
+func (p *Provider) FireBuildWebhook(app string, event BuildEvent) error {
+	log := Logger.At("FireBuildWebhook").Namespace("app=%s build=%s status=%s", app, event.Build, event.Status).Start()
+
+	exists, err := p.s3Exists(p.SettingsBucket, buildWebhookKey(app, "webhook.json"))
+	if err != nil {
+		return log.Error(err)
+	}
+
+	if !exists {
+		return log.Successf("webhook=none")
+	}
+
+	data, err := p.s3Get(p.SettingsBucket, buildWebhookKey(app, "webhook.json"))
+	if err != nil {
+		return log.Error(err)
+	}
+
+	var wh buildWebhook
+
+	if err := json.Unmarshal(data, &wh); err != nil {
+		return log.Error(err)
+	}
+
+	if len(wh.Events) > 0 && !containsString(wh.Events, event.Status) {
+		return log.Successf("skipped=true")
+	}
+
+	secret, err := p.s3Get(p.SettingsBucket, buildWebhookKey(app, "secret"))
+	if err != nil {
+		return log.Error(err)
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return log.Error(err)
+	}
+
+	req, err := http.NewRequest("POST", wh.Url, bytes.NewReader(body))
+	if err != nil {
+		return log.Error(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Convox-Signature", buildWebhookSignature(secret, body))
+
+	c := &http.Client{Timeout: buildWebhookTimeout}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return log.Error(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return log.Error(fmt.Errorf("build webhook responded with status %d", res.StatusCode))
+	}
+
+	return log.Success()
+}
+
+func buildWebhookKey(app, name string) string {
+	return objectKey("build-webhook", app, name)
+}
+
+func buildWebhookSignature(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func validateBuildWebhook(webhook string, events []string) error {
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url: %s", webhook)
+	}
+
+	for _, e := range events {
+		if !containsString(BuildWebhookEvents, e) {
+			return fmt.Errorf("invalid build event %s, must be one of %s", e, strings.Join(BuildWebhookEvents, ", "))
+		}
+	}
+
+	return nil
+}
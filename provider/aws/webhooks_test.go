@@ -0,0 +1,98 @@
+package aws_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFireBuildWebhook(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	events := []aws.BuildEvent{}
+	signatures := []string{}
+	bodies := [][]byte{}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var e aws.BuildEvent
+		require.NoError(t, json.Unmarshal(data, &e))
+
+		events = append(events, e)
+		signatures = append(signatures, r.Header.Get("X-Convox-Signature"))
+		bodies = append(bodies, data)
+	}))
+	defer s.Close()
+
+	require.NoError(t, provider.SetBuildWebhook("httpd", s.URL+"/hook", []string{"succeeded", "failed"}))
+
+	secret, ok := provider.Fake.S3.Object("convox-settings", "apps/httpd/build-webhook/secret")
+	require.True(t, ok)
+	require.Len(t, secret, 64)
+
+	ts := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, provider.FireBuildWebhook("httpd", aws.BuildEvent{App: "httpd", Build: "BABCDEFGHI", Status: "started", Timestamp: ts}))
+	require.NoError(t, provider.FireBuildWebhook("httpd", aws.BuildEvent{App: "httpd", Build: "BABCDEFGHI", Status: "succeeded", Duration: "1m30s", Timestamp: ts}))
+
+	require.Len(t, events, 1)
+	assert.Equal(t, aws.BuildEvent{App: "httpd", Build: "BABCDEFGHI", Status: "succeeded", Duration: "1m30s", Timestamp: ts}, events[0])
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(bodies[0])
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), signatures[0])
+
+	// setting the webhook again keeps the secret receivers already verify with
+	require.NoError(t, provider.SetBuildWebhook("httpd", s.URL+"/hook", nil))
+
+	again, ok := provider.Fake.S3.Object("convox-settings", "apps/httpd/build-webhook/secret")
+	require.True(t, ok)
+	assert.Equal(t, secret, again)
+
+	require.NoError(t, provider.FireBuildWebhook("httpd", aws.BuildEvent{App: "httpd", Build: "BABCDEFGHI", Status: "started", Timestamp: ts}))
+	require.Len(t, events, 2)
+}
+
+func TestFireBuildWebhookNone(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	require.NoError(t, provider.FireBuildWebhook("httpd", aws.BuildEvent{App: "httpd", Build: "BABCDEFGHI", Status: "failed"}))
+}
+
+func TestFireBuildWebhookError(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer s.Close()
+
+	require.NoError(t, provider.SetBuildWebhook("httpd", s.URL, nil))
+
+	err := provider.FireBuildWebhook("httpd", aws.BuildEvent{App: "httpd", Build: "BABCDEFGHI", Status: "failed"})
+	assert.EqualError(t, err, "build webhook responded with status 500")
+}
+
+func TestSetBuildWebhookInvalid(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	assert.EqualError(t, provider.SetBuildWebhook("httpd", "ftp://example.org/hook", nil), "invalid webhook url: ftp://example.org/hook")
+	assert.EqualError(t, provider.SetBuildWebhook("httpd", "https://example.org/hook", []string{"deployed"}), "invalid build event deployed, must be one of started, succeeded, failed")
+}
@@ -1,6 +1,7 @@
 package aws
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -14,6 +15,8 @@ import (
 	"github.com/convox/rack/pkg/structs"
 )
 
+const cleanupConcurrency = 4
+
 func (p *Provider) workerCleanup() {
 	log := logger.New("ns=workers.cleanup")
 
@@ -32,23 +35,33 @@ func (p *Provider) cleanupBuilds(log *logger.Logger) error {
 		return log.Error(err)
 	}
 
-	for _, a := range as {
-		log = log.Replace("app", a.Name)
+	report, _ := forEachApp(context.Background(), as, cleanupConcurrency, forEachAppOptions{}, func(a structs.App) error {
+		alog := log.Replace("app", a.Name)
 
-		log = log.At("images")
-		if count, err := p.cleanupAppImages(a); err != nil {
-			log.Error(err)
+		ilog := alog.At("images")
+		icount, ierr := p.cleanupAppImages(a)
+		if ierr != nil {
+			ilog.Error(ierr)
 		} else {
-			log.Logf("expired=%d", count)
+			ilog.Logf("expired=%d", icount)
 		}
 
-		log = log.At("builds")
-		if count, err := p.cleanupAppBuilds(a); err != nil {
-			log.Error(err)
+		blog := alog.At("builds")
+		bcount, berr := p.cleanupAppBuilds(a)
+		if berr != nil {
+			blog.Error(berr)
 		} else {
-			log.Logf("expired=%d", count)
+			blog.Logf("expired=%d", bcount)
 		}
-	}
+
+		if ierr != nil {
+			return ierr
+		}
+
+		return berr
+	})
+
+	log.Logf("apps=%d failed=%d", report.Total, len(report.Failed()))
 
 	return nil
 }
@@ -78,28 +78,72 @@ func (p *Provider) handleAccountEvents() {
 	}
 }
 
-func (p *Provider) handleCloudformationEvents() {
-	err := p.processQueue("CloudformationEvents", func(body string) error {
-		var raw struct {
-			Message string
-			Subject string
-		}
+// StackNotification is a CloudFormation stack event delivered over SNS
+type StackNotification struct {
+	ClientRequestToken   string
+	LogicalResourceId    string
+	PhysicalResourceId   string
+	ResourceStatus       string
+	ResourceStatusReason string
+	ResourceType         string
+	StackName            string
+}
 
-		if err := json.Unmarshal([]byte(body), &raw); err != nil {
-			return err
+// parseStackNotification parses the key='value' lines of a CloudFormation SNS message
+func parseStackNotification(body string) (*StackNotification, error) {
+	var raw struct {
+		Message string
+		Subject string
+	}
+
+	if err := json.Unmarshal([]byte(body), &raw); err != nil {
+		return nil, err
+	}
+
+	message := map[string]string{}
+
+	for _, line := range strings.Split(raw.Message, "\n") {
+		parts := strings.SplitN(line, "='", 2)
+
+		if len(parts) == 2 {
+			message[strings.TrimSpace(parts[0])] = strings.TrimSuffix(parts[1], "'")
 		}
+	}
 
-		message := map[string]string{}
+	n := &StackNotification{
+		ClientRequestToken:   message["ClientRequestToken"],
+		LogicalResourceId:    message["LogicalResourceId"],
+		PhysicalResourceId:   message["PhysicalResourceId"],
+		ResourceStatus:       message["ResourceStatus"],
+		ResourceStatusReason: message["ResourceStatusReason"],
+		ResourceType:         message["ResourceType"],
+		StackName:            message["StackName"],
+	}
 
-		for _, line := range strings.Split(raw.Message, "\n") {
-			parts := strings.SplitN(line, "='", 2)
+	return n, nil
+}
 
-			if len(parts) == 2 {
-				message[strings.TrimSpace(parts[0])] = strings.TrimSuffix(parts[1], "'")
-			}
+// HandleStackNotification invalidates the cached stack data when a stack changes status
+func (p *Provider) HandleStackNotification(n *StackNotification) {
+	stackNotificationLock.Lock()
+	stackNotificationLast = time.Now()
+	stackNotificationLock.Unlock()
+
+	if n.ResourceType == "AWS::CloudFormation::Stack" && n.StackName != "" {
+		p.invalidateStack(n.StackName)
+	}
+}
+
+func (p *Provider) handleCloudformationEvents() {
+	err := p.processQueue("CloudformationEvents", func(body string) error {
+		n, err := parseStackNotification(body)
+		if err != nil {
+			return err
 		}
 
-		stack := message["StackName"]
+		p.HandleStackNotification(n)
+
+		stack := n.StackName
 
 		group, err := p.getStackLogGroup(stack)
 		if err != nil {
@@ -117,7 +161,7 @@ func (p *Provider) handleCloudformationEvents() {
 			req.SequenceToken = aws.String(token)
 		}
 
-		log := fmt.Sprintf("aws/cfm %s %s %s %s", stack, message["ResourceStatus"], message["LogicalResourceId"], message["ResourceStatusReason"])
+		log := fmt.Sprintf("aws/cfm %s %s %s %s", stack, n.ResourceStatus, n.LogicalResourceId, n.ResourceStatusReason)
 
 		req.LogEvents = []*cloudwatchlogs.InputLogEvent{
 			&cloudwatchlogs.InputLogEvent{
@@ -133,14 +177,14 @@ func (p *Provider) handleCloudformationEvents() {
 
 		cache.Set("logStreamSequenceToken", fmt.Sprintf("%s/%s", group, stream), token, 4*time.Hour)
 
-		if message["ResourceType"] == "AWS::CloudFormation::Stack" && message["ClientRequestToken"] != "null" {
-			switch message["ResourceStatus"] {
+		if n.ResourceType == "AWS::CloudFormation::Stack" && n.ClientRequestToken != "null" {
+			switch n.ResourceStatus {
 			case "ROLLBACK_COMPLETE", "ROLLBACK_FAILED", "UPDATE_COMPLETE", "UPDATE_ROLLBACK_COMPLETE", "UPDATE_ROLLBACK_FAILED":
-				if ss, err := p.describeStacks(&cloudformation.DescribeStacksInput{StackName: aws.String(message["PhysicalResourceId"])}); err == nil && len(ss) == 1 {
+				if ss, err := p.describeStacks(&cloudformation.DescribeStacksInput{StackName: aws.String(n.PhysicalResourceId)}); err == nil && len(ss) == 1 {
 					if tags := stackTags(ss[0]); tags["Type"] == "app" {
-						if parts := strings.SplitN(message["ClientRequestToken"], "-", 2); len(parts) == 2 {
+						if parts := strings.SplitN(n.ClientRequestToken, "-", 2); len(parts) == 2 {
 							var emsg *string
-							switch message["ResourceStatus"] {
+							switch n.ResourceStatus {
 							case "ROLLBACK_COMPLETE", "UPDATE_ROLLBACK_COMPLETE":
 								emsg = options.String("rollback")
 							case "ROLLBACK_FAILED", "UPDATE_ROLLBACK_FAILED":
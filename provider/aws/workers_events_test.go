@@ -0,0 +1,142 @@
+package aws_test
+
+import (
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/convox/rack/provider/aws"
+	"github.com/convox/rack/provider/aws/awsfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStackNotification(t *testing.T) {
+	n, err := aws.ParseStackNotification(stackNotificationBody)
+	require.NoError(t, err)
+
+	assert.Equal(t, &aws.StackNotification{
+		ClientRequestToken:   "null",
+		LogicalResourceId:    "convox-httpd",
+		PhysicalResourceId:   "arn:aws:cloudformation:us-east-1:132866487567:stack/convox-httpd/53df3c30-f763-11e5-bd5d-50d5cd148236",
+		ResourceStatus:       "UPDATE_COMPLETE",
+		ResourceStatusReason: "",
+		ResourceType:         "AWS::CloudFormation::Stack",
+		StackName:            "convox-httpd",
+	}, n)
+}
+
+func TestHandleStackNotificationInvalidates(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.SkipCache = false
+
+	provider.Fake.CloudFormation.Delay = 5 * time.Minute
+
+	provider.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name:       "convox-httpd",
+		Parameters: map[string]string{"Release": "R1"},
+		Tags:       map[string]string{"Name": "httpd", "Rack": "convox"},
+	})
+
+	n, err := aws.ParseStackNotification(stackNotificationBody)
+	require.NoError(t, err)
+
+	// start and finish with a clean cache
+	provider.HandleStackNotification(n)
+	defer aws.SetStackNotificationLast(time.Time{})
+	defer provider.HandleStackNotification(n)
+
+	cf := cloudformation.New(session.New(), provider.Fake.Config())
+
+	_, err = cf.UpdateStack(&cloudformation.UpdateStackInput{
+		StackName:           awssdk.String("convox-httpd"),
+		UsePreviousTemplate: awssdk.Bool(true),
+		Parameters: []*cloudformation.Parameter{
+			{ParameterKey: awssdk.String("Release"), ParameterValue: awssdk.String("R2")},
+		},
+	})
+	require.NoError(t, err)
+
+	a, err := provider.AppGet("httpd")
+	require.NoError(t, err)
+	assert.Equal(t, "updating", a.Status)
+
+	provider.Fake.Clock.Advance(5 * time.Minute)
+
+	// still served from the cache until the notification arrives
+	a, err = provider.AppGet("httpd")
+	require.NoError(t, err)
+	assert.Equal(t, "updating", a.Status)
+
+	provider.HandleStackNotification(n)
+
+	a, err = provider.AppGet("httpd")
+	require.NoError(t, err)
+	assert.Equal(t, "running", a.Status)
+	assert.Equal(t, "R2", a.Release)
+}
+
+func TestHandleStackNotificationInvalidatesArn(t *testing.T) {
+	provider := awsfake.NewTestProvider()
+	defer provider.Close()
+
+	provider.SkipCache = false
+
+	provider.Fake.CloudFormation.Delay = 5 * time.Minute
+
+	arn := provider.Fake.CloudFormation.AddStack(awsfake.Stack{
+		Name:       "convox-httpd",
+		Parameters: map[string]string{"Release": "R1"},
+	})
+
+	n := &aws.StackNotification{ResourceType: "AWS::CloudFormation::Stack", StackName: "convox-httpd"}
+
+	// start and finish with a clean cache
+	provider.HandleStackNotification(n)
+	defer aws.SetStackNotificationLast(time.Time{})
+	defer provider.HandleStackNotification(n)
+
+	ss, err := provider.DescribeStacks(&cloudformation.DescribeStacksInput{StackName: awssdk.String(arn)})
+	require.NoError(t, err)
+	require.Len(t, ss, 1)
+	assert.Equal(t, "CREATE_COMPLETE", *ss[0].StackStatus)
+
+	cf := cloudformation.New(session.New(), provider.Fake.Config())
+
+	_, err = cf.UpdateStack(&cloudformation.UpdateStackInput{
+		StackName:           awssdk.String("convox-httpd"),
+		UsePreviousTemplate: awssdk.Bool(true),
+	})
+	require.NoError(t, err)
+
+	// a notification naming the stack clears what was cached under its arn
+	provider.HandleStackNotification(n)
+
+	ss, err = provider.DescribeStacks(&cloudformation.DescribeStacksInput{StackName: awssdk.String(arn)})
+	require.NoError(t, err)
+	require.Len(t, ss, 1)
+	assert.Equal(t, "UPDATE_IN_PROGRESS", *ss[0].StackStatus)
+}
+
+func TestStackCacheTTL(t *testing.T) {
+	defer aws.SetStackNotificationLast(time.Time{})
+
+	aws.SetStackNotificationLast(time.Now())
+	assert.Equal(t, 60*time.Second, aws.StackCacheTTL())
+
+	aws.SetStackNotificationLast(time.Now().Add(-1 * time.Hour))
+	assert.Equal(t, 5*time.Second, aws.StackCacheTTL())
+
+	aws.SetStackNotificationLast(time.Time{})
+	assert.Equal(t, 5*time.Second, aws.StackCacheTTL())
+}
+
+var stackNotificationBody = `{
+	"Type": "Notification",
+	"Subject": "AWS CloudFormation Notification",
+	"Message": "StackId='arn:aws:cloudformation:us-east-1:132866487567:stack/convox-httpd/53df3c30-f763-11e5-bd5d-50d5cd148236'\nTimestamp='2016-03-31T17:12:16.275Z'\nEventId='e1b1f1a0-f763-11e5-bd5d-50d5cd148236'\nLogicalResourceId='convox-httpd'\nNamespace='132866487567'\nPhysicalResourceId='arn:aws:cloudformation:us-east-1:132866487567:stack/convox-httpd/53df3c30-f763-11e5-bd5d-50d5cd148236'\nPrincipalId='AIDAJ5XTLOOSD2ZCU3TUG'\nResourceProperties='null'\nResourceStatus='UPDATE_COMPLETE'\nResourceStatusReason=''\nResourceType='AWS::CloudFormation::Stack'\nStackName='convox-httpd'\nClientRequestToken='null'\n"
+}`
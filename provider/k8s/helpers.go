@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/convox/rack/pkg/manifest"
 	cv "github.com/convox/rack/provider/k8s/pkg/client/clientset/versioned"
 )
 
@@ -141,6 +142,17 @@ func (p *Provider) volumeFrom(app, service, v string) string {
 	}
 }
 
+// serviceVolumes returns the volumes of s including its mounts of manifest volumes, which are shared across the app
+func serviceVolumes(s manifest.Service) []string {
+	vs := append([]string{}, s.Volumes...)
+
+	for _, m := range s.Mounts {
+		vs = append(vs, fmt.Sprintf("%s:%s", m.Volume, m.Path))
+	}
+
+	return vs
+}
+
 func (p *Provider) volumeName(app, v string) string {
 	hash := sha256.Sum256([]byte(v))
 	name := fmt.Sprintf("%s-%s-%x", p.Rack, app, hash[0:20])
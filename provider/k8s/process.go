@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/convox/rack/pkg/helpers"
+	"github.com/convox/rack/pkg/manifest"
 	"github.com/convox/rack/pkg/options"
 	"github.com/convox/rack/pkg/structs"
 	shellquote "github.com/kballard/go-shellquote"
@@ -292,6 +293,15 @@ func (p *Provider) podSpecFromService(app, service, release string) (*ac.PodSpec
 				c.Args = []string(s.Command)
 			}
 
+			// kubernetes can only run as a numeric user so a named user keeps the image default
+			if u, err := manifest.ParseUser(s.User); err == nil && u.Uid != nil {
+				c.SecurityContext = &ac.SecurityContext{RunAsUser: u.Uid, RunAsGroup: u.Gid}
+			}
+
+			if s.WorkingDirectory != "" {
+				c.WorkingDir = s.WorkingDirectory
+			}
+
 			for k, v := range s.EnvironmentDefaults() {
 				env[k] = v
 			}
@@ -316,11 +326,11 @@ func (p *Provider) podSpecFromService(app, service, release string) (*ac.PodSpec
 
 			c.Image = fmt.Sprintf("%s:%s.%s", repo, service, r.Build)
 
-			for _, v := range p.volumeSources(app, s.Name, s.Volumes) {
+			for _, v := range p.volumeSources(app, s.Name, serviceVolumes(*s)) {
 				vs = append(vs, p.podVolume(app, v))
 			}
 
-			for _, v := range s.Volumes {
+			for _, v := range serviceVolumes(*s) {
 				to, err := volumeTo(v)
 				if err != nil {
 					return nil, err
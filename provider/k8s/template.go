@@ -87,6 +87,9 @@ func (p *Provider) templateHelpers() template.FuncMap {
 			sort.Strings(ks)
 			return ks
 		},
+		"serviceVolumes": func(s manifest.Service) []string {
+			return serviceVolumes(s)
+		},
 		"systemHost": func() string {
 			return p.Engine.SystemHost()
 		},
@@ -96,6 +99,10 @@ func (p *Provider) templateHelpers() template.FuncMap {
 		"upper": func(s string) string {
 			return strings.ToUpper(s)
 		},
+		"user": func(s manifest.Service) manifest.ServiceUser {
+			u, _ := manifest.ParseUser(s.User)
+			return u
+		},
 		"volumeFrom": func(app, service, v string) string {
 			return p.volumeFrom(app, service, v)
 		},